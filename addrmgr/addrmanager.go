@@ -17,6 +17,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -47,6 +48,10 @@ type AddrManager struct {
 	lamtx          sync.Mutex
 	localAddresses map[string]*localAddress
 	version        int
+
+	// anchors are the addresses of block-relay-only peers to reconnect to
+	// at the next start.
+	anchors []*wire.NetAddress
 }
 
 type serializedKnownAddress struct {
@@ -67,6 +72,7 @@ type serializedAddrManager struct {
 	Addresses    []*serializedKnownAddress
 	NewBuckets   [newBucketCount][]string // string is NetAddressKey
 	TriedBuckets [triedBucketCount][]string
+	Anchors      []string // string is NetAddressKey
 }
 
 type localAddress struct {
@@ -404,6 +410,9 @@ func (a *AddrManager) savePeers() {
 			j++
 		}
 	}
+	for _, na := range a.anchors {
+		sam.Anchors = append(sam.Anchors, NetAddressKey(na))
+	}
 
 	w, err := os.Create(a.peersFile)
 	if err != nil {
@@ -530,6 +539,19 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		}
 	}
 
+	for _, val := range sam.Anchors {
+		services := wire.SFNodeNetwork
+		if ka, ok := a.addrIndex[val]; ok {
+			services = ka.na.Services
+		}
+		na, err := a.DeserializeNetAddress(val, services)
+		if err != nil {
+			return fmt.Errorf("failed to deserialize anchor "+
+				"%s: %v", val, err)
+		}
+		a.anchors = append(a.anchors, na)
+	}
+
 	// Sanity checking.
 	for k, v := range a.addrIndex {
 		if v.refs == 0 && !v.tried {
@@ -706,6 +728,7 @@ func (a *AddrManager) getAddresses() []*wire.NetAddress {
 func (a *AddrManager) reset() {
 
 	a.addrIndex = make(map[string]*KnownAddress)
+	a.anchors = nil
 
 	// fill key with bytes from a good random source.
 	io.ReadFull(crand.Reader, a.key[:])
@@ -884,6 +907,28 @@ func (a *AddrManager) Connected(addr *wire.NetAddress) {
 	}
 }
 
+// SetAnchors sets the addresses of the block-relay-only peers to reconnect to
+// at the next start.  They are saved along with the known addresses.
+func (a *AddrManager) SetAnchors(addrs []*wire.NetAddress) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	a.anchors = make([]*wire.NetAddress, len(addrs))
+	copy(a.anchors, addrs)
+}
+
+// TakeAnchors returns the addresses of the block-relay-only peers that were
+// saved by the previous run and forgets them, so they are not reconnected to
+// again should the next shutdown not be clean.
+func (a *AddrManager) TakeAnchors() []*wire.NetAddress {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	anchors := a.anchors
+	a.anchors = nil
+	return anchors
+}
+
 // Good marks the given address as good.  To be called after a successful
 // connection and version exchange.  If the address is unknown to the address
 // manager it will be ignored.
@@ -1135,6 +1180,35 @@ func (a *AddrManager) GetBestLocalAddress(remoteAddr *wire.NetAddress) *wire.Net
 	return bestAddress
 }
 
+// LocalAddress describes a known local address that is advertised to peers
+// along with its score, where addresses with higher scores are preferred.
+type LocalAddress struct {
+	NetAddress *wire.NetAddress
+	Score      AddressPriority
+}
+
+// LocalAddresses returns all known local addresses ordered by descending score.
+func (a *AddrManager) LocalAddresses() []LocalAddress {
+	a.lamtx.Lock()
+	addrs := make([]LocalAddress, 0, len(a.localAddresses))
+	for _, la := range a.localAddresses {
+		addrs = append(addrs, LocalAddress{
+			NetAddress: la.na,
+			Score:      la.score,
+		})
+	}
+	a.lamtx.Unlock()
+
+	sort.Slice(addrs, func(i, j int) bool {
+		if addrs[i].Score != addrs[j].Score {
+			return addrs[i].Score > addrs[j].Score
+		}
+		return NetAddressKey(addrs[i].NetAddress) <
+			NetAddressKey(addrs[j].NetAddress)
+	})
+	return addrs
+}
+
 // New returns a new bitcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, error)) *AddrManager {
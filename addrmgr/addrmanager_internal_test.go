@@ -118,6 +118,58 @@ func TestAddrManagerSerialization(t *testing.T) {
 	assertAddrs(t, addrMgr, expectedAddrs)
 }
 
+// TestAddrManagerAnchors ensures that the anchors are persisted along with the
+// known addresses and are only returned once after being loaded.
+func TestAddrManagerAnchors(t *testing.T) {
+	t.Parallel()
+
+	tempDir, err := ioutil.TempDir("", "addrmgr")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	addrMgr := New(tempDir, nil)
+
+	// Add an address that is also an anchor along with an anchor that is
+	// not a known address.
+	known := &wire.NetAddress{
+		Services: wire.SFNodeNetwork | wire.SFNodeWitness,
+		IP:       net.ParseIP("173.194.115.66"),
+		Port:     8333,
+	}
+	addrMgr.AddAddress(known, randAddr(t))
+	unknown := &wire.NetAddress{
+		Services: wire.SFNodeNetwork,
+		IP:       net.ParseIP("2001:db9::1"),
+		Port:     8333,
+	}
+	addrMgr.SetAnchors([]*wire.NetAddress{known, unknown})
+
+	addrMgr.savePeers()
+	addrMgr = New(tempDir, nil)
+	addrMgr.loadPeers()
+
+	anchors := addrMgr.TakeAnchors()
+	if len(anchors) != 2 {
+		t.Fatalf("expected 2 anchors, got %d", len(anchors))
+	}
+	assertAddr(t, anchors[0], known)
+	assertAddr(t, anchors[1], unknown)
+
+	// The anchors must be forgotten once they have been taken.
+	if anchors := addrMgr.TakeAnchors(); len(anchors) != 0 {
+		t.Fatalf("expected no anchors, got %d", len(anchors))
+	}
+	addrMgr.savePeers()
+	addrMgr = New(tempDir, nil)
+	addrMgr.loadPeers()
+	if anchors := addrMgr.TakeAnchors(); len(anchors) != 0 {
+		t.Fatalf("expected no anchors after reload, got %d",
+			len(anchors))
+	}
+}
+
 // TestAddrManagerV1ToV2 ensures that we can properly upgrade the serialized
 // version of the address manager from v1 to v2.
 func TestAddrManagerV1ToV2(t *testing.T) {
@@ -208,6 +208,49 @@ func TestAddLocalAddress(t *testing.T) {
 	}
 }
 
+// TestLocalAddresses ensures the known local addresses are returned with their
+// scores ordered by descending score.
+func TestLocalAddresses(t *testing.T) {
+	amgr := addrmgr.New("testlocaladdresses", nil)
+	addrs := []struct {
+		ip       string
+		priority addrmgr.AddressPriority
+	}{
+		{"2620:100::1", addrmgr.InterfacePrio},
+		{"204.124.1.1", addrmgr.InterfacePrio},
+		{"204.124.1.1", addrmgr.BoundPrio},
+		{"192.168.0.100", addrmgr.ManualPrio},
+	}
+	for _, addr := range addrs {
+		na := wire.NewNetAddressIPPort(net.ParseIP(addr.ip), 8333, 0)
+		amgr.AddLocalAddress(na, addr.priority)
+	}
+
+	// The unroutable address is not known and the score of an address
+	// added again with a higher priority is raised above it.
+	want := []struct {
+		ip    string
+		score addrmgr.AddressPriority
+	}{
+		{"204.124.1.1", addrmgr.BoundPrio + 1},
+		{"2620:100::1", addrmgr.InterfacePrio},
+	}
+	localAddrs := amgr.LocalAddresses()
+	if len(localAddrs) != len(want) {
+		t.Fatalf("LocalAddresses: got %d addresses, want %d",
+			len(localAddrs), len(want))
+	}
+	for i, la := range localAddrs {
+		if la.NetAddress.IP.String() != want[i].ip ||
+			la.Score != want[i].score {
+
+			t.Errorf("LocalAddresses #%d: got %s with score %d, "+
+				"want %s with score %d", i, la.NetAddress.IP,
+				la.Score, want[i].ip, want[i].score)
+		}
+	}
+}
+
 func TestAttempt(t *testing.T) {
 	n := addrmgr.New("testattempt", lookupFunc)
 
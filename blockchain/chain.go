@@ -8,12 +8,14 @@ package blockchain
 import (
 	"container/list"
 	"fmt"
+	"math/big"
 	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -90,6 +92,11 @@ func newBestState(node *blockNode, blockSize, blockWeight, numTxns,
 // follow all rules, orphan handling, checkpoint handling, and best chain
 // selection with reorganization.
 type BlockChain struct {
+	// utxoViewStats tracks the utxo view hits and misses while validating
+	// blocks.  It is first so its fields are 64-bit aligned for atomic
+	// access on 32-bit systems.
+	utxoViewStats utxoViewStats
+
 	// The following fields are set when the instance is created and can't
 	// be changed afterwards, so there is no need to protect them with a
 	// separate mutex.
@@ -101,6 +108,7 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	indexManager        IndexManager
 	hashCache           *txscript.HashCache
+	validationTimer     func(ValidationStage, time.Duration)
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -176,6 +184,11 @@ type BlockChain struct {
 	// activated.
 	unknownRulesWarned bool
 
+	// warning is the most recent warning about unknown rules that are
+	// either about to activate or have already been activated.  It is
+	// exposed via Warnings and protected by the chain lock.
+	warning string
+
 	// The notifications field stores a slice of callbacks to be executed on
 	// certain blockchain events.
 	notificationsLock sync.RWMutex
@@ -875,7 +888,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err = view.fetchInputUtxos(b.db, block)
+		err = view.fetchInputUtxos(b.db, &b.utxoViewStats, block)
 		if err != nil {
 			return err
 		}
@@ -942,7 +955,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// checkConnectBlock gets skipped, we still need to update the UTXO
 		// view.
 		if b.index.NodeStatus(n).KnownValid() {
-			err = view.fetchInputUtxos(b.db, block)
+			err = view.fetchInputUtxos(b.db, &b.utxoViewStats, block)
 			if err != nil {
 				return err
 			}
@@ -963,7 +976,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 		// In the case the block is determined to be invalid due to a
 		// rule violation, mark it as invalid and mark all of its
 		// descendants as having an invalid ancestor.
-		err = b.checkConnectBlock(n, block, view, nil)
+		err = b.checkConnectBlock(n, block, view, nil, BFNone)
 		if err != nil {
 			if _, ok := err.(RuleError); ok {
 				b.index.SetStatusFlags(n, statusValidateFailed)
@@ -994,7 +1007,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.db, &b.utxoViewStats, block)
 		if err != nil {
 			return err
 		}
@@ -1021,7 +1034,7 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 
 		// Load all of the utxos referenced by the block that aren't
 		// already in the view.
-		err := view.fetchInputUtxos(b.db, block)
+		err := view.fetchInputUtxos(b.db, &b.utxoViewStats, block)
 		if err != nil {
 			return err
 		}
@@ -1046,13 +1059,14 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List) error
 	// Log the point where the chain forked and old and new best chain
 	// heads.
 	if forkNode != nil {
-		log.Infof("REORGANIZE: Chain forks at %v (height %v)", forkNode.hash,
-			forkNode.height)
+		log.Infof("REORGANIZE: Chain forks at %v (height %v)",
+			jsonlog.BlockHash(&forkNode.hash),
+			jsonlog.Height(forkNode.height))
 	}
 	log.Infof("REORGANIZE: Old best chain head was %v (height %v)",
-		&oldBest.hash, oldBest.height)
+		jsonlog.BlockHash(&oldBest.hash), jsonlog.Height(oldBest.height))
 	log.Infof("REORGANIZE: New best chain head is %v (height %v)",
-		newBest.hash, newBest.height)
+		jsonlog.BlockHash(&newBest.hash), jsonlog.Height(newBest.height))
 
 	return nil
 }
@@ -1095,11 +1109,13 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		// Perform several checks to verify the block can be connected
 		// to the main chain without violating any rules and without
 		// actually connecting the block.
+		connectStart := time.Now()
 		view := NewUtxoViewpoint()
 		view.SetBestHash(parentHash)
 		stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, view, &stxos)
+			err := b.checkConnectBlock(node, block, view, &stxos,
+				flags)
 			if err == nil {
 				b.index.SetStatusFlags(node, statusValid)
 			} else if _, ok := err.(RuleError); ok {
@@ -1120,7 +1136,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
-			err := view.fetchInputUtxos(b.db, block)
+			err := view.fetchInputUtxos(b.db, &b.utxoViewStats, block)
 			if err != nil {
 				return false, err
 			}
@@ -1154,6 +1170,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 			b.index.SetStatusFlags(node, statusValid)
 			flushIndexState()
 		}
+		b.observeValidation(StageConnect, connectStart)
 
 		return true, nil
 	}
@@ -1170,11 +1187,11 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 		if fork.hash.IsEqual(parentHash) {
 			log.Infof("FORK: Block %v forks the chain at height %d"+
 				"/block %v, but does not cause a reorganize",
-				node.hash, fork.height, fork.hash)
+				jsonlog.BlockHash(&node.hash), fork.height, fork.hash)
 		} else {
 			log.Infof("EXTEND FORK: Block %v extends a side chain "+
 				"which forks the chain at height %d/block %v",
-				node.hash, fork.height, fork.hash)
+				jsonlog.BlockHash(&node.hash), fork.height, fork.hash)
 		}
 
 		return false, nil
@@ -1190,7 +1207,8 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 	detachNodes, attachNodes := b.getReorganizeNodes(node)
 
 	// Reorganize the chain.
-	log.Infof("REORGANIZE: Block %v is causing a reorganize.", node.hash)
+	log.Infof("REORGANIZE: Block %v is causing a reorganize.",
+		jsonlog.BlockHash(&node.hash))
 	err := b.reorganizeChain(detachNodes, attachNodes)
 
 	// Either getReorganizeNodes or reorganizeChain could have made unsaved
@@ -1208,6 +1226,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *btcutil.Block, fla
 // factors are used to guess, but the key factors that allow the chain to
 // believe it is current are:
 //  - Latest block height is after the latest checkpoint (if enabled)
+//  - Latest block has at least the minimum chain work (if defined)
 //  - Latest block has a timestamp newer than 24 hours ago
 //
 // This function MUST be called with the chain state lock held (for reads).
@@ -1219,6 +1238,13 @@ func (b *BlockChain) isCurrent() bool {
 		return false
 	}
 
+	// Not current if the latest main (best) chain doesn't have the minimum
+	// amount of work defined by the network parameters.
+	minWork := b.chainParams.MinimumChainWork
+	if minWork != nil && b.bestChain.Tip().workSum.Cmp(minWork) < 0 {
+		return false
+	}
+
 	// Not current if the latest best block has a timestamp before 24 hours
 	// ago.
 	//
@@ -1232,6 +1258,7 @@ func (b *BlockChain) isCurrent() bool {
 // factors are used to guess, but the key factors that allow the chain to
 // believe it is current are:
 //  - Latest block height is after the latest checkpoint (if enabled)
+//  - Latest block has at least the minimum chain work (if defined)
 //  - Latest block has a timestamp newer than 24 hours ago
 //
 // This function is safe for concurrent access.
@@ -1267,6 +1294,37 @@ func (b *BlockChain) HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, error
 	return node.Header(), nil
 }
 
+// ChainWork returns the total amount of work in the chain up to and including
+// the block identified by the given hash or an error if it doesn't exist.  Note
+// that this will return the work for blocks in both the main and side chains.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainWork(hash *chainhash.Hash) (*big.Int, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		err := fmt.Errorf("block %s is not known", hash)
+		return nil, err
+	}
+
+	return new(big.Int).Set(node.workSum), nil
+}
+
+// PastMedianTimeByHash returns the median time of the block identified by the
+// given hash and the blocks prior to it, as used to validate the timestamp of
+// the block after it, or an error if it doesn't exist.  Note that this works
+// for blocks in both the main and side chains.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PastMedianTimeByHash(hash *chainhash.Hash) (time.Time, error) {
+	node := b.index.LookupNode(hash)
+	if node == nil {
+		err := fmt.Errorf("block %s is not known", hash)
+		return time.Time{}, err
+	}
+
+	return node.CalcPastMedianTime(), nil
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
@@ -1700,6 +1758,14 @@ type Config struct {
 	// This field can be nil if the caller is not interested in using a
 	// signature cache.
 	HashCache *txscript.HashCache
+
+	// ValidationTimer defines a function that is invoked with the time spent
+	// in each timed stage of block validation.  It is invoked with the chain
+	// lock held, so it must return quickly and must not call back into the
+	// chain.
+	//
+	// This field can be nil if the caller is not interested in the timings.
+	ValidationTimer func(stage ValidationStage, elapsed time.Duration)
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1750,6 +1816,7 @@ func New(config *Config) (*BlockChain, error) {
 		blocksPerRetarget:   int32(targetTimespan / targetTimePerBlock),
 		index:               newBlockIndex(config.DB, params),
 		hashCache:           config.HashCache,
+		validationTimer:     config.ValidationTimer,
 		bestChain:           newChainView(nil),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
@@ -1785,7 +1852,8 @@ func New(config *Config) (*BlockChain, error) {
 
 	bestNode := b.bestChain.Tip()
 	log.Infof("Chain state (height %d, hash %v, totaltx %d, work %v)",
-		bestNode.height, bestNode.hash, b.stateSnapshot.TotalTxns,
+		jsonlog.Height(bestNode.height), jsonlog.BlockHash(&bestNode.hash),
+		b.stateSnapshot.TotalTxns,
 		bestNode.workSum)
 
 	return &b, nil
@@ -14,6 +14,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
@@ -67,6 +68,11 @@ var (
 	// unspent transaction output set.
 	utxoSetBucketName = []byte("utxosetv2")
 
+	// dbRepairKeyName is the name of the db key that is set while RepairDB
+	// rebuilds the utxo set.  It houses the hash of the block the main
+	// chain was truncated to.
+	dbRepairKeyName = []byte("dbrepair")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -1104,16 +1110,23 @@ func (b *BlockChain) createChainState() error {
 func (b *BlockChain) initChainState() error {
 	// Determine the state of the chain database. We may need to initialize
 	// everything from scratch or upgrade certain buckets.
-	var initialized, hasBlockIndex bool
+	var initialized, hasBlockIndex, repairing bool
 	err := b.db.View(func(dbTx database.Tx) error {
 		initialized = dbTx.Metadata().Get(chainStateKeyName) != nil
 		hasBlockIndex = dbTx.Metadata().Bucket(blockIndexBucketName) != nil
+		repairing = dbTx.Metadata().Get(dbRepairKeyName) != nil
 		return nil
 	})
 	if err != nil {
 		return err
 	}
 
+	// The utxo set is incomplete while a repair of the database is in
+	// progress, so refuse to use it until the repair is finished.
+	if repairing {
+		return errRepairInProgress
+	}
+
 	if !initialized {
 		// At this point the database has not already been initialized, so
 		// initialize both it and the chain state to the genesis block.
@@ -1225,7 +1238,8 @@ func (b *BlockChain) initChainState() error {
 				log.Infof("Block %v (height=%v) ancestor of "+
 					"chain tip not marked as valid, "+
 					"upgrading to valid for consistency",
-					iterNode.hash, iterNode.height)
+					jsonlog.BlockHash(&iterNode.hash),
+					jsonlog.Height(iterNode.height))
 
 				b.index.SetStatusFlags(iterNode, statusValid)
 			}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcutil"
 )
@@ -73,8 +74,8 @@ func (b *BlockChain) verifyCheckpoint(height int32, hash *chainhash.Hash) bool {
 		return false
 	}
 
-	log.Infof("Verified checkpoint at height %d/block %s", checkpoint.Height,
-		checkpoint.Hash)
+	log.Infof("Verified checkpoint at height %d/block %s",
+		jsonlog.Height(checkpoint.Height), jsonlog.BlockHash(checkpoint.Hash))
 	return true
 }
 
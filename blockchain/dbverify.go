@@ -0,0 +1,1145 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// dbVerifyProgressInterval is the minimum amount of time between
+	// progress messages while verifying or repairing the database.
+	dbVerifyProgressInterval = 10 * time.Second
+
+	// maxRepairBatchBlocks is the maximum number of blocks replayed into a
+	// single database transaction while rebuilding the utxo set.
+	maxRepairBatchBlocks = 2000
+
+	// maxRepairBatchUtxos is the maximum number of utxo entries kept in
+	// memory while rebuilding the utxo set before they are written to the
+	// database.
+	maxRepairBatchUtxos = 500000
+
+	// maxRepairDeletions is the maximum number of utxo entries removed in a
+	// single database transaction while clearing the utxo set.
+	maxRepairDeletions = 200000
+)
+
+// errRepairInProgress indicates that the database can't be used since a repair
+// of it was interrupted before the utxo set was rebuilt.
+var errRepairInProgress = errors.New("the utxo set is incomplete since a " +
+	"repair of the database was interrupted -- run the repair again to " +
+	"finish it")
+
+// DBCheck identifies one of the consistency checks performed by VerifyDB.
+type DBCheck int
+
+// These constants define the consistency checks performed by VerifyDB.
+const (
+	// DBCheckChainState identifies the check of the stored best chain
+	// state against the block index.
+	DBCheckChainState DBCheck = iota
+
+	// DBCheckBlockIndex identifies the checks of the block index entries
+	// that house every known block header and its validation status.
+	DBCheckBlockIndex
+
+	// DBCheckMainChain identifies the checks of the hash to height and
+	// height to hash mappings of the main chain.
+	DBCheckMainChain
+
+	// DBCheckBlockData identifies the checks of the block data stored in
+	// the flat files of the database.
+	DBCheckBlockData
+
+	// DBCheckSpendJournal identifies the checks of the spend journal
+	// entries of the main chain blocks.
+	DBCheckSpendJournal
+
+	// DBCheckUtxoSet identifies the check of the utxo set against a full
+	// replay of the main chain.
+	DBCheckUtxoSet
+)
+
+// Map of DBCheck values back to their constant names for pretty printing.
+var dbCheckStrings = map[DBCheck]string{
+	DBCheckChainState:   "chain state",
+	DBCheckBlockIndex:   "block index",
+	DBCheckMainChain:    "main chain index",
+	DBCheckBlockData:    "block data",
+	DBCheckSpendJournal: "spend journal",
+	DBCheckUtxoSet:      "utxo set",
+}
+
+// String returns the DBCheck as a human-readable name.
+func (c DBCheck) String() string {
+	if s := dbCheckStrings[c]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown DBCheck (%d)", int(c))
+}
+
+// DBInconsistency describes a single inconsistency found by VerifyDB.
+type DBInconsistency struct {
+	// Check is the consistency check that found the inconsistency.
+	Check DBCheck
+
+	// Hash is the hash of the block the inconsistency is associated with.
+	// It is the zero hash when the inconsistency is not associated with a
+	// specific block.
+	Hash chainhash.Hash
+
+	// Height is the height of the main chain block the inconsistency is
+	// associated with.  It is -1 when the inconsistency is not associated
+	// with a main chain block.
+	Height int32
+
+	// Description describes the inconsistency.
+	Description string
+}
+
+// String returns a human-readable description of the inconsistency.
+func (i *DBInconsistency) String() string {
+	switch {
+	case i.Height != -1:
+		return fmt.Sprintf("%v: block %v (height %d): %s", i.Check,
+			i.Hash, i.Height, i.Description)
+	case i.Hash != zeroHash:
+		return fmt.Sprintf("%v: block %v: %s", i.Check, i.Hash,
+			i.Description)
+	default:
+		return fmt.Sprintf("%v: %s", i.Check, i.Description)
+	}
+}
+
+// DBVerifyResult houses the results of a database consistency check.
+type DBVerifyResult struct {
+	// TipHash and TipHeight identify the best block according to the
+	// stored chain state.  TipHeight is -1 when the chain state could not
+	// be loaded.
+	TipHash   chainhash.Hash
+	TipHeight int32
+
+	// ConsistentHeight is the height of the last main chain block for
+	// which it and all of its ancestors passed every per-block check.  It
+	// is -1 when the chain state could not be loaded or the genesis block
+	// itself is inconsistent.
+	ConsistentHeight int32
+
+	// UtxoSetChecked indicates whether the utxo set was compared against
+	// a replay of the main chain.  The replay is not possible when the
+	// data or spend journal entry of a main chain block is unavailable.
+	UtxoSetChecked bool
+
+	// NumUtxos is the number of entries in the stored utxo set.
+	NumUtxos uint64
+
+	// Inconsistencies are the inconsistencies that were found.
+	Inconsistencies []DBInconsistency
+
+	mainChain []chainhash.Hash
+}
+
+// MainChainHash returns the hash of the main chain block at the passed height
+// according to the stored chain state.  It returns false when there is no such
+// block.
+func (r *DBVerifyResult) MainChainHash(height int32) (*chainhash.Hash, bool) {
+	if height < 0 || height >= int32(len(r.mainChain)) {
+		return nil, false
+	}
+	return &r.mainChain[height], true
+}
+
+// Consistent returns whether no inconsistencies were found.
+func (r *DBVerifyResult) Consistent() bool {
+	return len(r.Inconsistencies) == 0
+}
+
+// DBRepairResult houses the results of a database repair.
+type DBRepairResult struct {
+	// Verify is the result of the verification performed prior to the
+	// repair.
+	Verify *DBVerifyResult
+
+	// TipHash and TipHeight identify the best block after the repair.
+	TipHash   chainhash.Hash
+	TipHeight int32
+
+	// RemovedBlocks is the number of entries removed from the block index.
+	// The blocks they refer to are downloaded again as needed.
+	RemovedBlocks int
+
+	// RebuiltUtxoSet indicates whether the utxo set was rebuilt by
+	// replaying the main chain.
+	RebuiltUtxoSet bool
+}
+
+// utxoSetHash is an order independent hash of a set of unspent transaction
+// outputs.  It is the sum, modulo 2^256, of the sha256 hash of each output,
+// which allows outputs to be added and removed in any order.  It is only
+// intended for detecting corruption and is not secure against an adversary
+// that is able to choose the outputs.
+type utxoSetHash [4]uint64
+
+// add adds the passed element hash to the set hash.
+func (h *utxoSetHash) add(element *[sha256.Size]byte) {
+	var carry uint64
+	for i := range h {
+		word := binary.LittleEndian.Uint64(element[i*8:])
+		h[i], carry = bits.Add64(h[i], word, carry)
+	}
+}
+
+// remove removes the passed element hash from the set hash.
+func (h *utxoSetHash) remove(element *[sha256.Size]byte) {
+	var borrow uint64
+	for i := range h {
+		word := binary.LittleEndian.Uint64(element[i*8:])
+		h[i], borrow = bits.Sub64(h[i], word, borrow)
+	}
+}
+
+// utxoSetElement returns the hash used to represent the passed unspent output
+// in a utxoSetHash.
+func utxoSetElement(outpoint wire.OutPoint, amount int64, pkScript []byte,
+	height int32, isCoinBase bool) [sha256.Size]byte {
+
+	var buf [chainhash.HashSize + 17]byte
+	copy(buf[:], outpoint.Hash[:])
+	offset := chainhash.HashSize
+	binary.LittleEndian.PutUint32(buf[offset:], outpoint.Index)
+	offset += 4
+	binary.LittleEndian.PutUint32(buf[offset:], uint32(height))
+	offset += 4
+	if isCoinBase {
+		buf[offset] = 1
+	}
+	offset++
+	binary.LittleEndian.PutUint64(buf[offset:], uint64(amount))
+
+	hasher := sha256.New()
+	hasher.Write(buf[:])
+	hasher.Write(pkScript)
+	var element [sha256.Size]byte
+	copy(element[:], hasher.Sum(nil))
+	return element
+}
+
+// dbVerifier houses the state loaded directly from the database by VerifyDB
+// and RepairDB.  Unlike initChainState, loading it tolerates corruption so it
+// can be reported.
+type dbVerifier struct {
+	db        database.DB
+	params    *chaincfg.Params
+	interrupt <-chan struct{}
+	result    *DBVerifyResult
+
+	// nodes are the loaded block index entries in the order they are
+	// stored, which is by height, and index maps their hashes to them.
+	nodes []*blockNode
+	index map[chainhash.Hash]*blockNode
+
+	// badRows are the keys of block index entries that could not be
+	// loaded and badData are the blocks whose stored data is unusable.
+	badRows [][]byte
+	badData map[*blockNode]struct{}
+
+	// fatal is set when the chain state can't be loaded, in which case no
+	// further checks are possible.
+	fatal bool
+
+	// tip is the best block according to the stored chain state and
+	// mainChain are the blocks of the main chain indexed by height.
+	state     bestChainState
+	tip       *blockNode
+	mainChain []*blockNode
+
+	// totalTxns is the total number of transactions in the main chain up
+	// to and including the block at each height.
+	totalTxns []uint64
+
+	// replayOK is cleared once a block can't be replayed, replayHash is
+	// the utxo set hash of the replay, and coinbaseOuts tracks the unspent
+	// coinbase outputs created before BIP0034 since those may be
+	// overwritten by duplicate coinbase transactions.
+	replayOK     bool
+	replayHash   utxoSetHash
+	coinbaseOuts map[wire.OutPoint]int32
+}
+
+// report records an inconsistency.  The node is nil when the inconsistency is
+// not associated with a specific block.
+func (v *dbVerifier) report(check DBCheck, node *blockNode, format string, args ...interface{}) {
+	inconsistency := DBInconsistency{
+		Check:       check,
+		Height:      -1,
+		Description: fmt.Sprintf(format, args...),
+	}
+	if node != nil {
+		inconsistency.Hash = node.hash
+		if v.onMainChain(node) {
+			inconsistency.Height = node.height
+			if node.height <= v.result.ConsistentHeight {
+				v.result.ConsistentHeight = node.height - 1
+			}
+		}
+	}
+	v.result.Inconsistencies = append(v.result.Inconsistencies,
+		inconsistency)
+}
+
+// reportHash records an inconsistency that is associated with a block which
+// is not in the block index.
+func (v *dbVerifier) reportHash(check DBCheck, hash *chainhash.Hash, format string, args ...interface{}) {
+	v.result.Inconsistencies = append(v.result.Inconsistencies,
+		DBInconsistency{
+			Check:       check,
+			Hash:        *hash,
+			Height:      -1,
+			Description: fmt.Sprintf(format, args...),
+		})
+}
+
+// onMainChain returns whether the passed node is part of the main chain.
+func (v *dbVerifier) onMainChain(node *blockNode) bool {
+	return node.height < int32(len(v.mainChain)) &&
+		v.mainChain[node.height] == node
+}
+
+// loadChainState loads the best chain state and the block index and reports
+// any inconsistencies encountered while doing so.
+func (v *dbVerifier) loadChainState() error {
+	return v.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		buckets := [][]byte{blockIndexBucketName, hashIndexBucketName,
+			heightIndexBucketName, spendJournalBucketName,
+			utxoSetBucketName}
+		for _, bucketName := range buckets {
+			if meta.Bucket(bucketName) == nil {
+				v.report(DBCheckChainState, nil, "bucket %q does "+
+					"not exist", bucketName)
+				v.fatal = true
+			}
+		}
+		if v.fatal {
+			return nil
+		}
+
+		if meta.Get(dbRepairKeyName) != nil {
+			v.report(DBCheckUtxoSet, nil, "utxo set is incomplete "+
+				"since a repair was interrupted")
+		}
+
+		serializedState := meta.Get(chainStateKeyName)
+		if serializedState == nil {
+			v.report(DBCheckChainState, nil, "best chain state does "+
+				"not exist")
+			v.fatal = true
+			return nil
+		}
+		state, err := deserializeBestChainState(serializedState)
+		if err != nil {
+			v.report(DBCheckChainState, nil, "%v", err)
+			v.fatal = true
+			return nil
+		}
+		v.state = state
+
+		// Load all entries of the block index.  They are keyed by height
+		// so parents are always loaded before their children.
+		cursor := meta.Bucket(blockIndexBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			header, status, err := deserializeBlockRow(cursor.Value())
+			if err != nil {
+				v.report(DBCheckBlockIndex, nil, "unable to decode "+
+					"entry %x: %v", key, err)
+				v.badRows = append(v.badRows, copyBytes(key))
+				continue
+			}
+
+			hash := header.BlockHash()
+			if len(key) != chainhash.HashSize+4 ||
+				!bytes.Equal(key[4:], hash[:]) {
+
+				v.reportHash(DBCheckBlockIndex, &hash, "entry "+
+					"key %x does not match the header", key)
+				v.badRows = append(v.badRows, copyBytes(key))
+				continue
+			}
+			if _, ok := v.index[hash]; ok {
+				v.reportHash(DBCheckBlockIndex, &hash, "duplicate "+
+					"entry %x", key)
+				v.badRows = append(v.badRows, copyBytes(key))
+				continue
+			}
+
+			var parent *blockNode
+			if hash != *v.params.GenesisHash {
+				parent = v.index[header.PrevBlock]
+				if parent == nil {
+					v.reportHash(DBCheckBlockIndex, &hash,
+						"parent %v is not in the block "+
+							"index", header.PrevBlock)
+					v.badRows = append(v.badRows,
+						copyBytes(key))
+					continue
+				}
+			}
+			node := newBlockNode(header, parent)
+			node.status = status
+			if uint32(node.height) != binary.BigEndian.Uint32(key) {
+				v.reportHash(DBCheckBlockIndex, &hash, "entry "+
+					"key height %d does not match height %d",
+					binary.BigEndian.Uint32(key), node.height)
+				v.badRows = append(v.badRows, copyBytes(key))
+				continue
+			}
+			v.nodes = append(v.nodes, node)
+			v.index[hash] = node
+		}
+
+		// Build the main chain from the stored best block.
+		tip := v.index[state.hash]
+		if tip == nil {
+			v.reportHash(DBCheckChainState, &state.hash, "best block "+
+				"is not in the block index")
+			v.fatal = true
+			return nil
+		}
+		v.tip = tip
+		v.mainChain = make([]*blockNode, tip.height+1)
+		v.result.mainChain = make([]chainhash.Hash, tip.height+1)
+		for node := tip; node != nil; node = node.parent {
+			v.mainChain[node.height] = node
+			v.result.mainChain[node.height] = node.hash
+		}
+		v.result.TipHash = tip.hash
+		v.result.TipHeight = tip.height
+		v.result.ConsistentHeight = tip.height
+
+		if state.height != uint32(tip.height) {
+			v.report(DBCheckChainState, nil, "best chain state height "+
+				"%d does not match block index height %d",
+				state.height, tip.height)
+		}
+		if state.workSum.Cmp(tip.workSum) != 0 {
+			v.report(DBCheckChainState, nil, "best chain state work "+
+				"%v does not match block index work %v",
+				state.workSum, tip.workSum)
+		}
+		return nil
+	})
+}
+
+// checkBlockIndex ensures the block data of every entry in the block index
+// that claims to have it is available in the database.
+func (v *dbVerifier) checkBlockIndex() error {
+	return v.db.View(func(dbTx database.Tx) error {
+		for _, node := range v.nodes {
+			if !node.status.HaveData() {
+				continue
+			}
+			hasBlock, err := dbTx.HasBlock(&node.hash)
+			if err != nil {
+				return err
+			}
+			if !hasBlock {
+				v.report(DBCheckBlockIndex, node, "block data is "+
+					"marked as stored but does not exist")
+				v.badData[node] = struct{}{}
+			}
+		}
+		return nil
+	})
+}
+
+// checkMainChain ensures the hash to height and height to hash mappings
+// contain exactly the blocks of the main chain.
+func (v *dbVerifier) checkMainChain() error {
+	return v.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		cursor := meta.Bucket(heightIndexBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key, value := cursor.Key(), cursor.Value()
+			if len(key) != 4 || len(value) != chainhash.HashSize {
+				v.report(DBCheckMainChain, nil, "malformed height "+
+					"entry %x", key)
+				continue
+			}
+			height := int32(byteOrder.Uint32(key))
+			if height < 0 || height > v.tip.height {
+				v.report(DBCheckMainChain, nil, "height %d is "+
+					"above the best block", height)
+			}
+		}
+
+		cursor = meta.Bucket(hashIndexBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			var hash chainhash.Hash
+			copy(hash[:], cursor.Key())
+			node := v.index[hash]
+			if node == nil || !v.onMainChain(node) {
+				v.reportHash(DBCheckMainChain, &hash, "hash "+
+					"entry for block not in the main chain")
+			}
+		}
+
+		for _, node := range v.mainChain {
+			hash, err := dbFetchHashByHeight(dbTx, node.height)
+			if err != nil || *hash != node.hash {
+				v.report(DBCheckMainChain, node, "height "+
+					"entry does not match the block")
+				continue
+			}
+			height, err := dbFetchHeightByHash(dbTx, &node.hash)
+			if err != nil || height != node.height {
+				v.report(DBCheckMainChain, node, "hash entry "+
+					"does not match the height")
+			}
+		}
+		return nil
+	})
+}
+
+// checkBlock ensures the block data of the passed node can be loaded and
+// matches its header.  It returns nil when the block is unusable.
+func (v *dbVerifier) checkBlock(dbTx database.Tx, node *blockNode) *btcutil.Block {
+	if !node.status.HaveData() {
+		v.report(DBCheckBlockData, node, "block data is not "+
+			"marked as stored")
+		return nil
+	}
+	if _, ok := v.badData[node]; ok {
+		return nil
+	}
+
+	block, err := dbFetchBlockByNode(dbTx, node)
+	if err != nil {
+		v.report(DBCheckBlockData, node, "unable to load block: %v",
+			err)
+		v.badData[node] = struct{}{}
+		return nil
+	}
+	if *block.Hash() != node.hash {
+		v.report(DBCheckBlockData, node, "stored block has hash %v",
+			block.Hash())
+		v.badData[node] = struct{}{}
+		return nil
+	}
+	if len(block.Transactions()) == 0 {
+		v.report(DBCheckBlockData, node, "stored block has no "+
+			"transactions")
+		v.badData[node] = struct{}{}
+		return nil
+	}
+	merkles := BuildMerkleTreeStore(block.Transactions(), false)
+	if *merkles[len(merkles)-1] != node.merkleRoot {
+		v.report(DBCheckBlockData, node, "stored block transactions "+
+			"do not match the merkle root")
+		v.badData[node] = struct{}{}
+		return nil
+	}
+	return block
+}
+
+// checkSpendJournal ensures the spend journal entry of the passed main chain
+// block exists and matches the inputs of its transactions.  It returns false
+// when the entry is unusable.
+func (v *dbVerifier) checkSpendJournal(dbTx database.Tx, node *blockNode, block *btcutil.Block) ([]SpentTxOut, bool) {
+	// The genesis block never has a spend journal entry.
+	if node.height == 0 {
+		return nil, true
+	}
+
+	spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+	serialized := spendBucket.Get(node.hash[:])
+	if serialized == nil {
+		v.report(DBCheckSpendJournal, node, "entry does not exist")
+		return nil, false
+	}
+	txns := block.MsgBlock().Transactions[1:]
+	stxos, err := deserializeSpendJournalEntry(serialized, txns)
+	if err != nil {
+		v.report(DBCheckSpendJournal, node, "%v", err)
+		return nil, false
+	}
+
+	// The serialization is not self describing, so ensure there is no
+	// additional data after the entries of all inputs.
+	if len(serializeSpendJournalEntry(stxos)) != len(serialized) {
+		v.report(DBCheckSpendJournal, node, "entry contains more "+
+			"data than the block inputs require")
+		return nil, false
+	}
+	return stxos, true
+}
+
+// replayBlock updates the replayed utxo set hash with the outputs created and
+// spent by the passed main chain block.
+func (v *dbVerifier) replayBlock(block *btcutil.Block, stxos []SpentTxOut) {
+	height := block.Height()
+	var stxoIdx int
+	for txIdx, tx := range block.Transactions() {
+		isCoinBase := txIdx == 0
+		if !isCoinBase {
+			for _, txIn := range tx.MsgTx().TxIn {
+				stxo := &stxos[stxoIdx]
+				stxoIdx++
+
+				outpoint := txIn.PreviousOutPoint
+				element := utxoSetElement(outpoint, stxo.Amount,
+					stxo.PkScript, stxo.Height, stxo.IsCoinBase)
+				v.replayHash.remove(&element)
+				delete(v.coinbaseOuts, outpoint)
+			}
+		}
+
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			outpoint.Index = uint32(txOutIdx)
+
+			// Duplicate coinbase transactions prior to BIP0034
+			// overwrite the unspent outputs of the earlier ones.
+			if isCoinBase && height < v.params.BIP0034Height {
+				prevHeight, ok := v.coinbaseOuts[outpoint]
+				if ok {
+					element := utxoSetElement(outpoint,
+						txOut.Value, txOut.PkScript,
+						prevHeight, true)
+					v.replayHash.remove(&element)
+				}
+				v.coinbaseOuts[outpoint] = height
+			}
+
+			element := utxoSetElement(outpoint, txOut.Value,
+				txOut.PkScript, height, isCoinBase)
+			v.replayHash.add(&element)
+		}
+	}
+}
+
+// checkBlocks checks the block data and spend journal entry of every main
+// chain block while replaying the main chain, and the block data of all other
+// blocks in the block index that claim to have it.
+func (v *dbVerifier) checkBlocks() error {
+	lastLog := time.Now()
+	var totalTxns uint64
+	for _, node := range v.mainChain {
+		err := v.db.View(func(dbTx database.Tx) error {
+			block := v.checkBlock(dbTx, node)
+			if block == nil {
+				v.replayOK = false
+				return nil
+			}
+
+			// The total number of transactions is unknown past
+			// the first unusable block.
+			if int32(len(v.totalTxns)) == node.height {
+				totalTxns += uint64(len(block.Transactions()))
+				v.totalTxns = append(v.totalTxns, totalTxns)
+			}
+
+			stxos, ok := v.checkSpendJournal(dbTx, node, block)
+			if !ok {
+				v.replayOK = false
+				return nil
+			}
+			if v.replayOK && node.height > 0 {
+				v.replayBlock(block, stxos)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if interruptRequested(v.interrupt) {
+			return errInterruptRequested
+		}
+		if time.Since(lastLog) >= dbVerifyProgressInterval {
+			log.Infof("Verified %d of %d blocks", node.height+1,
+				len(v.mainChain))
+			lastLog = time.Now()
+		}
+	}
+
+	if len(v.totalTxns) == len(v.mainChain) && totalTxns != v.state.totalTxns {
+		v.report(DBCheckChainState, nil, "best chain state total "+
+			"transactions %d does not match %d in the main chain",
+			v.state.totalTxns, totalTxns)
+	}
+
+	// Check the data of the blocks that are not in the main chain as well
+	// since they are used when reorganizing to them.
+	return v.db.View(func(dbTx database.Tx) error {
+		for _, node := range v.nodes {
+			if !v.onMainChain(node) && node.status.HaveData() {
+				v.checkBlock(dbTx, node)
+			}
+		}
+
+		// Spend journal entries are removed when their block is
+		// disconnected, so there must not be any for other blocks.
+		cursor := dbTx.Metadata().Bucket(spendJournalBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			var hash chainhash.Hash
+			copy(hash[:], cursor.Key())
+			node := v.index[hash]
+			if node == nil || !v.onMainChain(node) {
+				v.reportHash(DBCheckSpendJournal, &hash, "entry "+
+					"for block not in the main chain")
+			}
+		}
+		return nil
+	})
+}
+
+// checkUtxoSet compares the stored utxo set against the replay of the main
+// chain.
+func (v *dbVerifier) checkUtxoSet() error {
+	var storedHash utxoSetHash
+	var numCorrupt uint64
+	err := v.db.View(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key, value := cursor.Key(), cursor.Value()
+			v.result.NumUtxos++
+
+			var outpoint wire.OutPoint
+			var entry *UtxoEntry
+			var err error
+			if len(key) > chainhash.HashSize && len(value) > 0 {
+				index, n := deserializeVLQ(key[chainhash.HashSize:])
+				if chainhash.HashSize+n == len(key) {
+					copy(outpoint.Hash[:], key)
+					outpoint.Index = uint32(index)
+					entry, err = deserializeUtxoEntry(value)
+				}
+			}
+			if entry == nil || err != nil {
+				numCorrupt++
+				continue
+			}
+
+			element := utxoSetElement(outpoint, entry.Amount(),
+				entry.PkScript(), entry.BlockHeight(),
+				entry.IsCoinBase())
+			storedHash.add(&element)
+
+			if v.result.NumUtxos%maxRepairDeletions == 0 &&
+				interruptRequested(v.interrupt) {
+
+				return errInterruptRequested
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if numCorrupt > 0 {
+		v.report(DBCheckUtxoSet, nil, "%d entries could not be "+
+			"decoded", numCorrupt)
+	}
+	if !v.replayOK {
+		return nil
+	}
+	v.result.UtxoSetChecked = true
+	if storedHash != v.replayHash {
+		v.report(DBCheckUtxoSet, nil, "stored utxo set does not "+
+			"match a replay of the main chain")
+	}
+	return nil
+}
+
+// newDBVerifier loads the chain state from the passed database and performs
+// all consistency checks on it.
+func newDBVerifier(db database.DB, params *chaincfg.Params, interrupt <-chan struct{}) (*dbVerifier, error) {
+	v := &dbVerifier{
+		db:        db,
+		params:    params,
+		interrupt: interrupt,
+		result: &DBVerifyResult{
+			TipHeight:        -1,
+			ConsistentHeight: -1,
+		},
+		index:        make(map[chainhash.Hash]*blockNode),
+		badData:      make(map[*blockNode]struct{}),
+		replayOK:     true,
+		coinbaseOuts: make(map[wire.OutPoint]int32),
+	}
+
+	log.Infof("Loading chain state")
+	if err := v.loadChainState(); err != nil {
+		return nil, err
+	}
+	if v.fatal {
+		return v, nil
+	}
+	if interruptRequested(interrupt) {
+		return nil, errInterruptRequested
+	}
+
+	log.Infof("Verifying block index and main chain (best height %d)",
+		v.tip.height)
+	if err := v.checkBlockIndex(); err != nil {
+		return nil, err
+	}
+	if err := v.checkMainChain(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Verifying block data and spend journal")
+	if err := v.checkBlocks(); err != nil {
+		return nil, err
+	}
+
+	log.Infof("Verifying utxo set")
+	if err := v.checkUtxoSet(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// VerifyDB performs a consistency check of the chain state stored in the
+// passed database.  It cross-checks the best chain state, the block index, the
+// main chain hash and height mappings, the stored block data, and the spend
+// journal, and compares the utxo set against a full replay of the main chain.
+//
+// The database must not be in use by a BlockChain instance that is
+// processing blocks while it is verified.  Inconsistencies are returned in
+// the result rather than as an error.
+func VerifyDB(db database.DB, params *chaincfg.Params, interrupt <-chan struct{}) (*DBVerifyResult, error) {
+	v, err := newDBVerifier(db, params, interrupt)
+	if err != nil {
+		return nil, err
+	}
+	return v.result, nil
+}
+
+// clearUtxoSet removes all entries from the utxo set in batches.
+func (v *dbVerifier) clearUtxoSet() error {
+	for {
+		var numDeleted int
+		err := v.db.Update(func(dbTx database.Tx) error {
+			bucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+			cursor := bucket.Cursor()
+			for ok := cursor.First(); ok &&
+				numDeleted < maxRepairDeletions; ok = cursor.Next() {
+
+				if err := cursor.Delete(); err != nil {
+					return err
+				}
+				numDeleted++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if numDeleted == 0 {
+			return nil
+		}
+		if interruptRequested(v.interrupt) {
+			return errInterruptRequested
+		}
+	}
+}
+
+// rebuildUtxoSet replaces the utxo set with the one that results from
+// replaying the main chain up to and including the passed height.  The work is
+// split across many database transactions, so the caller must mark the
+// database as being repaired beforehand.
+func (v *dbVerifier) rebuildUtxoSet(height int32) error {
+	log.Infof("Rebuilding utxo set to height %d.  This might take a "+
+		"while...", height)
+	if err := v.clearUtxoSet(); err != nil {
+		return err
+	}
+
+	lastLog := time.Now()
+	view := NewUtxoViewpoint()
+	var numBlocks int
+	flush := func() error {
+		err := v.db.Update(func(dbTx database.Tx) error {
+			return dbPutUtxoView(dbTx, view)
+		})
+		if err != nil {
+			return err
+		}
+		view = NewUtxoViewpoint()
+		numBlocks = 0
+		return nil
+	}
+	for _, node := range v.mainChain[1 : height+1] {
+		var block *btcutil.Block
+		err := v.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByNode(dbTx, node)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if err := view.fetchInputUtxos(v.db, nil, block); err != nil {
+			return err
+		}
+		if err := view.connectTransactions(block, nil); err != nil {
+			return err
+		}
+
+		numBlocks++
+		if numBlocks >= maxRepairBatchBlocks ||
+			len(view.entries) >= maxRepairBatchUtxos {
+
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		if interruptRequested(v.interrupt) {
+			return errInterruptRequested
+		}
+		if time.Since(lastLog) >= dbVerifyProgressInterval {
+			log.Infof("Replayed %d of %d blocks", node.height, height)
+			lastLog = time.Now()
+		}
+	}
+	return flush()
+}
+
+// RepairDB verifies the chain state stored in the passed database like
+// VerifyDB and repairs any inconsistencies it finds by truncating the main
+// chain to the last consistent block.
+//
+// Entries of the block index above that block and those whose block data is
+// unusable, along with all of their descendants, are removed so the blocks are
+// downloaded again.  The utxo set is rebuilt by replaying the main chain from
+// the genesis block unless it was verified to be consistent, which might take a
+// long time.  The database must not be in use by a BlockChain instance while it
+// is repaired.
+//
+// The main chain is truncated before the utxo set is rebuilt, and the database
+// is marked as being repaired until the rebuild finishes.  A BlockChain refuses
+// to load a marked database, so a repair that is interrupted, or that fails
+// while rebuilding the utxo set, must be run again, which resumes it.
+//
+// Optional indexes are not modified, so the caller is responsible for dropping
+// any whose tip is no longer part of the main chain.
+func RepairDB(db database.DB, params *chaincfg.Params, interrupt <-chan struct{}) (*DBRepairResult, error) {
+	v, err := newDBVerifier(db, params, interrupt)
+	if err != nil {
+		return nil, err
+	}
+	result := &DBRepairResult{
+		Verify:    v.result,
+		TipHash:   v.result.TipHash,
+		TipHeight: v.result.TipHeight,
+	}
+	if v.result.Consistent() {
+		return result, nil
+	}
+	if v.fatal {
+		return nil, fmt.Errorf("unable to repair the chain state: %v",
+			&v.result.Inconsistencies[0])
+	}
+
+	target := v.result.ConsistentHeight
+	if target < 0 {
+		return nil, fmt.Errorf("unable to repair the chain state: the " +
+			"genesis block is inconsistent")
+	}
+	if target > int32(len(v.totalTxns))-1 {
+		target = int32(len(v.totalTxns)) - 1
+	}
+	newTip := v.mainChain[target]
+
+	// Determine the entries to remove from the block index.  Since the
+	// nodes are ordered by height, parents are always visited before their
+	// children.
+	removed := make(map[*blockNode]struct{})
+	for _, node := range v.nodes {
+		_, badData := v.badData[node]
+		_, badParent := removed[node.parent]
+		aboveTip := v.onMainChain(node) && node.height > target
+		if badData || (node.parent != nil && badParent) || aboveTip {
+			removed[node] = struct{}{}
+		}
+	}
+
+	// The utxo set is rebuilt unless it is known to match the new tip.
+	rebuildUtxoSet := target != v.tip.height || !v.result.UtxoSetChecked ||
+		!v.utxoSetConsistent()
+
+	// Truncate the main chain first and, when the utxo set is rebuilt, mark
+	// the database as being repaired in the same transaction.  This ensures
+	// the database is never used with a partially rebuilt utxo set and
+	// allows an interrupted repair to be resumed.
+	log.Infof("Truncating main chain to block %v (height %d)",
+		jsonlog.BlockHash(&newTip.hash), jsonlog.Height(target))
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if rebuildUtxoSet {
+			err := meta.Put(dbRepairKeyName, newTip.hash[:])
+			if err != nil {
+				return err
+			}
+		}
+
+		// Remove the main chain mappings that do not refer to the
+		// truncated main chain and restore any missing ones.
+		isMainChain := func(hash *chainhash.Hash, height int32) bool {
+			return height >= 0 && height <= target &&
+				v.mainChain[height].hash == *hash
+		}
+		cursor := meta.Bucket(heightIndexBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key, value := cursor.Key(), cursor.Value()
+			if len(key) == 4 && len(value) == chainhash.HashSize {
+				var hash chainhash.Hash
+				copy(hash[:], value)
+				height := int32(byteOrder.Uint32(key))
+				if isMainChain(&hash, height) {
+					continue
+				}
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		cursor = meta.Bucket(hashIndexBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key, value := cursor.Key(), cursor.Value()
+			if len(key) == chainhash.HashSize && len(value) == 4 {
+				var hash chainhash.Hash
+				copy(hash[:], key)
+				height := int32(byteOrder.Uint32(value))
+				if isMainChain(&hash, height) {
+					continue
+				}
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+		for _, node := range v.mainChain[:target+1] {
+			hash, err := dbFetchHashByHeight(dbTx, node.height)
+			if err == nil && *hash == node.hash {
+				height, err := dbFetchHeightByHash(dbTx, &node.hash)
+				if err == nil && height == node.height {
+					continue
+				}
+			}
+			err = dbPutBlockIndex(dbTx, &node.hash, node.height)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Remove the spend journal entries of all blocks that are no
+		// longer in the main chain.
+		spendBucket := meta.Bucket(spendJournalBucketName)
+		cursor = spendBucket.Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			var hash chainhash.Hash
+			copy(hash[:], cursor.Key())
+			node := v.index[hash]
+			if node != nil && v.onMainChain(node) &&
+				node.height <= target {
+
+				continue
+			}
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+		}
+
+		// Remove the block index entries.
+		blockIndexBucket := meta.Bucket(blockIndexBucketName)
+		for _, key := range v.badRows {
+			if err := blockIndexBucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		for node := range removed {
+			key := blockIndexKey(&node.hash, uint32(node.height))
+			if err := blockIndexBucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		// Update the best chain state.
+		state := bestChainState{
+			hash:      newTip.hash,
+			height:    uint32(newTip.height),
+			totalTxns: v.totalTxns[target],
+			workSum:   new(big.Int).Set(newTip.workSum),
+		}
+		return meta.Put(chainStateKeyName, serializeBestChainState(state))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if rebuildUtxoSet {
+		if err := v.rebuildUtxoSet(target); err != nil {
+			if err == errInterruptRequested {
+				log.Infof("Repair interrupted while rebuilding " +
+					"the utxo set -- run it again to resume")
+			}
+			return nil, err
+		}
+		err := db.Update(func(dbTx database.Tx) error {
+			return dbTx.Metadata().Delete(dbRepairKeyName)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.RebuiltUtxoSet = true
+	}
+
+	result.TipHash = newTip.hash
+	result.TipHeight = target
+	result.RemovedBlocks = len(removed) + len(v.badRows)
+	return result, nil
+}
+
+// utxoSetConsistent returns whether no utxo set inconsistencies were found.
+func (v *dbVerifier) utxoSetConsistent() bool {
+	for i := range v.result.Inconsistencies {
+		if v.result.Inconsistencies[i].Check == DBCheckUtxoSet {
+			return false
+		}
+	}
+	return true
+}
+
+// copyBytes returns a copy of the passed byte slice.  This is required when
+// retaining keys beyond the lifetime of a database transaction.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2015-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestVerifyRepairDB ensures VerifyDB detects inconsistencies in the stored
+// chain state and that RepairDB truncates the main chain to the last
+// consistent block.
+func TestVerifyRepairDB(t *testing.T) {
+	// Load up blocks such that there is a side chain.
+	// (genesis block) -> 1 -> 2 -> 3 -> 4
+	//                          \-> 3a
+	testFiles := []string{
+		"blk_0_to_4.dat.bz2",
+		"blk_3A.dat.bz2",
+	}
+
+	var blocks []*btcutil.Block
+	for _, file := range testFiles {
+		blockTmp, err := loadBlocks(file)
+		if err != nil {
+			t.Fatalf("Error loading file: %v\n", err)
+		}
+		blocks = append(blocks, blockTmp...)
+	}
+
+	chain, teardownFunc, err := chainSetup("verifyrepairdb",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+	db := chain.db
+	params := chain.chainParams
+
+	// verify runs VerifyDB and ensures the result matches the expected
+	// values.
+	verify := func(desc string, consistentHeight int32, checks ...DBCheck) {
+		t.Helper()
+		result, err := VerifyDB(db, params, nil)
+		if err != nil {
+			t.Fatalf("%s: VerifyDB: unexpected error: %v", desc, err)
+		}
+		if result.TipHeight != 4 {
+			t.Fatalf("%s: unexpected tip height: got %d, want 4",
+				desc, result.TipHeight)
+		}
+		if result.ConsistentHeight != consistentHeight {
+			t.Fatalf("%s: unexpected consistent height: got %d, "+
+				"want %d", desc, result.ConsistentHeight,
+				consistentHeight)
+		}
+		if len(result.Inconsistencies) != len(checks) {
+			t.Fatalf("%s: unexpected inconsistencies: got %v, "+
+				"want checks %v", desc, result.Inconsistencies,
+				checks)
+		}
+		for i, check := range checks {
+			if result.Inconsistencies[i].Check != check {
+				t.Fatalf("%s: unexpected inconsistency #%d: "+
+					"got %v, want check %v", desc, i,
+					&result.Inconsistencies[i], check)
+			}
+		}
+	}
+
+	// update runs the passed function in a database transaction.
+	update := func(fn func(dbTx database.Tx) error) {
+		t.Helper()
+		err := db.Update(fn)
+		if err != nil {
+			t.Fatalf("unable to update database: %v", err)
+		}
+	}
+
+	verify("consistent", 4)
+
+	// Remove an unspent output and ensure it is detected by the replay and
+	// fixed by rebuilding the utxo set.
+	update(func(dbTx database.Tx) error {
+		cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+		if !cursor.First() {
+			t.Fatal("utxo set is empty")
+		}
+		return cursor.Delete()
+	})
+	verify("missing utxo", 4, DBCheckUtxoSet)
+	repair, err := RepairDB(db, params, nil)
+	if err != nil {
+		t.Fatalf("RepairDB: unexpected error: %v", err)
+	}
+	if !repair.RebuiltUtxoSet || repair.TipHeight != 4 ||
+		repair.RemovedBlocks != 0 {
+
+		t.Fatalf("unexpected repair result: %+v", repair)
+	}
+	verify("rebuilt utxo set", 4)
+
+	// Simulate a repair that was interrupted while rebuilding the utxo set
+	// and ensure the database is refused until the repair is resumed.
+	update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		err := meta.Put(dbRepairKeyName, blocks[4].Hash()[:])
+		if err != nil {
+			return err
+		}
+		cursor := meta.Bucket(utxoSetBucketName).Cursor()
+		if !cursor.First() {
+			t.Fatal("utxo set is empty")
+		}
+		return cursor.Delete()
+	})
+	verify("interrupted repair", 4, DBCheckUtxoSet, DBCheckUtxoSet)
+	_, err = New(&Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  NewMedianTime(),
+	})
+	if err != errRepairInProgress {
+		t.Fatalf("New: unexpected error -- got %v, want %v", err,
+			errRepairInProgress)
+	}
+	repair, err = RepairDB(db, params, nil)
+	if err != nil {
+		t.Fatalf("RepairDB: unexpected error: %v", err)
+	}
+	if !repair.RebuiltUtxoSet || repair.TipHeight != 4 {
+		t.Fatalf("unexpected repair result: %+v", repair)
+	}
+	verify("resumed repair", 4)
+	if _, err := New(&Config{
+		DB:          db,
+		ChainParams: params,
+		TimeSource:  NewMedianTime(),
+	}); err != nil {
+		t.Fatalf("New: unexpected error after repair: %v", err)
+	}
+
+	// Corrupt the spend journal entry of block 3 and remove the main chain
+	// mapping of block 4.
+	update(func(dbTx database.Tx) error {
+		spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+		err := spendBucket.Put(blocks[3].Hash()[:], []byte{0xff})
+		if err != nil {
+			return err
+		}
+		return dbRemoveBlockIndex(dbTx, blocks[4].Hash(), 4)
+	})
+	verify("corrupt journal", 2, DBCheckMainChain, DBCheckSpendJournal)
+
+	repair, err = RepairDB(db, params, nil)
+	if err != nil {
+		t.Fatalf("RepairDB: unexpected error: %v", err)
+	}
+	if repair.TipHeight != 2 || repair.TipHash != *blocks[2].Hash() ||
+		repair.RemovedBlocks != 2 || !repair.RebuiltUtxoSet {
+
+		t.Fatalf("unexpected repair result: %+v", repair)
+	}
+
+	result, err := VerifyDB(db, params, nil)
+	if err != nil {
+		t.Fatalf("VerifyDB: unexpected error: %v", err)
+	}
+	if !result.Consistent() || result.TipHeight != 2 ||
+		!result.UtxoSetChecked {
+
+		t.Fatalf("unexpected result after repair: %+v", result)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"math/big"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/jsonlog"
 )
 
 var (
@@ -288,7 +290,8 @@ func (b *BlockChain) calcNextRequiredDifficulty(lastNode *blockNode, newBlockTim
 	// newTarget since conversion to the compact representation loses
 	// precision.
 	newTargetBits := BigToCompact(newTarget)
-	log.Debugf("Difficulty retarget at block height %d", lastNode.height+1)
+	log.Debugf("Difficulty retarget at block height %d",
+		jsonlog.Height(lastNode.height+1))
 	log.Debugf("Old target %08x (%064x)", lastNode.bits, oldTarget)
 	log.Debugf("New target %08x (%064x)", newTargetBits, CompactToBig(newTargetBits))
 	log.Debugf("Actual timespan %v, adjusted timespan %v, target timespan %v",
@@ -310,3 +313,59 @@ func (b *BlockChain) CalcNextRequiredDifficulty(timestamp time.Time) (uint32, er
 	b.chainLock.Unlock()
 	return difficulty, err
 }
+
+// PermittedDifficultyTransition returns whether or not the difficulty bits of
+// a block at the passed height could legitimately follow a block with the
+// given old difficulty bits under the difficulty retarget rules of the passed
+// network.  It only has the context of the two headers involved, so it checks
+// that the difficulty does not change between retarget intervals and that the
+// change at a retarget interval stays within the bounds allowed by the
+// retarget adjustment factor.
+//
+// This is primarily intended to cheaply reject header chains which could not
+// possibly be valid before they are connected to the block index, where the
+// full difficulty rules are enforced.  Networks which allow the special
+// minimum difficulty reduction always return true since any transition is
+// possible given the right timestamps.
+func PermittedDifficultyTransition(params *chaincfg.Params, height int32,
+	oldBits, newBits uint32) bool {
+
+	if params.ReduceMinDifficulty {
+		return true
+	}
+
+	targetTimespan := int64(params.TargetTimespan / time.Second)
+	targetTimePerBlock := int64(params.TargetTimePerBlock / time.Second)
+	blocksPerRetarget := int32(targetTimespan / targetTimePerBlock)
+	if height%blocksPerRetarget != 0 {
+		return oldBits == newBits
+	}
+
+	// Calculate the easiest and hardest targets reachable from the old
+	// target and make sure the new one falls in between after rounding
+	// through the compact representation the same way a real retarget
+	// does.
+	adjustmentFactor := params.RetargetAdjustmentFactor
+	minRetargetTimespan := targetTimespan / adjustmentFactor
+	maxRetargetTimespan := targetTimespan * adjustmentFactor
+	oldTarget := CompactToBig(oldBits)
+	newTarget := CompactToBig(newBits)
+
+	largest := new(big.Int).Mul(oldTarget, big.NewInt(maxRetargetTimespan))
+	largest.Div(largest, big.NewInt(targetTimespan))
+	if largest.Cmp(params.PowLimit) > 0 {
+		largest.Set(params.PowLimit)
+	}
+	largest = CompactToBig(BigToCompact(largest))
+	if newTarget.Cmp(largest) > 0 {
+		return false
+	}
+
+	smallest := new(big.Int).Mul(oldTarget, big.NewInt(minRetargetTimespan))
+	smallest.Div(smallest, big.NewInt(targetTimespan))
+	if smallest.Cmp(params.PowLimit) > 0 {
+		smallest.Set(params.PowLimit)
+	}
+	smallest = CompactToBig(BigToCompact(smallest))
+	return newTarget.Cmp(smallest) >= 0
+}
@@ -7,6 +7,8 @@ package blockchain
 import (
 	"math/big"
 	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
 )
 
 // TestBigToCompact ensures BigToCompact converts big integers to the expected
@@ -73,3 +75,99 @@ func TestCalcWork(t *testing.T) {
 		}
 	}
 }
+
+// TestPermittedDifficultyTransition ensures PermittedDifficultyTransition only
+// allows difficulty changes at retarget intervals and within the bounds of the
+// retarget adjustment factor.
+func TestPermittedDifficultyTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  *chaincfg.Params
+		height  int32
+		oldBits uint32
+		newBits uint32
+		want    bool
+	}{
+		{
+			name:    "unchanged between retargets",
+			params:  &chaincfg.MainNetParams,
+			height:  2017,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b0404cb,
+			want:    true,
+		},
+		{
+			name:    "changed between retargets",
+			params:  &chaincfg.MainNetParams,
+			height:  2017,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b0404cc,
+			want:    false,
+		},
+		{
+			name:    "unchanged at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016 * 10,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b0404cb,
+			want:    true,
+		},
+		{
+			name:    "max increase at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016 * 10,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b10132c,
+			want:    true,
+		},
+		{
+			name:    "too large increase at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016 * 10,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b10132d,
+			want:    false,
+		},
+		{
+			name:    "max decrease at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016 * 10,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b010132,
+			want:    true,
+		},
+		{
+			name:    "too large decrease at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016 * 10,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1b010131,
+			want:    false,
+		},
+		{
+			name:    "above pow limit at retarget",
+			params:  &chaincfg.MainNetParams,
+			height:  2016,
+			oldBits: 0x1d00ffff,
+			newBits: 0x1d01fffe,
+			want:    false,
+		},
+		{
+			name:    "min difficulty reduction network",
+			params:  &chaincfg.TestNet3Params,
+			height:  2017,
+			oldBits: 0x1b0404cb,
+			newBits: 0x1d00ffff,
+			want:    true,
+		},
+	}
+
+	for _, test := range tests {
+		got := PermittedDifficultyTransition(test.params, test.height,
+			test.oldBits, test.newBits)
+		if got != test.want {
+			t.Errorf("%s: unexpected result -- got %v, want %v",
+				test.name, got, test.want)
+		}
+	}
+}
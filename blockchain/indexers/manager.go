@@ -695,3 +695,68 @@ func dropIndex(db database.DB, idxKey []byte, idxName string, interrupt <-chan s
 	log.Infof("Dropped %s", idxName)
 	return nil
 }
+
+// IndexTip describes the current tip of an optional index that exists in the
+// database.
+type IndexTip struct {
+	// Name is the human-readable name of the index.
+	Name string
+
+	// Hash and Height identify the block the index is caught up to.  The
+	// height is -1 when the index does not have any entries yet.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Err is set when the stored tip is corrupt, in which case the hash
+	// and height are not set.
+	Err error
+
+	key []byte
+}
+
+// Drop drops the index from the database.  It is created again on the next
+// start when it is enabled.
+func (t *IndexTip) Drop(db database.DB, interrupt <-chan struct{}) error {
+	return dropIndex(db, t.key, t.Name, interrupt)
+}
+
+// knownIndexes are the keys and names of all optional indexes.
+var knownIndexes = []struct {
+	key  []byte
+	name string
+}{
+	{txIndexKey, txIndexName},
+	{addrIndexKey, addrIndexName},
+	{cfIndexParentBucketKey, cfIndexName},
+}
+
+// FetchIndexTips returns the current tips of all optional indexes that exist
+// in the database.
+func FetchIndexTips(db database.DB) ([]IndexTip, error) {
+	var tips []IndexTip
+	err := db.View(func(dbTx database.Tx) error {
+		// None of the indexes exist if the index tips bucket hasn't been
+		// created yet.
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		if indexesBucket == nil {
+			return nil
+		}
+
+		for _, index := range knownIndexes {
+			if indexesBucket.Get(index.key) == nil {
+				continue
+			}
+			tip := IndexTip{Name: index.name, key: index.key}
+			hash, height, err := dbFetchIndexerTip(dbTx, index.key)
+			if err != nil {
+				tip.Err = err
+			} else {
+				tip.Hash = *hash
+				tip.Height = height
+			}
+			tips = append(tips, tip)
+		}
+		return nil
+	})
+	return tips, err
+}
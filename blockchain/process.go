@@ -10,6 +10,7 @@ import (
 
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -29,6 +30,12 @@ const (
 	// not be performed.
 	BFNoPoWCheck
 
+	// bfVerifyMainChain indicates the block is an existing main chain
+	// block that is validated again.  The checks that only apply to new
+	// blocks, such as rejecting forks before the latest checkpoint, are
+	// not performed and the scripts are always executed.
+	bfVerifyMainChain
+
 	// BFNone is a convenience value to specifically indicate no flags.
 	BFNone BehaviorFlags = 0
 )
@@ -146,7 +153,7 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 	fastAdd := flags&BFFastAdd == BFFastAdd
 
 	blockHash := block.Hash()
-	log.Tracef("Processing block %v", blockHash)
+	log.Tracef("Processing block %v", jsonlog.BlockHash(blockHash))
 
 	// The block must not already exist in the main chain or side chains.
 	exists, err := b.blockExists(blockHash)
@@ -165,7 +172,9 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
+	sanityStart := time.Now()
 	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	b.observeValidation(StageSanity, sanityStart)
 	if err != nil {
 		return false, false, err
 	}
@@ -217,7 +226,8 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 		return false, false, err
 	}
 	if !prevHashExists {
-		log.Infof("Adding orphan block %v with parent %v", blockHash, prevHash)
+		log.Infof("Adding orphan block %v with parent %v",
+			jsonlog.BlockHash(blockHash), prevHash)
 		b.addOrphanBlock(block)
 
 		return false, true, nil
@@ -238,7 +248,7 @@ func (b *BlockChain) ProcessBlock(block *btcutil.Block, flags BehaviorFlags) (bo
 		return false, false, err
 	}
 
-	log.Debugf("Accepted block %v", blockHash)
+	log.Debugf("Accepted block %v", jsonlog.BlockHash(blockHash))
 
 	return isMainChain, false, nil
 }
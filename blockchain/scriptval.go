@@ -10,6 +10,7 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
@@ -302,7 +303,8 @@ func checkBlockScripts(block *btcutil.Block, utxoView *UtxoViewpoint,
 	}
 	elapsed := time.Since(start)
 
-	log.Tracef("block %v took %v to verify", block.Hash(), elapsed)
+	log.Tracef("block %v took %v to verify",
+		jsonlog.BlockHash(block.Hash()), elapsed)
 
 	// If the HashCache is present, once we have validated the block, we no
 	// longer need the cached hashes for these transactions, so we purge
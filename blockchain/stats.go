@@ -0,0 +1,86 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ValidationStage identifies a stage of block validation that is timed and
+// reported to the ValidationTimer provided in the chain configuration.
+type ValidationStage int
+
+// These constants define the timed stages of block validation.
+const (
+	// StageSanity is the context-free sanity checking of a block performed
+	// by checkBlockSanity when a block is processed.
+	StageSanity ValidationStage = iota
+
+	// StageScripts is the validation of the scripts of all transactions in
+	// a block.
+	StageScripts
+
+	// StageConnect is the validation of a block against the utxo set and
+	// connecting it to the end of the main chain.  It includes the script
+	// validation timed by StageScripts.
+	StageConnect
+)
+
+// Map of ValidationStage values back to their constant names for pretty
+// printing.
+var validationStageStrings = map[ValidationStage]string{
+	StageSanity:  "sanity",
+	StageScripts: "scripts",
+	StageConnect: "connect",
+}
+
+// String returns the ValidationStage as a human-readable name.
+func (s ValidationStage) String() string {
+	if str, ok := validationStageStrings[s]; ok {
+		return str
+	}
+	return "unknown"
+}
+
+// observeValidation reports the time elapsed since the passed start time for
+// the passed validation stage to the configured validation timer, if any.
+func (b *BlockChain) observeValidation(stage ValidationStage, start time.Time) {
+	if b.validationTimer != nil {
+		b.validationTimer(stage, time.Since(start))
+	}
+}
+
+// utxoViewStats tracks the number of unspent transaction outputs referenced by
+// blocks being validated that were found in the utxo view, and those that had
+// to be loaded from the database.  The fields must only be used atomically.
+type utxoViewStats struct {
+	hits   uint64
+	misses uint64
+}
+
+// record adds the passed number of hits and misses to the stats.  It does
+// nothing when the stats are nil, which is the case for utxo views that are
+// not used to validate blocks for a chain instance.
+//
+// This function is safe for concurrent access.
+func (s *utxoViewStats) record(hits, misses uint64) {
+	if s == nil {
+		return
+	}
+	atomic.AddUint64(&s.hits, hits)
+	atomic.AddUint64(&s.misses, misses)
+}
+
+// UtxoCacheStats returns the number of unspent transaction outputs referenced by
+// blocks being validated that were already available in memory, either because
+// they were created earlier in the same block or were already loaded, and the
+// number that had to be loaded from the database.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) UtxoCacheStats() (hits, misses uint64) {
+	return atomic.LoadUint64(&b.utxoViewStats.hits),
+		atomic.LoadUint64(&b.utxoViewStats.misses)
+}
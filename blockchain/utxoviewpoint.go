@@ -515,8 +515,9 @@ func (view *UtxoViewpoint) fetchUtxosMain(db database.DB, outpoints map[wire.Out
 
 // fetchUtxos loads the unspent transaction outputs for the provided set of
 // outputs into the view from the database as needed unless they already exist
-// in the view in which case they are ignored.
-func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoint]struct{}) error {
+// in the view in which case they are ignored.  The number of outputs found in
+// the view and loaded from the database are recorded in the passed stats.
+func (view *UtxoViewpoint) fetchUtxos(db database.DB, stats *utxoViewStats, outpoints map[wire.OutPoint]struct{}) error {
 	// Nothing to do if there are no requested outputs.
 	if len(outpoints) == 0 {
 		return nil
@@ -532,6 +533,8 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 
 		neededSet[outpoint] = struct{}{}
 	}
+	stats.record(uint64(len(outpoints)-len(neededSet)),
+		uint64(len(neededSet)))
 
 	// Request the input utxos from the database.
 	return view.fetchUtxosMain(db, neededSet)
@@ -541,8 +544,9 @@ func (view *UtxoViewpoint) fetchUtxos(db database.DB, outpoints map[wire.OutPoin
 // referenced by the transactions in the given block into the view from the
 // database as needed.  In particular, referenced entries that are earlier in
 // the block are added to the view and entries that are already in the view are
-// not modified.
-func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block) error {
+// not modified.  The number of outputs found in the block or view and loaded
+// from the database are recorded in the passed stats.
+func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, stats *utxoViewStats, block *btcutil.Block) error {
 	// Build a map of in-flight transactions because some of the inputs in
 	// this block could be referencing other transactions earlier in this
 	// block which are not yet in the chain.
@@ -556,6 +560,7 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block)
 	// which has no inputs) collecting them into sets of what is needed and
 	// what is already known (in-flight).
 	neededSet := make(map[wire.OutPoint]struct{})
+	var numHits uint64
 	for i, tx := range transactions[1:] {
 		for _, txIn := range tx.MsgTx().TxIn {
 			// It is acceptable for a transaction input to reference
@@ -575,18 +580,21 @@ func (view *UtxoViewpoint) fetchInputUtxos(db database.DB, block *btcutil.Block)
 
 				originTx := transactions[inFlightIndex]
 				view.AddTxOuts(originTx, block.Height())
+				numHits++
 				continue
 			}
 
 			// Don't request entries that are already in the view
 			// from the database.
 			if _, ok := view.entries[txIn.PreviousOutPoint]; ok {
+				numHits++
 				continue
 			}
 
 			neededSet[txIn.PreviousOutPoint] = struct{}{}
 		}
 	}
+	stats.record(numHits, uint64(len(neededSet)))
 
 	// Request the input utxos from the database.
 	return view.fetchUtxosMain(db, neededSet)
@@ -656,3 +664,74 @@ func (b *BlockChain) FetchUtxoEntry(outpoint wire.OutPoint) (*UtxoEntry, error)
 
 	return entry, nil
 }
+
+// ScanUtxoSet invokes the passed function with every unspent transaction
+// output in the utxo set and returns the hash and height of the block the utxo
+// set is the result of.  The outputs are visited in order of their transaction
+// hash as it is stored, which allows callers to estimate the progress of the
+// scan from the leading bytes of the hash.
+//
+// The scan is performed against a consistent snapshot of the database, so the
+// chain may continue to process blocks while it runs.  The scan stops as soon
+// as the passed function returns an error and that error is returned.
+//
+// NOTE: The snapshot is a single database read transaction that stays open for
+// the whole scan, which may take a long time on a large utxo set.  While it is
+// open the database keeps the data it references around, and closing the
+// database blocks until the transaction ends.  Callers that may be shut down
+// during a scan must therefore make the passed function return an error when
+// they are asked to stop.
+//
+// This function is safe for concurrent access however the entries passed to
+// the function are NOT.
+func (b *BlockChain) ScanUtxoSet(fn func(outpoint wire.OutPoint, entry *UtxoEntry) error) (*chainhash.Hash, int32, error) {
+	var state bestChainState
+	err := b.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		var err error
+		state, err = deserializeBestChainState(meta.Get(chainStateKeyName))
+		if err != nil {
+			return err
+		}
+
+		cursor := meta.Bucket(utxoSetBucketName).Cursor()
+		for ok := cursor.First(); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(key) <= chainhash.HashSize {
+				return database.Error{
+					ErrorCode:   database.ErrCorruption,
+					Description: "corrupt utxo set key",
+				}
+			}
+			var outpoint wire.OutPoint
+			copy(outpoint.Hash[:], key)
+			index, _ := deserializeVLQ(key[chainhash.HashSize:])
+			outpoint.Index = uint32(index)
+
+			entry, err := deserializeUtxoEntry(cursor.Value())
+			if err != nil {
+				// Ensure any deserialization errors are returned as
+				// database corruption errors.
+				if isDeserializeErr(err) {
+					return database.Error{
+						ErrorCode: database.ErrCorruption,
+						Description: fmt.Sprintf("corrupt "+
+							"utxo entry for %v: %v",
+							outpoint, err),
+					}
+				}
+
+				return err
+			}
+			if err := fn(outpoint, entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &state.hash, int32(state.height), nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestScanUtxoSet ensures ScanUtxoSet visits every output of the utxo set in
+// order along with the best block and stops when the passed function fails.
+func TestScanUtxoSet(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("scanutxoset",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	// The genesis coinbase is not part of the utxo set while the outputs
+	// of the remaining blocks are unless they are spent.
+	want := make(map[wire.OutPoint]int64)
+	for _, block := range blocks[1:] {
+		for _, tx := range block.Transactions() {
+			for i, txOut := range tx.MsgTx().TxOut {
+				op := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(i)}
+				want[op] = txOut.Value
+			}
+			for _, txIn := range tx.MsgTx().TxIn {
+				delete(want, txIn.PreviousOutPoint)
+			}
+		}
+	}
+
+	var prev *wire.OutPoint
+	var numVisited int
+	hash, height, err := chain.ScanUtxoSet(func(op wire.OutPoint, entry *UtxoEntry) error {
+		numVisited++
+		amount, ok := want[op]
+		if !ok {
+			t.Errorf("ScanUtxoSet: unexpected output %v", op)
+			return nil
+		}
+		if entry.Amount() != amount {
+			t.Errorf("ScanUtxoSet: unexpected amount for %v - got "+
+				"%d, want %d", op, entry.Amount(), amount)
+		}
+		if prev != nil && string(prev.Hash[:]) > string(op.Hash[:]) {
+			t.Errorf("ScanUtxoSet: output %v visited after %v", op,
+				prev)
+		}
+		prev = &op
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanUtxoSet: unexpected error: %v", err)
+	}
+	if numVisited != len(want) {
+		t.Fatalf("ScanUtxoSet: unexpected number of outputs - got %d, "+
+			"want %d", numVisited, len(want))
+	}
+	if *hash != *blocks[4].Hash() || height != 4 {
+		t.Fatalf("ScanUtxoSet: unexpected best block - got %v (%d), "+
+			"want %v (4)", hash, height, blocks[4].Hash())
+	}
+
+	// Ensure the scan stops with the error of the passed function.
+	errStop := errors.New("stop")
+	numVisited = 0
+	_, _, err = chain.ScanUtxoSet(func(wire.OutPoint, *UtxoEntry) error {
+		numVisited++
+		return errStop
+	})
+	if err != errStop || numVisited != 1 {
+		t.Fatalf("ScanUtxoSet: unexpected result after stopping - got "+
+			"%v after %d outputs, want %v after 1", err, numVisited,
+			errStop)
+	}
+}
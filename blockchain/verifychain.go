@@ -0,0 +1,467 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// MaxVerifyLevel is the highest level of checks supported by
+	// VerifyChain.
+	MaxVerifyLevel = 4
+
+	// maxVerifyViewEntries is the maximum number of utxo entries the
+	// scratch view used by the utxo checks of VerifyChain may hold.  Blocks
+	// further from the tip are only checked at the lower levels once it is
+	// reached in order to bound memory usage.
+	maxVerifyViewEntries = 2000000
+)
+
+// VerifyChainError identifies a main chain block that failed one of the checks
+// performed by VerifyChain.
+type VerifyChainError struct {
+	// Hash and Height identify the block that failed the check.
+	Hash   chainhash.Hash
+	Height int32
+
+	// Level is the level of the check that failed.
+	Level int32
+
+	// Err is the reason the check failed.  It is a RuleError when the
+	// block violates a consensus rule.
+	Err error
+}
+
+// Error satisfies the error interface and includes the rule that was violated
+// when there is one.
+func (e VerifyChainError) Error() string {
+	reason := e.Err.Error()
+	if rerr, ok := e.Err.(RuleError); ok {
+		reason = fmt.Sprintf("%v: %v", rerr.ErrorCode, rerr.Description)
+	}
+	return fmt.Sprintf("block %v (height %d) failed level %d checks: %s",
+		e.Hash, e.Height, e.Level, reason)
+}
+
+// chainVerifier houses the state used by VerifyChain.
+type chainVerifier struct {
+	b         *BlockChain
+	interrupt <-chan struct{}
+
+	// tip is the tip of the best chain when the verification started.  The
+	// utxo checks are only valid while it is still the tip.
+	tip *blockNode
+
+	// view is the scratch view the blocks are disconnected from and
+	// reconnected to by the utxo checks.
+	view *UtxoViewpoint
+
+	// disconnected are the blocks and their spend journal entries that
+	// were disconnected from the scratch view, from the tip down.
+	disconnected []*btcutil.Block
+	journal      [][]SpentTxOut
+}
+
+// verifyError returns a VerifyChainError for the passed block.
+func verifyError(block *btcutil.Block, level int32, err error) error {
+	return VerifyChainError{
+		Hash:   *block.Hash(),
+		Height: block.Height(),
+		Level:  level,
+		Err:    err,
+	}
+}
+
+// lookupUtxo returns the entry for the passed output in the scratch view,
+// falling back to the utxo set in the database.  It returns nil when the
+// output is spent or does not exist.
+func (v *chainVerifier) lookupUtxo(outpoint wire.OutPoint) (*UtxoEntry, error) {
+	if entry, ok := v.view.entries[outpoint]; ok {
+		if entry == nil || entry.IsSpent() {
+			return nil, nil
+		}
+		return entry, nil
+	}
+
+	var entry *UtxoEntry
+	err := v.b.db.View(func(dbTx database.Tx) error {
+		var err error
+		entry, err = dbFetchUtxoEntry(dbTx, outpoint)
+		return err
+	})
+	return entry, err
+}
+
+// utxoMatches returns whether the passed entry represents the passed output.
+func utxoMatches(entry *UtxoEntry, amount int64, pkScript []byte, height int32, isCoinBase bool) bool {
+	return entry.Amount() == amount && bytes.Equal(entry.PkScript(), pkScript) &&
+		entry.BlockHeight() == height && entry.IsCoinBase() == isCoinBase
+}
+
+// checkUtxoChanges ensures the utxo set, as of the passed block being the tip
+// of the scratch view, contains the outputs created by the block and none of
+// the outputs spent by it.
+func (v *chainVerifier) checkUtxoChanges(block *btcutil.Block) error {
+	params := v.b.chainParams
+	for txIdx, tx := range block.Transactions() {
+		isCoinBase := txIdx == 0
+		outpoint := wire.OutPoint{Hash: *tx.Hash()}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+
+			outpoint.Index = uint32(txOutIdx)
+			entry, err := v.lookupUtxo(outpoint)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				str := fmt.Sprintf("output %v created by the "+
+					"block is not in the utxo set", outpoint)
+				return errors.New(str)
+			}
+
+			// Duplicate coinbase transactions prior to BIP0034
+			// overwrite the unspent outputs of the earlier ones.
+			if isCoinBase && entry.IsCoinBase() &&
+				entry.BlockHeight() > block.Height() &&
+				entry.BlockHeight() < params.BIP0034Height {
+
+				continue
+			}
+
+			if !utxoMatches(entry, txOut.Value, txOut.PkScript,
+				block.Height(), isCoinBase) {
+
+				str := fmt.Sprintf("utxo set entry for output "+
+					"%v created by the block does not match "+
+					"the output", outpoint)
+				return errors.New(str)
+			}
+		}
+	}
+
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			entry, err := v.lookupUtxo(txIn.PreviousOutPoint)
+			if err != nil {
+				return err
+			}
+			if entry != nil {
+				str := fmt.Sprintf("output %v spent by the "+
+					"block is still in the utxo set",
+					txIn.PreviousOutPoint)
+				return errors.New(str)
+			}
+		}
+	}
+
+	return nil
+}
+
+// disconnectBlock checks the utxo changes of the passed block, which must be
+// the tip of the scratch view, and disconnects it from the view using its
+// spend journal entry.
+func (v *chainVerifier) disconnectBlock(block *btcutil.Block) error {
+	var stxos []SpentTxOut
+	err := v.b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block)
+		return err
+	})
+	if err != nil {
+		return verifyError(block, 3, err)
+	}
+
+	if err := v.checkUtxoChanges(block); err != nil {
+		return verifyError(block, 3, err)
+	}
+
+	// Disconnecting modifies the journal entries, so store a copy for
+	// comparing them when the block is connected again.
+	journal := make([]SpentTxOut, len(stxos))
+	copy(journal, stxos)
+	err = v.view.fetchInputUtxos(v.b.db, &v.b.utxoViewStats, block)
+	if err != nil {
+		return verifyError(block, 3, err)
+	}
+	err = v.view.disconnectTransactions(v.b.db, block, stxos)
+	if err != nil {
+		return verifyError(block, 3, err)
+	}
+
+	v.disconnected = append(v.disconnected, block)
+	v.journal = append(v.journal, journal)
+	return nil
+}
+
+// tipChanged returns whether the tip of the best chain changed since the
+// verification started, in which case the utxo set no longer matches the
+// scratch view.
+//
+// This function MUST be called with the chain lock held (for reads).
+func (v *chainVerifier) tipChanged() bool {
+	return v.b.bestChain.Tip() != v.tip
+}
+
+// reconnectBlock connects the passed block, which was disconnected from the
+// scratch view, again while fully validating it, including its scripts, and
+// ensures the spent outputs match the passed spend journal entry.
+//
+// This function MUST be called with the chain lock held (for writes).
+func (v *chainVerifier) reconnectBlock(block *btcutil.Block, journal []SpentTxOut) error {
+	node := v.b.index.LookupNode(block.Hash())
+	stxos := make([]SpentTxOut, 0, countSpentOutputs(block))
+	err := v.b.checkConnectBlock(node, block, v.view, &stxos,
+		bfVerifyMainChain)
+	if err != nil {
+		return verifyError(block, 4, err)
+	}
+
+	for j := range stxos {
+		stxo, want := &stxos[j], &journal[j]
+		if stxo.Amount != want.Amount || stxo.Height != want.Height ||
+			stxo.IsCoinBase != want.IsCoinBase ||
+			!bytes.Equal(stxo.PkScript, want.PkScript) {
+
+			str := fmt.Sprintf("spend journal entry %d does not "+
+				"match the spent output", j)
+			return verifyError(block, 4, errors.New(str))
+		}
+	}
+	return nil
+}
+
+// reconnectBlocks connects the blocks disconnected from the scratch view again
+// and ensures the resulting outputs match the utxo set.  The chain lock is only
+// held while a single block is reconnected, so the checks stop early when the
+// best chain changes in the mean time.
+func (v *chainVerifier) reconnectBlocks() error {
+	lastLog := time.Now()
+	for i := len(v.disconnected) - 1; i >= 0; i-- {
+		block := v.disconnected[i]
+		v.b.chainLock.Lock()
+		if v.tipChanged() {
+			v.b.chainLock.Unlock()
+			log.Infof("Skipping the remaining level 4 checks since " +
+				"the best chain changed")
+			return nil
+		}
+		err := v.reconnectBlock(block, v.journal[i])
+		v.b.chainLock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if interruptRequested(v.interrupt) {
+			return errInterruptRequested
+		}
+		if time.Since(lastLog) >= dbVerifyProgressInterval {
+			log.Infof("Verify reconnected block at height %d",
+				jsonlog.Height(block.Height()))
+			lastLog = time.Now()
+		}
+	}
+
+	// The scratch view now represents the current tip again, so all of the
+	// outputs it touched must match the utxo set.
+	v.b.chainLock.RLock()
+	defer v.b.chainLock.RUnlock()
+	if v.tipChanged() {
+		log.Infof("Skipping the utxo set comparison since the best " +
+			"chain changed")
+		return nil
+	}
+	return v.b.db.View(func(dbTx database.Tx) error {
+		for outpoint, entry := range v.view.entries {
+			stored, err := dbFetchUtxoEntry(dbTx, outpoint)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case entry == nil || entry.IsSpent():
+				if stored == nil {
+					continue
+				}
+			case stored != nil && utxoMatches(stored, entry.Amount(),
+				entry.PkScript(), entry.BlockHeight(),
+				entry.IsCoinBase()):
+
+				continue
+			}
+
+			str := fmt.Sprintf("utxo set entry for output %v does "+
+				"not match the replayed blocks", outpoint)
+			return VerifyChainError{
+				Hash:   v.tip.hash,
+				Height: v.tip.height,
+				Level:  4,
+				Err:    errors.New(str),
+			}
+		}
+		return nil
+	})
+}
+
+// verifyBlock performs the checks of the passed level on the block of the
+// passed node.  The block is disconnected from the scratch view when the utxo
+// checks are enabled.
+//
+// This function MUST be called with the chain lock held (for writes).
+func (v *chainVerifier) verifyBlock(node *blockNode, level int32, utxoChecks bool) error {
+	// Level 0 just looks up the block.
+	b := v.b
+	var block *btcutil.Block
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByNode(dbTx, node)
+		return err
+	})
+	if err != nil {
+		return VerifyChainError{
+			Hash:   node.hash,
+			Height: node.height,
+			Err:    err,
+		}
+	}
+
+	// Level 1 does basic chain sanity checks.
+	if level >= 1 {
+		err := checkBlockSanity(block, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return verifyError(block, 1, err)
+		}
+	}
+
+	// Level 2 does the checks that depend on the position of the block
+	// within the chain.
+	if level >= 2 {
+		err := b.checkBlockContext(block, node.parent, bfVerifyMainChain)
+		if err != nil {
+			return verifyError(block, 2, err)
+		}
+	}
+
+	if utxoChecks {
+		return v.disconnectBlock(block)
+	}
+	return nil
+}
+
+// VerifyChain validates the blocks of the main chain again, starting from the
+// tip and going back the passed depth, where a depth of 0 means all blocks.
+// The level determines how thorough the checks are:
+//
+//	0 - Ensure each block can be loaded from the database
+//	1 - Perform the context-free sanity checks on each block
+//	2 - Perform the contextual checks on each block, such as the difficulty,
+//	    timestamp, finalized transaction, and witness commitment rules
+//	3 - Ensure the utxo set contains the outputs created by each block and
+//	    none spent by it according to its spend journal entry while
+//	    disconnecting the blocks from a scratch view of the utxo set
+//	4 - Connect the disconnected blocks to the scratch view again while fully
+//	    validating them, including executing all scripts, and ensure the
+//	    spent outputs and the resulting utxos match the spend journal entries
+//	    and the utxo set
+//
+// Levels above MaxVerifyLevel are treated as MaxVerifyLevel.  The checks of
+// levels 3 and 4 stop once the scratch view grows too large, in which case the
+// remaining blocks are only checked at level 2.
+//
+// A VerifyChainError identifying the block and the reason is returned when a
+// block fails a check.
+//
+// The chain lock is only held while a single block is checked, so the chain
+// keeps processing blocks during a long verification.  The blocks of the main
+// chain as of the start are verified, and the checks of levels 3 and 4 stop
+// early when the best chain changes in the mean time.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyChain(level, depth int32, interrupt <-chan struct{}) error {
+	if level > MaxVerifyLevel {
+		level = MaxVerifyLevel
+	}
+
+	b.chainLock.RLock()
+	tip := b.bestChain.Tip()
+	b.chainLock.RUnlock()
+	finishHeight := tip.height - depth
+	if depth <= 0 || finishHeight < 0 {
+		finishHeight = 0
+	}
+	log.Infof("Verifying chain for %d blocks at level %d",
+		tip.height-finishHeight, level)
+
+	v := &chainVerifier{
+		b:         b,
+		interrupt: interrupt,
+		tip:       tip,
+		view:      NewUtxoViewpoint(),
+	}
+	v.view.SetBestHash(&tip.hash)
+	utxoChecks := level >= 3
+	lastLog := time.Now()
+	for node := tip; node.height > finishHeight; node = node.parent {
+		// Level 3 checks the utxo changes of the block against the
+		// utxo set while disconnecting it from the scratch view.
+		if utxoChecks && len(v.view.entries) > maxVerifyViewEntries {
+			log.Infof("Skipping utxo checks of the blocks before "+
+				"height %d due to their memory usage",
+				node.height+1)
+			utxoChecks = false
+		}
+
+		// The chain lock is only held while a single block is checked
+		// so the chain isn't stalled for the whole verification.  The
+		// blocks are found by walking back from the initial tip, so
+		// the lower levels aren't affected by changes to the best
+		// chain in the mean time, but the utxo checks are.
+		b.chainLock.Lock()
+		if utxoChecks && v.tipChanged() {
+			log.Infof("Skipping utxo checks of the blocks before "+
+				"height %d since the best chain changed",
+				node.height+1)
+			utxoChecks = false
+		}
+		err := v.verifyBlock(node, level, utxoChecks)
+		b.chainLock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if interruptRequested(interrupt) {
+			return errInterruptRequested
+		}
+		if time.Since(lastLog) >= dbVerifyProgressInterval {
+			log.Infof("Verified block at height %d",
+				jsonlog.Height(node.height))
+			lastLog = time.Now()
+		}
+	}
+
+	// Level 4 connects the disconnected blocks again.
+	if level >= 4 {
+		if err := v.reconnectBlocks(); err != nil {
+			return err
+		}
+	}
+
+	log.Infof("Chain verify completed successfully")
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestVerifyChain ensures VerifyChain passes for a consistent chain at all
+// levels and identifies the failing level and block once the utxo set no
+// longer matches the chain.
+func TestVerifyChain(t *testing.T) {
+	blocks, err := loadBlocks("blk_0_to_4.dat.bz2")
+	if err != nil {
+		t.Fatalf("Error loading file: %v\n", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("verifychain",
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+	chain.TstSetCoinbaseMaturity(1)
+
+	for i := 1; i < len(blocks); i++ {
+		_, _, err := chain.ProcessBlock(blocks[i], BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock fail on block %v: %v\n", i, err)
+		}
+	}
+
+	for level := int32(0); level <= MaxVerifyLevel+1; level++ {
+		if err := chain.VerifyChain(level, 0, nil); err != nil {
+			t.Fatalf("VerifyChain level %d: unexpected error: %v",
+				level, err)
+		}
+	}
+
+	// Remove the newest output of block 4 from the utxo set.
+	tx := blocks[4].Transactions()[len(blocks[4].Transactions())-1]
+	err = chain.db.Update(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		for i := range tx.MsgTx().TxOut {
+			key := outpointKey(*wire.NewOutPoint(tx.Hash(), uint32(i)))
+			err := utxoBucket.Delete(*key)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to update database: %v", err)
+	}
+
+	// The checks that do not involve the utxo set still pass.
+	if err := chain.VerifyChain(2, 0, nil); err != nil {
+		t.Fatalf("VerifyChain level 2: unexpected error: %v", err)
+	}
+
+	err = chain.VerifyChain(4, 0, nil)
+	verr, ok := err.(VerifyChainError)
+	if !ok {
+		t.Fatalf("VerifyChain level 4: unexpected error: %v (%T)", err,
+			err)
+	}
+	if verr.Level != 3 || verr.Height != 4 || verr.Hash != *blocks[4].Hash() {
+		t.Fatalf("VerifyChain level 4: unexpected error: %v", verr)
+	}
+}
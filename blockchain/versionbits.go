@@ -5,6 +5,7 @@
 package blockchain
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/btcsuite/btcd/chaincfg"
@@ -229,6 +230,7 @@ func (b *BlockChain) CalcNextBlockVersion() (int32, error) {
 func (b *BlockChain) warnUnknownRuleActivations(node *blockNode) error {
 	// Warn if any unknown new rules are either about to activate or have
 	// already been activated.
+	var warning string
 	for bit := uint32(0); bit < vbNumBits; bit++ {
 		checker := bitConditionChecker{bit: bit, chain: b}
 		cache := &b.warningCaches[bit]
@@ -244,14 +246,35 @@ func (b *BlockChain) warnUnknownRuleActivations(node *blockNode) error {
 					bit)
 				b.unknownRulesWarned = true
 			}
+			warning = fmt.Sprintf("Unknown new rules activated "+
+				"(bit %d)", bit)
 
 		case ThresholdLockedIn:
 			window := int32(checker.MinerConfirmationWindow())
 			activationHeight := window - (node.height % window)
 			log.Warnf("Unknown new rules are about to activate in "+
 				"%d blocks (bit %d)", activationHeight, bit)
+			if warning == "" {
+				warning = fmt.Sprintf("Unknown new rules are "+
+					"about to activate in %d blocks (bit %d)",
+					activationHeight, bit)
+			}
 		}
 	}
+	b.warning = warning
 
 	return nil
 }
+
+// Warnings returns a human-readable warning about unknown rules that are either
+// about to activate or have already been activated on the main chain, or an
+// empty string when there are none.  Warnings are only determined once the
+// chain is current.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) Warnings() string {
+	b.chainLock.RLock()
+	warning := b.warning
+	b.chainLock.RUnlock()
+	return warning
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testChain returns a chain of the passed number of serialized blocks starting
+// with the regression test genesis block.  The blocks only link by their
+// previous block hash and are not otherwise valid.
+func testChain(t *testing.T, n int) ([][]byte, []chainhash.Hash) {
+	genesis := *chaincfg.RegressionNetParams.GenesisBlock
+	blocks := make([][]byte, 0, n)
+	hashes := make([]chainhash.Hash, 0, n)
+	prev := chainhash.Hash{}
+	for i := 0; i < n; i++ {
+		block := genesis
+		block.Header.PrevBlock = prev
+		block.Header.Nonce = uint32(i)
+		if i == 0 {
+			block.Header = genesis.Header
+		}
+		var buf bytes.Buffer
+		if err := block.Serialize(&buf); err != nil {
+			t.Fatalf("unable to serialize block: %v", err)
+		}
+		blocks = append(blocks, buf.Bytes())
+		prev = block.BlockHash()
+		hashes = append(hashes, prev)
+	}
+	return blocks, hashes
+}
+
+// writeBlockFile writes the passed blocks to a block file obfuscated with the
+// passed key, followed by the passed number of zeros like a preallocated file.
+func writeBlockFile(t *testing.T, path string, net wire.BitcoinNet,
+	blocks [][]byte, padding int, key []byte) {
+
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		binary.Write(&buf, binary.LittleEndian, uint32(net))
+		binary.Write(&buf, binary.LittleEndian, uint32(len(block)))
+		buf.Write(block)
+	}
+	buf.Write(make([]byte, padding))
+	data := buf.Bytes()
+	xor(data, key, 0)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("unable to write block file: %v", err)
+	}
+}
+
+// TestOrderer ensures blocks are read from a directory of obfuscated and
+// preallocated block files and returned after their parents.
+func TestOrderer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockfile")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Write the blocks to files in the blocks subdirectory the way Bitcoin
+	// Core does, including an unlinked block and a block of another
+	// network.
+	blocksDir := filepath.Join(dir, "blocks")
+	if err := os.Mkdir(blocksDir, 0700); err != nil {
+		t.Fatalf("unable to create blocks dir: %v", err)
+	}
+	key := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}
+	if err := ioutil.WriteFile(filepath.Join(blocksDir, xorKeyFilename),
+		key, 0600); err != nil {
+
+		t.Fatalf("unable to write key: %v", err)
+	}
+	net := chaincfg.RegressionNetParams.Net
+	blocks, hashes := testChain(t, 6)
+	unlinked, _ := testChain(t, 8)
+	unlinked = unlinked[7:]
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00000.dat"), net,
+		[][]byte{blocks[2], blocks[0], unlinked[0], blocks[3]}, 77, key)
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00001.dat"), wire.MainNet,
+		[][]byte{blocks[4]}, 0, key)
+	writeBlockFile(t, filepath.Join(blocksDir, "blk00002.dat"), net,
+		[][]byte{blocks[5], blocks[1]}, 3, key)
+	ioutil.WriteFile(filepath.Join(blocksDir, "rev00000.dat"), nil, 0600)
+
+	r, err := Open(dir, net)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer r.Close()
+	if len(r.Files()) != 3 {
+		t.Fatalf("unexpected block files %v", r.Files())
+	}
+
+	haveBlock := func(*chainhash.Hash) (bool, error) { return false, nil }
+	o := NewOrderer(r, haveBlock)
+	var got []chainhash.Hash
+	for {
+		block, err := o.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: unexpected error: %v", err)
+		}
+		header, err := parseHeader(block)
+		if err != nil {
+			t.Fatalf("unable to parse header: %v", err)
+		}
+		got = append(got, header.BlockHash())
+	}
+
+	// Block 4 is in the file of another network, so block 5 is unlinked
+	// along with the unrelated block.
+	want := hashes[:4]
+	if len(got) != len(want) {
+		t.Fatalf("unexpected number of blocks -- got %d, want %d",
+			len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("block #%d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+	if o.Unlinked() != 2 {
+		t.Fatalf("unexpected number of unlinked blocks -- got %d, "+
+			"want 2", o.Unlinked())
+	}
+}
+
+// TestReaderSingleFile ensures blocks are read in order from a single
+// bootstrap.dat style file and that blocks of other networks are skipped.
+func TestReaderSingleFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blockfile")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	net := chaincfg.RegressionNetParams.Net
+	blocks, _ := testChain(t, 3)
+	path := filepath.Join(dir, "bootstrap.dat")
+	writeBlockFile(t, path, net, blocks, 0, nil)
+
+	r, err := Open(path, net)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer r.Close()
+	for i, want := range blocks {
+		block, loc, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next #%d: unexpected error: %v", i, err)
+		}
+		if !bytes.Equal(block, want) {
+			t.Fatalf("Next #%d: unexpected block", i)
+		}
+		again, err := r.ReadAt(loc)
+		if err != nil || !bytes.Equal(again, want) {
+			t.Fatalf("ReadAt #%d: unexpected block (err %v)", i, err)
+		}
+	}
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Next: unexpected error -- got %v, want EOF", err)
+	}
+
+	r, err = Open(path, wire.MainNet)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer r.Close()
+	if _, _, err := r.Next(); err != io.EOF {
+		t.Fatalf("Next: unexpected error -- got %v, want EOF", err)
+	}
+}
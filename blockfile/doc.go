@@ -0,0 +1,22 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package blockfile reads blocks from bootstrap.dat files and from the blk*.dat
+block files of Bitcoin Core.
+
+Both formats store each block as the network magic, the little-endian block
+size, and the serialized block.  Bitcoin Core preallocates its block files, so
+the records may be followed by zeros, and starting with Bitcoin Core 28.0 the
+files are obfuscated by XORing them with the 8 byte key stored in xor.dat in the
+same directory.  The Reader handles both by scanning for the network magic and
+removing the obfuscation while reading.
+
+Bitcoin Core writes blocks in the order they are downloaded, which is not
+necessarily the order of the chain.  The Orderer reorders the blocks on the fly
+so that every block is returned after its parent.  Only the positions of the
+blocks that are waiting for their parent are kept in memory, and they are read
+again once their parent was returned.
+*/
+package blockfile
@@ -0,0 +1,158 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockfile
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// maxRecentBlocks is the number of most recently returned blocks the Orderer
+// remembers.  Older blocks are expected to be processed by the time a child
+// shows up, so they are looked up with the HaveBlock function of the Orderer
+// instead.
+const maxRecentBlocks = 4096
+
+// HaveBlockFunc returns whether or not the block with the passed hash is
+// already known, which means its children can be returned.
+type HaveBlockFunc func(hash *chainhash.Hash) (bool, error)
+
+// Orderer returns the blocks read by a Reader in an order in which every block
+// follows its parent.  Blocks that show up before their parent are held back
+// until the parent was returned.
+type Orderer struct {
+	r         *Reader
+	haveBlock HaveBlockFunc
+
+	// pending maps the hashes of parents that were not seen yet to the
+	// locations of their children.  numPending is the total number of
+	// children.
+	pending    map[chainhash.Hash][]Location
+	numPending int
+
+	// ready holds the locations of the blocks that were held back and
+	// whose parent was returned since.
+	ready []Location
+
+	// recent holds the hashes of the most recently returned blocks.
+	// recentOrder is a ring buffer of the same hashes in the order they
+	// were returned so the oldest one can be evicted.
+	recent      map[chainhash.Hash]struct{}
+	recentOrder []chainhash.Hash
+	recentIdx   int
+}
+
+// NewOrderer returns an orderer for the blocks read by the passed reader.  The
+// passed function is used to find out whether or not the parent of a block is
+// already known, such as when it is in the block chain.  The genesis block
+// doesn't have a parent, so it is always returned right away.
+func NewOrderer(r *Reader, haveBlock HaveBlockFunc) *Orderer {
+	return &Orderer{
+		r:           r,
+		haveBlock:   haveBlock,
+		pending:     make(map[chainhash.Hash][]Location),
+		recent:      make(map[chainhash.Hash]struct{}),
+		recentOrder: make([]chainhash.Hash, 0, maxRecentBlocks),
+	}
+}
+
+// parseHeader deserializes the header of the passed serialized block.
+func parseHeader(block []byte) (*wire.BlockHeader, error) {
+	var header wire.BlockHeader
+	err := header.Deserialize(bytes.NewReader(block))
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// linked returns whether or not the block with the passed hash is known, so its
+// children can be returned.
+func (o *Orderer) linked(hash *chainhash.Hash) (bool, error) {
+	if *hash == (chainhash.Hash{}) {
+		return true, nil
+	}
+	if _, ok := o.recent[*hash]; ok {
+		return true, nil
+	}
+	return o.haveBlock(hash)
+}
+
+// returned remembers the passed block hash as recently returned and releases
+// the children of the block that were held back.
+func (o *Orderer) returned(hash chainhash.Hash) {
+	if _, ok := o.recent[hash]; !ok {
+		if len(o.recentOrder) < maxRecentBlocks {
+			o.recentOrder = append(o.recentOrder, hash)
+		} else {
+			delete(o.recent, o.recentOrder[o.recentIdx])
+			o.recentOrder[o.recentIdx] = hash
+			o.recentIdx = (o.recentIdx + 1) % maxRecentBlocks
+		}
+		o.recent[hash] = struct{}{}
+	}
+
+	if children, ok := o.pending[hash]; ok {
+		o.ready = append(o.ready, children...)
+		o.numPending -= len(children)
+		delete(o.pending, hash)
+	}
+}
+
+// Next returns the next serialized block.  It returns io.EOF when there are no
+// more blocks that link to the known blocks.  Blocks with a malformed header
+// are skipped.
+func (o *Orderer) Next() ([]byte, error) {
+	for {
+		// Return the blocks that were held back first.
+		if len(o.ready) > 0 {
+			loc := o.ready[0]
+			o.ready = o.ready[1:]
+			block, err := o.r.ReadAt(loc)
+			if err != nil {
+				return nil, err
+			}
+			header, err := parseHeader(block)
+			if err != nil {
+				return nil, err
+			}
+			o.returned(header.BlockHash())
+			return block, nil
+		}
+
+		block, loc, err := o.r.Next()
+		if err != nil {
+			return nil, err
+		}
+		header, err := parseHeader(block)
+		if err != nil {
+			continue
+		}
+
+		// Hold the block back when its parent is not known yet.
+		linked, err := o.linked(&header.PrevBlock)
+		if err != nil {
+			return nil, err
+		}
+		if !linked {
+			o.pending[header.PrevBlock] = append(
+				o.pending[header.PrevBlock], loc)
+			o.numPending++
+			continue
+		}
+
+		o.returned(header.BlockHash())
+		return block, nil
+	}
+}
+
+// Unlinked returns the number of blocks that are held back because their
+// parent was not seen yet.  Once Next returned io.EOF, these are the blocks
+// that don't link to the known blocks.
+func (o *Orderer) Unlinked() int {
+	return o.numPending
+}
@@ -0,0 +1,333 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// xorKeyFilename is the name of the file that holds the key Bitcoin
+	// Core obfuscates its block files with.
+	xorKeyFilename = "xor.dat"
+
+	// xorKeySize is the size of the obfuscation key.
+	xorKeySize = 8
+
+	// minBlockSize is the size of the smallest possible serialized block,
+	// which is a header followed by a transaction count and a minimal
+	// coinbase transaction.  Records claiming to be smaller are skipped.
+	minBlockSize = wire.MaxBlockHeaderPayload + 1 + 60
+)
+
+// blockFileRegexp matches the names of the block files of Bitcoin Core.
+var blockFileRegexp = regexp.MustCompile(`^blk[0-9]+\.dat$`)
+
+// Location identifies a serialized block in the block files of a Reader.
+type Location struct {
+	// File is the index of the file in the files of the Reader.
+	File int
+
+	// Offset is the offset of the serialized block in the file.
+	Offset int64
+
+	// Size is the size of the serialized block.
+	Size uint32
+}
+
+// BlockFiles returns the paths of the Bitcoin Core block files in the passed
+// directory in the order they were written.  The blocks subdirectory is used
+// when the directory itself doesn't contain any block files, so the data
+// directory of Bitcoin Core may be passed as well.
+func BlockFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() && blockFileRegexp.MatchString(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		blocksDir := filepath.Join(dir, "blocks")
+		if fi, err := os.Stat(blocksDir); err == nil && fi.IsDir() {
+			return BlockFiles(blocksDir)
+		}
+		return nil, fmt.Errorf("no block files found in %s", dir)
+	}
+
+	// The file numbers are zero padded, but sort by length first in case
+	// there are ever more files than the padding allows for.
+	sort.Slice(files, func(i, j int) bool {
+		if len(files[i]) != len(files[j]) {
+			return len(files[i]) < len(files[j])
+		}
+		return files[i] < files[j]
+	})
+	return files, nil
+}
+
+// ReadXORKey returns the key the block files in the passed directory are
+// obfuscated with.  Nil is returned when the files are not obfuscated, either
+// because there is no key file or because the key is all zeros.
+func ReadXORKey(dir string) ([]byte, error) {
+	key, err := ioutil.ReadFile(filepath.Join(dir, xorKeyFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(key) != xorKeySize {
+		return nil, fmt.Errorf("%s has an invalid size of %d bytes",
+			xorKeyFilename, len(key))
+	}
+	for _, b := range key {
+		if b != 0 {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+// xorReader removes the obfuscation from the data read from the underlying
+// reader.  The offset is the position in the file the next byte is read from.
+type xorReader struct {
+	r      io.Reader
+	key    []byte
+	offset int64
+}
+
+// Read reads from the underlying reader and removes the obfuscation.
+func (r *xorReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	xor(p[:n], r.key, r.offset)
+	r.offset += int64(n)
+	return n, err
+}
+
+// xor XORs the passed data, which starts at the passed offset of a file, with
+// the key.  Nothing is done when the key is empty.
+func xor(data, key []byte, offset int64) {
+	if len(key) == 0 {
+		return
+	}
+	k := int(offset % int64(len(key)))
+	for i := range data {
+		data[i] ^= key[k]
+		k++
+		if k == len(key) {
+			k = 0
+		}
+	}
+}
+
+// Reader reads serialized blocks from a sequence of block files.
+type Reader struct {
+	files []string
+	net   wire.BitcoinNet
+	key   []byte
+
+	// fileIdx, file, r, and offset are the index of the file blocks are
+	// currently read from, the file itself, a reader for its deobfuscated
+	// contents, and the current offset in it.
+	fileIdx int
+	file    *os.File
+	r       *bufio.Reader
+	offset  int64
+
+	// readFileIdx and readFile are the index of the file that was last
+	// opened by ReadAt and the file itself.
+	readFileIdx int
+	readFile    *os.File
+}
+
+// NewReader returns a reader for the blocks of the passed network in the passed
+// files, which are obfuscated with the passed key.  The key may be nil.
+func NewReader(files []string, net wire.BitcoinNet, key []byte) *Reader {
+	return &Reader{
+		files:       files,
+		net:         net,
+		key:         key,
+		fileIdx:     -1,
+		readFileIdx: -1,
+	}
+}
+
+// Open returns a reader for the blocks of the passed network in the passed
+// path, which is either a single block file, such as bootstrap.dat, or a
+// directory containing the block files of Bitcoin Core.  See BlockFiles and
+// ReadXORKey for details on how the block files in a directory are found.
+func Open(path string, net wire.BitcoinNet) (*Reader, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return NewReader([]string{path}, net, nil), nil
+	}
+
+	files, err := BlockFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ReadXORKey(filepath.Dir(files[0]))
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(files, net, key), nil
+}
+
+// Files returns the paths of the block files the reader reads from.
+func (r *Reader) Files() []string {
+	return r.files
+}
+
+// nextFile opens the next block file.  It returns io.EOF when there are no
+// more files.
+func (r *Reader) nextFile() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	if r.fileIdx+1 >= len(r.files) {
+		return io.EOF
+	}
+	r.fileIdx++
+	f, err := os.Open(r.files[r.fileIdx])
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.r = bufio.NewReaderSize(&xorReader{r: f, key: r.key}, 1<<20)
+	r.offset = 0
+	return nil
+}
+
+// Next returns the next serialized block along with its location.  Data that
+// doesn't belong to a block of the network of the reader, such as the zeros at
+// the end of preallocated files, is skipped.  It returns io.EOF when there are
+// no more blocks.
+func (r *Reader) Next() ([]byte, Location, error) {
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(r.net))
+
+	for {
+		if r.file == nil {
+			if err := r.nextFile(); err != nil {
+				return nil, Location{}, err
+			}
+		}
+
+		// Scan for the network magic.
+		var window [4]byte
+		scanned := 0
+		for scanned < len(magic) || window != magic {
+			b, err := r.r.ReadByte()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, Location{}, err
+			}
+			r.offset++
+			copy(window[:], window[1:])
+			window[3] = b
+			scanned++
+		}
+		if scanned < len(magic) || window != magic {
+			// Move on to the next file.
+			if err := r.nextFile(); err != nil {
+				return nil, Location{}, err
+			}
+			continue
+		}
+
+		// Read the block size and skip records with a bogus size.
+		var sizeBuf [4]byte
+		n, err := io.ReadFull(r.r, sizeBuf[:])
+		r.offset += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.file.Close()
+			r.file = nil
+			continue
+		}
+		if err != nil {
+			return nil, Location{}, err
+		}
+		size := binary.LittleEndian.Uint32(sizeBuf[:])
+		if size < minBlockSize || size > wire.MaxBlockPayload {
+			continue
+		}
+
+		loc := Location{File: r.fileIdx, Offset: r.offset, Size: size}
+		block := make([]byte, size)
+		n, err = io.ReadFull(r.r, block)
+		r.offset += int64(n)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			// The last block of a file may be truncated when the
+			// node that wrote it crashed.
+			r.file.Close()
+			r.file = nil
+			continue
+		}
+		if err != nil {
+			return nil, Location{}, err
+		}
+		return block, loc, nil
+	}
+}
+
+// ReadAt returns the serialized block at the passed location.
+func (r *Reader) ReadAt(loc Location) ([]byte, error) {
+	if loc.File < 0 || loc.File >= len(r.files) {
+		return nil, fmt.Errorf("invalid block file index %d", loc.File)
+	}
+	if r.readFileIdx != loc.File {
+		if r.readFile != nil {
+			r.readFile.Close()
+			r.readFile = nil
+		}
+		f, err := os.Open(r.files[loc.File])
+		if err != nil {
+			return nil, err
+		}
+		r.readFile = f
+		r.readFileIdx = loc.File
+	}
+
+	block := make([]byte, loc.Size)
+	if _, err := r.readFile.ReadAt(block, loc.Offset); err != nil {
+		return nil, err
+	}
+	xor(block, r.key, loc.Offset)
+	return block, nil
+}
+
+// Close closes the open block files.
+func (r *Reader) Close() error {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	if r.readFile != nil {
+		r.readFile.Close()
+		r.readFile = nil
+		r.readFileIdx = -1
+	}
+	return nil
+}
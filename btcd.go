@@ -164,6 +164,16 @@ func btcdMain(serverChan chan<- *server) error {
 		serverChan <- server
 	}
 
+	// Import the blocks from the files specified with the loadblock
+	// option.  The import runs while the server is up, so it stops early
+	// once an interrupt is requested.
+	if len(cfg.LoadBlocks) > 0 {
+		err := importBlockFiles(server, cfg.LoadBlocks, interrupt)
+		if err != nil {
+			btcdLog.Errorf("Unable to import blocks: %v", err)
+		}
+	}
+
 	// Wait until the interrupt signal is received from an OS signal or
 	// shutdown is requested through one of the subsystems such as the RPC
 	// server.
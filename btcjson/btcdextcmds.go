@@ -59,6 +59,24 @@ func NewDebugLevelCmd(levelSpec string) *DebugLevelCmd {
 	}
 }
 
+// LoggingCmd defines the logging JSON-RPC command.  This command is not a
+// standard Bitcoin command.  It is an extension for btcd.
+type LoggingCmd struct {
+	Levels *map[string]string
+}
+
+// NewLoggingCmd returns a new LoggingCmd which can be used to issue a logging
+// JSON-RPC command.  The levels map subsystems to the log level to set for
+// them.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewLoggingCmd(levels *map[string]string) *LoggingCmd {
+	return &LoggingCmd{
+		Levels: levels,
+	}
+}
+
 // GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
 type GenerateToAddressCmd struct {
 	NumBlocks int64
@@ -141,6 +159,16 @@ type VersionCmd struct{}
 // github.com/decred/dcrd/dcrjson.
 func NewVersionCmd() *VersionCmd { return new(VersionCmd) }
 
+// RPCDiscoverCmd defines the rpc.discover JSON-RPC command which returns an
+// OpenRPC document describing the methods supported by the server.  This
+// command is not a standard Bitcoin command.  It is an extension for btcd.
+type RPCDiscoverCmd struct{}
+
+// NewRPCDiscoverCmd returns a new instance which can be used to issue an
+// rpc.discover JSON-RPC command.  This command is not a standard Bitcoin
+// command.  It is an extension for btcd.
+func NewRPCDiscoverCmd() *RPCDiscoverCmd { return new(RPCDiscoverCmd) }
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
@@ -152,5 +180,7 @@ func init() {
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("logging", (*LoggingCmd)(nil), flags)
+	MustRegisterCmd("rpc.discover", (*RPCDiscoverCmd)(nil), flags)
 	MustRegisterCmd("version", (*VersionCmd)(nil), flags)
 }
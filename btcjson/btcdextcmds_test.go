@@ -194,6 +194,44 @@ func TestBtcdExtCmds(t *testing.T) {
 				HashStop: "000000000000000000ba33b33e1fad70b69e234fc24414dd47113bff38f523f7",
 			},
 		},
+		{
+			name: "logging",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("logging")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewLoggingCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"logging","params":[],"id":1}`,
+			unmarshalled: &btcjson.LoggingCmd{
+				Levels: nil,
+			},
+		},
+		{
+			name: "logging levels",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("logging", `{"PEER":"debug","SYNC":"trace"}`)
+			},
+			staticCmd: func() interface{} {
+				levels := map[string]string{"PEER": "debug", "SYNC": "trace"}
+				return btcjson.NewLoggingCmd(&levels)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"logging","params":[{"PEER":"debug","SYNC":"trace"}],"id":1}`,
+			unmarshalled: &btcjson.LoggingCmd{
+				Levels: &map[string]string{"PEER": "debug", "SYNC": "trace"},
+			},
+		},
+		{
+			name: "rpc.discover",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rpc.discover")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRPCDiscoverCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"rpc.discover","params":[],"id":1}`,
+			unmarshalled: &btcjson.RPCDiscoverCmd{},
+		},
 		{
 			name: "version",
 			newCmd: func() (interface{}, error) {
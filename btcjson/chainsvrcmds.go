@@ -47,6 +47,41 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// AnalyzePsbtCmd defines the analyzepsbt JSON-RPC command.
+type AnalyzePsbtCmd struct {
+	Psbt string
+}
+
+// NewAnalyzePsbtCmd returns a new instance which can be used to issue an
+// analyzepsbt JSON-RPC command.
+func NewAnalyzePsbtCmd(psbt string) *AnalyzePsbtCmd {
+	return &AnalyzePsbtCmd{
+		Psbt: psbt,
+	}
+}
+
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
+// CombinePsbtCmd defines the combinepsbt JSON-RPC command.
+type CombinePsbtCmd struct {
+	Txs []string
+}
+
+// NewCombinePsbtCmd returns a new instance which can be used to issue a
+// combinepsbt JSON-RPC command.
+func NewCombinePsbtCmd(txs []string) *CombinePsbtCmd {
+	return &CombinePsbtCmd{
+		Txs: txs,
+	}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -80,6 +115,19 @@ func NewCreateRawTransactionCmd(inputs []TransactionInput, amounts map[string]fl
 	}
 }
 
+// DecodePsbtCmd defines the decodepsbt JSON-RPC command.
+type DecodePsbtCmd struct {
+	Psbt string
+}
+
+// NewDecodePsbtCmd returns a new instance which can be used to issue a
+// decodepsbt JSON-RPC command.
+func NewDecodePsbtCmd(psbt string) *DecodePsbtCmd {
+	return &DecodePsbtCmd{
+		Psbt: psbt,
+	}
+}
+
 // DecodeRawTransactionCmd defines the decoderawtransaction JSON-RPC command.
 type DecodeRawTransactionCmd struct {
 	HexTx string
@@ -109,7 +157,7 @@ func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
 // DeriveAddressesCmd defines the deriveaddresses JSON-RPC command.
 type DeriveAddressesCmd struct {
 	Descriptor string
-	Range      *DescriptorRange
+	Range      *DescriptorRange `jsonrpcusage:"n|[n,n]"`
 }
 
 // NewDeriveAddressesCmd returns a new instance which can be used to issue a
@@ -121,6 +169,24 @@ func NewDeriveAddressesCmd(descriptor string, descriptorRange *DescriptorRange)
 	}
 }
 
+// FinalizePsbtCmd defines the finalizepsbt JSON-RPC command.
+type FinalizePsbtCmd struct {
+	Psbt    string
+	Extract *bool `jsonrpcdefault:"true"`
+}
+
+// NewFinalizePsbtCmd returns a new instance which can be used to issue a
+// finalizepsbt JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewFinalizePsbtCmd(psbt string, extract *bool) *FinalizePsbtCmd {
+	return &FinalizePsbtCmd{
+		Psbt:    psbt,
+		Extract: extract,
+	}
+}
+
 // ChangeType defines the different output types to use for the change address
 // of a transaction built by the node.
 type ChangeType string
@@ -629,11 +695,13 @@ func NewGetPeerInfoCmd() *GetPeerInfoCmd {
 
 // GetRawMempoolCmd defines the getmempool JSON-RPC command.
 type GetRawMempoolCmd struct {
-	Verbose *bool `jsonrpcdefault:"false"`
+	Verbose         *bool `jsonrpcdefault:"false"`
+	MempoolSequence *bool `jsonrpcdefault:"false"`
 }
 
 // NewGetRawMempoolCmd returns a new instance which can be used to issue a
-// getrawmempool JSON-RPC command.
+// getrawmempool JSON-RPC command.  The MempoolSequence field of the returned
+// command may be set to also request the mempool sequence number.
 //
 // The parameters which are pointers indicate they are optional.  Passing nil
 // for optional parameters will use the default value.
@@ -756,6 +824,15 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -791,6 +868,84 @@ func NewReconsiderBlockCmd(blockHash string) *ReconsiderBlockCmd {
 	}
 }
 
+// ScanTxOutSetAction defines the type used in the scantxoutset JSON-RPC
+// command for the action field.
+type ScanTxOutSetAction string
+
+const (
+	// STOStart indicates a scan of the utxo set should be started.
+	STOStart ScanTxOutSetAction = "start"
+
+	// STOAbort indicates the scan in progress should be aborted.
+	STOAbort ScanTxOutSetAction = "abort"
+
+	// STOStatus indicates the progress of the scan in progress should be
+	// returned.
+	STOStatus ScanTxOutSetAction = "status"
+)
+
+// ScanObject defines a single object to scan the utxo set for with the
+// scantxoutset JSON-RPC command, which is also used to pass descriptors to the
+// utxoupdatepsbt JSON-RPC command.  It is marshalled as the plain descriptor
+// when there is no range and as an object with the descriptor and the range
+// otherwise.
+type ScanObject struct {
+	Descriptor string           `json:"desc"`
+	Range      *DescriptorRange `json:"range,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface for ScanObject
+func (s ScanObject) MarshalJSON() ([]byte, error) {
+	if s.Range == nil {
+		return json.Marshal(s.Descriptor)
+	}
+	return json.Marshal(struct {
+		Descriptor string           `json:"desc"`
+		Range      *DescriptorRange `json:"range"`
+	}{s.Descriptor, s.Range})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface for ScanObject
+func (s *ScanObject) UnmarshalJSON(data []byte) error {
+	var descriptor string
+	if err := json.Unmarshal(data, &descriptor); err == nil {
+		*s = ScanObject{Descriptor: descriptor}
+		return nil
+	}
+
+	var object struct {
+		Descriptor *string          `json:"desc"`
+		Range      *DescriptorRange `json:"range"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return fmt.Errorf("invalid scan object value: %s", data)
+	}
+	if object.Descriptor == nil {
+		return fmt.Errorf("scan object is missing the descriptor: %s",
+			data)
+	}
+	*s = ScanObject{Descriptor: *object.Descriptor, Range: object.Range}
+	return nil
+}
+
+// ScanTxOutSetCmd defines the scantxoutset JSON-RPC command.
+type ScanTxOutSetCmd struct {
+	Action      ScanTxOutSetAction `jsonrpcusage:"\"start|abort|status\""`
+	ScanObjects *[]ScanObject      `jsonrpcusage:"[\"descriptor\"|{\"desc\":\"descriptor\",\"range\":n|[n,n]},...]"`
+}
+
+// NewScanTxOutSetCmd returns a new instance which can be used to issue a
+// scantxoutset JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewScanTxOutSetCmd(action ScanTxOutSetAction, scanObjects *[]ScanObject) *ScanTxOutSetCmd {
+	return &ScanTxOutSetCmd{
+		Action:      action,
+		ScanObjects: scanObjects,
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -849,6 +1004,43 @@ func NewBitcoindSendRawTransactionCmd(hexTx string, maxFeeRate int32) *SendRawTr
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban on the specified subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	Subnet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subnet string, subCmd SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		Subnet:   subnet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -927,6 +1119,24 @@ func NewUptimeCmd() *UptimeCmd {
 	return &UptimeCmd{}
 }
 
+// UtxoUpdatePsbtCmd defines the utxoupdatepsbt JSON-RPC command.
+type UtxoUpdatePsbtCmd struct {
+	Psbt        string
+	Descriptors *[]ScanObject `jsonrpcusage:"[\"descriptor\"|{\"desc\":\"descriptor\",\"range\":n|[n,n]},...]"`
+}
+
+// NewUtxoUpdatePsbtCmd returns a new instance which can be used to issue a
+// utxoupdatepsbt JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewUtxoUpdatePsbtCmd(psbt string, descriptors *[]ScanObject) *UtxoUpdatePsbtCmd {
+	return &UtxoUpdatePsbtCmd{
+		Psbt:        psbt,
+		Descriptors: descriptors,
+	}
+}
+
 // ValidateAddressCmd defines the validateaddress JSON-RPC command.
 type ValidateAddressCmd struct {
 	Address string
@@ -993,10 +1203,15 @@ func init() {
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("analyzepsbt", (*AnalyzePsbtCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("combinepsbt", (*CombinePsbtCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("decodepsbt", (*DecodePsbtCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
 	MustRegisterCmd("deriveaddresses", (*DeriveAddressesCmd)(nil), flags)
+	MustRegisterCmd("finalizepsbt", (*FinalizePsbtCmd)(nil), flags)
 	MustRegisterCmd("fundrawtransaction", (*FundRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
@@ -1034,16 +1249,20 @@ func init() {
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("scantxoutset", (*ScanTxOutSetCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
 	MustRegisterCmd("signmessagewithprivkey", (*SignMessageWithPrivKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
 	MustRegisterCmd("uptime", (*UptimeCmd)(nil), flags)
+	MustRegisterCmd("utxoupdatepsbt", (*UtxoUpdatePsbtCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
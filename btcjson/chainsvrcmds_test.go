@@ -42,6 +42,39 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "analyzepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("analyzepsbt", "cHNidP8=")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAnalyzePsbtCmd("cHNidP8=")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"analyzepsbt","params":["cHNidP8="],"id":1}`,
+			unmarshalled: &btcjson.AnalyzePsbtCmd{Psbt: "cHNidP8="},
+		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "combinepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("combinepsbt", []string{"cHNidP8=", "cHNidP8="})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCombinePsbtCmd([]string{"cHNidP8=", "cHNidP8="})
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"combinepsbt","params":[["cHNidP8=","cHNidP8="]],"id":1}`,
+			unmarshalled: &btcjson.CombinePsbtCmd{Txs: []string{"cHNidP8=", "cHNidP8="}},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -198,6 +231,17 @@ func TestChainSvrCmds(t *testing.T) {
 				}(),
 			},
 		},
+		{
+			name: "decodepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("decodepsbt", "cHNidP8=")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDecodePsbtCmd("cHNidP8=")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"decodepsbt","params":["cHNidP8="],"id":1}`,
+			unmarshalled: &btcjson.DecodePsbtCmd{Psbt: "cHNidP8="},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -265,6 +309,34 @@ func TestChainSvrCmds(t *testing.T) {
 				Range:      &btcjson.DescriptorRange{Value: []int{0, 2}},
 			},
 		},
+		{
+			name: "finalizepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("finalizepsbt", "cHNidP8=")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFinalizePsbtCmd("cHNidP8=", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"finalizepsbt","params":["cHNidP8="],"id":1}`,
+			unmarshalled: &btcjson.FinalizePsbtCmd{
+				Psbt:    "cHNidP8=",
+				Extract: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "finalizepsbt optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("finalizepsbt", "cHNidP8=", false)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFinalizePsbtCmd("cHNidP8=", btcjson.Bool(false))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"finalizepsbt","params":["cHNidP8=",false],"id":1}`,
+			unmarshalled: &btcjson.FinalizePsbtCmd{
+				Psbt:    "cHNidP8=",
+				Extract: btcjson.Bool(false),
+			},
+		},
 		{
 			name: "getaddednodeinfo",
 			newCmd: func() (interface{}, error) {
@@ -845,7 +917,8 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetRawMempoolCmd{
-				Verbose: btcjson.Bool(false),
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(false),
 			},
 		},
 		{
@@ -858,7 +931,25 @@ func TestChainSvrCmds(t *testing.T) {
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[false],"id":1}`,
 			unmarshalled: &btcjson.GetRawMempoolCmd{
-				Verbose: btcjson.Bool(false),
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getrawmempool mempool sequence",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrawmempool", false, true)
+			},
+			staticCmd: func() interface{} {
+				return &btcjson.GetRawMempoolCmd{
+					Verbose:         btcjson.Bool(false),
+					MempoolSequence: btcjson.Bool(true),
+				}
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[false,true],"id":1}`,
+			unmarshalled: &btcjson.GetRawMempoolCmd{
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(true),
 			},
 		},
 		{
@@ -1025,6 +1116,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -1062,6 +1164,51 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "scantxoutset status",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "status")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd(btcjson.STOStatus, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["status"],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action: btcjson.STOStatus,
+			},
+		},
+		{
+			name: "scantxoutset start",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "start",
+					`["addr(1BoatSLRHtKNngkdXEeobR76b53LETtpyT)",{"desc":"pkh(xpub/*)","range":[1,5]}]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd(btcjson.STOStart,
+					&[]btcjson.ScanObject{
+						{Descriptor: "addr(1BoatSLRHtKNngkdXEeobR76b53LETtpyT)"},
+						{
+							Descriptor: "pkh(xpub/*)",
+							Range: &btcjson.DescriptorRange{
+								Value: []int{1, 5},
+							},
+						},
+					})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["start",["addr(1BoatSLRHtKNngkdXEeobR76b53LETtpyT)",{"desc":"pkh(xpub/*)","range":[1,5]}]],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action: btcjson.STOStart,
+				ScanObjects: &[]btcjson.ScanObject{
+					{Descriptor: "addr(1BoatSLRHtKNngkdXEeobR76b53LETtpyT)"},
+					{
+						Descriptor: "pkh(xpub/*)",
+						Range: &btcjson.DescriptorRange{
+							Value: []int{1, 5},
+						},
+					},
+				},
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -1249,6 +1396,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "1.2.3.4", btcjson.SBAdd, 1700000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("1.2.3.4", btcjson.SBAdd,
+					btcjson.Int64(1700000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["1.2.3.4","add",1700000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "1.2.3.4",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1700000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -1346,6 +1526,44 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"uptime","params":[],"id":1}`,
 			unmarshalled: &btcjson.UptimeCmd{},
 		},
+		{
+			name: "utxoupdatepsbt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("utxoupdatepsbt", "cHNidP8=")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewUtxoUpdatePsbtCmd("cHNidP8=", nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"utxoupdatepsbt","params":["cHNidP8="],"id":1}`,
+			unmarshalled: &btcjson.UtxoUpdatePsbtCmd{Psbt: "cHNidP8="},
+		},
+		{
+			name: "utxoupdatepsbt optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("utxoupdatepsbt", "cHNidP8=",
+					`["addr(1Address)",{"desc":"pkh(xpub/*)","range":[0,2]}]`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewUtxoUpdatePsbtCmd("cHNidP8=", &[]btcjson.ScanObject{
+					{Descriptor: "addr(1Address)"},
+					{
+						Descriptor: "pkh(xpub/*)",
+						Range:      &btcjson.DescriptorRange{Value: []int{0, 2}},
+					},
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"utxoupdatepsbt","params":["cHNidP8=",["addr(1Address)",{"desc":"pkh(xpub/*)","range":[0,2]}]],"id":1}`,
+			unmarshalled: &btcjson.UtxoUpdatePsbtCmd{
+				Psbt: "cHNidP8=",
+				Descriptors: &[]btcjson.ScanObject{
+					{Descriptor: "addr(1Address)"},
+					{
+						Descriptor: "pkh(xpub/*)",
+						Range:      &btcjson.DescriptorRange{Value: []int{0, 2}},
+					},
+				},
+			},
+		},
 		{
 			name: "validateaddress",
 			newCmd: func() (interface{}, error) {
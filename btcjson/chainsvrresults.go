@@ -219,11 +219,15 @@ type GetBlockChainInfoResult struct {
 	Headers              int32   `json:"headers"`
 	BestBlockHash        string  `json:"bestblockhash"`
 	Difficulty           float64 `json:"difficulty"`
+	Time                 int64   `json:"time"`
 	MedianTime           int64   `json:"mediantime"`
-	VerificationProgress float64 `json:"verificationprogress,omitempty"`
+	VerificationProgress float64 `json:"verificationprogress"`
+	InitialBlockDownload bool    `json:"initialblockdownload"`
+	ChainWork            string  `json:"chainwork,omitempty"`
+	SizeOnDisk           int64   `json:"size_on_disk"`
 	Pruned               bool    `json:"pruned"`
 	PruneHeight          int32   `json:"pruneheight,omitempty"`
-	ChainWork            string  `json:"chainwork,omitempty"`
+	Warnings             string  `json:"warnings"`
 	*SoftForks
 	*UnifiedSoftForks
 }
@@ -329,8 +333,16 @@ type GetMempoolEntryResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Loaded              bool    `json:"loaded"`
+	Size                int64   `json:"size"`
+	Bytes               int64   `json:"bytes"`
+	Usage               int64   `json:"usage"`
+	TotalFee            float64 `json:"total_fee"`
+	MaxMempool          int64   `json:"maxmempool"`
+	MempoolMinFee       float64 `json:"mempoolminfee"`
+	MinRelayTxFee       float64 `json:"minrelaytxfee"`
+	IncrementalRelayFee float64 `json:"incrementalrelayfee"`
+	FullRBF             bool    `json:"fullrbf"`
 }
 
 // NetworksResult models the networks data from the getnetworkinfo command.
@@ -353,19 +365,22 @@ type LocalAddressesResult struct {
 // GetNetworkInfoResult models the data returned from the getnetworkinfo
 // command.
 type GetNetworkInfoResult struct {
-	Version         int32                  `json:"version"`
-	SubVersion      string                 `json:"subversion"`
-	ProtocolVersion int32                  `json:"protocolversion"`
-	LocalServices   string                 `json:"localservices"`
-	LocalRelay      bool                   `json:"localrelay"`
-	TimeOffset      int64                  `json:"timeoffset"`
-	Connections     int32                  `json:"connections"`
-	NetworkActive   bool                   `json:"networkactive"`
-	Networks        []NetworksResult       `json:"networks"`
-	RelayFee        float64                `json:"relayfee"`
-	IncrementalFee  float64                `json:"incrementalfee"`
-	LocalAddresses  []LocalAddressesResult `json:"localaddresses"`
-	Warnings        string                 `json:"warnings"`
+	Version            int32                  `json:"version"`
+	SubVersion         string                 `json:"subversion"`
+	ProtocolVersion    int32                  `json:"protocolversion"`
+	LocalServices      string                 `json:"localservices"`
+	LocalServicesNames []string               `json:"localservicesnames"`
+	LocalRelay         bool                   `json:"localrelay"`
+	TimeOffset         int64                  `json:"timeoffset"`
+	Connections        int32                  `json:"connections"`
+	ConnectionsIn      int32                  `json:"connections_in"`
+	ConnectionsOut     int32                  `json:"connections_out"`
+	NetworkActive      bool                   `json:"networkactive"`
+	Networks           []NetworksResult       `json:"networks"`
+	RelayFee           float64                `json:"relayfee"`
+	IncrementalFee     float64                `json:"incrementalfee"`
+	LocalAddresses     []LocalAddressesResult `json:"localaddresses"`
+	Warnings           string                 `json:"warnings"`
 }
 
 // GetNodeAddressesResult models the data returned from the getnodeaddresses
@@ -380,27 +395,37 @@ type GetNodeAddressesResult struct {
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
 type GetPeerInfoResult struct {
-	ID             int32   `json:"id"`
-	Addr           string  `json:"addr"`
-	AddrLocal      string  `json:"addrlocal,omitempty"`
-	Services       string  `json:"services"`
-	RelayTxes      bool    `json:"relaytxes"`
-	LastSend       int64   `json:"lastsend"`
-	LastRecv       int64   `json:"lastrecv"`
-	BytesSent      uint64  `json:"bytessent"`
-	BytesRecv      uint64  `json:"bytesrecv"`
-	ConnTime       int64   `json:"conntime"`
-	TimeOffset     int64   `json:"timeoffset"`
-	PingTime       float64 `json:"pingtime"`
-	PingWait       float64 `json:"pingwait,omitempty"`
-	Version        uint32  `json:"version"`
-	SubVer         string  `json:"subver"`
-	Inbound        bool    `json:"inbound"`
-	StartingHeight int32   `json:"startingheight"`
-	CurrentHeight  int32   `json:"currentheight,omitempty"`
-	BanScore       int32   `json:"banscore"`
-	FeeFilter      int64   `json:"feefilter"`
-	SyncNode       bool    `json:"syncnode"`
+	ID              int32             `json:"id"`
+	Addr            string            `json:"addr"`
+	AddrLocal       string            `json:"addrlocal,omitempty"`
+	Services        string            `json:"services"`
+	RelayTxes       bool              `json:"relaytxes"`
+	LastSend        int64             `json:"lastsend"`
+	LastRecv        int64             `json:"lastrecv"`
+	BytesSent       uint64            `json:"bytessent"`
+	BytesRecv       uint64            `json:"bytesrecv"`
+	ConnTime        int64             `json:"conntime"`
+	TimeOffset      int64             `json:"timeoffset"`
+	PingTime        float64           `json:"pingtime"`
+	PingWait        float64           `json:"pingwait,omitempty"`
+	Version         uint32            `json:"version"`
+	SubVer          string            `json:"subver"`
+	Inbound         bool              `json:"inbound"`
+	ConnectionType  string            `json:"connection_type"`
+	StartingHeight  int32             `json:"startingheight"`
+	CurrentHeight   int32             `json:"currentheight,omitempty"`
+	BanScore        int32             `json:"banscore"`
+	FeeFilter       int64             `json:"feefilter"`
+	SyncNode        bool              `json:"syncnode"`
+	InFlight        []int32           `json:"inflight"`
+	BlocksReceived  uint64            `json:"blocksreceived"`
+	HeadersReceived uint64            `json:"headersreceived"`
+	BlockRate       float64           `json:"blockrate"`
+	HeaderRate      float64           `json:"headerrate"`
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+	MsgsSentPerMsg  map[string]uint64 `json:"msgssent_per_msg"`
+	MsgsRecvPerMsg  map[string]uint64 `json:"msgsrecv_per_msg"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -418,6 +443,13 @@ type GetRawMempoolVerboseResult struct {
 	Depends          []string `json:"depends"`
 }
 
+// GetRawMempoolSequenceResult models the data returned from the getrawmempool
+// command when the mempool sequence flag is set.
+type GetRawMempoolSequenceResult struct {
+	TxIDs           []string `json:"txids"`
+	MempoolSequence uint64   `json:"mempool_sequence"`
+}
+
 // ScriptPubKeyResult models the scriptPubKey data of a tx script.  It is
 // defined separately since it is used by multiple commands.
 type ScriptPubKeyResult struct {
@@ -495,11 +527,66 @@ func (g *GetTxOutSetInfoResult) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ScanTxOutSetUnspent models a single unspent transaction output found by the
+// scantxoutset command.
+type ScanTxOutSetUnspent struct {
+	TxID         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Descriptor   string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Coinbase     bool    `json:"coinbase"`
+	Height       int32   `json:"height"`
+}
+
+// ScanTxOutSetResult models the data from the scantxoutset command when a scan
+// is started.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	TxOuts      int64                 `json:"txouts"`
+	Height      int32                 `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// ScanTxOutSetStatusResult models the data from the scantxoutset command when
+// the status of the scan in progress is requested.
+type ScanTxOutSetStatusResult struct {
+	Progress int32 `json:"progress"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv  uint64              `json:"totalbytesrecv"`
+	TotalBytesSent  uint64              `json:"totalbytessent"`
+	TimeMillis      int64               `json:"timemillis"`
+	UploadTarget    *UploadTargetResult `json:"uploadtarget"`
+	BytesSentPerMsg map[string]uint64   `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64   `json:"bytesrecv_per_msg"`
+	MsgsSentPerMsg  map[string]uint64   `json:"msgssent_per_msg"`
+	MsgsRecvPerMsg  map[string]uint64   `json:"msgsrecv_per_msg"`
+}
+
+// UploadTargetResult models the upload target data returned as part of the
+// getnettotals command.
+type UploadTargetResult struct {
+	TimeFrame             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+}
+
+// ListBannedResult models the data returned for each banned subnet from the
+// listbanned command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -829,6 +916,89 @@ type GetDescriptorInfoResult struct {
 // DeriveAddressesResult models the data from the deriveaddresses command.
 type DeriveAddressesResult []string
 
+// PsbtWitnessUtxo models the witness utxo of an input returned by the
+// decodepsbt command.
+type PsbtWitnessUtxo struct {
+	Amount       float64            `json:"amount"`
+	ScriptPubKey ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// PsbtBip32Deriv models a BIP0032 derivation of a public key returned by the
+// decodepsbt command.
+type PsbtBip32Deriv struct {
+	PubKey            string `json:"pubkey"`
+	MasterFingerprint string `json:"master_fingerprint"`
+	Path              string `json:"path"`
+}
+
+// DecodePsbtInput models the data of an input returned by the decodepsbt
+// command.
+type DecodePsbtInput struct {
+	NonWitnessUtxo     *TxRawDecodeResult  `json:"non_witness_utxo,omitempty"`
+	WitnessUtxo        *PsbtWitnessUtxo    `json:"witness_utxo,omitempty"`
+	PartialSignatures  map[string]string   `json:"partial_signatures,omitempty"`
+	Sighash            string              `json:"sighash,omitempty"`
+	RedeemScript       *ScriptPubKeyResult `json:"redeem_script,omitempty"`
+	WitnessScript      *ScriptPubKeyResult `json:"witness_script,omitempty"`
+	Bip32Derivs        []PsbtBip32Deriv    `json:"bip32_derivs,omitempty"`
+	FinalScriptSig     *ScriptSig          `json:"final_scriptsig,omitempty"`
+	FinalScriptWitness []string            `json:"final_scriptwitness,omitempty"`
+	Unknown            map[string]string   `json:"unknown,omitempty"`
+}
+
+// DecodePsbtOutput models the data of an output returned by the decodepsbt
+// command.
+type DecodePsbtOutput struct {
+	RedeemScript  *ScriptPubKeyResult `json:"redeem_script,omitempty"`
+	WitnessScript *ScriptPubKeyResult `json:"witness_script,omitempty"`
+	Bip32Derivs   []PsbtBip32Deriv    `json:"bip32_derivs,omitempty"`
+	Unknown       map[string]string   `json:"unknown,omitempty"`
+}
+
+// DecodePsbtResult models the data from the decodepsbt command.
+type DecodePsbtResult struct {
+	Tx      TxRawDecodeResult  `json:"tx"`
+	Unknown map[string]string  `json:"unknown"`
+	Inputs  []DecodePsbtInput  `json:"inputs"`
+	Outputs []DecodePsbtOutput `json:"outputs"`
+	Fee     *float64           `json:"fee,omitempty"` // only when the outputs spent by all inputs are known
+}
+
+// AnalyzePsbtMissing models the information an input is missing returned by
+// the analyzepsbt command.
+type AnalyzePsbtMissing struct {
+	PubKeys       []string `json:"pubkeys,omitempty"`       // hash160 of the missing public keys
+	Signatures    []string `json:"signatures,omitempty"`    // hash160 of the public keys of the missing signatures
+	RedeemScript  string   `json:"redeemscript,omitempty"`  // hash160 of the missing redeem script
+	WitnessScript string   `json:"witnessscript,omitempty"` // sha256 of the missing witness script
+}
+
+// AnalyzePsbtInput models the data of an input returned by the analyzepsbt
+// command.
+type AnalyzePsbtInput struct {
+	HasUtxo bool                `json:"has_utxo"`
+	IsFinal bool                `json:"is_final"`
+	Missing *AnalyzePsbtMissing `json:"missing,omitempty"`
+	Next    string              `json:"next"`
+}
+
+// AnalyzePsbtResult models the data from the analyzepsbt command.
+type AnalyzePsbtResult struct {
+	Inputs           []AnalyzePsbtInput `json:"inputs,omitempty"`
+	EstimatedVSize   *int64             `json:"estimated_vsize,omitempty"`
+	EstimatedFeeRate *float64           `json:"estimated_feerate,omitempty"` // in BTC/kB
+	Fee              *float64           `json:"fee,omitempty"`
+	Next             string             `json:"next"`
+	Error            string             `json:"error,omitempty"`
+}
+
+// FinalizePsbtResult models the data from the finalizepsbt command.
+type FinalizePsbtResult struct {
+	Psbt     string `json:"psbt,omitempty"` // only when the transaction is not extracted
+	Hex      string `json:"hex,omitempty"`  // only when the transaction is extracted
+	Complete bool   `json:"complete"`
+}
+
 // LoadWalletResult models the data from the loadwallet command
 type LoadWalletResult struct {
 	Name    string `json:"name"`
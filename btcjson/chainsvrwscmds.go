@@ -57,6 +57,25 @@ func NewNotifyNewTransactionsCmd(verbose *bool) *NotifyNewTransactionsCmd {
 	}
 }
 
+// NotifyMempoolSequenceCmd defines the notifymempoolsequence JSON-RPC command.
+type NotifyMempoolSequenceCmd struct{}
+
+// NewNotifyMempoolSequenceCmd returns a new instance which can be used to issue
+// a notifymempoolsequence JSON-RPC command.
+func NewNotifyMempoolSequenceCmd() *NotifyMempoolSequenceCmd {
+	return &NotifyMempoolSequenceCmd{}
+}
+
+// StopNotifyMempoolSequenceCmd defines the stopnotifymempoolsequence JSON-RPC
+// command.
+type StopNotifyMempoolSequenceCmd struct{}
+
+// NewStopNotifyMempoolSequenceCmd returns a new instance which can be used to
+// issue a stopnotifymempoolsequence JSON-RPC command.
+func NewStopNotifyMempoolSequenceCmd() *StopNotifyMempoolSequenceCmd {
+	return &StopNotifyMempoolSequenceCmd{}
+}
+
 // SessionCmd defines the session JSON-RPC command.
 type SessionCmd struct{}
 
@@ -228,11 +247,13 @@ func init() {
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifymempoolsequence", (*NotifyMempoolSequenceCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifymempoolsequence", (*StopNotifyMempoolSequenceCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
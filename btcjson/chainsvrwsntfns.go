@@ -75,6 +75,31 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// MempoolSequenceNtfnMethod is the method used for notifications from
+	// the chain server about transactions being added to and removed from
+	// the mempool as well as blocks being connected and disconnected, in
+	// the order they happened.
+	MempoolSequenceNtfnMethod = "mempoolsequence"
+)
+
+// Event types of mempoolsequence notifications.
+const (
+	// MempoolSequenceTxAdded indicates a transaction was added to the
+	// mempool.
+	MempoolSequenceTxAdded = "added"
+
+	// MempoolSequenceTxRemoved indicates a transaction was removed from the
+	// mempool.  The reason it was removed is included in the event.
+	MempoolSequenceTxRemoved = "removed"
+
+	// MempoolSequenceBlockConnected indicates a block was connected to the
+	// main chain.
+	MempoolSequenceBlockConnected = "blockconnected"
+
+	// MempoolSequenceBlockDisconnected indicates a block was disconnected
+	// from the main chain.
+	MempoolSequenceBlockDisconnected = "blockdisconnected"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +310,33 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// MempoolSequenceEvent describes an event in a mempoolsequence notification.
+// The hash is the transaction hash for transaction events and the block hash
+// for block events.  The mempool sequence is incremented for each transaction
+// that is added to or removed from the mempool, so clients can detect gaps.
+// Block events carry the mempool sequence at the time the block was connected
+// or disconnected.
+type MempoolSequenceEvent struct {
+	Type            string `json:"type"`
+	Hash            string `json:"hash"`
+	Height          int32  `json:"height,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	MempoolSequence uint64 `json:"mempoolsequence"`
+}
+
+// MempoolSequenceNtfn defines the mempoolsequence JSON-RPC notification.
+type MempoolSequenceNtfn struct {
+	Event MempoolSequenceEvent
+}
+
+// NewMempoolSequenceNtfn returns a new instance which can be used to issue a
+// mempoolsequence JSON-RPC notification.
+func NewMempoolSequenceNtfn(event MempoolSequenceEvent) *MempoolSequenceNtfn {
+	return &MempoolSequenceNtfn{
+		Event: event,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +353,5 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(MempoolSequenceNtfnMethod, (*MempoolSequenceNtfn)(nil), flags)
 }
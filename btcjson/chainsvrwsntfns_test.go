@@ -225,6 +225,29 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "mempoolsequence",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("mempoolsequence", `{"type":"removed","hash":"123","reason":"conflict","mempoolsequence":7}`)
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewMempoolSequenceNtfn(btcjson.MempoolSequenceEvent{
+					Type:            btcjson.MempoolSequenceTxRemoved,
+					Hash:            "123",
+					Reason:          "conflict",
+					MempoolSequence: 7,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"mempoolsequence","params":[{"type":"removed","hash":"123","reason":"conflict","mempoolsequence":7}],"id":null}`,
+			unmarshalled: &btcjson.MempoolSequenceNtfn{
+				Event: btcjson.MempoolSequenceEvent{
+					Type:            btcjson.MempoolSequenceTxRemoved,
+					Hash:            "123",
+					Reason:          "conflict",
+					MempoolSequence: 7,
+				},
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
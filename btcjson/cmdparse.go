@@ -5,9 +5,11 @@
 package btcjson
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -105,9 +107,91 @@ func populateDefaults(numParams int, info *methodInfo, rv reflect.Value) {
 	}
 }
 
+// unmarshalParam unmarshals the passed parameter into the struct field with the
+// passed index.
+func unmarshalParam(rv reflect.Value, i int, param json.RawMessage) error {
+	rvf := rv.Field(i)
+	concreteVal := rvf.Addr().Interface()
+	if err := json.Unmarshal(param, &concreteVal); err != nil {
+		// The most common error is the wrong type, so
+		// explicitly detect that error and make it nicer.
+		fieldName := strings.ToLower(rv.Type().Field(i).Name)
+		if jerr, ok := err.(*json.UnmarshalTypeError); ok {
+			str := fmt.Sprintf("parameter #%d '%s' must "+
+				"be type %v (got %v)", i+1, fieldName,
+				jerr.Type, jerr.Value)
+			return makeError(ErrInvalidType, str)
+		}
+
+		// Fallback to showing the underlying error.
+		str := fmt.Sprintf("parameter #%d '%s' failed to "+
+			"unmarshal: %v", i+1, fieldName, err)
+		return makeError(ErrInvalidType, str)
+	}
+
+	return nil
+}
+
+// unmarshalNamedParams unmarshals the passed named parameters into the struct
+// fields of the same name.  The name of a parameter is the lowercase name of
+// its field, which is also the name used when generating help.  Optional
+// fields which are not provided, or which are provided as null, are populated
+// with their associated default value as needed.
+func unmarshalNamedParams(params map[string]json.RawMessage, info *methodInfo, rv reflect.Value) error {
+	rt := rv.Type()
+	fieldIndexes := make(map[string]int, info.maxParams)
+	for i := 0; i < info.maxParams; i++ {
+		fieldIndexes[strings.ToLower(rt.Field(i).Name)] = i
+	}
+
+	// Unmarshal the parameters in order of their names so any error is
+	// deterministic.
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	provided := make([]bool, info.maxParams)
+	for _, name := range names {
+		i, ok := fieldIndexes[name]
+		if !ok {
+			str := fmt.Sprintf("unknown parameter '%s'", name)
+			return makeError(ErrUnknownParam, str)
+		}
+		param := params[name]
+		if i >= info.numReqParams &&
+			bytes.Equal(bytes.TrimSpace(param), []byte("null")) {
+
+			continue
+		}
+		if err := unmarshalParam(rv, i, param); err != nil {
+			return err
+		}
+		provided[i] = true
+	}
+
+	for i := 0; i < info.maxParams; i++ {
+		if provided[i] {
+			continue
+		}
+		if i < info.numReqParams {
+			str := fmt.Sprintf("missing required parameter #%d '%s'",
+				i+1, strings.ToLower(rt.Field(i).Name))
+			return makeError(ErrNumParams, str)
+		}
+		if defaultVal, ok := info.defaults[i]; ok {
+			rv.Field(i).Set(defaultVal)
+		}
+	}
+
+	return nil
+}
+
 // UnmarshalCmd unmarshals a JSON-RPC request into a suitable concrete command
 // so long as the method type contained within the marshalled request is
-// registered.
+// registered.  The parameters of the request may either be positional or
+// named.
 func UnmarshalCmd(r *Request) (interface{}, error) {
 	registerLock.RLock()
 	rtp, ok := methodToConcreteType[r.Method]
@@ -121,6 +205,16 @@ func UnmarshalCmd(r *Request) (interface{}, error) {
 	rvp := reflect.New(rt)
 	rv := rvp.Elem()
 
+	// Named parameters are matched to the struct fields by name rather than
+	// by position.
+	if r.NamedParams != nil {
+		err := unmarshalNamedParams(r.NamedParams, &info, rv)
+		if err != nil {
+			return nil, err
+		}
+		return rvp.Interface(), nil
+	}
+
 	// Ensure the number of parameters are correct.
 	numParams := len(r.Params)
 	if err := checkNumParams(numParams, &info); err != nil {
@@ -130,24 +224,9 @@ func UnmarshalCmd(r *Request) (interface{}, error) {
 	// Loop through each of the struct fields and unmarshal the associated
 	// parameter into them.
 	for i := 0; i < numParams; i++ {
-		rvf := rv.Field(i)
 		// Unmarshal the parameter into the struct field.
-		concreteVal := rvf.Addr().Interface()
-		if err := json.Unmarshal(r.Params[i], &concreteVal); err != nil {
-			// The most common error is the wrong type, so
-			// explicitly detect that error and make it nicer.
-			fieldName := strings.ToLower(rt.Field(i).Name)
-			if jerr, ok := err.(*json.UnmarshalTypeError); ok {
-				str := fmt.Sprintf("parameter #%d '%s' must "+
-					"be type %v (got %v)", i+1, fieldName,
-					jerr.Type, jerr.Value)
-				return nil, makeError(ErrInvalidType, str)
-			}
-
-			// Fallback to showing the underlying error.
-			str := fmt.Sprintf("parameter #%d '%s' failed to "+
-				"unmarshal: %v", i+1, fieldName, err)
-			return nil, makeError(ErrInvalidType, str)
+		if err := unmarshalParam(rv, i, r.Params[i]); err != nil {
+			return nil, err
 		}
 	}
 
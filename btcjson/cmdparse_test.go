@@ -6,11 +6,13 @@ package btcjson_test
 
 import (
 	"encoding/json"
+	"fmt"
 	"math"
 	"reflect"
 	"testing"
 
 	"github.com/btcsuite/btcd/btcjson"
+	"github.com/davecgh/go-spew/spew"
 )
 
 // TestAssignField tests the assignField function handles supported combinations
@@ -572,6 +574,43 @@ func TestUnmarshalCmdErrors(t *testing.T) {
 			},
 			err: btcjson.Error{ErrorCode: btcjson.ErrInvalidType},
 		},
+		{
+			name: "unknown named parameter",
+			request: btcjson.Request{
+				Jsonrpc: "2.0",
+				Method:  "getblock",
+				NamedParams: map[string]json.RawMessage{
+					"hash":      []byte(`"123"`),
+					"blockhash": []byte(`"123"`),
+				},
+				ID: nil,
+			},
+			err: btcjson.Error{ErrorCode: btcjson.ErrUnknownParam},
+		},
+		{
+			name: "missing required named parameter",
+			request: btcjson.Request{
+				Jsonrpc: "2.0",
+				Method:  "getblock",
+				NamedParams: map[string]json.RawMessage{
+					"verbosity": []byte("0"),
+				},
+				ID: nil,
+			},
+			err: btcjson.Error{ErrorCode: btcjson.ErrNumParams},
+		},
+		{
+			name: "invalid type for a named parameter",
+			request: btcjson.Request{
+				Jsonrpc: "2.0",
+				Method:  "getblock",
+				NamedParams: map[string]json.RawMessage{
+					"hash": []byte("1"),
+				},
+				ID: nil,
+			},
+			err: btcjson.Error{ErrorCode: btcjson.ErrInvalidType},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -591,3 +630,82 @@ func TestUnmarshalCmdErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestUnmarshalCmdNamedParams ensures requests with named parameters are
+// unmarshalled into the expected concrete commands.
+func TestUnmarshalCmdNamedParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		method string
+		params string
+		cmd    interface{}
+	}{
+		{
+			name:   "required parameter only",
+			method: "getblock",
+			params: `{"hash":"123"}`,
+			cmd: &btcjson.GetBlockCmd{
+				Hash:      "123",
+				Verbosity: btcjson.Int(1),
+			},
+		},
+		{
+			name:   "optional parameter before required parameter",
+			method: "getblock",
+			params: `{"verbosity":0,"hash":"123"}`,
+			cmd: &btcjson.GetBlockCmd{
+				Hash:      "123",
+				Verbosity: btcjson.Int(0),
+			},
+		},
+		{
+			name:   "null optional parameter",
+			method: "getblock",
+			params: `{"hash":"123","verbosity":null}`,
+			cmd: &btcjson.GetBlockCmd{
+				Hash:      "123",
+				Verbosity: btcjson.Int(1),
+			},
+		},
+		{
+			name:   "no parameters",
+			method: "getblockcount",
+			params: `{}`,
+			cmd:    &btcjson.GetBlockCountCmd{},
+		},
+		{
+			name:   "skipped optional parameter",
+			method: "getnetworkhashps",
+			params: `{"height":100}`,
+			cmd: &btcjson.GetNetworkHashPSCmd{
+				Blocks: btcjson.Int(120),
+				Height: btcjson.Int(100),
+			},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		marshalled := fmt.Sprintf(`{"jsonrpc":"2.0","method":%q,`+
+			`"params":%s,"id":1}`, test.method, test.params)
+		var request btcjson.Request
+		if err := json.Unmarshal([]byte(marshalled), &request); err != nil {
+			t.Errorf("Test #%d (%s) unexpected unmarshal error: %v",
+				i, test.name, err)
+			continue
+		}
+		cmd, err := btcjson.UnmarshalCmd(&request)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(cmd, test.cmd) {
+			t.Errorf("Test #%d (%s) mismatched command - got %s, "+
+				"want %s", i, test.name, spew.Sdump(cmd),
+				spew.Sdump(test.cmd))
+		}
+	}
+}
@@ -42,6 +42,21 @@ object, except they have the id field set to null.  Therefore, servers will
 ignore requests with the id field set to null, while clients can choose to
 consume or ignore them.
 
+JSON-RPC 2.0 additionally permits the params field to be an object of named
+parameters rather than an array of positional parameters, and JSON-RPC 2.0
+responses only contain the error field on failure and the result field
+otherwise:
+
+  - Request Objects
+    {"jsonrpc":"2.0","id":"SOMEID","method":"SOMEMETHOD","params":{"NAME":SOMEPARAM}}
+
+  - Response Objects
+    {"jsonrpc":"2.0","result":SOMETHING,"id":"SOMEID"}
+    {"jsonrpc":"2.0","error":{"code":SOMEINT,"message":SOMESTRING},"id":"SOMEID"}
+
+The name of a parameter is the lowercase name of the associated command struct
+field, which is the same name that is used when generating help.
+
 Unfortunately, the original Bitcoin JSON-RPC API (and hence anything compatible
 with it) doesn't always follow the spec and will sometimes return an error
 string in the result field with a null error for certain commands.  However,
@@ -58,8 +73,8 @@ package map into the required parts of the protocol
   - Response Objects (type Response)
     - Result (type <Foo>Result)
 
-To simplify the marshalling of the requests and responses, the MarshalCmd and
-MarshalResponse functions are provided.  They return the raw bytes ready to be
+To simplify the marshalling of the requests and responses, the MarshalCmd,
+MarshalResponse, and MarshalVersionedResponse functions are provided.  They return the raw bytes ready to be
 sent across the wire.
 
 Unmarshalling a received Request object is a two step process:
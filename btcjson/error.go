@@ -62,6 +62,10 @@ const (
 	// match the requirements of the associated command.
 	ErrNumParams
 
+	// ErrUnknownParam indicates a named parameter was supplied that does
+	// not match any parameter of the associated command.
+	ErrUnknownParam
+
 	// numErrorCodes is the maximum error code number used in tests.
 	numErrorCodes
 )
@@ -80,6 +84,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrUnregisteredMethod:   "ErrUnregisteredMethod",
 	ErrMissingDescription:   "ErrMissingDescription",
 	ErrNumParams:            "ErrNumParams",
+	ErrUnknownParam:         "ErrUnknownParam",
 }
 
 // String returns the ErrorCode as a human-readable name.
@@ -29,6 +29,7 @@ func TestErrorCodeStringer(t *testing.T) {
 		{btcjson.ErrMismatchedDefault, "ErrMismatchedDefault"},
 		{btcjson.ErrUnregisteredMethod, "ErrUnregisteredMethod"},
 		{btcjson.ErrNumParams, "ErrNumParams"},
+		{btcjson.ErrUnknownParam, "ErrUnknownParam"},
 		{btcjson.ErrMissingDescription, "ErrMissingDescription"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
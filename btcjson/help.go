@@ -78,8 +78,9 @@ func reflectTypeToJSONType(xT descLookupFunc, rt reflect.Type) string {
 // a tabwriter can be used later to line everything up.  The descriptions are
 // pulled from the active help descriptions map based on the lowercase version
 // of the provided reflect type and json name (or the lowercase version of the
-// field name if no json tag was specified).
-func resultStructHelp(xT descLookupFunc, rt reflect.Type, indentLevel int) []string {
+// field name if no json tag was specified).  The passed types are the structs
+// that are already being described further up, if any.
+func resultStructHelp(xT descLookupFunc, rt reflect.Type, indentLevel int, visiting map[reflect.Type]struct{}) []string {
 	indent := strings.Repeat(" ", indentLevel)
 	typeName := strings.ToLower(rt.Name())
 
@@ -110,7 +111,7 @@ func resultStructHelp(xT descLookupFunc, rt reflect.Type, indentLevel int) []str
 		fieldType := reflectTypeToJSONType(xT, rtfType)
 		fieldDescKey := typeName + "-" + fieldName
 		fieldExamples, isComplex := reflectTypeToJSONExample(xT,
-			rtfType, indentLevel, fieldDescKey)
+			rtfType, indentLevel, fieldDescKey, visiting)
 		if isComplex {
 			var brace string
 			kind := rtfType.Kind()
@@ -139,8 +140,10 @@ func resultStructHelp(xT descLookupFunc, rt reflect.Type, indentLevel int) []str
 // is returned as a slice of lines so the final help can be nicely aligned via
 // a tab writer.  A bool is also returned which specifies whether or not the
 // type results in a complex JSON object since they need to be handled
-// differently.
-func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel int, fieldDescKey string) ([]string, bool) {
+// differently.  Structs which are already being described further up, as
+// tracked by the passed types, are not described again to avoid infinite
+// recursion.
+func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel int, fieldDescKey string, visiting map[reflect.Type]struct{}) ([]string, bool) {
 	// Indirect pointer if needed.
 	if rt.Kind() == reflect.Ptr {
 		rt = rt.Elem()
@@ -162,8 +165,16 @@ func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel in
 		return []string{xT("json-example-bool")}, false
 
 	case reflect.Struct:
+		if _, ok := visiting[rt]; ok {
+			return []string{"{...}"}, false
+		}
+		if visiting == nil {
+			visiting = make(map[reflect.Type]struct{})
+		}
+		visiting[rt] = struct{}{}
 		indent := strings.Repeat(" ", indentLevel)
-		results := resultStructHelp(xT, rt, indentLevel+1)
+		results := resultStructHelp(xT, rt, indentLevel+1, visiting)
+		delete(visiting, rt)
 
 		// An opening brace is needed for the first indent level.  For
 		// all others, it will be included as a part of the previous
@@ -187,7 +198,7 @@ func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel in
 
 	case reflect.Array, reflect.Slice:
 		results, isComplex := reflectTypeToJSONExample(xT, rt.Elem(),
-			indentLevel, fieldDescKey)
+			indentLevel, fieldDescKey, visiting)
 
 		// When the result is complex, it is because this is an array of
 		// objects.
@@ -251,7 +262,8 @@ func reflectTypeToJSONExample(xT descLookupFunc, rt reflect.Type, indentLevel in
 // type.
 func resultTypeHelp(xT descLookupFunc, rt reflect.Type, fieldDescKey string) string {
 	// Generate the JSON example for the result type.
-	results, isComplex := reflectTypeToJSONExample(xT, rt, 0, fieldDescKey)
+	results, isComplex := reflectTypeToJSONExample(xT, rt, 0, fieldDescKey,
+		nil)
 
 	// When this is a primitive type, add the associated JSON type and
 	// result description into the final string, format it accordingly,
@@ -457,6 +469,50 @@ func isValidResultType(kind reflect.Kind) bool {
 	return false
 }
 
+// checkResultTypes returns an error unless each of the passed result types is
+// a pointer to a supported type (or nil).
+func checkResultTypes(resultTypes []interface{}) error {
+	for i, resultType := range resultTypes {
+		if resultType == nil {
+			continue
+		}
+
+		rtp := reflect.TypeOf(resultType)
+		if rtp.Kind() != reflect.Ptr {
+			str := fmt.Sprintf("result #%d (%v) is not a pointer",
+				i, rtp.Kind())
+			return makeError(ErrInvalidType, str)
+		}
+
+		elemKind := rtp.Elem().Kind()
+		if !isValidResultType(elemKind) {
+			str := fmt.Sprintf("result #%d (%v) is not an allowed "+
+				"type", i, elemKind)
+			return makeError(ErrInvalidType, str)
+		}
+	}
+
+	return nil
+}
+
+// helpDescLookup returns a description lookup function for the passed
+// descriptions map which falls back to the base help descriptions map for
+// unrecognized keys and tracks any missing keys by setting the passed missing
+// key to the last key that could not be found.
+func helpDescLookup(descs map[string]string, missingKey *string) descLookupFunc {
+	return func(key string) string {
+		if desc, ok := descs[key]; ok {
+			return desc
+		}
+		if desc, ok := baseHelpDescs[key]; ok {
+			return desc
+		}
+
+		*missingKey = key
+		return key
+	}
+}
+
 // GenerateHelp generates and returns help output for the provided method and
 // result types given a map to provide the appropriate keys for the method
 // synopsis, field descriptions, conditions, and result descriptions.  The
@@ -514,42 +570,12 @@ func GenerateHelp(method string, descs map[string]string, resultTypes ...interfa
 		return "", makeError(ErrUnregisteredMethod, str)
 	}
 
-	// Validate each result type is a pointer to a supported type (or nil).
-	for i, resultType := range resultTypes {
-		if resultType == nil {
-			continue
-		}
-
-		rtp := reflect.TypeOf(resultType)
-		if rtp.Kind() != reflect.Ptr {
-			str := fmt.Sprintf("result #%d (%v) is not a pointer",
-				i, rtp.Kind())
-			return "", makeError(ErrInvalidType, str)
-		}
-
-		elemKind := rtp.Elem().Kind()
-		if !isValidResultType(elemKind) {
-			str := fmt.Sprintf("result #%d (%v) is not an allowed "+
-				"type", i, elemKind)
-			return "", makeError(ErrInvalidType, str)
-		}
+	if err := checkResultTypes(resultTypes); err != nil {
+		return "", err
 	}
 
-	// Create a closure for the description lookup function which falls back
-	// to the base help descriptions map for unrecognized keys and tracks
-	// and missing keys.
 	var missingKey string
-	xT := func(key string) string {
-		if desc, ok := descs[key]; ok {
-			return desc
-		}
-		if desc, ok := baseHelpDescs[key]; ok {
-			return desc
-		}
-
-		missingKey = key
-		return key
-	}
+	xT := helpDescLookup(descs, &missingKey)
 
 	// Generate and return the help for the method.
 	help := methodHelp(xT, rtp, info.defaults, method, resultTypes)
@@ -246,7 +246,7 @@ func TestHelpReflectInternals(t *testing.T) {
 
 		// Ensure the generated example is as expected.
 		examples, isComplex := btcjson.TstReflectTypeToJSONExample(xT,
-			test.reflectType, test.indentLevel, "fdk")
+			test.reflectType, test.indentLevel, "fdk", nil)
 		if isComplex != test.isComplex {
 			t.Errorf("Test #%d (%s) unexpected isComplex - got: %v, "+
 				"want: %v", i, test.name, isComplex,
@@ -394,6 +394,24 @@ func TestResultStructHelp(t *testing.T) {
 				"},...]",
 			},
 		},
+		{
+			name: "recursive struct",
+			reflectType: func() reflect.Type {
+				type s struct {
+					field int
+					sub   *s
+				}
+				return reflect.TypeOf(s{})
+			}(),
+			expected: []string{
+				"\"field\": n,\t(json-type-numeric)\ts-field",
+				"\"sub\": {\t(json-type-object)\ts-sub",
+				"{",
+				" \"field\": n,\t(json-type-numeric)\ts-field",
+				" \"sub\": {...},\t(json-type-object)\ts-sub",
+				"}\t\t",
+			},
+		},
 	}
 
 	xT := func(key string) string {
@@ -402,7 +420,8 @@ func TestResultStructHelp(t *testing.T) {
 
 	t.Logf("Running %d tests", len(tests))
 	for i, test := range tests {
-		results := btcjson.TstResultStructHelp(xT, test.reflectType, 0)
+		results := btcjson.TstResultStructHelp(xT, test.reflectType, 0,
+			nil)
 		if len(results) != len(test.expected) {
 			t.Errorf("Test #%d (%s) unexpected result length - "+
 				"got: %v, want: %v", i, test.name, len(results),
@@ -5,10 +5,15 @@
 package btcjson
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 )
 
+// JSONRPCVersion2 is the value of the jsonrpc member of JSON-RPC 2.0 requests
+// and responses.
+const JSONRPCVersion2 = "2.0"
+
 // RPCErrorCode represents an error code to be used as a part of an RPCError
 // which is in turn used in a JSON-RPC Response object.
 //
@@ -66,11 +71,68 @@ func IsValidIDType(id interface{}) bool {
 // statically typed command infrastructure which handles creation of these
 // requests, however this struct it being exported in case the caller wants to
 // construct raw requests for some reason.
+//
+// The parameters are either positional, in which case they are stored in the
+// Params field, or named as permitted by JSON-RPC 2.0, in which case they are
+// stored in the NamedParams field keyed by parameter name.
 type Request struct {
-	Jsonrpc string            `json:"jsonrpc"`
-	Method  string            `json:"method"`
-	Params  []json.RawMessage `json:"params"`
-	ID      interface{}       `json:"id"`
+	Jsonrpc     string                     `json:"jsonrpc"`
+	Method      string                     `json:"method"`
+	Params      []json.RawMessage          `json:"params"`
+	NamedParams map[string]json.RawMessage `json:"-"`
+	ID          interface{}                `json:"id"`
+}
+
+// MarshalJSON marshals the request as a JSON-RPC request object.  The params
+// member is an object when the request has named parameters and an array
+// otherwise.
+//
+// This is part of the json.Marshaler interface.
+func (r Request) MarshalJSON() ([]byte, error) {
+	// The request type is redefined to avoid recursing into this method.
+	type request Request
+	if r.NamedParams == nil {
+		return json.Marshal(request(r))
+	}
+
+	return json.Marshal(&struct {
+		Jsonrpc string                     `json:"jsonrpc"`
+		Method  string                     `json:"method"`
+		Params  map[string]json.RawMessage `json:"params"`
+		ID      interface{}                `json:"id"`
+	}{r.Jsonrpc, r.Method, r.NamedParams, r.ID})
+}
+
+// UnmarshalJSON unmarshals a JSON-RPC request object.  The params member may
+// be an array of positional parameters, an object of named parameters, or
+// omitted entirely.
+//
+// This is part of the json.Unmarshaler interface.
+func (r *Request) UnmarshalJSON(b []byte) error {
+	var request struct {
+		Jsonrpc string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+		ID      interface{}     `json:"id"`
+	}
+	if err := json.Unmarshal(b, &request); err != nil {
+		return err
+	}
+
+	*r = Request{
+		Jsonrpc: request.Jsonrpc,
+		Method:  request.Method,
+		ID:      request.ID,
+	}
+	params := bytes.TrimSpace(request.Params)
+	switch {
+	case len(params) == 0 || bytes.Equal(params, []byte("null")):
+		return nil
+	case params[0] == '{':
+		return json.Unmarshal(params, &r.NamedParams)
+	default:
+		return json.Unmarshal(params, &r.Params)
+	}
 }
 
 // NewRequest returns a new JSON-RPC 1.0 request object given the provided id,
@@ -109,10 +171,43 @@ func NewRequest(id interface{}, method string, params []interface{}) (*Request,
 // field varies from one command to the next, so it is implemented as an
 // interface.  The ID field has to be a pointer for Go to put a null in it when
 // empty.
+//
+// The Jsonrpc field is only set for JSON-RPC 2.0 responses, which only contain
+// the error member when the request failed and the result member otherwise.
 type Response struct {
-	Result json.RawMessage `json:"result"`
-	Error  *RPCError       `json:"error"`
-	ID     *interface{}    `json:"id"`
+	Jsonrpc string          `json:"jsonrpc,omitempty"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+	ID      *interface{}    `json:"id"`
+}
+
+// MarshalJSON marshals the response as a JSON-RPC response object in the
+// format of the JSON-RPC version identified by the Jsonrpc field.
+//
+// This is part of the json.Marshaler interface.
+func (r Response) MarshalJSON() ([]byte, error) {
+	// The response type is redefined to avoid recursing into this method.
+	type response Response
+	if r.Jsonrpc != JSONRPCVersion2 {
+		return json.Marshal(response(r))
+	}
+
+	if r.Error != nil {
+		return json.Marshal(&struct {
+			Jsonrpc string       `json:"jsonrpc"`
+			Error   *RPCError    `json:"error"`
+			ID      *interface{} `json:"id"`
+		}{r.Jsonrpc, r.Error, r.ID})
+	}
+	result := r.Result
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return json.Marshal(&struct {
+		Jsonrpc string          `json:"jsonrpc"`
+		Result  json.RawMessage `json:"result"`
+		ID      *interface{}    `json:"id"`
+	}{r.Jsonrpc, result, r.ID})
 }
 
 // NewResponse returns a new JSON-RPC response object given the provided id,
@@ -138,6 +233,14 @@ func NewResponse(id interface{}, marshalledResult []byte, rpcErr *RPCError) (*Re
 // MarshalResponse marshals the passed id, result, and RPCError to a JSON-RPC
 // response byte slice that is suitable for transmission to a JSON-RPC client.
 func MarshalResponse(id interface{}, result interface{}, rpcErr *RPCError) ([]byte, error) {
+	return MarshalVersionedResponse("", id, result, rpcErr)
+}
+
+// MarshalVersionedResponse marshals the passed id, result, and RPCError to a
+// JSON-RPC response byte slice in the format of the passed JSON-RPC version,
+// which is typically the jsonrpc member of the request.  Any version other
+// than JSON-RPC 2.0 results in a JSON-RPC 1.0 response.
+func MarshalVersionedResponse(jsonrpc string, id interface{}, result interface{}, rpcErr *RPCError) ([]byte, error) {
 	marshalledResult, err := json.Marshal(result)
 	if err != nil {
 		return nil, err
@@ -146,5 +249,8 @@ func MarshalResponse(id interface{}, result interface{}, rpcErr *RPCError) ([]by
 	if err != nil {
 		return nil, err
 	}
+	if jsonrpc == JSONRPCVersion2 {
+		response.Jsonrpc = jsonrpc
+	}
 	return json.Marshal(&response)
 }
@@ -98,6 +98,145 @@ func TestMarshalResponse(t *testing.T) {
 	}
 }
 
+// TestMarshalVersionedResponse ensures the MarshalVersionedResponse function
+// produces responses in the format of the requested JSON-RPC version.
+func TestMarshalVersionedResponse(t *testing.T) {
+	t.Parallel()
+
+	testID := 1
+	tests := []struct {
+		name     string
+		jsonrpc  string
+		result   interface{}
+		jsonErr  *btcjson.RPCError
+		expected []byte
+	}{
+		{
+			name:     "1.0 result",
+			jsonrpc:  "1.0",
+			result:   true,
+			expected: []byte(`{"result":true,"error":null,"id":1}`),
+		},
+		{
+			name:     "2.0 result",
+			jsonrpc:  "2.0",
+			result:   true,
+			expected: []byte(`{"jsonrpc":"2.0","result":true,"id":1}`),
+		},
+		{
+			name:     "2.0 null result",
+			jsonrpc:  "2.0",
+			result:   nil,
+			expected: []byte(`{"jsonrpc":"2.0","result":null,"id":1}`),
+		},
+		{
+			name:    "2.0 error",
+			jsonrpc: "2.0",
+			result:  nil,
+			jsonErr: btcjson.NewRPCError(btcjson.ErrRPCBlockNotFound,
+				"123 not found"),
+			expected: []byte(`{"jsonrpc":"2.0","error":{"code":-5,"message":"123 not found"},"id":1}`),
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		marshalled, err := btcjson.MarshalVersionedResponse(test.jsonrpc,
+			testID, test.result, test.jsonErr)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(marshalled, test.expected) {
+			t.Errorf("Test #%d (%s) mismatched result - got %s, "+
+				"want %s", i, test.name, marshalled,
+				test.expected)
+		}
+	}
+}
+
+// TestRequestParams ensures requests with positional, named, and omitted
+// parameters are unmarshalled and marshalled as expected.
+func TestRequestParams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		marshalled  string
+		params      []json.RawMessage
+		namedParams map[string]json.RawMessage
+		remarshal   string
+	}{
+		{
+			name:       "positional",
+			marshalled: `{"jsonrpc":"1.0","method":"getblock","params":["123",0],"id":1}`,
+			params: []json.RawMessage{
+				json.RawMessage(`"123"`), json.RawMessage("0"),
+			},
+			remarshal: `{"jsonrpc":"1.0","method":"getblock","params":["123",0],"id":1}`,
+		},
+		{
+			name:       "named",
+			marshalled: `{"jsonrpc":"2.0","method":"getblock","params":{"hash":"123","verbosity":0},"id":1}`,
+			namedParams: map[string]json.RawMessage{
+				"hash":      json.RawMessage(`"123"`),
+				"verbosity": json.RawMessage("0"),
+			},
+			remarshal: `{"jsonrpc":"2.0","method":"getblock","params":{"hash":"123","verbosity":0},"id":1}`,
+		},
+		{
+			name:       "omitted",
+			marshalled: `{"jsonrpc":"2.0","method":"getblockcount","id":1}`,
+			remarshal:  `{"jsonrpc":"2.0","method":"getblockcount","params":null,"id":1}`,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		var request btcjson.Request
+		err := json.Unmarshal([]byte(test.marshalled), &request)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(request.Params, test.params) {
+			t.Errorf("Test #%d (%s) mismatched params - got %v, "+
+				"want %v", i, test.name, request.Params,
+				test.params)
+			continue
+		}
+		if !reflect.DeepEqual(request.NamedParams, test.namedParams) {
+			t.Errorf("Test #%d (%s) mismatched named params - got "+
+				"%v, want %v", i, test.name, request.NamedParams,
+				test.namedParams)
+			continue
+		}
+
+		remarshalled, err := json.Marshal(&request)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected marshal error: %v",
+				i, test.name, err)
+			continue
+		}
+		if string(remarshalled) != test.remarshal {
+			t.Errorf("Test #%d (%s) mismatched marshalled request "+
+				"- got %s, want %s", i, test.name, remarshalled,
+				test.remarshal)
+		}
+	}
+
+	// Params which are neither an array nor an object are rejected.
+	var request btcjson.Request
+	err := json.Unmarshal([]byte(`{"method":"getblock","params":"123"}`),
+		&request)
+	if err == nil {
+		t.Error("Unmarshal: did not receive error for invalid params")
+	}
+}
+
 // TestMiscErrors tests a few error conditions not covered elsewhere.
 func TestMiscErrors(t *testing.T) {
 	t.Parallel()
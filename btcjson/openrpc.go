@@ -0,0 +1,323 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// OpenRPCVersion is the version of the OpenRPC specification that the
+// documents described by the types in this file conform to.
+const OpenRPCVersion = "1.2.6"
+
+// OpenRPCDocument models an OpenRPC document which describes the methods of a
+// JSON-RPC API in a machine-readable format.  See https://spec.open-rpc.org
+// for details.
+type OpenRPCDocument struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+// OpenRPCInfo models the metadata about the API of an OpenRPC document.
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenRPCMethod models the description of a single method of an OpenRPC
+// document.
+type OpenRPCMethod struct {
+	Name           string                     `json:"name"`
+	Summary        string                     `json:"summary,omitempty"`
+	ParamStructure string                     `json:"paramStructure,omitempty"`
+	Params         []OpenRPCContentDescriptor `json:"params"`
+	Result         OpenRPCContentDescriptor   `json:"result"`
+}
+
+// OpenRPCContentDescriptor models the description of a parameter or result of
+// an OpenRPC method.
+type OpenRPCContentDescriptor struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Required    bool        `json:"required,omitempty"`
+	Schema      *JSONSchema `json:"schema"`
+}
+
+// JSONSchema models the subset of JSON Schema used to describe the parameters
+// and results of the methods in an OpenRPC document.  A schema without a type
+// accepts any value.
+type JSONSchema struct {
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Default              json.RawMessage        `json:"default,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+}
+
+// isComplexJSONType returns whether the passed type results in a JSON object,
+// or an array of JSON objects, which is when the help output does not contain
+// a description of the type itself.
+func isComplexJSONType(rt reflect.Type) bool {
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.Struct, reflect.Map:
+		return true
+	case reflect.Array, reflect.Slice:
+		return isComplexJSONType(rt.Elem())
+	}
+	return false
+}
+
+// reflectTypeToJSONSchema returns the JSON schema for the provided Go type.
+// The descriptions of struct fields are looked up the same way as for the help
+// output, and the passed description key is used for the descriptions of the
+// entries of maps.  Structs which are already being described further up are
+// not described again to avoid infinite recursion.
+func reflectTypeToJSONSchema(xT descLookupFunc, rt reflect.Type, fieldDescKey string, visiting map[reflect.Type]struct{}) *JSONSchema {
+	// Indirect pointer if needed.
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	kind := rt.Kind()
+	if isNumeric(kind) {
+		if kind == reflect.Float32 || kind == reflect.Float64 {
+			return &JSONSchema{Type: "number"}
+		}
+
+		return &JSONSchema{Type: "integer"}
+	}
+
+	switch kind {
+	case reflect.String:
+		return &JSONSchema{Type: "string"}
+
+	case reflect.Bool:
+		return &JSONSchema{Type: "boolean"}
+
+	case reflect.Array, reflect.Slice:
+		return &JSONSchema{
+			Type: "array",
+			Items: reflectTypeToJSONSchema(xT, rt.Elem(),
+				fieldDescKey, visiting),
+		}
+
+	case reflect.Struct:
+		schema := &JSONSchema{Type: "object", Title: rt.Name()}
+		if _, ok := visiting[rt]; ok {
+			return schema
+		}
+		visiting[rt] = struct{}{}
+		schema.Properties = make(map[string]*JSONSchema)
+		addStructProperties(xT, rt, schema, true, visiting)
+		delete(visiting, rt)
+		return schema
+
+	case reflect.Map:
+		valueSchema := reflectTypeToJSONSchema(xT, rt.Elem(),
+			fieldDescKey, visiting)
+		valueSchema.Description = xT(fieldDescKey + "--desc")
+		return &JSONSchema{
+			Type:                 "object",
+			AdditionalProperties: valueSchema,
+		}
+	}
+
+	return &JSONSchema{}
+}
+
+// addStructProperties adds a property to the passed schema for each field of
+// the provided struct type as it would be marshalled by the encoding/json
+// package.  This means the fields of embedded structs are added as properties
+// of the passed schema.  Properties are only marked as required when the
+// passed flag is set and the field is not omitted when empty.
+func addStructProperties(xT descLookupFunc, rt reflect.Type, schema *JSONSchema, required bool, visiting map[reflect.Type]struct{}) {
+	typeName := strings.ToLower(rt.Name())
+	for i := 0; i < rt.NumField(); i++ {
+		rtf := rt.Field(i)
+		tag := rtf.Tag.Get("json")
+		tagParts := strings.Split(tag, ",")
+		jsonName := tagParts[0]
+		if jsonName == "-" || (rtf.PkgPath != "" && !rtf.Anonymous) {
+			continue
+		}
+
+		// The fields of embedded structs without a json name are
+		// marshalled as if they were fields of the outer struct.  They
+		// are not marshalled at all when the embedded pointer is nil.
+		if rtf.Anonymous && jsonName == "" {
+			embeddedType := rtf.Type
+			isPtr := embeddedType.Kind() == reflect.Ptr
+			if isPtr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				addStructProperties(xT, embeddedType, schema,
+					required && !isPtr, visiting)
+				continue
+			}
+		}
+		if rtf.PkgPath != "" {
+			continue
+		}
+
+		// The description key uses the same field name as the help
+		// output, which is the lowercase field name when there is no
+		// json name.
+		fieldName := jsonName
+		if jsonName == "" {
+			jsonName = rtf.Name
+			fieldName = strings.ToLower(rtf.Name)
+		}
+		fieldDescKey := typeName + "-" + fieldName
+		propSchema := reflectTypeToJSONSchema(xT, rtf.Type, fieldDescKey,
+			visiting)
+		propSchema.Description = xT(fieldDescKey)
+		schema.Properties[jsonName] = propSchema
+
+		omitEmpty := false
+		for _, option := range tagParts[1:] {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		if required && !omitEmpty {
+			schema.Required = append(schema.Required, jsonName)
+		}
+	}
+}
+
+// methodParams returns the OpenRPC content descriptors for the parameters of
+// the provided command.  The parameters are named by the lowercase field names
+// which is also how they are named by the help output and how named
+// parameters are matched by UnmarshalCmd.
+func methodParams(xT descLookupFunc, rtp reflect.Type, info *methodInfo, method string) ([]OpenRPCContentDescriptor, error) {
+	rt := rtp.Elem()
+	numFields := rt.NumField()
+	params := make([]OpenRPCContentDescriptor, 0, numFields)
+	for i := 0; i < numFields; i++ {
+		rtf := rt.Field(i)
+		fieldName := strings.ToLower(rtf.Name)
+		fieldDescKey := method + "-" + fieldName
+		schema := reflectTypeToJSONSchema(xT, rtf.Type, fieldDescKey,
+			make(map[reflect.Type]struct{}))
+
+		// Add the default value if there is one.  It must be a pointer
+		// due to the rules enforced by RegisterCmd.
+		if defaultVal, ok := info.defaults[i]; ok {
+			marshalled, err := json.Marshal(defaultVal.Elem().Interface())
+			if err != nil {
+				return nil, err
+			}
+			schema.Default = marshalled
+		}
+
+		params = append(params, OpenRPCContentDescriptor{
+			Name:        fieldName,
+			Description: xT(fieldDescKey),
+			Required:    i < info.numReqParams,
+			Schema:      schema,
+		})
+	}
+
+	return params, nil
+}
+
+// methodResult returns the OpenRPC content descriptor for the result of a
+// method with the provided result types.  When there is more than one result
+// type, the schema is one of the schemas of each result type titled with the
+// condition which triggers it.
+func methodResult(xT descLookupFunc, method string, resultTypes []interface{}) OpenRPCContentDescriptor {
+	schemas := make([]*JSONSchema, 0, len(resultTypes))
+	for i, resultType := range resultTypes {
+		if resultType == nil {
+			schemas = append(schemas, &JSONSchema{
+				Type:        "null",
+				Description: xT("help-result-nothing"),
+			})
+			continue
+		}
+
+		rt := reflect.TypeOf(resultType).Elem()
+		fieldDescKey := fmt.Sprintf("%s--result%d", method, i)
+		schema := reflectTypeToJSONSchema(xT, rt, fieldDescKey,
+			make(map[reflect.Type]struct{}))
+		if !isComplexJSONType(rt) {
+			schema.Description = xT(fieldDescKey)
+		}
+		schemas = append(schemas, schema)
+	}
+
+	result := OpenRPCContentDescriptor{Name: "result"}
+	switch len(schemas) {
+	case 0:
+		result.Schema = &JSONSchema{
+			Type:        "null",
+			Description: xT("help-result-nothing"),
+		}
+	case 1:
+		result.Schema = schemas[0]
+	default:
+		for i, schema := range schemas {
+			condKey := fmt.Sprintf("%s--condition%d", method, i)
+			schema.Title = xT(condKey)
+		}
+		result.Schema = &JSONSchema{OneOf: schemas}
+	}
+	return result
+}
+
+// GenerateOpenRPCMethod generates and returns the OpenRPC description of the
+// provided method and result types given a map to provide the appropriate keys
+// for the method synopsis, field descriptions, conditions, and result
+// descriptions.  The method must be associated with a registered type.
+//
+// The result types and the keys required in the provided descriptions map are
+// the same as for GenerateHelp, so the descriptions used to generate the help
+// of a method may also be used to generate its OpenRPC description.
+func GenerateOpenRPCMethod(method string, descs map[string]string, resultTypes ...interface{}) (*OpenRPCMethod, error) {
+	// Look up details about the provided method and error out if not
+	// registered.
+	registerLock.RLock()
+	rtp, ok := methodToConcreteType[method]
+	info := methodToInfo[method]
+	registerLock.RUnlock()
+	if !ok {
+		str := fmt.Sprintf("%q is not registered", method)
+		return nil, makeError(ErrUnregisteredMethod, str)
+	}
+
+	if err := checkResultTypes(resultTypes); err != nil {
+		return nil, err
+	}
+
+	var missingKey string
+	xT := helpDescLookup(descs, &missingKey)
+	params, err := methodParams(xT, rtp, &info, method)
+	if err != nil {
+		return nil, err
+	}
+	openRPCMethod := &OpenRPCMethod{
+		Name:           method,
+		Summary:        xT(method + "--synopsis"),
+		ParamStructure: "either",
+		Params:         params,
+		Result:         methodResult(xT, method, resultTypes),
+	}
+	if missingKey != "" {
+		return openRPCMethod, makeError(ErrMissingDescription, missingKey)
+	}
+	return openRPCMethod, nil
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// openRPCEmbedded is embedded in openRPCResult to ensure the fields of embedded
+// structs are described as fields of the outer struct.
+type openRPCEmbedded struct {
+	Extra string `json:"extra"`
+}
+
+// openRPCResult is a result type used to test the OpenRPC descriptions of
+// struct results.
+type openRPCResult struct {
+	Hash    string            `json:"hash"`
+	Height  int32             `json:"height"`
+	Fees    map[string]uint64 `json:"fees"`
+	Pruned  *bool             `json:"pruned,omitempty"`
+	Ignored string            `json:"-"`
+	*openRPCEmbedded
+}
+
+// TestGenerateOpenRPCMethod ensures the GenerateOpenRPCMethod function
+// describes the parameters and results of a method as expected.
+func TestGenerateOpenRPCMethod(t *testing.T) {
+	t.Parallel()
+
+	descs := map[string]string{
+		"getblock--synopsis":       "Returns a block",
+		"getblock-hash":            "The hash",
+		"getblock-verbosity":       "The verbosity",
+		"getblock--condition0":     "verbosity=0",
+		"getblock--condition1":     "verbosity=1",
+		"getblock--result0":        "The serialized block",
+		"openrpcresult-hash":       "The block hash",
+		"openrpcresult-height":     "The block height",
+		"openrpcresult-fees":       "The fees",
+		"openrpcresult-fees--desc": "The fee of a transaction",
+		"openrpcresult-pruned":     "Whether the block is pruned",
+		"openrpcresult-ignored":    "Never used",
+		"openrpcembedded-extra":    "Extra data",
+	}
+	method, err := btcjson.GenerateOpenRPCMethod("getblock", descs,
+		(*string)(nil), (*openRPCResult)(nil))
+	if err != nil {
+		t.Fatalf("GenerateOpenRPCMethod: unexpected error: %v", err)
+	}
+	marshalled, err := json.Marshal(method)
+	if err != nil {
+		t.Fatalf("Marshal: unexpected error: %v", err)
+	}
+
+	want := `{"name":"getblock","summary":"Returns a block",` +
+		`"paramStructure":"either","params":[` +
+		`{"name":"hash","description":"The hash","required":true,` +
+		`"schema":{"type":"string"}},` +
+		`{"name":"verbosity","description":"The verbosity",` +
+		`"schema":{"type":"integer","default":1}}],` +
+		`"result":{"name":"result","schema":{"oneOf":[` +
+		`{"title":"verbosity=0","description":"The serialized block",` +
+		`"type":"string"},` +
+		`{"title":"verbosity=1","type":"object","properties":{` +
+		`"extra":{"description":"Extra data","type":"string"},` +
+		`"fees":{"description":"The fees","type":"object",` +
+		`"additionalProperties":{"description":"The fee of a ` +
+		`transaction","type":"integer"}},` +
+		`"hash":{"description":"The block hash","type":"string"},` +
+		`"height":{"description":"The block height","type":"integer"},` +
+		`"pruned":{"description":"Whether the block is pruned",` +
+		`"type":"boolean"}},` +
+		`"required":["hash","height","fees"]}]}}}`
+	if string(marshalled) != want {
+		t.Fatalf("GenerateOpenRPCMethod: unexpected method - got\n%s\n"+
+			"want\n%s", marshalled, want)
+	}
+}
+
+// TestGenerateOpenRPCMethodErrors ensures the GenerateOpenRPCMethod function
+// returns the expected errors.
+func TestGenerateOpenRPCMethodErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		method      string
+		resultTypes []interface{}
+		err         btcjson.Error
+	}{
+		{
+			name:   "unregistered command",
+			method: "boguscommand",
+			err:    btcjson.Error{ErrorCode: btcjson.ErrUnregisteredMethod},
+		},
+		{
+			name:        "non-pointer result type",
+			method:      "help",
+			resultTypes: []interface{}{0},
+			err:         btcjson.Error{ErrorCode: btcjson.ErrInvalidType},
+		},
+		{
+			name:   "missing description",
+			method: "help",
+			err:    btcjson.Error{ErrorCode: btcjson.ErrMissingDescription},
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		_, err := btcjson.GenerateOpenRPCMethod(test.method, nil,
+			test.resultTypes...)
+		jerr, ok := err.(btcjson.Error)
+		if !ok || jerr.ErrorCode != test.err.ErrorCode {
+			t.Errorf("Test #%d (%s) unexpected error - got %v, "+
+				"want %v", i, test.name, err, test.err.ErrorCode)
+		}
+	}
+}
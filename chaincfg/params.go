@@ -181,6 +181,16 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// MinimumChainWork is the minimum amount of cumulative proof of work a
+	// header chain must demonstrate before it is committed to and the
+	// blocks it describes are downloaded.  It protects against peers
+	// feeding an endless stream of low-work headers during the initial
+	// header sync and is also used to determine whether or not the chain
+	// is believed to be current.
+	//
+	// A nil value disables the minimum chain work requirement.
+	MinimumChainWork *big.Int
+
 	// These fields are related to voting on consensus rule changes as
 	// defined by BIP0009.
 	//
@@ -281,6 +291,11 @@ var MainNetParams = Params{
 		{560000, newHashFromStr("0000000000000000002c7b276daf6efb2b6aa68e2ce3be67ef925b3264ae7122")},
 	},
 
+	// The minimum amount of cumulative work for a header chain to be
+	// considered by the initial header sync.  This is the total work of
+	// the main chain at height 563378.
+	MinimumChainWork: newBigFromHex("0000000000000000000000000000000000000000051dc8b82f450202ecb3d471"),
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -451,6 +466,11 @@ var TestNet3Params = Params{
 		{1300007, newHashFromStr("0000000072eab69d54df75107c052b26b0395b44f77578184293bf1bb1dbd9fa")},
 	},
 
+	// The minimum amount of cumulative work for a header chain to be
+	// considered by the initial header sync.  This is the total work of
+	// the test network at height 1488000.
+	MinimumChainWork: newBigFromHex("00000000000000000000000000000000000000000000007dbe94253893cbd463"),
+
 	// Consensus rule change deployments.
 	//
 	// The miner confirmation window is defined as:
@@ -734,6 +754,19 @@ func newHashFromStr(hexStr string) *chainhash.Hash {
 	return hash
 }
 
+// newBigFromHex converts the passed big-endian hex string into a big.Int.  It
+// panics on an error since it will only (and must only) be called with
+// hard-coded, and therefore known good, values.
+func newBigFromHex(hexStr string) *big.Int {
+	n, ok := new(big.Int).SetString(hexStr, 16)
+	if !ok {
+		// See the comment in newHashFromStr for why a panic is
+		// acceptable here.
+		panic("invalid hard-coded big integer " + hexStr)
+	}
+	return n
+}
+
 func init() {
 	// Register all default networks when the package is initialized.
 	mustRegister(&MainNetParams)
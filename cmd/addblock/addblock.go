@@ -10,6 +10,7 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/blockfile"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btcd/limits"
 	"github.com/btcsuite/btclog"
@@ -83,17 +84,20 @@ func realMain() error {
 	}
 	defer db.Close()
 
-	fi, err := os.Open(cfg.InFile)
+	// Open the input file or the block files in the input directory.
+	r, err := blockfile.Open(cfg.InFile, activeNetParams.Net)
 	if err != nil {
-		log.Errorf("Failed to open file %v: %v", cfg.InFile, err)
+		log.Errorf("Failed to open %v: %v", cfg.InFile, err)
 		return err
 	}
-	defer fi.Close()
+	defer r.Close()
+	log.Infof("Reading blocks from %d %s", len(r.Files()),
+		pickNoun(len(r.Files()), "file", "files"))
 
-	// Create a block importer for the database and input file and start it.
-	// The done channel returned from start will contain an error if
+	// Create a block importer for the database and input files and start
+	// it.  The done channel returned from start will contain an error if
 	// anything went wrong.
-	importer, err := newBlockImporter(db, fi)
+	importer, err := newBlockImporter(db, r)
 	if err != nil {
 		log.Errorf("Failed create block importer: %v", err)
 		return err
@@ -112,11 +116,20 @@ func realMain() error {
 	}
 
 	log.Infof("Processed a total of %d blocks (%d imported, %d already "+
-		"known)", results.blocksProcessed, results.blocksImported,
+		"known or skipped)", results.blocksProcessed, results.blocksImported,
 		results.blocksProcessed-results.blocksImported)
 	return nil
 }
 
+// pickNoun returns the singular or plural form of a noun depending on the
+// count n.
+func pickNoun(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
 func main() {
 	// up some limits.
 	if err := limits.SetLimits(); err != nil {
@@ -37,7 +37,7 @@ type config struct {
 	AddrIndex      bool   `long:"addrindex" description:"Build a full address-based transaction index which makes the searchrawtransactions RPC available"`
 	DataDir        string `short:"b" long:"datadir" description:"Location of the btcd data directory"`
 	DbType         string `long:"dbtype" description:"Database backend to use for the Block Chain"`
-	InFile         string `short:"i" long:"infile" description:"File containing the block(s)"`
+	InFile         string `short:"i" long:"infile" description:"File containing the block(s), such as bootstrap.dat, or directory containing the blk*.dat files of Bitcoin Core, which may be obfuscated with the key in xor.dat"`
 	Progress       int    `short:"p" long:"progress" description:"Show a progress message each time this number of seconds have passed -- Use 0 to disable progress announcements"`
 	RegressionTest bool   `long:"regtest" description:"Use the regression test network"`
 	SimNet         bool   `long:"simnet" description:"Use the simulation test network"`
@@ -148,9 +148,10 @@ func loadConfig() (*config, []string, error) {
 	// worry about changing names per network and such.
 	cfg.DataDir = filepath.Join(cfg.DataDir, netName(activeNetParams))
 
-	// Ensure the specified block file exists.
+	// Ensure the specified block file or directory exists.
 	if !fileExists(cfg.InFile) {
-		str := "%s: The specified block file [%v] does not exist"
+		str := "%s: The specified block file or directory [%v] does " +
+			"not exist"
 		err := fmt.Errorf(str, "loadConfig", cfg.InFile)
 		fmt.Fprintln(os.Stderr, err)
 		parser.WriteHelp(os.Stderr)
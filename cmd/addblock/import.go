@@ -5,7 +5,6 @@
 package main
 
 import (
-	"encoding/binary"
 	"fmt"
 	"io"
 	"sync"
@@ -13,9 +12,9 @@ import (
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/blockfile"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
-	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 )
 
@@ -28,12 +27,12 @@ type importResults struct {
 	err             error
 }
 
-// blockImporter houses information about an ongoing import from a block data
-// file to the block database.
+// blockImporter houses information about an ongoing import from block data
+// files to the block database.
 type blockImporter struct {
 	db                database.DB
 	chain             *blockchain.BlockChain
-	r                 io.ReadSeeker
+	blocks            *blockfile.Orderer
 	processQueue      chan []byte
 	doneChan          chan bool
 	errChan           chan error
@@ -48,55 +47,20 @@ type blockImporter struct {
 	lastLogTime       time.Time
 }
 
-// readBlock reads the next block from the input file.
-func (bi *blockImporter) readBlock() ([]byte, error) {
-	// The block file format is:
-	//  <network> <block length> <serialized block>
-	var net uint32
-	err := binary.Read(bi.r, binary.LittleEndian, &net)
-	if err != nil {
-		if err != io.EOF {
-			return nil, err
-		}
-
-		// No block and no error means there are no more blocks to read.
-		return nil, nil
-	}
-	if net != uint32(activeNetParams.Net) {
-		return nil, fmt.Errorf("network mismatch -- got %x, want %x",
-			net, uint32(activeNetParams.Net))
-	}
-
-	// Read the block length and ensure it is sane.
-	var blockLen uint32
-	if err := binary.Read(bi.r, binary.LittleEndian, &blockLen); err != nil {
-		return nil, err
-	}
-	if blockLen > wire.MaxBlockPayload {
-		return nil, fmt.Errorf("block payload of %d bytes is larger "+
-			"than the max allowed %d bytes", blockLen,
-			wire.MaxBlockPayload)
-	}
-
-	serializedBlock := make([]byte, blockLen)
-	if _, err := io.ReadFull(bi.r, serializedBlock); err != nil {
-		return nil, err
-	}
-
-	return serializedBlock, nil
-}
-
 // processBlock potentially imports the block into the database.  It first
-// deserializes the raw block while checking for errors.  Already known blocks
-// are skipped and orphan blocks are considered errors.  Finally, it runs the
+// deserializes the raw block while checking for errors.  Finally, it runs the
 // block through the chain rules to ensure it follows all rules and matches
-// up to the known checkpoint.  Returns whether the block was imported along
-// with any potential errors.
+// up to the known checkpoint.  Blocks that end up on a side chain, such as the
+// stale blocks found in the block files of Bitcoin Core, are imported as well.
+// Already known blocks are skipped, while malformed, orphan and rejected blocks
+// are logged and skipped, so only database errors abort the import.  Returns
+// whether the block was imported along with any potential errors.
 func (bi *blockImporter) processBlock(serializedBlock []byte) (bool, error) {
 	// Deserialize the block which includes checks for malformed blocks.
 	block, err := btcutil.NewBlockFromBytes(serializedBlock)
 	if err != nil {
-		return false, err
+		log.Warnf("Skipping malformed block: %v", err)
+		return false, nil
 	}
 
 	// update progress statistics
@@ -121,9 +85,9 @@ func (bi *blockImporter) processBlock(serializedBlock []byte) (bool, error) {
 			return false, err
 		}
 		if !exists {
-			return false, fmt.Errorf("import file contains block "+
-				"%v which does not link to the available "+
-				"block chain", prevHash)
+			log.Warnf("Skipping block %v which does not link to "+
+				"the available block chain", blockHash)
+			return false, nil
 		}
 	}
 
@@ -132,40 +96,49 @@ func (bi *blockImporter) processBlock(serializedBlock []byte) (bool, error) {
 	isMainChain, isOrphan, err := bi.chain.ProcessBlock(block,
 		blockchain.BFFastAdd)
 	if err != nil {
-		return false, err
-	}
-	if !isMainChain {
-		return false, fmt.Errorf("import file contains an block that "+
-			"does not extend the main chain: %v", blockHash)
+		if _, ok := err.(database.Error); ok {
+			return false, err
+		}
+		log.Warnf("Rejected block %v: %v", blockHash, err)
+		return false, nil
 	}
 	if isOrphan {
-		return false, fmt.Errorf("import file contains an orphan "+
-			"block: %v", blockHash)
+		log.Warnf("Skipping orphan block %v", blockHash)
+		return false, nil
+	}
+	if !isMainChain {
+		log.Debugf("Imported side chain block %v", blockHash)
 	}
+	bi.lastHeight = int64(bi.chain.BestSnapshot().Height)
 
 	return true, nil
 }
 
-// readHandler is the main handler for reading blocks from the import file.
-// This allows block processing to take place in parallel with block reads.
-// It must be run as a goroutine.
+// readHandler is the main handler for reading blocks from the import files.
+// The blocks are returned by the orderer so each block follows its parent even
+// when the files contain them out of order.  This allows block processing to
+// take place in parallel with block reads.  It must be run as a goroutine.
 func (bi *blockImporter) readHandler() {
 out:
 	for {
-		// Read the next block from the file and if anything goes wrong
+		// Read the next block from the files and if anything goes wrong
 		// notify the status handler with the error and bail.
-		serializedBlock, err := bi.readBlock()
+		serializedBlock, err := bi.blocks.Next()
+		if err == io.EOF {
+			// Blocks that never link to the chain, such as stale
+			// blocks whose parent is missing, are skipped.
+			if n := bi.blocks.Unlinked(); n > 0 {
+				log.Infof("Skipped %d blocks that don't link to "+
+					"the block chain", n)
+			}
+			break out
+		}
 		if err != nil {
 			bi.errChan <- fmt.Errorf("Error reading from input "+
 				"file: %v", err.Error())
 			break out
 		}
 
-		// A nil block with no error means we're done.
-		if serializedBlock == nil {
-			break out
-		}
-
 		// Send the block or quit if we've been signalled to exit by
 		// the status handler due to an error elsewhere.
 		select {
@@ -228,7 +201,6 @@ out:
 			}
 
 			bi.blocksProcessed++
-			bi.lastHeight++
 			imported, err := bi.processBlock(serializedBlock)
 			if err != nil {
 				bi.errChan <- err
@@ -297,9 +269,9 @@ func (bi *blockImporter) Import() chan *importResults {
 	return resultChan
 }
 
-// newBlockImporter returns a new importer for the provided file reader seeker
-// and database.
-func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
+// newBlockImporter returns a new importer for the blocks read by the provided
+// block file reader and database.
+func newBlockImporter(db database.DB, r *blockfile.Reader) (*blockImporter, error) {
 	// Create the transaction and address indexes if needed.
 	//
 	// CAUTION: the txindex needs to be first in the indexes array because
@@ -342,7 +314,7 @@ func newBlockImporter(db database.DB, r io.ReadSeeker) (*blockImporter, error) {
 
 	return &blockImporter{
 		db:           db,
-		r:            r,
+		blocks:       blockfile.NewOrderer(r, chain.HaveBlock),
 		processQueue: make(chan []byte, 2),
 		doneChan:     make(chan bool),
 		errChan:      make(chan error),
@@ -102,7 +102,7 @@ type config struct {
 	RegressionTest bool   `long:"regtest" description:"Connect to the regression test network"`
 	RPCCert        string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
 	RPCPassword    string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
-	RPCServer      string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
+	RPCServer      string `short:"s" long:"rpcserver" description:"RPC server to connect to -- NOTE: A Unix domain socket may be specified with unix:/path/to/socket which implies --notls"`
 	RPCUser        string `short:"u" long:"rpcuser" description:"RPC username"`
 	SimNet         bool   `long:"simnet" description:"Connect to the simulation test network"`
 	TLSSkipVerify  bool   `long:"skipverify" description:"Do not verify tls certificates (not recommended!)"`
@@ -111,9 +111,27 @@ type config struct {
 	Wallet         bool   `long:"wallet" description:"Connect to wallet"`
 }
 
+// unixSocketPrefix is the prefix of RPC server addresses which specify the path
+// of a Unix domain socket instead of a host and port.
+const unixSocketPrefix = "unix:"
+
+// unixSocketPath returns the path of the Unix domain socket specified by the
+// passed RPC server address and whether the address specifies one.
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixSocketPrefix) {
+		return "", false
+	}
+	return addr[len(unixSocketPrefix):], true
+}
+
 // normalizeAddress returns addr with the passed default port appended if
-// there is not already a port specified.
+// there is not already a port specified.  The path of a Unix domain socket is
+// expanded instead.
 func normalizeAddress(addr string, chain *chaincfg.Params, useWallet bool) (string, error) {
+	if path, ok := unixSocketPath(addr); ok {
+		return unixSocketPrefix + cleanAndExpandPath(path), nil
+	}
+
 	_, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		var defaultPort string
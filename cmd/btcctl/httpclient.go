@@ -34,9 +34,18 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 		}
 	}
 
+	// Connect to the Unix domain socket regardless of the address of the
+	// request if one is configured.
+	path, isUnix := unixSocketPath(cfg.RPCServer)
+	if isUnix {
+		dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
+	}
+
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
-	if !cfg.NoTLS && cfg.RPCCert != "" {
+	if !cfg.NoTLS && !isUnix && cfg.RPCCert != "" {
 		pem, err := ioutil.ReadFile(cfg.RPCCert)
 		if err != nil {
 			return nil, err
@@ -66,12 +75,18 @@ func newHTTPClient(cfg *config) (*http.Client, error) {
 // unmarshal the response as a JSON-RPC response and returns either the result
 // field or the error field depending on whether or not there is an error.
 func sendPostRequest(marshalledJSON []byte, cfg *config) ([]byte, error) {
-	// Generate a request to the configured RPC server.
+	// Generate a request to the configured RPC server.  Unix domain
+	// sockets do not use TLS and their path is not a valid host, so
+	// localhost is used in the URL instead.
 	protocol := "http"
-	if !cfg.NoTLS {
+	host := cfg.RPCServer
+	_, isUnix := unixSocketPath(cfg.RPCServer)
+	if isUnix {
+		host = "localhost"
+	} else if !cfg.NoTLS {
 		protocol = "https"
 	}
-	url := protocol + "://" + cfg.RPCServer
+	url := protocol + "://" + host
 	bodyReader := bytes.NewReader(marshalledJSON)
 	httpRequest, err := http.NewRequest("POST", url, bodyReader)
 	if err != nil {
@@ -80,8 +95,11 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) ([]byte, error) {
 	httpRequest.Close = true
 	httpRequest.Header.Set("Content-Type", "application/json")
 
-	// Configure basic access authorization.
-	httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	// Configure basic access authorization unless connecting to a Unix
+	// domain socket without credentials.
+	if !isUnix || cfg.RPCUser != "" || cfg.RPCPassword != "" {
+		httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	}
 
 	// Create the new HTTP client that is configured according to the user-
 	// specified options and submit the request.
@@ -0,0 +1,266 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/database"
+)
+
+const (
+	// manifestSuffix is appended to the name of a single output file to
+	// get the name of its checksum manifest.
+	manifestSuffix = ".sha256"
+
+	// manifestFilename is the name of the checksum manifest in the output
+	// directory when the output is split into chunks.
+	manifestFilename = "SHA256SUMS"
+)
+
+// blockFileRegexp matches the names of the block files written when the output
+// is split into chunks.
+var blockFileRegexp = regexp.MustCompile(`^blk[0-9]+\.dat$`)
+
+// exportedFile houses the name and checksum of a written output file.
+type exportedFile struct {
+	name string
+	sum  []byte
+}
+
+// blockExporter houses information about an ongoing export of the main chain
+// blocks in the block database to one or more files.
+type blockExporter struct {
+	db    database.DB
+	chain *blockchain.BlockChain
+
+	// outPath is the output file, or the output directory when chunkSize
+	// is set.  chunkSize is the maximum size of an output file in bytes.
+	outPath   string
+	chunkSize int64
+
+	// file, w, hasher, and size describe the output file that is currently
+	// written.
+	file   *os.File
+	w      *bufio.Writer
+	hasher hash.Hash
+	size   int64
+
+	files             []exportedFile
+	blocksExported    int64
+	receivedLogBlocks int64
+	lastLogTime       time.Time
+}
+
+// newBlockExporter returns a new exporter that writes the blocks in the passed
+// database and chain to the passed output path.  The output is split into
+// files of at most chunkSize bytes when it is not zero.
+func newBlockExporter(db database.DB, chain *blockchain.BlockChain,
+	outPath string, chunkSize int64) (*blockExporter, error) {
+
+	// Refuse to overwrite an existing export.
+	if chunkSize == 0 {
+		if _, err := os.Stat(outPath); err == nil {
+			return nil, fmt.Errorf("output file %s already exists",
+				outPath)
+		}
+	} else {
+		if err := os.MkdirAll(outPath, 0755); err != nil {
+			return nil, err
+		}
+		entries, err := ioutil.ReadDir(outPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if blockFileRegexp.MatchString(entry.Name()) ||
+				entry.Name() == manifestFilename {
+
+				return nil, fmt.Errorf("output directory %s "+
+					"already contains %s", outPath,
+					entry.Name())
+			}
+		}
+	}
+
+	return &blockExporter{
+		db:          db,
+		chain:       chain,
+		outPath:     outPath,
+		chunkSize:   chunkSize,
+		lastLogTime: time.Now(),
+	}, nil
+}
+
+// nextFile finishes the current output file, if any, and creates the next one.
+func (be *blockExporter) nextFile() error {
+	if err := be.finishFile(); err != nil {
+		return err
+	}
+
+	path := be.outPath
+	if be.chunkSize != 0 {
+		name := fmt.Sprintf("blk%05d.dat", len(be.files))
+		path = filepath.Join(be.outPath, name)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	be.file = f
+	be.w = bufio.NewWriterSize(f, 1<<20)
+	be.hasher = sha256.New()
+	be.size = 0
+	return nil
+}
+
+// finishFile flushes and closes the current output file and records its
+// checksum.
+func (be *blockExporter) finishFile() error {
+	if be.file == nil {
+		return nil
+	}
+	err := be.w.Flush()
+	if err == nil {
+		err = be.file.Sync()
+	}
+	if closeErr := be.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	be.files = append(be.files, exportedFile{
+		name: filepath.Base(be.file.Name()),
+		sum:  be.hasher.Sum(nil),
+	})
+	be.file = nil
+	return nil
+}
+
+// writeBlock writes the passed serialized block to the output, starting a new
+// output file first when the block doesn't fit in the current chunk.
+//
+// The file format is:
+//
+//	<network> <block length> <serialized block>
+func (be *blockExporter) writeBlock(serializedBlock []byte) error {
+	recordSize := int64(8 + len(serializedBlock))
+	if be.file == nil || (be.chunkSize != 0 && be.size > 0 &&
+		be.size+recordSize > be.chunkSize) {
+
+		if err := be.nextFile(); err != nil {
+			return err
+		}
+	}
+
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], uint32(activeNetParams.Net))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(serializedBlock)))
+	w := io.MultiWriter(be.w, be.hasher)
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(serializedBlock); err != nil {
+		return err
+	}
+	be.size += recordSize
+	return nil
+}
+
+// writeManifest writes the checksums of the output files in the format of the
+// sha256sum utility, so the files can be verified with sha256sum -c.
+func (be *blockExporter) writeManifest() (string, error) {
+	path := be.outPath + manifestSuffix
+	if be.chunkSize != 0 {
+		path = filepath.Join(be.outPath, manifestFilename)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", err
+	}
+	for _, file := range be.files {
+		_, err := fmt.Fprintf(f, "%s  %s\n", hex.EncodeToString(file.sum),
+			file.name)
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	return path, f.Close()
+}
+
+// logProgress logs block progress as an information message.  In order to
+// prevent spam, it limits logging to one message every cfg.Progress seconds
+// with duration and totals included.
+func (be *blockExporter) logProgress(height int32) {
+	be.receivedLogBlocks++
+
+	now := time.Now()
+	duration := now.Sub(be.lastLogTime)
+	if cfg.Progress == 0 || duration < time.Second*time.Duration(cfg.Progress) {
+		return
+	}
+
+	// Truncate the duration to 10s of milliseconds.
+	durationMillis := int64(duration / time.Millisecond)
+	tDuration := 10 * time.Millisecond * time.Duration(durationMillis/10)
+
+	blockStr := "blocks"
+	if be.receivedLogBlocks == 1 {
+		blockStr = "block"
+	}
+	log.Infof("Exported %d %s in the last %s (height %d)",
+		be.receivedLogBlocks, blockStr, tDuration, height)
+
+	be.receivedLogBlocks = 0
+	be.lastLogTime = now
+}
+
+// Export writes the main chain blocks from the start height through the end
+// height, inclusive, to the output files in height order and then writes the
+// checksum manifest.  It returns the path of the manifest.
+func (be *blockExporter) Export(startHeight, endHeight int32) (string, error) {
+	for height := startHeight; height <= endHeight; height++ {
+		hash, err := be.chain.BlockHashByHeight(height)
+		if err != nil {
+			return "", err
+		}
+
+		// The serialized block is only valid during the database
+		// transaction, so write it from within the transaction.
+		err = be.db.View(func(dbTx database.Tx) error {
+			serializedBlock, err := dbTx.FetchBlock(hash)
+			if err != nil {
+				return err
+			}
+			return be.writeBlock(serializedBlock)
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to export block %v at "+
+				"height %d: %v", hash, height, err)
+		}
+
+		be.blocksExported++
+		be.logProgress(height)
+	}
+
+	if err := be.finishFile(); err != nil {
+		return "", err
+	}
+	return be.writeManifest()
+}
@@ -0,0 +1,355 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockfile"
+	"github.com/btcsuite/btcd/database"
+)
+
+// testBlock returns fake serialized block data of the passed size.  The data
+// is filled with the passed byte so blocks can be told apart when read back.
+func testBlock(size int, b byte) []byte {
+	return bytes.Repeat([]byte{b}, size)
+}
+
+// readExport reads the blocks written to the passed output path back and
+// returns them grouped by the output file that contains them.
+func readExport(t *testing.T, path string) [][][]byte {
+	t.Helper()
+
+	r, err := blockfile.Open(path, activeNetParams.Net)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	files := make([][][]byte, len(r.Files()))
+	for {
+		block, loc, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: unexpected error: %v", err)
+		}
+		files[loc.File] = append(files[loc.File], block)
+	}
+	return files
+}
+
+// checkManifest ensures the manifest at the passed path lists the checksums of
+// exactly the passed files in the output directory dir.
+func checkManifest(t *testing.T, manifest, dir string, names []string) {
+	t.Helper()
+
+	contents, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	var want string
+	for _, name := range names {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile: unexpected error: %v", err)
+		}
+		sum := sha256.Sum256(data)
+		want += fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]),
+			name)
+	}
+	if string(contents) != want {
+		t.Fatalf("unexpected manifest -- got:\n%s\nwant:\n%s",
+			contents, want)
+	}
+}
+
+// TestWriteBlockChunking ensures blocks are split into output files of at most
+// the chunk size, that blocks larger than a chunk get a file of their own, and
+// that the checksum manifest lists every output file.
+func TestWriteBlockChunking(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int64
+		sizes     []int
+		want      [][]int // indexes of the blocks in each file
+	}{{
+		name:  "no chunking",
+		sizes: []int{192, 192, 192},
+		want:  [][]int{{0, 1, 2}},
+	}, {
+		name:      "two records per chunk",
+		chunkSize: 400,
+		sizes:     []int{192, 192, 192},
+		want:      [][]int{{0, 1}, {2}},
+	}, {
+		name:      "record fills chunk exactly",
+		chunkSize: 200,
+		sizes:     []int{192, 192, 192},
+		want:      [][]int{{0}, {1}, {2}},
+	}, {
+		name:      "record larger than chunk",
+		chunkSize: 100,
+		sizes:     []int{192, 192},
+		want:      [][]int{{0}, {1}},
+	}, {
+		name:      "mixed sizes",
+		chunkSize: 500,
+		sizes:     []int{192, 392, 192, 192},
+		want:      [][]int{{0}, {1}, {2, 3}},
+	}, {
+		name:      "everything fits",
+		chunkSize: 1 << 20,
+		sizes:     []int{192, 392, 192},
+		want:      [][]int{{0, 1, 2}},
+	}}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "exportblocks")
+		if err != nil {
+			t.Fatalf("TempDir: unexpected error: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		outPath := filepath.Join(dir, "bootstrap.dat")
+		if test.chunkSize != 0 {
+			outPath = filepath.Join(dir, "blocks")
+		}
+		be, err := newBlockExporter(nil, nil, outPath, test.chunkSize)
+		if err != nil {
+			t.Fatalf("%s: newBlockExporter: unexpected error: %v",
+				test.name, err)
+		}
+
+		var blocks [][]byte
+		for i, size := range test.sizes {
+			block := testBlock(size, byte(i+1))
+			blocks = append(blocks, block)
+			if err := be.writeBlock(block); err != nil {
+				t.Fatalf("%s: writeBlock: unexpected error: %v",
+					test.name, err)
+			}
+		}
+		if err := be.finishFile(); err != nil {
+			t.Fatalf("%s: finishFile: unexpected error: %v",
+				test.name, err)
+		}
+		manifest, err := be.writeManifest()
+		if err != nil {
+			t.Fatalf("%s: writeManifest: unexpected error: %v",
+				test.name, err)
+		}
+
+		// Ensure the blocks were written to the expected files.
+		var want [][][]byte
+		for _, indexes := range test.want {
+			var file [][]byte
+			for _, i := range indexes {
+				file = append(file, blocks[i])
+			}
+			want = append(want, file)
+		}
+		got := readExport(t, outPath)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: unexpected blocks per file -- got %d "+
+				"files, want %d", test.name, len(got), len(want))
+		}
+
+		// Ensure the manifest is named after the output and lists the
+		// checksums of all of the output files.
+		var names []string
+		wantManifest := outPath + manifestSuffix
+		manifestDir := dir
+		if test.chunkSize == 0 {
+			names = []string{filepath.Base(outPath)}
+		} else {
+			for i := range test.want {
+				names = append(names, fmt.Sprintf("blk%05d.dat", i))
+			}
+			wantManifest = filepath.Join(outPath, manifestFilename)
+			manifestDir = outPath
+		}
+		if manifest != wantManifest {
+			t.Fatalf("%s: unexpected manifest path -- got %s, "+
+				"want %s", test.name, manifest, wantManifest)
+		}
+		checkManifest(t, manifest, manifestDir, names)
+	}
+}
+
+// TestNewBlockExporterExisting ensures an export refuses to overwrite the
+// output of a previous export, while unrelated files in the output directory
+// are left alone.
+func TestNewBlockExporterExisting(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int64
+		existing  []string // files created before the export
+		err       string
+	}{{
+		name: "new output file",
+	}, {
+		name:     "existing output file",
+		existing: []string{"out"},
+		err:      "already exists",
+	}, {
+		name:      "new output directory",
+		chunkSize: 1 << 20,
+	}, {
+		name:      "unrelated files in output directory",
+		chunkSize: 1 << 20,
+		existing:  []string{"out/README", "out/blk00000.dat.bak"},
+	}, {
+		name:      "block file in output directory",
+		chunkSize: 1 << 20,
+		existing:  []string{"out/blk00003.dat"},
+		err:       "already contains blk00003.dat",
+	}, {
+		name:      "manifest in output directory",
+		chunkSize: 1 << 20,
+		existing:  []string{"out/" + manifestFilename},
+		err:       "already contains " + manifestFilename,
+	}}
+
+	for _, test := range tests {
+		dir, err := ioutil.TempDir("", "exportblocks")
+		if err != nil {
+			t.Fatalf("TempDir: unexpected error: %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		for _, name := range test.existing {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("MkdirAll: unexpected error: %v", err)
+			}
+			if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+				t.Fatalf("WriteFile: unexpected error: %v", err)
+			}
+		}
+
+		outPath := filepath.Join(dir, "out")
+		_, err = newBlockExporter(nil, nil, outPath, test.chunkSize)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name,
+					err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: unexpected error -- got %v, want %q",
+				test.name, err, test.err)
+		}
+	}
+}
+
+// TestExportResume ensures an export of a later height range, which continues
+// an earlier export, only contains the blocks of its own range, and that the
+// earlier export can't be overwritten.
+func TestExportResume(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exportblocks")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"),
+		activeNetParams.Net)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	oldCfg := cfg
+	cfg = &config{}
+	defer func() { cfg = oldCfg }()
+
+	var genesis bytes.Buffer
+	if err := activeNetParams.GenesisBlock.Serialize(&genesis); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		outPath   string
+		chunkSize int64
+		start     int32
+		end       int32
+		want      [][][]byte
+		err       bool
+	}{{
+		name:    "first range",
+		outPath: "part1.dat",
+		want:    [][][]byte{{genesis.Bytes()}},
+	}, {
+		name:    "rerun of first range",
+		outPath: "part1.dat",
+		err:     true,
+	}, {
+		name:      "chunked range",
+		outPath:   "part2",
+		chunkSize: 1 << 20,
+		want:      [][][]byte{{genesis.Bytes()}},
+	}, {
+		name:      "rerun of chunked range",
+		outPath:   "part2",
+		chunkSize: 1 << 20,
+		err:       true,
+	}, {
+		name:    "range past the best chain",
+		outPath: "part3.dat",
+		start:   1,
+		end:     1,
+		err:     true,
+	}}
+
+	for _, test := range tests {
+		outPath := filepath.Join(dir, test.outPath)
+		be, err := newBlockExporter(db, chain, outPath, test.chunkSize)
+		if err == nil {
+			_, err = be.Export(test.start, test.end)
+		}
+		if test.err {
+			if err == nil {
+				t.Fatalf("%s: expected error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+		if be.blocksExported != int64(test.end-test.start+1) {
+			t.Fatalf("%s: unexpected number of exported blocks -- "+
+				"got %d, want %d", test.name, be.blocksExported,
+				test.end-test.start+1)
+		}
+		if got := readExport(t, outPath); !reflect.DeepEqual(got,
+			test.want) {
+
+			t.Fatalf("%s: unexpected exported blocks", test.name)
+		}
+	}
+}
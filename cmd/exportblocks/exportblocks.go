@@ -0,0 +1,140 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/limits"
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// blockDbNamePrefix is the prefix for the btcd block database.
+	blockDbNamePrefix = "blocks"
+)
+
+var (
+	cfg *config
+	log btclog.Logger
+)
+
+// loadBlockDB opens the block database and returns a handle to it.
+func loadBlockDB() (database.DB, error) {
+	// The database name is based on the database type.
+	dbName := blockDbNamePrefix + "_" + cfg.DbType
+	dbPath := filepath.Join(cfg.DataDir, dbName)
+
+	log.Infof("Loading block database from '%s'", dbPath)
+	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info("Block database loaded")
+	return db, nil
+}
+
+// realMain is the real main function for the utility.  It is necessary to work
+// around the fact that deferred functions do not run when os.Exit() is called.
+func realMain() error {
+	// Load configuration and parse command line.
+	tcfg, _, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	cfg = tcfg
+
+	// Setup logging.
+	backendLogger := btclog.NewBackend(os.Stdout)
+	defer os.Stdout.Sync()
+	log = backendLogger.Logger("MAIN")
+	database.UseLogger(backendLogger.Logger("BCDB"))
+	blockchain.UseLogger(backendLogger.Logger("CHAN"))
+
+	// Load the block database.
+	db, err := loadBlockDB()
+	if err != nil {
+		log.Errorf("Failed to load database: %v", err)
+		return err
+	}
+	defer db.Close()
+
+	// Setup chain.  Ignore notifications since they aren't needed for this
+	// util.
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: activeNetParams,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		log.Errorf("Failed to initialize chain: %v", err)
+		return err
+	}
+
+	// Validate the height range against the best chain.
+	best := chain.BestSnapshot()
+	endHeight := cfg.EndHeight
+	if endHeight < 0 {
+		endHeight = best.Height
+	}
+	if endHeight > best.Height {
+		err := fmt.Errorf("end height %d is greater than the best chain "+
+			"height %d", endHeight, best.Height)
+		log.Error(err)
+		return err
+	}
+	if cfg.StartHeight > endHeight {
+		err := fmt.Errorf("start height %d is greater than the end "+
+			"height %d", cfg.StartHeight, endHeight)
+		log.Error(err)
+		return err
+	}
+
+	exporter, err := newBlockExporter(db, chain, cfg.OutFile,
+		cfg.ChunkSize*1024*1024)
+	if err != nil {
+		log.Errorf("Failed to create block exporter: %v", err)
+		return err
+	}
+
+	log.Infof("Exporting blocks %d to %d to %s", cfg.StartHeight, endHeight,
+		cfg.OutFile)
+	manifest, err := exporter.Export(cfg.StartHeight, endHeight)
+	if err != nil {
+		log.Errorf("%v", err)
+		return err
+	}
+
+	log.Infof("Exported a total of %d blocks to %d %s (checksums in %s)",
+		exporter.blocksExported, len(exporter.files),
+		pickNoun(len(exporter.files), "file", "files"), manifest)
+	return nil
+}
+
+// pickNoun returns the singular or plural form of a noun depending on the
+// count n.
+func pickNoun(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func main() {
+	// up some limits.
+	if err := limits.SetLimits(); err != nil {
+		os.Exit(1)
+	}
+
+	// Work around defer not working after os.Exit()
+	if err := realMain(); err != nil {
+		os.Exit(1)
+	}
+}
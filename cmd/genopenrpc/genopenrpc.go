@@ -0,0 +1,62 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/btcsuite/btcd/internal/rpchelp"
+	flags "github.com/jessevdk/go-flags"
+)
+
+type config struct {
+	OutFile    string `short:"o" long:"output" description:"File to write the OpenRPC document to instead of stdout"`
+	Websockets bool   `short:"w" long:"websockets" description:"Also describe the commands which are only available to websocket clients"`
+}
+
+func main() {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+	args, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+	if len(args) != 0 {
+		parser.WriteHelp(os.Stderr)
+		os.Exit(1)
+	}
+
+	// The document is the same as the one returned by the rpc.discover
+	// command of a btcd of the same version.
+	methods := rpchelp.Methods(cfg.Websockets)
+	doc, err := rpchelp.OpenRPCDocument(methods, version())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate OpenRPC document: %v\n",
+			err)
+		os.Exit(1)
+	}
+	docJSON, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cannot marshal OpenRPC document: %v\n",
+			err)
+		os.Exit(1)
+	}
+	docJSON = append(docJSON, '\n')
+
+	if cfg.OutFile == "" {
+		os.Stdout.Write(docJSON)
+		return
+	}
+	if err := ioutil.WriteFile(cfg.OutFile, docJSON, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot write OpenRPC document: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+type config struct {
+	Password string `short:"p" long:"password" description:"Password of the user -- NOTE: A random password is generated when not specified"`
+}
+
+func main() {
+	var cfg config
+	parser := flags.NewParser(&cfg, flags.Default)
+	parser.Usage = "[OPTIONS] <username>"
+	args, err := parser.Parse()
+	if err != nil {
+		if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+			parser.WriteHelp(os.Stderr)
+		}
+		return
+	}
+	if len(args) != 1 {
+		parser.WriteHelp(os.Stderr)
+		os.Exit(1)
+	}
+	username := args[0]
+	if username == "" || strings.ContainsRune(username, ':') {
+		fmt.Fprintf(os.Stderr, "username must not be empty or contain a colon\n")
+		os.Exit(1)
+	}
+
+	saltBytes := make([]byte, 16)
+	if _, err := rand.Read(saltBytes); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot generate salt: %v\n", err)
+		os.Exit(1)
+	}
+	salt := hex.EncodeToString(saltBytes)
+
+	password := cfg.Password
+	if password == "" {
+		passwordBytes := make([]byte, 32)
+		if _, err := rand.Read(passwordBytes); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot generate password: %v\n", err)
+			os.Exit(1)
+		}
+		password = base64.URLEncoding.EncodeToString(passwordBytes)
+	}
+
+	// The hash is the HMAC-SHA256 of the password keyed by the salt.
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(password))
+	hash := hex.EncodeToString(mac.Sum(nil))
+
+	fmt.Println("String to be appended to btcd.conf:")
+	fmt.Printf("rpcauth=%s:%s$%s\n", username, salt, hash)
+	fmt.Println("Your password:")
+	fmt.Println(password)
+}
@@ -26,8 +26,10 @@ import (
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
 	_ "github.com/btcsuite/btcd/database/ffldb"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/zmtp"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/go-socks/socks"
 	flags "github.com/jessevdk/go-flags"
@@ -39,13 +41,19 @@ const (
 	defaultLogLevel              = "info"
 	defaultLogDirname            = "logs"
 	defaultLogFilename           = "btcd.log"
+	defaultLogFormat             = "text"
+	defaultLogMaxSize            = 10
+	defaultLogMaxRolls           = 3
 	defaultMaxPeers              = 125
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 100
 	defaultConnectTimeout        = time.Second * 30
+	defaultSyncStallTimeout      = time.Minute * 3
 	defaultMaxRPCClients         = 10
 	defaultMaxRPCWebsockets      = 25
 	defaultMaxRPCConcurrentReqs  = 20
+	defaultMaxRPCBatchSize       = 1000
+	defaultRPCUnixSocketMode     = "0600"
 	defaultDbType                = "ffldb"
 	defaultFreeTxRelayLimit      = 15.0
 	defaultTrickleInterval       = peer.DefaultTrickleInterval
@@ -119,9 +127,15 @@ type config struct {
 	Generate             bool          `long:"generate" description:"Generate (mine) bitcoins using the CPU"`
 	FreeTxRelayLimit     float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
+	LoadBlocks           []string      `long:"loadblock" description:"Import blocks on startup from the specified bootstrap.dat style file or directory containing the blk*.dat files of Bitcoin Core, which may be out of order and obfuscated with the key in xor.dat"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
+	LogFormat            string        `long:"logformat" description:"Format of the log output {text, json} -- The json format writes one JSON object per line with the peer address, block hash, height, and txid as separate fields when available"`
+	LogMaxRolls          int           `long:"logmaxrolls" description:"Maximum number of rotated log files to keep"`
+	LogMaxSize           int64         `long:"logmaxsize" description:"Maximum size in MiB of the log file before it is rotated"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Try to keep the data sent to peers under the given target in MiB per 24 hours by no longer serving historical blocks once it is about to be reached -- NOTE: Whitelisted peers are not affected, and 0 means no limit"`
+	MetricsListen        string        `long:"metricslisten" description:"Serve Prometheus metrics over HTTP on the given interface/port (eg. 127.0.0.1:9332) -- NOTE: The metrics are not authenticated"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
@@ -133,7 +147,7 @@ type config struct {
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	NoWinService         bool          `long:"nowinservice" description:"Do not start as a background service on Windows -- NOTE: This flag only works on the command line, not in the config file"`
-	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
+	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass, rpclimituser/rpclimitpass or rpcauth is specified"`
 	DisableTLS           bool          `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
 	OnionProxy           string        `long:"onion" description:"Connect to tor hidden services via SOCKS5 proxy (eg. 127.0.0.1:9050)"`
 	OnionProxyPass       string        `long:"onionpass" default-mask:"-" description:"Password for onion proxy server"`
@@ -146,19 +160,26 @@ type config struct {
 	RejectNonStd         bool          `long:"rejectnonstd" description:"Reject non-standard transactions regardless of the default settings for the active network."`
 	RejectReplacement    bool          `long:"rejectreplacement" description:"Reject transactions that attempt to replace existing transactions within the mempool through the Replace-By-Fee (RBF) signaling policy."`
 	RelayNonStd          bool          `long:"relaynonstd" description:"Relay non-standard transactions regardless of the default settings for the active network."`
+	RESTListeners        []string      `long:"restlisten" description:"Add an interface/port to listen for unauthenticated read-only REST requests over HTTP (eg. 127.0.0.1:8080) -- NOTE: The REST interface is disabled unless a listen address is specified"`
+	RPCAuth              []string      `long:"rpcauth" description:"Add a username and hashed password for RPC connections in the form <user>:<salt>$<hash> -- NOTE: The genrpcauth utility can be used to generate these"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
-	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
+	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port or a Unix domain socket (eg. unix:/path/to/socket) to listen for RPC connections (default port: 8334, testnet: 18334) -- NOTE: Unix domain sockets do not use TLS"`
+	RPCMaxBatchSize      int           `long:"rpcmaxbatchsize" description:"Max number of requests allowed in a single JSON-RPC batch request"`
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
+	RPCUnixNoAuth        bool          `long:"rpcunixnoauth" description:"Grant full access without authentication to RPC clients connected over a Unix domain socket that do not supply credentials -- NOTE: Access is then only restricted by the file mode of the socket"`
+	RPCUnixSocketMode    string        `long:"rpcunixsocketmode" description:"File mode of RPC Unix domain sockets in octal"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCUser              string        `short:"u" long:"rpcuser" description:"Username for RPC connections"`
+	RPCWhitelist         []string      `long:"rpcwhitelist" description:"Restrict an RPC user to the given methods in the form <user>:<method>,<method>,... -- NOTE: Multiple whitelists for the same user allow only the methods in all of them"`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	SimNet               bool          `long:"simnet" description:"Use the simulation test network"`
+	SyncStallTimeout     time.Duration `long:"syncstalltimeout" description:"How long the sync peer may go without making progress before another peer is chosen to sync from.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	TestNet3             bool          `long:"testnet" description:"Use the test network"`
 	TorIsolation         bool          `long:"torisolation" description:"Enable Tor stream isolation by randomizing user credentials for each connection."`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
@@ -167,12 +188,18 @@ type config struct {
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	ShowVersion          bool          `short:"V" long:"version" description:"Display version information and exit"`
 	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	ZMQPubHashBlock      string        `long:"zmqpubhashblock" description:"Publish the hashes of connected blocks on the given ZeroMQ endpoint (eg. tcp://127.0.0.1:28332 or ipc:///path/to/socket)"`
+	ZMQPubHashTx         string        `long:"zmqpubhashtx" description:"Publish the hashes of transactions accepted into the mempool or included in connected blocks on the given ZeroMQ endpoint"`
+	ZMQPubRawBlock       string        `long:"zmqpubrawblock" description:"Publish connected blocks on the given ZeroMQ endpoint"`
+	ZMQPubRawTx          string        `long:"zmqpubrawtx" description:"Publish transactions accepted into the mempool or included in connected blocks on the given ZeroMQ endpoint"`
+	ZMQPubSequence       string        `long:"zmqpubsequence" description:"Publish block connect and disconnect and mempool accept and removal events on the given ZeroMQ endpoint"`
 	lookup               func(string) ([]net.IP, error)
 	oniondial            func(string, string, time.Duration) (net.Conn, error)
 	dial                 func(string, string, time.Duration) (net.Conn, error)
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          []btcutil.Address
 	minRelayTxFee        btcutil.Amount
+	rpcUnixSocketMode    os.FileMode
 	whitelists           []*net.IPNet
 }
 
@@ -325,6 +352,34 @@ func normalizeAddresses(addrs []string, defaultPort string) []string {
 	return removeDuplicateAddresses(addrs)
 }
 
+// unixSocketPrefix is the prefix of RPC listen addresses which specify the
+// path of a Unix domain socket instead of an interface/port.
+const unixSocketPrefix = "unix:"
+
+// unixSocketPath returns the path of the Unix domain socket specified by the
+// passed RPC listen address and whether the address specifies one.
+func unixSocketPath(addr string) (string, bool) {
+	if !strings.HasPrefix(addr, unixSocketPrefix) {
+		return "", false
+	}
+	return addr[len(unixSocketPrefix):], true
+}
+
+// normalizeRPCListeners returns a new slice with all the passed RPC listen
+// addresses normalized with the given default port, and all duplicates
+// removed.  The paths of Unix domain sockets are expanded instead.
+func normalizeRPCListeners(addrs []string, defaultPort string) []string {
+	for i, addr := range addrs {
+		if path, ok := unixSocketPath(addr); ok {
+			addrs[i] = unixSocketPrefix + cleanAndExpandPath(path)
+			continue
+		}
+		addrs[i] = normalizeAddress(addr, defaultPort)
+	}
+
+	return removeDuplicateAddresses(addrs)
+}
+
 // newCheckpointFromStr parses checkpoints in the '<height>:<hash>' format.
 func newCheckpointFromStr(checkpoint string) (chaincfg.Checkpoint, error) {
 	parts := strings.Split(checkpoint, ":")
@@ -412,11 +467,17 @@ func loadConfig() (*config, []string, error) {
 		MaxPeers:             defaultMaxPeers,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
+		SyncStallTimeout:     defaultSyncStallTimeout,
 		RPCMaxClients:        defaultMaxRPCClients,
 		RPCMaxWebsockets:     defaultMaxRPCWebsockets,
 		RPCMaxConcurrentReqs: defaultMaxRPCConcurrentReqs,
+		RPCMaxBatchSize:      defaultMaxRPCBatchSize,
+		RPCUnixSocketMode:    defaultRPCUnixSocketMode,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
+		LogFormat:            defaultLogFormat,
+		LogMaxRolls:          defaultLogMaxRolls,
+		LogMaxSize:           defaultLogMaxSize,
 		DbType:               defaultDbType,
 		RPCKey:               defaultRPCKeyFile,
 		RPCCert:              defaultRPCCertFile,
@@ -599,9 +660,38 @@ func loadConfig() (*config, []string, error) {
 		os.Exit(0)
 	}
 
+	// Validate the log format and rotation settings.
+	logFormat, ok := jsonlog.ParseFormat(cfg.LogFormat)
+	if !ok {
+		str := "%s: The specified log format [%v] is invalid -- " +
+			"supported formats are text and json"
+		err := fmt.Errorf(str, funcName, cfg.LogFormat)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogMaxSize <= 0 {
+		str := "%s: The logmaxsize option must be greater than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.LogMaxSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.LogMaxRolls < 0 {
+		str := "%s: The logmaxrolls option may not be less than 0 " +
+			"-- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.LogMaxRolls)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	backendLog.SetFormat(logFormat)
+
 	// Initialize log rotation.  After log rotation has been initialized, the
 	// logger variables may be used.
-	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename))
+	initLogRotator(filepath.Join(cfg.LogDir, defaultLogFilename),
+		cfg.LogMaxSize, cfg.LogMaxRolls)
 
 	// Parse, validate, and set debug log level(s).
 	if err := parseAndSetDebugLevels(cfg.DebugLevel); err != nil {
@@ -621,6 +711,20 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Ensure the files and directories to import blocks from exist.
+	for i, path := range cfg.LoadBlocks {
+		path = cleanAndExpandPath(path)
+		if !fileExists(path) {
+			str := "%s: The block file or directory [%v] specified " +
+				"with the loadblock option does not exist"
+			err := fmt.Errorf(str, funcName, path)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.LoadBlocks[i] = path
+	}
+
 	// Validate profile port number
 	if cfg.Profile != "" {
 		profilePort, err := strconv.Atoi(cfg.Profile)
@@ -633,6 +737,18 @@ func loadConfig() (*config, []string, error) {
 		}
 	}
 
+	// Validate the ZeroMQ endpoints.
+	for topic, endpoint := range zmqEndpoints(&cfg) {
+		if _, _, err := zmtp.ParseEndpoint(endpoint); err != nil {
+			str := "%s: Invalid endpoint for the %s ZeroMQ " +
+				"notifications: %v"
+			err := fmt.Errorf(str, funcName, topic, err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Don't allow ban durations that are too short.
 	if cfg.BanDuration < time.Second {
 		str := "%s: The banduration option may not be less than 1s -- parsed [%v]"
@@ -642,6 +758,15 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// Don't allow sync stall timeouts that are too short.
+	if cfg.SyncStallTimeout < time.Second {
+		str := "%s: The syncstalltimeout option may not be less than 1s -- parsed [%v]"
+		err := fmt.Errorf(str, funcName, cfg.SyncStallTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Validate any given whitelisted IP addresses and networks.
 	if len(cfg.Whitelists) > 0 {
 		var ip net.IP
@@ -724,9 +849,40 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
-	// The RPC server is disabled if no username or password is provided.
+	// Ensure the rpcauth and rpcwhitelist options are valid.
+	if _, err := newRPCAuthenticator(&cfg); err != nil {
+		err := fmt.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Ensure the paths of Unix domain sockets to listen for RPC connections
+	// on are not empty.
+	var haveUnixListener bool
+	for _, addr := range cfg.RPCListeners {
+		path, ok := unixSocketPath(addr)
+		if !ok {
+			continue
+		}
+		if path == "" {
+			str := "%s: RPC listen address '%s' does not specify " +
+				"the path of the Unix domain socket"
+			err := fmt.Errorf(str, funcName, addr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		haveUnixListener = true
+	}
+
+	// The RPC server is disabled if no username or password is provided
+	// and clients connected over a Unix domain socket are not granted
+	// access without one.
 	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
+		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") &&
+		len(cfg.RPCAuth) == 0 && !(cfg.RPCUnixNoAuth && haveUnixListener) {
+
 		cfg.DisableRPC = true
 	}
 
@@ -756,6 +912,27 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	if cfg.RPCMaxBatchSize < 1 {
+		str := "%s: The rpcmaxbatchsize option may not be less than " +
+			"1 -- parsed [%d]"
+		err := fmt.Errorf(str, funcName, cfg.RPCMaxBatchSize)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the file mode of RPC Unix domain sockets.
+	socketMode, err := strconv.ParseUint(cfg.RPCUnixSocketMode, 8, 32)
+	if err != nil || socketMode > 0777 {
+		str := "%s: The rpcunixsocketmode option must be an octal " +
+			"file mode between 0 and 0777 -- parsed [%s]"
+		err := fmt.Errorf(str, funcName, cfg.RPCUnixSocketMode)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	cfg.rpcUnixSocketMode = os.FileMode(socketMode)
+
 	// Validate the the minrelaytxfee.
 	cfg.minRelayTxFee, err = btcutil.NewAmount(cfg.MinRelayTxFee)
 	if err != nil {
@@ -907,11 +1084,11 @@ func loadConfig() (*config, []string, error) {
 
 	// Add default port to all rpc listener addresses if needed and remove
 	// duplicate addresses.
-	cfg.RPCListeners = normalizeAddresses(cfg.RPCListeners,
+	cfg.RPCListeners = normalizeRPCListeners(cfg.RPCListeners,
 		activeNetParams.rpcPort)
 
 	// Only allow TLS to be disabled if the RPC is bound to localhost
-	// addresses.
+	// addresses.  Unix domain sockets never use TLS since they are local.
 	if !cfg.DisableRPC && cfg.DisableTLS {
 		allowedTLSListeners := map[string]struct{}{
 			"localhost": {},
@@ -919,6 +1096,9 @@ func loadConfig() (*config, []string, error) {
 			"::1":       {},
 		}
 		for _, addr := range cfg.RPCListeners {
+			if _, ok := unixSocketPath(addr); ok {
+				continue
+			}
 			host, _, err := net.SplitHostPort(addr)
 			if err != nil {
 				str := "%s: RPC listen interface '%s' is " +
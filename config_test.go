@@ -4,6 +4,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
 	"testing"
@@ -70,3 +71,24 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestNormalizeRPCListeners ensures the default port is added to RPC listen
+// interfaces while Unix domain socket paths are left without a port.
+func TestNormalizeRPCListeners(t *testing.T) {
+	addrs := []string{
+		"127.0.0.1",
+		"unix:/tmp/btcd/../btcd/rpc.sock",
+		"[::1]:8337",
+		"127.0.0.1:8334",
+		"unix:/tmp/btcd/rpc.sock",
+	}
+	want := []string{
+		"127.0.0.1:8334",
+		"unix:" + filepath.Clean("/tmp/btcd/rpc.sock"),
+		"[::1]:8337",
+	}
+	got := normalizeRPCListeners(addrs, "8334")
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected listeners: got %q, want %q", got, want)
+	}
+}
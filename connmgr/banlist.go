@@ -0,0 +1,359 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// banListVersion is the current version of the serialized ban list.
+	banListVersion = 1
+)
+
+var (
+	// ErrAlreadyBanned is returned when attempting to ban a subnet that is
+	// already banned.
+	ErrAlreadyBanned = errors.New("subnet is already banned")
+
+	// ErrNotBanned is returned when attempting to unban a subnet that is
+	// not banned.
+	ErrNotBanned = errors.New("subnet is not banned")
+
+	// ErrBanned is returned when attempting to connect to a banned address.
+	ErrBanned = errors.New("address is banned")
+)
+
+// BanEntry describes a banned subnet.  Individual addresses are banned as a
+// subnet that only contains the address.
+type BanEntry struct {
+	// Subnet is the banned subnet.
+	Subnet *net.IPNet
+
+	// Created is the time the ban was created.
+	Created time.Time
+
+	// Until is the time the ban expires.
+	Until time.Time
+}
+
+// serializedBanEntry is the format of a ban entry in the ban list file.
+type serializedBanEntry struct {
+	Subnet  string `json:"subnet"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+}
+
+// serializedBanList is the format of the ban list file.
+type serializedBanList struct {
+	Version int                   `json:"version"`
+	Banned  []*serializedBanEntry `json:"banned"`
+}
+
+// BanList houses a set of banned subnets along with when their bans expire.
+// It is optionally persisted to a file so bans survive restarts.  Expired bans
+// are ignored by IsBanned and removed by Prune or when the ban list is next
+// modified.
+//
+// It is safe for concurrent access.
+type BanList struct {
+	mtx     sync.Mutex
+	path    string
+	entries map[string]*BanEntry
+}
+
+// NewBanList returns a new empty ban list that is persisted to the passed file
+// whenever it is modified.  The ban list is not persisted when the path is
+// empty.  Load must be called to load the bans from an existing file.
+func NewBanList(path string) *BanList {
+	return &BanList{
+		path:    path,
+		entries: make(map[string]*BanEntry),
+	}
+}
+
+// ParseSubnet parses the passed string as either a subnet in CIDR notation or
+// an individual IP address.  An individual address is returned as a subnet that
+// only contains the address.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	if strings.Contains(s, "/") {
+		_, subnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		return normalizeSubnet(subnet), nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address or subnet %q", s)
+	}
+	return HostSubnet(ip), nil
+}
+
+// HostSubnet returns a subnet that only contains the passed IP address.
+func HostSubnet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+}
+
+// normalizeSubnet returns the passed subnet with IPv4 subnets represented by
+// 4-byte addresses and masks so equivalent subnets have the same form.
+func normalizeSubnet(subnet *net.IPNet) *net.IPNet {
+	ones, bits := subnet.Mask.Size()
+	if ip4 := subnet.IP.To4(); ip4 != nil && bits == 128 && ones >= 96 {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(ones-96, 32)}
+	}
+	return &net.IPNet{IP: subnet.IP.Mask(subnet.Mask), Mask: subnet.Mask}
+}
+
+// addrIP returns the IP address of the passed network address or nil if it
+// does not have one, such as for onion addresses.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// Load replaces the bans in the ban list with those in its file.  A missing
+// file is not an error.
+func (b *BanList) Load() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.path == "" {
+		return nil
+	}
+	f, err := os.Open(b.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var sbl serializedBanList
+	if err := json.NewDecoder(f).Decode(&sbl); err != nil {
+		return fmt.Errorf("error reading %s: %v", b.path, err)
+	}
+	if sbl.Version > banListVersion {
+		return fmt.Errorf("unknown version %v in serialized ban list",
+			sbl.Version)
+	}
+
+	entries := make(map[string]*BanEntry, len(sbl.Banned))
+	for _, sbe := range sbl.Banned {
+		subnet, err := ParseSubnet(sbe.Subnet)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", b.path, err)
+		}
+		entries[subnet.String()] = &BanEntry{
+			Subnet:  subnet,
+			Created: time.Unix(sbe.Created, 0),
+			Until:   time.Unix(sbe.Until, 0),
+		}
+	}
+	b.entries = entries
+	b.pruneExpired()
+	log.Infof("Loaded %d banned %s from file '%s'", len(b.entries),
+		pickNoun(len(b.entries), "subnet", "subnets"), b.path)
+	return nil
+}
+
+// save removes the expired bans and writes the ban list to its file when it has
+// one.
+//
+// This function MUST be called with the ban list lock held.
+func (b *BanList) save() error {
+	b.pruneExpired()
+	if b.path == "" {
+		return nil
+	}
+
+	sbl := serializedBanList{
+		Version: banListVersion,
+		Banned:  make([]*serializedBanEntry, 0, len(b.entries)),
+	}
+	for _, entry := range b.entries {
+		sbl.Banned = append(sbl.Banned, &serializedBanEntry{
+			Subnet:  entry.Subnet.String(),
+			Created: entry.Created.Unix(),
+			Until:   entry.Until.Unix(),
+		})
+	}
+	sort.Slice(sbl.Banned, func(i, j int) bool {
+		return sbl.Banned[i].Subnet < sbl.Banned[j].Subnet
+	})
+
+	// Write to a temporary file first and rename it over the existing file
+	// so a crash while writing can't leave a partially written ban list.
+	tmpPath := b.path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(&sbl); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, b.path)
+}
+
+// pruneExpired removes the bans that have expired.  It returns whether or not
+// any bans were removed.
+//
+// This function MUST be called with the ban list lock held.
+func (b *BanList) pruneExpired() bool {
+	now := time.Now()
+	var pruned bool
+	for key, entry := range b.entries {
+		if !now.Before(entry.Until) {
+			log.Debugf("Ban on %v expired", entry.Subnet)
+			delete(b.entries, key)
+			pruned = true
+		}
+	}
+	return pruned
+}
+
+// Ban bans the passed subnet until the passed time and persists the ban list.
+// ErrAlreadyBanned is returned when the subnet is already banned.  The subnet
+// is not banned when the ban list can't be persisted.
+func (b *BanList) Ban(subnet *net.IPNet, until time.Time) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.pruneExpired()
+	subnet = normalizeSubnet(subnet)
+	key := subnet.String()
+	if _, ok := b.entries[key]; ok {
+		return ErrAlreadyBanned
+	}
+	b.entries[key] = &BanEntry{
+		Subnet:  subnet,
+		Created: time.Now(),
+		Until:   until,
+	}
+	if err := b.save(); err != nil {
+		delete(b.entries, key)
+		return err
+	}
+	return nil
+}
+
+// Unban removes the ban on the passed subnet and persists the ban list.  Only
+// a subnet that was banned as a whole can be unbanned.  ErrNotBanned is
+// returned when the subnet is not banned.  The ban remains in effect when the
+// ban list can't be persisted.
+func (b *BanList) Unban(subnet *net.IPNet) error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.pruneExpired()
+	key := normalizeSubnet(subnet).String()
+	entry, ok := b.entries[key]
+	if !ok {
+		return ErrNotBanned
+	}
+	delete(b.entries, key)
+	if err := b.save(); err != nil {
+		b.entries[key] = entry
+		return err
+	}
+	return nil
+}
+
+// Clear removes all bans and persists the ban list.  The bans remain in effect
+// when the ban list can't be persisted.
+func (b *BanList) Clear() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	entries := b.entries
+	b.entries = make(map[string]*BanEntry)
+	if err := b.save(); err != nil {
+		b.entries = entries
+		return err
+	}
+	return nil
+}
+
+// Prune removes the bans that have expired and persists the ban list when any
+// were removed.  It is intended to be called periodically so expired bans do
+// not accumulate.
+func (b *BanList) Prune() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if !b.pruneExpired() {
+		return nil
+	}
+	return b.save()
+}
+
+// IsBanned returns whether or not the passed IP address is contained in any of
+// the subnets with a ban that has not expired.  It never modifies the ban list
+// so it is cheap enough to call for every connection attempt.
+func (b *BanList) IsBanned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := time.Now()
+	for _, entry := range b.entries {
+		if now.Before(entry.Until) && entry.Subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsBannedAddr returns whether or not the IP address of the passed network
+// address is banned.  Addresses without an IP address, such as onion
+// addresses, are never banned.
+func (b *BanList) IsBannedAddr(addr net.Addr) bool {
+	return b.IsBanned(addrIP(addr))
+}
+
+// Entries returns the bans that have not expired ordered by subnet.
+func (b *BanList) Entries() []BanEntry {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.pruneExpired()
+	entries := make([]BanEntry, 0, len(b.entries))
+	for _, entry := range b.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Subnet.String() < entries[j].Subnet.String()
+	})
+	return entries
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures individual addresses and subnets are parsed into
+// their normalized subnet form.
+func TestParseSubnet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "1.2.3.4", want: "1.2.3.4/32"},
+		{in: "1.2.3.4/24", want: "1.2.3.0/24"},
+		{in: "::ffff:1.2.3.4", want: "1.2.3.4/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+		{in: "1.2.3.4/33", err: true},
+		{in: "example.com", err: true},
+	}
+
+	for _, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSubnet(%q): expected error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSubnet(%q): unexpected error: %v", test.in,
+				err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet(%q): got %v, want %v", test.in,
+				subnet, test.want)
+		}
+	}
+}
+
+// TestBanList ensures banning, unbanning, expiring and persisting bans works
+// as expected.
+func TestBanList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "banlist.json")
+
+	mustParse := func(s string) *net.IPNet {
+		subnet, err := ParseSubnet(s)
+		if err != nil {
+			t.Fatalf("ParseSubnet(%q) error: %v", s, err)
+		}
+		return subnet
+	}
+
+	bl := NewBanList(path)
+	until := time.Now().Add(time.Hour)
+	if err := bl.Ban(mustParse("10.0.0.0/8"), until); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+	if err := bl.Ban(mustParse("2001:db8::1"), until); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+	if err := bl.Ban(mustParse("10.0.0.0/8"), until); err != ErrAlreadyBanned {
+		t.Fatalf("Ban of banned subnet: got %v, want %v", err,
+			ErrAlreadyBanned)
+	}
+
+	// Expired bans must not be in effect.
+	expired := mustParse("192.168.0.1")
+	if err := bl.Ban(expired, time.Now().Add(-time.Second)); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+
+	isBanned := map[string]bool{
+		"10.1.2.3":    true,
+		"11.1.2.3":    false,
+		"2001:db8::1": true,
+		"2001:db8::2": false,
+		"192.168.0.1": false,
+	}
+	check := func(bl *BanList) {
+		t.Helper()
+		for ip, want := range isBanned {
+			if got := bl.IsBanned(net.ParseIP(ip)); got != want {
+				t.Fatalf("IsBanned(%s): got %v, want %v", ip, got,
+					want)
+			}
+		}
+		if got := len(bl.Entries()); got != 2 {
+			t.Fatalf("unexpected number of entries: got %d, want 2",
+				got)
+		}
+	}
+	check(bl)
+
+	// The bans must survive reloading the ban list.
+	bl = NewBanList(path)
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	check(bl)
+	entries := bl.Entries()
+	if entries[0].Subnet.String() != "10.0.0.0/8" ||
+		entries[0].Until.Unix() != until.Unix() {
+
+		t.Fatalf("unexpected first entry: %v until %v",
+			entries[0].Subnet, entries[0].Until)
+	}
+
+	// Bans which expire are ignored without modifying the ban list until
+	// it is pruned.
+	expiring := mustParse("172.16.0.0/12")
+	if err := bl.Ban(expiring, until); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+	bl.entries[expiring.String()].Until = time.Now().Add(-time.Second)
+	if bl.IsBanned(net.ParseIP("172.16.1.1")) {
+		t.Fatal("address is still banned after the ban expired")
+	}
+	if _, ok := bl.entries[expiring.String()]; !ok {
+		t.Fatal("IsBanned modified the ban list")
+	}
+	if err := bl.Prune(); err != nil {
+		t.Fatalf("Prune error: %v", err)
+	}
+	reloaded := NewBanList(path)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	check(reloaded)
+
+	// Only subnets that were banned as a whole can be unbanned.
+	if err := bl.Unban(mustParse("10.1.2.3")); err != ErrNotBanned {
+		t.Fatalf("Unban of unbanned subnet: got %v, want %v", err,
+			ErrNotBanned)
+	}
+	if err := bl.Unban(mustParse("10.0.0.0/8")); err != nil {
+		t.Fatalf("Unban error: %v", err)
+	}
+	if bl.IsBanned(net.ParseIP("10.1.2.3")) {
+		t.Fatal("address is still banned after unban")
+	}
+
+	if err := bl.Clear(); err != nil {
+		t.Fatalf("Clear error: %v", err)
+	}
+	bl = NewBanList(path)
+	if err := bl.Load(); err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if got := len(bl.Entries()); got != 0 {
+		t.Fatalf("unexpected number of entries after clear: got %d",
+			got)
+	}
+}
+
+// TestBanListSaveFailure ensures the bans are left unchanged when the ban list
+// can't be persisted.
+func TestBanListSaveFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "banlist")
+	if err != nil {
+		t.Fatalf("TempDir error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	subnet, err := ParseSubnet("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseSubnet error: %v", err)
+	}
+	ip := net.ParseIP("10.1.2.3")
+	until := time.Now().Add(time.Hour)
+
+	// Saving to a file in a directory that does not exist fails.
+	bl := NewBanList(filepath.Join(dir, "missing", "banlist.json"))
+	if err := bl.Ban(subnet, until); err == nil {
+		t.Fatal("Ban: expected save error")
+	}
+	if bl.IsBanned(ip) {
+		t.Fatal("subnet is banned after failing to save the ban")
+	}
+
+	bl.path = ""
+	if err := bl.Ban(subnet, until); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+	bl.path = filepath.Join(dir, "missing", "banlist.json")
+	if err := bl.Unban(subnet); err == nil {
+		t.Fatal("Unban: expected save error")
+	}
+	if !bl.IsBanned(ip) {
+		t.Fatal("subnet is not banned after failing to save the unban")
+	}
+	if err := bl.Clear(); err == nil {
+		t.Fatal("Clear: expected save error")
+	}
+	if !bl.IsBanned(ip) {
+		t.Fatal("subnet is not banned after failing to save the clear")
+	}
+}
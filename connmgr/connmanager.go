@@ -52,6 +52,32 @@ const (
 	ConnDisconnected
 )
 
+// ConnType describes the kind of an outbound connection.
+type ConnType uint8
+
+// ConnType can be either full relay or block-relay-only.  Full relay
+// connections relay blocks, transactions and addresses, while block-relay-only
+// connections never exchange transactions or addresses, which makes it harder
+// for an observer to infer the network topology.
+const (
+	ConnTypeFullRelay ConnType = iota
+	ConnTypeBlockRelayOnly
+)
+
+// Map of connection types back to their constant names for pretty printing.
+var connTypeStrings = map[ConnType]string{
+	ConnTypeFullRelay:      "outbound-full-relay",
+	ConnTypeBlockRelayOnly: "block-relay-only",
+}
+
+// String returns the ConnType in human-readable form.
+func (t ConnType) String() string {
+	if s, ok := connTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ConnType (%d)", uint8(t))
+}
+
 // ConnReq is the connection request to a network address. If permanent, the
 // connection will be retried on disconnection.
 type ConnReq struct {
@@ -61,6 +87,11 @@ type ConnReq struct {
 	Addr      net.Addr
 	Permanent bool
 
+	// Type is the kind of connection.  Connection requests made through
+	// NewConnReq are assigned a type by the connection manager in order
+	// to maintain the target number of connections of each type.
+	Type ConnType
+
 	conn       net.Conn
 	state      ConnState
 	stateMtx   sync.RWMutex
@@ -124,6 +155,11 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// network connections to maintain in addition to TargetOutbound.
+	// Defaults to 0.
+	TargetBlockRelayOnly uint32
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -140,8 +176,19 @@ type Config struct {
 	// to.  If nil, no new connections will be made automatically.
 	GetNewAddress func() (net.Addr, error)
 
+	// GetAnchors returns the addresses of block-relay-only peers, such as
+	// those that were connected during a previous run, to connect to when
+	// the connection manager is started.  They count toward
+	// TargetBlockRelayOnly.  It may be nil and it has no effect when
+	// GetNewAddress is nil.
+	GetAnchors func() []net.Addr
+
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
+
+	// BanList is the set of banned subnets.  Connections are neither made
+	// to nor accepted from addresses in a banned subnet.  It may be nil.
+	BanList *BanList
 }
 
 // registerPending is used to register a pending connection attempt. By
@@ -151,6 +198,10 @@ type Config struct {
 type registerPending struct {
 	c    *ConnReq
 	done chan struct{}
+
+	// assignType indicates the connection manager should assign the type
+	// of the connection request.
+	assignType bool
 }
 
 // handleConnected is used to queue a successful connection.
@@ -219,6 +270,36 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 	}
 }
 
+// targetConns returns the total number of outbound connections to maintain.
+func (cm *ConnManager) targetConns() uint32 {
+	return cm.cfg.TargetOutbound + cm.cfg.TargetBlockRelayOnly
+}
+
+// nextConnType returns the type to assign to a new automatic connection
+// request given the pending and established connection requests.  Requests are
+// block-relay-only until there are enough of them and full relay otherwise.
+func (cm *ConnManager) nextConnType(pending, conns map[uint64]*ConnReq) ConnType {
+	var numBlockRelayOnly uint32
+	countBlockRelayOnly := func(reqs map[uint64]*ConnReq) {
+		for _, c := range reqs {
+			if c.Permanent || c.Type != ConnTypeBlockRelayOnly {
+				continue
+			}
+			state := c.State()
+			if state == ConnPending || state == ConnEstablished {
+				numBlockRelayOnly++
+			}
+		}
+	}
+	countBlockRelayOnly(pending)
+	countBlockRelayOnly(conns)
+
+	if numBlockRelayOnly < cm.cfg.TargetBlockRelayOnly {
+		return ConnTypeBlockRelayOnly
+	}
+	return ConnTypeFullRelay
+}
+
 // connHandler handles all connection related requests.  It must be run as a
 // goroutine.
 //
@@ -244,6 +325,10 @@ out:
 
 			case registerPending:
 				connReq := msg.c
+				if msg.assignType {
+					connReq.Type = cm.nextConnType(pending,
+						conns)
+				}
 				connReq.updateState(ConnPending)
 				pending[msg.c.id] = connReq
 				close(msg.done)
@@ -322,7 +407,7 @@ out:
 				// re added to the pending map, so that
 				// subsequent processing of connections and
 				// failures do not ignore the request.
-				if uint32(len(conns)) < cm.cfg.TargetOutbound ||
+				if uint32(len(conns)) < cm.targetConns() ||
 					connReq.Permanent {
 
 					connReq.updateState(ConnPending)
@@ -375,7 +460,7 @@ func (cm *ConnManager) NewConnReq() {
 	// Remove method.
 	done := make(chan struct{})
 	select {
-	case cm.requests <- registerPending{c, done}:
+	case cm.requests <- registerPending{c: c, done: done, assignType: true}:
 	case <-cm.quit:
 		return
 	}
@@ -425,7 +510,7 @@ func (cm *ConnManager) Connect(c *ConnReq) {
 		// cancel the connection via the Remove method.
 		done := make(chan struct{})
 		select {
-		case cm.requests <- registerPending{c, done}:
+		case cm.requests <- registerPending{c: c, done: done}:
 		case <-cm.quit:
 			return
 		}
@@ -439,6 +524,16 @@ func (cm *ConnManager) Connect(c *ConnReq) {
 		}
 	}
 
+	// Refuse to connect to banned addresses.
+	if cm.cfg.BanList != nil && cm.cfg.BanList.IsBannedAddr(c.Addr) {
+		log.Debugf("Not connecting to banned address %v", c)
+		select {
+		case cm.requests <- handleFailed{c, ErrBanned}:
+		case <-cm.quit:
+		}
+		return
+	}
+
 	log.Debugf("Attempting to connect to %v", c)
 
 	conn, err := cm.cfg.Dial(c.Addr)
@@ -499,6 +594,16 @@ func (cm *ConnManager) listenHandler(listener net.Listener) {
 			}
 			continue
 		}
+
+		// Drop connections from banned addresses right away.
+		if cm.cfg.BanList != nil &&
+			cm.cfg.BanList.IsBannedAddr(conn.RemoteAddr()) {
+
+			log.Debugf("Rejecting connection from banned address %v",
+				conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
 		go cm.cfg.OnAccept(conn)
 	}
 
@@ -526,11 +631,44 @@ func (cm *ConnManager) Start() {
 		}
 	}
 
-	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
+	// Connect to the anchors first so they are accounted for before any
+	// automatic connection requests are assigned a type.
+	if cm.cfg.GetNewAddress != nil && cm.cfg.GetAnchors != nil {
+		cm.connectAnchors(cm.cfg.GetAnchors())
+	}
+
+	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.targetConns()); i++ {
 		go cm.NewConnReq()
 	}
 }
 
+// connectAnchors registers block-relay-only connection requests for up to
+// TargetBlockRelayOnly of the passed addresses and connects to them.
+func (cm *ConnManager) connectAnchors(anchors []net.Addr) {
+	if uint32(len(anchors)) > cm.cfg.TargetBlockRelayOnly {
+		anchors = anchors[:cm.cfg.TargetBlockRelayOnly]
+	}
+	for _, addr := range anchors {
+		c := &ConnReq{Addr: addr, Type: ConnTypeBlockRelayOnly}
+		atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
+
+		done := make(chan struct{})
+		select {
+		case cm.requests <- registerPending{c: c, done: done}:
+		case <-cm.quit:
+			return
+		}
+		select {
+		case <-done:
+		case <-cm.quit:
+			return
+		}
+
+		log.Debugf("Connecting to anchor %v", c)
+		go cm.Connect(c)
+	}
+}
+
 // Wait blocks until the connection manager halts gracefully.
 func (cm *ConnManager) Wait() {
 	cm.wg.Wait()
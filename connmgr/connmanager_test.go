@@ -216,6 +216,101 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests the target number of block-relay-only
+// outbound connections in addition to the target number of full relay ones.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(4)
+	targetBlockRelayOnly := uint32(2)
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	numConns := make(map[ConnType]uint32)
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		numConns[c.Type]++
+	}
+	if numConns[ConnTypeFullRelay] != targetOutbound {
+		t.Fatalf("block relay only: got %d full relay connections, "+
+			"want %d", numConns[ConnTypeFullRelay], targetOutbound)
+	}
+	if numConns[ConnTypeBlockRelayOnly] != targetBlockRelayOnly {
+		t.Fatalf("block relay only: got %d block-relay-only "+
+			"connections, want %d", numConns[ConnTypeBlockRelayOnly],
+			targetBlockRelayOnly)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("block relay only: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	cmgr.Stop()
+}
+
+// TestAnchors tests that the anchors are connected to as block-relay-only
+// connections when the connection manager is started and that they count
+// toward the target number of block-relay-only connections.
+func TestAnchors(t *testing.T) {
+	anchor := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 18555}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       1,
+		TargetBlockRelayOnly: 1,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		GetAnchors: func() []net.Addr {
+			return []net.Addr{anchor, anchor}
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	for i := 0; i < 2; i++ {
+		c := <-connected
+		isAnchor := c.Addr.String() == anchor.String()
+		if isAnchor != (c.Type == ConnTypeBlockRelayOnly) {
+			t.Fatalf("anchors: got %v connection to %v", c.Type,
+				c.Addr)
+		}
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("anchors: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -664,3 +759,83 @@ out:
 	cmgr.Stop()
 	cmgr.Wait()
 }
+
+// TestBannedConnections ensures the connection manager neither accepts
+// connections from nor dials addresses in a banned subnet.
+func TestBannedConnections(t *testing.T) {
+	banList := NewBanList("")
+	banned, err := ParseSubnet("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseSubnet error: %v", err)
+	}
+	if err := banList.Ban(banned, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Ban error: %v", err)
+	}
+
+	var dials uint32
+	dialer := func(addr net.Addr) (net.Conn, error) {
+		atomic.AddUint32(&dials, 1)
+		return mockDialer(addr)
+	}
+	receivedConns := make(chan net.Conn)
+	listener := newMockListener("127.0.0.1:8333")
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		Listeners: []net.Listener{listener},
+		OnAccept: func(conn net.Conn) {
+			receivedConns <- conn
+		},
+		Dial: dialer,
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+		BanList: banList,
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	// Only the connection from the address that is not banned must be
+	// accepted.
+	go func() {
+		listener.Connect("10.1.2.3", 8333)
+		listener.Connect("127.0.0.1", 8333)
+	}()
+	select {
+	case conn := <-receivedConns:
+		if got := conn.RemoteAddr().String(); got != "127.0.0.1:8333" {
+			t.Fatalf("accepted unexpected connection from %v", got)
+		}
+	case <-time.After(time.Millisecond * 50):
+		t.Fatal("timeout waiting for accepted connection")
+	}
+
+	// Only the connection to the address that is not banned must be
+	// dialed.
+	go cmgr.Connect(&ConnReq{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 8333},
+	})
+	go cmgr.Connect(&ConnReq{
+		Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 8333},
+	})
+	select {
+	case c := <-connected:
+		if got := c.Addr.String(); got != "127.0.0.1:8333" {
+			t.Fatalf("connected to unexpected address %v", got)
+		}
+	case <-time.After(time.Millisecond * 50):
+		t.Fatal("timeout waiting for outbound connection")
+	}
+	select {
+	case c := <-connected:
+		t.Fatalf("connected to unexpected address %v", c.Addr)
+	case <-time.After(time.Millisecond * 20):
+	}
+	if got := atomic.LoadUint32(&dials); got != 1 {
+		t.Fatalf("unexpected number of dials: got %d, want 1", got)
+	}
+
+	cmgr.Stop()
+	cmgr.Wait()
+}
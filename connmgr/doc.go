@@ -10,5 +10,15 @@ Connection Manager Overview
 Connection Manager handles all the general connection concerns such as
 maintaining a set number of outbound connections, sourcing peers, banning,
 limiting max connections, tor lookup, etc.
+
+Outbound connections are either full relay or block-relay-only.  In addition to
+the target number of full relay connections, a target number of
+block-relay-only connections, which the caller must not exchange transactions
+or addresses over, is maintained.  Anchors, such as the block-relay-only peers
+of a previous run, are connected to first when the manager is started.
+
+A BanList holds banned subnets, optionally persisted to a file so the bans
+survive restarts.  When one is provided, the connection manager neither dials
+nor accepts connections from addresses within a banned subnet.
 */
 package connmgr
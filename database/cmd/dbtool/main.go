@@ -9,6 +9,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/database"
 	"github.com/btcsuite/btclog"
 	flags "github.com/jessevdk/go-flags"
@@ -24,12 +26,16 @@ var (
 	shutdownChannel = make(chan error)
 )
 
-// loadBlockDB opens the block database and returns a handle to it.
-func loadBlockDB() (database.DB, error) {
+// blockDbPath returns the path to the block database.
+func blockDbPath() string {
 	// The database name is based on the database type.
 	dbName := blockDbNamePrefix + "_" + cfg.DbType
-	dbPath := filepath.Join(cfg.DataDir, dbName)
+	return filepath.Join(cfg.DataDir, dbName)
+}
 
+// loadBlockDB opens the block database and returns a handle to it.
+func loadBlockDB() (database.DB, error) {
+	dbPath := blockDbPath()
 	log.Infof("Loading block database from '%s'", dbPath)
 	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
 	if err != nil {
@@ -66,6 +72,8 @@ func realMain() error {
 	dbLog := backendLogger.Logger("BCDB")
 	dbLog.SetLevel(btclog.LevelDebug)
 	database.UseLogger(dbLog)
+	blockchain.UseLogger(backendLogger.Logger("CHAN"))
+	indexers.UseLogger(backendLogger.Logger("INDX"))
 
 	// Setup the parser options and commands.
 	appName := filepath.Base(os.Args[0])
@@ -88,6 +96,23 @@ func realMain() error {
 	parser.AddCommand("fetchblockregion",
 		"Fetch the specified block region from the database", "",
 		&blockRegionCfg)
+	parser.AddCommand("verify",
+		"Verify the consistency of the chain state in the database",
+		"Verify the consistency of the chain state in the database.  "+
+			"The block index, the main chain, the stored block "+
+			"data, the spend journal, and the tip of each "+
+			"optional index are cross-checked and the utxo set is "+
+			"compared against a full replay of the main chain.",
+		&verifyCfg)
+	parser.AddCommand("repair",
+		"Truncate the chain state to the last consistent block",
+		"Verify the consistency of the chain state in the database "+
+			"and truncate the main chain to the last consistent "+
+			"block when inconsistencies are found.  The utxo set "+
+			"is rebuilt by replaying the main chain unless it is "+
+			"consistent, which might take a long time, and any "+
+			"optional index whose tip is no longer in the main "+
+			"chain is dropped.", &repairCfg)
 
 	// Parse command line and invoke the Execute function for the specified
 	// command.
@@ -0,0 +1,78 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+)
+
+// repairCmd defines the configuration options for the repair command.
+type repairCmd struct{}
+
+var (
+	// repairCfg defines the configuration options for the command.
+	repairCfg = repairCmd{}
+)
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *repairCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Load the block database.
+	db, interrupt, err := loadExistingBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := blockchain.RepairDB(db, activeNetParams, interrupt)
+	if err != nil {
+		return err
+	}
+	for i := range result.Verify.Inconsistencies {
+		log.Warnf("%v", &result.Verify.Inconsistencies[i])
+	}
+	if !result.Verify.Consistent() {
+		log.Infof("Truncated the main chain to block %v (height %d) "+
+			"and removed %d blocks from the block index",
+			result.TipHash, result.TipHeight, result.RemovedBlocks)
+	}
+	if result.RebuiltUtxoSet {
+		log.Infof("Rebuilt the utxo set")
+	}
+
+	// Drop the optional indexes whose tip is not in the repaired main
+	// chain since they can't be rolled back without the data of the
+	// blocks that were removed.
+	tips, err := indexers.FetchIndexTips(db)
+	if err != nil {
+		return err
+	}
+	var numDropped int
+	for i := range tips {
+		tip := &tips[i]
+		problem := indexTipProblem(tip, result.Verify, result.TipHeight)
+		if problem == "" {
+			continue
+		}
+
+		log.Warnf("%s: %s", tip.Name, problem)
+		if err := tip.Drop(db, interrupt); err != nil {
+			return err
+		}
+		log.Infof("The %s is created again on the next start when "+
+			"it is enabled", tip.Name)
+		numDropped++
+	}
+
+	if result.Verify.Consistent() && numDropped == 0 {
+		log.Infof("The database is consistent -- nothing to repair")
+	}
+	return nil
+}
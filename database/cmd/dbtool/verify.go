@@ -0,0 +1,120 @@
+// Copyright (c) 2015-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockchain/indexers"
+	"github.com/btcsuite/btcd/database"
+)
+
+// verifyCmd defines the configuration options for the verify command.
+type verifyCmd struct{}
+
+var (
+	// verifyCfg defines the configuration options for the command.
+	verifyCfg = verifyCmd{}
+)
+
+// loadExistingBlockDB opens the block database and returns a handle to it
+// along with a channel that is closed on Ctrl+C.  Unlike loadBlockDB, it does
+// not create the database when it does not exist.
+func loadExistingBlockDB() (database.DB, <-chan struct{}, error) {
+	if !fileExists(blockDbPath()) {
+		str := "The block database [%v] does not exist"
+		return nil, nil, fmt.Errorf(str, blockDbPath())
+	}
+	db, err := loadBlockDB()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interrupt := make(chan struct{})
+	addInterruptHandler(func() {
+		close(interrupt)
+	})
+	return db, interrupt, nil
+}
+
+// indexTipProblem returns a description of the reason the tip of the passed
+// optional index is inconsistent with the main chain up to the passed height
+// of the verified chain state.  It returns an empty string when the tip is
+// consistent.
+func indexTipProblem(tip *indexers.IndexTip, result *blockchain.DBVerifyResult, tipHeight int32) string {
+	switch {
+	case tip.Err != nil:
+		return tip.Err.Error()
+
+	// The index does not have any entries yet.
+	case tip.Height == -1:
+		return ""
+
+	case tip.Height > tipHeight:
+		return fmt.Sprintf("tip %v (height %d) is above the best "+
+			"block", tip.Hash, tip.Height)
+	}
+
+	hash, ok := result.MainChainHash(tip.Height)
+	if !ok || *hash != tip.Hash {
+		return fmt.Sprintf("tip %v (height %d) is not in the main "+
+			"chain", tip.Hash, tip.Height)
+	}
+	return ""
+}
+
+// Execute is the main entry point for the command.  It's invoked by the parser.
+func (cmd *verifyCmd) Execute(args []string) error {
+	// Setup the global config options and ensure they are valid.
+	if err := setupGlobalConfig(); err != nil {
+		return err
+	}
+
+	// Load the block database.
+	db, interrupt, err := loadExistingBlockDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result, err := blockchain.VerifyDB(db, activeNetParams, interrupt)
+	if err != nil {
+		return err
+	}
+	for i := range result.Inconsistencies {
+		log.Warnf("%v", &result.Inconsistencies[i])
+	}
+	numInconsistent := len(result.Inconsistencies)
+
+	// Ensure the tips of the optional indexes are in the main chain.
+	tips, err := indexers.FetchIndexTips(db)
+	if err != nil {
+		return err
+	}
+	for i := range tips {
+		tip := &tips[i]
+		problem := indexTipProblem(tip, result, result.TipHeight)
+		if problem != "" {
+			log.Warnf("%s: %s", tip.Name, problem)
+			numInconsistent++
+		}
+	}
+
+	if !result.UtxoSetChecked {
+		log.Warnf("The utxo set was not compared against the main " +
+			"chain since it can't be replayed")
+	}
+	if numInconsistent != 0 {
+		return fmt.Errorf("found %d inconsistencies -- use the repair "+
+			"command to truncate the main chain to the last "+
+			"consistent block at height %d", numInconsistent,
+			result.ConsistentHeight)
+	}
+
+	log.Infof("The database is consistent (best block %v, height %d, "+
+		"%d utxos)", result.TipHash, result.TipHeight, result.NumUtxos)
+	return nil
+}
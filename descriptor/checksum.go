@@ -0,0 +1,128 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// ChecksumLength is the number of characters of a descriptor checksum.
+	ChecksumLength = 8
+
+	// inputCharset is the set of characters a descriptor may contain,
+	// ordered such that the checksum of a descriptor is unaffected by the
+	// case of hex characters.
+	inputCharset = "0123456789()[],'/*abcdefgh@:$%{}" +
+		"IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~" +
+		"ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+
+	// checksumCharset is the set of characters used to encode the
+	// checksum of a descriptor.
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// polyMod computes the BCH checksum polynomial used by descriptors over the
+// passed symbols.
+func polyMod(symbols []uint64) uint64 {
+	generator := [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d,
+		0x3706b1677a, 0x644d626ffd}
+	chk := uint64(1)
+	for _, value := range symbols {
+		top := chk >> 35
+		chk = (chk&0x7ffffffff)<<5 ^ value
+		for i := uint(0); i < 5; i++ {
+			if (top>>i)&1 != 0 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// Checksum returns the checksum of the passed descriptor as defined by
+// BIP0380.  Any checksum the descriptor already has is not part of the
+// calculation, so this returns the checksum the descriptor is expected to have.
+// An error is returned when the descriptor contains characters that are not
+// allowed.
+func Checksum(desc string) (string, error) {
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		desc = desc[:i]
+	}
+
+	symbols := make([]uint64, 0, len(desc)+len(desc)/3+ChecksumLength+1)
+	groups := make([]uint64, 0, 3)
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos < 0 {
+			return "", fmt.Errorf("invalid character %q in descriptor",
+				desc[i])
+		}
+		symbols = append(symbols, uint64(pos&31))
+		groups = append(groups, uint64(pos>>5))
+		if len(groups) == 3 {
+			symbols = append(symbols, groups[0]*9+groups[1]*3+groups[2])
+			groups = groups[:0]
+		}
+	}
+	switch len(groups) {
+	case 1:
+		symbols = append(symbols, groups[0])
+	case 2:
+		symbols = append(symbols, groups[0]*3+groups[1])
+	}
+	symbols = append(symbols, make([]uint64, ChecksumLength)...)
+
+	chk := polyMod(symbols) ^ 1
+	checksum := make([]byte, ChecksumLength)
+	for i := range checksum {
+		shift := 5 * (ChecksumLength - 1 - uint(i))
+		checksum[i] = checksumCharset[(chk>>shift)&31]
+	}
+	return string(checksum), nil
+}
+
+// AddChecksum returns the passed descriptor with its checksum appended.  Any
+// checksum the descriptor already has is replaced.
+func AddChecksum(desc string) (string, error) {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	if i := strings.IndexByte(desc, '#'); i >= 0 {
+		desc = desc[:i]
+	}
+	return desc + "#" + checksum, nil
+}
+
+// splitChecksum splits the passed descriptor into the descriptor without its
+// checksum and the checksum, after ensuring the checksum is valid.  The
+// returned checksum is empty when the descriptor has none, which is an error
+// when a checksum is required.
+func splitChecksum(desc string, requireChecksum bool) (string, string, error) {
+	i := strings.IndexByte(desc, '#')
+	if i < 0 {
+		if requireChecksum {
+			return "", "", fmt.Errorf("missing checksum")
+		}
+		return desc, "", nil
+	}
+
+	desc, checksum := desc[:i], desc[i+1:]
+	if len(checksum) != ChecksumLength {
+		return "", "", fmt.Errorf("expected %d character checksum, "+
+			"not %d characters", ChecksumLength, len(checksum))
+	}
+	want, err := Checksum(desc)
+	if err != nil {
+		return "", "", err
+	}
+	if checksum != want {
+		return "", "", fmt.Errorf("provided checksum %q does not "+
+			"match computed checksum %q", checksum, want)
+	}
+	return desc, checksum, nil
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import "testing"
+
+// TestChecksum ensures descriptor checksums are calculated as defined by
+// BIP0380.
+func TestChecksum(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		checksum string
+		valid    bool
+	}{
+		{"raw(deadbeef)", "89f8spxm", true},
+		{"raw(deadbeef)#00000000", "89f8spxm", true},
+		{"sh(multi(2,[00000000/111'/222]xprvA1RpRA33e1JQ7ifknakTFpgNXPmW" +
+			"2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8" +
+			"HFsTjSyQbLYnMpCqE2VbFWc,xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxX" +
+			"eJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFce" +
+			"UvJFjaPdGZ2y9WACViL4L/0))", "ggrsrxfy", true},
+		{"raw(deadbeef)\n", "", false},
+	}
+
+	for i, test := range tests {
+		checksum, err := Checksum(test.desc)
+		if (err == nil) != test.valid {
+			t.Errorf("Test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if checksum != test.checksum {
+			t.Errorf("Test #%d: unexpected checksum - got %s, want %s",
+				i, checksum, test.checksum)
+		}
+	}
+}
+
+// TestSplitChecksum ensures the checksum of a descriptor is verified and
+// required as requested.
+func TestSplitChecksum(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		require  bool
+		checksum string
+		valid    bool
+	}{
+		{"raw(deadbeef)#89f8spxm", true, "89f8spxm", true},
+		{"raw(deadbeef)", false, "", true},
+		{"raw(deadbeef)", true, "", false},
+		{"raw(deadbeef)#", false, "", false},
+		{"raw(deadbeef)#89f8spx", false, "", false},
+		{"raw(deadbeef)#89f8spxmx", false, "", false},
+		{"raw(deadbeef)#89f8spxn", false, "", false},
+		{"raw(deedbeef)#89f8spxm", false, "", false},
+		{"raw(deadbeef)##9f8spxm", false, "", false},
+	}
+
+	for i, test := range tests {
+		desc, checksum, err := splitChecksum(test.desc, test.require)
+		if (err == nil) != test.valid {
+			t.Errorf("Test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if desc != "raw(deadbeef)" || checksum != test.checksum {
+			t.Errorf("Test #%d: unexpected result - got %q and %q",
+				i, desc, checksum)
+		}
+	}
+
+	// Ensure adding a checksum replaces an existing one.
+	desc, err := AddChecksum("raw(deadbeef)#00000000")
+	if err != nil || desc != "raw(deadbeef)#89f8spxm" {
+		t.Fatalf("AddChecksum: unexpected result %q (%v)", desc, err)
+	}
+}
@@ -0,0 +1,534 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// maxBareMultiSigKeys is the maximum number of keys of a multi or
+	// sortedmulti descriptor that is not nested in sh or wsh.
+	maxBareMultiSigKeys = 3
+
+	// maxP2SHMultiSigKeys is the maximum number of keys of a multi or
+	// sortedmulti descriptor nested in sh.
+	maxP2SHMultiSigKeys = 15
+
+	// maxWitnessMultiSigKeys is the maximum number of keys of a multi or
+	// sortedmulti descriptor nested in wsh.
+	maxWitnessMultiSigKeys = 20
+
+	// maxRedeemScriptSize is the maximum size of the redeem script of a
+	// pay-to-script-hash output that can be spent.
+	maxRedeemScriptSize = 520
+)
+
+// ErrNoAddress indicates an output described by a descriptor does not have a
+// corresponding address.
+var ErrNoAddress = errors.New("output does not have an address")
+
+// scriptContext identifies what a descriptor function is nested in, which
+// restricts the functions and keys that may be used.
+type scriptContext int
+
+const (
+	contextTop scriptContext = iota
+	contextP2SH
+	contextP2WSH
+)
+
+// node is a parsed descriptor function along with its arguments.  Which of the
+// fields are set depends on the function.
+type node struct {
+	fn        string
+	threshold int
+	keys      []*keyExpr
+	sub       *node
+	addr      btcutil.Address
+	script    []byte
+}
+
+// Descriptor is a parsed output script descriptor as defined by BIP0380 and
+// the BIPs building upon it.
+type Descriptor struct {
+	root   *node
+	params *chaincfg.Params
+}
+
+// Output describes a single output script of a descriptor.
+type Output struct {
+	// PkScript is the output script.
+	PkScript []byte
+
+	// RedeemScript is the redeem script of pay-to-script-hash outputs.
+	RedeemScript []byte
+
+	// WitnessScript is the witness script of pay-to-witness-script-hash
+	// outputs, including those nested in pay-to-script-hash outputs.
+	WitnessScript []byte
+
+	// Keys are the public keys involved in the output along with their
+	// origin.
+	Keys []Key
+
+	// Descriptor is the descriptor of only this output, which has all keys
+	// derived and includes its checksum.
+	Descriptor string
+}
+
+// Address returns the address that pays to the output.  ErrNoAddress is
+// returned when the output is not a pay-to-pubkey-hash, pay-to-script-hash,
+// or version 0 witness output.
+func (o *Output) Address(params *chaincfg.Params) (btcutil.Address, error) {
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(o.PkScript, params)
+	if err != nil || len(addrs) != 1 {
+		return nil, ErrNoAddress
+	}
+	switch class {
+	case txscript.PubKeyHashTy, txscript.ScriptHashTy,
+		txscript.WitnessV0PubKeyHashTy, txscript.WitnessV0ScriptHashTy:
+
+		return addrs[0], nil
+	}
+	return nil, ErrNoAddress
+}
+
+// splitArgs splits the arguments of a descriptor function at the commas that
+// are not nested in another function.
+func splitArgs(args string) []string {
+	var split []string
+	var depth, start int
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				split = append(split, args[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(split, args[start:])
+}
+
+// parseNode parses the passed descriptor function nested in the provided
+// context.
+func parseNode(expr string, ctx scriptContext, params *chaincfg.Params) (*node, error) {
+	open := strings.IndexByte(expr, '(')
+	if open < 0 || !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("%q is not a valid descriptor function",
+			expr)
+	}
+	n := &node{fn: expr[:open]}
+	args := expr[open+1 : len(expr)-1]
+
+	// Keys nested in wsh must be compressed.
+	compressed := ctx == contextP2WSH
+
+	switch n.fn {
+	case "pk", "pkh":
+		key, err := parseKey(args, compressed, params)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+
+	case "wpkh":
+		if ctx == contextP2WSH {
+			return nil, errors.New("can only have wpkh() at top " +
+				"level or inside sh()")
+		}
+		key, err := parseKey(args, true, params)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+
+	case "combo":
+		if ctx != contextTop {
+			return nil, errors.New("can only have combo() at top level")
+		}
+		key, err := parseKey(args, false, params)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*keyExpr{key}
+
+	case "multi", "sortedmulti":
+		split := splitArgs(args)
+		threshold, err := strconv.Atoi(split[0])
+		if err != nil {
+			return nil, fmt.Errorf("multi threshold %q is not valid",
+				split[0])
+		}
+		for _, arg := range split[1:] {
+			key, err := parseKey(arg, compressed, params)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key)
+		}
+		if threshold < 1 || threshold > len(n.keys) {
+			return nil, fmt.Errorf("multisig threshold cannot be %d, "+
+				"must be at least 1 and at most %d", threshold,
+				len(n.keys))
+		}
+		maxKeys := maxBareMultiSigKeys
+		switch ctx {
+		case contextP2SH:
+			maxKeys = maxP2SHMultiSigKeys
+		case contextP2WSH:
+			maxKeys = maxWitnessMultiSigKeys
+		}
+		if len(n.keys) > maxKeys {
+			return nil, fmt.Errorf("cannot have %d keys in multisig, "+
+				"at most %d are allowed", len(n.keys), maxKeys)
+		}
+		n.threshold = threshold
+
+	case "sh":
+		if ctx != contextTop {
+			return nil, errors.New("can only have sh() at top level")
+		}
+		sub, err := parseNode(args, contextP2SH, params)
+		if err != nil {
+			return nil, err
+		}
+		n.sub = sub
+
+	case "wsh":
+		if ctx == contextP2WSH {
+			return nil, errors.New("can only have wsh() at top " +
+				"level or inside sh()")
+		}
+		sub, err := parseNode(args, contextP2WSH, params)
+		if err != nil {
+			return nil, err
+		}
+		n.sub = sub
+
+	case "addr":
+		if ctx != contextTop {
+			return nil, errors.New("can only have addr() at top level")
+		}
+		addr, err := btcutil.DecodeAddress(args, params)
+		if err != nil || !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %q is not valid", args)
+		}
+		n.addr = addr
+
+	case "raw":
+		if ctx != contextTop {
+			return nil, errors.New("can only have raw() at top level")
+		}
+		script, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, fmt.Errorf("raw script %q is not hex", args)
+		}
+		n.script = script
+
+	case "tr":
+		return nil, errors.New("tr() descriptors are not supported")
+
+	default:
+		return nil, fmt.Errorf("%q is not a valid descriptor function",
+			n.fn)
+	}
+	return n, nil
+}
+
+// Parse parses the passed descriptor for the provided network.  The checksum
+// of the descriptor is verified when present and an error is returned when it
+// is missing while required.
+func Parse(desc string, requireChecksum bool, params *chaincfg.Params) (*Descriptor, error) {
+	desc, _, err := splitChecksum(desc, requireChecksum)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := Checksum(desc); err != nil {
+		return nil, err
+	}
+	root, err := parseNode(desc, contextTop, params)
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{root: root, params: params}, nil
+}
+
+// String returns the descriptor in canonical form along with its checksum.
+// Private keys are replaced by their public keys.
+func (d *Descriptor) String() string {
+	// Descriptors that were parsed only contain valid characters.
+	desc, _ := AddChecksum(d.root.String(false))
+	return desc
+}
+
+// IsRange returns whether the descriptor describes a range of outputs, which
+// is the case when it contains extended keys with a wildcard.
+func (d *Descriptor) IsRange() bool {
+	return d.root.any(func(k *keyExpr) bool { return k.isRange() })
+}
+
+// IsSolvable returns whether the descriptor contains the information that is
+// required to spend its outputs other than private keys and signatures, which
+// is not the case for addr and raw descriptors.
+func (d *Descriptor) IsSolvable() bool {
+	return d.root.fn != "addr" && d.root.fn != "raw"
+}
+
+// HasPrivateKeys returns whether the descriptor contains private keys.
+func (d *Descriptor) HasPrivateKeys() bool {
+	return d.root.any(func(k *keyExpr) bool { return k.isPrivate() })
+}
+
+// Expand returns the outputs the descriptor describes at the passed index of
+// its range.  The index is ignored for descriptors that are not ranged.
+func (d *Descriptor) Expand(index uint32) ([]Output, error) {
+	derived, err := d.root.derive(index)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := derived.expand(d.params)
+	if err != nil {
+		return nil, err
+	}
+	for i := range outputs {
+		if outputs[i].Descriptor == "" {
+			outputs[i].Descriptor, _ = AddChecksum(derived.String(false))
+		}
+	}
+	return outputs, nil
+}
+
+// any returns whether the passed function returns true for any key of the
+// node or the nodes nested in it.
+func (n *node) any(fn func(*keyExpr) bool) bool {
+	for _, key := range n.keys {
+		if fn(key) {
+			return true
+		}
+	}
+	return n.sub != nil && n.sub.any(fn)
+}
+
+// String returns the node in the format used by descriptors.  Private keys are
+// replaced by their public keys unless the private flag is set.
+func (n *node) String(private bool) string {
+	var args []string
+	switch {
+	case n.sub != nil:
+		args = append(args, n.sub.String(private))
+	case n.addr != nil:
+		args = append(args, n.addr.EncodeAddress())
+	case n.script != nil:
+		args = append(args, hex.EncodeToString(n.script))
+	}
+	if n.fn == "multi" || n.fn == "sortedmulti" {
+		args = append(args, strconv.Itoa(n.threshold))
+	}
+	for _, key := range n.keys {
+		args = append(args, key.String(private))
+	}
+	return n.fn + "(" + strings.Join(args, ",") + ")"
+}
+
+// derive returns a copy of the node with all keys derived at the passed index
+// of a range.
+func (n *node) derive(index uint32) (*node, error) {
+	derived := *n
+	derived.keys = make([]*keyExpr, len(n.keys))
+	for i, key := range n.keys {
+		var err error
+		derived.keys[i], err = key.derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n.sub != nil {
+		var err error
+		derived.sub, err = n.sub.derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &derived, nil
+}
+
+// expand returns the outputs described by a node with derived keys.
+func (n *node) expand(params *chaincfg.Params) ([]Output, error) {
+	keys := make([]Key, len(n.keys))
+	for i, key := range n.keys {
+		keys[i] = key.key()
+	}
+
+	switch n.fn {
+	case "pk":
+		script, err := payToPubKeyScript(keys[0].PubKey)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{PkScript: script, Keys: keys}}, nil
+
+	case "pkh":
+		script, err := payToPubKeyHashScript(keys[0].PubKey, params)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{PkScript: script, Keys: keys}}, nil
+
+	case "wpkh":
+		script, err := payToWitnessPubKeyHashScript(keys[0].PubKey, params)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{PkScript: script, Keys: keys}}, nil
+
+	case "combo":
+		return n.expandCombo(params)
+
+	case "multi", "sortedmulti":
+		if n.fn == "sortedmulti" {
+			sort.Slice(keys, func(i, j int) bool {
+				return bytes.Compare(keys[i].PubKey,
+					keys[j].PubKey) < 0
+			})
+		}
+		pubKeys := make([]*btcutil.AddressPubKey, len(keys))
+		for i, key := range keys {
+			var err error
+			pubKeys[i], err = btcutil.NewAddressPubKey(key.PubKey,
+				params)
+			if err != nil {
+				return nil, err
+			}
+		}
+		script, err := txscript.MultiSigScript(pubKeys, n.threshold)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{PkScript: script, Keys: keys}}, nil
+
+	case "sh":
+		outputs, err := n.sub.expand(params)
+		if err != nil {
+			return nil, err
+		}
+		output := outputs[0]
+		if len(output.PkScript) > maxRedeemScriptSize {
+			return nil, fmt.Errorf("redeem script of %d bytes exceeds "+
+				"the maximum of %d bytes", len(output.PkScript),
+				maxRedeemScriptSize)
+		}
+		addr, err := btcutil.NewAddressScriptHash(output.PkScript, params)
+		if err != nil {
+			return nil, err
+		}
+		output.RedeemScript = output.PkScript
+		output.PkScript, err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{output}, nil
+
+	case "wsh":
+		outputs, err := n.sub.expand(params)
+		if err != nil {
+			return nil, err
+		}
+		output := outputs[0]
+		scriptHash := sha256.Sum256(output.PkScript)
+		addr, err := btcutil.NewAddressWitnessScriptHash(scriptHash[:],
+			params)
+		if err != nil {
+			return nil, err
+		}
+		output.WitnessScript = output.PkScript
+		output.PkScript, err = txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{output}, nil
+
+	case "addr":
+		script, err := txscript.PayToAddrScript(n.addr)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{PkScript: script}}, nil
+	}
+
+	// The only remaining function is raw.
+	return []Output{{PkScript: n.script}}, nil
+}
+
+// expandCombo returns the outputs described by a combo node with a derived key,
+// which are the outputs of the pk, pkh, and for compressed keys also wpkh and
+// sh(wpkh) descriptors of the key.
+func (n *node) expandCombo(params *chaincfg.Params) ([]Output, error) {
+	key := n.keys[0]
+	fns := []string{"pk", "pkh"}
+	if len(key.pubKey) == btcec.PubKeyBytesLenCompressed {
+		fns = append(fns, "wpkh", "sh")
+	}
+
+	var outputs []Output
+	for _, fn := range fns {
+		sub := &node{fn: fn, keys: n.keys}
+		if fn == "sh" {
+			sub = &node{fn: fn, sub: &node{fn: "wpkh", keys: n.keys}}
+		}
+		expanded, err := sub.expand(params)
+		if err != nil {
+			return nil, err
+		}
+		expanded[0].Descriptor, _ = AddChecksum(sub.String(false))
+		outputs = append(outputs, expanded...)
+	}
+	return outputs, nil
+}
+
+// payToPubKeyScript returns a script that pays to the passed serialized public
+// key.
+func payToPubKeyScript(pubKey []byte) ([]byte, error) {
+	return txscript.NewScriptBuilder().AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).Script()
+}
+
+// payToPubKeyHashScript returns a script that pays to the hash of the passed
+// serialized public key.
+func payToPubKeyHashScript(pubKey []byte, params *chaincfg.Params) ([]byte, error) {
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(pubKey), params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
+
+// payToWitnessPubKeyHashScript returns a version 0 witness script that pays to
+// the hash of the passed serialized public key.
+func payToWitnessPubKeyHashScript(pubKey []byte, params *chaincfg.Params) ([]byte, error) {
+	addr, err := btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey),
+		params)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.PayToAddrScript(addr)
+}
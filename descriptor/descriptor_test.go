@@ -0,0 +1,468 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+const (
+	// testPubKey is the secp256k1 generator point as public key, whose
+	// private key is testWIF.
+	testPubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b" +
+		"16f81798"
+	testWIF = "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+
+	// testUncompressedPubKey is the uncompressed form of testPubKey.
+	testUncompressedPubKey = "0479be667ef9dcbbac55a06295ce870b07029bfcdb2d" +
+		"ce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b44" +
+		"8a68554199c47d08ffb10d4b8"
+
+	// testXprv and testXpub are the master keys of BIP0032 test vector 1.
+	testXprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPP" +
+		"qjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	testXpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhe" +
+		"PY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected. It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// TestParse ensures descriptors are parsed as expected, that they are
+// formatted in canonical form, and that invalid descriptors are rejected.
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		desc       string
+		canonical  string
+		isRange    bool
+		solvable   bool
+		hasPrivate bool
+		valid      bool
+	}{
+		{
+			name:      "pk",
+			desc:      "pk(" + testPubKey + ")",
+			canonical: "pk(" + testPubKey + ")#gn28ywm7",
+			solvable:  true,
+			valid:     true,
+		},
+		{
+			name:       "private key in sh(wpkh)",
+			desc:       "sh(wpkh(" + testWIF + "))",
+			canonical:  "sh(wpkh(" + testPubKey + "))#jqtwwlah",
+			solvable:   true,
+			hasPrivate: true,
+			valid:      true,
+		},
+		{
+			name: "ranged private extended key with h notation",
+			desc: "pkh(" + testXprv + "/0h/1/2h/*h)",
+			canonical: "pkh(" + testXpub + "/0'/1/2'/*')#" +
+				mustChecksum("pkh("+testXpub+"/0'/1/2'/*')"),
+			isRange:    true,
+			solvable:   true,
+			hasPrivate: true,
+			valid:      true,
+		},
+		{
+			name: "sortedmulti in wsh",
+			desc: "wsh(sortedmulti(1,[d34db33f/48'/0'/0'/2']" +
+				testXpub + "/0/*," + testPubKey + "))",
+			canonical: "wsh(sortedmulti(1,[d34db33f/48'/0'/0'/2']" +
+				testXpub + "/0/*," + testPubKey + "))#" +
+				mustChecksum("wsh(sortedmulti(1,[d34db33f/48'/0'/0'/2']"+
+					testXpub+"/0/*,"+testPubKey+"))"),
+			isRange:  true,
+			solvable: true,
+			valid:    true,
+		},
+		{
+			name:      "addr",
+			desc:      "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)#45hf9yxk",
+			canonical: "addr(1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH)#45hf9yxk",
+			valid:     true,
+		},
+		{
+			name:      "raw with uppercase hex",
+			desc:      "raw(DEADBEEF)",
+			canonical: "raw(deadbeef)#89f8spxm",
+			valid:     true,
+		},
+		{
+			name: "uncompressed key in wpkh",
+			desc: "wpkh(" + testUncompressedPubKey + ")",
+		},
+		{
+			name: "uncompressed key in wsh",
+			desc: "wsh(pk(" + testUncompressedPubKey + "))",
+		},
+		{
+			name: "nested sh",
+			desc: "sh(sh(pk(" + testPubKey + ")))",
+		},
+		{
+			name: "wsh in wsh",
+			desc: "wsh(wsh(pk(" + testPubKey + ")))",
+		},
+		{
+			name: "wpkh in wsh",
+			desc: "wsh(wpkh(" + testPubKey + "))",
+		},
+		{
+			name: "nested combo",
+			desc: "sh(combo(" + testPubKey + "))",
+		},
+		{
+			name: "too many keys in bare multi",
+			desc: "multi(1," + testPubKey + "," + testPubKey + "," +
+				testPubKey + "," + testPubKey + ")",
+		},
+		{
+			name: "multi threshold too high",
+			desc: "multi(3," + testPubKey + "," + testPubKey + ")",
+		},
+		{
+			name: "multi threshold zero",
+			desc: "multi(0," + testPubKey + ")",
+		},
+		{
+			name: "hardened derivation from public key",
+			desc: "pkh(" + testXpub + "/0'/*)",
+		},
+		{
+			name: "hardened wildcard from public key",
+			desc: "pkh(" + testXpub + "/*')",
+		},
+		{
+			name: "extended key for other network",
+			desc: "pkh(tpubD6NzVbkrYhZ4XgiXtGrdW5XDAPFCL9h7we1vwNCpn8tGb" +
+				"BcgfVYjXyhWo4E1xkh56hjod1RhGjxbaTLV3X4FyWuejifB9jusQ46" +
+				"QzG87VKp/0/*)",
+		},
+		{
+			name: "address for other network",
+			desc: "addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)",
+		},
+		{
+			name: "multiple key origins",
+			desc: "pkh([d34db33f][d34db33f]" + testPubKey + ")",
+		},
+		{
+			name: "bad key origin fingerprint",
+			desc: "pkh([d34db33]" + testPubKey + ")",
+		},
+		{
+			name: "bad checksum",
+			desc: "pk(" + testPubKey + ")#gn28ywm8",
+		},
+		{
+			name: "unknown function",
+			desc: "foo(" + testPubKey + ")",
+		},
+		{
+			name: "taproot",
+			desc: "tr(" + testPubKey + ")",
+		},
+	}
+
+	params := &chaincfg.MainNetParams
+	for _, test := range tests {
+		desc, err := Parse(test.desc, false, params)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !test.valid {
+			continue
+		}
+		if got := desc.String(); got != test.canonical {
+			t.Errorf("%s: unexpected canonical form - got %s, want %s",
+				test.name, got, test.canonical)
+		}
+		if desc.IsRange() != test.isRange {
+			t.Errorf("%s: unexpected range flag %v", test.name,
+				desc.IsRange())
+		}
+		if desc.IsSolvable() != test.solvable {
+			t.Errorf("%s: unexpected solvable flag %v", test.name,
+				desc.IsSolvable())
+		}
+		if desc.HasPrivateKeys() != test.hasPrivate {
+			t.Errorf("%s: unexpected private keys flag %v", test.name,
+				desc.HasPrivateKeys())
+		}
+
+		// The canonical form must parse to itself unless private keys
+		// were required for hardened derivation.
+		if test.hasPrivate {
+			continue
+		}
+		reparsed, err := Parse(test.canonical, true, params)
+		if err != nil || reparsed.String() != test.canonical {
+			t.Errorf("%s: canonical form does not round trip: %v",
+				test.name, err)
+		}
+	}
+}
+
+// mustChecksum returns the checksum of the passed descriptor and will panic if
+// there is an error.  It must only be called with hard-coded values.
+func mustChecksum(desc string) string {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		panic(err)
+	}
+	return checksum
+}
+
+// TestExpand ensures descriptors expand to the expected scripts, keys, and
+// addresses.
+func TestExpand(t *testing.T) {
+	t.Parallel()
+
+	type output struct {
+		pkScript      string
+		redeemScript  string
+		witnessScript string
+		addr          string
+		descriptor    string
+	}
+	tests := []struct {
+		name    string
+		desc    string
+		index   uint32
+		outputs []output
+	}{
+		{
+			name: "wpkh",
+			desc: "wpkh(02f9308a019258c31049344f85f89d5229b531c845836f9" +
+				"9b08601f113bce036f9)",
+			outputs: []output{{
+				pkScript: "00147dd65592d0ab2fe0d0257d571abf032cd9db93dc",
+				addr:     "bc1q0ht9tyks4vh7p5p904t340cr9nvahy7u3re7zg",
+			}},
+		},
+		{
+			name: "sh(wpkh)",
+			desc: "sh(wpkh(03fff97bd5755eeea420453a14355235d382f6472f8" +
+				"568a18b2f057a1460297556))",
+			outputs: []output{{
+				pkScript:     "a914cc6ffbc0bf31af759451068f90ba7a0272b6b33287",
+				redeemScript: "00147fda9cf020c16cacf529c87d8de89bfc70b8c9cb",
+				addr:         "3LKyvRN6SmYXGBNn8fcQvYxW9MGKtwcinN",
+			}},
+		},
+		{
+			name: "pkh with private key and origin",
+			desc: "pkh([deadbeef/1/2'/3/4']L4rK1yDtCWekvXuE6oXD9jCYfFNV" +
+				"2cWRpVuPLBcCU2z8TrisoyY1)",
+			outputs: []output{{
+				pkScript: "76a9149a1c78a507689f6f54b847ad1cef1e614ee23f1e88ac",
+				addr:     "1F3sAm6ZtwLAUnj7d38pGFxtP3RVEvtsbV",
+				descriptor: "pkh([deadbeef/1/2'/3/4']03a34b99f22c790c4e36" +
+					"b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+			}},
+		},
+		{
+			name: "sh(wsh(pkh))",
+			desc: "sh(wsh(pkh(02e493dbf1c10d80f3581e4904930b1404cc6c139" +
+				"00ee0758474fa94abe8c4cd13)))",
+			outputs: []output{{
+				pkScript: "a91455e8d5e8ee4f3604aba23c71c2684fa0a56a3a1287",
+				redeemScript: "0020fc5acc302aab97f821f9a61e1cc572e7968a" +
+					"603551e95d4ba12b51df6581482f",
+				witnessScript: "76a914c42e7ef92fdb603af844d064faad95db9b" +
+					"cdfd3d88ac",
+				addr: "39XGHYpYmJV9sGFoGHZeU2rLkY6r1MJ6C1",
+			}},
+		},
+		{
+			name:  "ranged extended key",
+			desc:  "pkh(" + testXprv + "/0'/1/2'/*)",
+			index: 2,
+			outputs: []output{{
+				pkScript: "76a914" + hex.EncodeToString(hash160(
+					"02e8445082a72f29b75ca48748a914df60622a609c"+
+						"acfce8ed0e35804560741d29")) + "88ac",
+				addr: "1LjmJcdPnDHhNTUgrWyhLGnRDKxQjoxAgt",
+				descriptor: "pkh([3442193e/0'/1/2'/2]02e8445082a72f29b7" +
+					"5ca48748a914df60622a609cacfce8ed0e35804560741d29)",
+			}},
+		},
+		{
+			name: "bare sortedmulti",
+			desc: "sortedmulti(1," + testPubKey + ",022f8bde4d1a0720935" +
+				"5b4a7250a5c5128e88b84bddc619ab7cba8d569b240efe4)",
+			outputs: []output{{
+				pkScript: "5121022f8bde4d1a07209355b4a7250a5c5128e88b84" +
+					"bddc619ab7cba8d569b240efe4210279be667ef9dcbbac55a0" +
+					"6295ce870b07029bfcdb2dce28d959f2815b16f8179852ae",
+			}},
+		},
+		{
+			name: "combo",
+			desc: "combo(" + testPubKey + ")",
+			outputs: []output{{
+				pkScript:   "21" + testPubKey + "ac",
+				descriptor: "pk(" + testPubKey + ")",
+			}, {
+				pkScript:   "76a914751e76e8199196d454941c45d1b3a323f1433bd688ac",
+				addr:       "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+				descriptor: "pkh(" + testPubKey + ")",
+			}, {
+				pkScript:   "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+				addr:       "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+				descriptor: "wpkh(" + testPubKey + ")",
+			}, {
+				pkScript:     "a914bcfeb728b584253d5f3f70bcb780e9ef218a68f487",
+				redeemScript: "0014751e76e8199196d454941c45d1b3a323f1433bd6",
+				addr:         "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+				descriptor:   "sh(wpkh(" + testPubKey + "))",
+			}},
+		},
+		{
+			name: "combo with uncompressed key",
+			desc: "combo(" + testUncompressedPubKey + ")",
+			outputs: []output{{
+				pkScript: "41" + testUncompressedPubKey + "ac",
+			}, {
+				pkScript: "76a91491b24bf9f5288532960ac687abb035127b1d28a588ac",
+				addr:     "1EHNa6Q4Jz2uvNExL497mE43ikXhwF6kZm",
+			}},
+		},
+	}
+
+	params := &chaincfg.MainNetParams
+	for _, test := range tests {
+		desc, err := Parse(test.desc, false, params)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		outputs, err := desc.Expand(test.index)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if len(outputs) != len(test.outputs) {
+			t.Errorf("%s: unexpected number of outputs - got %d, "+
+				"want %d", test.name, len(outputs),
+				len(test.outputs))
+			continue
+		}
+		for i, want := range test.outputs {
+			got := &outputs[i]
+			if !bytes.Equal(got.PkScript, hexToBytes(want.pkScript)) ||
+				!bytes.Equal(got.RedeemScript, hexToBytes(want.redeemScript)) ||
+				!bytes.Equal(got.WitnessScript, hexToBytes(want.witnessScript)) {
+
+				t.Errorf("%s: unexpected scripts of output %d - got "+
+					"%x, %x, %x", test.name, i, got.PkScript,
+					got.RedeemScript, got.WitnessScript)
+			}
+
+			addr, err := got.Address(params)
+			switch {
+			case want.addr == "" && err != ErrNoAddress:
+				t.Errorf("%s: unexpected address of output %d: %v "+
+					"(%v)", test.name, i, addr, err)
+			case want.addr != "" && (err != nil ||
+				addr.EncodeAddress() != want.addr):
+
+				t.Errorf("%s: unexpected address of output %d - got "+
+					"%v (%v), want %s", test.name, i, addr, err,
+					want.addr)
+			}
+
+			if want.descriptor != "" {
+				wantDesc := want.descriptor + "#" +
+					mustChecksum(want.descriptor)
+				if got.Descriptor != wantDesc {
+					t.Errorf("%s: unexpected descriptor of output "+
+						"%d - got %s, want %s", test.name, i,
+						got.Descriptor, wantDesc)
+				}
+			}
+		}
+	}
+}
+
+// hash160 returns the hash160 of the passed hex-encoded data.
+func hash160(s string) []byte {
+	return btcutil.Hash160(hexToBytes(s))
+}
+
+// TestExpandKeys ensures the keys of expanded outputs carry their full origin
+// and that derivation retains leading zeros of private keys as demonstrated
+// by BIP0032 test vector 4.
+func TestExpandKeys(t *testing.T) {
+	t.Parallel()
+
+	// The master key of BIP0032 test vector 4 along with the public key of
+	// m/0'/1'.
+	const master = "xprv9s21ZrQH143K48vGoLGRPxgo2JNkJ3J3fqkirQC2zVdk5Dgd5" +
+		"w14S7fRDyHH4dWNHUgkvsvNDCkvAwcSHNAQwhwgNMgZhLtQC63zxwhQmRv"
+	const child = "xpub6BJA1jSqiukeaesWfxe6sNK9CCGaujFFSJLomWHprUL9DePQ4" +
+		"JDkM5d88n49sMGJxrhpjazuXYWdMf17C9T5XnxkopaeS7jGk1GyyVziaMt"
+	childKey, err := hdkeychain.NewKeyFromString(child)
+	if err != nil {
+		t.Fatalf("NewKeyFromString: unexpected error: %v", err)
+	}
+	childPubKey, err := childKey.ECPubKey()
+	if err != nil {
+		t.Fatalf("ECPubKey: unexpected error: %v", err)
+	}
+
+	params := &chaincfg.MainNetParams
+	desc, err := Parse("wsh(multi(1,[01020304/9]"+testPubKey+","+master+
+		"/0'/*'))", false, params)
+	if err != nil {
+		t.Fatalf("Parse: unexpected error: %v", err)
+	}
+	outputs, err := desc.Expand(1)
+	if err != nil {
+		t.Fatalf("Expand: unexpected error: %v", err)
+	}
+	if len(outputs) != 1 || len(outputs[0].Keys) != 2 {
+		t.Fatalf("Expand: unexpected outputs %v", outputs)
+	}
+
+	keys := outputs[0].Keys
+	if !bytes.Equal(keys[0].PubKey, hexToBytes(testPubKey)) ||
+		keys[0].Origin.String() != "01020304/9" {
+
+		t.Errorf("unexpected first key %x with origin %s",
+			keys[0].PubKey, keys[0].Origin.String())
+	}
+	if !bytes.Equal(keys[1].PubKey, childPubKey.SerializeCompressed()) ||
+		keys[1].Origin.String() != "ad85d955/0'/1'" {
+
+		t.Errorf("unexpected second key %x with origin %s",
+			keys[1].PubKey, keys[1].Origin.String())
+	}
+
+	// Ensure indices beyond the range of unhardened indices are rejected.
+	if _, err := desc.Expand(hdkeychain.HardenedKeyStart); err == nil {
+		t.Errorf("Expand: did not reject out of range index")
+	}
+}
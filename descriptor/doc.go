@@ -0,0 +1,32 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package descriptor implements parsing and expansion of output script
+descriptors as defined by BIP0380 through BIP0386.
+
+A descriptor describes a set of output scripts along with the information
+needed to spend them, such as:
+
+	wpkh([d34db33f/84'/0'/0']xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL/0/*)#cjjspncu
+
+The following descriptor functions are supported:
+
+  - pk(KEY), pkh(KEY) and wpkh(KEY) for single key outputs
+  - multi(k,KEY,...) and sortedmulti(k,KEY,...) for multisig outputs
+  - sh(SCRIPT) and wsh(SCRIPT) for outputs paying to the hash of a script
+  - combo(KEY) for all of the single key outputs of a key
+  - addr(ADDR) and raw(HEX) for outputs given by an address or script
+
+Keys are hex-encoded public keys, private keys in wallet import format, or
+extended keys followed by a derivation path, optionally ending with a wildcard
+that makes the descriptor describe a range of outputs.  Any key may be preceded
+by its origin in brackets.  Taproot descriptors are not supported since the
+txscript package does not support taproot.
+
+Parse verifies the checksum of a descriptor when present, while Checksum and
+AddChecksum calculate it.  Expand derives the outputs of a descriptor at an
+index of its range.
+*/
+package descriptor
@@ -0,0 +1,360 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package descriptor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// KeyOrigin describes where a key comes from by the fingerprint of the master
+// key it was derived from and the BIP0032 derivation path from the master key
+// to the key.  Hardened path elements have hdkeychain.HardenedKeyStart added.
+type KeyOrigin struct {
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// String returns the key origin in the format used by descriptors without the
+// surrounding brackets, such as d34db33f/44'/0'/0'.
+func (o *KeyOrigin) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x", o.Fingerprint)
+	for _, index := range o.Path {
+		b.WriteString("/")
+		b.WriteString(formatPathElement(index))
+	}
+	return b.String()
+}
+
+// Key describes a public key that is part of a script derived from a
+// descriptor along with where the key comes from.
+type Key struct {
+	PubKey []byte
+	Origin KeyOrigin
+}
+
+// wildcard identifies whether and how the final step of the derivation path of
+// an extended key expression is a wildcard.
+type wildcard int
+
+const (
+	noWildcard wildcard = iota
+	unhardenedWildcard
+	hardenedWildcard
+)
+
+// keyExpr is a parsed key expression of a descriptor.  Exactly one of pubKey,
+// wif, and extKey is set.
+type keyExpr struct {
+	origin *KeyOrigin
+
+	// pubKey is the serialized public key of a hex-encoded key expression.
+	pubKey []byte
+
+	// wif is the private key of a key expression in wallet import format.
+	wif *btcutil.WIF
+
+	// extKey is the extended key of an extended key expression followed
+	// by the derivation path and wildcard.  base is the result of deriving
+	// the path from extKey, which the wildcard is derived from.
+	extKey   *hdkeychain.ExtendedKey
+	path     []uint32
+	wildcard wildcard
+	base     *hdkeychain.ExtendedKey
+}
+
+// formatPathElement returns the passed BIP0032 path element as it appears in
+// descriptors.
+func formatPathElement(index uint32) string {
+	if index >= hdkeychain.HardenedKeyStart {
+		return strconv.FormatUint(uint64(index-hdkeychain.HardenedKeyStart),
+			10) + "'"
+	}
+	return strconv.FormatUint(uint64(index), 10)
+}
+
+// parsePathElement parses a single BIP0032 path element which is hardened when
+// followed by ' or h.
+func parsePathElement(elem string) (uint32, error) {
+	var hardened bool
+	if strings.HasSuffix(elem, "'") || strings.HasSuffix(elem, "h") {
+		elem = elem[:len(elem)-1]
+		hardened = true
+	}
+	index, err := strconv.ParseUint(elem, 10, 32)
+	if err != nil || index >= hdkeychain.HardenedKeyStart ||
+		(len(elem) > 1 && elem[0] == '0') {
+
+		return 0, fmt.Errorf("key path value %q is out of range", elem)
+	}
+	if hardened {
+		index += hdkeychain.HardenedKeyStart
+	}
+	return uint32(index), nil
+}
+
+// fingerprint returns the BIP0032 fingerprint of the passed serialized public
+// key.
+func fingerprint(pubKey []byte) uint32 {
+	return binary.BigEndian.Uint32(btcutil.Hash160(pubKey)[:4])
+}
+
+// deriveChild returns the passed child of the provided extended key.
+//
+// Unlike the hdkeychain package, this retains the leading zero bytes of
+// derived private keys when deriving further hardened children from them as
+// required by BIP0032.  Serializing the key pads it accordingly, so the
+// derived key is serialized and parsed again to achieve this.
+func deriveChild(key *hdkeychain.ExtendedKey, index uint32) (*hdkeychain.ExtendedKey, error) {
+	child, err := key.Child(index)
+	if err != nil {
+		return nil, err
+	}
+	if !child.IsPrivate() {
+		return child, nil
+	}
+	return hdkeychain.NewKeyFromString(child.String())
+}
+
+// parseKeyOrigin parses the key origin of a key expression without the
+// surrounding brackets.
+func parseKeyOrigin(origin string) (*KeyOrigin, error) {
+	elems := strings.Split(origin, "/")
+	fp, err := hex.DecodeString(elems[0])
+	if err != nil || len(fp) != 4 {
+		return nil, fmt.Errorf("fingerprint %q is not 4 bytes", elems[0])
+	}
+	keyOrigin := &KeyOrigin{Fingerprint: binary.BigEndian.Uint32(fp)}
+	for _, elem := range elems[1:] {
+		index, err := parsePathElement(elem)
+		if err != nil {
+			return nil, err
+		}
+		keyOrigin.Path = append(keyOrigin.Path, index)
+	}
+	return keyOrigin, nil
+}
+
+// parseKey parses the passed key expression.  Uncompressed public keys are
+// rejected when the compressed flag is set.
+func parseKey(expr string, compressed bool, params *chaincfg.Params) (*keyExpr, error) {
+	key := new(keyExpr)
+	if strings.HasPrefix(expr, "[") {
+		end := strings.IndexByte(expr, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("key origin start '[' character " +
+				"without corresponding end ']'")
+		}
+		origin, err := parseKeyOrigin(expr[1:end])
+		if err != nil {
+			return nil, err
+		}
+		key.origin = origin
+		expr = expr[end+1:]
+	}
+	if strings.ContainsAny(expr, "[]") {
+		return nil, errors.New("multiple key origins")
+	}
+
+	elems := strings.Split(expr, "/")
+	if len(elems) == 1 {
+		if pubKey, err := hex.DecodeString(expr); err == nil {
+			_, err := btcec.ParsePubKey(pubKey, btcec.S256())
+			if err != nil {
+				return nil, fmt.Errorf("pubkey %q is invalid",
+					expr)
+			}
+			if compressed &&
+				len(pubKey) != btcec.PubKeyBytesLenCompressed {
+
+				return nil, errors.New("uncompressed keys " +
+					"are not allowed")
+			}
+			key.pubKey = pubKey
+			return key, nil
+		}
+
+		if wif, err := btcutil.DecodeWIF(expr); err == nil {
+			if !wif.IsForNet(params) {
+				return nil, fmt.Errorf("private key is not "+
+					"for %s", params.Name)
+			}
+			if compressed && !wif.CompressPubKey {
+				return nil, errors.New("uncompressed keys " +
+					"are not allowed")
+			}
+			key.wif = wif
+			return key, nil
+		}
+	}
+
+	extKey, err := hdkeychain.NewKeyFromString(elems[0])
+	if err != nil {
+		return nil, fmt.Errorf("key %q is not valid", elems[0])
+	}
+	if !extKey.IsForNet(params) {
+		return nil, fmt.Errorf("extended key %q is not for %s",
+			elems[0], params.Name)
+	}
+	key.extKey = extKey
+	for i, elem := range elems[1:] {
+		if i == len(elems)-2 {
+			switch elem {
+			case "*":
+				key.wildcard = unhardenedWildcard
+				continue
+			case "*'", "*h":
+				key.wildcard = hardenedWildcard
+				continue
+			}
+		}
+		index, err := parsePathElement(elem)
+		if err != nil {
+			return nil, err
+		}
+		key.path = append(key.path, index)
+	}
+	if key.wildcard == hardenedWildcard && !extKey.IsPrivate() {
+		return nil, errors.New("hardened derivation requires a " +
+			"private extended key")
+	}
+
+	key.base = extKey
+	for _, index := range key.path {
+		key.base, err = deriveChild(key.base, index)
+		if err == hdkeychain.ErrDeriveHardFromPublic {
+			return nil, errors.New("hardened derivation requires " +
+				"a private extended key")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// isRange returns whether the key expression ends with a wildcard.
+func (k *keyExpr) isRange() bool {
+	return k.wildcard != noWildcard
+}
+
+// isPrivate returns whether the key expression contains a private key.
+func (k *keyExpr) isPrivate() bool {
+	return k.wif != nil || (k.extKey != nil && k.extKey.IsPrivate())
+}
+
+// String returns the key expression in the format used by descriptors.
+// Private keys are replaced by their public keys unless the private flag is
+// set.
+func (k *keyExpr) String(private bool) string {
+	var b strings.Builder
+	if k.origin != nil {
+		b.WriteString("[" + k.origin.String() + "]")
+	}
+
+	switch {
+	case k.pubKey != nil:
+		b.WriteString(hex.EncodeToString(k.pubKey))
+
+	case k.wif != nil:
+		if private {
+			b.WriteString(k.wif.String())
+		} else {
+			b.WriteString(hex.EncodeToString(k.wif.SerializePubKey()))
+		}
+
+	default:
+		extKey := k.extKey
+		if !private && extKey.IsPrivate() {
+			// Neutering only fails for unknown networks which
+			// were already rejected when the key was parsed.
+			extKey, _ = extKey.Neuter()
+		}
+		b.WriteString(extKey.String())
+		for _, index := range k.path {
+			b.WriteString("/" + formatPathElement(index))
+		}
+		switch k.wildcard {
+		case unhardenedWildcard:
+			b.WriteString("/*")
+		case hardenedWildcard:
+			b.WriteString("/*'")
+		}
+	}
+	return b.String()
+}
+
+// derive returns the key expression of the public key the key expression
+// refers to at the passed index of a range along with its full origin.  The
+// index is ignored when the key expression does not end with a wildcard.
+func (k *keyExpr) derive(index uint32) (*keyExpr, error) {
+	if k.extKey == nil {
+		pubKey := k.pubKey
+		if k.wif != nil {
+			pubKey = k.wif.SerializePubKey()
+		}
+		return &keyExpr{origin: k.origin, pubKey: pubKey}, nil
+	}
+
+	// The origin of a derived key extends the origin of the extended key,
+	// which is the extended key itself when it has none.
+	origin := new(KeyOrigin)
+	if k.origin != nil {
+		origin.Fingerprint = k.origin.Fingerprint
+		origin.Path = append(origin.Path, k.origin.Path...)
+	} else {
+		pubKey, err := k.extKey.ECPubKey()
+		if err != nil {
+			return nil, err
+		}
+		origin.Fingerprint = fingerprint(pubKey.SerializeCompressed())
+	}
+	origin.Path = append(origin.Path, k.path...)
+
+	derived := k.base
+	switch k.wildcard {
+	case unhardenedWildcard, hardenedWildcard:
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, fmt.Errorf("index %d is out of range", index)
+		}
+		if k.wildcard == hardenedWildcard {
+			index += hdkeychain.HardenedKeyStart
+		}
+		var err error
+		derived, err = deriveChild(derived, index)
+		if err != nil {
+			return nil, err
+		}
+		origin.Path = append(origin.Path, index)
+	}
+
+	pubKey, err := derived.ECPubKey()
+	if err != nil {
+		return nil, err
+	}
+	return &keyExpr{origin: origin, pubKey: pubKey.SerializeCompressed()}, nil
+}
+
+// key returns the public key of a key expression that was derived along with
+// its origin.  Keys without an origin are their own master key.
+func (k *keyExpr) key() Key {
+	if k.origin != nil {
+		return Key{PubKey: k.pubKey, Origin: *k.origin}
+	}
+	return Key{
+		PubKey: k.pubKey,
+		Origin: KeyOrigin{Fingerprint: fingerprint(k.pubKey)},
+	}
+}
@@ -73,11 +73,32 @@ Application Options:
       --listen=               Add an interface/port to listen for connections
                               (default all interfaces port: 8333, testnet:
                               18333)
+      --loadblock=            Import blocks on startup from the specified
+                              bootstrap.dat style file or directory containing
+                              the blk*.dat files of Bitcoin Core, which may be
+                              out of order and obfuscated with the key in
+                              xor.dat
       --logdir=               Directory to log output
+      --logformat=            Format of the log output {text, json} -- The json
+                              format writes one JSON object per line with the
+                              peer address, block hash, height, and txid as
+                              separate fields when available (default: text)
+      --logmaxrolls=          Maximum number of rotated log files to keep
+                              (default: 3)
+      --logmaxsize=           Maximum size in MiB of the log file before it is
+                              rotated (default: 10)
       --maxorphantx=          Max number of orphan transactions to keep in
                               memory (default: 100)
       --maxpeers=             Max number of inbound and outbound peers
                               (default: 125)
+      --maxuploadtarget=      Try to keep the data sent to peers under the given
+                              target in MiB per 24 hours by no longer serving
+                              historical blocks once it is about to be reached
+                              -- NOTE: Whitelisted peers are not affected, and 0
+                              means no limit
+      --metricslisten=        Serve Prometheus metrics over HTTP on the given
+                              interface/port (eg. 127.0.0.1:9332) -- NOTE: The
+                              metrics are not authenticated
       --miningaddr=           Add the specified payment address to the list of
                               addresses to use for generated blocks -- At least
                               one address is required if the generate option is
@@ -99,8 +120,8 @@ Application Options:
                               have high priority for relaying
       --norpc                 Disable built-in RPC server -- NOTE: The RPC
                               server is disabled by default if no
-                              rpcuser/rpcpass or rpclimituser/rpclimitpass is
-                              specified
+                              rpcuser/rpcpass, rpclimituser/rpclimitpass or
+                              rpcauth is specified
       --notls                 Disable TLS for the RPC server -- NOTE: This is
                               only allowed if the RPC server is bound to
                               localhost
@@ -118,26 +139,53 @@ Application Options:
                               the default settings for the active network.
       --relaynonstd           Relay non-standard transactions regardless of the
                               default settings for the active network.
+      --restlisten=           Add an interface/port to listen for
+                              unauthenticated read-only REST requests over
+                              HTTP (eg. 127.0.0.1:8080) -- NOTE: The REST
+                              interface is disabled unless a listen address is
+                              specified
+      --rpcauth=              Add a username and hashed password for RPC
+                              connections in the form <user>:<salt>$<hash> --
+                              NOTE: The genrpcauth utility can be used to
+                              generate these
       --rpccert=              File containing the certificate file
       --rpckey=               File containing the certificate key
       --rpclimitpass=         Password for limited RPC connections
       --rpclimituser=         Username for limited RPC connections
-      --rpclisten=            Add an interface/port to listen for RPC
+      --rpclisten=            Add an interface/port or a Unix domain socket
+                              (eg. unix:/path/to/socket) to listen for RPC
                               connections (default port: 8334, testnet: 18334)
+                              -- NOTE: Unix domain sockets do not use TLS
+      --rpcmaxbatchsize=      Max number of requests allowed in a single
+                              JSON-RPC batch request (default: 1000)
       --rpcmaxclients=        Max number of RPC clients for standard
                               connections (default: 10)
       --rpcmaxconcurrentreqs= Max number of concurrent RPC requests that may be
                               processed concurrently (default: 20)
       --rpcmaxwebsockets=     Max number of RPC websocket connections (default:
                               25)
+      --rpcunixnoauth         Grant full access without authentication to RPC
+                              clients connected over a Unix domain socket that
+                              do not supply credentials -- NOTE: Access is then
+                              only restricted by the file mode of the socket
+      --rpcunixsocketmode=    File mode of RPC Unix domain sockets in octal
+                              (default: 0600)
       --rpcquirks             Mirror some JSON-RPC quirks of Bitcoin Core --
                               NOTE: Discouraged unless interoperability issues
                               need to be worked around
   -P, --rpcpass=              Password for RPC connections
   -u, --rpcuser=              Username for RPC connections
+      --rpcwhitelist=         Restrict an RPC user to the given methods in the
+                              form <user>:<method>,<method>,... -- NOTE:
+                              Multiple whitelists for the same user allow only
+                              the methods in all of them
       --sigcachemaxsize=      The maximum number of entries in the signature
                               verification cache (default: 100000)
       --simnet                Use the simulation test network
+      --syncstalltimeout=     How long the sync peer may go without making
+                              progress before another peer is chosen to sync
+                              from.  Valid time units are {s, m, h}.  Minimum
+                              1 second (default: 3m0s)
       --testnet               Use the test network
       --torisolation          Enable Tor stream isolation by randomizing user
                               credentials for each connection.
@@ -152,6 +200,20 @@ Application Options:
   -V, --version               Display version information and exit
       --whitelist=            Add an IP network or IP that will not be banned.
                               (eg. 192.168.1.0/24 or ::1)
+      --zmqpubhashblock=      Publish the hashes of connected blocks on the given
+                              ZeroMQ endpoint (eg. tcp://127.0.0.1:28332 or
+                              ipc:///path/to/socket)
+      --zmqpubhashtx=         Publish the hashes of transactions accepted into
+                              the mempool or included in connected blocks on the
+                              given ZeroMQ endpoint
+      --zmqpubrawblock=       Publish connected blocks on the given ZeroMQ
+                              endpoint
+      --zmqpubrawtx=          Publish transactions accepted into the mempool or
+                              included in connected blocks on the given ZeroMQ
+                              endpoint
+      --zmqpubsequence=       Publish block connect and disconnect and mempool
+                              accept and removal events on the given ZeroMQ
+                              endpoint
 
 Help Options:
   -h, --help           Show this help message
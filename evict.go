@@ -0,0 +1,327 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/addrmgr"
+)
+
+const (
+	// evictProtectNetGroup is the number of inbound peers from distinct
+	// network groups, chosen by a keyed hash of their network group, that
+	// are protected from eviction.  Since the key is not known to an
+	// attacker, they can't choose which groups are protected.
+	evictProtectNetGroup = 4
+
+	// evictProtectPing is the number of inbound peers with the lowest ping
+	// times that are protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectTx is the number of inbound peers that most recently
+	// sent a transaction that was accepted to the mempool that are
+	// protected from eviction.
+	evictProtectTx = 4
+
+	// evictProtectBlockRelay is the maximum number of inbound peers that
+	// do not relay transactions and most recently sent a new block that
+	// are protected from eviction.
+	evictProtectBlockRelay = 8
+
+	// evictProtectBlock is the number of inbound peers that most recently
+	// sent a new block that are protected from eviction.
+	evictProtectBlock = 4
+
+	// staleTipCheckInterval is the interval at which the server checks
+	// whether the best chain tip has gone stale and whether an extra
+	// outbound peer should be evicted.
+	staleTipCheckInterval = time.Second * 45
+
+	// staleTipBlocks is the number of expected block intervals without a
+	// change to the best chain tip after which the tip is considered stale
+	// and an extra outbound peer is connected.
+	staleTipBlocks = 3
+
+	// minOutboundEvictAge is the minimum amount of time an outbound peer
+	// must be connected before it is considered for eviction in favor of
+	// an extra outbound peer.
+	minOutboundEvictAge = time.Second * 30
+)
+
+// evictionCandidate houses the information about an inbound peer that is used
+// to choose a peer to evict when the maximum number of peers is reached.
+type evictionCandidate struct {
+	id            int32
+	connTime      time.Time
+	pingMicros    int64
+	lastBlockTime time.Time
+	lastTxTime    time.Time
+	relayTxs      bool
+	netGroup      string
+	keyedNetGroup uint64
+}
+
+// protectCandidates sorts the candidates so those most worthy of protection
+// come first according to the passed function and removes up to the given
+// number of them from the returned candidates.  When a filter is provided,
+// only the candidates among them that satisfy it are removed.
+func protectCandidates(candidates []*evictionCandidate, n int,
+	morePro func(a, b *evictionCandidate) bool,
+	filter func(c *evictionCandidate) bool) []*evictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return morePro(candidates[i], candidates[j])
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	if filter == nil {
+		return candidates[n:]
+	}
+
+	remaining := make([]*evictionCandidate, 0, len(candidates))
+	for i, c := range candidates {
+		if i < n && filter(c) {
+			continue
+		}
+		remaining = append(remaining, c)
+	}
+	return remaining
+}
+
+// selectPeerToEvict chooses the inbound peer to evict in order to make room
+// for a new inbound peer from the passed candidates.  It returns nil when all
+// candidates are protected.
+//
+// Peers are protected from eviction based on several characteristics an
+// attacker can't easily fake at scale: their network group, low ping times,
+// recently relaying novel transactions and blocks, and a long uptime.  The
+// peer to evict is the most recently connected peer of the network group with
+// the most remaining connections.
+func selectPeerToEvict(candidates []*evictionCandidate) *evictionCandidate {
+	candidates = append([]*evictionCandidate(nil), candidates...)
+
+	// Protect peers from a deterministic, but unpredictable, set of
+	// network groups.
+	candidates = protectCandidates(candidates, evictProtectNetGroup,
+		func(a, b *evictionCandidate) bool {
+			return a.keyedNetGroup > b.keyedNetGroup
+		}, nil)
+
+	// Protect the peers with the lowest ping times.  Peers with an unknown
+	// ping time are protected last.
+	candidates = protectCandidates(candidates, evictProtectPing,
+		func(a, b *evictionCandidate) bool {
+			if (a.pingMicros == 0) != (b.pingMicros == 0) {
+				return b.pingMicros == 0
+			}
+			return a.pingMicros < b.pingMicros
+		}, nil)
+
+	// Protect the peers that most recently sent novel transactions.
+	candidates = protectCandidates(candidates, evictProtectTx,
+		func(a, b *evictionCandidate) bool {
+			return a.lastTxTime.After(b.lastTxTime)
+		}, nil)
+
+	// Protect the peers that don't relay transactions and most recently
+	// sent novel blocks, followed by any peers that most recently sent
+	// novel blocks.
+	candidates = protectCandidates(candidates, evictProtectBlockRelay,
+		func(a, b *evictionCandidate) bool {
+			if a.relayTxs != b.relayTxs {
+				return !a.relayTxs
+			}
+			if !a.lastBlockTime.Equal(b.lastBlockTime) {
+				return a.lastBlockTime.After(b.lastBlockTime)
+			}
+			return a.connTime.Before(b.connTime)
+		}, func(c *evictionCandidate) bool {
+			return !c.relayTxs
+		})
+	candidates = protectCandidates(candidates, evictProtectBlock,
+		func(a, b *evictionCandidate) bool {
+			return a.lastBlockTime.After(b.lastBlockTime)
+		}, nil)
+
+	// Protect the half of the remaining peers that have been connected the
+	// longest.
+	candidates = protectCandidates(candidates, len(candidates)/2,
+		func(a, b *evictionCandidate) bool {
+			return a.connTime.Before(b.connTime)
+		}, nil)
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// Identify the network group with the most connections and the most
+	// recently connected peer of each group.  When multiple groups have the
+	// same number of connections, the group with the most recently
+	// connected peer is chosen.
+	groupSizes := make(map[string]int)
+	youngest := make(map[string]*evictionCandidate)
+	for _, c := range candidates {
+		groupSizes[c.netGroup]++
+		if y, ok := youngest[c.netGroup]; !ok || c.connTime.After(y.connTime) {
+			youngest[c.netGroup] = c
+		}
+	}
+	var evict *evictionCandidate
+	for group, size := range groupSizes {
+		y := youngest[group]
+		if evict == nil {
+			evict = y
+			continue
+		}
+		evictSize := groupSizes[evict.netGroup]
+		if size > evictSize || (size == evictSize &&
+			y.connTime.After(evict.connTime)) {
+
+			evict = y
+		}
+	}
+	return evict
+}
+
+// unixNanoTime converts the passed unix nanoseconds, as stored by the atomic
+// peer activity fields, to a time.  Zero is converted to the zero time.
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// keyedNetGroup returns a hash of the network group of the passed peer that is
+// keyed by a secret random value so remote peers can't predict it.
+func (s *server) keyedNetGroup(sp *serverPeer) uint64 {
+	h := sha256.New()
+	h.Write(s.evictionKey[:])
+	h.Write([]byte(addrmgr.GroupKey(sp.NA())))
+	return binary.LittleEndian.Uint64(h.Sum(nil))
+}
+
+// evictInboundPeer attempts to make room for a new inbound peer by evicting
+// one of the existing inbound peers.  It returns whether or not a peer was
+// evicted.  It is invoked from the peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]*evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		if !sp.Connected() || sp.isWhitelisted || sp.NA() == nil {
+			continue
+		}
+		stats := sp.StatsSnapshot()
+		candidates = append(candidates, &evictionCandidate{
+			id:            sp.ID(),
+			connTime:      stats.ConnTime,
+			pingMicros:    stats.LastPingMicros,
+			lastBlockTime: unixNanoTime(atomic.LoadInt64(&sp.lastBlockTime)),
+			lastTxTime:    unixNanoTime(atomic.LoadInt64(&sp.lastTxTime)),
+			relayTxs:      !sp.relayTxDisabled(),
+			netGroup:      addrmgr.GroupKey(sp.NA()),
+			keyedNetGroup: s.keyedNetGroup(sp),
+		})
+	}
+
+	evict := selectPeerToEvict(candidates)
+	if evict == nil {
+		return false
+	}
+
+	// Remove the peer right away so it no longer counts towards the
+	// maximum number of peers.
+	sp := state.inboundPeers[evict.id]
+	delete(state.inboundPeers, evict.id)
+	sp.evicted = true
+	srvrLog.Infof("Evicting inbound peer %s to make room for a new peer",
+		sp)
+	sp.Disconnect()
+	return true
+}
+
+// checkStaleTip connects to an extra outbound peer when the best chain tip has
+// not changed for longer than expected, since that might indicate the current
+// outbound peers are not relaying blocks.  It is invoked from the peerHandler
+// goroutine.
+func (s *server) checkStaleTip(state *peerState) {
+	best := s.chain.BestSnapshot()
+	now := time.Now()
+	if best.Hash != state.tipHash || state.tipUpdated.IsZero() {
+		state.tipHash = best.Hash
+		state.tipUpdated = now
+		return
+	}
+
+	staleAfter := staleTipBlocks * s.chainParams.TargetTimePerBlock
+	if now.Sub(state.tipUpdated) < staleAfter {
+		return
+	}
+
+	srvrLog.Infof("Best chain tip %v has not changed in %v -- connecting "+
+		"to an extra outbound peer", best.Hash,
+		now.Sub(state.tipUpdated).Truncate(time.Second))
+	state.tipUpdated = now
+	go s.connManager.NewConnReq()
+}
+
+// evictExtraOutboundPeer disconnects the outbound peer that least recently
+// announced a new block when there are more full-relay outbound peers than
+// targeted, such as after an extra peer was connected due to a stale tip.  It
+// is invoked from the peerHandler goroutine.
+func (s *server) evictExtraOutboundPeer(state *peerState) {
+	var numFullRelay int
+	for _, sp := range state.outboundPeers {
+		if !sp.isBlockRelayOnly() && !sp.evicted {
+			numFullRelay++
+		}
+	}
+	if numFullRelay <= s.targetOutbound {
+		return
+	}
+
+	// The sync peer is never evicted.  Its ID is kept up to date by the
+	// sync manager rather than queried since the sync manager may be
+	// blocked relaying inventory to this goroutine.
+	var evict *serverPeer
+	var evictAnnounce int64
+	syncPeerID := atomic.LoadInt32(&s.syncPeerID)
+	for _, sp := range state.outboundPeers {
+		if sp.isBlockRelayOnly() || sp.evicted {
+			continue
+		}
+		if sp.ID() == syncPeerID || !sp.Connected() ||
+			time.Since(sp.StatsSnapshot().ConnTime) < minOutboundEvictAge {
+
+			continue
+		}
+
+		// Prefer evicting the peer that least recently announced a new
+		// block, and the most recently connected peer among those with
+		// the same announcement time.
+		announce := atomic.LoadInt64(&sp.lastBlockAnnounce)
+		if evict == nil || announce < evictAnnounce ||
+			(announce == evictAnnounce && sp.ID() > evict.ID()) {
+
+			evict = sp
+			evictAnnounce = announce
+		}
+	}
+	if evict == nil {
+		return
+	}
+
+	// The evicted peer is not replaced since there are already more
+	// outbound peers than targeted.
+	srvrLog.Infof("Evicting extra outbound peer %s (last block "+
+		"announcement %v)", evict,
+		unixNanoTime(evictAnnounce).Truncate(time.Second))
+	evict.evicted = true
+	evict.Disconnect()
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2013-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// evictionTestCandidates returns a set of eviction candidates made up of the
+// passed number of long lived peers from distinct network groups with low ping
+// times followed by the passed number of recently connected peers from a
+// single network group with high ping times.
+func evictionTestCandidates(numHonest, numAttacker int) []*evictionCandidate {
+	start := time.Now().Add(-time.Hour)
+	var candidates []*evictionCandidate
+	for i := 0; i < numHonest; i++ {
+		candidates = append(candidates, &evictionCandidate{
+			id:            int32(len(candidates)),
+			connTime:      start.Add(time.Duration(i) * time.Second),
+			pingMicros:    int64(1000 + i),
+			relayTxs:      true,
+			netGroup:      fmt.Sprintf("honest%d", i),
+			keyedNetGroup: uint64(i + 1),
+		})
+	}
+	for i := 0; i < numAttacker; i++ {
+		candidates = append(candidates, &evictionCandidate{
+			id:         int32(len(candidates)),
+			connTime:   start.Add(time.Minute + time.Duration(i)*time.Second),
+			pingMicros: 1000000,
+			relayTxs:   true,
+			netGroup:   "attacker",
+		})
+	}
+	return candidates
+}
+
+// TestSelectPeerToEvict ensures the peer chosen for eviction is the most
+// recently connected peer from the largest network group among those that are
+// not protected.
+func TestSelectPeerToEvict(t *testing.T) {
+	tests := []struct {
+		name        string
+		numHonest   int
+		numAttacker int
+		modify      func(candidates []*evictionCandidate)
+		wantID      int32 // -1 for no eviction
+	}{
+		{
+			name:      "all protected",
+			numHonest: 12,
+			wantID:    -1,
+		},
+		{
+			name:        "youngest of largest group",
+			numHonest:   30,
+			numAttacker: 20,
+			wantID:      49,
+		},
+		{
+			name:        "recent tx protects peer",
+			numHonest:   30,
+			numAttacker: 20,
+			modify: func(candidates []*evictionCandidate) {
+				candidates[49].lastTxTime = time.Now()
+			},
+			wantID: 48,
+		},
+		{
+			name:        "block-relay-only recent block protects peer",
+			numHonest:   30,
+			numAttacker: 20,
+			modify: func(candidates []*evictionCandidate) {
+				candidates[49].relayTxs = false
+				candidates[49].lastBlockTime = time.Now()
+			},
+			wantID: 48,
+		},
+		{
+			name:        "low ping protects peer",
+			numHonest:   30,
+			numAttacker: 20,
+			modify: func(candidates []*evictionCandidate) {
+				candidates[49].pingMicros = 1
+			},
+			wantID: 48,
+		},
+	}
+
+	for _, test := range tests {
+		candidates := evictionTestCandidates(test.numHonest,
+			test.numAttacker)
+		if test.modify != nil {
+			test.modify(candidates)
+		}
+		evict := selectPeerToEvict(candidates)
+		if test.wantID == -1 {
+			if evict != nil {
+				t.Errorf("%s: unexpected eviction of peer %d",
+					test.name, evict.id)
+			}
+			continue
+		}
+		if evict == nil {
+			t.Errorf("%s: no peer evicted, want peer %d", test.name,
+				test.wantID)
+			continue
+		}
+		if evict.id != test.wantID {
+			t.Errorf("%s: unexpected evicted peer: got %d, want %d",
+				test.name, evict.id, test.wantID)
+		}
+	}
+}
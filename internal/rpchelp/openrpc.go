@@ -0,0 +1,59 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpchelp
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// Methods returns the names of all described RPC commands in sorted order.
+// The commands which are only available to websocket clients are only included
+// when requested.
+func Methods(includeWebsockets bool) []string {
+	methods := make([]string, 0, len(ResultTypes))
+	for method := range ResultTypes {
+		if !includeWebsockets {
+			flags, err := btcjson.MethodUsageFlags(method)
+			if err == nil && flags&btcjson.UFWebsocketOnly != 0 {
+				continue
+			}
+		}
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// OpenRPCDocument returns an OpenRPC document which describes the passed RPC
+// commands, in the passed order, of the passed version of btcd.  The
+// descriptions are the same as those of the help.
+func OpenRPCDocument(methods []string, version string) (*btcjson.OpenRPCDocument, error) {
+	doc := &btcjson.OpenRPCDocument{
+		OpenRPC: btcjson.OpenRPCVersion,
+		Info: btcjson.OpenRPCInfo{
+			Title:       "btcd JSON-RPC API",
+			Description: "The JSON-RPC API of btcd, an alternative full node bitcoin implementation written in Go.",
+			Version:     version,
+		},
+		Methods: make([]btcjson.OpenRPCMethod, 0, len(methods)),
+	}
+	for _, method := range methods {
+		resultTypes, ok := ResultTypes[method]
+		if !ok {
+			return nil, errors.New("no result types specified " +
+				"for method " + method)
+		}
+		openRPCMethod, err := btcjson.GenerateOpenRPCMethod(method,
+			DescsEnUS, resultTypes...)
+		if err != nil {
+			return nil, err
+		}
+		doc.Methods = append(doc.Methods, *openRPCMethod)
+	}
+	return doc, nil
+}
@@ -0,0 +1,291 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+// Format is the output format of a Backend.
+type Format uint32
+
+// Supported output formats.
+const (
+	// FormatText writes records in the text format of btclog.
+	FormatText Format = iota
+
+	// FormatJSON writes records as JSON objects, one per line.
+	FormatJSON
+)
+
+// formatStrings is a map of formats back to their names.
+var formatStrings = map[Format]string{
+	FormatText: "text",
+	FormatJSON: "json",
+}
+
+// String returns the name of the format.
+func (f Format) String() string {
+	if s, ok := formatStrings[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Format (%d)", uint32(f))
+}
+
+// ParseFormat returns the format with the passed name and whether or not the
+// name is valid.
+func ParseFormat(s string) (Format, bool) {
+	for f, name := range formatStrings {
+		if strings.EqualFold(s, name) {
+			return f, true
+		}
+	}
+	return FormatText, false
+}
+
+// levelNames maps each log level to the name used in JSON records.
+var levelNames = map[btclog.Level]string{
+	btclog.LevelTrace:    "trace",
+	btclog.LevelDebug:    "debug",
+	btclog.LevelInfo:     "info",
+	btclog.LevelWarn:     "warn",
+	btclog.LevelError:    "error",
+	btclog.LevelCritical: "critical",
+	btclog.LevelOff:      "off",
+}
+
+// LevelName returns the lowercase name of the passed level, as accepted by
+// btclog.LevelFromString.
+func LevelName(level btclog.Level) string {
+	if s, ok := levelNames[level]; ok {
+		return s
+	}
+	return level.String()
+}
+
+// Backend is a logging backend.  Subsystem loggers created from it write to
+// the backend's writer in the backend's current format.  The writer receives
+// exactly one record per Write call.
+//
+// It is safe for concurrent access.
+type Backend struct {
+	format uint32 // atomic
+
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// NewBackend returns a new backend that writes text records to the passed
+// writer.
+func NewBackend(w io.Writer) *Backend {
+	return &Backend{w: w}
+}
+
+// SetFormat changes the output format of all subsystem loggers created from
+// the backend.
+func (b *Backend) SetFormat(format Format) {
+	atomic.StoreUint32(&b.format, uint32(format))
+}
+
+// Format returns the current output format of the backend.
+func (b *Backend) Format() Format {
+	return Format(atomic.LoadUint32(&b.format))
+}
+
+// Logger returns a new logger for the passed subsystem that writes to the
+// backend.  The logger uses the info level by default.
+func (b *Backend) Logger(subsystem string) btclog.Logger {
+	return &subsystemLogger{lvl: uint32(btclog.LevelInfo), tag: subsystem, b: b}
+}
+
+// jsonRecord is the fixed part of a JSON record.  The structured fields are
+// appended after it.
+type jsonRecord struct {
+	Time      string `json:"time"`
+	Level     string `json:"level"`
+	Subsystem string `json:"subsystem"`
+	Msg       string `json:"msg"`
+}
+
+// write formats and writes a record with the passed level, subsystem, message,
+// and the fields described by the passed log call arguments.
+func (b *Backend) write(lvl btclog.Level, tag, msg string, args []interface{}) {
+	t := time.Now()
+
+	var buf bytes.Buffer
+	if b.Format() == FormatJSON {
+		rec, err := json.Marshal(jsonRecord{
+			Time:      t.UTC().Format(time.RFC3339Nano),
+			Level:     LevelName(lvl),
+			Subsystem: tag,
+			Msg:       msg,
+		})
+		if err != nil {
+			return
+		}
+		buf.Write(rec[:len(rec)-1])
+		for _, f := range extractFields(args) {
+			value, err := json.Marshal(f.value)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&buf, ",%q:%s", f.name, value)
+		}
+		buf.WriteString("}\n")
+	} else {
+		buf.WriteString(t.Format("2006-01-02 15:04:05.000"))
+		fmt.Fprintf(&buf, " [%s] %s: %s\n", lvl, tag, msg)
+	}
+
+	b.mtx.Lock()
+	b.w.Write(buf.Bytes())
+	b.mtx.Unlock()
+}
+
+// subsystemLogger is a logger for a subsystem that writes to a Backend.  It
+// implements the btclog.Logger interface.
+type subsystemLogger struct {
+	lvl uint32 // atomic
+	tag string
+	b   *Backend
+}
+
+// print writes a record with the passed level when it is enabled, formatting
+// the arguments using the default formats.
+func (l *subsystemLogger) print(lvl btclog.Level, args []interface{}) {
+	if lvl < l.Level() {
+		return
+	}
+	msg := strings.TrimSuffix(fmt.Sprintln(args...), "\n")
+	l.b.write(lvl, l.tag, msg, args)
+}
+
+// printf writes a record with the passed level when it is enabled, formatting
+// the arguments according to the format specifier.
+func (l *subsystemLogger) printf(lvl btclog.Level, format string, args []interface{}) {
+	if lvl < l.Level() {
+		return
+	}
+	l.b.write(lvl, l.tag, fmt.Sprintf(format, args...), args)
+}
+
+// Trace formats a message using the default formats for its operands and
+// writes it with LevelTrace.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Trace(args ...interface{}) {
+	l.print(btclog.LevelTrace, args)
+}
+
+// Tracef formats a message according to the format specifier and writes it
+// with LevelTrace.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Tracef(format string, args ...interface{}) {
+	l.printf(btclog.LevelTrace, format, args)
+}
+
+// Debug formats a message using the default formats for its operands and
+// writes it with LevelDebug.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Debug(args ...interface{}) {
+	l.print(btclog.LevelDebug, args)
+}
+
+// Debugf formats a message according to the format specifier and writes it
+// with LevelDebug.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Debugf(format string, args ...interface{}) {
+	l.printf(btclog.LevelDebug, format, args)
+}
+
+// Info formats a message using the default formats for its operands and
+// writes it with LevelInfo.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Info(args ...interface{}) {
+	l.print(btclog.LevelInfo, args)
+}
+
+// Infof formats a message according to the format specifier and writes it
+// with LevelInfo.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Infof(format string, args ...interface{}) {
+	l.printf(btclog.LevelInfo, format, args)
+}
+
+// Warn formats a message using the default formats for its operands and
+// writes it with LevelWarn.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Warn(args ...interface{}) {
+	l.print(btclog.LevelWarn, args)
+}
+
+// Warnf formats a message according to the format specifier and writes it
+// with LevelWarn.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Warnf(format string, args ...interface{}) {
+	l.printf(btclog.LevelWarn, format, args)
+}
+
+// Error formats a message using the default formats for its operands and
+// writes it with LevelError.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Error(args ...interface{}) {
+	l.print(btclog.LevelError, args)
+}
+
+// Errorf formats a message according to the format specifier and writes it
+// with LevelError.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Errorf(format string, args ...interface{}) {
+	l.printf(btclog.LevelError, format, args)
+}
+
+// Critical formats a message using the default formats for its operands and
+// writes it with LevelCritical.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Critical(args ...interface{}) {
+	l.print(btclog.LevelCritical, args)
+}
+
+// Criticalf formats a message according to the format specifier and writes it
+// with LevelCritical.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Criticalf(format string, args ...interface{}) {
+	l.printf(btclog.LevelCritical, format, args)
+}
+
+// Level returns the current logging level.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) Level() btclog.Level {
+	return btclog.Level(atomic.LoadUint32(&l.lvl))
+}
+
+// SetLevel changes the logging level to the passed level.
+//
+// This is part of the btclog.Logger interface.
+func (l *subsystemLogger) SetLevel(level btclog.Level) {
+	atomic.StoreUint32(&l.lvl, uint32(level))
+}
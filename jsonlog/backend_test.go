@@ -0,0 +1,173 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/btcutil"
+)
+
+// testPeer is a peer with a remote address.
+type testPeer string
+
+func (p testPeer) Addr() string   { return string(p) }
+func (p testPeer) String() string { return string(p) + " (inbound)" }
+
+// TestTextFormat ensures the text format matches the format of btclog and that
+// wrapped arguments print like the values they wrap.
+func TestTextFormat(t *testing.T) {
+	var buf bytes.Buffer
+	backend := NewBackend(&buf)
+	log := backend.Logger("TEST")
+
+	hash := chaincfg.MainNetParams.GenesisHash
+	log.Infof("Accepted block %v (height %d) from %s", BlockHash(hash),
+		Height(0), testPeer("127.0.0.1:8333"))
+	log.Debug("not written")
+	log.Warn("a", "b")
+
+	want := regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} ` +
+		`\[INF\] TEST: Accepted block ` + hash.String() + ` \(height 0\) ` +
+		`from 127\.0\.0\.1:8333 \(inbound\)\n` +
+		`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}\.\d{3} \[WRN\] TEST: a b\n$`)
+	if !want.Match(buf.Bytes()) {
+		t.Fatalf("unexpected text output: %q", buf.String())
+	}
+}
+
+// TestJSONFormat ensures JSON records contain the message and the structured
+// fields extracted from the log call arguments.
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	backend := NewBackend(&buf)
+	backend.SetFormat(FormatJSON)
+	log := backend.Logger("TEST")
+	log.SetLevel(btclog.LevelTrace)
+
+	blockHash := chaincfg.MainNetParams.GenesisHash
+	txHash := chainhash.Hash{0x01}
+
+	tests := []struct {
+		log  func()
+		msg  string
+		want map[string]interface{}
+	}{
+		{
+			log: func() {
+				log.Debugf("Processed block at height %d from %s",
+					Height(10), testPeer("127.0.0.1:8333"))
+			},
+			msg: "Processed block at height 10 from 127.0.0.1:8333 " +
+				"(inbound)",
+			want: map[string]interface{}{
+				"level":  "debug",
+				"height": float64(10),
+				"peer":   "127.0.0.1:8333",
+			},
+		},
+		{
+			log: func() {
+				log.Error("Rejected", TxID(&txHash), "in",
+					BlockHash(blockHash))
+			},
+			msg: "Rejected " + txHash.String() + " in " +
+				blockHash.String(),
+			want: map[string]interface{}{
+				"level":      "error",
+				"txid":       txHash.String(),
+				"block_hash": blockHash.String(),
+			},
+		},
+	}
+
+	for i, test := range tests {
+		buf.Reset()
+		test.log()
+
+		var rec map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatalf("#%d: invalid JSON record %q: %v", i, buf.String(),
+				err)
+		}
+		if rec["msg"] != test.msg || rec["subsystem"] != "TEST" {
+			t.Fatalf("#%d: unexpected record %q", i, buf.String())
+		}
+		if _, ok := rec["time"].(string); !ok {
+			t.Fatalf("#%d: missing time in %q", i, buf.String())
+		}
+		for name, value := range test.want {
+			if rec[name] != value {
+				t.Fatalf("#%d: unexpected %s -- got %v, want %v", i,
+					name, rec[name], value)
+			}
+		}
+		if len(rec) != len(test.want)+3 {
+			t.Fatalf("#%d: unexpected fields in %q", i, buf.String())
+		}
+	}
+}
+
+// TestExtractFields ensures the structured fields are extracted from blocks and
+// transactions and that the first argument describing a field wins.
+func TestExtractFields(t *testing.T) {
+	block := btcutil.NewBlock(chaincfg.MainNetParams.GenesisBlock)
+	tx := btcutil.NewTx(chaincfg.MainNetParams.GenesisBlock.Transactions[0])
+
+	fields := extractFields([]interface{}{block, tx, Height(5)})
+	want := []field{
+		{FieldBlockHash, block.Hash().String()},
+		{FieldTxID, tx.Hash().String()},
+		{FieldHeight, int32(5)},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("unexpected fields -- got %v, want %v", fields, want)
+	}
+
+	block.SetHeight(7)
+	fields = extractFields([]interface{}{block, Height(5), "ignored"})
+	want = []field{
+		{FieldBlockHash, block.Hash().String()},
+		{FieldHeight, int32(7)},
+	}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("unexpected fields -- got %v, want %v", fields, want)
+	}
+
+	// Peers are extracted, but their plain address strings are not.
+	p := testPeer("127.0.0.1:8333")
+	fields = extractFields([]interface{}{p.Addr(), p})
+	want = []field{{FieldPeer, "127.0.0.1:8333"}}
+	if !reflect.DeepEqual(fields, want) {
+		t.Fatalf("unexpected fields -- got %v, want %v", fields, want)
+	}
+}
+
+// TestParseFormat ensures formats are parsed from their names.
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		ok     bool
+	}{
+		{"text", FormatText, true},
+		{"JSON", FormatJSON, true},
+		{"xml", FormatText, false},
+	}
+	for _, test := range tests {
+		format, ok := ParseFormat(test.name)
+		if format != test.format || ok != test.ok {
+			t.Errorf("ParseFormat(%q): got %v %v, want %v %v", test.name,
+				format, ok, test.format, test.ok)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package jsonlog implements a logging backend for btclog subsystem loggers that
+writes either the usual text format of btclog or one JSON object per line.
+
+The text format is identical to the format of btclog.Backend:
+
+	2017-06-28 15:53:09.123 [INF] SYNC: Processed 1 block in the last 10s
+
+The JSON format contains the time, level, subsystem and message along with
+structured fields that are extracted from the arguments of the log call, so log
+pipelines don't have to parse the free-form messages:
+
+	{"time":"2017-06-28T15:53:09.123Z","level":"info","subsystem":"SYNC","msg":"...","height":478558}
+
+The following arguments are recognized:
+
+  - Values implementing Field, such as those returned by BlockHash, TxID and
+    Height, add the field they describe
+  - Values with an Addr() string method, such as peers, add a peer field
+  - *btcutil.Block values add block_hash and, when known, height fields
+  - *btcutil.Tx values add a txid field
+
+The field values print exactly like the values they wrap, so wrapping log call
+arguments doesn't change the text format.
+*/
+package jsonlog
@@ -0,0 +1,137 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package jsonlog
+
+import (
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcutil"
+)
+
+// Names of the structured fields.
+const (
+	FieldPeer      = "peer"
+	FieldBlockHash = "block_hash"
+	FieldHeight    = "height"
+	FieldTxID      = "txid"
+)
+
+// Field is implemented by log call arguments that describe a structured field
+// of JSON log records.
+type Field interface {
+	// LogField returns the name and value of the field.
+	LogField() (string, interface{})
+}
+
+// blockHash is a block hash passed to a log call.  It prints like the wrapped
+// hash.
+type blockHash struct {
+	*chainhash.Hash
+}
+
+// LogField returns the block hash field.
+//
+// This is part of the Field interface.
+func (h blockHash) LogField() (string, interface{}) {
+	return FieldBlockHash, hashString(h.Hash)
+}
+
+// txID is a transaction hash passed to a log call.  It prints like the wrapped
+// hash.
+type txID struct {
+	*chainhash.Hash
+}
+
+// LogField returns the transaction hash field.
+//
+// This is part of the Field interface.
+func (h txID) LogField() (string, interface{}) {
+	return FieldTxID, hashString(h.Hash)
+}
+
+// height is a block height passed to a log call.  It prints like the wrapped
+// height.
+type height int32
+
+// LogField returns the block height field.
+//
+// This is part of the Field interface.
+func (h height) LogField() (string, interface{}) {
+	return FieldHeight, int32(h)
+}
+
+// hashString returns the string representation of the passed hash, or an empty
+// string when it is nil.
+func hashString(hash *chainhash.Hash) string {
+	if hash == nil {
+		return ""
+	}
+	return hash.String()
+}
+
+// BlockHash wraps the passed block hash so it is added as the block_hash field
+// of JSON log records.
+func BlockHash(hash *chainhash.Hash) Field {
+	return blockHash{hash}
+}
+
+// TxID wraps the passed transaction hash so it is added as the txid field of
+// JSON log records.
+func TxID(hash *chainhash.Hash) Field {
+	return txID{hash}
+}
+
+// Height wraps the passed block height so it is added as the height field of
+// JSON log records.
+func Height(h int32) Field {
+	return height(h)
+}
+
+// addrer is implemented by peers and other values with a remote address.
+type addrer interface {
+	Addr() string
+}
+
+// field is a structured field of a JSON log record.
+type field struct {
+	name  string
+	value interface{}
+}
+
+// extractFields returns the structured fields described by the passed log call
+// arguments.  When several arguments describe the same field, the first one
+// wins.
+func extractFields(args []interface{}) []field {
+	var fields []field
+	add := func(name string, value interface{}) {
+		for _, f := range fields {
+			if f.name == name {
+				return
+			}
+		}
+		fields = append(fields, field{name, value})
+	}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case Field:
+			add(v.LogField())
+		case *btcutil.Block:
+			if v == nil {
+				continue
+			}
+			add(FieldBlockHash, v.Hash().String())
+			if h := v.Height(); h != btcutil.BlockHeightUnknown {
+				add(FieldHeight, h)
+			}
+		case *btcutil.Tx:
+			if v == nil {
+				continue
+			}
+			add(FieldTxID, v.Hash().String())
+		case addrer:
+			add(FieldPeer, v.Addr())
+		}
+	}
+	return fields
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/blockfile"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcutil"
+)
+
+// importProgressInterval is the minimum amount of time between progress
+// messages while importing blocks.
+const importProgressInterval = time.Second * 10
+
+// importBlockFiles imports the blocks in each of the passed paths into the
+// block chain of the passed server.  Each path is either a bootstrap.dat style
+// file or a directory containing the block files of Bitcoin Core.  The import
+// stops early when an interrupt is requested.
+//
+// The blocks are processed through the sync manager, like blocks submitted via
+// RPC, since the server is running while the blocks are imported.
+func importBlockFiles(s *server, paths []string, interrupt <-chan struct{}) error {
+	for _, path := range paths {
+		if err := importBlockFile(s, path, interrupt); err != nil {
+			return err
+		}
+		if interruptRequested(interrupt) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// importBlockFile imports the blocks in the passed path into the block chain.
+// Blocks that are out of order are reordered so each block is processed after
+// its parent.  Blocks that are malformed or rejected by the chain rules are
+// logged and skipped, so only read and database errors stop the import.
+func importBlockFile(s *server, path string, interrupt <-chan struct{}) error {
+	chain := s.chain
+
+	r, err := blockfile.Open(path, activeNetParams.Net)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	numFiles := uint64(len(r.Files()))
+	btcdLog.Infof("Importing blocks from %s (%d %s)", path, numFiles,
+		pickNoun(numFiles, "file", "files"))
+
+	blocks := blockfile.NewOrderer(r, chain.HaveBlock)
+	var numProcessed, numImported, numLogged uint64
+	lastLogTime := time.Now()
+	for !interruptRequested(interrupt) {
+		serializedBlock, err := blocks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		numProcessed++
+
+		block, err := btcutil.NewBlockFromBytes(serializedBlock)
+		if err != nil {
+			btcdLog.Warnf("Skipping malformed block in %s: %v", path,
+				err)
+			continue
+		}
+
+		// Skip blocks that already exist.
+		exists, err := chain.HaveBlock(block.Hash())
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		isOrphan, err := s.syncManager.ProcessBlock(block,
+			blockchain.BFNone)
+		if _, ok := err.(database.Error); ok {
+			return err
+		}
+		if err != nil {
+			btcdLog.Warnf("Rejected block %v from %s: %v", block.Hash(),
+				path, err)
+			continue
+		}
+		if isOrphan {
+			btcdLog.Warnf("Block %v from %s is an orphan",
+				block.Hash(), path)
+			continue
+		}
+		numImported++
+		numLogged++
+
+		if now := time.Now(); now.Sub(lastLogTime) >= importProgressInterval {
+			btcdLog.Infof("Imported %d %s in the last %s (height %d)",
+				numLogged, pickNoun(numLogged, "block", "blocks"),
+				now.Sub(lastLogTime).Truncate(time.Second),
+				chain.BestSnapshot().Height)
+			numLogged = 0
+			lastLogTime = now
+		}
+	}
+
+	if n := blocks.Unlinked(); n > 0 {
+		btcdLog.Infof("Skipped %d blocks from %s that don't link to the "+
+			"block chain", n, path)
+	}
+	btcdLog.Infof("Processed %d blocks from %s (%d imported, %d already "+
+		"known or rejected)", numProcessed, path, numImported,
+		numProcessed-numImported)
+	return nil
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
@@ -190,6 +191,15 @@ type TxPool struct {
 	// the scan will only run when an orphan is added to the pool as opposed
 	// to on an unconditional timer.
 	nextExpireScan time.Time
+
+	// sequence is incremented every time a transaction is added to or
+	// removed from the pool.
+	sequence uint64
+
+	// notifications is the list of callbacks subscribed to transactions
+	// being added to and removed from the pool.
+	notificationsLock sync.RWMutex
+	notifications     []NotificationCallback
 }
 
 // Ensure the TxPool type implements the mining.TxSource interface.
@@ -343,8 +353,8 @@ func (mp *TxPool) addOrphan(tx *btcutil.Tx, tag Tag) {
 		mp.orphansByPrev[txIn.PreviousOutPoint][*tx.Hash()] = tx
 	}
 
-	log.Debugf("Stored orphan transaction %v (total: %d)", tx.Hash(),
-		len(mp.orphans))
+	log.Debugf("Stored orphan transaction %v (total: %d)",
+		jsonlog.TxID(tx.Hash()), len(mp.orphans))
 }
 
 // maybeAddOrphan potentially adds an orphan to the orphan pool.
@@ -466,14 +476,16 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool,
+	reason RemovalReason) {
+
 	txHash := tx.Hash()
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason)
 			}
 		}
 	}
@@ -492,19 +504,23 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 		}
 		delete(mp.pool, *txHash)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		mp.sendNotification(NTTxRemoved, tx, reason)
 	}
 }
 
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The reason is reported to the
+// subscribers for every removed transaction.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool,
+	reason RemovalReason) {
+
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, reason)
 	mp.mtx.Unlock()
 }
 
@@ -521,7 +537,8 @@ func (mp *TxPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true,
+					RemovalConflict)
 			}
 		}
 	}
@@ -564,6 +581,8 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	mp.sendNotification(NTTxAccepted, tx, RemovalUnknown)
+
 	return txD
 }
 
@@ -1228,12 +1247,12 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		// The conflict set should already include the descendants for
 		// each one, so we don't need to remove the redeemers within
 		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+		mp.removeTransaction(conflict, false, RemovalReplaced)
 	}
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
-	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
-		len(mp.pool))
+	log.Debugf("Accepted transaction %v (pool size: %v)",
+		jsonlog.TxID(txHash), len(mp.pool))
 
 	return nil, txD, nil
 }
@@ -1371,7 +1390,7 @@ func (mp *TxPool) ProcessOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 //
 // This function is safe for concurrent access.
 func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool, tag Tag) ([]*TxDesc, error) {
-	log.Tracef("Processing transaction %v", tx.Hash())
+	log.Tracef("Processing transaction %v", jsonlog.TxID(tx.Hash()))
 
 	// Protect concurrent access.
 	mp.mtx.Lock()
@@ -1470,6 +1489,23 @@ func (mp *TxPool) TxDescs() []*TxDesc {
 	return descs
 }
 
+// TxDescsAndSequence returns a slice of descriptors for all the transactions in
+// the pool along with the pool sequence number they correspond to.  The
+// descriptors must be treated as read only.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TxDescsAndSequence() ([]*TxDesc, uint64) {
+	mp.mtx.RLock()
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+	seq := mp.sequence
+	mp.mtx.RUnlock()
+
+	return descs, seq
+}
+
 // MiningDescs returns a slice of mining descriptors for all the transactions
 // in the pool.
 //
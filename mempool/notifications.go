@@ -0,0 +1,146 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// NotificationType represents the type of a notification message.
+type NotificationType int
+
+// NotificationCallback is used for a caller to provide a callback for
+// notifications about transactions being added to and removed from the memory
+// pool.
+type NotificationCallback func(*Notification)
+
+// Constants for the type of a notification message.
+const (
+	// NTTxAccepted indicates the associated transaction was accepted into
+	// the memory pool.
+	NTTxAccepted NotificationType = iota
+
+	// NTTxRemoved indicates the associated transaction was removed from the
+	// memory pool.
+	NTTxRemoved
+)
+
+// notificationTypeStrings is a map of notification types back to their constant
+// names for pretty printing.
+var notificationTypeStrings = map[NotificationType]string{
+	NTTxAccepted: "NTTxAccepted",
+	NTTxRemoved:  "NTTxRemoved",
+}
+
+// String returns the NotificationType in human-readable form.
+func (n NotificationType) String() string {
+	if s, ok := notificationTypeStrings[n]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Notification Type (%d)", int(n))
+}
+
+// RemovalReason describes why a transaction was removed from the memory pool.
+type RemovalReason int
+
+// Constants for the reasons a transaction is removed from the memory pool.
+const (
+	// RemovalUnknown indicates the transaction was removed for a reason
+	// that is not otherwise described.
+	RemovalUnknown RemovalReason = iota
+
+	// RemovalConfirmed indicates the transaction was included in a block
+	// connected to the main chain.
+	RemovalConfirmed
+
+	// RemovalConflict indicates the transaction, or one of its ancestors,
+	// spends an output that is spent by a transaction in a block connected
+	// to the main chain.
+	RemovalConflict
+
+	// RemovalReplaced indicates the transaction, or one of its ancestors,
+	// was replaced by a transaction paying a higher fee.
+	RemovalReplaced
+
+	// RemovalReorg indicates the transaction, or one of its ancestors, was
+	// in a block disconnected from the main chain and could not be added
+	// back to the memory pool.
+	RemovalReorg
+)
+
+// removalReasonStrings is a map of removal reasons back to their names.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalUnknown:   "unknown",
+	RemovalConfirmed: "confirmed",
+	RemovalConflict:  "conflict",
+	RemovalReplaced:  "replaced",
+	RemovalReorg:     "reorg",
+}
+
+// String returns the RemovalReason in human-readable form.
+func (r RemovalReason) String() string {
+	if s, ok := removalReasonStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(r))
+}
+
+// Notification defines a notification that is sent to the callbacks provided
+// via Subscribe.  The sequence is the memory pool sequence number after the
+// transaction was added or removed, so notifications can be ordered and gaps
+// can be detected.  The reason is only set for NTTxRemoved notifications.
+type Notification struct {
+	Type     NotificationType
+	Tx       *btcutil.Tx
+	Sequence uint64
+	Reason   RemovalReason
+}
+
+// Subscribe to memory pool notifications.  Registers a callback to be executed
+// when transactions are added to or removed from the memory pool.
+//
+// The callbacks are invoked with the memory pool lock held in order to deliver
+// the notifications in order.  Thus, they MUST NOT call back into the memory
+// pool and should return quickly.
+func (mp *TxPool) Subscribe(callback NotificationCallback) {
+	mp.notificationsLock.Lock()
+	mp.notifications = append(mp.notifications, callback)
+	mp.notificationsLock.Unlock()
+}
+
+// sendNotification increments the memory pool sequence number and sends a
+// notification with the passed type, transaction, and removal reason to all
+// subscribers.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) sendNotification(typ NotificationType, tx *btcutil.Tx,
+	reason RemovalReason) {
+
+	mp.sequence++
+	n := Notification{
+		Type:     typ,
+		Tx:       tx,
+		Sequence: mp.sequence,
+		Reason:   reason,
+	}
+	mp.notificationsLock.RLock()
+	for _, callback := range mp.notifications {
+		callback(&n)
+	}
+	mp.notificationsLock.RUnlock()
+}
+
+// Sequence returns the current memory pool sequence number.  It is incremented
+// every time a transaction is added to or removed from the memory pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Sequence() uint64 {
+	mp.mtx.RLock()
+	seq := mp.sequence
+	mp.mtx.RUnlock()
+	return seq
+}
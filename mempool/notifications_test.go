@@ -0,0 +1,93 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestNotifications ensures subscribers are notified about transactions being
+// added to and removed from the pool in order, with increasing sequence numbers
+// and the expected removal reasons.
+func TestNotifications(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	coinbase := ctx.addCoinbaseTx(2)
+
+	var notifications []Notification
+	harness.txPool.Subscribe(func(n *Notification) {
+		notifications = append(notifications, *n)
+	})
+
+	// Add a parent and child transaction along with an unrelated one.
+	chainedTxns, err := harness.CreateTxChain(
+		txOutToSpendableOut(coinbase, 0), 2,
+	)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: unexpected error: %v", err)
+		}
+	}
+	unrelatedOuts := []spendableOutput{txOutToSpendableOut(coinbase, 1)}
+	unrelated := ctx.addSignedTx(unrelatedOuts, 1, 1000, false, false)
+
+	// Remove the unrelated transaction as if it were confirmed and double
+	// spend the parent transaction, which removes the child as well.
+	harness.txPool.RemoveTransaction(unrelated, false, RemovalConfirmed)
+	doubleSpendOuts := []spendableOutput{txOutToSpendableOut(coinbase, 0)}
+	doubleSpend, err := harness.CreateSignedTx(doubleSpendOuts, 1, 2000,
+		false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	harness.txPool.RemoveDoubleSpends(doubleSpend)
+
+	tests := []struct {
+		typ    NotificationType
+		tx     *btcutil.Tx
+		reason RemovalReason
+	}{
+		{NTTxAccepted, chainedTxns[0], RemovalUnknown},
+		{NTTxAccepted, chainedTxns[1], RemovalUnknown},
+		{NTTxAccepted, unrelated, RemovalUnknown},
+		{NTTxRemoved, unrelated, RemovalConfirmed},
+		{NTTxRemoved, chainedTxns[1], RemovalConflict},
+		{NTTxRemoved, chainedTxns[0], RemovalConflict},
+	}
+	if len(notifications) != len(tests) {
+		t.Fatalf("unexpected number of notifications -- got %d, want %d",
+			len(notifications), len(tests))
+	}
+	for i, test := range tests {
+		n := notifications[i]
+		if n.Type != test.typ || !n.Tx.Hash().IsEqual(test.tx.Hash()) ||
+			n.Reason != test.reason {
+
+			t.Fatalf("notification #%d: got %v %v (%v), want %v %v "+
+				"(%v)", i, n.Type, n.Tx.Hash(), n.Reason, test.typ,
+				test.tx.Hash(), test.reason)
+		}
+		if n.Sequence != uint64(i+1) {
+			t.Fatalf("notification #%d: unexpected sequence -- got "+
+				"%d, want %d", i, n.Sequence, i+1)
+		}
+	}
+	if seq := harness.txPool.Sequence(); seq != uint64(len(tests)) {
+		t.Fatalf("unexpected pool sequence -- got %d, want %d", seq,
+			len(tests))
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/metrics"
+	"github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// metricsReadTimeout is the maximum amount of time the metrics server
+	// waits for a scrape request to be read.
+	metricsReadTimeout = time.Second * 10
+)
+
+// serverMetrics houses the metrics exposed by the server.  The metrics are
+// always maintained, but they are only served when a metrics listener is
+// configured.
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	validationTime *metrics.HistogramVec
+	p2pBytes       *metrics.CounterVec
+	peers          *metrics.GaugeVec
+	rpcLatency     *metrics.HistogramVec
+
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+// newServerMetrics returns a new set of metrics for the passed server.  The
+// chain and mempool metrics are computed from the server when the metrics are
+// scraped, so they must only be scraped once the server is fully created.
+func newServerMetrics(s *server) *serverMetrics {
+	m := &serverMetrics{
+		registry: metrics.NewRegistry(),
+		validationTime: metrics.NewHistogramVec(
+			"btcd_block_validation_seconds",
+			"Time spent validating blocks by validation stage.",
+			metrics.ExponentialBuckets(0.0005, 2, 16), "stage"),
+		p2pBytes: metrics.NewCounterVec("btcd_p2p_bytes_total",
+			"Bytes of P2P messages by direction and command.",
+			"direction", "command"),
+		peers: metrics.NewGaugeVec("btcd_peers",
+			"Connected peers by direction.", "direction"),
+		rpcLatency: metrics.NewHistogramVec(
+			"btcd_rpc_request_duration_seconds",
+			"Time spent handling RPC requests by method.",
+			metrics.DefBuckets, "method"),
+	}
+
+	// Ensure both directions are always reported, even without peers.
+	m.peers.With("inbound")
+	m.peers.With("outbound")
+
+	m.registry.MustRegister(
+		m.validationTime,
+		m.p2pBytes,
+		m.peers,
+		m.rpcLatency,
+		metrics.NewGaugeFunc("btcd_chain_height",
+			"Height of the best chain tip.",
+			func() float64 {
+				return float64(s.chain.BestSnapshot().Height)
+			}),
+		metrics.NewGaugeFunc("btcd_chain_tip_age_seconds",
+			"Seconds since the timestamp of the best chain tip.",
+			func() float64 {
+				best := s.chain.BestSnapshot()
+				header, err := s.chain.HeaderByHash(&best.Hash)
+				if err != nil {
+					return 0
+				}
+				return time.Since(header.Timestamp).Seconds()
+			}),
+		metrics.NewCounterFunc("btcd_utxo_cache_hits_total",
+			"Transaction outputs needed to validate blocks that "+
+				"were already loaded.",
+			func() float64 {
+				hits, _ := s.chain.UtxoCacheStats()
+				return float64(hits)
+			}),
+		metrics.NewCounterFunc("btcd_utxo_cache_misses_total",
+			"Transaction outputs needed to validate blocks that "+
+				"were loaded from the database.",
+			func() float64 {
+				_, misses := s.chain.UtxoCacheStats()
+				return float64(misses)
+			}),
+		metrics.NewGaugeFunc("btcd_mempool_transactions",
+			"Number of transactions in the mempool.",
+			func() float64 {
+				return float64(s.txMemPool.Count())
+			}),
+		metrics.NewGaugeFunc("btcd_mempool_bytes",
+			"Total serialized size of the transactions in the "+
+				"mempool.",
+			func() float64 {
+				var size int
+				for _, desc := range s.txMemPool.TxDescs() {
+					size += desc.Tx.MsgTx().SerializeSize()
+				}
+				return float64(size)
+			}),
+		metrics.NewGaugeFunc("btcd_mempool_fees_satoshis",
+			"Total fees of the transactions in the mempool.",
+			func() float64 {
+				var fees int64
+				for _, desc := range s.txMemPool.TxDescs() {
+					fees += desc.Fee
+				}
+				return float64(fees)
+			}),
+	)
+	return m
+}
+
+// observeValidation records the time spent in the passed block validation
+// stage.  It is used as the validation timer of the block chain.
+func (m *serverMetrics) observeValidation(stage blockchain.ValidationStage,
+	elapsed time.Duration) {
+
+	m.validationTime.With(stage.String()).Observe(elapsed.Seconds())
+}
+
+// observeRPC records the time spent handling a request for the passed RPC
+// method.
+func (m *serverMetrics) observeRPC(method string, elapsed time.Duration) {
+	m.rpcLatency.With(method).Observe(elapsed.Seconds())
+}
+
+// addP2PBytes records the passed number of bytes read or written for a P2P
+// message.  The message is nil when it could not be decoded.
+func (m *serverMetrics) addP2PBytes(direction string, msg wire.Message,
+	bytes int) {
+
+	command := peer.OtherCommand
+	if msg != nil {
+		command = msg.Command()
+	}
+	m.p2pBytes.With(direction, command).Add(float64(bytes))
+}
+
+// updatePeers updates the connected peer counts from the passed peer state.
+// It is invoked from the peerHandler goroutine.
+func (m *serverMetrics) updatePeers(state *peerState) {
+	m.peers.With("inbound").Set(float64(len(state.inboundPeers)))
+	m.peers.With("outbound").Set(float64(len(state.outboundPeers) +
+		len(state.persistentPeers)))
+}
+
+// listen creates the listener for the metrics server on the passed address.
+func (m *serverMetrics) listen(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	m.listener = listener
+	m.httpServer = &http.Server{
+		Handler:     m.registry,
+		ReadTimeout: metricsReadTimeout,
+	}
+	return nil
+}
+
+// start begins serving the metrics when a listener was created.
+func (m *serverMetrics) start() {
+	if m.listener == nil {
+		return
+	}
+	srvrLog.Infof("Metrics server listening on %s", m.listener.Addr())
+	go func() {
+		err := m.httpServer.Serve(m.listener)
+		if err != http.ErrServerClosed {
+			srvrLog.Errorf("Metrics server: %v", err)
+		}
+	}()
+}
+
+// stop shuts down the metrics server when it is running.
+func (m *serverMetrics) stop() {
+	if m.httpServer == nil {
+		return
+	}
+	m.httpServer.Close()
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package metrics implements counters, gauges and histograms that are exposed
+over HTTP in the Prometheus text exposition format.
+
+Overview
+
+The package provides a small, dependency free subset of the functionality of
+the official Prometheus client library that is sufficient for exposing the
+internal state of btcd to a Prometheus server.
+
+Counters only ever increase, gauges may increase and decrease, and histograms
+count observations, such as durations, in configurable buckets.  Each of them
+has a vec variant which partitions the metric by the values of a fixed set of
+labels, along with function variants of counters and gauges which obtain their
+value when they are collected.
+
+Metrics are added to a Registry, which implements http.Handler, so it can be
+served directly:
+
+	reg := metrics.NewRegistry()
+	height := metrics.NewGauge("chain_height", "Height of the best chain")
+	reg.MustRegister(height)
+	http.Handle("/metrics", reg)
+*/
+package metrics
@@ -0,0 +1,489 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Metric types as they appear in the text exposition format.
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// DefBuckets are the default histogram buckets.  They are tailored to measure
+// durations in seconds ranging from a millisecond to ten seconds.
+var DefBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5,
+	1, 2.5, 5, 10}
+
+// ExponentialBuckets returns count buckets where the lowest bucket has an upper
+// bound of start and each following bucket's upper bound is factor times the
+// previous one.  It panics when count is less than one, start is not positive,
+// or factor is not greater than one.
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	if count < 1 || start <= 0 || factor <= 1 {
+		panic("metrics: invalid exponential bucket parameters")
+	}
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+// atomicFloat is a float64 that is safe for concurrent access.
+type atomicFloat struct {
+	bits uint64
+}
+
+// Load returns the current value.
+func (f *atomicFloat) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// Store sets the value.
+func (f *atomicFloat) Store(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+// Add adds the passed delta to the value.
+func (f *atomicFloat) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		v := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&f.bits, old, v) {
+			return
+		}
+	}
+}
+
+// desc houses the name and help text shared by all metric types.
+type desc struct {
+	name string
+	help string
+}
+
+// Name returns the name of the metric.
+func (d *desc) Name() string {
+	return d.name
+}
+
+// Counter is a metric whose value only ever increases, such as the number of
+// bytes sent.
+//
+// It is safe for concurrent access.
+type Counter struct {
+	desc
+	val atomicFloat
+}
+
+// NewCounter returns a new counter with the passed name and help text.
+func NewCounter(name, help string) *Counter {
+	return &Counter{desc: desc{name: name, help: help}}
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc() {
+	c.val.Add(1)
+}
+
+// Add increases the counter by the passed value.  Negative values are ignored
+// since counters must never decrease.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.val.Add(v)
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() float64 {
+	return c.val.Load()
+}
+
+// write writes the counter in the text exposition format.
+func (c *Counter) write(w *textWriter) {
+	w.header(&c.desc, typeCounter)
+	w.sample(c.name, nil, nil, c.Value())
+}
+
+// Gauge is a metric whose value may increase and decrease, such as the number
+// of connected peers.
+//
+// It is safe for concurrent access.
+type Gauge struct {
+	desc
+	val atomicFloat
+}
+
+// NewGauge returns a new gauge with the passed name and help text.
+func NewGauge(name, help string) *Gauge {
+	return &Gauge{desc: desc{name: name, help: help}}
+}
+
+// Set sets the gauge to the passed value.
+func (g *Gauge) Set(v float64) {
+	g.val.Store(v)
+}
+
+// Add adds the passed value, which may be negative, to the gauge.
+func (g *Gauge) Add(v float64) {
+	g.val.Add(v)
+}
+
+// Value returns the current value of the gauge.
+func (g *Gauge) Value() float64 {
+	return g.val.Load()
+}
+
+// write writes the gauge in the text exposition format.
+func (g *Gauge) write(w *textWriter) {
+	w.header(&g.desc, typeGauge)
+	w.sample(g.name, nil, nil, g.Value())
+}
+
+// valueFunc is a metric whose value is obtained from a function each time it
+// is collected.  It is the basis of CounterFunc and GaugeFunc.
+type valueFunc struct {
+	desc
+	typ string
+	fn  func() float64
+}
+
+// write writes the metric in the text exposition format.
+func (f *valueFunc) write(w *textWriter) {
+	w.header(&f.desc, f.typ)
+	w.sample(f.name, nil, nil, f.fn())
+}
+
+// CounterFunc is a counter whose value is obtained from a function each time
+// it is collected.  It is useful for exposing counters that are maintained
+// elsewhere.  The function must be safe for concurrent access and must never
+// return a lower value than it previously returned.
+type CounterFunc struct {
+	valueFunc
+}
+
+// NewCounterFunc returns a new counter with the passed name and help text whose
+// value is obtained from the passed function.
+func NewCounterFunc(name, help string, fn func() float64) *CounterFunc {
+	return &CounterFunc{valueFunc{
+		desc: desc{name: name, help: help},
+		typ:  typeCounter,
+		fn:   fn,
+	}}
+}
+
+// GaugeFunc is a gauge whose value is obtained from a function each time it is
+// collected.  It is useful for exposing values that are maintained elsewhere,
+// such as the current best chain height.  The function must be safe for
+// concurrent access.
+type GaugeFunc struct {
+	valueFunc
+}
+
+// NewGaugeFunc returns a new gauge with the passed name and help text whose
+// value is obtained from the passed function.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{valueFunc{
+		desc: desc{name: name, help: help},
+		typ:  typeGauge,
+		fn:   fn,
+	}}
+}
+
+// histogramData houses the observations of a histogram.
+type histogramData struct {
+	mtx     sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+// newHistogramData returns histogram data for the passed sorted bucket upper
+// bounds.
+func newHistogramData(buckets []float64) *histogramData {
+	return &histogramData{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// observe adds an observation to the histogram data.
+func (h *histogramData) observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mtx.Lock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+	h.mtx.Unlock()
+}
+
+// write writes the histogram data with the passed labels in the text exposition
+// format.
+func (h *histogramData) write(w *textWriter, name string, labelNames,
+	labelValues []string) {
+
+	h.mtx.Lock()
+	counts := make([]uint64, len(h.counts))
+	copy(counts, h.counts)
+	count, sum := h.count, h.sum
+	h.mtx.Unlock()
+
+	bucketLabels := append(append([]string(nil), labelNames...), "le")
+	bucketValues := append(append([]string(nil), labelValues...), "")
+	var cumulative uint64
+	for i, upper := range h.buckets {
+		cumulative += counts[i]
+		bucketValues[len(bucketValues)-1] = formatFloat(upper)
+		w.sample(name+"_bucket", bucketLabels, bucketValues,
+			float64(cumulative))
+	}
+	bucketValues[len(bucketValues)-1] = "+Inf"
+	w.sample(name+"_bucket", bucketLabels, bucketValues, float64(count))
+	w.sample(name+"_sum", labelNames, labelValues, sum)
+	w.sample(name+"_count", labelNames, labelValues, float64(count))
+}
+
+// checkBuckets returns a sorted copy of the passed histogram buckets with any
+// explicit +Inf bucket removed since it is always implied.  It panics when no
+// buckets remain.
+func checkBuckets(buckets []float64) []float64 {
+	sorted := make([]float64, 0, len(buckets))
+	for _, b := range buckets {
+		if !math.IsInf(b, 1) {
+			sorted = append(sorted, b)
+		}
+	}
+	if len(sorted) == 0 {
+		panic("metrics: histogram requires at least one bucket")
+	}
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// Histogram is a metric that samples observations, such as durations, and
+// counts them in configurable buckets along with their total sum and count.
+//
+// It is safe for concurrent access.
+type Histogram struct {
+	desc
+	data *histogramData
+}
+
+// NewHistogram returns a new histogram with the passed name, help text and
+// bucket upper bounds.  DefBuckets is used when no buckets are provided.
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	return &Histogram{
+		desc: desc{name: name, help: help},
+		data: newHistogramData(checkBuckets(buckets)),
+	}
+}
+
+// Observe adds an observation to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.data.observe(v)
+}
+
+// write writes the histogram in the text exposition format.
+func (h *Histogram) write(w *textWriter) {
+	w.header(&h.desc, typeHistogram)
+	h.data.write(w, h.name, nil, nil)
+}
+
+// vec houses a set of metrics of the same name that are partitioned by the
+// values of a fixed set of labels.
+type vec struct {
+	desc
+	labelNames []string
+
+	mtx     sync.RWMutex
+	metrics map[string]interface{}
+	values  map[string][]string
+}
+
+// newVec returns a new vec with the passed name, help text and label names.
+func newVec(name, help string, labelNames []string) vec {
+	return vec{
+		desc:       desc{name: name, help: help},
+		labelNames: labelNames,
+		metrics:    make(map[string]interface{}),
+		values:     make(map[string][]string),
+	}
+}
+
+// labels returns the label names of the metrics in the vec.
+func (v *vec) labels() []string {
+	return v.labelNames
+}
+
+// get returns the metric for the passed label values, creating it with the
+// passed function when it does not exist yet.  It panics when the number of
+// label values does not match the number of label names.
+func (v *vec) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(v.labelNames) {
+		panic("metrics: wrong number of label values for " + v.name)
+	}
+	key := strings.Join(labelValues, "\xff")
+
+	v.mtx.RLock()
+	m, ok := v.metrics[key]
+	v.mtx.RUnlock()
+	if ok {
+		return m
+	}
+
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	if m, ok := v.metrics[key]; ok {
+		return m
+	}
+	m = create()
+	v.metrics[key] = m
+	v.values[key] = append([]string(nil), labelValues...)
+	return m
+}
+
+// forEach invokes the passed function for every metric in the vec ordered by
+// their label values.
+func (v *vec) forEach(fn func(labelValues []string, m interface{})) {
+	v.mtx.RLock()
+	keys := make([]string, 0, len(v.metrics))
+	for key := range v.metrics {
+		keys = append(keys, key)
+	}
+	metrics := make([]interface{}, 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		metrics = append(metrics, v.metrics[key])
+	}
+	values := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, v.values[key])
+	}
+	v.mtx.RUnlock()
+
+	for i, m := range metrics {
+		fn(values[i], m)
+	}
+}
+
+// CounterVec is a set of counters partitioned by the values of a fixed set of
+// labels, such as the number of bytes sent per message type.
+//
+// It is safe for concurrent access.
+type CounterVec struct {
+	vec
+}
+
+// NewCounterVec returns a new counter vec with the passed name, help text and
+// label names.
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newVec(name, help, labelNames)}
+}
+
+// With returns the counter for the passed label values, which must be provided
+// in the same order as the label names.
+func (v *CounterVec) With(labelValues ...string) *Counter {
+	return v.get(labelValues, func() interface{} {
+		return NewCounter(v.name, v.help)
+	}).(*Counter)
+}
+
+// write writes the counters in the text exposition format.
+func (v *CounterVec) write(w *textWriter) {
+	w.header(&v.desc, typeCounter)
+	v.forEach(func(labelValues []string, m interface{}) {
+		w.sample(v.name, v.labelNames, labelValues,
+			m.(*Counter).Value())
+	})
+}
+
+// GaugeVec is a set of gauges partitioned by the values of a fixed set of
+// labels, such as the number of peers by direction.
+//
+// It is safe for concurrent access.
+type GaugeVec struct {
+	vec
+}
+
+// NewGaugeVec returns a new gauge vec with the passed name, help text and label
+// names.
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newVec(name, help, labelNames)}
+}
+
+// With returns the gauge for the passed label values, which must be provided in
+// the same order as the label names.
+func (v *GaugeVec) With(labelValues ...string) *Gauge {
+	return v.get(labelValues, func() interface{} {
+		return NewGauge(v.name, v.help)
+	}).(*Gauge)
+}
+
+// write writes the gauges in the text exposition format.
+func (v *GaugeVec) write(w *textWriter) {
+	w.header(&v.desc, typeGauge)
+	v.forEach(func(labelValues []string, m interface{}) {
+		w.sample(v.name, v.labelNames, labelValues,
+			m.(*Gauge).Value())
+	})
+}
+
+// HistogramVec is a set of histograms with the same buckets partitioned by the
+// values of a fixed set of labels, such as RPC latency per method.
+//
+// It is safe for concurrent access.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+// NewHistogramVec returns a new histogram vec with the passed name, help text,
+// bucket upper bounds and label names.  DefBuckets is used when no buckets are
+// provided.
+func NewHistogramVec(name, help string, buckets []float64,
+	labelNames ...string) *HistogramVec {
+
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	return &HistogramVec{
+		vec:     newVec(name, help, labelNames),
+		buckets: checkBuckets(buckets),
+	}
+}
+
+// With returns the histogram for the passed label values, which must be
+// provided in the same order as the label names.
+func (v *HistogramVec) With(labelValues ...string) *Histogram {
+	return v.get(labelValues, func() interface{} {
+		return &Histogram{
+			desc: v.desc,
+			data: newHistogramData(v.buckets),
+		}
+	}).(*Histogram)
+}
+
+// write writes the histograms in the text exposition format.
+func (v *HistogramVec) write(w *textWriter) {
+	w.header(&v.desc, typeHistogram)
+	v.forEach(func(labelValues []string, m interface{}) {
+		m.(*Histogram).data.write(w, v.name, v.labelNames, labelValues)
+	})
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestExposition ensures metrics of all types are written in the Prometheus
+// text exposition format.
+func TestExposition(t *testing.T) {
+	reg := NewRegistry()
+
+	counter := NewCounter("test_counter", "A counter.")
+	counter.Inc()
+	counter.Add(2.5)
+	counter.Add(-1)
+
+	gauge := NewGauge("test_gauge", "A gauge\nwith a newline.")
+	gauge.Set(10)
+	gauge.Add(-3)
+
+	gaugeFunc := NewGaugeFunc("test_gauge_func", "", func() float64 {
+		return math.Inf(1)
+	})
+	counterFunc := NewCounterFunc("test_counter_func", "A counter func.",
+		func() float64 { return 42 })
+
+	counterVec := NewCounterVec("test_counter_vec", "A counter vec.",
+		"direction", "command")
+	counterVec.With("sent", "tx").Add(100)
+	counterVec.With("received", `quo"te`).Inc()
+
+	hist := NewHistogram("test_histogram", "A histogram.",
+		[]float64{1, 0.5, math.Inf(1)})
+	hist.Observe(0.25)
+	hist.Observe(0.5)
+	hist.Observe(2)
+
+	histVec := NewHistogramVec("test_histogram_vec", "A histogram vec.",
+		[]float64{1}, "method")
+	histVec.With("getinfo").Observe(0.5)
+
+	gaugeVec := NewGaugeVec("test_gauge_vec", "A gauge vec.", "direction")
+	gaugeVec.With("inbound").Set(3)
+
+	reg.MustRegister(counter, gauge, gaugeFunc, counterFunc, counterVec,
+		hist, histVec, gaugeVec)
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: unexpected error: %v", err)
+	}
+
+	want := `# HELP test_counter A counter.
+# TYPE test_counter counter
+test_counter 3.5
+# HELP test_counter_func A counter func.
+# TYPE test_counter_func counter
+test_counter_func 42
+# HELP test_counter_vec A counter vec.
+# TYPE test_counter_vec counter
+test_counter_vec{direction="received",command="quo\"te"} 1
+test_counter_vec{direction="sent",command="tx"} 100
+# HELP test_gauge A gauge\nwith a newline.
+# TYPE test_gauge gauge
+test_gauge 7
+# TYPE test_gauge_func gauge
+test_gauge_func +Inf
+# HELP test_gauge_vec A gauge vec.
+# TYPE test_gauge_vec gauge
+test_gauge_vec{direction="inbound"} 3
+# HELP test_histogram A histogram.
+# TYPE test_histogram histogram
+test_histogram_bucket{le="0.5"} 2
+test_histogram_bucket{le="1"} 2
+test_histogram_bucket{le="+Inf"} 3
+test_histogram_sum 2.75
+test_histogram_count 3
+# HELP test_histogram_vec A histogram vec.
+# TYPE test_histogram_vec histogram
+test_histogram_vec_bucket{method="getinfo",le="1"} 1
+test_histogram_vec_bucket{method="getinfo",le="+Inf"} 1
+test_histogram_vec_sum{method="getinfo"} 0.5
+test_histogram_vec_count{method="getinfo"} 1
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("unexpected exposition:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRegister ensures invalid and duplicate metrics are rejected.
+func TestRegister(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(NewCounter("valid_name", "")); err != nil {
+		t.Fatalf("Register: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		c    Collector
+	}{
+		{"duplicate", NewGauge("valid_name", "")},
+		{"invalid name", NewGauge("invalid-name", "")},
+		{"invalid label", NewCounterVec("other", "", "bad label")},
+		{"reserved label", NewCounterVec("other", "", "__reserved")},
+	}
+	for _, test := range tests {
+		if err := reg.Register(test.c); err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+}
+
+// TestConcurrentUpdates ensures metrics may be updated concurrently.
+func TestConcurrentUpdates(t *testing.T) {
+	counter := NewCounterVec("concurrent", "", "label")
+	hist := NewHistogram("concurrent_hist", "", nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				counter.With("a").Inc()
+				hist.Observe(0.01)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := counter.With("a").Value(); got != 8000 {
+		t.Fatalf("unexpected counter value: got %v, want 8000", got)
+	}
+	if hist.data.count != 8000 {
+		t.Fatalf("unexpected histogram count: got %v, want 8000",
+			hist.data.count)
+	}
+}
+
+// TestServeHTTP ensures the registry serves metrics over HTTP.
+func TestServeHTTP(t *testing.T) {
+	reg := NewRegistry()
+	reg.MustRegister(NewGauge("served", "Served gauge."))
+
+	rec := httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Fatalf("unexpected content type: got %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), "served 0\n") {
+		t.Fatalf("unexpected body: %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	reg.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status for POST: got %d", rec.Code)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentType is the content type of the text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricNameRegexp matches valid metric and label names.
+var metricNameRegexp = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Collector is implemented by all metric types in this package.
+type Collector interface {
+	// Name returns the name of the metric.
+	Name() string
+
+	// write writes the metric in the text exposition format.
+	write(w *textWriter)
+}
+
+// Registry houses a set of metrics and exposes them in the Prometheus text
+// exposition format.
+//
+// It is safe for concurrent access.
+type Registry struct {
+	mtx        sync.RWMutex
+	collectors map[string]Collector
+}
+
+// Ensure Registry implements the http.Handler interface.
+var _ http.Handler = (*Registry)(nil)
+
+// NewRegistry returns a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds the passed metric to the registry.  An error is returned when
+// the name of the metric is invalid or a metric with the same name is already
+// registered.
+func (r *Registry) Register(c Collector) error {
+	name := c.Name()
+	if !metricNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid metric name %q", name)
+	}
+	if v, ok := c.(interface{ labels() []string }); ok {
+		for _, label := range v.labels() {
+			if !metricNameRegexp.MatchString(label) ||
+				strings.HasPrefix(label, "__") {
+
+				return fmt.Errorf("invalid label name %q for "+
+					"metric %q", label, name)
+			}
+		}
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if _, ok := r.collectors[name]; ok {
+		return fmt.Errorf("metric %q is already registered", name)
+	}
+	r.collectors[name] = c
+	return nil
+}
+
+// MustRegister adds the passed metrics to the registry and panics if any of
+// them can't be registered.
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WriteText writes all registered metrics ordered by name to the passed writer
+// in the Prometheus text exposition format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mtx.RLock()
+	collectors := make([]Collector, 0, len(r.collectors))
+	for _, c := range r.collectors {
+		collectors = append(collectors, c)
+	}
+	r.mtx.RUnlock()
+	sort.Slice(collectors, func(i, j int) bool {
+		return collectors[i].Name() < collectors[j].Name()
+	})
+
+	tw := &textWriter{w: bufio.NewWriter(w)}
+	for _, c := range collectors {
+		c.write(tw)
+	}
+	return tw.w.Flush()
+}
+
+// ServeHTTP responds with all registered metrics in the Prometheus text
+// exposition format.
+//
+// This is part of the http.Handler interface.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if req.Method == http.MethodHead {
+		return
+	}
+	r.WriteText(w)
+}
+
+// textWriter writes metrics in the Prometheus text exposition format.
+type textWriter struct {
+	w *bufio.Writer
+}
+
+// header writes the help text and type of a metric.
+func (w *textWriter) header(d *desc, typ string) {
+	if d.help != "" {
+		fmt.Fprintf(w.w, "# HELP %s %s\n", d.name, escapeHelp(d.help))
+	}
+	fmt.Fprintf(w.w, "# TYPE %s %s\n", d.name, typ)
+}
+
+// sample writes a single sample of a metric with the passed labels.
+func (w *textWriter) sample(name string, labelNames, labelValues []string,
+	v float64) {
+
+	w.w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(label)
+			w.w.WriteString(`="`)
+			w.w.WriteString(escapeLabelValue(labelValues[i]))
+			w.w.WriteByte('"')
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatFloat(v))
+	w.w.WriteByte('\n')
+}
+
+// helpEscaper and labelValueEscaper escape help text and label values as
+// required by the text exposition format.
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+// escapeHelp escapes the passed help text.
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+// escapeLabelValue escapes the passed label value.
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+// formatFloat formats the passed value as required by the text exposition
+// format.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btclog"
 	"github.com/btcsuite/btcutil"
 )
@@ -68,7 +69,8 @@ func (b *blockProgressLogger) LogBlockHeight(block *btcutil.Block) {
 	}
 	b.subsystemLogger.Infof("%s %d %s in the last %s (%d %s, height %d, %s)",
 		b.progressAction, b.receivedLogBlocks, blockStr, tDuration, b.receivedLogTx,
-		txStr, block.Height(), block.MsgBlock().Header.Timestamp)
+		txStr, jsonlog.Height(block.Height()),
+		block.MsgBlock().Header.Timestamp)
 
 	b.receivedLogBlocks = 0
 	b.receivedLogTx = 0
@@ -6,8 +6,25 @@
 Package netsync implements a concurrency safe block syncing protocol. The
 SyncManager communicates with connected peers to perform an initial block
 download, keep the chain and unconfirmed transaction pool in sync, and announce
-new blocks connected to the chain. Currently the sync manager selects a single
-sync peer that it downloads all blocks from until it is up to date with the
-longest chain the sync peer is aware of.
+new blocks connected to the chain. The sync manager selects a single sync peer
+that it downloads block headers from until it is up to date with the longest
+chain the sync peer is aware of.  The blocks described by the headers are
+downloaded in parallel from all full node peers within a moving window and are
+processed in order as they become available.  Peers that hold up the window are
+disconnected and their blocks are requested from other peers.
+
+Block headers are downloaded before the blocks they describe.  Up to the final
+checkpoint the headers are verified against the checkpoints.  Beyond it, or when
+checkpoints are disabled, the headers are first pre-synchronized while only
+keeping commitments to them until the sync peer proves its header chain has the
+minimum chain work defined by the network parameters.  They are then downloaded
+again, verified against the commitments, and only then are their blocks
+fetched.  This prevents peers from exhausting resources with low-work headers.
+
+The rate at which each peer delivers requested blocks and headers is measured.
+When the sync peer fails to deliver requested headers, or no progress is made,
+within the configured stall timeout, another sync peer is chosen.  Peers are
+preferred by their measured delivery rate and peers that recently stalled are
+avoided.
 */
 package netsync
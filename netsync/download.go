@@ -0,0 +1,544 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"sort"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/mempool"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// blockDownloadWindow is the maximum distance, in blocks, past the next
+	// block to be processed that blocks will be requested in headers-first
+	// mode.  Limiting the window bounds the number of blocks which have to
+	// be held in memory while waiting for a slow block.
+	blockDownloadWindow = 1024
+
+	// maxBlocksInFlightPerPeer is the maximum number of blocks that will be
+	// requested from a single peer at a time in headers-first mode.
+	maxBlocksInFlightPerPeer = 16
+
+	// maxDownloadedBlockBytes is the maximum combined size of the blocks
+	// that have been downloaded, but not yet processed, before no more
+	// blocks past the next block to be processed are requested.
+	maxDownloadedBlockBytes = 256 * 1024 * 1024
+
+	// minBlockStallTimeout is the initial amount of time a peer may hold up
+	// the block download window before it is disconnected.  The timeout is
+	// doubled each time a peer is disconnected for stalling, up to
+	// maxBlockStallTimeout, and decays back as blocks are processed so
+	// that slow connections do not cause all peers to be disconnected.
+	minBlockStallTimeout = 2 * time.Second
+
+	// maxBlockStallTimeout is the maximum amount of time a peer may hold up
+	// the block download window before it is disconnected.
+	maxBlockStallTimeout = 64 * time.Second
+
+	// blockStallSampleInterval is the interval at which peers holding up
+	// the block download window are checked.
+	blockStallSampleInterval = time.Second
+)
+
+// inFlightBlock tracks a block that has been requested from a peer by the block
+// download scheduler.
+type inFlightBlock struct {
+	node      *headerNode
+	peer      *peerpkg.Peer
+	state     *peerSyncState
+	requested time.Time
+}
+
+// downloadedBlock houses a block that has been downloaded by the block download
+// scheduler, but not processed yet, along with the peer that sent it.  A nil
+// block indicates the block was already known when it was scheduled.
+type downloadedBlock struct {
+	block *btcutil.Block
+	peer  *peerpkg.Peer
+	size  int
+}
+
+// resetBlockDownloads clears all state related to downloading blocks in
+// headers-first mode.  The blocks in flight are no longer tracked as requested
+// so they are not ignored when they are announced again later.
+func (sm *SyncManager) resetBlockDownloads() {
+	for hash, req := range sm.inFlightBlocks {
+		delete(sm.requestedBlocks, hash)
+		delete(req.state.requestedBlocks, hash)
+	}
+	for _, state := range sm.peerStates {
+		state.blocksInFlight = 0
+	}
+	sm.inFlightBlocks = make(map[chainhash.Hash]*inFlightBlock)
+	sm.retryBlocks = nil
+	sm.downloadedBlocks = make(map[chainhash.Hash]*downloadedBlock)
+	sm.downloadedBytes = 0
+	sm.stallingPeer = nil
+	if sm.blockStallTimeout == 0 {
+		sm.blockStallTimeout = minBlockStallTimeout
+	}
+}
+
+// downloadPeers returns the peers blocks may be downloaded from in
+// headers-first mode.  The sync peer, if any, is always first.
+func (sm *SyncManager) downloadPeers() []*peerpkg.Peer {
+	peers := make([]*peerpkg.Peer, 0, len(sm.peerStates))
+	if sm.syncPeer != nil {
+		peers = append(peers, sm.syncPeer)
+	}
+	for peer, state := range sm.peerStates {
+		if peer == sm.syncPeer || !state.syncCandidate ||
+			!peer.Connected() {
+
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// canServeBlock returns whether or not the passed peer is believed to have the
+// block for the passed header.
+func (sm *SyncManager) canServeBlock(peer *peerpkg.Peer, node *headerNode) bool {
+	return peer == sm.syncPeer || peer.LastBlock() >= node.height
+}
+
+// fetchHeaderBlocks requests the blocks for the headers in the header list from
+// all of the peers blocks may be downloaded from.  Blocks are only requested
+// within the download window past the next block to be processed and each peer
+// only has up to maxBlocksInFlightPerPeer blocks requested at a time.
+func (sm *SyncManager) fetchHeaderBlocks() {
+	front := sm.headerList.Front()
+	if front == nil {
+		return
+	}
+
+	// Only the next block to be processed may be requested once too many
+	// blocks are waiting to be processed.
+	frontNode := front.Value.(*headerNode)
+	windowEnd := frontNode.height + blockDownloadWindow
+	if sm.downloadedBytes >= maxDownloadedBlockBytes {
+		windowEnd = frontNode.height + 1
+	}
+
+	for _, peer := range sm.downloadPeers() {
+		sm.fetchPeerBlocks(peer, windowEnd)
+	}
+}
+
+// fetchPeerBlocks requests as many blocks from the passed peer as it has
+// capacity for, starting with blocks that need to be requested again and
+// followed by the next blocks in the header list, up to, but not including,
+// the given window end height.
+//
+// When the peer has capacity but nothing can be requested because of the
+// window, the peer that the first block in the window is being downloaded from
+// is marked as stalling the download.
+func (sm *SyncManager) fetchPeerBlocks(peer *peerpkg.Peer, windowEnd int32) {
+	state, exists := sm.peerStates[peer]
+	if !exists {
+		return
+	}
+	capacity := maxBlocksInFlightPerPeer - state.blocksInFlight
+	if capacity <= 0 {
+		return
+	}
+
+	gdmsg := wire.NewMsgGetDataSizeHint(uint(capacity))
+	defer func() {
+		if len(gdmsg.InvList) > 0 {
+			peer.QueueMessage(gdmsg, nil)
+		}
+	}()
+
+	// Request blocks that need to be requested again first since they are
+	// the oldest.  The retry list is sorted by height.
+	for i := 0; i < len(sm.retryBlocks) && capacity > 0; {
+		node := sm.retryBlocks[i]
+		if node.height >= windowEnd {
+			break
+		}
+		if !sm.canServeBlock(peer, node) {
+			i++
+			continue
+		}
+		sm.retryBlocks = append(sm.retryBlocks[:i], sm.retryBlocks[i+1:]...)
+		sm.requestBlock(peer, state, node, gdmsg)
+		capacity--
+	}
+
+	for sm.startHeader != nil && capacity > 0 {
+		node, ok := sm.startHeader.Value.(*headerNode)
+		if !ok {
+			log.Warn("Header list node type is not a headerNode")
+			sm.startHeader = sm.startHeader.Next()
+			continue
+		}
+		if node.height >= windowEnd {
+			sm.maybeMarkStallingPeer(peer)
+			return
+		}
+		if !sm.canServeBlock(peer, node) {
+			return
+		}
+		sm.startHeader = sm.startHeader.Next()
+
+		// Blocks that are already known will never be received, so
+		// mark them as downloaded in order to skip them.
+		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
+		haveInv, err := sm.haveInventory(iv)
+		if err != nil {
+			log.Warnf("Unexpected failure when checking for "+
+				"existing inventory during header block "+
+				"fetch: %v", err)
+		}
+		if haveInv {
+			sm.downloadedBlocks[*node.hash] = &downloadedBlock{}
+			continue
+		}
+
+		sm.requestBlock(peer, state, node, gdmsg)
+		capacity--
+	}
+}
+
+// requestBlock adds a request for the block described by the passed header to
+// the getdata message that will be sent to the peer and tracks it as being in
+// flight.
+func (sm *SyncManager) requestBlock(peer *peerpkg.Peer, state *peerSyncState,
+	node *headerNode, gdmsg *wire.MsgGetData) {
+
+	sm.requestedBlocks[*node.hash] = struct{}{}
+	state.requestedBlocks[*node.hash] = struct{}{}
+	state.blocksInFlight++
+	sm.inFlightBlocks[*node.hash] = &inFlightBlock{
+		node:      node,
+		peer:      peer,
+		state:     state,
+		requested: time.Now(),
+	}
+
+	// If we're fetching from a witness enabled peer post-fork, then ensure
+	// that we receive all the witness data in the blocks.
+	iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
+	if peer.IsWitnessEnabled() {
+		iv.Type = wire.InvTypeWitnessBlock
+	}
+	gdmsg.AddInvVect(iv)
+}
+
+// retryBlock queues the block described by the passed header to be requested
+// again, keeping the retry list sorted by height.
+func (sm *SyncManager) retryBlock(node *headerNode) {
+	i := sort.Search(len(sm.retryBlocks), func(i int) bool {
+		return sm.retryBlocks[i].height >= node.height
+	})
+	sm.retryBlocks = append(sm.retryBlocks, nil)
+	copy(sm.retryBlocks[i+1:], sm.retryBlocks[i:])
+	sm.retryBlocks[i] = node
+}
+
+// releaseInFlightBlock queues the block with the passed hash to be requested
+// from another peer when it is in flight from the peer with the passed sync
+// state.
+func (sm *SyncManager) releaseInFlightBlock(hash *chainhash.Hash, state *peerSyncState) {
+	req, exists := sm.inFlightBlocks[*hash]
+	if !exists || req.state != state {
+		return
+	}
+	delete(sm.inFlightBlocks, *hash)
+	state.blocksInFlight--
+	sm.retryBlock(req.node)
+}
+
+// maybeMarkStallingPeer marks the peer that the first block in the download
+// window is being downloaded from as stalling the download, unless it is the
+// passed peer, which is the one that is unable to request more blocks.
+func (sm *SyncManager) maybeMarkStallingPeer(waitingPeer *peerpkg.Peer) {
+	if sm.stallingPeer != nil {
+		return
+	}
+	front := sm.headerList.Front()
+	if front == nil {
+		return
+	}
+	req, exists := sm.inFlightBlocks[*front.Value.(*headerNode).hash]
+	if !exists || req.peer == waitingPeer {
+		return
+	}
+
+	log.Debugf("Peer %s is stalling the block download window at height "+
+		"%d", req.peer, req.node.height)
+	sm.stallingPeer = req.peer
+	sm.stallingSince = time.Now()
+}
+
+// handleBlockStallSample disconnects the peer that is stalling the block
+// download window when it has done so for longer than the block stall timeout
+// and reassigns the blocks that were being downloaded from it.
+func (sm *SyncManager) handleBlockStallSample() {
+	peer := sm.stallingPeer
+	if peer == nil || time.Since(sm.stallingSince) < sm.blockStallTimeout {
+		return
+	}
+	sm.stallingPeer = nil
+
+	log.Infof("Peer %s is stalling the block download for more than %v "+
+		"-- disconnecting", peer, sm.blockStallTimeout)
+	sm.blockStallTimeout *= 2
+	if sm.blockStallTimeout > maxBlockStallTimeout {
+		sm.blockStallTimeout = maxBlockStallTimeout
+	}
+
+	// The sync peer is replaced once it disconnects, which also resets the
+	// header state, so there is nothing more to do for it.
+	peer.Disconnect()
+	if peer == sm.syncPeer {
+		return
+	}
+	if state, exists := sm.peerStates[peer]; exists {
+		state.syncCandidate = false
+		sm.clearRequestedState(state)
+	}
+	sm.fetchHeaderBlocks()
+}
+
+// handleDownloadedBlock handles a block that was requested by the block
+// download scheduler.  The block is held until all of the blocks before it have
+// been processed, and the blocks that are now able to be processed are.
+func (sm *SyncManager) handleDownloadedBlock(block *btcutil.Block,
+	peer *peerpkg.Peer, state *peerSyncState) {
+
+	// The block may have been reassigned to another peer after it was
+	// requested from this one, so update the state of the peer it is
+	// currently assigned to.
+	blockHash := block.Hash()
+	req := sm.inFlightBlocks[*blockHash]
+	req.state.blocksInFlight--
+	delete(req.state.requestedBlocks, *blockHash)
+	delete(sm.inFlightBlocks, *blockHash)
+	delete(state.requestedBlocks, *blockHash)
+	delete(sm.requestedBlocks, *blockHash)
+
+	size := block.MsgBlock().SerializeSize()
+	sm.downloadedBlocks[*blockHash] = &downloadedBlock{
+		block: block,
+		peer:  peer,
+		size:  size,
+	}
+	sm.downloadedBytes += size
+
+	sm.processDownloadedBlocks()
+	sm.fetchHeaderBlocks()
+	sm.maybeFinishHeadersSync()
+}
+
+// processDownloadedBlocks processes the downloaded blocks at the front of the
+// header list in order until a block that has not been downloaded yet is
+// reached.
+func (sm *SyncManager) processDownloadedBlocks() {
+	for sm.headersFirstMode {
+		front := sm.headerList.Front()
+		if front == nil {
+			return
+		}
+		node := front.Value.(*headerNode)
+		dlBlock, exists := sm.downloadedBlocks[*node.hash]
+		if !exists {
+			return
+		}
+		delete(sm.downloadedBlocks, *node.hash)
+		sm.downloadedBytes -= dlBlock.size
+		sm.headerList.Remove(front)
+		sm.stallingPeer = nil
+
+		if dlBlock.block == nil {
+			continue
+		}
+		if !sm.processDownloadedBlock(node, dlBlock) {
+			return
+		}
+	}
+}
+
+// processDownloadedBlock processes a single downloaded block which is expected
+// to connect to the current best chain.  It returns whether or not processing
+// of further downloaded blocks should continue.
+func (sm *SyncManager) processDownloadedBlock(node *headerNode, dlBlock *downloadedBlock) bool {
+	// The block might have been received outside of the block download
+	// scheduler, such as when the sync peer changed while it was in
+	// flight.
+	blockHash := dlBlock.block.Hash()
+	if haveBlock, _ := sm.chain.HaveBlock(blockHash); haveBlock {
+		return true
+	}
+
+	// Blocks before the next checkpoint are eligible for less validation
+	// since the headers have already been verified to link together and
+	// are valid up to the checkpoint.
+	isCheckpointBlock := false
+	behaviorFlags := blockchain.BFNone
+	if sm.nextCheckpoint != nil {
+		behaviorFlags |= blockchain.BFFastAdd
+		isCheckpointBlock = node.hash.IsEqual(sm.nextCheckpoint.Hash)
+	}
+
+	peer := dlBlock.peer
+	_, isOrphan, err := sm.chain.ProcessBlock(dlBlock.block, behaviorFlags)
+	if err != nil {
+		// When the error is a rule error, it means the block was simply
+		// rejected as opposed to something actually going wrong, so log
+		// it as such.  Otherwise, something really did go wrong, so log
+		// it as an actual error.
+		if _, ok := err.(blockchain.RuleError); ok {
+			log.Infof("Rejected block %v from %s: %v", blockHash,
+				peer, err)
+		} else {
+			log.Errorf("Failed to process block %v: %v",
+				blockHash, err)
+		}
+		if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
+			database.ErrCorruption {
+			panic(dbErr)
+		}
+
+		// Convert the error into an appropriate reject message and
+		// send it.
+		code, reason := mempool.ErrToRejectErr(err)
+		peer.PushRejectMsg(wire.CmdBlock, code, reason, blockHash, false)
+
+		// A block that was rejected without being added to the block
+		// index does not match its header, so the peer that sent it is
+		// misbehaving and the block is requested from another peer.
+		if haveBlock, _ := sm.chain.HaveBlock(blockHash); !haveBlock {
+			log.Warnf("Block %v from %s does not match its header "+
+				"-- disconnecting", blockHash, peer.Addr())
+			peer.Disconnect()
+			if state, exists := sm.peerStates[peer]; exists {
+				state.syncCandidate = false
+			}
+			sm.headerList.PushFront(node)
+			sm.retryBlock(node)
+			return false
+		}
+
+		// Otherwise, the header chain the block is part of is invalid,
+		// so start over with another sync peer.
+		if sm.syncPeer != nil {
+			log.Warnf("Header chain from sync peer %s contains "+
+				"invalid block %v -- disconnecting", sm.syncPeer,
+				blockHash)
+			if state, exists := sm.peerStates[sm.syncPeer]; exists {
+				sm.clearRequestedState(state)
+			}
+			sm.updateSyncPeer(true)
+		}
+		return false
+	}
+	if isOrphan {
+		log.Warnf("Downloaded block %v at height %d is unexpectedly an "+
+			"orphan", blockHash, node.height)
+	}
+
+	// Gradually reduce the stall timeout again as blocks are processed.
+	sm.blockStallTimeout = sm.blockStallTimeout * 85 / 100
+	if sm.blockStallTimeout < minBlockStallTimeout {
+		sm.blockStallTimeout = minBlockStallTimeout
+	}
+
+	sm.lastProgressTime = time.Now()
+	sm.progressLogger.LogBlockHeight(dlBlock.block)
+	if peer.LastBlock() < node.height {
+		peer.UpdateLastBlockHeight(node.height)
+	}
+
+	// Clear the rejected transactions.
+	sm.rejectedTxns = make(map[chainhash.Hash]struct{})
+
+	if isCheckpointBlock {
+		sm.handleCheckpointBlock(node)
+	}
+	return true
+}
+
+// handleCheckpointBlock requests the headers up to the next checkpoint once the
+// block at the current next checkpoint has been processed.  When there are no
+// more checkpoints, a headers sync without checkpoints is started.
+func (sm *SyncManager) handleCheckpointBlock(node *headerNode) {
+	if sm.syncPeer == nil {
+		return
+	}
+
+	// When there is a next checkpoint, get the next round of headers by
+	// asking for headers starting from the block after this one up to the
+	// next checkpoint.  The checkpoint block is added to the header list
+	// so the next downloaded header can prove it links to the chain.
+	prevHeight := sm.nextCheckpoint.Height
+	prevHash := sm.nextCheckpoint.Hash
+	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
+	if sm.nextCheckpoint != nil {
+		sm.resetHeaderState(prevHash, prevHeight)
+		sm.headersFirstMode = true
+		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
+		err := sm.pushGetHeadersMsg(sm.syncPeer, locator,
+			sm.nextCheckpoint.Hash)
+		if err != nil {
+			log.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", sm.syncPeer.Addr(), err)
+			return
+		}
+		log.Infof("Downloading headers for blocks %d to %d from "+
+			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
+			sm.syncPeer.Addr())
+		return
+	}
+
+	// This is headers-first mode, the block is a checkpoint, and there are
+	// no more checkpoints, so continue downloading the headers after this
+	// one up to the end of the chain without a checkpoint.
+	log.Infof("Reached the final checkpoint -- continuing headers-first " +
+		"sync without checkpoints")
+	if err := sm.startHeadersSync(sm.syncPeer); err != nil {
+		log.Warnf("Failed to start headers sync with peer %s: %v",
+			sm.syncPeer.Addr(), err)
+	}
+}
+
+// maybeFinishHeadersSync switches from headers-first mode to normal mode once
+// all headers the sync peer has to offer have been synced without checkpoints
+// and all of the blocks they describe have been processed.  It also ensures any
+// blocks that were announced in the mean time are requested by requesting
+// blocks from the current best chain tip up to the end of the chain (zero
+// hash).
+func (sm *SyncManager) maybeFinishHeadersSync() {
+	if sm.headersSync == nil || !sm.headersSync.isComplete() ||
+		sm.headerList.Len() != 0 || sm.syncPeer == nil {
+
+		return
+	}
+
+	best := sm.chain.BestSnapshot()
+	sm.resetHeaderState(&best.Hash, best.Height)
+	log.Infof("Processed blocks for all synced headers -- switching to " +
+		"normal mode")
+	locator, err := sm.chain.LatestBlockLocator()
+	if err != nil {
+		log.Warnf("Failed to get block locator for the latest block: "+
+			"%v", err)
+		return
+	}
+	err = sm.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
+	if err != nil {
+		log.Warnf("Failed to send getblocks message to peer %s: %v",
+			sm.syncPeer.Addr(), err)
+	}
+}
@@ -0,0 +1,393 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/database"
+	_ "github.com/btcsuite/btcd/database/ffldb"
+	peerpkg "github.com/btcsuite/btcd/peer"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// makeTestBlocks returns a chain of valid blocks, which only contain a
+// coinbase transaction, that builds on the genesis block of the passed
+// regression test network parameters.
+func makeTestBlocks(params *chaincfg.Params, count int) []*btcutil.Block {
+	blocks := make([]*btcutil.Block, 0, count)
+	prev := &params.GenesisBlock.Header
+	for i := 0; i < count; i++ {
+		height := int32(i + 1)
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: *wire.NewOutPoint(&chainhash.Hash{},
+				wire.MaxPrevOutIndex),
+			SignatureScript: []byte{0x02, byte(height), byte(height >> 8)},
+			Sequence:        wire.MaxTxInSequenceNum,
+		})
+		coinbase.AddTxOut(wire.NewTxOut(blockchain.CalcBlockSubsidy(height,
+			params), []byte{0x51}))
+		merkles := blockchain.BuildMerkleTreeStore([]*btcutil.Tx{
+			btcutil.NewTx(coinbase)}, false)
+
+		msgBlock := &wire.MsgBlock{
+			Header: wire.BlockHeader{
+				Version:    1,
+				PrevBlock:  prev.BlockHash(),
+				MerkleRoot: *merkles[len(merkles)-1],
+				Timestamp:  prev.Timestamp.Add(time.Second),
+				Bits:       prev.Bits,
+			},
+			Transactions: []*wire.MsgTx{coinbase},
+		}
+		solveHeader(&msgBlock.Header)
+		blocks = append(blocks, btcutil.NewBlock(msgBlock))
+		prev = &msgBlock.Header
+	}
+	return blocks
+}
+
+// newTestSyncManager returns a sync manager in headers-first mode backed by a
+// new chain on a copy of the regression test network parameters along with a
+// function to tear it down.
+func newTestSyncManager(t *testing.T) (*SyncManager, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "netsync")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"), params.Net)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	teardown := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+	})
+	if err != nil {
+		teardown()
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	sm := &SyncManager{
+		chain:            chain,
+		chainParams:      &params,
+		rejectedTxns:     make(map[chainhash.Hash]struct{}),
+		requestedTxns:    make(map[chainhash.Hash]struct{}),
+		requestedBlocks:  make(map[chainhash.Hash]struct{}),
+		peerStates:       make(map[*peerpkg.Peer]*peerSyncState),
+		progressLogger:   newBlockProgressLogger("Processed", log),
+		headerList:       list.New(),
+		headersFirstMode: true,
+	}
+	sm.resetBlockDownloads()
+	return sm, teardown
+}
+
+// setTestHeaders adds header nodes for the passed block hashes, starting at
+// height one, to the header list of the sync manager so their blocks are
+// downloaded next.
+func setTestHeaders(sm *SyncManager, hashes []chainhash.Hash) {
+	for i := range hashes {
+		sm.headerList.PushBack(&headerNode{
+			height: int32(i + 1),
+			hash:   &hashes[i],
+		})
+	}
+	sm.startHeader = sm.headerList.Front()
+}
+
+// blockHashes returns the hashes of the passed blocks.
+func blockHashes(blocks []*btcutil.Block) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, len(blocks))
+	for _, block := range blocks {
+		hashes = append(hashes, *block.Hash())
+	}
+	return hashes
+}
+
+// fakeHashes returns the given number of distinct hashes of blocks that are
+// not known to the chain.
+func fakeHashes(count int) []chainhash.Hash {
+	hashes := make([]chainhash.Hash, 0, count)
+	for i := 0; i < count; i++ {
+		hashes = append(hashes, chainhash.Hash{byte(i), byte(i >> 8), 1})
+	}
+	return hashes
+}
+
+// addTestPeer adds a new sync candidate peer that announced the passed height
+// to the sync manager.
+func addTestPeer(sm *SyncManager, lastBlock int32) *peerpkg.Peer {
+	peer := peerpkg.NewInboundPeer(&peerpkg.Config{})
+	peer.UpdateLastBlockHeight(lastBlock)
+	sm.peerStates[peer] = &peerSyncState{
+		syncCandidate:   true,
+		requestedTxns:   make(map[chainhash.Hash]struct{}),
+		requestedBlocks: make(map[chainhash.Hash]struct{}),
+	}
+	return peer
+}
+
+// checkInFlight ensures the blocks with the passed hashes are in flight from
+// the given peer and requested from it.
+func checkInFlight(t *testing.T, sm *SyncManager, hashes []chainhash.Hash, peer *peerpkg.Peer) {
+	t.Helper()
+
+	state := sm.peerStates[peer]
+	for i := range hashes {
+		req, exists := sm.inFlightBlocks[hashes[i]]
+		if !exists {
+			t.Fatalf("block %v is not in flight", hashes[i])
+		}
+		if req.peer != peer || req.state != state {
+			t.Fatalf("block %v is in flight from peer %v, want %v",
+				hashes[i], req.peer, peer)
+		}
+		if _, exists := state.requestedBlocks[hashes[i]]; !exists {
+			t.Fatalf("block %v is not requested from peer %v",
+				hashes[i], peer)
+		}
+		if _, exists := sm.requestedBlocks[hashes[i]]; !exists {
+			t.Fatalf("block %v is not requested", hashes[i])
+		}
+	}
+}
+
+// TestBlockDownloadOutOfOrder ensures blocks that arrive out of order are held
+// until all of the blocks before them arrived and are then processed in order.
+func TestBlockDownloadOutOfOrder(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	blocks := makeTestBlocks(sm.chainParams, 3)
+	hashes := blockHashes(blocks)
+	setTestHeaders(sm, hashes)
+	peer := addTestPeer(sm, 3)
+	sm.syncPeer = peer
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes, peer)
+
+	// The later blocks must be held since the first one is missing.
+	for i := 2; i > 0; i-- {
+		sm.handleBlockMsg(&blockMsg{block: blocks[i], peer: peer})
+		if _, exists := sm.downloadedBlocks[hashes[i]]; !exists {
+			t.Fatalf("block %d was not held", i+1)
+		}
+		if height := sm.chain.BestSnapshot().Height; height != 0 {
+			t.Fatalf("unexpected best height %d, want 0", height)
+		}
+	}
+	if got := sm.peerStates[peer].blocksInFlight; got != 1 {
+		t.Fatalf("unexpected blocks in flight %d, want 1", got)
+	}
+
+	// All blocks are processed in order once the first one arrives.
+	sm.handleBlockMsg(&blockMsg{block: blocks[0], peer: peer})
+	if height := sm.chain.BestSnapshot().Height; height != 3 {
+		t.Fatalf("unexpected best height %d, want 3", height)
+	}
+	if len(sm.downloadedBlocks) != 0 || sm.downloadedBytes != 0 {
+		t.Fatalf("%d downloaded blocks (%d bytes) were not released",
+			len(sm.downloadedBlocks), sm.downloadedBytes)
+	}
+	if len(sm.inFlightBlocks) != 0 || len(sm.requestedBlocks) != 0 ||
+		sm.peerStates[peer].blocksInFlight != 0 {
+
+		t.Fatal("blocks are still in flight after being processed")
+	}
+	if sm.headerList.Len() != 0 {
+		t.Fatalf("%d headers left in the header list, want 0",
+			sm.headerList.Len())
+	}
+}
+
+// TestBlockDownloadPeerLimit ensures no more than the maximum number of blocks
+// are requested from a single peer and that the remaining blocks are requested
+// from other peers.
+func TestBlockDownloadPeerLimit(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	hashes := fakeHashes(maxBlocksInFlightPerPeer + 4)
+	setTestHeaders(sm, hashes)
+	syncPeer := addTestPeer(sm, int32(len(hashes)))
+	sm.syncPeer = syncPeer
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes[:maxBlocksInFlightPerPeer], syncPeer)
+	if len(sm.inFlightBlocks) != maxBlocksInFlightPerPeer {
+		t.Fatalf("unexpected blocks in flight %d, want %d",
+			len(sm.inFlightBlocks), maxBlocksInFlightPerPeer)
+	}
+
+	// Blocks are only requested from a peer that announced them.
+	peer := addTestPeer(sm, maxBlocksInFlightPerPeer+2)
+	sm.fetchPeerBlocks(peer, blockDownloadWindow)
+	checkInFlight(t, sm, hashes[maxBlocksInFlightPerPeer:maxBlocksInFlightPerPeer+2],
+		peer)
+	if len(sm.inFlightBlocks) != maxBlocksInFlightPerPeer+2 {
+		t.Fatalf("unexpected blocks in flight %d, want %d",
+			len(sm.inFlightBlocks), maxBlocksInFlightPerPeer+2)
+	}
+}
+
+// TestBlockDownloadReassign ensures blocks are requested from another peer
+// when the peer they were requested from does not have them or disconnects.
+func TestBlockDownloadReassign(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	hashes := fakeHashes(4)
+	setTestHeaders(sm, hashes)
+	syncPeer := addTestPeer(sm, 4)
+	peer := addTestPeer(sm, 4)
+	sm.syncPeer = syncPeer
+	sm.fetchPeerBlocks(peer, blockDownloadWindow)
+	checkInFlight(t, sm, hashes, peer)
+
+	// The block the peer does not have is requested from the sync peer.
+	notFound := wire.NewMsgNotFound()
+	notFound.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, &hashes[1]))
+	sm.handleNotFoundMsg(&notFoundMsg{notFound: notFound, peer: peer})
+	if len(sm.retryBlocks) != 1 || *sm.retryBlocks[0].hash != hashes[1] {
+		t.Fatalf("block 2 was not queued to be requested again")
+	}
+	if got := sm.peerStates[peer].blocksInFlight; got != 3 {
+		t.Fatalf("unexpected blocks in flight %d, want 3", got)
+	}
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes[1:2], syncPeer)
+	if len(sm.retryBlocks) != 0 {
+		t.Fatalf("%d blocks left to be requested again, want 0",
+			len(sm.retryBlocks))
+	}
+
+	// The remaining blocks are requested from the sync peer once the peer
+	// disconnects.
+	sm.handleDonePeerMsg(peer)
+	checkInFlight(t, sm, hashes, syncPeer)
+	if got := sm.peerStates[syncPeer].blocksInFlight; got != 4 {
+		t.Fatalf("unexpected blocks in flight %d, want 4", got)
+	}
+}
+
+// TestBlockDownloadWindow ensures blocks are only requested within the download
+// window past the next block to be processed and that the peer the next block
+// is requested from is marked as stalling once the window is exhausted.
+func TestBlockDownloadWindow(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	hashes := fakeHashes(blockDownloadWindow + 10)
+	setTestHeaders(sm, hashes)
+	slowPeer := addTestPeer(sm, int32(len(hashes)))
+	sm.syncPeer = slowPeer
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes[:maxBlocksInFlightPerPeer], slowPeer)
+
+	// Pretend all blocks but the last two in the window were requested
+	// from other peers.
+	for sm.startHeader.Value.(*headerNode).height < blockDownloadWindow-1 {
+		sm.startHeader = sm.startHeader.Next()
+	}
+
+	peer := addTestPeer(sm, int32(len(hashes)))
+	sm.syncPeer = peer
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes[blockDownloadWindow-2:blockDownloadWindow],
+		peer)
+	if got := sm.peerStates[peer].blocksInFlight; got != 2 {
+		t.Fatalf("unexpected blocks in flight %d, want 2", got)
+	}
+	next := sm.startHeader.Value.(*headerNode)
+	if next.height != blockDownloadWindow+1 {
+		t.Fatalf("unexpected next header height %d, want %d",
+			next.height, blockDownloadWindow+1)
+	}
+	if sm.stallingPeer != slowPeer {
+		t.Fatal("the peer holding up the window is not stalling")
+	}
+}
+
+// TestBlockDownloadStall ensures a peer that stalls the download window for
+// longer than the stall timeout is disconnected and the blocks requested from
+// it are requested from other peers.
+func TestBlockDownloadStall(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	hashes := fakeHashes(4)
+	setTestHeaders(sm, hashes)
+	syncPeer := addTestPeer(sm, 4)
+	peer := addTestPeer(sm, 4)
+	sm.syncPeer = syncPeer
+	sm.fetchPeerBlocks(peer, blockDownloadWindow)
+	checkInFlight(t, sm, hashes, peer)
+
+	// Nothing happens before the timeout.
+	sm.stallingPeer = peer
+	sm.stallingSince = time.Now()
+	sm.handleBlockStallSample()
+	if sm.stallingPeer != peer || !sm.peerStates[peer].syncCandidate {
+		t.Fatal("stalling peer was disconnected before the timeout")
+	}
+
+	sm.stallingSince = time.Now().Add(-minBlockStallTimeout)
+	sm.handleBlockStallSample()
+	if sm.stallingPeer != nil {
+		t.Fatal("stalling peer was not cleared")
+	}
+	state := sm.peerStates[peer]
+	if state.syncCandidate || state.blocksInFlight != 0 {
+		t.Fatal("stalling peer is still used to download blocks")
+	}
+	if sm.blockStallTimeout != 2*minBlockStallTimeout {
+		t.Fatalf("unexpected stall timeout %v, want %v",
+			sm.blockStallTimeout, 2*minBlockStallTimeout)
+	}
+	checkInFlight(t, sm, hashes, syncPeer)
+}
+
+// TestResetBlockDownloads ensures blocks that were in flight when the block
+// downloads are reset are no longer tracked as requested, so they are
+// requested again when they are announced.
+func TestResetBlockDownloads(t *testing.T) {
+	sm, teardown := newTestSyncManager(t)
+	defer teardown()
+
+	hashes := fakeHashes(2)
+	setTestHeaders(sm, hashes)
+	peer := addTestPeer(sm, 2)
+	sm.syncPeer = peer
+	sm.fetchHeaderBlocks()
+	checkInFlight(t, sm, hashes, peer)
+
+	sm.resetHeaderState(sm.chainParams.GenesisHash, 0)
+	state := sm.peerStates[peer]
+	if len(sm.inFlightBlocks) != 0 || state.blocksInFlight != 0 {
+		t.Fatal("blocks are still in flight after the reset")
+	}
+	if len(sm.requestedBlocks) != 0 || len(state.requestedBlocks) != 0 {
+		t.Fatal("blocks are still requested after the reset")
+	}
+}
@@ -0,0 +1,489 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// headerCommitmentPeriod is the interval, in headers, at which a single
+	// bit commitment to a header is stored while pre-synchronizing a header
+	// chain.  It is chosen along with redownloadBufferSize so that an
+	// attacker who is able to feed a different chain during the redownload
+	// would have to correctly guess a large number of commitment bits.
+	headerCommitmentPeriod = 584
+
+	// redownloadBufferSize is the number of headers that are held back
+	// during the redownload phase until enough commitments have been
+	// verified to trust them.  Only once the buffer exceeds this size, or
+	// the redownloaded chain reaches the minimum required work, are headers
+	// released to be fetched.
+	redownloadBufferSize = 13959
+
+	// maxBlocksPerSecond is the maximum rate at which the median time rule
+	// allows blocks to be produced.  It is used to bound the number of
+	// commitments a legitimate header chain could require.
+	maxBlocksPerSecond = 6
+
+	// maxTimeOffset is the maximum amount of time a header timestamp is
+	// allowed to be ahead of the current time.
+	maxTimeOffset = 2 * time.Hour
+)
+
+// errHeadersSyncAborted indicates a header sync was given up without the peer
+// necessarily misbehaving, such as when the header chain it serves does not
+// have enough work to be committed to.
+var errHeadersSyncAborted = errors.New("headers sync aborted")
+
+// errHeadersNotConnected indicates headers did not connect to the chain being
+// synced.  This happens without the peer misbehaving, such as when it announces
+// a new block or its chain was reorganized between requests, so the headers
+// should be requested again rather than the peer being disconnected.
+var errHeadersNotConnected = errors.New("headers do not connect")
+
+// headersSyncPhase represents the phases of a header sync.
+type headersSyncPhase int
+
+const (
+	// headersSyncPresync is the phase where headers are downloaded and
+	// checked for proof of work while only storing commitments to them
+	// until the chain is shown to have the minimum required work.
+	headersSyncPresync headersSyncPhase = iota
+
+	// headersSyncRedownload is the phase where the headers are downloaded
+	// again from the start and verified against the stored commitments
+	// before they are released.
+	headersSyncRedownload
+
+	// headersSyncCommitted is the phase where the header chain is known to
+	// have the minimum required work, so all further headers that connect
+	// are released as soon as they are checked.
+	headersSyncCommitted
+
+	// headersSyncFinal is the phase once the header sync has finished,
+	// either successfully or not.
+	headersSyncFinal
+)
+
+// String returns the phase as a human-readable name.
+func (p headersSyncPhase) String() string {
+	switch p {
+	case headersSyncPresync:
+		return "presync"
+	case headersSyncRedownload:
+		return "redownload"
+	case headersSyncCommitted:
+		return "committed"
+	case headersSyncFinal:
+		return "final"
+	}
+	return fmt.Sprintf("unknown phase (%d)", int(p))
+}
+
+// headerTip tracks the latest header of a chain of headers being processed.
+type headerTip struct {
+	hash   chainhash.Hash
+	height int32
+	bits   uint32
+}
+
+// headersSyncState implements a low memory, anti-DoS header sync against a
+// single peer.  Headers are first downloaded while only keeping a small number
+// of salted commitment bits until the peer proves its header chain has at least
+// the minimum chain work.  The headers are then downloaded a second time,
+// verified against the commitments, and only then released to be fetched.
+// This prevents a peer from exhausting memory with long low-work chains while
+// still allowing headers-first sync without checkpoints.
+//
+// Once the minimum chain work has been reached, or when the chain the sync
+// starts from already has it, headers are released as soon as they are
+// checked to connect and have valid proof of work.
+type headersSyncState struct {
+	chainParams *chaincfg.Params
+	minWork     *big.Int
+	phase       headersSyncPhase
+
+	// chainStart is the block the header chain is being synced from.  Its
+	// locator is appended to every request so the peer can find the fork
+	// point.
+	chainStart        headerTip
+	chainStartLocator blockchain.BlockLocator
+
+	// The commitment parameters are randomized per sync so a peer can not
+	// precompute a chain which satisfies them.
+	commitSalt     [8]byte
+	commitOffset   int32
+	commitments    []bool
+	maxCommitments int64
+
+	// The following fields are used during the presync phase.
+	presyncTip  headerTip
+	presyncWork *big.Int
+
+	// The following fields are used during the redownload and committed
+	// phases.  The commit tip refers to the latest header that has been
+	// checked, which may still be in the redownload buffer.
+	commitTip      headerTip
+	commitWork     *big.Int
+	redownloaded   []wire.BlockHeader
+	processAllRest bool
+
+	// complete is set once all headers the peer has to offer have been
+	// successfully synced.
+	complete bool
+}
+
+// newHeadersSyncState returns a new header sync state that syncs headers which
+// build on the given chain start block.  The median time of the start block is
+// used to bound the number of headers a valid chain could contain.
+func newHeadersSyncState(params *chaincfg.Params, startHash *chainhash.Hash,
+	startHeader *wire.BlockHeader, startHeight int32, startWork *big.Int,
+	startMedianTime time.Time, locator blockchain.BlockLocator) *headersSyncState {
+
+	start := headerTip{
+		hash:   *startHash,
+		height: startHeight,
+		bits:   startHeader.Bits,
+	}
+	h := &headersSyncState{
+		chainParams:       params,
+		minWork:           params.MinimumChainWork,
+		chainStart:        start,
+		chainStartLocator: locator,
+		presyncTip:        start,
+		presyncWork:       new(big.Int).Set(startWork),
+		commitTip:         start,
+		commitWork:        new(big.Int).Set(startWork),
+	}
+
+	// There is no need to presync when the chain being built on already
+	// has the minimum required work since any header chain that extends
+	// it has more.
+	if h.minWork == nil || startWork.Cmp(h.minWork) >= 0 {
+		h.phase = headersSyncCommitted
+		return h
+	}
+
+	// Randomize the commitments.  The offset does not need to be secure,
+	// so fall back to no offset in the unlikely event the random source
+	// fails.
+	var randBytes [12]byte
+	if _, err := rand.Read(randBytes[:]); err != nil {
+		log.Warnf("Unable to generate header commitment salt: %v", err)
+	}
+	copy(h.commitSalt[:], randBytes[:8])
+	offset := uint32(randBytes[8]) | uint32(randBytes[9])<<8 |
+		uint32(randBytes[10])<<16 | uint32(randBytes[11])<<24
+	h.commitOffset = int32(offset % headerCommitmentPeriod)
+
+	// A valid chain can not contain more headers than the median time rule
+	// allows to be produced between the start block and the maximum time
+	// a header is allowed to be in the future.
+	maxSeconds := time.Since(startMedianTime) + maxTimeOffset
+	maxHeaders := int64(maxSeconds/time.Second) * maxBlocksPerSecond
+	h.maxCommitments = maxHeaders / headerCommitmentPeriod
+	h.phase = headersSyncPresync
+	return h
+}
+
+// commitmentBit returns the salted commitment bit for the given header hash.
+func (h *headersSyncState) commitmentBit(hash *chainhash.Hash) bool {
+	var buf [8 + chainhash.HashSize]byte
+	copy(buf[:], h.commitSalt[:])
+	copy(buf[8:], hash[:])
+	return chainhash.HashB(buf[:])[0]&0x01 == 0x01
+}
+
+// checkHeader ensures the passed header connects to the given tip, has a
+// difficulty that could follow the tip, and satisfies its claimed proof of
+// work.  It returns the new tip on success.
+func (h *headersSyncState) checkHeader(tip *headerTip, header *wire.BlockHeader) (headerTip, error) {
+	hash := header.BlockHash()
+	if header.PrevBlock != tip.hash {
+		return headerTip{}, fmt.Errorf("%w: header %v does not connect "+
+			"to previous header %v", errHeadersNotConnected, hash,
+			tip.hash)
+	}
+
+	height := tip.height + 1
+	if !blockchain.PermittedDifficultyTransition(h.chainParams, height,
+		tip.bits, header.Bits) {
+
+		str := fmt.Sprintf("header %v at height %d has invalid "+
+			"difficulty bits %08x", hash, height, header.Bits)
+		return headerTip{}, errors.New(str)
+	}
+
+	err := blockchain.CheckHeaderProofOfWork(header, h.chainParams.PowLimit)
+	if err != nil {
+		return headerTip{}, err
+	}
+
+	return headerTip{hash: hash, height: height, bits: header.Bits}, nil
+}
+
+// processPresync checks the passed headers build on the presync chain and
+// stores commitments for them.  The sync moves to the redownload phase once
+// the presync chain has the minimum required work.
+func (h *headersSyncState) processPresync(headers []wire.BlockHeader) error {
+	for i := range headers {
+		tip, err := h.checkHeader(&h.presyncTip, &headers[i])
+		if err != nil {
+			return err
+		}
+
+		if tip.height%headerCommitmentPeriod == h.commitOffset {
+			h.commitments = append(h.commitments,
+				h.commitmentBit(&tip.hash))
+			if int64(len(h.commitments)) > h.maxCommitments {
+				str := fmt.Sprintf("header chain exceeds the "+
+					"maximum possible length at height %d",
+					tip.height)
+				return errors.New(str)
+			}
+		}
+
+		h.presyncWork.Add(h.presyncWork, blockchain.CalcWork(tip.bits))
+		h.presyncTip = tip
+	}
+
+	// Start downloading the headers again from the chain start once the
+	// presync chain has proven it has enough work.
+	if h.presyncWork.Cmp(h.minWork) >= 0 {
+		log.Infof("Header chain reached the minimum chain work at "+
+			"height %d -- redownloading headers", h.presyncTip.height)
+		h.phase = headersSyncRedownload
+	}
+	return nil
+}
+
+// processRedownload checks the passed headers build on the redownloaded chain
+// and match the commitments stored during the presync phase.  It returns any
+// headers that may be released from the redownload buffer.
+func (h *headersSyncState) processRedownload(headers []wire.BlockHeader) ([]headerNode, error) {
+	for i := range headers {
+		header := &headers[i]
+		tip, err := h.checkHeader(&h.commitTip, header)
+		if err != nil {
+			return nil, err
+		}
+
+		h.commitWork.Add(h.commitWork, blockchain.CalcWork(tip.bits))
+		if h.commitWork.Cmp(h.minWork) >= 0 {
+			h.processAllRest = true
+		}
+
+		// Headers beyond the point where the chain has the minimum
+		// work no longer need to be checked against commitments.
+		if !h.processAllRest &&
+			tip.height%headerCommitmentPeriod == h.commitOffset {
+
+			if len(h.commitments) == 0 {
+				str := fmt.Sprintf("redownloaded header chain "+
+					"exceeds the presynced chain at height %d",
+					tip.height)
+				return nil, errors.New(str)
+			}
+			expected := h.commitments[0]
+			h.commitments = h.commitments[1:]
+			if h.commitmentBit(&tip.hash) != expected {
+				str := fmt.Sprintf("redownloaded header %v at "+
+					"height %d does not match the presynced "+
+					"chain", tip.hash, tip.height)
+				return nil, errors.New(str)
+			}
+		}
+
+		h.redownloaded = append(h.redownloaded, *header)
+		h.commitTip = tip
+	}
+
+	// Release all buffered headers once the redownloaded chain has enough
+	// work, otherwise only release those beyond the buffer size.
+	numRelease := len(h.redownloaded) - redownloadBufferSize
+	if h.processAllRest {
+		numRelease = len(h.redownloaded)
+	}
+	if numRelease <= 0 {
+		return nil, nil
+	}
+	firstHeight := h.commitTip.height - int32(len(h.redownloaded)) + 1
+	released := makeHeaderNodes(h.redownloaded[:numRelease], firstHeight)
+	h.redownloaded = h.redownloaded[numRelease:]
+	if h.processAllRest {
+		h.redownloaded = nil
+		h.commitments = nil
+		h.phase = headersSyncCommitted
+	}
+	return released, nil
+}
+
+// processCommitted checks the passed headers build on the committed chain and
+// returns them to be released.  The headers which were checked before any
+// error are returned along with it since the commit tip already includes them.
+func (h *headersSyncState) processCommitted(headers []wire.BlockHeader) ([]headerNode, error) {
+	firstHeight := h.commitTip.height + 1
+	for i := range headers {
+		tip, err := h.checkHeader(&h.commitTip, &headers[i])
+		if err != nil {
+			return makeHeaderNodes(headers[:i], firstHeight), err
+		}
+		h.commitWork.Add(h.commitWork, blockchain.CalcWork(tip.bits))
+		h.commitTip = tip
+	}
+	return makeHeaderNodes(headers, firstHeight), nil
+}
+
+// processNextHeaders processes a batch of headers received from the peer in
+// response to a request made with the locator and stop hash provided by the
+// state.  The fullMessage flag indicates whether or not the message contained
+// the maximum number of headers, which means the peer likely has more.
+//
+// It returns the headers that have been verified to be part of a chain with
+// the minimum required work and therefore may have their blocks fetched, and
+// whether or not more headers should be requested.  An error is returned when
+// the headers are invalid, in which case the peer should be considered
+// misbehaving, or errHeadersSyncAborted when the sync can not continue
+// without the peer necessarily being at fault.  Headers that do not connect
+// return an error wrapping errHeadersNotConnected without ending the sync, so
+// the headers may be requested again.
+func (h *headersSyncState) processNextHeaders(headers []wire.BlockHeader,
+	fullMessage bool) ([]headerNode, bool, error) {
+
+	var released []headerNode
+	var err error
+	switch h.phase {
+	case headersSyncPresync:
+		err = h.processPresync(headers)
+		if err != nil {
+			break
+		}
+
+		// The peer has run out of headers before showing a chain with
+		// enough work, so there is nothing worth syncing from it.
+		if h.phase == headersSyncPresync && !fullMessage {
+			log.Debugf("Header chain ended at height %d without "+
+				"the minimum chain work", h.presyncTip.height)
+			err = errHeadersSyncAborted
+			break
+		}
+		return nil, true, nil
+
+	case headersSyncRedownload:
+		released, err = h.processRedownload(headers)
+		if err != nil {
+			break
+		}
+
+		// Always continue once the redownload is complete since the
+		// redownload stops at the end of the presynced chain, which may
+		// not be the end of the peer's chain.
+		if h.phase == headersSyncCommitted {
+			return released, true, nil
+		}
+		if !fullMessage {
+			log.Debugf("Header chain ended at height %d during "+
+				"redownload", h.commitTip.height)
+			err = errHeadersSyncAborted
+			break
+		}
+		return released, true, nil
+
+	case headersSyncCommitted:
+		released, err = h.processCommitted(headers)
+		if err != nil {
+			break
+		}
+		if !fullMessage {
+			h.complete = true
+			h.finalize()
+		}
+		return released, fullMessage, nil
+
+	case headersSyncFinal:
+		return nil, false, nil
+	}
+
+	// The headers which connected have been processed, so the sync may
+	// continue from them.
+	if errors.Is(err, errHeadersNotConnected) {
+		return released, true, err
+	}
+
+	h.finalize()
+	return nil, false, err
+}
+
+// nextHeadersRequest returns the locator and stop hash to use for the next
+// getheaders request for the current phase.
+func (h *headersSyncState) nextHeadersRequest() (blockchain.BlockLocator, *chainhash.Hash) {
+	tip := &h.commitTip
+	stopHash := &zeroHash
+	switch h.phase {
+	case headersSyncPresync:
+		tip = &h.presyncTip
+
+	case headersSyncRedownload:
+		// Only redownload up to the end of the presynced chain.  This
+		// also ensures the request differs from the initial presync
+		// request which starts from the same block.
+		stopHash = &h.presyncTip.hash
+	}
+
+	locator := make(blockchain.BlockLocator, 0, len(h.chainStartLocator)+1)
+	locator = append(locator, &tip.hash)
+	if tip.hash != h.chainStart.hash {
+		locator = append(locator, h.chainStartLocator...)
+	} else if len(h.chainStartLocator) > 1 {
+		locator = append(locator, h.chainStartLocator[1:]...)
+	}
+	return locator, stopHash
+}
+
+// atChainStart returns whether or not no headers have been processed yet, which
+// means the next headers are expected to build on the chain start block.
+func (h *headersSyncState) atChainStart() bool {
+	return h.phase != headersSyncFinal &&
+		h.presyncTip.hash == h.chainStart.hash &&
+		h.commitTip.hash == h.chainStart.hash
+}
+
+// isComplete returns whether or not all headers the peer has to offer have
+// been successfully synced.
+func (h *headersSyncState) isComplete() bool {
+	return h.complete
+}
+
+// finalize transitions the state to the final phase and frees any resources
+// that are no longer needed.
+func (h *headersSyncState) finalize() {
+	h.phase = headersSyncFinal
+	h.commitments = nil
+	h.redownloaded = nil
+}
+
+// makeHeaderNodes converts the passed headers, which are expected to be
+// connected and start at the given height, into header nodes.
+func makeHeaderNodes(headers []wire.BlockHeader, firstHeight int32) []headerNode {
+	nodes := make([]headerNode, 0, len(headers))
+	for i := range headers {
+		hash := headers[i].BlockHash()
+		nodes = append(nodes, headerNode{
+			height: firstHeight + int32(i),
+			hash:   &hash,
+		})
+	}
+	return nodes
+}
@@ -0,0 +1,279 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// solveHeader increments the nonce of the passed header until its hash
+// satisfies the proof of work claimed by its bits.
+func solveHeader(header *wire.BlockHeader) {
+	target := blockchain.CompactToBig(header.Bits)
+	for {
+		hash := header.BlockHash()
+		if blockchain.HashToBig(&hash).Cmp(target) <= 0 {
+			return
+		}
+		header.Nonce++
+	}
+}
+
+// makeHeaderChain returns a chain of solved headers that builds on the passed
+// previous header.  The extra nonce is mixed into the merkle root so separate
+// calls produce distinct chains.
+func makeHeaderChain(prev *wire.BlockHeader, count int, extraNonce byte) []wire.BlockHeader {
+	headers := make([]wire.BlockHeader, 0, count)
+	prevHash := prev.BlockHash()
+	timestamp := prev.Timestamp
+	for i := 0; i < count; i++ {
+		timestamp = timestamp.Add(time.Second)
+		header := wire.BlockHeader{
+			Version:    1,
+			PrevBlock:  prevHash,
+			MerkleRoot: chainhash.Hash{extraNonce},
+			Timestamp:  timestamp,
+			Bits:       prev.Bits,
+		}
+		solveHeader(&header)
+		headers = append(headers, header)
+		prevHash = header.BlockHash()
+	}
+	return headers
+}
+
+// newTestHeadersSync returns a headers sync state which syncs from the genesis
+// block of a copy of the regression test network parameters with the minimum
+// chain work set to the work of the given number of headers.
+func newTestHeadersSync(t *testing.T, minWorkHeaders int64) (*headersSyncState, *wire.BlockHeader) {
+	t.Helper()
+
+	params := chaincfg.RegressionNetParams
+	genesis := &params.GenesisBlock.Header
+	work := blockchain.CalcWork(genesis.Bits)
+	params.MinimumChainWork = new(big.Int).Mul(work,
+		big.NewInt(minWorkHeaders+1))
+
+	locator := blockchain.BlockLocator{params.GenesisHash}
+	h := newHeadersSyncState(&params, params.GenesisHash, genesis, 0, work,
+		genesis.Timestamp, locator)
+	return h, genesis
+}
+
+// TestHeadersSyncPresync ensures a header chain with the minimum chain work is
+// presynced, redownloaded, and then released.
+func TestHeadersSyncPresync(t *testing.T) {
+	h, genesis := newTestHeadersSync(t, 2500)
+	if h.phase != headersSyncPresync {
+		t.Fatalf("unexpected initial phase: got %v, want %v", h.phase,
+			headersSyncPresync)
+	}
+	chain := makeHeaderChain(genesis, 3000, 0)
+
+	// Presync the chain.  No headers are released while presyncing.
+	nodes, more, err := h.processNextHeaders(chain[:2000], true)
+	if err != nil || !more || len(nodes) != 0 {
+		t.Fatalf("presync: unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	nodes, more, err = h.processNextHeaders(chain[2000:], false)
+	if err != nil || !more || len(nodes) != 0 {
+		t.Fatalf("presync: unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	if h.phase != headersSyncRedownload {
+		t.Fatalf("unexpected phase: got %v, want %v", h.phase,
+			headersSyncRedownload)
+	}
+
+	// The redownload must start from the chain start and stop at the end
+	// of the presynced chain.
+	locator, stopHash := h.nextHeadersRequest()
+	if *locator[0] != *chaincfg.RegressionNetParams.GenesisHash {
+		t.Fatalf("unexpected redownload locator start %v", locator[0])
+	}
+	if *stopHash != chain[len(chain)-1].BlockHash() {
+		t.Fatalf("unexpected redownload stop hash %v", stopHash)
+	}
+
+	// Headers are held back until the minimum chain work is reached and
+	// then all of them are released.
+	nodes, more, err = h.processNextHeaders(chain[:2000], true)
+	if err != nil || !more || len(nodes) != 0 {
+		t.Fatalf("redownload: unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	nodes, more, err = h.processNextHeaders(chain[2000:], false)
+	if err != nil || !more || len(nodes) != len(chain) {
+		t.Fatalf("redownload: unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	for i := range nodes {
+		wantHash := chain[i].BlockHash()
+		if nodes[i].height != int32(i+1) || *nodes[i].hash != wantHash {
+			t.Fatalf("unexpected released header %d: got %d/%v, "+
+				"want %d/%v", i, nodes[i].height, nodes[i].hash,
+				i+1, wantHash)
+		}
+	}
+	if h.phase != headersSyncCommitted {
+		t.Fatalf("unexpected phase: got %v, want %v", h.phase,
+			headersSyncCommitted)
+	}
+
+	// Further headers are released immediately and an incomplete message
+	// completes the sync.
+	extra := makeHeaderChain(&chain[len(chain)-1], 10, 0)
+	nodes, more, err = h.processNextHeaders(extra, false)
+	if err != nil || more || len(nodes) != len(extra) {
+		t.Fatalf("committed: unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	if nodes[0].height != 3001 {
+		t.Fatalf("unexpected height: got %d, want 3001", nodes[0].height)
+	}
+	if !h.isComplete() {
+		t.Fatal("headers sync is not complete")
+	}
+}
+
+// TestHeadersSyncLowWork ensures a header chain which ends before reaching the
+// minimum chain work is aborted without releasing any headers.
+func TestHeadersSyncLowWork(t *testing.T) {
+	h, genesis := newTestHeadersSync(t, 5000)
+	chain := makeHeaderChain(genesis, 1000, 0)
+
+	nodes, more, err := h.processNextHeaders(chain, false)
+	if err != errHeadersSyncAborted {
+		t.Fatalf("unexpected error: got %v, want %v", err,
+			errHeadersSyncAborted)
+	}
+	if more || len(nodes) != 0 || h.isComplete() {
+		t.Fatalf("unexpected result (%d nodes, more %v, complete %v)",
+			len(nodes), more, h.isComplete())
+	}
+}
+
+// TestHeadersSyncCommitmentMismatch ensures a redownloaded chain which does not
+// match the commitments made during the presync is rejected.
+func TestHeadersSyncCommitmentMismatch(t *testing.T) {
+	h, genesis := newTestHeadersSync(t, 1500)
+	h.commitOffset = 100
+	chain := makeHeaderChain(genesis, 1500, 0)
+
+	_, more, err := h.processNextHeaders(chain, false)
+	if err != nil || !more || h.phase != headersSyncRedownload {
+		t.Fatalf("presync: unexpected result (more %v, phase %v): %v",
+			more, h.phase, err)
+	}
+
+	// Create an alternative chain whose header at the first commitment
+	// height has a different commitment bit.
+	alt := makeHeaderChain(genesis, 99, 1)
+	for extraNonce := byte(2); ; extraNonce++ {
+		header := makeHeaderChain(&alt[len(alt)-1], 1, extraNonce)[0]
+		hash := header.BlockHash()
+		if h.commitmentBit(&hash) != h.commitments[0] {
+			alt = append(alt, header)
+			break
+		}
+	}
+
+	_, _, err = h.processNextHeaders(alt, true)
+	if err == nil || err == errHeadersSyncAborted {
+		t.Fatalf("redownload: unexpected error %v", err)
+	}
+	if h.phase != headersSyncFinal || h.isComplete() {
+		t.Fatalf("unexpected phase %v (complete %v)", h.phase,
+			h.isComplete())
+	}
+}
+
+// TestHeadersSyncInvalidHeaders ensures headers which do not connect or do not
+// satisfy their proof of work are rejected, and that the sync continues from
+// the connecting headers when they do not connect.
+func TestHeadersSyncInvalidHeaders(t *testing.T) {
+	h, genesis := newTestHeadersSync(t, 100)
+	if !h.atChainStart() {
+		t.Fatal("new headers sync is not at the chain start")
+	}
+	chain := makeHeaderChain(genesis, 10, 0)
+	gapped := append(chain[:5:5], chain[6:]...)
+	_, more, err := h.processNextHeaders(gapped, true)
+	if !errors.Is(err, errHeadersNotConnected) || !more {
+		t.Fatalf("non-connecting headers: unexpected result (more "+
+			"%v): %v", more, err)
+	}
+	if h.phase != headersSyncPresync || h.atChainStart() ||
+		h.presyncTip.height != 5 {
+
+		t.Fatalf("unexpected state after non-connecting headers "+
+			"(phase %v, height %d)", h.phase, h.presyncTip.height)
+	}
+	if _, _, err := h.processNextHeaders(chain[5:], true); err != nil {
+		t.Fatalf("unexpected error continuing the sync: %v", err)
+	}
+
+	h, genesis = newTestHeadersSync(t, 100)
+	chain = makeHeaderChain(genesis, 10, 0)
+	for {
+		chain[9].Nonce++
+		hash := chain[9].BlockHash()
+		target := blockchain.CompactToBig(chain[9].Bits)
+		if blockchain.HashToBig(&hash).Cmp(target) > 0 {
+			break
+		}
+	}
+	if _, _, err := h.processNextHeaders(chain, true); err == nil {
+		t.Fatal("header with invalid proof of work was not rejected")
+	}
+}
+
+// TestHeadersSyncNoMinimumWork ensures headers are released immediately when
+// the chain being synced from already has the minimum chain work.
+func TestHeadersSyncNoMinimumWork(t *testing.T) {
+	params := chaincfg.RegressionNetParams
+	genesis := &params.GenesisBlock.Header
+	locator := blockchain.BlockLocator{params.GenesisHash}
+	h := newHeadersSyncState(&params, params.GenesisHash, genesis, 0,
+		blockchain.CalcWork(genesis.Bits), genesis.Timestamp, locator)
+	if h.phase != headersSyncCommitted {
+		t.Fatalf("unexpected initial phase: got %v, want %v", h.phase,
+			headersSyncCommitted)
+	}
+
+	chain := makeHeaderChain(genesis, 20, 0)
+	nodes, more, err := h.processNextHeaders(chain[:10], true)
+	if err != nil || !more || len(nodes) != 10 {
+		t.Fatalf("unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+
+	// The headers which connect are released even when later ones in the
+	// same message do not.
+	gapped := append(chain[10:15:15], chain[16:]...)
+	nodes, more, err = h.processNextHeaders(gapped, true)
+	if !errors.Is(err, errHeadersNotConnected) || !more || len(nodes) != 5 {
+		t.Fatalf("non-connecting headers: unexpected result (%d "+
+			"nodes, more %v): %v", len(nodes), more, err)
+	}
+	nodes, more, err = h.processNextHeaders(chain[15:], true)
+	if err != nil || !more || len(nodes) != 5 || nodes[0].height != 16 {
+		t.Fatalf("unexpected result (%d nodes, more %v): %v",
+			len(nodes), more, err)
+	}
+	locator, stopHash := h.nextHeadersRequest()
+	if *locator[0] != chain[len(chain)-1].BlockHash() || *stopHash != zeroHash {
+		t.Fatalf("unexpected next request %v/%v", locator[0], stopHash)
+	}
+}
@@ -5,6 +5,8 @@
 package netsync
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
@@ -25,6 +27,8 @@ type PeerNotifier interface {
 	RelayInventory(invVect *wire.InvVect, data interface{})
 
 	TransactionConfirmed(tx *btcutil.Tx)
+
+	SyncPeerChanged(syncPeer *peer.Peer)
 }
 
 // Config is a configuration struct used to initialize a new SyncManager.
@@ -37,5 +41,9 @@ type Config struct {
 	DisableCheckpoints bool
 	MaxPeers           int
 
+	// SyncStallTimeout is the time after which the sync peer is replaced
+	// when it has not made progress.  A default is used when it is zero.
+	SyncStallTimeout time.Duration
+
 	FeeEstimator *mempool.FeeEstimator
 }
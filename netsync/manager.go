@@ -6,7 +6,7 @@ package netsync
 
 import (
 	"container/list"
-	"math/rand"
+	"errors"
 	"net"
 	"sync"
 	"sync/atomic"
@@ -16,6 +16,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/mempool"
 	peerpkg "github.com/btcsuite/btcd/peer"
 	"github.com/btcsuite/btcd/wire"
@@ -23,11 +24,6 @@ import (
 )
 
 const (
-	// minInFlightBlocks is the minimum number of blocks that should be
-	// in the request queue for headers-first mode before requesting
-	// more.
-	minInFlightBlocks = 10
-
 	// maxRejectedTxns is the maximum number of rejected transactions
 	// hashes to store in memory.
 	maxRejectedTxns = 1000
@@ -40,13 +36,18 @@ const (
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
 
-	// maxStallDuration is the time after which we will disconnect our
-	// current sync peer if we haven't made progress.
+	// maxStallDuration is the default time after which we will disconnect
+	// our current sync peer if we haven't made progress.
 	maxStallDuration = 3 * time.Minute
 
 	// stallSampleInterval the interval at which we will check to see if our
 	// sync has stalled.
 	stallSampleInterval = 30 * time.Second
+
+	// maxUnconnectedHeaders is the number of consecutive headers messages
+	// which do not connect to the header chain being synced that are
+	// tolerated from the sync peer before choosing a new one.
+	maxUnconnectedHeaders = 10
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
@@ -152,6 +153,26 @@ type peerSyncState struct {
 	requestQueue    []*wire.InvVect
 	requestedTxns   map[chainhash.Hash]struct{}
 	requestedBlocks map[chainhash.Hash]struct{}
+
+	// blocksInFlight is the number of blocks requested from the peer by
+	// the block download scheduler that have not been received yet.
+	blocksInFlight int
+
+	// headersRequested is the time headers were requested from the peer
+	// while it is the sync peer.  It is the zero time when no headers are
+	// outstanding.
+	headersRequested time.Time
+
+	// lastStall is the time the peer was last replaced as the sync peer
+	// because it stalled.
+	lastStall time.Time
+
+	// unconnectedHeaders is the number of consecutive headers messages
+	// received from the peer while it is the sync peer which did not
+	// connect to the header chain being synced.
+	unconnectedHeaders int
+
+	throughput peerThroughput
 }
 
 // limitAdd is a helper function for maps that require a maximum limit by
@@ -198,11 +219,32 @@ type SyncManager struct {
 	peerStates       map[*peerpkg.Peer]*peerSyncState
 	lastProgressTime time.Time
 
-	// The following fields are used for headers-first mode.
+	// stallTimeout is the time after which the sync peer is replaced when
+	// it hasn't made progress.
+	stallTimeout         time.Duration
+	lastThroughputSample time.Time
+
+	// The following fields are used for headers-first mode.  When there is
+	// a next checkpoint, headers are synced up to it.  Otherwise, the
+	// headers sync state is used to sync headers of a chain with at least
+	// the minimum chain work.
 	headersFirstMode bool
 	headerList       *list.List
 	startHeader      *list.Element
 	nextCheckpoint   *chaincfg.Checkpoint
+	headersSync      *headersSyncState
+
+	// The following fields are used to download the blocks described by
+	// the headers in headers-first mode in parallel from all of the sync
+	// candidate peers.  Blocks may arrive out of order, so they are held
+	// until all of the blocks before them have been processed.
+	inFlightBlocks    map[chainhash.Hash]*inFlightBlock
+	retryBlocks       []*headerNode
+	downloadedBlocks  map[chainhash.Hash]*downloadedBlock
+	downloadedBytes   int
+	stallingPeer      *peerpkg.Peer
+	stallingSince     time.Time
+	blockStallTimeout time.Duration
 
 	// An optional fee estimator.
 	feeEstimator *mempool.FeeEstimator
@@ -214,6 +256,8 @@ func (sm *SyncManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight
 	sm.headersFirstMode = false
 	sm.headerList.Init()
 	sm.startHeader = nil
+	sm.headersSync = nil
+	sm.resetBlockDownloads()
 
 	// When there is a next checkpoint, add an entry for the latest known
 	// block into the header pool.  This allows the next downloaded header
@@ -309,18 +353,19 @@ func (sm *SyncManager) startSync() {
 		higherPeers = append(higherPeers, peer)
 	}
 
-	// Pick randomly from the set of peers greater than our block height,
-	// falling back to a random peer of the same height if none are greater.
-	//
-	// TODO(conner): Use a better algorithm to ranking peers based on
-	// observed metrics and/or sync in parallel.
+	// Pick the best ranked peer from the set of peers greater than our
+	// block height, falling back to a peer of the same height if none are
+	// greater.  Peers are ranked by their measured delivery rate, and
+	// peers which recently stalled are avoided.
 	var bestPeer *peerpkg.Peer
 	switch {
 	case len(higherPeers) > 0:
-		bestPeer = higherPeers[rand.Intn(len(higherPeers))]
+		sm.rankSyncPeers(higherPeers)
+		bestPeer = higherPeers[0]
 
 	case len(equalPeers) > 0:
-		bestPeer = equalPeers[rand.Intn(len(equalPeers))]
+		sm.rankSyncPeers(equalPeers)
+		bestPeer = equalPeers[0]
 	}
 
 	// Start syncing from the best peer if one was selected.
@@ -338,7 +383,7 @@ func (sm *SyncManager) startSync() {
 		}
 
 		log.Infof("Syncing to block height %d from peer %v",
-			bestPeer.LastBlock(), bestPeer.Addr())
+			jsonlog.Height(bestPeer.LastBlock()), bestPeer)
 
 		// When the current height is less than a known checkpoint we
 		// can use block headers to learn about which blocks comprise
@@ -353,23 +398,37 @@ func (sm *SyncManager) startSync() {
 		// full block hasn't been tampered with.
 		//
 		// Once we have passed the final checkpoint, or checkpoints are
-		// disabled, use standard inv messages learn about the blocks
-		// and fully validate them.  Finally, regression test mode does
-		// not support the headers-first approach so do normal block
-		// downloads when in regression test mode.
-		if sm.nextCheckpoint != nil &&
-			best.Height < sm.nextCheckpoint.Height &&
-			sm.chainParams != &chaincfg.RegressionNetParams {
-
-			bestPeer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+		// disabled, headers are still downloaded first, but they are
+		// only committed to once the peer has proven its header chain
+		// has the minimum chain work, and the blocks are fully
+		// validated.  Finally, regression test mode does not support
+		// the headers-first approach so do normal block downloads when
+		// in regression test mode.
+		switch {
+		case sm.chainParams == &chaincfg.RegressionNetParams:
+			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+
+		case sm.nextCheckpoint != nil &&
+			best.Height < sm.nextCheckpoint.Height:
+
+			sm.pushGetHeadersMsg(bestPeer, locator,
+				sm.nextCheckpoint.Hash)
 			sm.headersFirstMode = true
 			log.Infof("Downloading headers for blocks %d to "+
 				"%d from peer %s", best.Height+1,
 				sm.nextCheckpoint.Height, bestPeer.Addr())
-		} else {
+
+		case !sm.chain.IsCurrent():
+			if err := sm.startHeadersSync(bestPeer); err != nil {
+				log.Errorf("Failed to start headers sync with "+
+					"peer %s: %v", bestPeer.Addr(), err)
+				return
+			}
+
+		default:
 			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
 		}
-		sm.syncPeer = bestPeer
+		sm.setSyncPeer(bestPeer)
 
 		// Reset the last progress time now that we have a non-nil
 		// syncPeer to avoid instantly detecting it as stalled in the
@@ -380,6 +439,74 @@ func (sm *SyncManager) startSync() {
 	}
 }
 
+// startHeadersSync begins a headers-first sync which is not bound by a
+// checkpoint from the passed peer.  The headers are requested with a locator
+// for the current best chain tip using the headers sync state, which only
+// commits to the headers once the peer has proven its chain has the minimum
+// chain work.  The sync is moved to the fork point with the peer's chain once
+// its first headers are received.
+func (sm *SyncManager) startHeadersSync(peer *peerpkg.Peer) error {
+	best := sm.chain.BestSnapshot()
+	if err := sm.resetHeadersSync(peer, &best.Hash); err != nil {
+		return err
+	}
+	locator, stopHash := sm.headersSync.nextHeadersRequest()
+	return sm.pushGetHeadersMsg(peer, locator, stopHash)
+}
+
+// resetHeadersSync replaces the headers sync state with one that syncs headers
+// building on the passed main chain block.
+func (sm *SyncManager) resetHeadersSync(peer *peerpkg.Peer, startHash *chainhash.Hash) error {
+	height, err := sm.chain.BlockHeightByHash(startHash)
+	if err != nil {
+		return err
+	}
+	header, err := sm.chain.HeaderByHash(startHash)
+	if err != nil {
+		return err
+	}
+	work, err := sm.chain.ChainWork(startHash)
+	if err != nil {
+		return err
+	}
+	medianTime, err := sm.chain.PastMedianTimeByHash(startHash)
+	if err != nil {
+		return err
+	}
+	locator := sm.chain.BlockLocatorFromHash(startHash)
+
+	sm.headerList.Init()
+	sm.startHeader = nil
+	sm.resetBlockDownloads()
+	sm.headersSync = newHeadersSyncState(sm.chainParams, startHash,
+		&header, height, work, medianTime, locator)
+	sm.headersFirstMode = true
+
+	if sm.headersSync.phase == headersSyncPresync {
+		log.Infof("Pre-synchronizing headers from block %d with peer "+
+			"%s until the minimum chain work is reached",
+			height+1, peer.Addr())
+	} else {
+		log.Infof("Downloading headers from block %d from peer %s",
+			height+1, peer.Addr())
+	}
+	return nil
+}
+
+// pushGetHeadersMsg requests headers from the passed peer and records the time
+// they were requested so the peer can be replaced as the sync peer when it
+// fails to deliver them.
+func (sm *SyncManager) pushGetHeadersMsg(peer *peerpkg.Peer,
+	locator blockchain.BlockLocator, stopHash *chainhash.Hash) error {
+
+	if state, exists := sm.peerStates[peer]; exists &&
+		state.headersRequested.IsZero() {
+
+		state.headersRequested = time.Now()
+	}
+	return peer.PushGetHeadersMsg(locator, stopHash)
+}
+
 // isSyncCandidate returns whether or not the peer is a candidate to consider
 // syncing from.
 func (sm *SyncManager) isSyncCandidate(peer *peerpkg.Peer) bool {
@@ -440,33 +567,59 @@ func (sm *SyncManager) handleNewPeerMsg(peer *peerpkg.Peer) {
 	if isSyncCandidate && sm.syncPeer == nil {
 		sm.startSync()
 	}
+
+	// Put the new peer to work downloading blocks when a headers-first
+	// sync is already underway.
+	if isSyncCandidate && sm.headersFirstMode {
+		sm.fetchHeaderBlocks()
+	}
 }
 
-// handleStallSample will switch to a new sync peer if the current one has
-// stalled. This is detected when by comparing the last progress timestamp with
-// the current time, and disconnecting the peer if we stalled before reaching
-// their highest advertised block.
+// handleStallSample updates the measured delivery rates of all peers and will
+// switch to a new sync peer if the current one has stalled. This is detected
+// when the sync peer has not delivered requested headers within the stall
+// timeout, or by comparing the last progress timestamp with the current time,
+// and disconnecting the peer if we stalled before reaching their highest
+// advertised block.
 func (sm *SyncManager) handleStallSample() {
 	if atomic.LoadInt32(&sm.shutdown) != 0 {
 		return
 	}
 
+	sm.sampleThroughput()
+
 	// If we don't have an active sync peer, exit early.
 	if sm.syncPeer == nil {
 		return
 	}
 
-	// If the stall timeout has not elapsed, exit early.
-	if time.Since(sm.lastProgressTime) <= maxStallDuration {
-		return
-	}
-
 	// Check to see that the peer's sync state exists.
 	state, exists := sm.peerStates[sm.syncPeer]
 	if !exists {
 		return
 	}
 
+	// If the sync peer has neither failed to deliver requested headers nor
+	// stopped making progress within the stall timeout, exit early.
+	switch {
+	case !state.headersRequested.IsZero() &&
+		time.Since(state.headersRequested) > sm.stallTimeout:
+
+		log.Infof("Sync peer %s has not delivered requested headers "+
+			"for %v -- choosing new sync peer", sm.syncPeer,
+			time.Since(state.headersRequested).Truncate(time.Second))
+
+	case time.Since(sm.lastProgressTime) > sm.stallTimeout:
+		log.Debugf("No progress syncing from sync peer %s for %v",
+			sm.syncPeer,
+			time.Since(sm.lastProgressTime).Truncate(time.Second))
+
+	default:
+		return
+	}
+
+	state.lastStall = time.Now()
+	state.headersRequested = time.Time{}
 	sm.clearRequestedState(state)
 
 	disconnectSyncPeer := sm.shouldDCStalledSyncPeer()
@@ -512,11 +665,20 @@ func (sm *SyncManager) handleDonePeerMsg(peer *peerpkg.Peer) {
 	log.Infof("Lost peer %s", peer)
 
 	sm.clearRequestedState(state)
+	if peer == sm.stallingPeer {
+		sm.stallingPeer = nil
+	}
 
 	if peer == sm.syncPeer {
 		// Update the sync peer. The server has already disconnected the
 		// peer before signaling to the sync manager.
 		sm.updateSyncPeer(false)
+		return
+	}
+
+	// Reassign any blocks that were being downloaded from the peer.
+	if sm.headersFirstMode {
+		sm.fetchHeaderBlocks()
 	}
 }
 
@@ -531,11 +693,12 @@ func (sm *SyncManager) clearRequestedState(state *peerSyncState) {
 	}
 
 	// Remove requested blocks from the global map so that they will be
-	// fetched from elsewhere next time we get an inv.
-	// TODO: we could possibly here check which peers have these blocks
-	// and request them now to speed things up a little.
+	// fetched from elsewhere next time we get an inv.  Blocks that were
+	// requested by the block download scheduler are queued to be fetched
+	// from another peer.
 	for blockHash := range state.requestedBlocks {
 		delete(sm.requestedBlocks, blockHash)
+		sm.releaseInFlightBlock(&blockHash, state)
 	}
 }
 
@@ -552,16 +715,28 @@ func (sm *SyncManager) updateSyncPeer(dcSyncPeer bool) {
 		sm.syncPeer.Disconnect()
 	}
 
+	// Any outstanding headers request no longer applies to the peer.
+	if state, exists := sm.peerStates[sm.syncPeer]; exists {
+		state.headersRequested = time.Time{}
+	}
+
 	// Reset any header state before we choose our next active sync peer.
 	if sm.headersFirstMode {
 		best := sm.chain.BestSnapshot()
 		sm.resetHeaderState(&best.Hash, best.Height)
 	}
 
-	sm.syncPeer = nil
+	sm.setSyncPeer(nil)
 	sm.startSync()
 }
 
+// setSyncPeer makes the passed peer, which may be nil, the sync peer and
+// notifies the peer notifier of the change.
+func (sm *SyncManager) setSyncPeer(peer *peerpkg.Peer) {
+	sm.syncPeer = peer
+	sm.peerNotifier.SyncPeerChanged(peer)
+}
+
 // handleTxMsg handles transaction messages from all peers.
 func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	peer := tmsg.peer
@@ -613,10 +788,10 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 		// so log it as an actual error.
 		if _, ok := err.(mempool.RuleError); ok {
 			log.Debugf("Rejected transaction %v from %s: %v",
-				txHash, peer, err)
+				jsonlog.TxID(txHash), peer, err)
 		} else {
 			log.Errorf("Failed to process transaction %v: %v",
-				txHash, err)
+				jsonlog.TxID(txHash), err)
 		}
 
 		// Convert the error into an appropriate reject message and
@@ -669,33 +844,21 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 		// duplicate blocks.
 		if sm.chainParams != &chaincfg.RegressionNetParams {
 			log.Warnf("Got unrequested block %v from %s -- "+
-				"disconnecting", blockHash, peer.Addr())
+				"disconnecting", jsonlog.BlockHash(blockHash), peer)
 			peer.Disconnect()
 			return
 		}
+	} else {
+		state.throughput.recordBlock()
 	}
 
-	// When in headers-first mode, if the block matches the hash of the
-	// first header in the list of headers that are being fetched, it's
-	// eligible for less validation since the headers have already been
-	// verified to link together and are valid up to the next checkpoint.
-	// Also, remove the list entry for all blocks except the checkpoint
-	// since it is needed to verify the next round of headers links
-	// properly.
-	isCheckpointBlock := false
-	behaviorFlags := blockchain.BFNone
+	// Blocks requested by the block download scheduler in headers-first
+	// mode may arrive out of order, so they are held until all of the
+	// blocks before them have been processed.
 	if sm.headersFirstMode {
-		firstNodeEl := sm.headerList.Front()
-		if firstNodeEl != nil {
-			firstNode := firstNodeEl.Value.(*headerNode)
-			if blockHash.IsEqual(firstNode.hash) {
-				behaviorFlags |= blockchain.BFFastAdd
-				if firstNode.hash.IsEqual(sm.nextCheckpoint.Hash) {
-					isCheckpointBlock = true
-				} else {
-					sm.headerList.Remove(firstNodeEl)
-				}
-			}
+		if _, exists := sm.inFlightBlocks[*blockHash]; exists {
+			sm.handleDownloadedBlock(bmsg.block, peer, state)
+			return
 		}
 	}
 
@@ -707,18 +870,18 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, blockchain.BFNone)
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
 		// it as such.  Otherwise, something really did go wrong, so log
 		// it as an actual error.
 		if _, ok := err.(blockchain.RuleError); ok {
-			log.Infof("Rejected block %v from %s: %v", blockHash,
-				peer, err)
+			log.Infof("Rejected block %v from %s: %v",
+				jsonlog.BlockHash(blockHash), peer, err)
 		} else {
 			log.Errorf("Failed to process block %v: %v",
-				blockHash, err)
+				jsonlog.BlockHash(blockHash), err)
 		}
 		if dbErr, ok := err.(database.Error); ok && dbErr.ErrorCode ==
 			database.ErrCorruption {
@@ -804,118 +967,13 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 				peer)
 		}
 	}
-
-	// Nothing more to do if we aren't in headers-first mode.
-	if !sm.headersFirstMode {
-		return
-	}
-
-	// This is headers-first mode, so if the block is not a checkpoint
-	// request more blocks using the header list when the request queue is
-	// getting short.
-	if !isCheckpointBlock {
-		if sm.startHeader != nil &&
-			len(state.requestedBlocks) < minInFlightBlocks {
-			sm.fetchHeaderBlocks()
-		}
-		return
-	}
-
-	// This is headers-first mode and the block is a checkpoint.  When
-	// there is a next checkpoint, get the next round of headers by asking
-	// for headers starting from the block after this one up to the next
-	// checkpoint.
-	prevHeight := sm.nextCheckpoint.Height
-	prevHash := sm.nextCheckpoint.Hash
-	sm.nextCheckpoint = sm.findNextHeaderCheckpoint(prevHeight)
-	if sm.nextCheckpoint != nil {
-		locator := blockchain.BlockLocator([]*chainhash.Hash{prevHash})
-		err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
-		if err != nil {
-			log.Warnf("Failed to send getheaders message to "+
-				"peer %s: %v", peer.Addr(), err)
-			return
-		}
-		log.Infof("Downloading headers for blocks %d to %d from "+
-			"peer %s", prevHeight+1, sm.nextCheckpoint.Height,
-			sm.syncPeer.Addr())
-		return
-	}
-
-	// This is headers-first mode, the block is a checkpoint, and there are
-	// no more checkpoints, so switch to normal mode by requesting blocks
-	// from the block after this one up to the end of the chain (zero hash).
-	sm.headersFirstMode = false
-	sm.headerList.Init()
-	log.Infof("Reached the final checkpoint -- switching to normal mode")
-	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
-	err = peer.PushGetBlocksMsg(locator, &zeroHash)
-	if err != nil {
-		log.Warnf("Failed to send getblocks message to peer %s: %v",
-			peer.Addr(), err)
-		return
-	}
-}
-
-// fetchHeaderBlocks creates and sends a request to the syncPeer for the next
-// list of blocks to be downloaded based on the current list of headers.
-func (sm *SyncManager) fetchHeaderBlocks() {
-	// Nothing to do if there is no start header.
-	if sm.startHeader == nil {
-		log.Warnf("fetchHeaderBlocks called with no start header")
-		return
-	}
-
-	// Build up a getdata request for the list of blocks the headers
-	// describe.  The size hint will be limited to wire.MaxInvPerMsg by
-	// the function, so no need to double check it here.
-	gdmsg := wire.NewMsgGetDataSizeHint(uint(sm.headerList.Len()))
-	numRequested := 0
-	for e := sm.startHeader; e != nil; e = e.Next() {
-		node, ok := e.Value.(*headerNode)
-		if !ok {
-			log.Warn("Header list node type is not a headerNode")
-			continue
-		}
-
-		iv := wire.NewInvVect(wire.InvTypeBlock, node.hash)
-		haveInv, err := sm.haveInventory(iv)
-		if err != nil {
-			log.Warnf("Unexpected failure when checking for "+
-				"existing inventory during header block "+
-				"fetch: %v", err)
-		}
-		if !haveInv {
-			syncPeerState := sm.peerStates[sm.syncPeer]
-
-			sm.requestedBlocks[*node.hash] = struct{}{}
-			syncPeerState.requestedBlocks[*node.hash] = struct{}{}
-
-			// If we're fetching from a witness enabled peer
-			// post-fork, then ensure that we receive all the
-			// witness data in the blocks.
-			if sm.syncPeer.IsWitnessEnabled() {
-				iv.Type = wire.InvTypeWitnessBlock
-			}
-
-			gdmsg.AddInvVect(iv)
-			numRequested++
-		}
-		sm.startHeader = e.Next()
-		if numRequested >= wire.MaxInvPerMsg {
-			break
-		}
-	}
-	if len(gdmsg.InvList) > 0 {
-		sm.syncPeer.QueueMessage(gdmsg, nil)
-	}
 }
 
 // handleHeadersMsg handles block header messages from all peers.  Headers are
 // requested when performing a headers-first sync.
 func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	peer := hmsg.peer
-	_, exists := sm.peerStates[peer]
+	state, exists := sm.peerStates[peer]
 	if !exists {
 		log.Warnf("Received headers message from unknown peer %s", peer)
 		return
@@ -930,6 +988,17 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 		peer.Disconnect()
 		return
 	}
+	if !state.headersRequested.IsZero() {
+		state.headersRequested = time.Time{}
+		state.throughput.recordHeaders(numHeaders)
+	}
+
+	// Headers that are not bound by a checkpoint are handled by the
+	// headers sync state.
+	if sm.nextCheckpoint == nil {
+		sm.handleHeadersSyncMsg(hmsg)
+		return
+	}
 
 	// Nothing to do for an empty headers message.
 	if numHeaders == 0 {
@@ -1011,7 +1080,7 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	// headers starting from the latest known header and ending with the
 	// next checkpoint.
 	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
-	err := peer.PushGetHeadersMsg(locator, sm.nextCheckpoint.Hash)
+	err := sm.pushGetHeadersMsg(peer, locator, sm.nextCheckpoint.Hash)
 	if err != nil {
 		log.Warnf("Failed to send getheaders message to "+
 			"peer %s: %v", peer.Addr(), err)
@@ -1019,6 +1088,115 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 	}
 }
 
+// handleHeadersSyncMsg handles block header messages from the sync peer when
+// syncing headers without a checkpoint.  The headers are passed to the headers
+// sync state, which decides whether they may be committed to, and the blocks
+// for any committed headers are fetched.
+func (sm *SyncManager) handleHeadersSyncMsg(hmsg *headersMsg) {
+	peer := hmsg.peer
+	if peer != sm.syncPeer || sm.headersSync == nil {
+		log.Debugf("Ignoring %d headers from non-sync peer %s",
+			len(hmsg.headers.Headers), peer.Addr())
+		return
+	}
+	state := sm.peerStates[peer]
+
+	numHeaders := len(hmsg.headers.Headers)
+	headers := make([]wire.BlockHeader, 0, numHeaders)
+	for _, header := range hmsg.headers.Headers {
+		headers = append(headers, *header)
+	}
+	fullMessage := numHeaders == wire.MaxBlockHeadersPerMsg
+
+	// The first headers in response to the locator build on the fork
+	// point of the peer's chain with the main chain, which is not the
+	// current tip when it is stale or on a stale fork, so move the sync
+	// there.
+	if numHeaders > 0 && sm.headersSync.atChainStart() {
+		forkHash := &headers[0].PrevBlock
+		if *forkHash != sm.headersSync.chainStart.hash &&
+			sm.chain.MainChainHasBlock(forkHash) {
+
+			if err := sm.resetHeadersSync(peer, forkHash); err != nil {
+				log.Errorf("Failed to restart headers sync with "+
+					"peer %s: %v", peer.Addr(), err)
+				return
+			}
+		}
+	}
+
+	prevPhase := sm.headersSync.phase
+	nodes, requestMore, err := sm.headersSync.processNextHeaders(headers,
+		fullMessage)
+	switch {
+	case errors.Is(err, errHeadersNotConnected):
+		// Headers which do not connect are expected when the peer
+		// announces a block or reorganizes during the sync, so request
+		// the headers again unless it keeps happening.
+		state.unconnectedHeaders++
+		if state.unconnectedHeaders >= maxUnconnectedHeaders {
+			log.Infof("Peer %s repeatedly sent headers which do not "+
+				"connect -- choosing new sync peer", peer.Addr())
+			state.syncCandidate = false
+			sm.clearRequestedState(state)
+			sm.updateSyncPeer(false)
+			return
+		}
+		log.Debugf("Received headers during %v phase from peer %s "+
+			"which do not connect: %v -- requesting again",
+			prevPhase, peer.Addr(), err)
+
+	case err == errHeadersSyncAborted:
+		// The peer doesn't have a chain worth syncing, so stop
+		// considering it as a sync candidate and choose another.
+		log.Infof("Peer %s does not have a header chain with the "+
+			"minimum chain work -- choosing new sync peer", peer.Addr())
+		state.syncCandidate = false
+		sm.clearRequestedState(state)
+		sm.updateSyncPeer(false)
+		return
+
+	case err != nil:
+		log.Warnf("Received invalid headers during %v phase from peer "+
+			"%s: %v -- disconnecting", prevPhase, peer.Addr(), err)
+		peer.Disconnect()
+		return
+
+	default:
+		state.unconnectedHeaders = 0
+	}
+	sm.lastProgressTime = time.Now()
+
+	// Add the committed headers to the list of headers that need their
+	// blocks fetched.
+	for i := range nodes {
+		e := sm.headerList.PushBack(&nodes[i])
+		if sm.startHeader == nil {
+			sm.startHeader = e
+		}
+	}
+	if len(nodes) > 0 {
+		log.Debugf("Committed to headers for blocks %d to %d from "+
+			"peer %s", nodes[0].height, nodes[len(nodes)-1].height,
+			peer.Addr())
+	}
+
+	if requestMore {
+		locator, stopHash := sm.headersSync.nextHeadersRequest()
+		err := sm.pushGetHeadersMsg(peer, locator, stopHash)
+		if err != nil {
+			log.Warnf("Failed to send getheaders message to "+
+				"peer %s: %v", peer.Addr(), err)
+			return
+		}
+	}
+
+	if sm.startHeader != nil {
+		sm.fetchHeaderBlocks()
+	}
+	sm.maybeFinishHeadersSync()
+}
+
 // handleNotFoundMsg handles notfound messages from all peers.
 func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 	peer := nfmsg.peer
@@ -1037,6 +1215,7 @@ func (sm *SyncManager) handleNotFoundMsg(nfmsg *notFoundMsg) {
 			if _, exists := state.requestedBlocks[inv.Hash]; exists {
 				delete(state.requestedBlocks, inv.Hash)
 				delete(sm.requestedBlocks, inv.Hash)
+				sm.releaseInFlightBlock(&inv.Hash, state)
 			}
 
 		case wire.InvTypeWitnessTx:
@@ -1308,6 +1487,8 @@ func (sm *SyncManager) handleInvMsg(imsg *invMsg) {
 func (sm *SyncManager) blockHandler() {
 	stallTicker := time.NewTicker(stallSampleInterval)
 	defer stallTicker.Stop()
+	blockStallTicker := time.NewTicker(blockStallSampleInterval)
+	defer blockStallTicker.Stop()
 
 out:
 	for {
@@ -1344,6 +1525,9 @@ out:
 				}
 				msg.reply <- peerID
 
+			case getPeerSyncStatsMsg:
+				msg.reply <- sm.peerSyncStats()
+
 			case processBlockMsg:
 				_, isOrphan, err := sm.chain.ProcessBlock(
 					msg.block, msg.flags)
@@ -1374,6 +1558,9 @@ out:
 		case <-stallTicker.C:
 			sm.handleStallSample()
 
+		case <-blockStallTicker.C:
+			sm.handleBlockStallSample()
+
 		case <-sm.quit:
 			break out
 		}
@@ -1423,7 +1610,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalConfirmed)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1462,7 +1650,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalReorg)
 			}
 		}
 
@@ -1587,6 +1776,14 @@ func (sm *SyncManager) SyncPeerID() int32 {
 	return <-reply
 }
 
+// PeerSyncStats returns the sync statistics of all peers known to the sync
+// manager keyed by their ID.
+func (sm *SyncManager) PeerSyncStats() map[int32]*PeerSyncStats {
+	reply := make(chan map[int32]*PeerSyncStats)
+	sm.msgChan <- getPeerSyncStatsMsg{reply: reply}
+	return <-reply
+}
+
 // ProcessBlock makes use of ProcessBlock on an internal instance of a block
 // chain.
 func (sm *SyncManager) ProcessBlock(block *btcutil.Block, flags blockchain.BehaviorFlags) (bool, error) {
@@ -1632,6 +1829,13 @@ func New(config *Config) (*SyncManager, error) {
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
 	}
+	sm.resetBlockDownloads()
+
+	sm.stallTimeout = config.SyncStallTimeout
+	if sm.stallTimeout <= 0 {
+		sm.stallTimeout = maxStallDuration
+	}
+	sm.lastThroughputSample = time.Now()
 
 	best := sm.chain.BestSnapshot()
 	if !config.DisableCheckpoints {
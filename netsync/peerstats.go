@@ -0,0 +1,155 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+
+	peerpkg "github.com/btcsuite/btcd/peer"
+)
+
+const (
+	// throughputDecay is the weight given to the previously measured
+	// delivery rate of a peer when a new sample is taken.  The remainder is
+	// given to the rate measured over the most recent sample interval.
+	throughputDecay = 0.5
+)
+
+// PeerSyncStats houses statistics about the blocks and headers the sync manager
+// has requested from and received from a peer.
+type PeerSyncStats struct {
+	// InFlightHeights are the heights of the blocks that have been
+	// requested from the peer by the block download scheduler and not
+	// received yet.
+	InFlightHeights []int32
+
+	// BlocksReceived and HeadersReceived are the total number of requested
+	// blocks and headers received from the peer.
+	BlocksReceived  uint64
+	HeadersReceived uint64
+
+	// BlockRate and HeaderRate are the recent delivery rates of blocks and
+	// headers from the peer, in items per second.
+	BlockRate  float64
+	HeaderRate float64
+
+	// LastBlockTime and LastHeaderTime are the times the last requested
+	// block and headers were received from the peer.  They are the zero
+	// time when nothing has been received.
+	LastBlockTime  time.Time
+	LastHeaderTime time.Time
+}
+
+// getPeerSyncStatsMsg is a message type to be sent across the message channel
+// for retrieving the sync statistics of all peers.
+type getPeerSyncStatsMsg struct {
+	reply chan map[int32]*PeerSyncStats
+}
+
+// peerThroughput tracks the rate at which a peer delivers requested blocks and
+// headers.
+type peerThroughput struct {
+	blocksReceived  uint64
+	headersReceived uint64
+	lastBlockTime   time.Time
+	lastHeaderTime  time.Time
+
+	// The following fields are used to measure the delivery rates.  They
+	// hold the totals at the time of the previous sample along with the
+	// resulting moving average rates.
+	sampleBlocks  uint64
+	sampleHeaders uint64
+	blockRate     float64
+	headerRate    float64
+}
+
+// recordBlock records the receipt of a requested block.
+func (t *peerThroughput) recordBlock() {
+	t.blocksReceived++
+	t.lastBlockTime = time.Now()
+}
+
+// recordHeaders records the receipt of the passed number of requested headers.
+func (t *peerThroughput) recordHeaders(numHeaders int) {
+	t.headersReceived += uint64(numHeaders)
+	t.lastHeaderTime = time.Now()
+}
+
+// sample updates the delivery rates with the blocks and headers received
+// during the passed interval since the previous sample.
+func (t *peerThroughput) sample(interval time.Duration) {
+	secs := interval.Seconds()
+	if secs <= 0 {
+		return
+	}
+	blocks := float64(t.blocksReceived-t.sampleBlocks) / secs
+	headers := float64(t.headersReceived-t.sampleHeaders) / secs
+	t.blockRate = t.blockRate*throughputDecay + blocks*(1-throughputDecay)
+	t.headerRate = t.headerRate*throughputDecay + headers*(1-throughputDecay)
+	t.sampleBlocks = t.blocksReceived
+	t.sampleHeaders = t.headersReceived
+}
+
+// sampleThroughput updates the delivery rates of all peers.
+func (sm *SyncManager) sampleThroughput() {
+	now := time.Now()
+	interval := now.Sub(sm.lastThroughputSample)
+	sm.lastThroughputSample = now
+	for _, state := range sm.peerStates {
+		state.throughput.sample(interval)
+	}
+}
+
+// rankSyncPeers sorts the passed peers in the order they should be preferred as
+// the sync peer.  Peers that recently stalled as the sync peer come last and
+// the remaining peers are ordered by their measured block delivery rate.  Peers
+// with the same rate are ordered randomly.
+func (sm *SyncManager) rankSyncPeers(peers []*peerpkg.Peer) {
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	recentlyStalled := func(peer *peerpkg.Peer) bool {
+		stalled := sm.peerStates[peer].lastStall
+		return !stalled.IsZero() && time.Since(stalled) < sm.stallTimeout
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		iStalled, jStalled := recentlyStalled(peers[i]), recentlyStalled(peers[j])
+		if iStalled != jStalled {
+			return jStalled
+		}
+		return sm.peerStates[peers[i]].throughput.blockRate >
+			sm.peerStates[peers[j]].throughput.blockRate
+	})
+}
+
+// peerSyncStats returns the sync statistics of all peers keyed by their ID.
+func (sm *SyncManager) peerSyncStats() map[int32]*PeerSyncStats {
+	stats := make(map[int32]*PeerSyncStats, len(sm.peerStates))
+	for peer, state := range sm.peerStates {
+		t := &state.throughput
+		stats[peer.ID()] = &PeerSyncStats{
+			InFlightHeights: make([]int32, 0, state.blocksInFlight),
+			BlocksReceived:  t.blocksReceived,
+			HeadersReceived: t.headersReceived,
+			BlockRate:       t.blockRate,
+			HeaderRate:      t.headerRate,
+			LastBlockTime:   t.lastBlockTime,
+			LastHeaderTime:  t.lastHeaderTime,
+		}
+	}
+	for _, req := range sm.inFlightBlocks {
+		if s, ok := stats[req.peer.ID()]; ok {
+			s.InFlightHeights = append(s.InFlightHeights, req.node.height)
+		}
+	}
+	for _, s := range stats {
+		sort.Slice(s.InFlightHeights, func(i, j int) bool {
+			return s.InFlightHeights[i] < s.InFlightHeights[j]
+		})
+	}
+	return stats
+}
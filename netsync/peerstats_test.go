@@ -0,0 +1,72 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package netsync
+
+import (
+	"testing"
+	"time"
+
+	peerpkg "github.com/btcsuite/btcd/peer"
+)
+
+// TestPeerThroughput ensures the delivery rates of a peer are measured as a
+// moving average of the rates over each sample interval.
+func TestPeerThroughput(t *testing.T) {
+	var tp peerThroughput
+	for i := 0; i < 20; i++ {
+		tp.recordBlock()
+	}
+	tp.recordHeaders(2000)
+	tp.sample(10 * time.Second)
+	if tp.blockRate != 1 || tp.headerRate != 100 {
+		t.Fatalf("unexpected rates: got %v/%v, want 1/100",
+			tp.blockRate, tp.headerRate)
+	}
+
+	// Nothing was received during the next interval, so the rates decay.
+	tp.sample(10 * time.Second)
+	if tp.blockRate != 0.5 || tp.headerRate != 50 {
+		t.Fatalf("unexpected rates: got %v/%v, want 0.5/50",
+			tp.blockRate, tp.headerRate)
+	}
+	if tp.blocksReceived != 20 || tp.headersReceived != 2000 {
+		t.Fatalf("unexpected totals: got %d/%d, want 20/2000",
+			tp.blocksReceived, tp.headersReceived)
+	}
+}
+
+// TestRankSyncPeers ensures sync peer candidates are ranked by their measured
+// block delivery rate with peers that recently stalled ranked last.
+func TestRankSyncPeers(t *testing.T) {
+	sm := &SyncManager{
+		peerStates:   make(map[*peerpkg.Peer]*peerSyncState),
+		stallTimeout: maxStallDuration,
+	}
+	rates := []float64{1, 8, 4, 16}
+	peers := make([]*peerpkg.Peer, 0, len(rates))
+	for _, rate := range rates {
+		peer := peerpkg.NewInboundPeer(&peerpkg.Config{})
+		state := &peerSyncState{}
+		state.throughput.blockRate = rate
+		sm.peerStates[peer] = state
+		peers = append(peers, peer)
+	}
+
+	// The fastest peer recently stalled, so it must be ranked last.
+	sm.peerStates[peers[3]].lastStall = time.Now()
+	want := []*peerpkg.Peer{peers[1], peers[2], peers[0], peers[3]}
+
+	ranked := make([]*peerpkg.Peer, len(peers))
+	copy(ranked, peers)
+	sm.rankSyncPeers(ranked)
+	for i := range want {
+		if ranked[i] != want[i] {
+			t.Fatalf("unexpected peer at rank %d: got rate %v, "+
+				"want rate %v", i,
+				sm.peerStates[ranked[i]].throughput.blockRate,
+				sm.peerStates[want[i]].throughput.blockRate)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/go-socks/socks"
 	"github.com/davecgh/go-spew/spew"
@@ -361,6 +362,82 @@ type stallControlMsg struct {
 	message wire.Message
 }
 
+// OtherCommand is the command under which the traffic of messages that could
+// not be decoded, such as those with an unknown command, is accounted.
+const OtherCommand = "*other*"
+
+// MsgTraffic houses the number of messages and bytes sent and received for a
+// single message command.
+type MsgTraffic struct {
+	MsgsSent  uint64
+	MsgsRecv  uint64
+	BytesSent uint64
+	BytesRecv uint64
+}
+
+// Add adds the number of messages and bytes sent and received in the passed
+// traffic.
+func (t *MsgTraffic) Add(other *MsgTraffic) {
+	t.MsgsSent += other.MsgsSent
+	t.MsgsRecv += other.MsgsRecv
+	t.BytesSent += other.BytesSent
+	t.BytesRecv += other.BytesRecv
+}
+
+// MsgTrafficCounter tracks the number of messages and bytes sent and received
+// keyed by message command.  The zero value is ready for use.
+//
+// It is safe for concurrent access.
+type MsgTrafficCounter struct {
+	mtx     sync.Mutex
+	traffic map[string]*MsgTraffic
+}
+
+// Add accounts for a message with the passed number of bytes that was sent or
+// received.  The message is nil when it could not be decoded, in which case it
+// is accounted under OtherCommand.
+//
+// This function is safe for concurrent access.
+func (c *MsgTrafficCounter) Add(msg wire.Message, n int, sent bool) {
+	command := OtherCommand
+	if msg != nil {
+		command = msg.Command()
+	}
+
+	c.mtx.Lock()
+	if c.traffic == nil {
+		c.traffic = make(map[string]*MsgTraffic)
+	}
+	t, ok := c.traffic[command]
+	if !ok {
+		t = new(MsgTraffic)
+		c.traffic[command] = t
+	}
+	if sent {
+		t.MsgsSent++
+		t.BytesSent += uint64(n)
+	} else {
+		t.MsgsRecv++
+		t.BytesRecv += uint64(n)
+	}
+	c.mtx.Unlock()
+}
+
+// Traffic returns a copy of the messages and bytes sent and received keyed by
+// message command.
+//
+// This function is safe for concurrent access.
+func (c *MsgTrafficCounter) Traffic() map[string]MsgTraffic {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	traffic := make(map[string]MsgTraffic, len(c.traffic))
+	for command, t := range c.traffic {
+		traffic[command] = *t
+	}
+	return traffic
+}
+
 // StatsSnap is a snapshot of peer stats at a point in time.
 type StatsSnap struct {
 	ID             int32
@@ -380,6 +457,7 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	MsgTraffic     map[string]MsgTraffic
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -471,6 +549,10 @@ type Peer struct {
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
 
+	// msgTraffic tracks the messages and bytes sent and received per
+	// message command.
+	msgTraffic MsgTrafficCounter
+
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
 	sendQueue     chan outMsg
@@ -500,7 +582,7 @@ func (p *Peer) UpdateLastBlockHeight(newHeight int32) {
 		return
 	}
 	log.Tracef("Updating last block height of peer %v from %v to %v",
-		p.addr, p.lastBlock, newHeight)
+		p, p.lastBlock, jsonlog.Height(newHeight))
 	p.lastBlock = newHeight
 	p.statsMtx.Unlock()
 }
@@ -510,7 +592,8 @@ func (p *Peer) UpdateLastBlockHeight(newHeight int32) {
 //
 // This function is safe for concurrent access.
 func (p *Peer) UpdateLastAnnouncedBlock(blkHash *chainhash.Hash) {
-	log.Tracef("Updating last blk for peer %v, %v", p.addr, blkHash)
+	log.Tracef("Updating last blk for peer %v, %v", p,
+		jsonlog.BlockHash(blkHash))
 
 	p.statsMtx.Lock()
 	p.lastAnnouncedBlock = blkHash
@@ -558,12 +641,21 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		MsgTraffic:     p.MsgTraffic(),
 	}
 
 	p.statsMtx.RUnlock()
 	return statsSnap
 }
 
+// MsgTraffic returns the number of messages and bytes sent and received by the
+// peer keyed by message command.
+//
+// This function is safe for concurrent access.
+func (p *Peer) MsgTraffic() map[string]MsgTraffic {
+	return p.msgTraffic.Traffic()
+}
+
 // ID returns the peer id.
 //
 // This function is safe for concurrent access.
@@ -1014,6 +1106,9 @@ func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte,
 	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	if n > 0 {
+		p.msgTraffic.Add(msg, n, false)
+	}
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
@@ -1077,6 +1172,9 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) error {
 	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
 		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
+	if n > 0 {
+		p.msgTraffic.Add(msg, n, true)
+	}
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
@@ -2081,10 +2179,10 @@ func (p *Peer) writeLocalVersionMsg() error {
 // peer. The events should occur in the following order, otherwise an error is
 // returned:
 //
-//   1. Remote peer sends their version.
-//   2. We send our version.
-//   3. We send our verack.
-//   4. Remote peer sends their verack.
+//  1. Remote peer sends their version.
+//  2. We send our version.
+//  3. We send our verack.
+//  4. Remote peer sends their verack.
 func (p *Peer) negotiateInboundProtocol() error {
 	if err := p.readRemoteVersionMsg(); err != nil {
 		return err
@@ -2106,10 +2204,10 @@ func (p *Peer) negotiateInboundProtocol() error {
 // peer. The events should occur in the following order, otherwise an error is
 // returned:
 //
-//   1. We send our version.
-//   2. Remote peer sends their version.
-//   3. Remote peer sends their verack.
-//   4. We send our verack.
+//  1. We send our version.
+//  2. Remote peer sends their version.
+//  3. Remote peer sends their verack.
+//  4. We send our verack.
 func (p *Peer) negotiateOutboundProtocol() error {
 	if err := p.writeLocalVersionMsg(); err != nil {
 		return err
@@ -2150,7 +2248,7 @@ func (p *Peer) start() error {
 		p.Disconnect()
 		return errors.New("protocol negotiation timeout")
 	}
-	log.Debugf("Connected to %s", p.Addr())
+	log.Debugf("Connected to %s", p)
 
 	// The protocol has been negotiated successfully so start processing input
 	// and output messages.
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"io"
 	"net"
+	"reflect"
 	"strconv"
 	"testing"
 	"time"
@@ -110,6 +111,7 @@ type peerStats struct {
 	wantBytesSent       uint64
 	wantBytesReceived   uint64
 	wantWitnessEnabled  bool
+	wantMsgTraffic      map[string]peer.MsgTraffic
 }
 
 // testPeer tests the given peer's flags and stats
@@ -214,6 +216,12 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		t.Errorf("testPeer: wrong LastRecv - got %v, want %v", p.LastRecv(), stats.LastRecv)
 		return
 	}
+
+	if !reflect.DeepEqual(stats.MsgTraffic, s.wantMsgTraffic) {
+		t.Errorf("testPeer: wrong MsgTraffic - got %v, want %v",
+			stats.MsgTraffic, s.wantMsgTraffic)
+		return
+	}
 }
 
 // TestPeerConnection tests connection between inbound and outbound peers.
@@ -249,6 +257,10 @@ func TestPeerConnection(t *testing.T) {
 		TrickleInterval:   time.Second * 10,
 	}
 
+	handshakeTraffic := map[string]peer.MsgTraffic{
+		wire.CmdVersion: {MsgsSent: 1, MsgsRecv: 1, BytesSent: 143, BytesRecv: 143},
+		wire.CmdVerAck:  {MsgsSent: 1, MsgsRecv: 1, BytesSent: 24, BytesRecv: 24},
+	}
 	wantStats1 := peerStats{
 		wantUserAgent:       wire.DefaultUserAgent + "peer:1.0(comment)/",
 		wantServices:        0,
@@ -262,6 +274,7 @@ func TestPeerConnection(t *testing.T) {
 		wantTimeOffset:      int64(0),
 		wantBytesSent:       167, // 143 version + 24 verack
 		wantBytesReceived:   167,
+		wantMsgTraffic:      handshakeTraffic,
 		wantWitnessEnabled:  false,
 	}
 	wantStats2 := peerStats{
@@ -277,6 +290,7 @@ func TestPeerConnection(t *testing.T) {
 		wantTimeOffset:      int64(0),
 		wantBytesSent:       167, // 143 version + 24 verack
 		wantBytesReceived:   167,
+		wantMsgTraffic:      handshakeTraffic,
 		wantWitnessEnabled:  true,
 	}
 
@@ -983,6 +997,28 @@ func TestUpdateLastBlockHeight(t *testing.T) {
 	}
 }
 
+// TestMsgTrafficCounter ensures messages are accounted by command and
+// direction, and that undecoded messages are accounted as other traffic.
+func TestMsgTrafficCounter(t *testing.T) {
+	var counter peer.MsgTrafficCounter
+	if traffic := counter.Traffic(); len(traffic) != 0 {
+		t.Fatalf("unexpected traffic of zero value: %v", traffic)
+	}
+
+	counter.Add(wire.NewMsgPing(1), 32, true)
+	counter.Add(wire.NewMsgPing(2), 32, false)
+	counter.Add(wire.NewMsgPing(3), 32, false)
+	counter.Add(nil, 100, false)
+	want := map[string]peer.MsgTraffic{
+		wire.CmdPing: {MsgsSent: 1, MsgsRecv: 2, BytesSent: 32,
+			BytesRecv: 64},
+		peer.OtherCommand: {MsgsRecv: 1, BytesRecv: 100},
+	}
+	if traffic := counter.Traffic(); !reflect.DeepEqual(traffic, want) {
+		t.Fatalf("unexpected traffic: got %v, want %v", traffic, want)
+	}
+}
+
 func init() {
 	// Allow self connection when running the tests.
 	peer.TstAllowSelfConns()
@@ -0,0 +1,110 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// ErrMissingUtxo indicates the fee of a PSBT could not be calculated since the
+// output spent by one of its inputs is unknown.
+var ErrMissingUtxo = errors.New("output spent by an input is unknown")
+
+// Role identifies a role of BIP0174 that acts on a PSBT.  The roles are
+// ordered by the stage of a PSBT they act on.
+type Role int
+
+// These constants define the roles of BIP0174 in the order they act on a PSBT.
+const (
+	RoleCreator Role = iota
+	RoleUpdater
+	RoleSigner
+	RoleFinalizer
+	RoleExtractor
+)
+
+// Map of roles back to their constant names for pretty printing.
+var roleStrings = map[Role]string{
+	RoleCreator:   "creator",
+	RoleUpdater:   "updater",
+	RoleSigner:    "signer",
+	RoleFinalizer: "finalizer",
+	RoleExtractor: "extractor",
+}
+
+// String returns the role as a human-readable name.
+func (r Role) String() string {
+	if s := roleStrings[r]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown Role (%d)", int(r))
+}
+
+// InputAnalysis describes the state of an input of a PSBT and what is needed
+// to finalize it.  The missing public keys and signatures are identified by
+// the hash160 of their public keys, the missing redeem script by its hash160
+// and the missing witness script by its sha256 hash.
+type InputAnalysis struct {
+	HasUtxo              bool
+	IsFinal              bool
+	MissingPubKeys       [][]byte
+	MissingSigs          [][]byte
+	MissingRedeemScript  []byte
+	MissingWitnessScript []byte
+	Next                 Role
+}
+
+// AnalyzeInput returns the state of the passed input of the PSBT along with
+// the role that has to act on it next.
+func (p *Packet) AnalyzeInput(i int) *InputAnalysis {
+	in := &p.Inputs[i]
+	prevOut, hasUtxo := p.PrevOutput(i)
+	analysis := &InputAnalysis{HasUtxo: hasUtxo}
+	switch {
+	case in.IsFinalized():
+		analysis.IsFinal = true
+		analysis.Next = RoleExtractor
+
+	case !hasUtxo:
+		analysis.Next = RoleUpdater
+
+	default:
+		var missing missingInfo
+		_, _, ok := in.solve(prevOut.PkScript, &missing)
+		analysis.MissingPubKeys = missing.pubKeys
+		analysis.MissingSigs = missing.sigs
+		analysis.MissingRedeemScript = missing.redeemScript
+		analysis.MissingWitnessScript = missing.witnessScript
+		switch {
+		case ok:
+			analysis.Next = RoleFinalizer
+		case len(missing.sigs) != 0:
+			analysis.Next = RoleSigner
+		default:
+			analysis.Next = RoleUpdater
+		}
+	}
+	return analysis
+}
+
+// Fee returns the fee paid by the transaction of the PSBT.  ErrMissingUtxo is
+// returned when the output spent by an input is unknown.
+func (p *Packet) Fee() (btcutil.Amount, error) {
+	var fee int64
+	for i := range p.Inputs {
+		prevOut, ok := p.PrevOutput(i)
+		if !ok {
+			return 0, ErrMissingUtxo
+		}
+		fee += prevOut.Value
+	}
+	for _, txOut := range p.UnsignedTx.TxOut {
+		fee -= txOut.Value
+	}
+	return btcutil.Amount(fee), nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import "bytes"
+
+// mergeUnknowns adds the unknown key-value pairs of src with keys that are not
+// in dst to dst.
+func mergeUnknowns(dst, src []*Unknown) []*Unknown {
+next:
+	for _, u := range src {
+		for _, existing := range dst {
+			if bytes.Equal(existing.Key, u.Key) {
+				continue next
+			}
+		}
+		dst = append(dst, u)
+	}
+	return dst
+}
+
+// mergeBip32Derivations adds the derivations of src for public keys without a
+// derivation in dst to dst.
+func mergeBip32Derivations(dst, src []*Bip32Derivation) []*Bip32Derivation {
+next:
+	for _, d := range src {
+		for _, existing := range dst {
+			if bytes.Equal(existing.PubKey, d.PubKey) {
+				continue next
+			}
+		}
+		dst = append(dst, d)
+	}
+	return dst
+}
+
+// merge adds the information of src the input does not have yet.
+func (in *Input) merge(src *Input) {
+	if in.NonWitnessUtxo == nil {
+		in.NonWitnessUtxo = src.NonWitnessUtxo
+	}
+	if in.WitnessUtxo == nil {
+		in.WitnessUtxo = src.WitnessUtxo
+	}
+next:
+	for _, sig := range src.PartialSigs {
+		for _, existing := range in.PartialSigs {
+			if bytes.Equal(existing.PubKey, sig.PubKey) {
+				continue next
+			}
+		}
+		in.PartialSigs = append(in.PartialSigs, sig)
+	}
+	if in.SighashType == 0 {
+		in.SighashType = src.SighashType
+	}
+	if in.RedeemScript == nil {
+		in.RedeemScript = src.RedeemScript
+	}
+	if in.WitnessScript == nil {
+		in.WitnessScript = src.WitnessScript
+	}
+	in.Bip32Derivation = mergeBip32Derivations(in.Bip32Derivation,
+		src.Bip32Derivation)
+	if in.FinalScriptSig == nil {
+		in.FinalScriptSig = src.FinalScriptSig
+	}
+	if in.FinalScriptWitness == nil {
+		in.FinalScriptWitness = src.FinalScriptWitness
+	}
+	in.Unknowns = mergeUnknowns(in.Unknowns, src.Unknowns)
+}
+
+// merge adds the information of src the output does not have yet.
+func (out *Output) merge(src *Output) {
+	if out.RedeemScript == nil {
+		out.RedeemScript = src.RedeemScript
+	}
+	if out.WitnessScript == nil {
+		out.WitnessScript = src.WitnessScript
+	}
+	out.Bip32Derivation = mergeBip32Derivations(out.Bip32Derivation,
+		src.Bip32Derivation)
+	out.Unknowns = mergeUnknowns(out.Unknowns, src.Unknowns)
+}
+
+// Combine merges PSBTs for the same transaction into a new PSBT as done by the
+// combiner role of BIP0174.  When the PSBTs hold different values for a key,
+// the value of the first PSBT that has the key is used.  ErrTxMismatch is
+// returned when the PSBTs are not all for the same transaction.
+func Combine(packets []*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, ErrTxMismatch
+	}
+	txHash := packets[0].UnsignedTx.TxHash()
+	combined := &Packet{
+		UnsignedTx: packets[0].UnsignedTx.Copy(),
+		Inputs:     make([]Input, len(packets[0].Inputs)),
+		Outputs:    make([]Output, len(packets[0].Outputs)),
+	}
+	for _, p := range packets {
+		if p.UnsignedTx.TxHash() != txHash {
+			return nil, ErrTxMismatch
+		}
+		for i := range combined.Inputs {
+			combined.Inputs[i].merge(&p.Inputs[i])
+		}
+		for i := range combined.Outputs {
+			combined.Outputs[i].merge(&p.Outputs[i])
+		}
+		combined.Unknowns = mergeUnknowns(combined.Unknowns, p.Unknowns)
+	}
+	return combined, nil
+}
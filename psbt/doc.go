@@ -0,0 +1,33 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package psbt implements partially signed bitcoin transactions as defined by
+BIP0174.
+
+A PSBT carries an unsigned transaction along with the information the
+participants of a transaction need to sign it, such as the outputs spent by the
+inputs, the scripts that are hashed in them, the derivation paths of the keys
+involved, and the signatures gathered so far.  BIP0174 divides the work on a
+PSBT into roles:
+
+  - The creator creates a PSBT from an unsigned transaction, see New
+  - Updaters add the information needed to sign the inputs
+  - Signers add their signatures
+  - The combiner merges PSBTs for the same transaction, see Combine
+  - The finalizer builds the final scripts of inputs from the gathered
+    signatures, see Packet.Finalize
+  - The extractor produces the signed transaction, see Packet.Extract
+
+PSBTs are serialized with Packet.Serialize and parsed with Decode, while
+EncodeBase64 and DecodeBase64 handle the base64 encoding PSBTs are usually
+exchanged in.
+
+Finalization is supported for inputs spending pay-to-pubkey, pay-to-pubkey-hash
+and multisig scripts, either directly or nested in pay-to-script-hash and
+version 0 pay-to-witness-script-hash scripts, as well as pay-to-witness-pubkey-
+hash scripts, either directly or nested in pay-to-script-hash scripts.  The
+final scripts are verified with the txscript package before they are accepted.
+*/
+package psbt
@@ -0,0 +1,268 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// missingInfo records the information that prevented an input from being
+// solved.
+type missingInfo struct {
+	pubKeys       [][]byte
+	sigs          [][]byte
+	redeemScript  []byte
+	witnessScript []byte
+}
+
+// findSig returns the partial signature of the input for the passed public key
+// or nil when there is none.
+func (in *Input) findSig(pubKey []byte) []byte {
+	for _, sig := range in.PartialSigs {
+		if bytes.Equal(sig.PubKey, pubKey) {
+			return sig.Signature
+		}
+	}
+	return nil
+}
+
+// findPubKey returns the public key with the passed hash among the public keys
+// the input has signatures or derivations for, or nil when there is none.
+func (in *Input) findPubKey(hash []byte) []byte {
+	for _, sig := range in.PartialSigs {
+		if bytes.Equal(btcutil.Hash160(sig.PubKey), hash) {
+			return sig.PubKey
+		}
+	}
+	for _, d := range in.Bip32Derivation {
+		if bytes.Equal(btcutil.Hash160(d.PubKey), hash) {
+			return d.PubKey
+		}
+	}
+	return nil
+}
+
+// solveScript returns the stack that satisfies the passed pay-to-pubkey,
+// pay-to-pubkey-hash or multisig script with the signatures of the input.
+func (in *Input) solveScript(script []byte, missing *missingInfo) ([][]byte, bool) {
+	switch txscript.GetScriptClass(script) {
+	case txscript.PubKeyTy:
+		pushes, _ := txscript.PushedData(script)
+		sig := in.findSig(pushes[0])
+		if sig == nil {
+			missing.sigs = append(missing.sigs,
+				btcutil.Hash160(pushes[0]))
+			return nil, false
+		}
+		return [][]byte{sig}, true
+
+	case txscript.PubKeyHashTy:
+		hash := script[3:23]
+		pubKey := in.findPubKey(hash)
+		if pubKey == nil {
+			missing.pubKeys = append(missing.pubKeys, hash)
+			return nil, false
+		}
+		sig := in.findSig(pubKey)
+		if sig == nil {
+			missing.sigs = append(missing.sigs, hash)
+			return nil, false
+		}
+		return [][]byte{sig, pubKey}, true
+
+	case txscript.MultiSigTy:
+		_, required, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return nil, false
+		}
+		pubKeys, _ := txscript.PushedData(script)
+
+		// The signatures must be in the order of their public keys
+		// and are preceded by a dummy element due to an off-by-one
+		// error in OP_CHECKMULTISIG.
+		stack := [][]byte{nil}
+		var missingSigs [][]byte
+		for _, pubKey := range pubKeys {
+			if len(stack)-1 == required {
+				break
+			}
+			if sig := in.findSig(pubKey); sig != nil {
+				stack = append(stack, sig)
+			} else {
+				missingSigs = append(missingSigs,
+					btcutil.Hash160(pubKey))
+			}
+		}
+		if len(stack)-1 < required {
+			missing.sigs = append(missing.sigs, missingSigs...)
+			return nil, false
+		}
+		return stack, true
+	}
+	return nil, false
+}
+
+// solve returns the final signature script and witness of the input when it
+// has the information and signatures to spend the passed public key script.
+// Otherwise, the information that is missing is added to missing.
+func (in *Input) solve(pkScript []byte, missing *missingInfo) ([]byte, wire.TxWitness, bool) {
+	script := pkScript
+	p2sh := txscript.IsPayToScriptHash(pkScript)
+	if p2sh {
+		hash := pkScript[2:22]
+		if in.RedeemScript == nil ||
+			!bytes.Equal(btcutil.Hash160(in.RedeemScript), hash) {
+
+			missing.redeemScript = hash
+			return nil, nil, false
+		}
+		script = in.RedeemScript
+	}
+
+	var stack [][]byte
+	var witness wire.TxWitness
+	switch {
+	case txscript.IsPayToWitnessPubKeyHash(script):
+		pkhScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_DUP).AddOp(txscript.OP_HASH160).
+			AddData(script[2:22]).AddOp(txscript.OP_EQUALVERIFY).
+			AddOp(txscript.OP_CHECKSIG).Script()
+		if err != nil {
+			return nil, nil, false
+		}
+		items, ok := in.solveScript(pkhScript, missing)
+		if !ok {
+			return nil, nil, false
+		}
+		witness = items
+
+	case txscript.IsPayToWitnessScriptHash(script):
+		hash := script[2:34]
+		witnessScriptHash := sha256.Sum256(in.WitnessScript)
+		if in.WitnessScript == nil ||
+			!bytes.Equal(witnessScriptHash[:], hash) {
+
+			missing.witnessScript = hash
+			return nil, nil, false
+		}
+		items, ok := in.solveScript(in.WitnessScript, missing)
+		if !ok {
+			return nil, nil, false
+		}
+		witness = append(items, in.WitnessScript)
+
+	case txscript.IsWitnessProgram(script):
+		return nil, nil, false
+
+	default:
+		items, ok := in.solveScript(script, missing)
+		if !ok {
+			return nil, nil, false
+		}
+		stack = items
+	}
+
+	if p2sh {
+		stack = append(stack, in.RedeemScript)
+	}
+	if len(stack) == 0 {
+		return nil, witness, true
+	}
+	builder := txscript.NewScriptBuilder()
+	for _, item := range stack {
+		builder.AddData(item)
+	}
+	sigScript, err := builder.Script()
+	if err != nil {
+		return nil, nil, false
+	}
+	return sigScript, witness, true
+}
+
+// Finalize builds the final signature script and witness of the passed input
+// from its partial signatures and verifies them, as done by the finalizer role
+// of BIP0174.  The information that is only needed to finalize the input is
+// removed from it.  An error wrapping ErrNotFinalizable is returned when the
+// input cannot be finalized.  Inputs that are already finalized are left as
+// is.
+func (p *Packet) Finalize(i int) error {
+	in := &p.Inputs[i]
+	if in.IsFinalized() {
+		return nil
+	}
+	prevOut, ok := p.PrevOutput(i)
+	if !ok {
+		return fmt.Errorf("%w: the output spent by input %d is unknown",
+			ErrNotFinalizable, i)
+	}
+	sigScript, witness, ok := in.solve(prevOut.PkScript, new(missingInfo))
+	if !ok {
+		return fmt.Errorf("%w: input %d is missing information or "+
+			"signatures", ErrNotFinalizable, i)
+	}
+
+	tx := p.UnsignedTx.Copy()
+	tx.TxIn[i].SignatureScript = sigScript
+	tx.TxIn[i].Witness = witness
+	vm, err := txscript.NewEngine(prevOut.PkScript, tx, i,
+		txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(tx),
+		prevOut.Value)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		return fmt.Errorf("%w: input %d does not verify: %v",
+			ErrNotFinalizable, i, err)
+	}
+
+	*in = Input{
+		NonWitnessUtxo:     in.NonWitnessUtxo,
+		WitnessUtxo:        in.WitnessUtxo,
+		FinalScriptSig:     sigScript,
+		FinalScriptWitness: witness,
+		Unknowns:           in.Unknowns,
+	}
+	return nil
+}
+
+// FinalizeAll finalizes the inputs of the PSBT that can be finalized and
+// returns whether all of its inputs are finalized.
+func (p *Packet) FinalizeAll() bool {
+	for i := range p.Inputs {
+		p.Finalize(i)
+	}
+	return p.IsComplete()
+}
+
+// IsComplete returns whether all inputs of the PSBT are finalized.
+func (p *Packet) IsComplete() bool {
+	for i := range p.Inputs {
+		if !p.Inputs[i].IsFinalized() {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract returns the signed transaction of a PSBT with all inputs finalized,
+// as done by the extractor role of BIP0174.  ErrIncomplete is returned when an
+// input is not finalized.
+func (p *Packet) Extract() (*wire.MsgTx, error) {
+	if !p.IsComplete() {
+		return nil, ErrIncomplete
+	}
+	tx := p.UnsignedTx.Copy()
+	for i, txIn := range tx.TxIn {
+		txIn.SignatureScript = p.Inputs[i].FinalScriptSig
+		txIn.Witness = p.Inputs[i].FinalScriptWitness
+	}
+	return tx, nil
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// testKeys returns deterministic private keys for the tests.
+func testKeys(n int) []*btcec.PrivateKey {
+	keys := make([]*btcec.PrivateKey, n)
+	for i := range keys {
+		keys[i], _ = btcec.PrivKeyFromBytes(btcec.S256(),
+			[]byte{byte(i + 1), 0x01, 0x02, 0x03})
+	}
+	return keys
+}
+
+// mustScript returns the script of the passed builder and panics if there is
+// an error.
+func mustScript(builder *txscript.ScriptBuilder) []byte {
+	script, err := builder.Script()
+	if err != nil {
+		panic(err)
+	}
+	return script
+}
+
+// pkhScript returns a pay-to-pubkey-hash script for the passed public key.
+func pkhScript(pubKey []byte) []byte {
+	return mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(btcutil.Hash160(pubKey)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG))
+}
+
+// p2shScript returns a pay-to-script-hash script for the passed script.
+func p2shScript(script []byte) []byte {
+	return mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(script)).AddOp(txscript.OP_EQUAL))
+}
+
+// p2wshScript returns a pay-to-witness-script-hash script for the passed
+// script.
+func p2wshScript(script []byte) []byte {
+	hash := sha256.Sum256(script)
+	return mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(hash[:]))
+}
+
+// multisigScript returns a script that requires the passed number of
+// signatures of the passed keys.
+func multisigScript(required int, keys []*btcec.PrivateKey) []byte {
+	builder := txscript.NewScriptBuilder().AddInt64(int64(required))
+	for _, key := range keys {
+		builder.AddData(key.PubKey().SerializeCompressed())
+	}
+	builder.AddInt64(int64(len(keys))).AddOp(txscript.OP_CHECKMULTISIG)
+	return mustScript(builder)
+}
+
+// TestFinalize ensures inputs of the supported script types are finalized
+// once they have enough signatures, that the analysis of their inputs reports
+// the information they are missing, and that the extracted transaction
+// verifies.
+func TestFinalize(t *testing.T) {
+	t.Parallel()
+
+	keys := testKeys(3)
+	pubKey := keys[0].PubKey().SerializeCompressed()
+	multisig := multisigScript(2, keys)
+	wpkh := mustScript(txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(pubKey)))
+
+	tests := []struct {
+		name          string
+		pkScript      []byte
+		redeemScript  []byte
+		witnessScript []byte
+		signers       []*btcec.PrivateKey
+		required      int
+		pubKeyHash    bool
+		witness       bool
+	}{
+		{
+			name: "p2pk",
+			pkScript: mustScript(txscript.NewScriptBuilder().
+				AddData(pubKey).AddOp(txscript.OP_CHECKSIG)),
+			signers:  keys[:1],
+			required: 1,
+		},
+		{
+			name:       "p2pkh",
+			pkScript:   pkhScript(pubKey),
+			signers:    keys[:1],
+			required:   1,
+			pubKeyHash: true,
+		},
+		{
+			name:         "p2sh multisig",
+			pkScript:     p2shScript(multisig),
+			redeemScript: multisig,
+			signers:      keys[1:],
+			required:     2,
+		},
+		{
+			name:       "p2wpkh",
+			pkScript:   wpkh,
+			signers:    keys[:1],
+			required:   1,
+			pubKeyHash: true,
+			witness:    true,
+		},
+		{
+			name:         "p2sh-p2wpkh",
+			pkScript:     p2shScript(wpkh),
+			redeemScript: wpkh,
+			signers:      keys[:1],
+			required:     1,
+			pubKeyHash:   true,
+			witness:      true,
+		},
+		{
+			name:          "p2wsh multisig",
+			pkScript:      p2wshScript(multisig),
+			witnessScript: multisig,
+			signers:       []*btcec.PrivateKey{keys[0], keys[2]},
+			required:      2,
+			witness:       true,
+		},
+		{
+			name:          "p2sh-p2wsh multisig",
+			pkScript:      p2shScript(p2wshScript(multisig)),
+			redeemScript:  p2wshScript(multisig),
+			witnessScript: multisig,
+			signers:       keys,
+			required:      2,
+			witness:       true,
+		},
+	}
+
+	for _, test := range tests {
+		prevTx := testUnsignedTx(wire.OutPoint{Index: 1})
+		prevTx.TxOut[0].PkScript = test.pkScript
+		prevOut := prevTx.TxOut[0]
+		tx := testUnsignedTx(wire.OutPoint{Hash: prevTx.TxHash()})
+		tx.TxOut[0].Value = prevOut.Value - 1000
+		p, err := New(tx)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", test.name, err)
+		}
+
+		// Without the output spent by the input, an updater has to
+		// act on it next.
+		in := &p.Inputs[0]
+		analysis := p.AnalyzeInput(0)
+		if analysis.HasUtxo || analysis.Next != RoleUpdater {
+			t.Errorf("%s: unexpected analysis without utxo %+v",
+				test.name, analysis)
+			continue
+		}
+		if _, err := p.Fee(); err != ErrMissingUtxo {
+			t.Errorf("%s: unexpected fee error %v", test.name, err)
+			continue
+		}
+		if test.witness {
+			in.WitnessUtxo = prevOut
+		} else {
+			in.NonWitnessUtxo = prevTx
+		}
+		fee, err := p.Fee()
+		if err != nil || fee != 1000 {
+			t.Errorf("%s: unexpected fee %v (%v)", test.name, fee, err)
+			continue
+		}
+
+		// Without the scripts that are hashed in the output, they are
+		// reported missing.
+		analysis = p.AnalyzeInput(0)
+		if test.redeemScript != nil {
+			want := btcutil.Hash160(test.redeemScript)
+			if string(analysis.MissingRedeemScript) != string(want) ||
+				analysis.Next != RoleUpdater {
+
+				t.Errorf("%s: unexpected analysis without redeem "+
+					"script %+v", test.name, analysis)
+				continue
+			}
+			in.RedeemScript = test.redeemScript
+			analysis = p.AnalyzeInput(0)
+		}
+		if test.witnessScript != nil {
+			want := sha256.Sum256(test.witnessScript)
+			if string(analysis.MissingWitnessScript) != string(want[:]) ||
+				analysis.Next != RoleUpdater {
+
+				t.Errorf("%s: unexpected analysis without witness "+
+					"script %+v", test.name, analysis)
+				continue
+			}
+			in.WitnessScript = test.witnessScript
+			analysis = p.AnalyzeInput(0)
+		}
+
+		// The public keys of pubkey hash scripts are reported missing
+		// until there is a signature or derivation for them.
+		if test.pubKeyHash {
+			want := btcutil.Hash160(pubKey)
+			if len(analysis.MissingPubKeys) != 1 ||
+				string(analysis.MissingPubKeys[0]) != string(want) ||
+				analysis.Next != RoleUpdater {
+
+				t.Errorf("%s: unexpected analysis without public "+
+					"key %+v", test.name, analysis)
+				continue
+			}
+			in.Bip32Derivation = []*Bip32Derivation{{
+				PubKey:      pubKey,
+				Fingerprint: 0x01020304,
+				Path:        []uint32{0},
+			}}
+			analysis = p.AnalyzeInput(0)
+		}
+
+		// Add the signatures one at a time and ensure the input can
+		// only be finalized once it has enough of them.
+		script := test.pkScript
+		switch {
+		case test.witnessScript != nil:
+			script = test.witnessScript
+		case test.redeemScript != nil:
+			script = test.redeemScript
+		}
+		sigHashes := txscript.NewTxSigHashes(tx)
+		for i, key := range test.signers {
+			if i == test.required {
+				break
+			}
+			if analysis.Next != RoleSigner ||
+				len(analysis.MissingSigs) == 0 {
+
+				t.Errorf("%s: unexpected analysis with %d "+
+					"signatures %+v", test.name, i, analysis)
+				break
+			}
+			if err := p.Finalize(0); !errors.Is(err, ErrNotFinalizable) {
+				t.Errorf("%s: unexpected error finalizing with %d "+
+					"signatures: %v", test.name, i, err)
+				break
+			}
+
+			var sig []byte
+			if test.witness {
+				sig, err = txscript.RawTxInWitnessSignature(tx,
+					sigHashes, 0, prevOut.Value, script,
+					txscript.SigHashAll, key)
+			} else {
+				sig, err = txscript.RawTxInSignature(tx, 0, script,
+					txscript.SigHashAll, key)
+			}
+			if err != nil {
+				t.Fatalf("%s: unexpected error signing: %v",
+					test.name, err)
+			}
+			in.PartialSigs = append(in.PartialSigs, &PartialSig{
+				PubKey:    key.PubKey().SerializeCompressed(),
+				Signature: sig,
+			})
+			analysis = p.AnalyzeInput(0)
+		}
+		if analysis.Next != RoleFinalizer {
+			t.Errorf("%s: unexpected analysis with all signatures %+v",
+				test.name, analysis)
+			continue
+		}
+
+		if _, err := p.Extract(); err != ErrIncomplete {
+			t.Errorf("%s: unexpected error extracting before "+
+				"finalizing: %v", test.name, err)
+			continue
+		}
+		if !p.FinalizeAll() {
+			t.Errorf("%s: unable to finalize: %v", test.name,
+				p.Finalize(0))
+			continue
+		}
+		if in.PartialSigs != nil || in.RedeemScript != nil ||
+			in.WitnessScript != nil {
+
+			t.Errorf("%s: finalized input still has signing "+
+				"information", test.name)
+			continue
+		}
+		analysis = p.AnalyzeInput(0)
+		if !analysis.IsFinal || analysis.Next != RoleExtractor {
+			t.Errorf("%s: unexpected analysis of finalized input %+v",
+				test.name, analysis)
+			continue
+		}
+
+		signedTx, err := p.Extract()
+		if err != nil {
+			t.Errorf("%s: unexpected error extracting: %v", test.name,
+				err)
+			continue
+		}
+		if test.witness != (signedTx.TxIn[0].Witness != nil) {
+			t.Errorf("%s: unexpected witness %x", test.name,
+				signedTx.TxIn[0].Witness)
+			continue
+		}
+		vm, err := txscript.NewEngine(prevOut.PkScript, signedTx, 0,
+			txscript.StandardVerifyFlags, nil,
+			txscript.NewTxSigHashes(signedTx), prevOut.Value)
+		if err == nil {
+			err = vm.Execute()
+		}
+		if err != nil {
+			t.Errorf("%s: extracted transaction does not verify: %v",
+				test.name, err)
+		}
+	}
+}
+
+// TestFinalizeInvalidSig ensures inputs with signatures that do not verify are
+// not finalized.
+func TestFinalizeInvalidSig(t *testing.T) {
+	t.Parallel()
+
+	key := testKeys(1)[0]
+	pubKey := key.PubKey().SerializeCompressed()
+	tx := testUnsignedTx(wire.OutPoint{Hash: chainhash.Hash{1}})
+	p, _ := New(tx)
+	in := &p.Inputs[0]
+	in.WitnessUtxo = wire.NewTxOut(100000, mustScript(txscript.
+		NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(btcutil.Hash160(pubKey))))
+
+	// Sign with the wrong amount.
+	sig, err := txscript.RawTxInWitnessSignature(tx,
+		txscript.NewTxSigHashes(tx), 0, 1, in.WitnessUtxo.PkScript,
+		txscript.SigHashAll, key)
+	if err != nil {
+		t.Fatalf("unexpected error signing: %v", err)
+	}
+	in.PartialSigs = []*PartialSig{{PubKey: pubKey, Signature: sig}}
+	if p.AnalyzeInput(0).Next != RoleFinalizer {
+		t.Fatalf("unexpected analysis %+v", p.AnalyzeInput(0))
+	}
+	if err := p.Finalize(0); !errors.Is(err, ErrNotFinalizable) {
+		t.Fatalf("Finalize: unexpected error %v", err)
+	}
+	if in.IsFinalized() || in.PartialSigs == nil {
+		t.Fatalf("Finalize: input changed by failed finalization")
+	}
+}
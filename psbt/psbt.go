@@ -0,0 +1,702 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// magic is the sequence of bytes every serialized PSBT starts with.
+var magic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// The key types of the global map of a PSBT.
+const (
+	globalUnsignedTxType = 0x00
+	globalVersionType    = 0xfb
+)
+
+// The key types of the input maps of a PSBT.
+const (
+	inputNonWitnessUtxoType     = 0x00
+	inputWitnessUtxoType        = 0x01
+	inputPartialSigType         = 0x02
+	inputSighashType            = 0x03
+	inputRedeemScriptType       = 0x04
+	inputWitnessScriptType      = 0x05
+	inputBip32DerivationType    = 0x06
+	inputFinalScriptSigType     = 0x07
+	inputFinalScriptWitnessType = 0x08
+)
+
+// The key types of the output maps of a PSBT.
+const (
+	outputRedeemScriptType    = 0x00
+	outputWitnessScriptType   = 0x01
+	outputBip32DerivationType = 0x02
+)
+
+var (
+	// ErrInvalidFormat indicates a PSBT could not be parsed because it is
+	// not serialized as defined by BIP0174.
+	ErrInvalidFormat = errors.New("invalid PSBT format")
+
+	// ErrInvalidPacket indicates a PSBT is well-formed but its contents
+	// are inconsistent, such as a previous output that does not match the
+	// outpoint spent by the input.
+	ErrInvalidPacket = errors.New("invalid PSBT")
+
+	// ErrTxMismatch indicates PSBTs for different transactions were
+	// combined.
+	ErrTxMismatch = errors.New("PSBTs are for different transactions")
+
+	// ErrNotFinalizable indicates an input could not be finalized since
+	// information or signatures are missing or the resulting scripts do
+	// not verify.
+	ErrNotFinalizable = errors.New("input cannot be finalized")
+
+	// ErrIncomplete indicates the transaction of a PSBT could not be
+	// extracted since not all of its inputs are finalized.
+	ErrIncomplete = errors.New("PSBT is not finalized")
+)
+
+// Unknown is a key-value pair of a PSBT map with a key type this package does
+// not interpret.  It is retained as is when the PSBT is serialized.
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// PartialSig is a signature of an input along with the public key it was
+// created with.
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// Bip32Derivation describes where a public key comes from by the fingerprint
+// of the master key it was derived from and the BIP0032 derivation path from
+// the master key to the key.
+type Bip32Derivation struct {
+	PubKey      []byte
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// Input houses the information a PSBT holds about an input of its transaction.
+// Fields that are not set are nil, except for SighashType which is zero.
+type Input struct {
+	NonWitnessUtxo     *wire.MsgTx
+	WitnessUtxo        *wire.TxOut
+	PartialSigs        []*PartialSig
+	SighashType        txscript.SigHashType
+	RedeemScript       []byte
+	WitnessScript      []byte
+	Bip32Derivation    []*Bip32Derivation
+	FinalScriptSig     []byte
+	FinalScriptWitness wire.TxWitness
+	Unknowns           []*Unknown
+}
+
+// IsFinalized returns whether the final scripts of the input are set.
+func (in *Input) IsFinalized() bool {
+	return in.FinalScriptSig != nil || in.FinalScriptWitness != nil
+}
+
+// Output houses the information a PSBT holds about an output of its
+// transaction.
+type Output struct {
+	RedeemScript    []byte
+	WitnessScript   []byte
+	Bip32Derivation []*Bip32Derivation
+	Unknowns        []*Unknown
+}
+
+// Packet is a partially signed bitcoin transaction.  It has an input and an
+// output for each input and output of the unsigned transaction.
+type Packet struct {
+	UnsignedTx *wire.MsgTx
+	Inputs     []Input
+	Outputs    []Output
+	Unknowns   []*Unknown
+}
+
+// checkUnsignedTx returns an error when the passed transaction has signature
+// scripts or witnesses.
+func checkUnsignedTx(tx *wire.MsgTx) error {
+	for _, txIn := range tx.TxIn {
+		if len(txIn.SignatureScript) != 0 || len(txIn.Witness) != 0 {
+			return fmt.Errorf("%w: unsigned transaction has "+
+				"signature scripts or witnesses",
+				ErrInvalidFormat)
+		}
+	}
+	return nil
+}
+
+// New returns a PSBT for the passed unsigned transaction without any
+// information about its inputs and outputs.
+func New(tx *wire.MsgTx) (*Packet, error) {
+	if err := checkUnsignedTx(tx); err != nil {
+		return nil, err
+	}
+	return &Packet{
+		UnsignedTx: tx,
+		Inputs:     make([]Input, len(tx.TxIn)),
+		Outputs:    make([]Output, len(tx.TxOut)),
+	}, nil
+}
+
+// PrevOutput returns the output spent by the passed input, which is known when
+// the PSBT holds the witness or non-witness utxo of the input.
+func (p *Packet) PrevOutput(i int) (*wire.TxOut, bool) {
+	in := &p.Inputs[i]
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, true
+	}
+	if in.NonWitnessUtxo != nil {
+		index := p.UnsignedTx.TxIn[i].PreviousOutPoint.Index
+		return in.NonWitnessUtxo.TxOut[index], true
+	}
+	return nil, false
+}
+
+// SanityCheck ensures the contents of the PSBT are consistent with its
+// transaction.  Packets returned by Decode are already checked.
+func (p *Packet) SanityCheck() error {
+	if len(p.Inputs) != len(p.UnsignedTx.TxIn) ||
+		len(p.Outputs) != len(p.UnsignedTx.TxOut) {
+
+		return fmt.Errorf("%w: number of inputs or outputs does not "+
+			"match the transaction", ErrInvalidPacket)
+	}
+	for i := range p.Inputs {
+		utxo := p.Inputs[i].NonWitnessUtxo
+		if utxo == nil {
+			continue
+		}
+		prevOut := p.UnsignedTx.TxIn[i].PreviousOutPoint
+		if utxo.TxHash() != prevOut.Hash {
+			return fmt.Errorf("%w: non-witness utxo of input %d "+
+				"does not match the outpoint hash",
+				ErrInvalidPacket, i)
+		}
+		if prevOut.Index >= uint32(len(utxo.TxOut)) {
+			return fmt.Errorf("%w: non-witness utxo of input %d "+
+				"does not have output %d", ErrInvalidPacket, i,
+				prevOut.Index)
+		}
+	}
+	return nil
+}
+
+// kvReader reads the key-value pairs of PSBT maps.
+type kvReader struct {
+	r *bytes.Reader
+}
+
+// readBytes reads a compact size length followed by that many bytes.
+func (kv *kvReader) readBytes() ([]byte, error) {
+	n, err := wire.ReadVarInt(kv.r, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFormat, err)
+	}
+	if n > uint64(kv.r.Len()) {
+		return nil, fmt.Errorf("%w: unexpected end of data",
+			ErrInvalidFormat)
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(kv.r, b)
+	return b, err
+}
+
+// next reads the next key-value pair of a map.  The returned key is nil at the
+// separator that ends the map.
+func (kv *kvReader) next() ([]byte, []byte, error) {
+	key, err := kv.readBytes()
+	if err != nil || len(key) == 0 {
+		return nil, nil, err
+	}
+	value, err := kv.readBytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// readMap reads the key-value pairs of a map up to its separator and passes
+// them to the provided function, ensuring no key occurs twice.
+func (kv *kvReader) readMap(fn func(key, value []byte) error) error {
+	seen := make(map[string]struct{})
+	for {
+		key, value, err := kv.next()
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return nil
+		}
+		if _, ok := seen[string(key)]; ok {
+			return fmt.Errorf("%w: duplicate key %x", ErrInvalidFormat,
+				key)
+		}
+		seen[string(key)] = struct{}{}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+// errKeyData returns the error for a key of the passed type with unexpected
+// data.
+func errKeyData(keyType byte) error {
+	return fmt.Errorf("%w: unexpected data in key of type %#02x",
+		ErrInvalidFormat, keyType)
+}
+
+// parsePubKeyKey returns the public key of a key that consists of its type
+// followed by a serialized public key.
+func parsePubKeyKey(key []byte) ([]byte, error) {
+	pubKey := key[1:]
+	_, err := btcec.ParsePubKey(pubKey, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid public key %x in key of "+
+			"type %#02x", ErrInvalidFormat, pubKey, key[0])
+	}
+	return pubKey, nil
+}
+
+// parseBip32Derivation parses a BIP0032 derivation of the passed public key.
+func parseBip32Derivation(pubKey, value []byte) (*Bip32Derivation, error) {
+	if len(value) == 0 || len(value)%4 != 0 {
+		return nil, fmt.Errorf("%w: invalid BIP0032 derivation of "+
+			"length %d", ErrInvalidFormat, len(value))
+	}
+	derivation := &Bip32Derivation{
+		PubKey:      pubKey,
+		Fingerprint: binary.BigEndian.Uint32(value),
+	}
+	for i := 4; i < len(value); i += 4 {
+		index := binary.LittleEndian.Uint32(value[i:])
+		derivation.Path = append(derivation.Path, index)
+	}
+	return derivation, nil
+}
+
+// parseTx parses the passed serialized transaction ensuring there is no data
+// following it.
+func parseTx(value []byte, witness bool) (*wire.MsgTx, error) {
+	tx := new(wire.MsgTx)
+	r := bytes.NewReader(value)
+	var err error
+	if witness {
+		err = tx.Deserialize(r)
+	} else {
+		err = tx.DeserializeNoWitness(r)
+	}
+	if err != nil || r.Len() != 0 {
+		return nil, fmt.Errorf("%w: invalid transaction", ErrInvalidFormat)
+	}
+	return tx, nil
+}
+
+// parseInput parses the key-value pair of an input map into the passed input.
+func parseInput(in *Input, key, value []byte) error {
+	keyType := key[0]
+	switch keyType {
+	case inputPartialSigType:
+		pubKey, err := parsePubKeyKey(key)
+		if err != nil {
+			return err
+		}
+		in.PartialSigs = append(in.PartialSigs, &PartialSig{
+			PubKey:    pubKey,
+			Signature: value,
+		})
+		return nil
+
+	case inputBip32DerivationType:
+		pubKey, err := parsePubKeyKey(key)
+		if err != nil {
+			return err
+		}
+		derivation, err := parseBip32Derivation(pubKey, value)
+		if err != nil {
+			return err
+		}
+		in.Bip32Derivation = append(in.Bip32Derivation, derivation)
+		return nil
+
+	case inputNonWitnessUtxoType, inputWitnessUtxoType, inputSighashType,
+		inputRedeemScriptType, inputWitnessScriptType,
+		inputFinalScriptSigType, inputFinalScriptWitnessType:
+
+		if len(key) != 1 {
+			return errKeyData(keyType)
+		}
+
+	default:
+		in.Unknowns = append(in.Unknowns, &Unknown{Key: key, Value: value})
+		return nil
+	}
+
+	switch keyType {
+	case inputNonWitnessUtxoType:
+		tx, err := parseTx(value, true)
+		if err != nil {
+			return err
+		}
+		in.NonWitnessUtxo = tx
+
+	case inputWitnessUtxoType:
+		r := bytes.NewReader(value)
+		var amount int64
+		err := binary.Read(r, binary.LittleEndian, &amount)
+		if err != nil {
+			return fmt.Errorf("%w: invalid witness utxo",
+				ErrInvalidFormat)
+		}
+		pkScript, err := (&kvReader{r}).readBytes()
+		if err != nil || r.Len() != 0 {
+			return fmt.Errorf("%w: invalid witness utxo",
+				ErrInvalidFormat)
+		}
+		in.WitnessUtxo = wire.NewTxOut(amount, pkScript)
+
+	case inputSighashType:
+		if len(value) != 4 {
+			return fmt.Errorf("%w: invalid sighash type",
+				ErrInvalidFormat)
+		}
+		in.SighashType = txscript.SigHashType(binary.LittleEndian.Uint32(value))
+
+	case inputRedeemScriptType:
+		in.RedeemScript = value
+
+	case inputWitnessScriptType:
+		in.WitnessScript = value
+
+	case inputFinalScriptSigType:
+		in.FinalScriptSig = value
+
+	case inputFinalScriptWitnessType:
+		r := &kvReader{bytes.NewReader(value)}
+		count, err := wire.ReadVarInt(r.r, 0)
+		if err != nil || count > uint64(r.r.Len()) {
+			return fmt.Errorf("%w: invalid final script witness",
+				ErrInvalidFormat)
+		}
+		witness := make(wire.TxWitness, count)
+		for i := range witness {
+			witness[i], err = r.readBytes()
+			if err != nil {
+				return err
+			}
+		}
+		if r.r.Len() != 0 {
+			return fmt.Errorf("%w: invalid final script witness",
+				ErrInvalidFormat)
+		}
+		in.FinalScriptWitness = witness
+	}
+	return nil
+}
+
+// parseOutput parses the key-value pair of an output map into the passed
+// output.
+func parseOutput(out *Output, key, value []byte) error {
+	keyType := key[0]
+	switch keyType {
+	case outputRedeemScriptType, outputWitnessScriptType:
+		if len(key) != 1 {
+			return errKeyData(keyType)
+		}
+		if keyType == outputRedeemScriptType {
+			out.RedeemScript = value
+		} else {
+			out.WitnessScript = value
+		}
+
+	case outputBip32DerivationType:
+		pubKey, err := parsePubKeyKey(key)
+		if err != nil {
+			return err
+		}
+		derivation, err := parseBip32Derivation(pubKey, value)
+		if err != nil {
+			return err
+		}
+		out.Bip32Derivation = append(out.Bip32Derivation, derivation)
+
+	default:
+		out.Unknowns = append(out.Unknowns, &Unknown{Key: key, Value: value})
+	}
+	return nil
+}
+
+// Decode parses a serialized PSBT and ensures its contents are consistent.
+func Decode(serialized []byte) (*Packet, error) {
+	if !bytes.HasPrefix(serialized, magic) {
+		return nil, fmt.Errorf("%w: invalid magic bytes", ErrInvalidFormat)
+	}
+	kv := &kvReader{bytes.NewReader(serialized[len(magic):])}
+
+	p := new(Packet)
+	err := kv.readMap(func(key, value []byte) error {
+		switch key[0] {
+		case globalUnsignedTxType:
+			if len(key) != 1 {
+				return errKeyData(key[0])
+			}
+			tx, err := parseTx(value, false)
+			if err != nil {
+				return err
+			}
+			if err := checkUnsignedTx(tx); err != nil {
+				return err
+			}
+			p.UnsignedTx = tx
+
+		case globalVersionType:
+			if len(key) != 1 || len(value) != 4 {
+				return errKeyData(key[0])
+			}
+			version := binary.LittleEndian.Uint32(value)
+			if version != 0 {
+				return fmt.Errorf("%w: unsupported version %d",
+					ErrInvalidFormat, version)
+			}
+			fallthrough
+
+		default:
+			p.Unknowns = append(p.Unknowns, &Unknown{
+				Key:   key,
+				Value: value,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if p.UnsignedTx == nil {
+		return nil, fmt.Errorf("%w: missing unsigned transaction",
+			ErrInvalidFormat)
+	}
+
+	p.Inputs = make([]Input, len(p.UnsignedTx.TxIn))
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		err := kv.readMap(func(key, value []byte) error {
+			return parseInput(in, key, value)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	p.Outputs = make([]Output, len(p.UnsignedTx.TxOut))
+	for i := range p.Outputs {
+		out := &p.Outputs[i]
+		err := kv.readMap(func(key, value []byte) error {
+			return parseOutput(out, key, value)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if kv.r.Len() != 0 {
+		return nil, fmt.Errorf("%w: unexpected data after the last map",
+			ErrInvalidFormat)
+	}
+
+	if err := p.SanityCheck(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DecodeBase64 parses a base64-encoded PSBT and ensures its contents are
+// consistent.
+func DecodeBase64(encoded string) (*Packet, error) {
+	serialized, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64 encoding",
+			ErrInvalidFormat)
+	}
+	return Decode(serialized)
+}
+
+// kvWriter writes the key-value pairs of PSBT maps.
+type kvWriter struct {
+	w   io.Writer
+	err error
+}
+
+// writeBytes writes a compact size length followed by the passed bytes.
+func (kv *kvWriter) writeBytes(b []byte) {
+	if kv.err != nil {
+		return
+	}
+	if kv.err = wire.WriteVarInt(kv.w, 0, uint64(len(b))); kv.err != nil {
+		return
+	}
+	_, kv.err = kv.w.Write(b)
+}
+
+// write writes a key-value pair.
+func (kv *kvWriter) write(key, value []byte) {
+	kv.writeBytes(key)
+	kv.writeBytes(value)
+}
+
+// writeSeparator writes the separator that ends a map.
+func (kv *kvWriter) writeSeparator() {
+	if kv.err == nil {
+		_, kv.err = kv.w.Write([]byte{0x00})
+	}
+}
+
+// writeBip32Derivation writes a BIP0032 derivation with the passed key type.
+func (kv *kvWriter) writeBip32Derivation(keyType byte, d *Bip32Derivation) {
+	value := make([]byte, 4+4*len(d.Path))
+	binary.BigEndian.PutUint32(value, d.Fingerprint)
+	for i, index := range d.Path {
+		binary.LittleEndian.PutUint32(value[4+4*i:], index)
+	}
+	kv.write(append([]byte{keyType}, d.PubKey...), value)
+}
+
+// writeUnknowns writes the passed unknown key-value pairs.
+func (kv *kvWriter) writeUnknowns(unknowns []*Unknown) {
+	for _, u := range unknowns {
+		kv.write(u.Key, u.Value)
+	}
+}
+
+// serializeTx returns the passed transaction serialized with or without its
+// witnesses.
+func serializeTx(tx *wire.MsgTx, witness bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	var err error
+	if witness {
+		err = tx.Serialize(&buf)
+	} else {
+		err = tx.SerializeNoWitness(&buf)
+	}
+	return buf.Bytes(), err
+}
+
+// writeInput writes the map of the passed input.
+func (kv *kvWriter) writeInput(in *Input) error {
+	if in.NonWitnessUtxo != nil {
+		tx, err := serializeTx(in.NonWitnessUtxo, true)
+		if err != nil {
+			return err
+		}
+		kv.write([]byte{inputNonWitnessUtxoType}, tx)
+	}
+	if in.WitnessUtxo != nil {
+		var buf bytes.Buffer
+		err := binary.Write(&buf, binary.LittleEndian, in.WitnessUtxo.Value)
+		if err != nil {
+			return err
+		}
+		(&kvWriter{w: &buf}).writeBytes(in.WitnessUtxo.PkScript)
+		kv.write([]byte{inputWitnessUtxoType}, buf.Bytes())
+	}
+	for _, sig := range in.PartialSigs {
+		key := append([]byte{inputPartialSigType}, sig.PubKey...)
+		kv.write(key, sig.Signature)
+	}
+	if in.SighashType != 0 {
+		var value [4]byte
+		binary.LittleEndian.PutUint32(value[:], uint32(in.SighashType))
+		kv.write([]byte{inputSighashType}, value[:])
+	}
+	if in.RedeemScript != nil {
+		kv.write([]byte{inputRedeemScriptType}, in.RedeemScript)
+	}
+	if in.WitnessScript != nil {
+		kv.write([]byte{inputWitnessScriptType}, in.WitnessScript)
+	}
+	for _, d := range in.Bip32Derivation {
+		kv.writeBip32Derivation(inputBip32DerivationType, d)
+	}
+	if in.FinalScriptSig != nil {
+		kv.write([]byte{inputFinalScriptSigType}, in.FinalScriptSig)
+	}
+	if in.FinalScriptWitness != nil {
+		var buf bytes.Buffer
+		w := &kvWriter{w: &buf}
+		w.err = wire.WriteVarInt(&buf, 0, uint64(len(in.FinalScriptWitness)))
+		for _, item := range in.FinalScriptWitness {
+			w.writeBytes(item)
+		}
+		if w.err != nil {
+			return w.err
+		}
+		kv.write([]byte{inputFinalScriptWitnessType}, buf.Bytes())
+	}
+	kv.writeUnknowns(in.Unknowns)
+	kv.writeSeparator()
+	return kv.err
+}
+
+// writeOutput writes the map of the passed output.
+func (kv *kvWriter) writeOutput(out *Output) {
+	if out.RedeemScript != nil {
+		kv.write([]byte{outputRedeemScriptType}, out.RedeemScript)
+	}
+	if out.WitnessScript != nil {
+		kv.write([]byte{outputWitnessScriptType}, out.WitnessScript)
+	}
+	for _, d := range out.Bip32Derivation {
+		kv.writeBip32Derivation(outputBip32DerivationType, d)
+	}
+	kv.writeUnknowns(out.Unknowns)
+	kv.writeSeparator()
+}
+
+// Serialize writes the PSBT to the passed writer as defined by BIP0174.
+func (p *Packet) Serialize(w io.Writer) error {
+	if _, err := w.Write(magic); err != nil {
+		return err
+	}
+	tx, err := serializeTx(p.UnsignedTx, false)
+	if err != nil {
+		return err
+	}
+	kv := &kvWriter{w: w}
+	kv.write([]byte{globalUnsignedTxType}, tx)
+	kv.writeUnknowns(p.Unknowns)
+	kv.writeSeparator()
+	for i := range p.Inputs {
+		if err := kv.writeInput(&p.Inputs[i]); err != nil {
+			return err
+		}
+	}
+	for i := range p.Outputs {
+		kv.writeOutput(&p.Outputs[i])
+	}
+	return kv.err
+}
+
+// EncodeBase64 returns the serialized PSBT encoded in base64.
+func (p *Packet) EncodeBase64() (string, error) {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
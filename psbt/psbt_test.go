@@ -0,0 +1,315 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package psbt
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// hexToBytes converts the passed hex string into bytes and will panic if there
+// is an error.  This is only provided for the hard-coded constants so errors in
+// the source code can be detected.  It will only (and must only) be called with
+// hard-coded values.
+func hexToBytes(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	return b
+}
+
+// testPubKey is a valid compressed public key used in the tests.
+var testPubKey = hexToBytes("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce" +
+	"28d959f2815b16f81798")
+
+// testUnsignedTx returns an unsigned transaction that spends the passed
+// outpoints to a single output.  The signature scripts are empty rather than
+// nil like those of parsed transactions.
+func testUnsignedTx(prevOuts ...wire.OutPoint) *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	for i := range prevOuts {
+		tx.AddTxIn(wire.NewTxIn(&prevOuts[i], []byte{}, nil))
+	}
+	tx.AddTxOut(wire.NewTxOut(90000, hexToBytes("0014"+
+		"751e76e8199196d454941c45d1b3a323f1433bd6")))
+	return tx
+}
+
+// serializeMaps returns a serialized PSBT with the passed maps, each given as
+// alternating keys and values.
+func serializeMaps(maps ...[][]byte) []byte {
+	var buf bytes.Buffer
+	buf.Write(magic)
+	kv := &kvWriter{w: &buf}
+	for _, m := range maps {
+		for i := 0; i < len(m); i += 2 {
+			kv.write(m[i], m[i+1])
+		}
+		kv.writeSeparator()
+	}
+	return buf.Bytes()
+}
+
+// TestSerializeRoundTrip ensures a PSBT with all fields set is serialized and
+// parsed back to the same PSBT, both in binary and base64.
+func TestSerializeRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	prevTx := testUnsignedTx(wire.OutPoint{Index: 3})
+	prevTx.TxIn[0].Witness = wire.TxWitness{{0x01}}
+	tx := testUnsignedTx(wire.OutPoint{Hash: prevTx.TxHash()},
+		wire.OutPoint{Index: 1})
+	p, err := New(tx)
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	p.Unknowns = []*Unknown{{Key: []byte{0xfb}, Value: []byte{0, 0, 0, 0}}}
+	p.Inputs[0] = Input{
+		NonWitnessUtxo: prevTx,
+		PartialSigs: []*PartialSig{{
+			PubKey:    testPubKey,
+			Signature: []byte{0x30, 0x01},
+		}},
+		SighashType:  1,
+		RedeemScript: []byte{0x51},
+		Bip32Derivation: []*Bip32Derivation{{
+			PubKey:      testPubKey,
+			Fingerprint: 0xd90c6a4f,
+			Path:        []uint32{0x80000000, 1},
+		}},
+		Unknowns: []*Unknown{{Key: []byte{0x0f, 0x01}, Value: []byte{}}},
+	}
+	p.Inputs[1] = Input{
+		WitnessUtxo:        wire.NewTxOut(100000, []byte{0x00, 0x01, 0x02}),
+		WitnessScript:      []byte{0x52},
+		FinalScriptSig:     []byte{0x00},
+		FinalScriptWitness: wire.TxWitness{{}, {0x01, 0x02}},
+	}
+	p.Outputs[0] = Output{
+		RedeemScript:  []byte{0x53},
+		WitnessScript: []byte{0x54},
+		Bip32Derivation: []*Bip32Derivation{{
+			PubKey:      testPubKey,
+			Fingerprint: 1,
+		}},
+	}
+	if err := p.SanityCheck(); err != nil {
+		t.Fatalf("SanityCheck: unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: unexpected error: %v", err)
+	}
+	decoded, err := Decode(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Decode: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, p) {
+		t.Fatalf("Decode: unexpected PSBT - got %+v, want %+v",
+			decoded, p)
+	}
+
+	encoded, err := p.EncodeBase64()
+	if err != nil {
+		t.Fatalf("EncodeBase64: unexpected error: %v", err)
+	}
+	decoded, err = DecodeBase64(encoded)
+	if err != nil {
+		t.Fatalf("DecodeBase64: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, p) {
+		t.Fatalf("DecodeBase64: unexpected PSBT - got %+v, want %+v",
+			decoded, p)
+	}
+
+	// Ensure a transaction with signature scripts is rejected.
+	tx.TxIn[0].SignatureScript = []byte{0x00}
+	if _, err := New(tx); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("New: unexpected error %v", err)
+	}
+}
+
+// TestDecodeInvalid ensures serialized PSBTs that are malformed or
+// inconsistent are rejected.
+func TestDecodeInvalid(t *testing.T) {
+	t.Parallel()
+
+	tx := testUnsignedTx(wire.OutPoint{Index: 1})
+	rawTx, _ := serializeTx(tx, false)
+	signedTx := tx.Copy()
+	signedTx.TxIn[0].SignatureScript = []byte{0x00}
+	rawSignedTx, _ := serializeTx(signedTx, false)
+
+	unsignedTx := [][]byte{{globalUnsignedTxType}, rawTx}
+	tests := []struct {
+		name       string
+		serialized []byte
+		err        error
+	}{
+		{
+			name:       "valid",
+			serialized: serializeMaps(unsignedTx, nil, nil),
+		},
+		{
+			name: "invalid magic",
+			serialized: append([]byte{0x70, 0x73, 0x62, 0x74, 0x00},
+				serializeMaps(unsignedTx, nil, nil)[5:]...),
+			err: ErrInvalidFormat,
+		},
+		{
+			name:       "missing output map",
+			serialized: serializeMaps(unsignedTx, nil),
+			err:        ErrInvalidFormat,
+		},
+		{
+			name:       "trailing data",
+			serialized: serializeMaps(unsignedTx, nil, nil, nil),
+			err:        ErrInvalidFormat,
+		},
+		{
+			name:       "missing unsigned transaction",
+			serialized: serializeMaps(nil),
+			err:        ErrInvalidFormat,
+		},
+		{
+			name: "signed transaction",
+			serialized: serializeMaps([][]byte{{globalUnsignedTxType},
+				rawSignedTx}, nil, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "unsupported version",
+			serialized: serializeMaps(append(unsignedTx,
+				[]byte{globalVersionType}, []byte{1, 0, 0, 0}),
+				nil, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "duplicate key",
+			serialized: serializeMaps(unsignedTx, [][]byte{
+				{inputRedeemScriptType}, {0x51},
+				{inputRedeemScriptType}, {0x52},
+			}, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "data in key",
+			serialized: serializeMaps(unsignedTx, [][]byte{
+				{inputRedeemScriptType, 0x00}, {0x51},
+			}, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "invalid public key",
+			serialized: serializeMaps(unsignedTx, [][]byte{
+				append([]byte{inputPartialSigType},
+					testPubKey[1:]...), {0x30},
+			}, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "invalid derivation",
+			serialized: serializeMaps(unsignedTx, nil, [][]byte{
+				append([]byte{outputBip32DerivationType},
+					testPubKey...), {0x00, 0x00, 0x00},
+			}),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "invalid witness utxo",
+			serialized: serializeMaps(unsignedTx, [][]byte{
+				{inputWitnessUtxoType}, {0x01, 0x02},
+			}, nil),
+			err: ErrInvalidFormat,
+		},
+		{
+			name: "non-witness utxo hash mismatch",
+			serialized: serializeMaps(unsignedTx, [][]byte{
+				{inputNonWitnessUtxoType}, rawTx,
+			}, nil),
+			err: ErrInvalidPacket,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := Decode(test.serialized)
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: unexpected error - got %v, want %v",
+				test.name, err, test.err)
+		}
+	}
+
+	if _, err := DecodeBase64("cHNidP8"); !errors.Is(err, ErrInvalidFormat) {
+		t.Fatalf("DecodeBase64: unexpected error %v", err)
+	}
+}
+
+// TestCombine ensures PSBTs for the same transaction are merged and PSBTs for
+// different transactions are rejected.
+func TestCombine(t *testing.T) {
+	t.Parallel()
+
+	tx := testUnsignedTx(wire.OutPoint{Index: 1})
+	p1, _ := New(tx)
+	p1.Inputs[0].PartialSigs = []*PartialSig{{
+		PubKey:    testPubKey,
+		Signature: []byte{0x01},
+	}}
+	p1.Inputs[0].RedeemScript = []byte{0x51}
+	p2, _ := New(tx.Copy())
+	otherPubKey := append([]byte{0x03}, testPubKey[1:]...)
+	p2.Inputs[0].PartialSigs = []*PartialSig{
+		{PubKey: testPubKey, Signature: []byte{0x02}},
+		{PubKey: otherPubKey, Signature: []byte{0x03}},
+	}
+	p2.Inputs[0].RedeemScript = []byte{0x52}
+	p2.Outputs[0].WitnessScript = []byte{0x53}
+	p2.Unknowns = []*Unknown{{Key: []byte{0xf0}, Value: []byte{0x01}}}
+
+	combined, err := Combine([]*Packet{p1, p2})
+	if err != nil {
+		t.Fatalf("Combine: unexpected error: %v", err)
+	}
+	if combined.UnsignedTx.TxHash() != tx.TxHash() {
+		t.Fatalf("Combine: unexpected transaction %v",
+			combined.UnsignedTx.TxHash())
+	}
+	want := &Packet{
+		UnsignedTx: combined.UnsignedTx,
+		Inputs: []Input{{
+			PartialSigs: []*PartialSig{
+				{PubKey: testPubKey, Signature: []byte{0x01}},
+				{PubKey: otherPubKey, Signature: []byte{0x03}},
+			},
+			RedeemScript: []byte{0x51},
+		}},
+		Outputs:  []Output{{WitnessScript: []byte{0x53}}},
+		Unknowns: []*Unknown{{Key: []byte{0xf0}, Value: []byte{0x01}}},
+	}
+	if !reflect.DeepEqual(combined, want) {
+		t.Fatalf("Combine: unexpected PSBT - got %+v, want %+v",
+			combined, want)
+	}
+
+	other, _ := New(testUnsignedTx(wire.OutPoint{Hash: chainhash.Hash{1}}))
+	_, err = Combine([]*Packet{p1, other})
+	if err != ErrTxMismatch {
+		t.Fatalf("Combine: unexpected error %v", err)
+	}
+}
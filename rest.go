@@ -0,0 +1,854 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// restPathPrefix is the path prefix of all REST requests.
+	restPathPrefix = "/rest/"
+
+	// restReadTimeout is the maximum amount of time the REST server waits
+	// for a request to be read.
+	restReadTimeout = time.Second * 10
+
+	// restMaxHeaders is the maximum number of block headers and filter
+	// headers returned by a single REST request.
+	restMaxHeaders = 2000
+
+	// restMaxOutpoints is the maximum number of outpoints that may be
+	// queried by a single getutxos REST request.
+	restMaxOutpoints = 15
+
+	// restMempoolHeight is the height reported by the getutxos REST
+	// request for outputs of mempool transactions.
+	restMempoolHeight = 0x7fffffff
+)
+
+// restFormat identifies the encoding of a REST response.
+type restFormat int
+
+const (
+	// restFormatBinary encodes the response in the serialized binary
+	// format.
+	restFormatBinary restFormat = iota
+
+	// restFormatHex encodes the serialized binary format as a hex string.
+	restFormatHex
+
+	// restFormatJSON encodes the response as a JSON object.
+	restFormatJSON
+)
+
+// restFormatExtensions maps the file extensions of REST requests to the
+// format of the response.
+var restFormatExtensions = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restFilterTypes maps the filter type names used by REST requests to the
+// committed filter types.
+var restFilterTypes = map[string]wire.FilterType{
+	"basic": wire.GCSFilterRegular,
+}
+
+// restError is an error that is returned to REST clients along with the HTTP
+// status code.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error returns the message of the REST error.  This satisfies the builtin
+// error interface.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// newRESTError returns a REST error with the passed HTTP status code and a
+// formatted message.
+func newRESTError(status int, format string, args ...interface{}) *restError {
+	return &restError{status: status, message: fmt.Sprintf(format, args...)}
+}
+
+// restHandler is the function signature of the handlers of REST requests.
+// The params are the path segments following the request type with the format
+// extension removed.
+type restHandler func(*restServer, http.ResponseWriter, []string, url.Values, restFormat) error
+
+// restHandlers maps the request types, which are the first path segment after
+// the REST prefix, to their handlers.
+var restHandlers = map[string]restHandler{
+	"block":              handleRESTBlock,
+	"blockfilter":        handleRESTBlockFilter,
+	"blockfilterheaders": handleRESTBlockFilterHeaders,
+	"blockhashbyheight":  handleRESTBlockHashByHeight,
+	"chaininfo":          handleRESTChainInfo,
+	"getutxos":           handleRESTGetUtxos,
+	"headers":            handleRESTHeaders,
+	"mempool":            handleRESTMempool,
+	"tx":                 handleRESTTx,
+}
+
+// restServer provides an unauthenticated, read-only HTTP interface to public
+// chain and mempool data modeled after the REST interface of Bitcoin Core.
+// It shares the RPC server helpers to produce the JSON responses, so they are
+// identical to the results of the equivalent RPCs.
+type restServer struct {
+	started  int32
+	shutdown int32
+
+	// rpc is used to invoke the handlers of the equivalent RPCs.  It is
+	// not started, so it does not serve any RPC clients.
+	rpc        *rpcServer
+	listeners  []net.Listener
+	httpServer *http.Server
+	wg         sync.WaitGroup
+}
+
+// newRESTServer returns a new REST server which serves requests on the
+// listeners of the passed configuration using its chain and mempool.
+func newRESTServer(config *rpcserverConfig) *restServer {
+	rs := &restServer{
+		rpc: &rpcServer{
+			cfg:         *config,
+			statusLines: make(map[int]string),
+			quit:        make(chan int),
+		},
+		listeners: config.Listeners,
+	}
+	rs.httpServer = &http.Server{
+		Handler:     http.HandlerFunc(rs.serveHTTP),
+		ReadTimeout: restReadTimeout,
+	}
+	return rs
+}
+
+// Start begins serving REST requests on all listeners.
+func (rs *restServer) Start() {
+	if atomic.AddInt32(&rs.started, 1) != 1 {
+		return
+	}
+
+	for _, listener := range rs.listeners {
+		rs.wg.Add(1)
+		go func(listener net.Listener) {
+			rpcsLog.Infof("REST server listening on %s", listener.Addr())
+			err := rs.httpServer.Serve(listener)
+			if err != http.ErrServerClosed {
+				rpcsLog.Errorf("REST server: %v", err)
+			}
+			rpcsLog.Tracef("REST listener done for %s", listener.Addr())
+			rs.wg.Done()
+		}(listener)
+	}
+}
+
+// Stop shuts down the REST server and closes all listeners.
+func (rs *restServer) Stop() {
+	if atomic.AddInt32(&rs.shutdown, 1) != 1 {
+		return
+	}
+
+	rs.httpServer.Close()
+	rs.wg.Wait()
+	rpcsLog.Infof("REST server shutdown complete")
+}
+
+// parseRESTPath splits the passed REST request path into the request type, its
+// parameters and the requested response format.  The format is determined by
+// the extension of the final path segment, which is removed.
+func parseRESTPath(path string) (string, []string, restFormat, error) {
+	if !strings.HasPrefix(path, restPathPrefix) {
+		return "", nil, 0, newRESTError(http.StatusNotFound,
+			"not found")
+	}
+	path = strings.TrimPrefix(path, restPathPrefix)
+
+	var ext string
+	if i := strings.LastIndexByte(path, '.'); i != -1 &&
+		!strings.Contains(path[i:], "/") {
+
+		path, ext = path[:i], path[i+1:]
+	}
+	format, ok := restFormatExtensions[ext]
+	if !ok {
+		return "", nil, 0, newRESTError(http.StatusNotFound,
+			"output format not found (available: bin, hex, json)")
+	}
+
+	segments := strings.Split(path, "/")
+	return segments[0], segments[1:], format, nil
+}
+
+// serveHTTP handles a REST request by dispatching it to the handler for its
+// request type and writes any error to the client.
+func (rs *restServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&rs.shutdown) != 0 {
+		return
+	}
+
+	err := rs.handleRequest(w, r)
+	if err == nil {
+		return
+	}
+	rerr, ok := err.(*restError)
+	if !ok {
+		rpcsLog.Errorf("REST request %s failed: %v", r.URL.Path, err)
+		rerr = newRESTError(http.StatusInternalServerError, "%v", err)
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(rerr.status)
+	io.WriteString(w, rerr.message+"\n")
+}
+
+// handleRequest parses the passed REST request and invokes the handler for
+// its request type.
+func (rs *restServer) handleRequest(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return newRESTError(http.StatusMethodNotAllowed,
+			"method %s not allowed", r.Method)
+	}
+
+	reqType, params, format, err := parseRESTPath(r.URL.Path)
+	if err != nil {
+		return err
+	}
+	handler, ok := restHandlers[reqType]
+	if !ok {
+		return newRESTError(http.StatusNotFound, "not found")
+	}
+	rpcsLog.Debugf("Received REST request %s from %s", r.URL.Path,
+		r.RemoteAddr)
+	return handler(rs, w, params, r.URL.Query(), format)
+}
+
+// rpcResult converts the passed result and error of an RPC handler to the
+// result of a REST request.  RPC errors are converted to REST errors with an
+// appropriate HTTP status code.
+func rpcResult(result interface{}, err error) (interface{}, error) {
+	if err == nil {
+		return result, nil
+	}
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return nil, err
+	}
+
+	// Note that the codes of missing blocks and transactions are the same.
+	status := http.StatusInternalServerError
+	switch rpcErr.Code {
+	case btcjson.ErrRPCBlockNotFound:
+		status = http.StatusNotFound
+	case btcjson.ErrRPCDecodeHexString, btcjson.ErrRPCInvalidParameter:
+		status = http.StatusBadRequest
+	}
+	return nil, newRESTError(status, "%s", rpcErr.Message)
+}
+
+// writeRESTBinary writes the passed serialized data to the client in the
+// binary or hex format.
+func writeRESTBinary(w http.ResponseWriter, format restFormat, data []byte) error {
+	switch format {
+	case restFormatBinary:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, err := w.Write(data)
+		return err
+
+	case restFormatHex:
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := io.WriteString(w, hex.EncodeToString(data)+"\n")
+		return err
+	}
+	return newRESTError(http.StatusNotFound, "output format not found "+
+		"(available: json)")
+}
+
+// writeRESTJSON writes the passed result to the client in the JSON format.
+func writeRESTJSON(w http.ResponseWriter, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// requireJSON returns an error unless the passed format is JSON, which is the
+// only format supported by some request types.
+func requireJSON(format restFormat) error {
+	if format != restFormatJSON {
+		return newRESTError(http.StatusNotFound, "output format not "+
+			"found (available: json)")
+	}
+	return nil
+}
+
+// parseRESTHash decodes the passed block or transaction hash parameter.
+func parseRESTHash(param string) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(param)
+	if err != nil || len(param) != chainhash.MaxHashStringSize {
+		return nil, newRESTError(http.StatusBadRequest, "invalid hash: "+
+			"%s", param)
+	}
+	return hash, nil
+}
+
+// parseRESTCount decodes the passed count parameter of the headers requests
+// which is either a path segment or, if empty, the count query value.
+func parseRESTCount(param string, query url.Values) (int, error) {
+	if param == "" {
+		param = query.Get("count")
+		if param == "" {
+			param = "5"
+		}
+	}
+	count, err := strconv.Atoi(param)
+	if err != nil || count < 1 || count > restMaxHeaders {
+		return 0, newRESTError(http.StatusBadRequest, "header count is "+
+			"invalid or out of acceptable range (1-%d): %s",
+			restMaxHeaders, param)
+	}
+	return count, nil
+}
+
+// parseRESTRange decodes the optional count and the block hash parameters of
+// the headers requests, which are either <count>/<hash> or <hash> with the
+// count in the query.
+func parseRESTRange(params []string, query url.Values) (int, *chainhash.Hash, error) {
+	var countParam, hashParam string
+	switch len(params) {
+	case 1:
+		hashParam = params[0]
+	case 2:
+		countParam, hashParam = params[0], params[1]
+	default:
+		return 0, nil, newRESTError(http.StatusBadRequest, "invalid "+
+			"URI format, expected <count>/<hash> or <hash>?count=<count>")
+	}
+	count, err := parseRESTCount(countParam, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	hash, err := parseRESTHash(hashParam)
+	if err != nil {
+		return 0, nil, err
+	}
+	return count, hash, nil
+}
+
+// mainChainRange returns the hashes of up to count main chain blocks starting
+// with the block with the passed hash.  No hashes are returned when the block
+// is not part of the main chain.
+func (rs *restServer) mainChainRange(hash *chainhash.Hash, count int) []chainhash.Hash {
+	chain := rs.rpc.cfg.Chain
+	height, err := chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil
+	}
+	hashes := make([]chainhash.Hash, 0, count)
+	hashes = append(hashes, *hash)
+	for len(hashes) < count {
+		next, err := chain.BlockHashByHeight(height + int32(len(hashes)))
+		if err != nil {
+			break
+		}
+		hashes = append(hashes, *next)
+	}
+	return hashes
+}
+
+// handleRESTTx implements the tx/<txid> REST request.  The transaction index
+// is required for transactions that are not in the mempool.
+func handleRESTTx(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	if len(params) != 1 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected tx/<txid>")
+	}
+	txHash, err := parseRESTHash(params[0])
+	if err != nil {
+		return err
+	}
+
+	// Transactions that are not in the mempool can only be found with the
+	// transaction index.
+	if rs.rpc.cfg.TxIndex == nil &&
+		!rs.rpc.cfg.TxMemPool.HaveTransaction(txHash) {
+
+		return newRESTError(http.StatusServiceUnavailable, "the "+
+			"transaction index must be enabled to query transactions "+
+			"that are not in the mempool (specify --txindex)")
+	}
+
+	verbose := 0
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	cmd := &btcjson.GetRawTransactionCmd{
+		Txid:    txHash.String(),
+		Verbose: &verbose,
+	}
+	result, err := rpcResult(handleGetRawTransaction(rs.rpc, cmd, nil))
+	if err != nil {
+		return err
+	}
+	if format == restFormatJSON {
+		return writeRESTJSON(w, result)
+	}
+	txBytes, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return err
+	}
+	return writeRESTBinary(w, format, txBytes)
+}
+
+// handleRESTBlock implements the block/<hash> and block/notxdetails/<hash>
+// REST requests.  The JSON format includes the details of all transactions
+// unless notxdetails is specified.
+func handleRESTBlock(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	verbosity := 2
+	if len(params) == 2 && params[0] == "notxdetails" {
+		verbosity = 1
+		params = params[1:]
+	}
+	if len(params) != 1 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected block/<hash> or block/notxdetails/<hash>")
+	}
+	hash, err := parseRESTHash(params[0])
+	if err != nil {
+		return err
+	}
+
+	if format != restFormatJSON {
+		verbosity = 0
+	}
+	cmd := &btcjson.GetBlockCmd{
+		Hash:      hash.String(),
+		Verbosity: &verbosity,
+	}
+	result, err := rpcResult(handleGetBlock(rs.rpc, cmd, nil))
+	if err != nil {
+		return err
+	}
+	if format == restFormatJSON {
+		return writeRESTJSON(w, result)
+	}
+	blockBytes, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return err
+	}
+	return writeRESTBinary(w, format, blockBytes)
+}
+
+// handleRESTHeaders implements the headers/<count>/<hash> REST request, which
+// returns up to count main chain block headers starting with the block with
+// the given hash.
+func handleRESTHeaders(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	count, hash, err := parseRESTRange(params, query)
+	if err != nil {
+		return err
+	}
+	hashes := rs.mainChainRange(hash, count)
+
+	if format == restFormatJSON {
+		headers := make([]interface{}, 0, len(hashes))
+		for i := range hashes {
+			cmd := &btcjson.GetBlockHeaderCmd{
+				Hash:    hashes[i].String(),
+				Verbose: btcjson.Bool(true),
+			}
+			header, err := rpcResult(handleGetBlockHeader(rs.rpc,
+				cmd, nil))
+			if err != nil {
+				return err
+			}
+			headers = append(headers, header)
+		}
+		return writeRESTJSON(w, headers)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(len(hashes) * wire.MaxBlockHeaderPayload)
+	for i := range hashes {
+		header, err := rs.rpc.cfg.Chain.HeaderByHash(&hashes[i])
+		if err != nil {
+			return err
+		}
+		if err := header.Serialize(&buf); err != nil {
+			return err
+		}
+	}
+	return writeRESTBinary(w, format, buf.Bytes())
+}
+
+// handleRESTBlockHashByHeight implements the blockhashbyheight/<height> REST
+// request.  The binary format is the hash in internal byte order while the
+// hex and JSON formats use the usual byte-reversed string.
+func handleRESTBlockHashByHeight(rs *restServer, w http.ResponseWriter,
+	params []string, query url.Values, format restFormat) error {
+
+	if len(params) != 1 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected blockhashbyheight/<height>")
+	}
+	height, err := strconv.ParseInt(params[0], 10, 32)
+	if err != nil || height < 0 {
+		return newRESTError(http.StatusBadRequest, "invalid height: %s",
+			params[0])
+	}
+	hash, err := rs.rpc.cfg.Chain.BlockHashByHeight(int32(height))
+	if err != nil {
+		return newRESTError(http.StatusNotFound, "block height out of "+
+			"range")
+	}
+
+	switch format {
+	case restFormatBinary:
+		return writeRESTBinary(w, format, hash[:])
+
+	case restFormatHex:
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := io.WriteString(w, hash.String()+"\n")
+		return err
+	}
+	return writeRESTJSON(w, map[string]string{"blockhash": hash.String()})
+}
+
+// handleRESTChainInfo implements the chaininfo REST request, which returns the
+// result of the getblockchaininfo RPC.
+func handleRESTChainInfo(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	if err := requireJSON(format); err != nil {
+		return err
+	}
+	cmd := &btcjson.GetBlockChainInfoCmd{}
+	result, err := rpcResult(handleGetBlockChainInfo(rs.rpc, cmd, nil))
+	if err != nil {
+		return err
+	}
+	return writeRESTJSON(w, result)
+}
+
+// handleRESTMempool implements the mempool/info and mempool/contents REST
+// requests, which return the results of the getmempoolinfo and verbose
+// getrawmempool RPCs.
+func handleRESTMempool(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	if err := requireJSON(format); err != nil {
+		return err
+	}
+	if len(params) != 1 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected mempool/info or mempool/contents")
+	}
+
+	var result interface{}
+	var err error
+	switch params[0] {
+	case "info":
+		cmd := &btcjson.GetMempoolInfoCmd{}
+		result, err = rpcResult(handleGetMempoolInfo(rs.rpc, cmd, nil))
+	case "contents":
+		cmd := &btcjson.GetRawMempoolCmd{Verbose: btcjson.Bool(true)}
+		result, err = rpcResult(handleGetRawMempool(rs.rpc, cmd, nil))
+	default:
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected mempool/info or mempool/contents")
+	}
+	if err != nil {
+		return err
+	}
+	return writeRESTJSON(w, result)
+}
+
+// restUtxo describes an unspent output in the JSON response of the getutxos
+// REST request.
+type restUtxo struct {
+	Height       int32                      `json:"height"`
+	Value        float64                    `json:"value"`
+	ScriptPubKey btcjson.ScriptPubKeyResult `json:"scriptPubKey"`
+}
+
+// restGetUtxosResult is the JSON response of the getutxos REST request.  The
+// bitmap has a 1 for each requested outpoint that is unspent and a 0
+// otherwise, and the utxos describe the unspent outputs in request order.
+type restGetUtxosResult struct {
+	ChainHeight  int32      `json:"chainHeight"`
+	ChainTipHash string     `json:"chaintipHash"`
+	Bitmap       string     `json:"bitmap"`
+	Utxos        []restUtxo `json:"utxos"`
+}
+
+// parseRESTOutpoint decodes the passed <txid>-<index> outpoint parameter.
+func parseRESTOutpoint(param string) (*wire.OutPoint, error) {
+	i := strings.IndexByte(param, '-')
+	if i == -1 {
+		return nil, newRESTError(http.StatusBadRequest, "invalid "+
+			"outpoint: %s", param)
+	}
+	hash, err := parseRESTHash(param[:i])
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(param[i+1:], 10, 32)
+	if err != nil {
+		return nil, newRESTError(http.StatusBadRequest, "invalid "+
+			"outpoint: %s", param)
+	}
+	return wire.NewOutPoint(hash, uint32(index)), nil
+}
+
+// fetchUtxo returns the height, value and public key script of the passed
+// outpoint when it is unspent.  When checkMempool is set, outputs spent by
+// mempool transactions are considered spent and the outputs of mempool
+// transactions are included.
+func (rs *restServer) fetchUtxo(outpoint *wire.OutPoint, checkMempool bool) (*restUtxo, *wire.TxOut, error) {
+	mp := rs.rpc.cfg.TxMemPool
+	if checkMempool {
+		if mp.CheckSpend(*outpoint) != nil {
+			return nil, nil, nil
+		}
+		if tx, err := mp.FetchTransaction(&outpoint.Hash); err == nil {
+			txOuts := tx.MsgTx().TxOut
+			if outpoint.Index >= uint32(len(txOuts)) {
+				return nil, nil, nil
+			}
+			txOut := txOuts[outpoint.Index]
+			return &restUtxo{Height: restMempoolHeight}, txOut, nil
+		}
+	}
+
+	entry, err := rs.rpc.cfg.Chain.FetchUtxoEntry(*outpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if entry == nil || entry.IsSpent() {
+		return nil, nil, nil
+	}
+	txOut := wire.NewTxOut(entry.Amount(), entry.PkScript())
+	return &restUtxo{Height: entry.BlockHeight()}, txOut, nil
+}
+
+// handleRESTGetUtxos implements the getutxos/<txid>-<n>/... REST request,
+// which reports which of the requested outpoints are unspent along with the
+// unspent outputs.  The outpoints may be preceded by checkmempool to take the
+// mempool into account.
+func handleRESTGetUtxos(rs *restServer, w http.ResponseWriter, params []string,
+	query url.Values, format restFormat) error {
+
+	checkMempool := len(params) > 0 && params[0] == "checkmempool"
+	if checkMempool {
+		params = params[1:]
+	}
+	if len(params) == 0 || (len(params) == 1 && params[0] == "") {
+		return newRESTError(http.StatusBadRequest, "empty request")
+	}
+	if len(params) > restMaxOutpoints {
+		return newRESTError(http.StatusBadRequest, "max outpoints "+
+			"exceeded (max: %d, tried: %d)", restMaxOutpoints,
+			len(params))
+	}
+	outpoints := make([]*wire.OutPoint, 0, len(params))
+	for _, param := range params {
+		outpoint, err := parseRESTOutpoint(param)
+		if err != nil {
+			return err
+		}
+		outpoints = append(outpoints, outpoint)
+	}
+
+	best := rs.rpc.cfg.Chain.BestSnapshot()
+	bitmap := make([]byte, (len(outpoints)+7)/8)
+	bitmapStr := make([]byte, len(outpoints))
+	utxos := make([]restUtxo, 0, len(outpoints))
+	txOuts := make([]*wire.TxOut, 0, len(outpoints))
+	for i, outpoint := range outpoints {
+		utxo, txOut, err := rs.fetchUtxo(outpoint, checkMempool)
+		if err != nil {
+			return err
+		}
+		if utxo == nil {
+			bitmapStr[i] = '0'
+			continue
+		}
+		bitmap[i/8] |= 1 << uint(i%8)
+		bitmapStr[i] = '1'
+		utxo.Value = btcutil.Amount(txOut.Value).ToBTC()
+		utxo.ScriptPubKey = createScriptPubKeyResult(txOut.PkScript,
+			rs.rpc.cfg.ChainParams)
+		utxos = append(utxos, *utxo)
+		txOuts = append(txOuts, txOut)
+	}
+
+	if format == restFormatJSON {
+		return writeRESTJSON(w, &restGetUtxosResult{
+			ChainHeight:  best.Height,
+			ChainTipHash: best.Hash.String(),
+			Bitmap:       string(bitmapStr),
+			Utxos:        utxos,
+		})
+	}
+
+	// The binary format matches Bitcoin Core and consists of the chain
+	// height, the chain tip hash, the bitmap, and the unspent outputs,
+	// each preceded by an unused version and its height.
+	var buf bytes.Buffer
+	var scratch [4]byte
+	binary.LittleEndian.PutUint32(scratch[:], uint32(best.Height))
+	buf.Write(scratch[:])
+	buf.Write(best.Hash[:])
+	if err := wire.WriteVarBytes(&buf, 0, bitmap); err != nil {
+		return err
+	}
+	if err := wire.WriteVarInt(&buf, 0, uint64(len(utxos))); err != nil {
+		return err
+	}
+	for i := range utxos {
+		binary.LittleEndian.PutUint32(scratch[:], 0)
+		buf.Write(scratch[:])
+		binary.LittleEndian.PutUint32(scratch[:], uint32(utxos[i].Height))
+		buf.Write(scratch[:])
+		if err := wire.WriteTxOut(&buf, 0, 0, txOuts[i]); err != nil {
+			return err
+		}
+	}
+	return writeRESTBinary(w, format, buf.Bytes())
+}
+
+// parseRESTFilterType decodes the passed filter type name and ensures the
+// committed filter index is available.
+func (rs *restServer) parseRESTFilterType(param string) (wire.FilterType, error) {
+	filterType, ok := restFilterTypes[param]
+	if !ok {
+		return 0, newRESTError(http.StatusBadRequest, "unknown filter "+
+			"type: %s", param)
+	}
+	if rs.rpc.cfg.CfIndex == nil {
+		return 0, newRESTError(http.StatusServiceUnavailable, "index "+
+			"is not enabled for filtertype %s", param)
+	}
+	return filterType, nil
+}
+
+// handleRESTBlockFilter implements the blockfilter/<filtertype>/<hash> REST
+// request.  The binary format matches Bitcoin Core and consists of the filter
+// type, the block hash and the encoded filter.
+func handleRESTBlockFilter(rs *restServer, w http.ResponseWriter,
+	params []string, query url.Values, format restFormat) error {
+
+	if len(params) != 2 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected blockfilter/<filtertype>/<hash>")
+	}
+	filterType, err := rs.parseRESTFilterType(params[0])
+	if err != nil {
+		return err
+	}
+	hash, err := parseRESTHash(params[1])
+	if err != nil {
+		return err
+	}
+
+	filter, err := rs.rpc.cfg.CfIndex.FilterByBlockHash(hash, filterType)
+	if err != nil || len(filter) == 0 {
+		return newRESTError(http.StatusNotFound, "block not found: %v",
+			hash)
+	}
+
+	if format == restFormatJSON {
+		return writeRESTJSON(w, map[string]string{
+			"filter": hex.EncodeToString(filter),
+		})
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(byte(filterType))
+	buf.Write(hash[:])
+	if err := wire.WriteVarBytes(&buf, 0, filter); err != nil {
+		return err
+	}
+	return writeRESTBinary(w, format, buf.Bytes())
+}
+
+// handleRESTBlockFilterHeaders implements the
+// blockfilterheaders/<filtertype>/<count>/<hash> REST request, which returns
+// the filter headers of up to count main chain blocks starting with the block
+// with the given hash.
+func handleRESTBlockFilterHeaders(rs *restServer, w http.ResponseWriter,
+	params []string, query url.Values, format restFormat) error {
+
+	if len(params) < 2 {
+		return newRESTError(http.StatusBadRequest, "invalid URI format, "+
+			"expected blockfilterheaders/<filtertype>/<count>/<hash>")
+	}
+	filterType, err := rs.parseRESTFilterType(params[0])
+	if err != nil {
+		return err
+	}
+	count, hash, err := parseRESTRange(params[1:], query)
+	if err != nil {
+		return err
+	}
+
+	hashes := rs.mainChainRange(hash, count)
+	blockHashes := make([]*chainhash.Hash, len(hashes))
+	for i := range hashes {
+		blockHashes[i] = &hashes[i]
+	}
+	headers, err := rs.rpc.cfg.CfIndex.FilterHeadersByBlockHashes(
+		blockHashes, filterType)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	jsonHeaders := make([]string, 0, len(headers))
+	for i, header := range headers {
+		if len(header) != chainhash.HashSize {
+			return newRESTError(http.StatusNotFound, "filter header "+
+				"not found: %v", blockHashes[i])
+		}
+		buf.Write(header)
+		var headerHash chainhash.Hash
+		copy(headerHash[:], header)
+		jsonHeaders = append(jsonHeaders, headerHash.String())
+	}
+
+	if format == restFormatJSON {
+		return writeRESTJSON(w, jsonHeaders)
+	}
+	return writeRESTBinary(w, format, buf.Bytes())
+}
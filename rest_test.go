@@ -0,0 +1,134 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// TestParseRESTPath ensures REST request paths are split into the request
+// type, parameters and response format as expected.
+func TestParseRESTPath(t *testing.T) {
+	t.Parallel()
+
+	const hash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	tests := []struct {
+		path    string
+		reqType string
+		params  []string
+		format  restFormat
+		status  int
+	}{
+		{
+			path:    "/rest/chaininfo.json",
+			reqType: "chaininfo",
+			params:  []string{},
+			format:  restFormatJSON,
+		},
+		{
+			path:    "/rest/block/notxdetails/" + hash + ".bin",
+			reqType: "block",
+			params:  []string{"notxdetails", hash},
+			format:  restFormatBinary,
+		},
+		{
+			path:    "/rest/getutxos/checkmempool/" + hash + "-0/" + hash + "-1.hex",
+			reqType: "getutxos",
+			params:  []string{"checkmempool", hash + "-0", hash + "-1"},
+			format:  restFormatHex,
+		},
+		{
+			path:   "/rest/block/" + hash,
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/rest/block/" + hash + ".xml",
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/rest/v1.0/block/" + hash,
+			status: http.StatusNotFound,
+		},
+		{
+			path:   "/block/" + hash + ".json",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		reqType, params, format, err := parseRESTPath(test.path)
+		if test.status != 0 {
+			rerr, ok := err.(*restError)
+			if !ok || rerr.status != test.status {
+				t.Errorf("%s: unexpected error: got %v, want status "+
+					"%d", test.path, err, test.status)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.path, err)
+			continue
+		}
+		if reqType != test.reqType || format != test.format ||
+			!reflect.DeepEqual(params, test.params) {
+
+			t.Errorf("%s: unexpected result: got %q %q %d, want %q "+
+				"%q %d", test.path, reqType, params, format,
+				test.reqType, test.params, test.format)
+		}
+	}
+}
+
+// TestParseRESTRange ensures the count and hash parameters of the headers
+// requests are parsed from both supported URI formats and that invalid
+// counts are rejected.
+func TestParseRESTRange(t *testing.T) {
+	t.Parallel()
+
+	const hash = "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	tests := []struct {
+		name   string
+		params []string
+		query  string
+		count  int
+		valid  bool
+	}{
+		{"count in path", []string{"10", hash}, "", 10, true},
+		{"count in query", []string{hash}, "count=20", 20, true},
+		{"default count", []string{hash}, "", 5, true},
+		{"max count", []string{"2000", hash}, "", 2000, true},
+		{"zero count", []string{"0", hash}, "", 0, false},
+		{"count too large", []string{hash}, "count=2001", 0, false},
+		{"invalid count", []string{"ten", hash}, "", 0, false},
+		{"short hash", []string{"10", hash[1:]}, "", 0, false},
+		{"missing hash", []string{}, "", 0, false},
+	}
+
+	for _, test := range tests {
+		query, err := url.ParseQuery(test.query)
+		if err != nil {
+			t.Fatalf("%s: unable to parse query: %v", test.name, err)
+		}
+		count, blockHash, err := parseRESTRange(test.params, query)
+		if !test.valid {
+			if _, ok := err.(*restError); !ok {
+				t.Errorf("%s: expected REST error, got %v",
+					test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if count != test.count || blockHash.String() != hash {
+			t.Errorf("%s: unexpected result: got %d %v, want %d %s",
+				test.name, count, blockHash, test.count, hash)
+		}
+	}
+}
@@ -5,10 +5,14 @@
 package main
 
 import (
+	"net"
 	"sync/atomic"
+	"time"
 
+	"github.com/btcsuite/btcd/addrmgr"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/netsync"
 	"github.com/btcsuite/btcd/peer"
@@ -40,7 +44,7 @@ func (p *rpcPeer) ToPeer() *peer.Peer {
 // This function is safe for concurrent access and is part of the rpcserverPeer
 // interface implementation.
 func (p *rpcPeer) IsTxRelayDisabled() bool {
-	return (*serverPeer)(p).disableRelayTx
+	return (*serverPeer)(p).relayTxDisabled()
 }
 
 // BanScore returns the current integer value that represents how close the peer
@@ -61,6 +65,15 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// ConnectionType returns a human-readable description of the type of
+// connection to the peer.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) ConnectionType() string {
+	return (*serverPeer)(p).connectionType()
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -164,6 +177,23 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// MsgTraffic returns the messages and bytes sent and received across all peers
+// keyed by message command.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) MsgTraffic() map[string]peer.MsgTraffic {
+	return cm.server.MsgTraffic()
+}
+
+// UploadTarget returns the state of the upload target for the current cycle.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() *uploadTargetStats {
+	return cm.server.uploadTarget.Stats()
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -232,6 +262,56 @@ func (cm *rpcConnManager) NodeAddresses() []*wire.NetAddress {
 	return cm.server.addrManager.AddressCache()
 }
 
+// Ban bans the provided subnet until the provided time and disconnects any
+// connected peers within it, including persistent peers.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Ban(subnet *net.IPNet, until time.Time) error {
+	if err := cm.server.banList.Ban(subnet, until); err != nil {
+		return err
+	}
+
+	// Disconnect all peers in the banned subnet.
+	replyChan := make(chan int)
+	cm.server.query <- disconnectSubnetMsg{subnet: subnet, reply: replyChan}
+	<-replyChan
+	return nil
+}
+
+// Unban removes the ban on the provided subnet.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Unban(subnet *net.IPNet) error {
+	return cm.server.banList.Unban(subnet)
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() error {
+	return cm.server.banList.Clear()
+}
+
+// BannedSubnets returns the subnets that are currently banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedSubnets() []connmgr.BanEntry {
+	return cm.server.banList.Entries()
+}
+
+// LocalAddresses returns the local addresses known to the address manager
+// along with their scores.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) LocalAddresses() []addrmgr.LocalAddress {
+	return cm.server.addrManager.LocalAddresses()
+}
+
 // rpcSyncMgr provides a block manager for use with the RPC server and
 // implements the rpcserverSyncManager interface.
 type rpcSyncMgr struct {
@@ -277,6 +357,26 @@ func (b *rpcSyncMgr) SyncPeerID() int32 {
 	return b.syncMgr.SyncPeerID()
 }
 
+// PeerSyncStats returns the sync statistics of all peers known to the sync
+// manager keyed by their ID.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverSyncManager interface implementation.
+func (b *rpcSyncMgr) PeerSyncStats() map[int32]*rpcserverPeerSyncStats {
+	syncStats := b.syncMgr.PeerSyncStats()
+	stats := make(map[int32]*rpcserverPeerSyncStats, len(syncStats))
+	for id, s := range syncStats {
+		stats[id] = &rpcserverPeerSyncStats{
+			InFlightHeights: s.InFlightHeights,
+			BlocksReceived:  s.BlocksReceived,
+			HeadersReceived: s.HeadersReceived,
+			BlockRate:       s.BlockRate,
+			HeaderRate:      s.HeaderRate,
+		}
+	}
+	return stats
+}
+
 // LocateBlocks returns the hashes of the blocks after the first known block in
 // the provided locators until the provided stop hash or the current tip is
 // reached, up to a max of wire.MaxBlockHeadersPerMsg hashes.
@@ -0,0 +1,296 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// rpcAuthUser describes an authenticated RPC user along with the methods it is
+// allowed to invoke.  The websocket notifications a user receives are governed
+// by the methods which register for them, such as notifyblocks.
+type rpcAuthUser struct {
+	name string
+
+	// limited specifies whether the user is the limited user configured
+	// with the rpclimituser option.
+	limited bool
+
+	// methods is the set of methods the user is allowed to invoke.  All
+	// methods are allowed when it is nil.
+	methods map[string]struct{}
+}
+
+// allowed returns whether the user is allowed to invoke the passed method.
+func (u *rpcAuthUser) allowed(method string) bool {
+	if u.methods == nil {
+		return true
+	}
+	_, ok := u.methods[method]
+	return ok
+}
+
+// checkMethod returns an RPC error when the user is not allowed to invoke the
+// passed method.
+func (u *rpcAuthUser) checkMethod(method string) *btcjson.RPCError {
+	if u.allowed(method) {
+		return nil
+	}
+	message := "user not authorized for this method"
+	if u.limited {
+		message = "limited user not authorized for this method"
+	}
+	return &btcjson.RPCError{
+		Code:    btcjson.ErrRPCInvalidParams.Code,
+		Message: message,
+	}
+}
+
+// rpcAuthCredential houses the hashed credentials of an RPC user configured
+// with the rpcauth option.
+type rpcAuthCredential struct {
+	user string
+	salt string
+	hash [sha256.Size]byte
+}
+
+// rpcAuthHash returns the HMAC-SHA256 of the passed password keyed by the
+// passed salt, which is the hash stored by the rpcauth option.
+func rpcAuthHash(salt, password string) [sha256.Size]byte {
+	var hash [sha256.Size]byte
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(password))
+	copy(hash[:], mac.Sum(nil))
+	return hash
+}
+
+// parseRPCAuth parses the passed rpcauth option value which is in the form
+// <user>:<salt>$<hash> where the hash is the hex-encoded result of rpcAuthHash.
+func parseRPCAuth(value string) (*rpcAuthCredential, error) {
+	i := strings.IndexByte(value, ':')
+	if i < 1 {
+		return nil, fmt.Errorf("rpcauth %q is not in the form "+
+			"<user>:<salt>$<hash>", value)
+	}
+	user, saltedHash := value[:i], value[i+1:]
+	j := strings.IndexByte(saltedHash, '$')
+	if j < 1 {
+		return nil, fmt.Errorf("rpcauth for user %q is not in the "+
+			"form <user>:<salt>$<hash>", user)
+	}
+	salt, hashStr := saltedHash[:j], saltedHash[j+1:]
+
+	cred := &rpcAuthCredential{user: user, salt: salt}
+	hash, err := hex.DecodeString(hashStr)
+	if err != nil || len(hash) != len(cred.hash) {
+		return nil, fmt.Errorf("rpcauth for user %q does not contain "+
+			"a valid hex-encoded HMAC-SHA256 hash", user)
+	}
+	copy(cred.hash[:], hash)
+	return cred, nil
+}
+
+// parseRPCWhitelist parses the passed rpcwhitelist option value which is in
+// the form <user>:<method>,<method>,... and returns the user and the set of
+// methods.  The method list may be empty to disallow all methods.
+func parseRPCWhitelist(value string) (string, map[string]struct{}, error) {
+	i := strings.IndexByte(value, ':')
+	if i < 1 {
+		return "", nil, fmt.Errorf("rpcwhitelist %q is not in the form "+
+			"<user>:<method>,<method>,...", value)
+	}
+	user := value[:i]
+	methods := make(map[string]struct{})
+	for _, method := range strings.Split(value[i+1:], ",") {
+		method = strings.TrimSpace(method)
+		if method != "" {
+			methods[method] = struct{}{}
+		}
+	}
+	return user, methods, nil
+}
+
+// intersectMethods returns the methods contained in both passed sets where a
+// nil set contains all methods.
+func intersectMethods(a, b map[string]struct{}) map[string]struct{} {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+	methods := make(map[string]struct{})
+	for method := range a {
+		if _, ok := b[method]; ok {
+			methods[method] = struct{}{}
+		}
+	}
+	return methods
+}
+
+// rpcAuthenticator checks the credentials supplied by RPC clients against the
+// admin and limited users as well as the users configured with the rpcauth
+// option and determines the methods the authenticated user may invoke.
+type rpcAuthenticator struct {
+	authsha      [sha256.Size]byte
+	limitauthsha [sha256.Size]byte
+	adminUser    *rpcAuthUser
+	limitUser    *rpcAuthUser
+
+	credentials []*rpcAuthCredential
+	users       map[string]*rpcAuthUser
+
+	// unixUser is the user of clients connected over a Unix domain socket
+	// that do not supply credentials.  It is nil when such clients must
+	// authenticate like any other client.
+	unixUser *rpcAuthUser
+}
+
+// newRPCAuthenticator returns an authenticator for the users and method
+// whitelists of the passed configuration.  The methods of a user with more
+// than one whitelist entry are restricted to those allowed by all of them,
+// and the limited user is always restricted to the rpcLimited methods.
+func newRPCAuthenticator(cfg *config) (*rpcAuthenticator, error) {
+	whitelists := make(map[string]map[string]struct{})
+	for _, value := range cfg.RPCWhitelist {
+		user, methods, err := parseRPCWhitelist(value)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := whitelists[user]; ok {
+			methods = intersectMethods(existing, methods)
+		}
+		whitelists[user] = methods
+	}
+
+	a := &rpcAuthenticator{
+		users: make(map[string]*rpcAuthUser),
+	}
+	known := make(map[string]struct{})
+	if cfg.RPCUser != "" && cfg.RPCPass != "" {
+		login := cfg.RPCUser + ":" + cfg.RPCPass
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+		a.authsha = sha256.Sum256([]byte(auth))
+		a.adminUser = &rpcAuthUser{
+			name:    cfg.RPCUser,
+			methods: whitelists[cfg.RPCUser],
+		}
+		known[cfg.RPCUser] = struct{}{}
+	}
+	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
+		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+		a.limitauthsha = sha256.Sum256([]byte(auth))
+		a.limitUser = &rpcAuthUser{
+			name:    cfg.RPCLimitUser,
+			limited: true,
+			methods: intersectMethods(rpcLimited,
+				whitelists[cfg.RPCLimitUser]),
+		}
+		known[cfg.RPCLimitUser] = struct{}{}
+	}
+	for _, value := range cfg.RPCAuth {
+		cred, err := parseRPCAuth(value)
+		if err != nil {
+			return nil, err
+		}
+		a.credentials = append(a.credentials, cred)
+		if _, ok := a.users[cred.user]; !ok {
+			a.users[cred.user] = &rpcAuthUser{
+				name:    cred.user,
+				methods: whitelists[cred.user],
+			}
+		}
+		known[cred.user] = struct{}{}
+	}
+
+	if cfg.RPCUnixNoAuth {
+		a.unixUser = &rpcAuthUser{name: "unix"}
+	}
+
+	for user := range whitelists {
+		if _, ok := known[user]; !ok {
+			return nil, fmt.Errorf("rpcwhitelist specified for "+
+				"unknown user %q", user)
+		}
+	}
+	return a, nil
+}
+
+// authenticate returns the user identified by the passed HTTP basic access
+// authorization header value or nil when it does not match any user.
+//
+// The checks against the admin and limited users are time-constant, as are
+// the comparisons of the hashed credentials.
+func (a *rpcAuthenticator) authenticate(authHeader string) *rpcAuthUser {
+	authsha := sha256.Sum256([]byte(authHeader))
+
+	// Check for limited auth first as in environments with limited users,
+	// those are probably expected to have a higher volume of calls.
+	if a.limitUser != nil &&
+		subtle.ConstantTimeCompare(authsha[:], a.limitauthsha[:]) == 1 {
+
+		return a.limitUser
+	}
+
+	// Check for admin-level auth.
+	if a.adminUser != nil &&
+		subtle.ConstantTimeCompare(authsha[:], a.authsha[:]) == 1 {
+
+		return a.adminUser
+	}
+
+	// Check the hashed credentials of the rpcauth users.
+	if len(a.credentials) == 0 {
+		return nil
+	}
+	user, pass, ok := parseBasicAuth(authHeader)
+	if !ok {
+		return nil
+	}
+	var match *rpcAuthCredential
+	for _, cred := range a.credentials {
+		hash := rpcAuthHash(cred.salt, pass)
+		userCmp := subtle.ConstantTimeCompare([]byte(user),
+			[]byte(cred.user))
+		hashCmp := subtle.ConstantTimeCompare(hash[:], cred.hash[:])
+		if userCmp&hashCmp == 1 && match == nil {
+			match = cred
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	return a.users[match.user]
+}
+
+// parseBasicAuth extracts the username and password from the passed HTTP basic
+// access authorization header value.
+func parseBasicAuth(authHeader string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(authHeader) < len(prefix) ||
+		!strings.EqualFold(authHeader[:len(prefix)], prefix) {
+
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(authHeader[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	login := string(decoded)
+	i := strings.IndexByte(login, ':')
+	if i == -1 {
+		return "", "", false
+	}
+	return login[:i], login[i+1:], true
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+// basicAuth returns the HTTP basic access authorization header value for the
+// passed username and password.
+func basicAuth(user, pass string) string {
+	login := user + ":" + pass
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+}
+
+// TestParseRPCAuth ensures rpcauth option values are parsed as expected and
+// that malformed values are rejected.
+func TestParseRPCAuth(t *testing.T) {
+	t.Parallel()
+
+	hash := rpcAuthHash("salt", "pass")
+	hashStr := hex.EncodeToString(hash[:])
+	tests := []struct {
+		value string
+		user  string
+		salt  string
+		valid bool
+	}{
+		{"alice:salt$" + hashStr, "alice", "salt", true},
+		{"alice:salt$" + hashStr[2:], "", "", false},
+		{"alice:salt$" + hashStr[1:] + "z", "", "", false},
+		{"alice:salt" + hashStr, "", "", false},
+		{"alice:$" + hashStr, "", "", false},
+		{":salt$" + hashStr, "", "", false},
+		{"alice", "", "", false},
+	}
+
+	for _, test := range tests {
+		cred, err := parseRPCAuth(test.value)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q: expected error", test.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.value, err)
+			continue
+		}
+		if cred.user != test.user || cred.salt != test.salt ||
+			cred.hash != hash {
+
+			t.Errorf("%q: unexpected credential: %+v", test.value, cred)
+		}
+	}
+}
+
+// TestRPCAuthenticator ensures clients are authenticated as the expected user
+// and that the method whitelists are applied.
+func TestRPCAuthenticator(t *testing.T) {
+	t.Parallel()
+
+	aliceHash := rpcAuthHash("s1", "alicepass")
+	bobHash := rpcAuthHash("s2", "bobpass")
+	c := &config{
+		RPCUser:      "admin",
+		RPCPass:      "adminpass",
+		RPCLimitUser: "limit",
+		RPCLimitPass: "limitpass",
+		RPCAuth: []string{
+			"alice:s1$" + hex.EncodeToString(aliceHash[:]),
+			"bob:s2$" + hex.EncodeToString(bobHash[:]),
+		},
+		RPCWhitelist: []string{
+			"alice:getblockcount, getblock,notifyblocks",
+			"alice:getblock,notifyblocks,stop",
+			"limit:getblockcount,stop",
+			"admin:",
+		},
+	}
+	auth, err := newRPCAuthenticator(c)
+	if err != nil {
+		t.Fatalf("newRPCAuthenticator: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		user    string
+		allowed []string
+		denied  []string
+	}{
+		{
+			name:   "admin with empty whitelist",
+			header: basicAuth("admin", "adminpass"),
+			user:   "admin",
+			denied: []string{"getblockcount", "stop"},
+		},
+		{
+			name:    "limited user with whitelist",
+			header:  basicAuth("limit", "limitpass"),
+			user:    "limit",
+			allowed: []string{"getblockcount"},
+			denied:  []string{"notifyblocks", "stop"},
+		},
+		{
+			name:    "rpcauth user with intersected whitelists",
+			header:  basicAuth("alice", "alicepass"),
+			user:    "alice",
+			allowed: []string{"getblock", "notifyblocks"},
+			denied:  []string{"getblockcount", "stop"},
+		},
+		{
+			name:    "rpcauth user without whitelist",
+			header:  basicAuth("bob", "bobpass"),
+			user:    "bob",
+			allowed: []string{"getblockcount", "stop"},
+		},
+		{
+			name:   "wrong password",
+			header: basicAuth("alice", "bobpass"),
+		},
+		{
+			name:   "password of another user",
+			header: basicAuth("bob", "alicepass"),
+		},
+		{
+			name:   "malformed header",
+			header: "Basic !!!",
+		},
+	}
+
+	for _, test := range tests {
+		user := auth.authenticate(test.header)
+		if test.user == "" {
+			if user != nil {
+				t.Errorf("%s: unexpectedly authenticated as %q",
+					test.name, user.name)
+			}
+			continue
+		}
+		if user == nil || user.name != test.user {
+			t.Errorf("%s: unexpected user: got %v, want %q",
+				test.name, user, test.user)
+			continue
+		}
+		for _, method := range test.allowed {
+			if err := user.checkMethod(method); err != nil {
+				t.Errorf("%s: %s unexpectedly denied: %v",
+					test.name, method, err)
+			}
+		}
+		for _, method := range test.denied {
+			if user.checkMethod(method) == nil {
+				t.Errorf("%s: %s unexpectedly allowed", test.name,
+					method)
+			}
+		}
+	}
+
+	// A whitelist for an unknown user is an error.
+	c.RPCWhitelist = []string{"carol:getblockcount"}
+	if _, err := newRPCAuthenticator(c); err == nil {
+		t.Error("newRPCAuthenticator: expected error for whitelist of " +
+			"unknown user")
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/websocket"
+)
+
+// batchTestConfig returns the configuration used by the batch request tests.
+// It must be installed as the global configuration since the batch handling
+// consults it for the maximum batch size and RPC quirks.
+func batchTestConfig() *config {
+	return &config{RPCMaxBatchSize: 3, RPCMaxConcurrentReqs: 1}
+}
+
+// parseBatchReply unmarshals the passed reply to a batch request.
+func parseBatchReply(t *testing.T, reply []byte) []btcjson.Response {
+	t.Helper()
+
+	var responses []btcjson.Response
+	if err := json.Unmarshal(reply, &responses); err != nil {
+		t.Fatalf("unexpected batch reply %q: %v", reply, err)
+	}
+	return responses
+}
+
+// parseErrorReply unmarshals the passed single reply and returns its error.
+func parseErrorReply(t *testing.T, reply []byte) *btcjson.RPCError {
+	t.Helper()
+
+	var response btcjson.Response
+	if err := json.Unmarshal(reply, &response); err != nil {
+		t.Fatalf("unexpected reply %q: %v", reply, err)
+	}
+	if response.Error == nil {
+		t.Fatalf("reply %q does not contain an error", reply)
+	}
+	return response.Error
+}
+
+// TestProcessBatchRequest ensures batch requests from HTTP POST clients are
+// executed in order, that malformed batches and elements are rejected, and
+// that the authorization of the user applies to each request of the batch.
+func TestProcessBatchRequest(t *testing.T) {
+	oldCfg := cfg
+	cfg = batchTestConfig()
+	defer func() { cfg = oldCfg }()
+
+	s := &rpcServer{cfg: rpcserverConfig{StartupTime: time.Now().Unix()}}
+	admin := &rpcAuthUser{name: "admin"}
+	limited := &rpcAuthUser{
+		name:    "limit",
+		limited: true,
+		methods: map[string]struct{}{"uptime": {}},
+	}
+	whitelisted := &rpcAuthUser{
+		name:    "alice",
+		methods: map[string]struct{}{"getblockcount": {}},
+	}
+
+	// Batches which are rejected as a whole are answered with a single
+	// error reply.
+	wholeTests := []struct {
+		name string
+		body string
+		code btcjson.RPCErrorCode
+	}{
+		{
+			name: "empty batch",
+			body: `[]`,
+			code: btcjson.ErrRPCInvalidRequest.Code,
+		},
+		{
+			name: "batch over the size limit",
+			body: `[{"method":"uptime","params":[],"id":1},` +
+				`{"method":"uptime","params":[],"id":2},` +
+				`{"method":"uptime","params":[],"id":3},` +
+				`{"method":"uptime","params":[],"id":4}]`,
+			code: btcjson.ErrRPCInvalidRequest.Code,
+		},
+		{
+			name: "malformed batch",
+			body: `[{"method":"uptime","params":[],"id":1}`,
+			code: btcjson.ErrRPCParse.Code,
+		},
+	}
+	for _, test := range wholeTests {
+		reply := s.processBatchRequest([]byte(test.body), admin, nil)
+		if jsonErr := parseErrorReply(t, reply); jsonErr.Code != test.code {
+			t.Errorf("%s: unexpected error code -- got %d, want %d",
+				test.name, jsonErr.Code, test.code)
+		}
+	}
+
+	// A malformed element is answered with an error in its place while
+	// the remaining elements are executed.
+	body := `[{"method":"uptime","params":[],"id":1},"bogus",` +
+		`{"method":"uptime","params":[],"id":3}]`
+	responses := parseBatchReply(t, s.processBatchRequest([]byte(body),
+		admin, nil))
+	if len(responses) != 3 {
+		t.Fatalf("mixed batch: unexpected number of replies %d",
+			len(responses))
+	}
+	for i, id := range []float64{1, 3} {
+		response := responses[i*2]
+		if response.Error != nil || response.ID == nil ||
+			*response.ID != id {
+
+			t.Errorf("mixed batch: unexpected reply %d: %+v", i*2,
+				response)
+		}
+	}
+	if responses[1].Error == nil ||
+		responses[1].Error.Code != btcjson.ErrRPCInvalidRequest.Code {
+
+		t.Errorf("mixed batch: unexpected reply for malformed element: "+
+			"%+v", responses[1])
+	}
+
+	// A batch which only consists of notifications is not replied to.
+	body = `[{"method":"uptime","params":[],"id":null},` +
+		`{"jsonrpc":"2.0","method":"uptime","params":[]}]`
+	if reply := s.processBatchRequest([]byte(body), admin, nil); reply != nil {
+		t.Errorf("notification batch: unexpected reply %q", reply)
+	}
+
+	// Requests the user is not authorized to invoke are rejected without
+	// affecting the other requests of the batch.
+	authTests := []struct {
+		name    string
+		user    *rpcAuthUser
+		message string
+	}{
+		{
+			name:    "limited user",
+			user:    limited,
+			message: "limited user not authorized for this method",
+		},
+		{
+			name:    "whitelisted user",
+			user:    whitelisted,
+			message: "user not authorized for this method",
+		},
+	}
+	body = `[{"method":"uptime","params":[],"id":1},` +
+		`{"method":"stop","params":[],"id":2}]`
+	for _, test := range authTests {
+		responses := parseBatchReply(t, s.processBatchRequest(
+			[]byte(body), test.user, nil))
+		if len(responses) != 2 {
+			t.Errorf("%s: unexpected number of replies %d",
+				test.name, len(responses))
+			continue
+		}
+		for i, response := range responses {
+			allowed := test.user.allowed([]string{"uptime",
+				"stop"}[i])
+			if allowed && response.Error != nil {
+				t.Errorf("%s: request %d unexpectedly denied: %v",
+					test.name, i, response.Error)
+			}
+			if !allowed && (response.Error == nil ||
+				response.Error.Message != test.message) {
+
+				t.Errorf("%s: request %d unexpectedly allowed: "+
+					"%+v", test.name, i, response)
+			}
+		}
+	}
+}
+
+// TestWebsocketUnauthenticatedBatch ensures a websocket client which sends a
+// batch request before authenticating is disconnected without a reply.
+func TestWebsocketUnauthenticatedBatch(t *testing.T) {
+	oldCfg := cfg
+	cfg = batchTestConfig()
+	defer func() { cfg = oldCfg }()
+
+	// The rejected batch is logged, so disable the logger since the log
+	// rotator is not initialized by the tests.
+	oldLog := rpcsLog
+	rpcsLog = btclog.Disabled
+	defer func() { rpcsLog = oldLog }()
+
+	s := &rpcServer{cfg: rpcserverConfig{StartupTime: time.Now().Unix()}}
+	done := make(chan struct{})
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		ws, err := websocket.Upgrade(w, r, nil, 0, 0)
+		if err != nil {
+			t.Errorf("Upgrade: unexpected error: %v", err)
+			close(done)
+			return
+		}
+		c, err := newWebsocketClient(s, ws, r.RemoteAddr, nil)
+		if err != nil {
+			t.Errorf("newWebsocketClient: unexpected error: %v", err)
+			ws.Close()
+			close(done)
+			return
+		}
+		c.wg.Add(1)
+		c.inHandler()
+		close(done)
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer conn.Close()
+
+	batch := `[{"method":"uptime","params":[],"id":1}]`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(batch)); err != nil {
+		t.Fatalf("WriteMessage: unexpected error: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("unauthenticated client was not disconnected")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, msg, err := conn.ReadMessage(); err == nil {
+		t.Fatalf("unexpected reply %q to unauthenticated batch", msg)
+	}
+}
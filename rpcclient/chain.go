@@ -724,6 +724,45 @@ func (c *Client) GetMempoolEntry(txHash string) (*btcjson.GetMempoolEntryResult,
 	return c.GetMempoolEntryAsync(txHash).Receive()
 }
 
+// FutureGetMempoolInfoResult is a future promise to deliver the result of a
+// GetMempoolInfoAsync RPC invocation (or an applicable error).
+type FutureGetMempoolInfoResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about the state of the memory pool.
+func (r FutureGetMempoolInfoResult) Receive() (*btcjson.GetMempoolInfoResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a mempool info result object.
+	var mempoolInfo btcjson.GetMempoolInfoResult
+	err = json.Unmarshal(res, &mempoolInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mempoolInfo, nil
+}
+
+// GetMempoolInfoAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See GetMempoolInfo for the blocking version and more details.
+func (c *Client) GetMempoolInfoAsync() FutureGetMempoolInfoResult {
+	cmd := btcjson.NewGetMempoolInfoCmd()
+	return c.sendCmd(cmd)
+}
+
+// GetMempoolInfo returns information about the state of the memory pool such
+// as its size and the minimum fee rate required for transactions to be
+// accepted.
+func (c *Client) GetMempoolInfo() (*btcjson.GetMempoolInfoResult, error) {
+	return c.GetMempoolInfoAsync().Receive()
+}
+
 // FutureGetRawMempoolResult is a future promise to deliver the result of a
 // GetRawMempoolAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolResult chan *response
@@ -774,6 +813,48 @@ func (c *Client) GetRawMempool() ([]*chainhash.Hash, error) {
 	return c.GetRawMempoolAsync().Receive()
 }
 
+// FutureGetRawMempoolSequenceResult is a future promise to deliver the result
+// of a GetRawMempoolSequenceAsync RPC invocation (or an applicable error).
+type FutureGetRawMempoolSequenceResult chan *response
+
+// Receive waits for the response promised by the future and returns the hashes
+// of all transactions in the memory pool along with the mempool sequence number
+// the hashes correspond to.
+func (r FutureGetRawMempoolSequenceResult) Receive() (*btcjson.GetRawMempoolSequenceResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var result btcjson.GetRawMempoolSequenceResult
+	err = json.Unmarshal(res, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetRawMempoolSequenceAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetRawMempoolSequence for the blocking version and more details.
+func (c *Client) GetRawMempoolSequenceAsync() FutureGetRawMempoolSequenceResult {
+	cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(false))
+	cmd.MempoolSequence = btcjson.Bool(true)
+	return c.sendCmd(cmd)
+}
+
+// GetRawMempoolSequence returns the hashes of all transactions in the memory
+// pool along with the mempool sequence number they correspond to.  It is
+// intended to be combined with mempoolsequence notifications, which carry the
+// same sequence number, to maintain a gap-free view of the memory pool.
+//
+// NOTE: This is a btcd extension ported from Bitcoin Core.
+func (c *Client) GetRawMempoolSequence() (*btcjson.GetRawMempoolSequenceResult, error) {
+	return c.GetRawMempoolSequenceAsync().Receive()
+}
+
 // FutureGetRawMempoolVerboseResult is a future promise to deliver the result of
 // a GetRawMempoolVerboseAsync RPC invocation (or an applicable error).
 type FutureGetRawMempoolVerboseResult chan *response
@@ -1064,6 +1145,121 @@ func (c *Client) GetTxOutSetInfo() (*btcjson.GetTxOutSetInfoResult, error) {
 	return c.GetTxOutSetInfoAsync().Receive()
 }
 
+// FutureScanTxOutSetResult is a future promise to deliver the result of a
+// ScanTxOutSetAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// unspent transaction outputs found by the scan.
+func (r FutureScanTxOutSetResult) Receive() (*btcjson.ScanTxOutSetResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a scantxoutset result object.
+	var scanResult btcjson.ScanTxOutSetResult
+	err = json.Unmarshal(res, &scanResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scanResult, nil
+}
+
+// ScanTxOutSetAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on the
+// returned instance.
+//
+// See ScanTxOutSet for the blocking version and more details.
+func (c *Client) ScanTxOutSetAsync(scanObjects []btcjson.ScanObject) FutureScanTxOutSetResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.STOStart, &scanObjects)
+	return c.sendCmd(cmd)
+}
+
+// ScanTxOutSet scans the unspent transaction output set for outputs matching
+// the passed output descriptors and returns them once the scan is done.  The
+// Success field of the result is false when the scan was aborted.
+func (c *Client) ScanTxOutSet(scanObjects []btcjson.ScanObject) (*btcjson.ScanTxOutSetResult, error) {
+	return c.ScanTxOutSetAsync(scanObjects).Receive()
+}
+
+// FutureScanTxOutSetStatusResult is a future promise to deliver the result of
+// a ScanTxOutSetStatusAsync RPC invocation (or an applicable error).
+type FutureScanTxOutSetStatusResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// progress of the scan in progress, or nil when there is no scan in progress.
+func (r FutureScanTxOutSetStatusResult) Receive() (*btcjson.ScanTxOutSetStatusResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a scantxoutset status result object, which is
+	// null when there is no scan in progress.
+	var status *btcjson.ScanTxOutSetStatusResult
+	err = json.Unmarshal(res, &status)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// ScanTxOutSetStatusAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See ScanTxOutSetStatus for the blocking version and more details.
+func (c *Client) ScanTxOutSetStatusAsync() FutureScanTxOutSetStatusResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.STOStatus, nil)
+	return c.sendCmd(cmd)
+}
+
+// ScanTxOutSetStatus returns the progress of the unspent transaction output
+// set scan in progress, or nil when there is no scan in progress.
+func (c *Client) ScanTxOutSetStatus() (*btcjson.ScanTxOutSetStatusResult, error) {
+	return c.ScanTxOutSetStatusAsync().Receive()
+}
+
+// FutureAbortScanTxOutSetResult is a future promise to deliver the result of
+// an AbortScanTxOutSetAsync RPC invocation (or an applicable error).
+type FutureAbortScanTxOutSetResult chan *response
+
+// Receive waits for the response promised by the future and returns whether a
+// scan in progress was aborted.
+func (r FutureAbortScanTxOutSetResult) Receive() (bool, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return false, err
+	}
+
+	// Unmarshal the result as a boolean.
+	var aborted bool
+	err = json.Unmarshal(res, &aborted)
+	if err != nil {
+		return false, err
+	}
+	return aborted, nil
+}
+
+// AbortScanTxOutSetAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AbortScanTxOutSet for the blocking version and more details.
+func (c *Client) AbortScanTxOutSetAsync() FutureAbortScanTxOutSetResult {
+	cmd := btcjson.NewScanTxOutSetCmd(btcjson.STOAbort, nil)
+	return c.sendCmd(cmd)
+}
+
+// AbortScanTxOutSet aborts the unspent transaction output set scan in progress
+// and returns whether there was a scan to abort.
+func (c *Client) AbortScanTxOutSet() (bool, error) {
+	return c.AbortScanTxOutSetAsync().Receive()
+}
+
 // FutureRescanBlocksResult is a future promise to deliver the result of a
 // RescanBlocksAsync RPC invocation (or an applicable error).
 //
@@ -66,6 +66,51 @@ func (c *Client) DebugLevel(levelSpec string) (string, error) {
 	return c.DebugLevelAsync(levelSpec).Receive()
 }
 
+// FutureLoggingResult is a future promise to deliver the result of a
+// LoggingAsync RPC invocation (or an applicable error).
+type FutureLoggingResult chan *response
+
+// Receive waits for the response promised by the future and returns the log
+// level of each subsystem.
+func (r FutureLoggingResult) Receive() (map[string]string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a map of subsystems to log levels.
+	var levels map[string]string
+	err = json.Unmarshal(res, &levels)
+	if err != nil {
+		return nil, err
+	}
+	return levels, nil
+}
+
+// LoggingAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See Logging for the blocking version and more details.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) LoggingAsync(levels map[string]string) FutureLoggingResult {
+	var levelsPtr *map[string]string
+	if len(levels) != 0 {
+		levelsPtr = &levels
+	}
+	cmd := btcjson.NewLoggingCmd(levelsPtr)
+	return c.sendCmd(cmd)
+}
+
+// Logging sets the log level of each subsystem in the passed map, which may be
+// nil, and returns the resulting log level of every subsystem.
+//
+// NOTE: This is a btcd extension.
+func (c *Client) Logging(levels map[string]string) (map[string]string, error) {
+	return c.LoggingAsync(levels).Receive()
+}
+
 // FutureCreateEncryptedWalletResult is a future promise to deliver the error
 // result of a CreateEncryptedWalletAsync RPC invocation.
 type FutureCreateEncryptedWalletResult chan *response
@@ -7,6 +7,7 @@ package rpcclient
 import (
 	"bytes"
 	"container/list"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -72,6 +73,11 @@ var (
 	// client having already connected to the RPC server.
 	ErrClientAlreadyConnected = errors.New("websocket client has already " +
 		"connected")
+
+	// ErrNotBatchClient is an error to describe the condition of calling
+	// Send on a client that was not created with NewBatch.
+	ErrNotBatchClient = errors.New("client is not configured for batch " +
+		"requests")
 )
 
 const (
@@ -167,6 +173,11 @@ type Client struct {
 	// reconnect to the RPC server.
 	retryCount int64
 
+	// batch indicates the client queues requests until Send is called
+	// instead of sending them immediately.  The queued requests are
+	// tracked by the request map and list.
+	batch bool
+
 	// Track command and their response channels by ID.
 	requestLock sync.Mutex
 	requestMap  map[uint64]*list.Element
@@ -277,6 +288,9 @@ func (c *Client) trackRegisteredNtfns(cmd interface{}) {
 
 		}
 
+	case *btcjson.NotifyMempoolSequenceCmd:
+		c.ntfnState.notifySequence = true
+
 	case *btcjson.NotifySpentCmd:
 		for _, op := range bcmd.OutPoints {
 			c.ntfnState.notifySpent[op] = struct{}{}
@@ -549,6 +563,14 @@ func (c *Client) reregisterNtfns() error {
 		}
 	}
 
+	// Reregister notifymempoolsequence if needed.
+	if stateCopy.notifySequence {
+		log.Debugf("Reregistering [notifymempoolsequence]")
+		if err := c.NotifyMempoolSequence(); err != nil {
+			return err
+		}
+	}
+
 	// Reregister the combination of all previously registered notifyspent
 	// outpoints in one command if needed.
 	nslen := len(stateCopy.notifySpent)
@@ -830,34 +852,47 @@ func receiveFuture(f chan *response) ([]byte, error) {
 	return r.result, r.err
 }
 
-// sendPost sends the passed request to the server by issuing an HTTP POST
-// request using the provided response channel for the reply.  Typically a new
-// connection is opened and closed for each command when using this method,
-// however, the underlying HTTP client might coalesce multiple commands
-// depending on several factors including the remote server configuration.
-func (c *Client) sendPost(jReq *jsonRequest) {
+// newPostRequest returns an HTTP POST request to the configured RPC server
+// with the passed body and the basic access authorization set.
+func (c *Client) newPostRequest(body []byte) (*http.Request, error) {
 	// Generate a request to the configured RPC server.
 	protocol := "http"
-	if !c.config.DisableTLS {
+	if c.config.useTLS() {
 		protocol = "https"
 	}
-	url := protocol + "://" + c.config.Host
-	bodyReader := bytes.NewReader(jReq.marshalledJSON)
+	url := protocol + "://" + c.config.urlHost()
+	bodyReader := bytes.NewReader(body)
 	httpReq, err := http.NewRequest("POST", url, bodyReader)
 	if err != nil {
-		jReq.responseChan <- &response{result: nil, err: err}
-		return
+		return nil, err
 	}
 	httpReq.Close = true
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	// Configure basic access authorization.
+	// Configure basic access authorization unless connecting to a Unix
+	// domain socket without credentials.
+	if c.config.skipAuth() {
+		return httpReq, nil
+	}
 	user, pass, err := c.config.getAuth()
+	if err != nil {
+		return nil, err
+	}
+	httpReq.SetBasicAuth(user, pass)
+	return httpReq, nil
+}
+
+// sendPost sends the passed request to the server by issuing an HTTP POST
+// request using the provided response channel for the reply.  Typically a new
+// connection is opened and closed for each command when using this method,
+// however, the underlying HTTP client might coalesce multiple commands
+// depending on several factors including the remote server configuration.
+func (c *Client) sendPost(jReq *jsonRequest) {
+	httpReq, err := c.newPostRequest(jReq.marshalledJSON)
 	if err != nil {
 		jReq.responseChan <- &response{result: nil, err: err}
 		return
 	}
-	httpReq.SetBasicAuth(user, pass)
 
 	log.Tracef("Sending command [%s] with id %d", jReq.method, jReq.id)
 	c.sendPostRequest(httpReq, jReq)
@@ -871,6 +906,13 @@ func (c *Client) sendRequest(jReq *jsonRequest) {
 	// the client running in HTTP POST mode or not.  When running in HTTP
 	// POST mode, the command is issued via an HTTP client.  Otherwise,
 	// the command is issued via the asynchronous websocket channels.
+	// Batch clients queue the request until Send is called.
+	if c.batch {
+		if err := c.addRequest(jReq); err != nil {
+			jReq.responseChan <- &response{err: err}
+		}
+		return
+	}
 	if c.config.HTTPPostMode {
 		c.sendPost(jReq)
 		return
@@ -1089,7 +1131,9 @@ func (c *Client) WaitForShutdown() {
 // This
 type ConnConfig struct {
 	// Host is the IP address and port of the RPC server you want to connect
-	// to.
+	// to.  It may also be the path of a Unix domain socket the RPC server
+	// listens on prefixed with "unix:", such as "unix:/path/to/socket",
+	// in which case TLS is not used.
 	Host string
 
 	// Endpoint is the websocket endpoint on the RPC server.  This is
@@ -1166,6 +1210,44 @@ type ConnConfig struct {
 	EnableBCInfoHacks bool
 }
 
+// unixSocketPrefix is the prefix of hosts which specify the path of a Unix
+// domain socket instead of an IP address and port.
+const unixSocketPrefix = "unix:"
+
+// unixSocketPath returns the path of the Unix domain socket specified by the
+// host and whether the host specifies one.
+func (config *ConnConfig) unixSocketPath() (string, bool) {
+	if !strings.HasPrefix(config.Host, unixSocketPrefix) {
+		return "", false
+	}
+	return config.Host[len(unixSocketPrefix):], true
+}
+
+// useTLS returns whether the connection uses TLS, which is never the case for
+// Unix domain sockets.
+func (config *ConnConfig) useTLS() bool {
+	_, isUnix := config.unixSocketPath()
+	return !config.DisableTLS && !isUnix
+}
+
+// urlHost returns the host to use in the URLs of requests.  Requests over Unix
+// domain sockets use localhost since the path is not a valid host.
+func (config *ConnConfig) urlHost() string {
+	if _, isUnix := config.unixSocketPath(); isUnix {
+		return "localhost"
+	}
+	return config.Host
+}
+
+// skipAuth returns whether requests are sent without basic access
+// authorization, which is the case when connecting to a Unix domain socket
+// without a passphrase or cookie file.  The RPC server may be configured to
+// grant access to such clients.
+func (config *ConnConfig) skipAuth() bool {
+	_, isUnix := config.unixSocketPath()
+	return isUnix && config.Pass == "" && config.CookiePath == ""
+}
+
 // getAuth returns the username and passphrase that will actually be used for
 // this connection.  This will be the result of checking the cookie if a cookie
 // path is configured; if not, it will be the user-configured username and
@@ -1218,7 +1300,7 @@ func newHTTPClient(config *ConnConfig) (*http.Client, error) {
 
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
-	if !config.DisableTLS {
+	if config.useTLS() {
 		if len(config.Certificates) > 0 {
 			pool := x509.NewCertPool()
 			pool.AppendCertsFromPEM(config.Certificates)
@@ -1228,11 +1310,23 @@ func newHTTPClient(config *ConnConfig) (*http.Client, error) {
 		}
 	}
 
+	transport := &http.Transport{
+		Proxy:           proxyFunc,
+		TLSClientConfig: tlsConfig,
+	}
+
+	// Connect to the Unix domain socket regardless of the address of the
+	// request if one is configured.
+	if path, isUnix := config.unixSocketPath(); isUnix {
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", path)
+		}
+	}
+
 	client := http.Client{
-		Transport: &http.Transport{
-			Proxy:           proxyFunc,
-			TLSClientConfig: tlsConfig,
-		},
+		Transport: transport,
 	}
 
 	return &client, nil
@@ -1244,7 +1338,7 @@ func dial(config *ConnConfig) (*websocket.Conn, error) {
 	// Setup TLS if not disabled.
 	var tlsConfig *tls.Config
 	var scheme = "ws"
-	if !config.DisableTLS {
+	if config.useTLS() {
 		tlsConfig = &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		}
@@ -1270,19 +1364,30 @@ func dial(config *ConnConfig) (*websocket.Conn, error) {
 		dialer.NetDial = proxy.Dial
 	}
 
-	// The RPC server requires basic authorization, so create a custom
-	// request header with the Authorization header set.
-	user, pass, err := config.getAuth()
-	if err != nil {
-		return nil, err
+	// Connect to the Unix domain socket if one is configured.
+	if path, isUnix := config.unixSocketPath(); isUnix {
+		dialer.NetDial = func(_, _ string) (net.Conn, error) {
+			return net.Dial("unix", path)
+		}
 	}
-	login := user + ":" + pass
-	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+
+	// The RPC server requires basic authorization, so create a custom
+	// request header with the Authorization header set unless connecting
+	// to a Unix domain socket without credentials.
 	requestHeader := make(http.Header)
-	requestHeader.Add("Authorization", auth)
+	if !config.skipAuth() {
+		user, pass, err := config.getAuth()
+		if err != nil {
+			return nil, err
+		}
+		login := user + ":" + pass
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+		requestHeader.Add("Authorization", auth)
+	}
 
 	// Dial the connection.
-	url := fmt.Sprintf("%s://%s/%s", scheme, config.Host, config.Endpoint)
+	url := fmt.Sprintf("%s://%s/%s", scheme, config.urlHost(),
+		config.Endpoint)
 	wsConn, resp, err := dialer.Dial(url, requestHeader)
 	if err != nil {
 		if err != websocket.ErrBadHandshake || resp == nil {
@@ -1387,6 +1492,142 @@ func New(config *ConnConfig, ntfnHandlers *NotificationHandlers) (*Client, error
 	return client, nil
 }
 
+// NewBatch creates a new RPC client that combines requests into JSON-RPC batch
+// requests based on the provided connection configuration details.  The client
+// always runs in HTTP POST mode.
+//
+// Invoking the async version of a command on a batch client only queues the
+// request.  All queued requests are sent to the server in a single HTTP POST
+// request when Send is called, after which the Receive method of the returned
+// futures delivers the individual results.  The synchronous versions of the
+// commands must not be used with a batch client since they would block until
+// Send is called.
+func NewBatch(config *ConnConfig) (*Client, error) {
+	// The batch requests are sent with HTTP POST requests.
+	batchConfig := *config
+	batchConfig.HTTPPostMode = true
+	client, err := New(&batchConfig, nil)
+	if err != nil {
+		return nil, err
+	}
+	client.batch = true
+	return client, nil
+}
+
+// batchResponse is a partially-unmarshaled element of the reply to a JSON-RPC
+// batch request.
+type batchResponse struct {
+	ID *float64 `json:"id"`
+	rawResponse
+}
+
+// Send sends all requests queued by a client created with NewBatch to the
+// server in a single JSON-RPC batch request and delivers the replies to the
+// futures returned when the requests were queued.  Requests queued while the
+// batch is being sent are part of the next batch.
+//
+// An error is returned when the batch as a whole fails, such as when the
+// server can't be reached or rejects the batch, in which case the error is
+// also delivered to each of the futures.  Errors for individual requests are
+// only returned by the Receive method of the associated future.
+func (c *Client) Send() error {
+	if !c.batch {
+		return ErrNotBatchClient
+	}
+
+	// Take the queued requests so requests queued from now on become part
+	// of the next batch.
+	c.requestLock.Lock()
+	requests := make(map[uint64]*jsonRequest, c.requestList.Len())
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for e := c.requestList.Front(); e != nil; e = e.Next() {
+		jReq := e.Value.(*jsonRequest)
+		if len(requests) > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(jReq.marshalledJSON)
+		requests[jReq.id] = jReq
+	}
+	body.WriteByte(']')
+	c.removeAllRequests()
+	c.requestLock.Unlock()
+
+	if len(requests) == 0 {
+		return nil
+	}
+
+	// failAll delivers the passed error to all requests of the batch and
+	// returns it.
+	failAll := func(err error) error {
+		for _, jReq := range requests {
+			jReq.responseChan <- &response{err: err}
+		}
+		return err
+	}
+
+	httpReq, err := c.newPostRequest(body.Bytes())
+	if err != nil {
+		return failAll(err)
+	}
+	log.Tracef("Sending batch of %d commands", len(requests))
+	httpResponse, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return failAll(err)
+	}
+
+	// Read the raw bytes and close the response.
+	respBytes, err := ioutil.ReadAll(httpResponse.Body)
+	httpResponse.Body.Close()
+	if err != nil {
+		return failAll(fmt.Errorf("error reading json reply: %v", err))
+	}
+
+	// Try to unmarshal the response as an array of JSON-RPC responses.
+	// The server replies with a single response when it rejects the batch
+	// as a whole.
+	var responses []batchResponse
+	if err := json.Unmarshal(respBytes, &responses); err != nil {
+		var resp rawResponse
+		if json.Unmarshal(respBytes, &resp) == nil && resp.Error != nil {
+			return failAll(resp.Error)
+		}
+
+		// When the response itself isn't a valid JSON-RPC response
+		// return an error which includes the HTTP status code and raw
+		// response bytes.
+		return failAll(fmt.Errorf("status code: %d, response: %q",
+			httpResponse.StatusCode, string(respBytes)))
+	}
+
+	// Deliver the responses to the associated requests.
+	for i := range responses {
+		resp := &responses[i]
+		if resp.ID == nil || *resp.ID < 0 || *resp.ID != math.Trunc(*resp.ID) {
+			log.Warnf("Malformed batch response: invalid identifier")
+			continue
+		}
+		id := uint64(*resp.ID)
+		jReq, ok := requests[id]
+		if !ok {
+			log.Warnf("Received unexpected batch reply: %s (id %d)",
+				resp.Result, id)
+			continue
+		}
+		delete(requests, id)
+
+		result, err := resp.result()
+		jReq.responseChan <- &response{result: result, err: err}
+	}
+
+	// Fail any requests the server did not respond to.
+	for id, jReq := range requests {
+		err := fmt.Errorf("no reply to request with id %d in batch", id)
+		jReq.responseChan <- &response{err: err}
+	}
+	return nil
+}
+
 // Connect establishes the initial websocket connection.  This is necessary when
 // a client was created after setting the DisableConnectOnNew field of the
 // Config struct.
@@ -0,0 +1,182 @@
+// Copyright (c) 2014-2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpcclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// TestBatchSend ensures a batch client sends all queued requests in a single
+// batch request and delivers the replies to the associated futures regardless
+// of their order.
+func TestBatchSend(t *testing.T) {
+	t.Parallel()
+
+	var numPosts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		numPosts++
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unable to read request: %v", err)
+			return
+		}
+		var requests []btcjson.Request
+		if err := json.Unmarshal(body, &requests); err != nil {
+			t.Errorf("unable to unmarshal batch request: %v", err)
+			return
+		}
+
+		// Reply in reverse order, with an error for getdifficulty and
+		// without a reply for getbestblockhash.
+		var replies []string
+		for i := len(requests) - 1; i >= 0; i-- {
+			req := requests[i]
+			switch req.Method {
+			case "getblockcount":
+				replies = append(replies, fmt.Sprintf(
+					`{"result":100,"error":null,"id":%v}`,
+					req.ID))
+			case "getdifficulty":
+				replies = append(replies, fmt.Sprintf(
+					`{"result":null,"error":{"code":-1,`+
+						`"message":"fail"},"id":%v}`, req.ID))
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(replies, ","))
+	}))
+	defer server.Close()
+
+	client, err := NewBatch(&ConnConfig{
+		Host:       strings.TrimPrefix(server.URL, "http://"),
+		User:       "user",
+		Pass:       "pass",
+		DisableTLS: true,
+	})
+	if err != nil {
+		t.Fatalf("unable to create batch client: %v", err)
+	}
+	defer client.Shutdown()
+
+	// Sending an empty batch is a no-op.
+	if err := client.Send(); err != nil {
+		t.Fatalf("Send: unexpected error for empty batch: %v", err)
+	}
+
+	blockCount := client.GetBlockCountAsync()
+	difficulty := client.GetDifficultyAsync()
+	bestHash := client.GetBestBlockHashAsync()
+	if err := client.Send(); err != nil {
+		t.Fatalf("Send: unexpected error: %v", err)
+	}
+	if numPosts != 1 {
+		t.Fatalf("unexpected number of posts: got %d, want 1", numPosts)
+	}
+
+	count, err := blockCount.Receive()
+	if err != nil || count != 100 {
+		t.Fatalf("unexpected getblockcount result: %v, %v", count, err)
+	}
+	if _, err := difficulty.Receive(); err == nil {
+		t.Fatal("getdifficulty: expected error")
+	} else if rpcErr, ok := err.(*btcjson.RPCError); !ok || rpcErr.Code != -1 {
+		t.Fatalf("getdifficulty: unexpected error: %v", err)
+	}
+	if _, err := bestHash.Receive(); err == nil {
+		t.Fatal("getbestblockhash: expected error for missing reply")
+	}
+
+	// Calling Send on a client not created with NewBatch is an error.
+	postClient, err := New(&ConnConfig{
+		Host:         "127.0.0.1:1",
+		HTTPPostMode: true,
+		DisableTLS:   true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	defer postClient.Shutdown()
+	if err := postClient.Send(); err != ErrNotBatchClient {
+		t.Fatalf("Send: unexpected error: got %v, want %v", err,
+			ErrNotBatchClient)
+	}
+}
+
+// TestUnixSocket ensures a client configured with the path of a Unix domain
+// socket connects to the socket without TLS and only sends credentials when
+// configured with them.
+func TestUnixSocket(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "rpcclienttest")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rpc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unable to listen on unix socket: %v", err)
+	}
+
+	authHeaders := make(chan string, 2)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+
+		authHeaders <- r.Header.Get("Authorization")
+		var request btcjson.Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("unable to unmarshal request: %v", err)
+			return
+		}
+		fmt.Fprintf(w, `{"result":100,"error":null,"id":%v}`, request.ID)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		user     string
+		pass     string
+		wantAuth bool
+	}{
+		{"without credentials", "", "", false},
+		{"with credentials", "user", "pass", true},
+	}
+	for _, test := range tests {
+		client, err := New(&ConnConfig{
+			Host:         "unix:" + path,
+			User:         test.user,
+			Pass:         test.pass,
+			HTTPPostMode: true,
+		}, nil)
+		if err != nil {
+			t.Fatalf("%s: unable to create client: %v", test.name, err)
+		}
+		count, err := client.GetBlockCount()
+		client.Shutdown()
+		if err != nil || count != 100 {
+			t.Fatalf("%s: unexpected getblockcount result: %v, %v",
+				test.name, count, err)
+		}
+		if auth := <-authHeaders; (auth != "") != test.wantAuth {
+			t.Fatalf("%s: unexpected authorization header %q",
+				test.name, auth)
+		}
+	}
+}
@@ -35,6 +35,7 @@ type notificationState struct {
 	notifyBlocks       bool
 	notifyNewTx        bool
 	notifyNewTxVerbose bool
+	notifySequence     bool
 	notifyReceived     map[string]struct{}
 	notifySpent        map[btcjson.OutPoint]struct{}
 }
@@ -45,6 +46,7 @@ func (s *notificationState) Copy() *notificationState {
 	stateCopy.notifyBlocks = s.notifyBlocks
 	stateCopy.notifyNewTx = s.notifyNewTx
 	stateCopy.notifyNewTxVerbose = s.notifyNewTxVerbose
+	stateCopy.notifySequence = s.notifySequence
 	stateCopy.notifyReceived = make(map[string]struct{})
 	for addr := range s.notifyReceived {
 		stateCopy.notifyReceived[addr] = struct{}{}
@@ -179,6 +181,14 @@ type NotificationHandlers struct {
 	// made to register for the notification and the function is non-nil.
 	OnTxAcceptedVerbose func(txDetails *btcjson.TxRawResult)
 
+	// OnMempoolSequence is invoked when a transaction is added to or
+	// removed from the memory pool and when a block is connected to or
+	// disconnected from the main chain, in the order the events happened.
+	// It will only be invoked if a preceding call to NotifyMempoolSequence
+	// has been made to register for the notification and the function is
+	// non-nil.
+	OnMempoolSequence func(event *btcjson.MempoolSequenceEvent)
+
 	// OnBtcdConnected is invoked when a wallet connects or disconnects from
 	// btcd.
 	//
@@ -409,6 +419,23 @@ func (c *Client) handleNotification(ntfn *rawNotification) {
 
 		c.ntfnHandlers.OnTxAcceptedVerbose(rawTx)
 
+	// OnMempoolSequence
+	case btcjson.MempoolSequenceNtfnMethod:
+		// Ignore the notification if the client is not interested in
+		// it.
+		if c.ntfnHandlers.OnMempoolSequence == nil {
+			return
+		}
+
+		event, err := parseMempoolSequenceNtfnParams(ntfn.Params)
+		if err != nil {
+			log.Warnf("Received invalid mempool sequence "+
+				"notification: %v", err)
+			return
+		}
+
+		c.ntfnHandlers.OnMempoolSequence(event)
+
 	// OnBtcdConnected
 	case btcjson.BtcdConnectedNtfnMethod:
 		// Ignore the notification if the client is not interested in
@@ -775,6 +802,25 @@ func parseTxAcceptedVerboseNtfnParams(params []json.RawMessage) (*btcjson.TxRawR
 	return &rawTx, nil
 }
 
+// parseMempoolSequenceNtfnParams parses out the event from the parameters of a
+// mempoolsequence notification.
+func parseMempoolSequenceNtfnParams(params []json.RawMessage) (*btcjson.MempoolSequenceEvent,
+	error) {
+
+	if len(params) != 1 {
+		return nil, wrongNumParams(len(params))
+	}
+
+	// Unmarshal first parameter as a mempool sequence event object.
+	var event btcjson.MempoolSequenceEvent
+	err := json.Unmarshal(params[0], &event)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
 // parseBtcdConnectedNtfnParams parses out the connection status of btcd
 // and btcwallet from the parameters of a btcdconnected notification.
 func parseBtcdConnectedNtfnParams(params []json.RawMessage) (bool, error) {
@@ -1037,6 +1083,57 @@ func (c *Client) NotifyNewTransactions(verbose bool) error {
 	return c.NotifyNewTransactionsAsync(verbose).Receive()
 }
 
+// FutureNotifyMempoolSequenceResult is a future promise to deliver the result
+// of a NotifyMempoolSequenceAsync RPC invocation (or an applicable error).
+type FutureNotifyMempoolSequenceResult chan *response
+
+// Receive waits for the response promised by the future and returns an error
+// if the registration was not successful.
+func (r FutureNotifyMempoolSequenceResult) Receive() error {
+	_, err := receiveFuture(r)
+	return err
+}
+
+// NotifyMempoolSequenceAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See NotifyMempoolSequence for the blocking version and more details.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyMempoolSequenceAsync() FutureNotifyMempoolSequenceResult {
+	// Not supported in HTTP POST mode.
+	if c.config.HTTPPostMode {
+		return newFutureError(ErrWebsocketsRequired)
+	}
+
+	// Ignore the notification if the client is not interested in
+	// notifications.
+	if c.ntfnHandlers == nil {
+		return newNilFutureResult()
+	}
+
+	cmd := btcjson.NewNotifyMempoolSequenceCmd()
+	return c.sendCmd(cmd)
+}
+
+// NotifyMempoolSequence registers the client to receive notifications every
+// time a transaction is added to or removed from the memory pool and every
+// time a block is connected to or disconnected from the main chain.  The
+// notifications are delivered to the notification handlers associated with the
+// client.  Calling this function has no effect if there are no notification
+// handlers and will result in an error if the client is configured to run in
+// HTTP POST mode.
+//
+// The notifications delivered as a result of this call will be via
+// OnMempoolSequence.  Combine them with GetRawMempoolSequence to maintain a
+// gap-free view of the memory pool.
+//
+// NOTE: This is a btcd extension and requires a websocket connection.
+func (c *Client) NotifyMempoolSequence() error {
+	return c.NotifyMempoolSequenceAsync().Receive()
+}
+
 // FutureNotifyReceivedResult is a future promise to deliver the result of a
 // NotifyReceivedAsync RPC invocation (or an applicable error).
 //
@@ -882,3 +882,176 @@ func (c *Client) DecodeScriptAsync(serializedScript []byte) FutureDecodeScriptRe
 func (c *Client) DecodeScript(serializedScript []byte) (*btcjson.DecodeScriptResult, error) {
 	return c.DecodeScriptAsync(serializedScript).Receive()
 }
+
+// FutureDecodePsbtResult is a future promise to deliver the result of a
+// DecodePsbtAsync RPC invocation (or an applicable error).
+type FutureDecodePsbtResult chan *response
+
+// Receive waits for the response promised by the future and returns
+// information about a partially signed transaction.
+func (r FutureDecodePsbtResult) Receive() (*btcjson.DecodePsbtResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a decodepsbt result object.
+	var decodePsbtResult btcjson.DecodePsbtResult
+	err = json.Unmarshal(res, &decodePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decodePsbtResult, nil
+}
+
+// DecodePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See DecodePsbt for the blocking version and more details.
+func (c *Client) DecodePsbtAsync(psbt string) FutureDecodePsbtResult {
+	cmd := btcjson.NewDecodePsbtCmd(psbt)
+	return c.sendCmd(cmd)
+}
+
+// DecodePsbt returns information about a partially signed transaction given
+// in base64.
+func (c *Client) DecodePsbt(psbt string) (*btcjson.DecodePsbtResult, error) {
+	return c.DecodePsbtAsync(psbt).Receive()
+}
+
+// FutureAnalyzePsbtResult is a future promise to deliver the result of an
+// AnalyzePsbtAsync RPC invocation (or an applicable error).
+type FutureAnalyzePsbtResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// analysis of a partially signed transaction.
+func (r FutureAnalyzePsbtResult) Receive() (*btcjson.AnalyzePsbtResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as an analyzepsbt result object.
+	var analyzePsbtResult btcjson.AnalyzePsbtResult
+	err = json.Unmarshal(res, &analyzePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &analyzePsbtResult, nil
+}
+
+// AnalyzePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See AnalyzePsbt for the blocking version and more details.
+func (c *Client) AnalyzePsbtAsync(psbt string) FutureAnalyzePsbtResult {
+	cmd := btcjson.NewAnalyzePsbtCmd(psbt)
+	return c.sendCmd(cmd)
+}
+
+// AnalyzePsbt returns what each input of a partially signed transaction given
+// in base64 is missing and which role has to act on it next.
+func (c *Client) AnalyzePsbt(psbt string) (*btcjson.AnalyzePsbtResult, error) {
+	return c.AnalyzePsbtAsync(psbt).Receive()
+}
+
+// FuturePsbtResult is a future promise to deliver the result of an RPC
+// invocation that returns a partially signed transaction, such as
+// CombinePsbtAsync and UtxoUpdatePsbtAsync (or an applicable error).
+type FuturePsbtResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// base64-encoded partially signed transaction.
+func (r FuturePsbtResult) Receive() (string, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return "", err
+	}
+
+	// Unmarshal result as a string.
+	var psbt string
+	err = json.Unmarshal(res, &psbt)
+	if err != nil {
+		return "", err
+	}
+
+	return psbt, nil
+}
+
+// CombinePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See CombinePsbt for the blocking version and more details.
+func (c *Client) CombinePsbtAsync(psbts []string) FuturePsbtResult {
+	cmd := btcjson.NewCombinePsbtCmd(psbts)
+	return c.sendCmd(cmd)
+}
+
+// CombinePsbt combines the passed base64-encoded partially signed
+// transactions for the same transaction into one.
+func (c *Client) CombinePsbt(psbts []string) (string, error) {
+	return c.CombinePsbtAsync(psbts).Receive()
+}
+
+// UtxoUpdatePsbtAsync returns an instance of a type that can be used to get
+// the result of the RPC at some future time by invoking the Receive function
+// on the returned instance.
+//
+// See UtxoUpdatePsbt for the blocking version and more details.
+func (c *Client) UtxoUpdatePsbtAsync(psbt string, descriptors *[]btcjson.ScanObject) FuturePsbtResult {
+	cmd := btcjson.NewUtxoUpdatePsbtCmd(psbt, descriptors)
+	return c.sendCmd(cmd)
+}
+
+// UtxoUpdatePsbt adds the outputs spent by the segwit inputs of the passed
+// base64-encoded partially signed transaction from the utxo set and the
+// memory pool of the server, along with the scripts and key derivations
+// described by the optional output descriptors.
+func (c *Client) UtxoUpdatePsbt(psbt string, descriptors *[]btcjson.ScanObject) (string, error) {
+	return c.UtxoUpdatePsbtAsync(psbt, descriptors).Receive()
+}
+
+// FutureFinalizePsbtResult is a future promise to deliver the result of a
+// FinalizePsbtAsync RPC invocation (or an applicable error).
+type FutureFinalizePsbtResult chan *response
+
+// Receive waits for the response promised by the future and returns the
+// finalized partially signed transaction or the extracted transaction.
+func (r FutureFinalizePsbtResult) Receive() (*btcjson.FinalizePsbtResult, error) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal result as a finalizepsbt result object.
+	var finalizePsbtResult btcjson.FinalizePsbtResult
+	err = json.Unmarshal(res, &finalizePsbtResult)
+	if err != nil {
+		return nil, err
+	}
+
+	return &finalizePsbtResult, nil
+}
+
+// FinalizePsbtAsync returns an instance of a type that can be used to get the
+// result of the RPC at some future time by invoking the Receive function on
+// the returned instance.
+//
+// See FinalizePsbt for the blocking version and more details.
+func (c *Client) FinalizePsbtAsync(psbt string, extract *bool) FutureFinalizePsbtResult {
+	cmd := btcjson.NewFinalizePsbtCmd(psbt, extract)
+	return c.sendCmd(cmd)
+}
+
+// FinalizePsbt finalizes the inputs of the passed base64-encoded partially
+// signed transaction that have enough signatures.  The signed transaction is
+// returned instead of the PSBT when all inputs are finalized, unless extract
+// is false.
+func (c *Client) FinalizePsbt(psbt string, extract *bool) (*btcjson.FinalizePsbtResult, error) {
+	return c.FinalizePsbtAsync(psbt, extract).Receive()
+}
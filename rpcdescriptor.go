@@ -0,0 +1,204 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+const (
+	// maxDescriptorRangeSize is the maximum number of indices of a ranged
+	// descriptor that may be derived by a single command.
+	maxDescriptorRangeSize = 1000000
+
+	// defaultDescriptorRangeEnd is the last index ranged descriptors are
+	// expanded to by the commands that accept descriptor objects when no
+	// range is provided.
+	defaultDescriptorRangeEnd = 1000
+)
+
+// parseDescriptor parses the passed output descriptor for the network the
+// server is running on and converts any error to an RPC error.
+func parseDescriptor(s *rpcServer, desc string, requireChecksum bool) (*descriptor.Descriptor, error) {
+	d, err := descriptor.Parse(desc, requireChecksum, s.cfg.ChainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: fmt.Sprintf("Invalid descriptor %q: %v", desc, err),
+		}
+	}
+	return d, nil
+}
+
+// parseDescriptorRange returns the first and last index, inclusive, of the
+// passed descriptor range.  A range given as a single number n is the range
+// from 0 to n.
+func parseDescriptorRange(r *btcjson.DescriptorRange) (uint32, uint32, error) {
+	var begin, end int
+	switch v := r.Value.(type) {
+	case int:
+		end = v
+	case []int:
+		if len(v) != 2 {
+			return 0, 0, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Range must be a number or a [begin,end] pair",
+			}
+		}
+		begin, end = v[0], v[1]
+	default:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be a number or a [begin,end] pair",
+		}
+	}
+
+	switch {
+	case begin < 0 || end < 0:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range should be greater or equal than 0",
+		}
+	case end < begin:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range end should be equal to or greater than begin",
+		}
+	case int64(end) >= hdkeychain.HardenedKeyStart:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "End of range is too high",
+		}
+	case end-begin >= maxDescriptorRangeSize:
+		return 0, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range is too large",
+		}
+	}
+	return uint32(begin), uint32(end), nil
+}
+
+// expandScanObjects returns the outputs described by the passed descriptor
+// objects as accepted by the scantxoutset and utxoupdatepsbt commands.  Ranged
+// descriptors are expanded over the range of their object, which defaults to
+// the indices 0 through defaultDescriptorRangeEnd.
+func expandScanObjects(s *rpcServer, scanObjects []btcjson.ScanObject) ([]descriptor.Output, error) {
+	var outputs []descriptor.Output
+	for _, scanObject := range scanObjects {
+		d, err := parseDescriptor(s, scanObject.Descriptor, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var begin, end uint32
+		switch {
+		case !d.IsRange() && scanObject.Range != nil:
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: "Range should not be specified for an " +
+					"un-ranged descriptor",
+			}
+		case d.IsRange() && scanObject.Range == nil:
+			end = defaultDescriptorRangeEnd
+		case d.IsRange():
+			begin, end, err = parseDescriptorRange(scanObject.Range)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for i := begin; i <= end; i++ {
+			expanded, err := d.Expand(i)
+			if err != nil {
+				context := "Failed to derive descriptor outputs"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			outputs = append(outputs, expanded...)
+		}
+	}
+	return outputs, nil
+}
+
+// handleDeriveAddresses handles deriveaddresses commands.
+func handleDeriveAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DeriveAddressesCmd)
+
+	d, err := parseDescriptor(s, c.Descriptor, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// The range is required for and only allowed with ranged descriptors.
+	var begin, end uint32
+	switch {
+	case d.IsRange() && c.Range == nil:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Range must be specified for a ranged descriptor",
+		}
+	case !d.IsRange() && c.Range != nil:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Range should not be specified for an un-ranged " +
+				"descriptor",
+		}
+	case c.Range != nil:
+		begin, end, err = parseDescriptorRange(c.Range)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	addresses := make([]string, 0, end-begin+1)
+	for i := begin; i <= end; i++ {
+		outputs, err := d.Expand(i)
+		if err != nil {
+			context := "Failed to derive descriptor outputs"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// Outputs without an address, such as the bare public key
+		// output of a combo descriptor, are skipped.
+		for _, output := range outputs {
+			addr, err := output.Address(s.cfg.ChainParams)
+			if err != nil {
+				continue
+			}
+			addresses = append(addresses, addr.EncodeAddress())
+		}
+	}
+	if len(addresses) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Descriptor does not have a corresponding address",
+		}
+	}
+	return addresses, nil
+}
+
+// handleGetDescriptorInfo implements the getdescriptorinfo command.
+func handleGetDescriptorInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetDescriptorInfoCmd)
+
+	d, err := parseDescriptor(s, c.Descriptor, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum is that of the descriptor as provided, which only
+	// contains valid characters since it was parsed.
+	checksum, _ := descriptor.Checksum(c.Descriptor)
+	return &btcjson.GetDescriptorInfoResult{
+		Descriptor:     d.String(),
+		Checksum:       checksum,
+		IsRange:        d.IsRange(),
+		IsSolvable:     d.IsSolvable(),
+		HasPrivateKeys: d.HasPrivateKeys(),
+	}, nil
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/descriptor"
+)
+
+// mustDescriptorChecksum returns the checksum of the passed descriptor and
+// fails the test if there is an error.
+func mustDescriptorChecksum(t *testing.T, desc string) string {
+	checksum, err := descriptor.Checksum(desc)
+	if err != nil {
+		t.Fatalf("Checksum(%s): unexpected error: %v", desc, err)
+	}
+	return checksum
+}
+
+// TestParseDescriptorRange ensures descriptor ranges are converted to the
+// expected indices and that invalid ranges are rejected.
+func TestParseDescriptorRange(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value      interface{}
+		begin, end uint32
+		valid      bool
+	}{
+		{value: 0, begin: 0, end: 0, valid: true},
+		{value: 10, begin: 0, end: 10, valid: true},
+		{value: []int{5, 10}, begin: 5, end: 10, valid: true},
+		{value: []int{1, maxDescriptorRangeSize}, begin: 1,
+			end: maxDescriptorRangeSize, valid: true},
+		{value: -1},
+		{value: []int{-1, 10}},
+		{value: []int{10, 5}},
+		{value: []int{0, maxDescriptorRangeSize}},
+		{value: []int{1 << 31, 1<<31 + 1}},
+		{value: []int{1}},
+		{value: "10"},
+	}
+
+	for i, test := range tests {
+		r := &btcjson.DescriptorRange{Value: test.value}
+		begin, end, err := parseDescriptorRange(r)
+		if (err == nil) != test.valid {
+			t.Errorf("Test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if begin != test.begin || end != test.end {
+			t.Errorf("Test #%d: unexpected range - got [%d,%d], "+
+				"want [%d,%d]", i, begin, end, test.begin, test.end)
+		}
+	}
+}
+
+// TestDeriveAddresses ensures the deriveaddresses command returns the
+// addresses of the outputs described by a descriptor and enforces the range
+// and checksum requirements.
+func TestDeriveAddresses(t *testing.T) {
+	t.Parallel()
+
+	// The master public key of BIP0032 test vector 1.
+	const xpub = "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGh" +
+		"ePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"
+	const pubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959" +
+		"f2815b16f81798"
+
+	// withChecksum returns the passed descriptor with its checksum.
+	withChecksum := func(desc string) string {
+		return desc + "#" + mustDescriptorChecksum(t, desc)
+	}
+
+	tests := []struct {
+		name      string
+		desc      string
+		rng       *btcjson.DescriptorRange
+		addresses []string
+		code      btcjson.RPCErrorCode
+	}{
+		{
+			name: "combo",
+			desc: withChecksum("combo(" + pubKey + ")"),
+			addresses: []string{
+				"1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH",
+				"bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4",
+				"3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN",
+			},
+		},
+		{
+			name: "ranged",
+			desc: withChecksum("pkh(" + xpub + "/0/*)"),
+			rng:  &btcjson.DescriptorRange{Value: []int{0, 1}},
+			addresses: []string{
+				"12CL4K2eVqj7hQTix7dM7CVHCkpP17Pry3",
+				"13Q3u97PKtyERBpXg31MLoJbQsECgJiMMw",
+			},
+		},
+		{
+			name: "missing checksum",
+			desc: "combo(" + pubKey + ")",
+			code: btcjson.ErrRPCInvalidAddressOrKey,
+		},
+		{
+			name: "no address",
+			desc: withChecksum("pk(" + pubKey + ")"),
+			code: btcjson.ErrRPCInvalidAddressOrKey,
+		},
+		{
+			name: "missing range",
+			desc: withChecksum("pkh(" + xpub + "/0/*)"),
+			code: btcjson.ErrRPCInvalidParameter,
+		},
+		{
+			name: "range for unranged descriptor",
+			desc: withChecksum("pkh(" + pubKey + ")"),
+			rng:  &btcjson.DescriptorRange{Value: 1},
+			code: btcjson.ErrRPCInvalidParameter,
+		},
+	}
+
+	params := &chaincfg.MainNetParams
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: params}}
+	for _, test := range tests {
+		cmd := btcjson.NewDeriveAddressesCmd(test.desc, test.rng)
+		result, err := handleDeriveAddresses(s, cmd, nil)
+		if test.code != 0 {
+			jerr, ok := err.(*btcjson.RPCError)
+			if !ok || jerr.Code != test.code {
+				t.Errorf("%s: unexpected error - got %v, want code "+
+					"%d", test.name, err, test.code)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, test.addresses) {
+			t.Errorf("%s: unexpected addresses - got %v, want %v",
+				test.name, result, test.addresses)
+		}
+	}
+}
+
+// TestGetDescriptorInfo ensures the getdescriptorinfo command returns the
+// canonical form of a descriptor along with the checksum of the provided one.
+func TestGetDescriptorInfo(t *testing.T) {
+	t.Parallel()
+
+	const pubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959" +
+		"f2815b16f81798"
+	const wif = "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+
+	params := &chaincfg.MainNetParams
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: params}}
+	desc := "wpkh(" + wif + ")"
+	cmd := &btcjson.GetDescriptorInfoCmd{Descriptor: desc}
+	result, err := handleGetDescriptorInfo(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("getdescriptorinfo: unexpected error: %v", err)
+	}
+	want := &btcjson.GetDescriptorInfoResult{
+		Descriptor: "wpkh(" + pubKey + ")#" + mustDescriptorChecksum(t,
+			"wpkh("+pubKey+")"),
+		Checksum:       mustDescriptorChecksum(t, desc),
+		IsSolvable:     true,
+		HasPrivateKeys: true,
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("getdescriptorinfo: unexpected result - got %+v, want "+
+			"%+v", result, want)
+	}
+
+	cmd = &btcjson.GetDescriptorInfoCmd{Descriptor: desc + "#00000000"}
+	_, err = handleGetDescriptorInfo(s, cmd, nil)
+	if jerr, ok := err.(*btcjson.RPCError); !ok ||
+		jerr.Code != btcjson.ErrRPCInvalidAddressOrKey {
+
+		t.Fatalf("getdescriptorinfo: unexpected error %v", err)
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/descriptor"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
+)
+
+// sigHashTypeStrings maps the sighash types of inputs to the names used by
+// the decodepsbt command.
+var sigHashTypeStrings = map[txscript.SigHashType]string{
+	txscript.SigHashAll:                                "ALL",
+	txscript.SigHashNone:                               "NONE",
+	txscript.SigHashSingle:                             "SINGLE",
+	txscript.SigHashAll | txscript.SigHashAnyOneCanPay: "ALL|ANYONECANPAY",
+	txscript.SigHashNone | txscript.SigHashAnyOneCanPay: "NONE|" +
+		"ANYONECANPAY",
+	txscript.SigHashSingle | txscript.SigHashAnyOneCanPay: "SINGLE|" +
+		"ANYONECANPAY",
+}
+
+// decodePsbt parses the passed base64-encoded PSBT and converts any error to
+// an RPC error.
+func decodePsbt(encoded string) (*psbt.Packet, error) {
+	p, err := psbt.DecodeBase64(encoded)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed " + err.Error(),
+		}
+	}
+	return p, nil
+}
+
+// encodePsbt returns the passed PSBT encoded in base64 and converts any error
+// to an RPC error.
+func encodePsbt(p *psbt.Packet) (string, error) {
+	encoded, err := p.EncodeBase64()
+	if err != nil {
+		context := "Failed to serialize PSBT"
+		return "", internalRPCError(err.Error(), context)
+	}
+	return encoded, nil
+}
+
+// createPsbtTxResult returns the JSON description of a transaction of a PSBT
+// in the format used by the decoderawtransaction command.
+func createPsbtTxResult(s *rpcServer, mtx *wire.MsgTx) btcjson.TxRawDecodeResult {
+	return btcjson.TxRawDecodeResult{
+		Txid:     mtx.TxHash().String(),
+		Version:  mtx.Version,
+		Locktime: mtx.LockTime,
+		Vin:      createVinList(mtx),
+		Vout:     createVoutList(mtx, s.cfg.ChainParams, nil),
+	}
+}
+
+// createPsbtScriptResult returns the JSON description of a redeem or witness
+// script of a PSBT, or nil when the script is not set.
+func createPsbtScriptResult(s *rpcServer, script []byte) *btcjson.ScriptPubKeyResult {
+	if script == nil {
+		return nil
+	}
+	result := createScriptPubKeyResult(script, s.cfg.ChainParams)
+	return &result
+}
+
+// createPsbtBip32Derivs returns the JSON description of the passed BIP0032
+// derivations of a PSBT.
+func createPsbtBip32Derivs(derivations []*psbt.Bip32Derivation) []btcjson.PsbtBip32Deriv {
+	if len(derivations) == 0 {
+		return nil
+	}
+	derivs := make([]btcjson.PsbtBip32Deriv, 0, len(derivations))
+	for _, d := range derivations {
+		var path strings.Builder
+		path.WriteString("m")
+		for _, index := range d.Path {
+			if index >= hdkeychain.HardenedKeyStart {
+				fmt.Fprintf(&path, "/%d'",
+					index-hdkeychain.HardenedKeyStart)
+			} else {
+				fmt.Fprintf(&path, "/%d", index)
+			}
+		}
+		derivs = append(derivs, btcjson.PsbtBip32Deriv{
+			PubKey:            hex.EncodeToString(d.PubKey),
+			MasterFingerprint: fmt.Sprintf("%08x", d.Fingerprint),
+			Path:              path.String(),
+		})
+	}
+	return derivs
+}
+
+// createPsbtUnknowns returns the passed unknown key-value pairs of a PSBT as a
+// map of hex-encoded keys to hex-encoded values, or nil when there are none.
+func createPsbtUnknowns(unknowns []*psbt.Unknown) map[string]string {
+	if len(unknowns) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(unknowns))
+	for _, u := range unknowns {
+		m[hex.EncodeToString(u.Key)] = hex.EncodeToString(u.Value)
+	}
+	return m
+}
+
+// handleDecodePsbt handles decodepsbt commands.
+func handleDecodePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodePsbtCmd)
+
+	p, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.DecodePsbtResult{
+		Tx:      createPsbtTxResult(s, p.UnsignedTx),
+		Unknown: createPsbtUnknowns(p.Unknowns),
+		Inputs:  make([]btcjson.DecodePsbtInput, len(p.Inputs)),
+		Outputs: make([]btcjson.DecodePsbtOutput, len(p.Outputs)),
+	}
+	if result.Unknown == nil {
+		result.Unknown = make(map[string]string)
+	}
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		inResult := &result.Inputs[i]
+		if in.NonWitnessUtxo != nil {
+			tx := createPsbtTxResult(s, in.NonWitnessUtxo)
+			inResult.NonWitnessUtxo = &tx
+		}
+		if in.WitnessUtxo != nil {
+			inResult.WitnessUtxo = &btcjson.PsbtWitnessUtxo{
+				Amount: btcutil.Amount(in.WitnessUtxo.Value).ToBTC(),
+				ScriptPubKey: createScriptPubKeyResult(
+					in.WitnessUtxo.PkScript, s.cfg.ChainParams),
+			}
+		}
+		if len(in.PartialSigs) != 0 {
+			sigs := make(map[string]string, len(in.PartialSigs))
+			for _, sig := range in.PartialSigs {
+				sigs[hex.EncodeToString(sig.PubKey)] =
+					hex.EncodeToString(sig.Signature)
+			}
+			inResult.PartialSignatures = sigs
+		}
+		if in.SighashType != 0 {
+			inResult.Sighash = sigHashTypeStrings[in.SighashType]
+			if inResult.Sighash == "" {
+				inResult.Sighash = fmt.Sprintf("%#x",
+					uint32(in.SighashType))
+			}
+		}
+		inResult.RedeemScript = createPsbtScriptResult(s, in.RedeemScript)
+		inResult.WitnessScript = createPsbtScriptResult(s, in.WitnessScript)
+		inResult.Bip32Derivs = createPsbtBip32Derivs(in.Bip32Derivation)
+		if in.FinalScriptSig != nil {
+			// The disassembled string will contain [error] inline
+			// if the script doesn't fully parse, so ignore the
+			// error here.
+			disbuf, _ := txscript.DisasmString(in.FinalScriptSig)
+			inResult.FinalScriptSig = &btcjson.ScriptSig{
+				Asm: disbuf,
+				Hex: hex.EncodeToString(in.FinalScriptSig),
+			}
+		}
+		inResult.FinalScriptWitness = witnessToHex(in.FinalScriptWitness)
+		inResult.Unknown = createPsbtUnknowns(in.Unknowns)
+	}
+	for i := range p.Outputs {
+		out := &p.Outputs[i]
+		result.Outputs[i] = btcjson.DecodePsbtOutput{
+			RedeemScript:  createPsbtScriptResult(s, out.RedeemScript),
+			WitnessScript: createPsbtScriptResult(s, out.WitnessScript),
+			Bip32Derivs:   createPsbtBip32Derivs(out.Bip32Derivation),
+			Unknown:       createPsbtUnknowns(out.Unknowns),
+		}
+	}
+
+	// The fee is only known when the outputs spent by all inputs are.
+	if fee, err := p.Fee(); err == nil {
+		feeBTC := fee.ToBTC()
+		result.Fee = &feeBTC
+	}
+	return result, nil
+}
+
+// handleAnalyzePsbt handles analyzepsbt commands.
+func handleAnalyzePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AnalyzePsbtCmd)
+
+	p, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The role that has to act on the PSBT next is the earliest role any
+	// of its inputs needs next.
+	result := &btcjson.AnalyzePsbtResult{
+		Inputs: make([]btcjson.AnalyzePsbtInput, len(p.Inputs)),
+	}
+	next := psbt.RoleExtractor
+	for i := range p.Inputs {
+		analysis := p.AnalyzeInput(i)
+		result.Inputs[i] = btcjson.AnalyzePsbtInput{
+			HasUtxo: analysis.HasUtxo,
+			IsFinal: analysis.IsFinal,
+			Next:    analysis.Next.String(),
+		}
+		if len(analysis.MissingPubKeys) != 0 ||
+			len(analysis.MissingSigs) != 0 ||
+			analysis.MissingRedeemScript != nil ||
+			analysis.MissingWitnessScript != nil {
+
+			missing := &btcjson.AnalyzePsbtMissing{
+				RedeemScript: hex.EncodeToString(
+					analysis.MissingRedeemScript),
+				WitnessScript: hex.EncodeToString(
+					analysis.MissingWitnessScript),
+			}
+			for _, hash := range analysis.MissingPubKeys {
+				missing.PubKeys = append(missing.PubKeys,
+					hex.EncodeToString(hash))
+			}
+			for _, hash := range analysis.MissingSigs {
+				missing.Signatures = append(missing.Signatures,
+					hex.EncodeToString(hash))
+			}
+			result.Inputs[i].Missing = missing
+		}
+		if analysis.Next < next {
+			next = analysis.Next
+		}
+
+		// A PSBT that spends an invalid output can never become a
+		// valid transaction.
+		prevOut, ok := p.PrevOutput(i)
+		if !ok {
+			continue
+		}
+		if prevOut.Value < 0 || prevOut.Value > btcutil.MaxSatoshi {
+			result.Next = psbt.RoleCreator.String()
+			result.Error = fmt.Sprintf("PSBT is not valid. Input %d "+
+				"has invalid value", i)
+			return result, nil
+		}
+		if txscript.IsUnspendable(prevOut.PkScript) {
+			result.Next = psbt.RoleCreator.String()
+			result.Error = fmt.Sprintf("PSBT is not valid. Input %d "+
+				"spends unspendable output", i)
+			return result, nil
+		}
+	}
+	result.Next = next.String()
+
+	// The fee is only known when the outputs spent by all inputs are.
+	fee, err := p.Fee()
+	if err != nil {
+		return result, nil
+	}
+	if fee < 0 {
+		result.Next = psbt.RoleCreator.String()
+		result.Error = "PSBT is not valid. Input amount less than " +
+			"output amount"
+		return result, nil
+	}
+	feeBTC := fee.ToBTC()
+	result.Fee = &feeBTC
+
+	// The size of the final transaction is only known when all inputs can
+	// be finalized.
+	if p.FinalizeAll() {
+		tx, err := p.Extract()
+		if err != nil {
+			context := "Failed to extract transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		vsize := mempool.GetTxVirtualSize(btcutil.NewTx(tx))
+		feeRate := btcutil.Amount(int64(fee) * 1000 / vsize).ToBTC()
+		result.EstimatedVSize = &vsize
+		result.EstimatedFeeRate = &feeRate
+	}
+	return result, nil
+}
+
+// handleCombinePsbt handles combinepsbt commands.
+func handleCombinePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CombinePsbtCmd)
+
+	if len(c.Txs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Parameter 'txs' cannot be empty",
+		}
+	}
+	packets := make([]*psbt.Packet, 0, len(c.Txs))
+	for _, encoded := range c.Txs {
+		p, err := decodePsbt(encoded)
+		if err != nil {
+			return nil, err
+		}
+		packets = append(packets, p)
+	}
+
+	combined, err := psbt.Combine(packets)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "PSBTs not compatible (different transactions)",
+		}
+	}
+	return encodePsbt(combined)
+}
+
+// handleFinalizePsbt handles finalizepsbt commands.
+func handleFinalizePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.FinalizePsbtCmd)
+
+	p, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &btcjson.FinalizePsbtResult{Complete: p.FinalizeAll()}
+	if result.Complete && (c.Extract == nil || *c.Extract) {
+		tx, err := p.Extract()
+		if err != nil {
+			context := "Failed to extract transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		result.Hex, err = messageToHex(tx)
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+	result.Psbt, err = encodePsbt(p)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// fetchPsbtUtxo returns the unspent output for the passed outpoint from the
+// main chain or the outputs of transactions in the memory pool, or nil when
+// it is not found.
+func fetchPsbtUtxo(s *rpcServer, outpoint wire.OutPoint) *wire.TxOut {
+	entry, err := s.cfg.Chain.FetchUtxoEntry(outpoint)
+	if err == nil && entry != nil && !entry.IsSpent() {
+		return wire.NewTxOut(entry.Amount(), entry.PkScript())
+	}
+	tx, err := s.cfg.TxMemPool.FetchTransaction(&outpoint.Hash)
+	if err != nil || outpoint.Index >= uint32(len(tx.MsgTx().TxOut)) {
+		return nil
+	}
+	return tx.MsgTx().TxOut[outpoint.Index]
+}
+
+// mergePsbtBip32Derivations adds the origins of the passed descriptor keys
+// that have no derivation in derivations yet to them.
+func mergePsbtBip32Derivations(derivations []*psbt.Bip32Derivation, keys []descriptor.Key) []*psbt.Bip32Derivation {
+next:
+	for _, key := range keys {
+		for _, d := range derivations {
+			if bytes.Equal(d.PubKey, key.PubKey) {
+				continue next
+			}
+		}
+		derivations = append(derivations, &psbt.Bip32Derivation{
+			PubKey:      key.PubKey,
+			Fingerprint: key.Origin.Fingerprint,
+			Path:        key.Origin.Path,
+		})
+	}
+	return derivations
+}
+
+// handleUtxoUpdatePsbt handles utxoupdatepsbt commands.
+func handleUtxoUpdatePsbt(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.UtxoUpdatePsbtCmd)
+
+	p, err := decodePsbt(c.Psbt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expand the descriptors into the scripts and keys of their outputs
+	// keyed by the output script.
+	outputs := make(map[string]*descriptor.Output)
+	if c.Descriptors != nil {
+		expanded, err := expandScanObjects(s, *c.Descriptors)
+		if err != nil {
+			return nil, err
+		}
+		for i := range expanded {
+			outputs[string(expanded[i].PkScript)] = &expanded[i]
+		}
+	}
+
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		prevOut, ok := p.PrevOutput(i)
+		if !ok {
+			outpoint := p.UnsignedTx.TxIn[i].PreviousOutPoint
+			prevOut = fetchPsbtUtxo(s, outpoint)
+			if prevOut == nil {
+				continue
+			}
+		}
+
+		if output, ok := outputs[string(prevOut.PkScript)]; ok {
+			if in.RedeemScript == nil {
+				in.RedeemScript = output.RedeemScript
+			}
+			if in.WitnessScript == nil {
+				in.WitnessScript = output.WitnessScript
+			}
+			in.Bip32Derivation = mergePsbtBip32Derivations(
+				in.Bip32Derivation, output.Keys)
+		}
+
+		// Only the outputs spent by segwit inputs are added since the
+		// signatures of other inputs do not commit to the amount of
+		// the output they spend, which requires the entire previous
+		// transaction to be included instead.
+		isWitness := txscript.IsWitnessProgram(prevOut.PkScript) ||
+			(txscript.IsPayToScriptHash(prevOut.PkScript) &&
+				txscript.IsWitnessProgram(in.RedeemScript))
+		if in.WitnessUtxo == nil && isWitness {
+			in.WitnessUtxo = prevOut
+		}
+	}
+
+	for i := range p.Outputs {
+		out := &p.Outputs[i]
+		pkScript := p.UnsignedTx.TxOut[i].PkScript
+		output, ok := outputs[string(pkScript)]
+		if !ok {
+			continue
+		}
+		if out.RedeemScript == nil {
+			out.RedeemScript = output.RedeemScript
+		}
+		if out.WitnessScript == nil {
+			out.WitnessScript = output.WitnessScript
+		}
+		out.Bip32Derivation = mergePsbtBip32Derivations(
+			out.Bip32Derivation, output.Keys)
+	}
+
+	return encodePsbt(p)
+}
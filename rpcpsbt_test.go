@@ -0,0 +1,341 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/psbt"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// testPsbtSpend returns a PSBT of a transaction that spends a single
+// pay-to-witness-pubkey-hash output of 1 BTC to the passed key with a fee of
+// 1000 satoshi, without any information about its input.
+func testPsbtSpend(t *testing.T, key *btcec.PrivateKey) (*psbt.Packet, *wire.TxOut) {
+	pubKeyHash := btcutil.Hash160(key.PubKey().SerializeCompressed())
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_0).
+		AddData(pubKeyHash).Script()
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	prevOut := wire.NewTxOut(1e8, pkScript)
+
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}}, nil,
+		nil))
+	tx.AddTxOut(wire.NewTxOut(1e8-1000, pkScript))
+	p, err := psbt.New(tx)
+	if err != nil {
+		t.Fatalf("unable to create PSBT: %v", err)
+	}
+	return p, prevOut
+}
+
+// mustEncodePsbt returns the passed PSBT encoded in base64 and fails the test
+// if there is an error.
+func mustEncodePsbt(t *testing.T, p *psbt.Packet) string {
+	encoded, err := p.EncodeBase64()
+	if err != nil {
+		t.Fatalf("unable to encode PSBT: %v", err)
+	}
+	return encoded
+}
+
+// checkRPCErrorCode ensures the passed error is an RPC error with the passed
+// code.
+func checkRPCErrorCode(t *testing.T, context string, err error, code btcjson.RPCErrorCode) {
+	t.Helper()
+	if jerr, ok := err.(*btcjson.RPCError); !ok || jerr.Code != code {
+		t.Errorf("%s: unexpected error - got %v, want code %d", context,
+			err, code)
+	}
+}
+
+// TestDecodePsbt ensures the decodepsbt command describes all information a
+// PSBT holds.
+func TestDecodePsbt(t *testing.T) {
+	t.Parallel()
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{0x01})
+	pubKey := key.PubKey().SerializeCompressed()
+	p, prevOut := testPsbtSpend(t, key)
+	in := &p.Inputs[0]
+	in.WitnessUtxo = prevOut
+	in.PartialSigs = []*psbt.PartialSig{{PubKey: pubKey,
+		Signature: []byte{0x30, 0x01}}}
+	in.SighashType = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+	in.Bip32Derivation = []*psbt.Bip32Derivation{{
+		PubKey:      pubKey,
+		Fingerprint: 0xd34db33f,
+		Path:        []uint32{0x80000000, 1},
+	}}
+	in.Unknowns = []*psbt.Unknown{{Key: []byte{0xf0}, Value: []byte{0x01}}}
+	p.Outputs[0].WitnessScript = []byte{txscript.OP_TRUE}
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &chaincfg.MainNetParams}}
+	cmd := btcjson.NewDecodePsbtCmd(mustEncodePsbt(t, p))
+	result, err := handleDecodePsbt(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("decodepsbt: unexpected error: %v", err)
+	}
+	decoded := result.(*btcjson.DecodePsbtResult)
+
+	if decoded.Tx.Txid != p.UnsignedTx.TxHash().String() {
+		t.Errorf("decodepsbt: unexpected txid %s", decoded.Tx.Txid)
+	}
+	if decoded.Fee == nil || *decoded.Fee != 0.00001 {
+		t.Errorf("decodepsbt: unexpected fee %v", decoded.Fee)
+	}
+	wantInput := btcjson.DecodePsbtInput{
+		WitnessUtxo: &btcjson.PsbtWitnessUtxo{
+			Amount: 1,
+			ScriptPubKey: createScriptPubKeyResult(prevOut.PkScript,
+				s.cfg.ChainParams),
+		},
+		PartialSignatures: map[string]string{
+			hex.EncodeToString(pubKey): "3001",
+		},
+		Sighash: "SINGLE|ANYONECANPAY",
+		Bip32Derivs: []btcjson.PsbtBip32Deriv{{
+			PubKey:            hex.EncodeToString(pubKey),
+			MasterFingerprint: "d34db33f",
+			Path:              "m/0'/1",
+		}},
+		Unknown: map[string]string{"f0": "01"},
+	}
+	if !reflect.DeepEqual(decoded.Inputs, []btcjson.DecodePsbtInput{wantInput}) {
+		t.Errorf("decodepsbt: unexpected inputs - got %+v, want %+v",
+			decoded.Inputs, wantInput)
+	}
+	witnessScript := decoded.Outputs[0].WitnessScript
+	if witnessScript == nil || witnessScript.Hex != "51" {
+		t.Errorf("decodepsbt: unexpected witness script %+v",
+			witnessScript)
+	}
+
+	cmd = btcjson.NewDecodePsbtCmd("cHNidP8=")
+	_, err = handleDecodePsbt(s, cmd, nil)
+	checkRPCErrorCode(t, "decodepsbt", err, btcjson.ErrRPCDeserialization)
+}
+
+// TestPsbtWorkflow ensures a PSBT that is updated and signed in separate
+// copies is combined, analyzed and finalized into a valid transaction by the
+// respective commands.
+func TestPsbtWorkflow(t *testing.T) {
+	t.Parallel()
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{0x01})
+	pubKey := key.PubKey().SerializeCompressed()
+	p, prevOut := testPsbtSpend(t, key)
+	created := mustEncodePsbt(t, p)
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &chaincfg.MainNetParams}}
+	analyze := func(encoded string) *btcjson.AnalyzePsbtResult {
+		t.Helper()
+		cmd := btcjson.NewAnalyzePsbtCmd(encoded)
+		result, err := handleAnalyzePsbt(s, cmd, nil)
+		if err != nil {
+			t.Fatalf("analyzepsbt: unexpected error: %v", err)
+		}
+		return result.(*btcjson.AnalyzePsbtResult)
+	}
+	if next := analyze(created).Next; next != "updater" {
+		t.Fatalf("analyzepsbt: unexpected next role %s", next)
+	}
+
+	// Add the output spent by the input and the derivation of its key in
+	// one copy and the signature in another.
+	p.Inputs[0].WitnessUtxo = prevOut
+	p.Inputs[0].Bip32Derivation = []*psbt.Bip32Derivation{{
+		PubKey:      pubKey,
+		Fingerprint: 0xd34db33f,
+	}}
+	updated := mustEncodePsbt(t, p)
+	analysis := analyze(updated)
+	wantMissing := &btcjson.AnalyzePsbtMissing{
+		Signatures: []string{hex.EncodeToString(btcutil.Hash160(pubKey))},
+	}
+	if analysis.Next != "signer" ||
+		!reflect.DeepEqual(analysis.Inputs[0].Missing, wantMissing) {
+
+		t.Fatalf("analyzepsbt: unexpected analysis of updated PSBT %+v",
+			analysis)
+	}
+
+	sig, err := txscript.RawTxInWitnessSignature(p.UnsignedTx,
+		txscript.NewTxSigHashes(p.UnsignedTx), 0, prevOut.Value,
+		prevOut.PkScript, txscript.SigHashAll, key)
+	if err != nil {
+		t.Fatalf("unable to sign: %v", err)
+	}
+	p.Inputs[0].WitnessUtxo = nil
+	p.Inputs[0].Bip32Derivation = nil
+	p.Inputs[0].PartialSigs = []*psbt.PartialSig{{PubKey: pubKey,
+		Signature: sig}}
+	signed := mustEncodePsbt(t, p)
+
+	result, err := handleCombinePsbt(s, btcjson.NewCombinePsbtCmd(
+		[]string{updated, signed}), nil)
+	if err != nil {
+		t.Fatalf("combinepsbt: unexpected error: %v", err)
+	}
+	combined := result.(string)
+	analysis = analyze(combined)
+	if analysis.Next != "finalizer" || analysis.Fee == nil ||
+		*analysis.Fee != 0.00001 || analysis.EstimatedVSize == nil ||
+		*analysis.EstimatedVSize != 110 {
+
+		t.Fatalf("analyzepsbt: unexpected analysis of combined PSBT %+v",
+			analysis)
+	}
+
+	// Finalize the PSBT without extracting the transaction first.
+	cmd := btcjson.NewFinalizePsbtCmd(combined, btcjson.Bool(false))
+	result, err = handleFinalizePsbt(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("finalizepsbt: unexpected error: %v", err)
+	}
+	finalized := result.(*btcjson.FinalizePsbtResult)
+	if !finalized.Complete || finalized.Hex != "" {
+		t.Fatalf("finalizepsbt: unexpected result %+v", finalized)
+	}
+	if next := analyze(finalized.Psbt).Next; next != "extractor" {
+		t.Fatalf("analyzepsbt: unexpected next role of finalized PSBT "+
+			"%s", next)
+	}
+
+	cmd = btcjson.NewFinalizePsbtCmd(combined, nil)
+	result, err = handleFinalizePsbt(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("finalizepsbt: unexpected error: %v", err)
+	}
+	finalized = result.(*btcjson.FinalizePsbtResult)
+	serializedTx, err := hex.DecodeString(finalized.Hex)
+	if !finalized.Complete || finalized.Psbt != "" || err != nil {
+		t.Fatalf("finalizepsbt: unexpected result %+v", finalized)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(serializedTx)); err != nil {
+		t.Fatalf("finalizepsbt: unable to decode transaction: %v", err)
+	}
+	vm, err := txscript.NewEngine(prevOut.PkScript, &tx, 0,
+		txscript.StandardVerifyFlags, nil, txscript.NewTxSigHashes(&tx),
+		prevOut.Value)
+	if err == nil {
+		err = vm.Execute()
+	}
+	if err != nil {
+		t.Fatalf("finalizepsbt: transaction does not verify: %v", err)
+	}
+
+	// A PSBT that cannot be finalized is returned as is.
+	result, err = handleFinalizePsbt(s, btcjson.NewFinalizePsbtCmd(updated,
+		nil), nil)
+	if err != nil {
+		t.Fatalf("finalizepsbt: unexpected error: %v", err)
+	}
+	want := &btcjson.FinalizePsbtResult{Psbt: updated}
+	if !reflect.DeepEqual(result, want) {
+		t.Fatalf("finalizepsbt: unexpected result %+v", result)
+	}
+
+	// PSBTs of different transactions are not combined.
+	p.UnsignedTx.LockTime = 1
+	cmd2 := btcjson.NewCombinePsbtCmd([]string{updated, mustEncodePsbt(t, p)})
+	_, err = handleCombinePsbt(s, cmd2, nil)
+	checkRPCErrorCode(t, "combinepsbt", err, btcjson.ErrRPCInvalidParameter)
+	_, err = handleCombinePsbt(s, btcjson.NewCombinePsbtCmd(nil), nil)
+	checkRPCErrorCode(t, "combinepsbt", err, btcjson.ErrRPCInvalidParameter)
+}
+
+// TestAnalyzePsbtInvalid ensures the analyzepsbt command reports PSBTs that can
+// never become valid transactions.
+func TestAnalyzePsbtInvalid(t *testing.T) {
+	t.Parallel()
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{0x01})
+	p, prevOut := testPsbtSpend(t, key)
+	prevOut.Value = 100
+	p.Inputs[0].WitnessUtxo = prevOut
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &chaincfg.MainNetParams}}
+	cmd := btcjson.NewAnalyzePsbtCmd(mustEncodePsbt(t, p))
+	result, err := handleAnalyzePsbt(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("analyzepsbt: unexpected error: %v", err)
+	}
+	analysis := result.(*btcjson.AnalyzePsbtResult)
+	if analysis.Next != "creator" || analysis.Error == "" ||
+		analysis.Fee != nil {
+
+		t.Fatalf("analyzepsbt: unexpected analysis %+v", analysis)
+	}
+}
+
+// TestUtxoUpdatePsbt ensures the utxoupdatepsbt command adds the scripts and
+// key derivations described by the provided descriptors to the inputs and
+// outputs of a PSBT.
+func TestUtxoUpdatePsbt(t *testing.T) {
+	t.Parallel()
+
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{0x01})
+	pubKey := key.PubKey().SerializeCompressed()
+	p, prevOut := testPsbtSpend(t, key)
+	p.Inputs[0].WitnessUtxo = prevOut
+	redeemScript := p.UnsignedTx.TxOut[0].PkScript
+	p.UnsignedTx.TxOut[0].PkScript, _ = txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).AddData(btcutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).Script()
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: &chaincfg.MainNetParams}}
+	pubKeyHex := hex.EncodeToString(pubKey)
+	descriptors := []btcjson.ScanObject{
+		{Descriptor: "wpkh([d34db33f/0']" + pubKeyHex + ")"},
+		{Descriptor: "sh(wpkh(" + pubKeyHex + "))"},
+	}
+	cmd := btcjson.NewUtxoUpdatePsbtCmd(mustEncodePsbt(t, p), &descriptors)
+	result, err := handleUtxoUpdatePsbt(s, cmd, nil)
+	if err != nil {
+		t.Fatalf("utxoupdatepsbt: unexpected error: %v", err)
+	}
+	updated, err := psbt.DecodeBase64(result.(string))
+	if err != nil {
+		t.Fatalf("utxoupdatepsbt: unable to decode result: %v", err)
+	}
+
+	wantDerivations := []*psbt.Bip32Derivation{{
+		PubKey:      pubKey,
+		Fingerprint: 0xd34db33f,
+		Path:        []uint32{0x80000000},
+	}}
+	if !reflect.DeepEqual(updated.Inputs[0].Bip32Derivation,
+		wantDerivations) {
+
+		t.Errorf("utxoupdatepsbt: unexpected input derivations %+v",
+			updated.Inputs[0].Bip32Derivation)
+	}
+	out := &updated.Outputs[0]
+	if !bytes.Equal(out.RedeemScript, redeemScript) ||
+		len(out.Bip32Derivation) != 1 {
+
+		t.Errorf("utxoupdatepsbt: unexpected output %+v", out)
+	}
+
+	descriptors = []btcjson.ScanObject{{Descriptor: "tr(" + pubKeyHex + ")"}}
+	cmd = btcjson.NewUtxoUpdatePsbtCmd(mustEncodePsbt(t, p), &descriptors)
+	_, err = handleUtxoUpdatePsbt(s, cmd, nil)
+	checkRPCErrorCode(t, "utxoupdatepsbt", err,
+		btcjson.ErrRPCInvalidAddressOrKey)
+}
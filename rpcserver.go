@@ -7,8 +7,6 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -21,19 +19,23 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/btcsuite/btcd/addrmgr"
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/blockchain/indexers"
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/btcjson"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
@@ -73,6 +75,11 @@ const (
 	// in the memory pool.
 	gbtRegenerateSeconds = 60
 
+	// diskUsageRefreshInterval is the minimum amount of time between walks
+	// of the block database directory to determine its size on disk for
+	// the getblockchaininfo RPC.
+	diskUsageRefreshInterval = time.Minute
+
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
 )
@@ -128,11 +135,17 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":                handleAddNode,
+	"analyzepsbt":            handleAnalyzePsbt,
+	"clearbanned":            handleClearBanned,
+	"combinepsbt":            handleCombinePsbt,
 	"createrawtransaction":   handleCreateRawTransaction,
 	"debuglevel":             handleDebugLevel,
+	"decodepsbt":             handleDecodePsbt,
 	"decoderawtransaction":   handleDecodeRawTransaction,
 	"decodescript":           handleDecodeScript,
+	"deriveaddresses":        handleDeriveAddresses,
 	"estimatefee":            handleEstimateFee,
+	"finalizepsbt":           handleFinalizePsbt,
 	"generate":               handleGenerate,
 	"getaddednodeinfo":       handleGetAddedNodeInfo,
 	"getbestblock":           handleGetBestBlock,
@@ -147,6 +160,7 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getcfilterheader":       handleGetCFilterHeader,
 	"getconnectioncount":     handleGetConnectionCount,
 	"getcurrentnet":          handleGetCurrentNet,
+	"getdescriptorinfo":      handleGetDescriptorInfo,
 	"getdifficulty":          handleGetDifficulty,
 	"getgenerate":            handleGetGenerate,
 	"gethashespersec":        handleGetHashesPerSec,
@@ -156,21 +170,28 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getmininginfo":          handleGetMiningInfo,
 	"getnettotals":           handleGetNetTotals,
 	"getnetworkhashps":       handleGetNetworkHashPS,
+	"getnetworkinfo":         handleGetNetworkInfo,
 	"getnodeaddresses":       handleGetNodeAddresses,
 	"getpeerinfo":            handleGetPeerInfo,
 	"getrawmempool":          handleGetRawMempool,
 	"getrawtransaction":      handleGetRawTransaction,
 	"gettxout":               handleGetTxOut,
 	"help":                   handleHelp,
+	"listbanned":             handleListBanned,
+	"logging":                handleLogging,
 	"node":                   handleNode,
 	"ping":                   handlePing,
+	"rpc.discover":           handleRPCDiscover,
+	"scantxoutset":           handleScanTxOutSet,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
+	"setban":                 handleSetBan,
 	"setgenerate":            handleSetGenerate,
 	"signmessagewithprivkey": handleSignMessageWithPrivKey,
 	"stop":                   handleStop,
 	"submitblock":            handleSubmitBlock,
 	"uptime":                 handleUptime,
+	"utxoupdatepsbt":         handleUtxoUpdatePsbt,
 	"validateaddress":        handleValidateAddress,
 	"verifychain":            handleVerifyChain,
 	"verifymessage":          handleVerifyMessage,
@@ -230,7 +251,6 @@ var rpcUnimplemented = map[string]struct{}{
 	"estimatepriority": {},
 	"getchaintips":     {},
 	"getmempoolentry":  {},
-	"getnetworkinfo":   {},
 	"getwork":          {},
 	"invalidateblock":  {},
 	"preciousblock":    {},
@@ -250,13 +270,19 @@ var rpcLimited = map[string]struct{}{
 	"session":               {},
 
 	// Websockets AND HTTP/S commands
-	"help": {},
+	"help":         {},
+	"rpc.discover": {},
 
 	// HTTP/S-only commands
+	"analyzepsbt":           {},
+	"combinepsbt":           {},
 	"createrawtransaction":  {},
+	"decodepsbt":            {},
 	"decoderawtransaction":  {},
 	"decodescript":          {},
+	"deriveaddresses":       {},
 	"estimatefee":           {},
+	"finalizepsbt":          {},
 	"getbestblock":          {},
 	"getbestblockhash":      {},
 	"getblock":              {},
@@ -266,6 +292,7 @@ var rpcLimited = map[string]struct{}{
 	"getcfilter":            {},
 	"getcfilterheader":      {},
 	"getcurrentnet":         {},
+	"getdescriptorinfo":     {},
 	"getdifficulty":         {},
 	"getheaders":            {},
 	"getinfo":               {},
@@ -278,6 +305,7 @@ var rpcLimited = map[string]struct{}{
 	"sendrawtransaction":    {},
 	"submitblock":           {},
 	"uptime":                {},
+	"utxoupdatepsbt":        {},
 	"validateaddress":       {},
 	"verifymessage":         {},
 	"version":               {},
@@ -392,6 +420,14 @@ func handleAddNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return nil, nil
 }
 
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.cfg.ConnMgr.ClearBanned(); err != nil {
+		return nil, internalRPCError(err.Error(), "Failed to clear ban list")
+	}
+	return nil, nil
+}
+
 // handleNode handles node commands.
 func handleNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.NodeCmd)
@@ -1182,6 +1218,68 @@ func softForkStatus(state blockchain.ThresholdState) (string, error) {
 	}
 }
 
+// verificationProgress returns an estimate of the portion of the chain which
+// has been verified based on the timestamp of the best block relative to the
+// time elapsed since the genesis block.
+func verificationProgress(bestTime, genesisTime, now time.Time) float64 {
+	total := now.Sub(genesisTime)
+	if total <= 0 {
+		return 1
+	}
+	progress := float64(bestTime.Sub(genesisTime)) / float64(total)
+	if progress < 0 {
+		return 0
+	}
+	if progress > 1 {
+		return 1
+	}
+	return progress
+}
+
+// dirSize returns the total size of the regular files beneath the passed path.
+// Files which can't be accessed are ignored.
+func dirSize(path string) int64 {
+	var size int64
+	filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// diskUsageCache caches the size of a directory on disk.  Walking the block
+// database directory is expensive, and getblockchaininfo may be requested by
+// unauthenticated REST clients, so the size is only determined again once it
+// is older than diskUsageRefreshInterval.
+type diskUsageCache struct {
+	mtx     sync.Mutex
+	path    string
+	size    int64
+	updated time.Time
+}
+
+// newDiskUsageCache returns a new cache of the size of the passed directory.
+func newDiskUsageCache(path string) *diskUsageCache {
+	return &diskUsageCache{path: path}
+}
+
+// Size returns the size of the directory on disk, which is at most
+// diskUsageRefreshInterval old.
+//
+// This function is safe for concurrent access.
+func (c *diskUsageCache) Size() int64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if time.Since(c.updated) >= diskUsageRefreshInterval {
+		c.size = dirSize(c.path)
+		c.updated = time.Now()
+	}
+	return c.size
+}
+
 // handleGetBlockChainInfo implements the getblockchaininfo command.
 func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Obtain a snapshot of the current best known blockchain state. We'll
@@ -1190,14 +1288,33 @@ func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan str
 	chain := s.cfg.Chain
 	chainSnapshot := chain.BestSnapshot()
 
+	header, err := chain.HeaderByHash(&chainSnapshot.Hash)
+	if err != nil {
+		context := "Failed to obtain best block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	chainWork, err := chain.ChainWork(&chainSnapshot.Hash)
+	if err != nil {
+		context := "Failed to obtain best chain work"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
 	chainInfo := &btcjson.GetBlockChainInfoResult{
 		Chain:         params.Name,
 		Blocks:        chainSnapshot.Height,
 		Headers:       chainSnapshot.Height,
 		BestBlockHash: chainSnapshot.Hash.String(),
 		Difficulty:    getDifficultyRatio(chainSnapshot.Bits, params),
+		Time:          header.Timestamp.Unix(),
 		MedianTime:    chainSnapshot.MedianTime.Unix(),
-		Pruned:        false,
+		VerificationProgress: verificationProgress(header.Timestamp,
+			params.GenesisBlock.Header.Timestamp,
+			s.cfg.TimeSource.AdjustedTime()),
+		InitialBlockDownload: !chain.IsCurrent(),
+		ChainWork:            fmt.Sprintf("%064x", chainWork),
+		SizeOnDisk:           s.cfg.DiskUsage.Size(),
+		Pruned:               false,
+		Warnings:             chain.Warnings(),
 		SoftForks: &btcjson.SoftForks{
 			Bip9SoftForks: make(map[string]*btcjson.Bip9SoftForkDescription),
 		},
@@ -2325,14 +2442,31 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
 
-	var numBytes int64
+	// The size limit of the mempool applies to the serialized size of the
+	// transactions, so it is reported as the usage.
+	var numBytes, usage, totalFee int64
 	for _, txD := range mempoolTxns {
-		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
+		numBytes += mempool.GetTxVirtualSize(txD.Tx)
+		usage += int64(txD.Tx.MsgTx().SerializeSize())
+		totalFee += txD.Fee
 	}
 
+	// The size of the mempool is not limited, so its minimum fee is never
+	// raised above the minimum relay fee, and the fee of replacement
+	// transactions must exceed the fee of the replaced ones by at least the
+	// minimum relay fee, so all fees are the minimum relay fee.
+	minRelayTxFee := cfg.minRelayTxFee.ToBTC()
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Loaded:              true,
+		Size:                int64(len(mempoolTxns)),
+		Bytes:               numBytes,
+		Usage:               usage,
+		TotalFee:            btcutil.Amount(totalFee).ToBTC(),
+		MaxMempool:          0,
+		MempoolMinFee:       minRelayTxFee,
+		MinRelayTxFee:       minRelayTxFee,
+		IncrementalRelayFee: minRelayTxFee,
+		FullRBF:             false,
 	}
 
 	return ret, nil
@@ -2377,14 +2511,49 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.cfg.ConnMgr.NetTotals()
+	target := s.cfg.ConnMgr.UploadTarget()
 	reply := &btcjson.GetNetTotalsResult{
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget: &btcjson.UploadTargetResult{
+			TimeFrame:             int64(target.Timeframe / time.Second),
+			Target:                target.Target,
+			TargetReached:         target.TargetReached,
+			ServeHistoricalBlocks: target.ServeHistoricalBlocks,
+			BytesLeftInCycle:      target.BytesLeftInCycle,
+			TimeLeftInCycle:       int64(target.TimeLeftInCycle / time.Second),
+		},
 	}
+	reply.BytesSentPerMsg, reply.BytesRecvPerMsg, reply.MsgsSentPerMsg,
+		reply.MsgsRecvPerMsg = msgTrafficPerMsg(s.cfg.ConnMgr.MsgTraffic())
 	return reply, nil
 }
 
+// msgTrafficPerMsg splits the passed per-command traffic into the bytes sent,
+// bytes received, messages sent, and messages received per command.  Commands
+// without any traffic in a direction are omitted from the maps for that
+// direction.
+func msgTrafficPerMsg(traffic map[string]peer.MsgTraffic) (bytesSent,
+	bytesRecv, msgsSent, msgsRecv map[string]uint64) {
+
+	bytesSent = make(map[string]uint64)
+	bytesRecv = make(map[string]uint64)
+	msgsSent = make(map[string]uint64)
+	msgsRecv = make(map[string]uint64)
+	for command, t := range traffic {
+		if t.MsgsSent != 0 {
+			bytesSent[command] = t.BytesSent
+			msgsSent[command] = t.MsgsSent
+		}
+		if t.MsgsRecv != 0 {
+			bytesRecv[command] = t.BytesRecv
+			msgsRecv[command] = t.MsgsRecv
+		}
+	}
+	return bytesSent, bytesRecv, msgsSent, msgsRecv
+}
+
 // handleGetNetworkHashPS implements the getnetworkhashps command.
 func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Note: All valid error return paths should return an int64.
@@ -2479,6 +2648,130 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hashesPerSec.Int64(), nil
 }
 
+// serviceFlagNames maps the service flags to the names used by Bitcoin Core.
+var serviceFlagNames = []struct {
+	flag wire.ServiceFlag
+	name string
+}{
+	{wire.SFNodeNetwork, "NETWORK"},
+	{wire.SFNodeGetUTXO, "GETUTXO"},
+	{wire.SFNodeBloom, "BLOOM"},
+	{wire.SFNodeWitness, "WITNESS"},
+	{wire.SFNodeXthin, "XTHIN"},
+	{wire.SFNodeCF, "COMPACT_FILTERS"},
+	{wire.SFNode2X, "SEGWIT2X"},
+}
+
+// serviceNames returns the names of the passed service flags.  Unknown flags
+// are named by their bit.
+func serviceNames(services wire.ServiceFlag) []string {
+	names := make([]string, 0, len(serviceFlagNames))
+	for _, sf := range serviceFlagNames {
+		if services&sf.flag == sf.flag {
+			names = append(names, sf.name)
+			services &^= sf.flag
+		}
+	}
+	for bit := uint(0); bit < 64; bit++ {
+		if services&(1<<bit) != 0 {
+			names = append(names, fmt.Sprintf("UNKNOWN[%d]", bit))
+		}
+	}
+	return names
+}
+
+// networksInfo returns the reachability and proxy of each type of network the
+// server is able to connect to.
+func networksInfo() []btcjson.NetworksResult {
+	onionProxy := cfg.OnionProxy
+	if onionProxy == "" {
+		onionProxy = cfg.Proxy
+	}
+	if cfg.NoOnion {
+		onionProxy = ""
+	}
+
+	networks := []struct {
+		name      string
+		reachable bool
+		proxy     string
+	}{
+		{"ipv4", true, cfg.Proxy},
+		{"ipv6", true, cfg.Proxy},
+		{"onion", onionProxy != "", onionProxy},
+	}
+	results := make([]btcjson.NetworksResult, 0, len(networks))
+	for _, network := range networks {
+		results = append(results, btcjson.NetworksResult{
+			Name:      network.name,
+			Limited:   !network.reachable,
+			Reachable: network.reachable,
+			Proxy:     network.proxy,
+			ProxyRandomizeCredentials: cfg.TorIsolation &&
+				network.proxy != "",
+		})
+	}
+	return results
+}
+
+// handleGetNetworkInfo implements the getnetworkinfo command.
+func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	var connectionsIn, connectionsOut int32
+	for _, p := range s.cfg.ConnMgr.ConnectedPeers() {
+		if p.ToPeer().Inbound() {
+			connectionsIn++
+		} else {
+			connectionsOut++
+		}
+	}
+
+	localAddrs := s.cfg.ConnMgr.LocalAddresses()
+	localAddresses := make([]btcjson.LocalAddressesResult, 0, len(localAddrs))
+	for _, la := range localAddrs {
+		// The key of the address is used to obtain the host since it
+		// properly encodes onion addresses.
+		host, _, err := net.SplitHostPort(addrmgr.NetAddressKey(la.NetAddress))
+		if err != nil {
+			continue
+		}
+		localAddresses = append(localAddresses, btcjson.LocalAddressesResult{
+			Address: host,
+			Port:    la.NetAddress.Port,
+			Score:   int32(la.Score),
+		})
+	}
+
+	// The user agent is built the same way as the one advertised to peers.
+	versionMsg := wire.MsgVersion{UserAgent: wire.DefaultUserAgent}
+	err := versionMsg.AddUserAgent(userAgentName, userAgentVersion,
+		cfg.UserAgentComments...)
+	if err != nil {
+		return nil, internalRPCError(err.Error(), "Invalid user agent")
+	}
+
+	minRelayTxFee := cfg.minRelayTxFee.ToBTC()
+	ret := &btcjson.GetNetworkInfoResult{
+		Version:            int32(1000000*appMajor + 10000*appMinor + 100*appPatch),
+		SubVersion:         versionMsg.UserAgent,
+		ProtocolVersion:    int32(maxProtocolVersion),
+		LocalServices:      fmt.Sprintf("%016x", uint64(s.cfg.Services)),
+		LocalServicesNames: serviceNames(s.cfg.Services),
+		LocalRelay:         !cfg.BlocksOnly,
+		TimeOffset:         int64(s.cfg.TimeSource.Offset().Seconds()),
+		Connections:        connectionsIn + connectionsOut,
+		ConnectionsIn:      connectionsIn,
+		ConnectionsOut:     connectionsOut,
+		NetworkActive:      true,
+		Networks:           networksInfo(),
+		RelayFee:           minRelayTxFee,
+		IncrementalFee:     minRelayTxFee,
+		LocalAddresses:     localAddresses,
+		Warnings:           s.cfg.Chain.Warnings(),
+	}
+
+	return ret, nil
+}
+
 // handleGetNodeAddresses implements the getnodeaddresses command.
 func handleGetNodeAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetNodeAddressesCmd)
@@ -2517,6 +2810,7 @@ func handleGetNodeAddresses(s *rpcServer, cmd interface{}, closeChan <-chan stru
 func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	peers := s.cfg.ConnMgr.ConnectedPeers()
 	syncPeerID := s.cfg.SyncMgr.SyncPeerID()
+	syncStats := s.cfg.SyncMgr.PeerSyncStats()
 	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
 	for _, p := range peers {
 		statsSnap := p.ToPeer().StatsSnapshot()
@@ -2536,12 +2830,23 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			Version:        statsSnap.Version,
 			SubVer:         statsSnap.UserAgent,
 			Inbound:        statsSnap.Inbound,
+			ConnectionType: p.ConnectionType(),
 			StartingHeight: statsSnap.StartingHeight,
 			CurrentHeight:  statsSnap.LastBlock,
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			InFlight:       []int32{},
+		}
+		if stats, ok := syncStats[statsSnap.ID]; ok {
+			info.InFlight = stats.InFlightHeights
+			info.BlocksReceived = stats.BlocksReceived
+			info.HeadersReceived = stats.HeadersReceived
+			info.BlockRate = stats.BlockRate
+			info.HeaderRate = stats.HeaderRate
 		}
+		info.BytesSentPerMsg, info.BytesRecvPerMsg, info.MsgsSentPerMsg,
+			info.MsgsRecvPerMsg = msgTrafficPerMsg(statsSnap.MsgTraffic)
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
 			// We actually want microseconds.
@@ -2557,10 +2862,34 @@ func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 	c := cmd.(*btcjson.GetRawMempoolCmd)
 	mp := s.cfg.TxMemPool
 
-	if c.Verbose != nil && *c.Verbose {
+	verbose := c.Verbose != nil && *c.Verbose
+	mempoolSequence := c.MempoolSequence != nil && *c.MempoolSequence
+	if verbose && mempoolSequence {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Verbose results cannot contain mempool " +
+				"sequence values",
+		}
+	}
+	if verbose {
 		return mp.RawMempoolVerbose(), nil
 	}
 
+	// Include the mempool sequence number the hashes correspond to when
+	// requested so callers can combine them with mempool sequence
+	// notifications.
+	if mempoolSequence {
+		descs, seq := mp.TxDescsAndSequence()
+		hashStrings := make([]string, len(descs))
+		for i := range hashStrings {
+			hashStrings[i] = descs[i].Tx.Hash().String()
+		}
+		return &btcjson.GetRawMempoolSequenceResult{
+			TxIDs:           hashStrings,
+			MempoolSequence: seq,
+		}, nil
+	}
+
 	// The response is simply an array of the transaction hashes if the
 	// verbose flag is not set.
 	descs := mp.TxDescs()
@@ -2766,6 +3095,19 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		isCoinbase = entry.IsCoinBase()
 	}
 
+	txOutReply := &btcjson.GetTxOutResult{
+		BestBlock:     bestBlockHash,
+		Confirmations: int64(confirmations),
+		Value:         btcutil.Amount(value).ToBTC(),
+		ScriptPubKey:  createScriptPubKeyResult(pkScript, s.cfg.ChainParams),
+		Coinbase:      isCoinbase,
+	}
+	return txOutReply, nil
+}
+
+// createScriptPubKeyResult returns the JSON description of the passed public
+// key script.
+func createScriptPubKeyResult(pkScript []byte, chainParams *chaincfg.Params) btcjson.ScriptPubKeyResult {
 	// Disassemble script into single line printable format.
 	// The disassembled string will contain [error] inline if the script
 	// doesn't fully parse, so ignore the error here.
@@ -2775,26 +3117,19 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	// Ignore the error here since an error means the script couldn't parse
 	// and there is no additional information about it anyways.
 	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		s.cfg.ChainParams)
+		chainParams)
 	addresses := make([]string, len(addrs))
 	for i, addr := range addrs {
 		addresses[i] = addr.EncodeAddress()
 	}
 
-	txOutReply := &btcjson.GetTxOutResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: int64(confirmations),
-		Value:         btcutil.Amount(value).ToBTC(),
-		ScriptPubKey: btcjson.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+	return btcjson.ScriptPubKeyResult{
+		Asm:       disbuf,
+		Hex:       hex.EncodeToString(pkScript),
+		ReqSigs:   int32(reqSigs),
+		Type:      scriptClass.String(),
+		Addresses: addresses,
 	}
-	return txOutReply, nil
 }
 
 // handleHelp implements the help command.
@@ -2836,6 +3171,63 @@ func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return help, nil
 }
 
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	banned := s.cfg.ConnMgr.BannedSubnets()
+	now := time.Now()
+	results := make([]btcjson.ListBannedResult, 0, len(banned))
+	for _, entry := range banned {
+		results = append(results, btcjson.ListBannedResult{
+			Address:       entry.Subnet.String(),
+			BanCreated:    entry.Created.Unix(),
+			BannedUntil:   entry.Until.Unix(),
+			BanDuration:   entry.Until.Unix() - entry.Created.Unix(),
+			TimeRemaining: entry.Until.Unix() - now.Unix(),
+		})
+	}
+	return results, nil
+}
+
+// handleLogging implements the logging command.
+func handleLogging(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.LoggingCmd)
+
+	// Validate all of the requested levels before changing any of them so
+	// an invalid request leaves the levels untouched.
+	if c.Levels != nil {
+		for subsysID, logLevel := range *c.Levels {
+			if _, exists := subsystemLoggers[subsysID]; !exists {
+				str := fmt.Sprintf("The specified subsystem [%v] is "+
+					"invalid -- supported subsystems %v", subsysID,
+					supportedSubsystems())
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParameter,
+					Message: str,
+				}
+			}
+			if !validLogLevel(logLevel) {
+				str := fmt.Sprintf("The specified log level [%v] "+
+					"is invalid", logLevel)
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParameter,
+					Message: str,
+				}
+			}
+		}
+		for subsysID, logLevel := range *c.Levels {
+			setLogLevel(subsysID, logLevel)
+			rpcsLog.Infof("Set log level of subsystem %s to %s",
+				subsysID, logLevel)
+		}
+	}
+
+	levels := make(map[string]string, len(subsystemLoggers))
+	for subsysID, logger := range subsystemLoggers {
+		levels[subsysID] = jsonlog.LevelName(logger.Level())
+	}
+	return levels, nil
+}
+
 // handlePing implements the ping command.
 func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Ask server to ping \o_
@@ -3070,6 +3462,16 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleRPCDiscover implements the rpc.discover command.
+func handleRPCDiscover(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	doc, err := s.helpCacher.rpcDiscover(false)
+	if err != nil {
+		context := "Failed to generate OpenRPC document"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return doc, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
@@ -3411,7 +3813,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalUnknown)
 
 		errStr := fmt.Sprintf("transaction %v is not in accepted list",
 			tx.Hash())
@@ -3436,6 +3839,76 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	return tx.Hash().String(), nil
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := connmgr.ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: "Invalid IP/Subnet: " + err.Error(),
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// The ban time is either a duration in seconds or an absolute
+		// unix time.  The configured ban duration is used when it is
+		// not specified.
+		until := time.Now().Add(cfg.BanDuration)
+		if c.BanTime != nil && *c.BanTime > 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+			} else {
+				until = time.Now().Add(time.Duration(*c.BanTime) *
+					time.Second)
+			}
+		}
+		if !until.After(time.Now()) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Ban time is in the past",
+			}
+		}
+
+		err := s.cfg.ConnMgr.Ban(subnet, until)
+		if err == connmgr.ErrAlreadyBanned {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientNodeAlreadyAdded,
+				Message: "IP/Subnet already banned",
+			}
+		}
+		if err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Failed to ban subnet")
+		}
+
+	case btcjson.SBRemove:
+		err := s.cfg.ConnMgr.Unban(subnet)
+		if err == connmgr.ErrNotBanned {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: "Unban failed: the IP/Subnet was not " +
+					"previously banned",
+			}
+		}
+		if err != nil {
+			return nil, internalRPCError(err.Error(),
+				"Failed to unban subnet")
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // handleSetGenerate implements the setgenerate command.
 func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.SetGenerateCmd)
@@ -3613,41 +4086,6 @@ func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	return result, nil
 }
 
-func verifyChain(s *rpcServer, level, depth int32) error {
-	best := s.cfg.Chain.BestSnapshot()
-	finishHeight := best.Height - depth
-	if finishHeight < 0 {
-		finishHeight = 0
-	}
-	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
-		best.Height-finishHeight, level)
-
-	for height := best.Height; height > finishHeight; height-- {
-		// Level 0 just looks up the block.
-		block, err := s.cfg.Chain.BlockByHeight(height)
-		if err != nil {
-			rpcsLog.Errorf("Verify is unable to fetch block at "+
-				"height %d: %v", height, err)
-			return err
-		}
-
-		// Level 1 does basic chain sanity checks.
-		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				s.cfg.ChainParams.PowLimit, s.cfg.TimeSource)
-			if err != nil {
-				rpcsLog.Errorf("Verify is unable to validate "+
-					"block at hash %v height %d: %v",
-					block.Hash(), height, err)
-				return err
-			}
-		}
-	}
-	rpcsLog.Infof("Chain verify completed successfully")
-
-	return nil
-}
-
 // handleVerifyChain implements the verifychain command.
 func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.VerifyChainCmd)
@@ -3660,8 +4098,19 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		checkDepth = *c.CheckDepth
 	}
 
-	err := verifyChain(s, checkLevel, checkDepth)
-	return err == nil, nil
+	err := s.cfg.Chain.VerifyChain(checkLevel, checkDepth, closeChan)
+	if err != nil {
+		if verr, ok := err.(blockchain.VerifyChainError); ok {
+			rpcsLog.Errorf("Chain verify failed: %v", verr)
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCVerify,
+				Message: verr.Error(),
+			}
+		}
+		context := "Unable to verify chain"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return true, nil
 }
 
 // handleVerifyMessage implements the verifymessage command.
@@ -3747,8 +4196,7 @@ type rpcServer struct {
 	started                int32
 	shutdown               int32
 	cfg                    rpcserverConfig
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	auth                   *rpcAuthenticator
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
 	statusLines            map[int]string
@@ -3756,6 +4204,7 @@ type rpcServer struct {
 	wg                     sync.WaitGroup
 	gbtWorkState           *gbtWorkState
 	helpCacher             *helpCacher
+	utxoScan               utxoScanState
 	requestProcessShutdown chan struct{}
 	quit                   chan int
 }
@@ -3892,57 +4341,57 @@ func (s *rpcServer) decrementClients() {
 	atomic.AddInt32(&s.numClients, -1)
 }
 
+// isUnixSocketRequest returns whether the passed request was received on a
+// Unix domain socket.
+func isUnixSocketRequest(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
 // checkAuth checks the HTTP Basic authentication supplied by a wallet
 // or RPC client in the HTTP request r.  If the supplied authentication
-// does not match the username and password expected, a non-nil error is
+// does not match the credentials of any configured user, a non-nil error is
 // returned.
 //
-// This check is time-constant.
-//
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, error) {
+// The returned user determines the methods the client is allowed to invoke.
+// It is nil when no authentication was supplied and it is not required.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (*rpcAuthUser, error) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
+		// Clients connected over a Unix domain socket are granted full
+		// access without credentials when configured to do so since
+		// access to the socket is restricted by its file mode instead.
+		if s.auth.unixUser != nil && isUnixSocketRequest(r) {
+			return s.auth.unixUser, nil
+		}
+
 		if require {
 			rpcsLog.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, errors.New("auth failure")
+			return nil, errors.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	user := s.auth.authenticate(authhdr[0])
+	if user == nil {
+		// Request's auth doesn't match any user.
+		rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
+		return nil, errors.New("auth failure")
 	}
-
-	// Request's auth doesn't match either user
-	rpcsLog.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, errors.New("auth failure")
+	return user, nil
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
 // a known concrete command along with any error that might have happened while
 // parsing it.
 type parsedRPCCmd struct {
-	id     interface{}
-	method string
-	cmd    interface{}
-	err    *btcjson.RPCError
+	jsonrpc string
+	id      interface{}
+	method  string
+	cmd     interface{}
+	err     *btcjson.RPCError
 }
 
 // standardCmdResult checks that a parsed command is a standard Bitcoin JSON-RPC
@@ -3967,6 +4416,11 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct
 	return nil, btcjson.ErrRPCMethodNotFound
 handled:
 
+	if s.cfg.RequestTimer != nil {
+		defer func(start time.Time) {
+			s.cfg.RequestTimer(cmd.method, time.Since(start))
+		}(time.Now())
+	}
 	return handler(s, cmd.cmd, closeChan)
 }
 
@@ -3976,6 +4430,7 @@ handled:
 // an unregistered command or invalid parameters.
 func parseCmd(request *btcjson.Request) *parsedRPCCmd {
 	var parsedCmd parsedRPCCmd
+	parsedCmd.jsonrpc = request.Jsonrpc
 	parsedCmd.id = request.ID
 	parsedCmd.method = request.Method
 
@@ -4002,9 +4457,10 @@ func parseCmd(request *btcjson.Request) *parsedRPCCmd {
 }
 
 // createMarshalledReply returns a new marshalled JSON-RPC response given the
-// passed parameters.  It will automatically convert errors that are not of
-// the type *btcjson.RPCError to the appropriate type as needed.
-func createMarshalledReply(id, result interface{}, replyErr error) ([]byte, error) {
+// passed parameters.  The response is in the format of the passed JSON-RPC
+// version of the request.  It will automatically convert errors that are not
+// of the type *btcjson.RPCError to the appropriate type as needed.
+func createMarshalledReply(jsonrpc string, id, result interface{}, replyErr error) ([]byte, error) {
 	var jsonErr *btcjson.RPCError
 	if replyErr != nil {
 		if jErr, ok := replyErr.(*btcjson.RPCError); ok {
@@ -4014,11 +4470,180 @@ func createMarshalledReply(id, result interface{}, replyErr error) ([]byte, erro
 		}
 	}
 
-	return btcjson.MarshalResponse(id, result, jsonErr)
+	return btcjson.MarshalVersionedResponse(jsonrpc, id, result, jsonErr)
+}
+
+// marshalErrorReply returns the marshalled reply for the passed JSON-RPC
+// version, request id, and error.  Nil is returned when the reply can't be
+// marshalled.
+func marshalErrorReply(jsonrpc string, id interface{}, jsonErr *btcjson.RPCError) []byte {
+	reply, err := createMarshalledReply(jsonrpc, id, nil, jsonErr)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal reply: %v", err)
+		return nil
+	}
+	return reply
+}
+
+// isBatchRequest returns whether the passed JSON-RPC message is a batch
+// request, which is an array of requests rather than a single request object.
+func isBatchRequest(msg []byte) bool {
+	msg = bytes.TrimLeft(msg, " \t\r\n")
+	return len(msg) > 0 && msg[0] == '['
+}
+
+// parseBatchRequest splits the passed JSON-RPC batch request into the raw
+// requests it contains.  An error suitable for the reply to the batch is
+// returned when the batch is malformed, empty, or contains more than the
+// maximum number of requests allowed by the configuration.
+func parseBatchRequest(msg []byte) ([]json.RawMessage, *btcjson.RPCError) {
+	var requests []json.RawMessage
+	if err := json.Unmarshal(msg, &requests); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCParse.Code,
+			Message: "Failed to parse batch request: " + err.Error(),
+		}
+	}
+	if len(requests) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidRequest.Code,
+			Message: "Batch request must contain at least one request",
+		}
+	}
+	if len(requests) > cfg.RPCMaxBatchSize {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidRequest.Code,
+			Message: fmt.Sprintf("Batch request contains %d "+
+				"requests which exceeds the maximum of %d",
+				len(requests), cfg.RPCMaxBatchSize),
+		}
+	}
+	return requests, nil
+}
+
+// parseBatchElement parses a single raw request of a batch request.  The
+// marshalled error reply for the element is returned instead when it is not a
+// valid request object.
+func parseBatchElement(rawRequest json.RawMessage) (*btcjson.Request, []byte) {
+	var request btcjson.Request
+	if err := json.Unmarshal(rawRequest, &request); err != nil {
+		jsonErr := &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidRequest.Code,
+			Message: "Failed to parse request: " + err.Error(),
+		}
+		return nil, marshalErrorReply("", nil, jsonErr)
+	}
+	return &request, nil
+}
+
+// marshalBatchReply combines the passed marshalled replies into the reply to
+// a batch request.  Nil is returned when there are no replies to send, which
+// is the case when the batch only consists of notifications.
+func marshalBatchReply(replies []json.RawMessage) []byte {
+	if len(replies) == 0 {
+		return nil
+	}
+	msg, err := json.Marshal(replies)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal batch reply: %v", err)
+		return nil
+	}
+	return msg
+}
+
+// isNotification returns whether the passed request is a notification, which
+// must not be responded to.
+//
+// The JSON-RPC 1.0 spec defines that notifications must have their "id"
+// set to null and states that notifications do not have a response.
+//
+// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
+// without an "id" member. The specification states that notifications
+// must not be responded to. JSON-RPC 2.0 permits the null value as a
+// valid request id, therefore such requests are not notifications.
+//
+// Bitcoin Core serves requests with "id":null or even an absent "id",
+// and responds to such requests with "id":null in the response.
+//
+// Btcd does not respond to any request without and "id" or "id":null,
+// regardless the indicated JSON-RPC protocol version unless RPC quirks
+// are enabled. With RPC quirks enabled, such requests will be responded
+// to if the reqeust does not indicate JSON-RPC version.
+//
+// RPC quirks can be enabled by the user to avoid compatibility issues
+// with software relying on Core's behavior.
+func isNotification(request *btcjson.Request) bool {
+	return request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "")
+}
+
+// processRequest executes the passed JSON-RPC request from an HTTP POST client
+// and returns the marshalled reply.  Nil is returned when there is nothing to
+// send, such as for notifications.
+func (s *rpcServer) processRequest(request *btcjson.Request, user *rpcAuthUser, closeChan <-chan struct{}) []byte {
+	if isNotification(request) {
+		return nil
+	}
+
+	// Set error if the user is not authorized to call this method.
+	var result interface{}
+	var jsonErr error
+	if rpcErr := user.checkMethod(request.Method); rpcErr != nil {
+		jsonErr = rpcErr
+	}
+
+	if jsonErr == nil {
+		// Attempt to parse the JSON-RPC request into a known concrete
+		// command.
+		parsedCmd := parseCmd(request)
+		if parsedCmd.err != nil {
+			jsonErr = parsedCmd.err
+		} else {
+			result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
+		}
+	}
+
+	reply, err := createMarshalledReply(request.Jsonrpc, request.ID, result,
+		jsonErr)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal reply: %v", err)
+		return nil
+	}
+	return reply
+}
+
+// processBatchRequest executes each request of the passed JSON-RPC batch
+// request from an HTTP POST client in order and returns the marshalled array
+// of their replies.  Each request is subject to the same authorization checks
+// as a single request.  Nil is returned when there is nothing to send.
+func (s *rpcServer) processBatchRequest(body []byte, user *rpcAuthUser, closeChan <-chan struct{}) []byte {
+	requests, jsonErr := parseBatchRequest(body)
+	if jsonErr != nil {
+		return marshalErrorReply("", nil, jsonErr)
+	}
+
+	replies := make([]json.RawMessage, 0, len(requests))
+	for _, rawRequest := range requests {
+		// Stop executing the remaining requests once the client
+		// disconnected since the reply can no longer be delivered.
+		select {
+		case <-closeChan:
+			return nil
+		default:
+		}
+
+		request, reply := parseBatchElement(rawRequest)
+		if request != nil {
+			reply = s.processRequest(request, user, closeChan)
+		}
+		if reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	return marshalBatchReply(replies)
 }
 
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, user *rpcAuthUser) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
@@ -4058,80 +4683,35 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 	defer buf.Flush()
 	conn.SetReadDeadline(timeZeroVal)
 
-	// Attempt to parse the raw body into a JSON-RPC request.
-	var responseID interface{}
-	var jsonErr error
-	var result interface{}
-	var request btcjson.Request
-	if err := json.Unmarshal(body, &request); err != nil {
-		jsonErr = &btcjson.RPCError{
-			Code:    btcjson.ErrRPCParse.Code,
-			Message: "Failed to parse request: " + err.Error(),
-		}
-	}
-	if jsonErr == nil {
-		// The JSON-RPC 1.0 spec defines that notifications must have their "id"
-		// set to null and states that notifications do not have a response.
-		//
-		// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
-		// without an "id" member. The specification states that notifications
-		// must not be responded to. JSON-RPC 2.0 permits the null value as a
-		// valid request id, therefore such requests are not notifications.
-		//
-		// Bitcoin Core serves requests with "id":null or even an absent "id",
-		// and responds to such requests with "id":null in the response.
-		//
-		// Btcd does not respond to any request without and "id" or "id":null,
-		// regardless the indicated JSON-RPC protocol version unless RPC quirks
-		// are enabled. With RPC quirks enabled, such requests will be responded
-		// to if the reqeust does not indicate JSON-RPC version.
-		//
-		// RPC quirks can be enabled by the user to avoid compatibility issues
-		// with software relying on Core's behavior.
-		if request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "") {
-			return
-		}
-
-		// The parse was at least successful enough to have an ID so
-		// set it for the response.
-		responseID = request.ID
-
-		// Setup a close notifier.  Since the connection is hijacked,
-		// the CloseNotifer on the ResponseWriter is not available.
-		closeChan := make(chan struct{}, 1)
-		go func() {
-			_, err := conn.Read(make([]byte, 1))
-			if err != nil {
-				close(closeChan)
-			}
-		}()
-
-		// Check if the user is limited and set error if method unauthorized
-		if !isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr = &btcjson.RPCError{
-					Code:    btcjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-			}
+	// Setup a close notifier.  Since the connection is hijacked,
+	// the CloseNotifer on the ResponseWriter is not available.
+	closeChan := make(chan struct{}, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		if err != nil {
+			close(closeChan)
 		}
+	}()
 
-		if jsonErr == nil {
-			// Attempt to parse the JSON-RPC request into a known concrete
-			// command.
-			parsedCmd := parseCmd(&request)
-			if parsedCmd.err != nil {
-				jsonErr = parsedCmd.err
-			} else {
-				result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
+	// Execute the request, or each request of a batch request, and
+	// marshal the response.  There is nothing to send when the request
+	// only consists of notifications.
+	var msg []byte
+	if isBatchRequest(body) {
+		msg = s.processBatchRequest(body, user, closeChan)
+	} else {
+		var request btcjson.Request
+		if err := json.Unmarshal(body, &request); err != nil {
+			jsonErr := &btcjson.RPCError{
+				Code:    btcjson.ErrRPCParse.Code,
+				Message: "Failed to parse request: " + err.Error(),
 			}
+			msg = marshalErrorReply("", nil, jsonErr)
+		} else {
+			msg = s.processRequest(&request, user, closeChan)
 		}
 	}
-
-	// Marshal the response.
-	msg, err := createMarshalledReply(responseID, result, jsonErr)
-	if err != nil {
-		rpcsLog.Errorf("Failed to marshal reply: %v", err)
+	if msg == nil {
 		return
 	}
 
@@ -4185,19 +4765,19 @@ func (s *rpcServer) Start() {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
-		_, isAdmin, err := s.checkAuth(r, true)
+		user, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, user)
 	})
 
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
+		user, err := s.checkAuth(r, false)
 		if err != nil {
 			jsonAuthFail(w)
 			return
@@ -4214,7 +4794,7 @@ func (s *rpcServer) Start() {
 			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
 			return
 		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
+		s.WebsocketHandler(ws, r.RemoteAddr, user)
 	})
 
 	for _, listener := range s.cfg.Listeners {
@@ -4273,6 +4853,10 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// ConnectionType returns a human-readable description of the type of
+	// connection to the peer.
+	ConnectionType() string
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4315,6 +4899,14 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// MsgTraffic returns the messages and bytes sent and received across
+	// all peers keyed by message command.
+	MsgTraffic() map[string]peer.MsgTraffic
+
+	// UploadTarget returns the state of the upload target for the current
+	// cycle.
+	UploadTarget() *uploadTargetStats
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
@@ -4338,6 +4930,35 @@ type rpcserverConnManager interface {
 	// NodeAddresses returns an array consisting node addresses which can
 	// potentially be used to find new nodes in the network.
 	NodeAddresses() []*wire.NetAddress
+
+	// LocalAddresses returns the local addresses advertised to peers along
+	// with their scores.
+	LocalAddresses() []addrmgr.LocalAddress
+
+	// Ban bans the provided subnet until the provided time and disconnects
+	// any connected peers within it.  connmgr.ErrAlreadyBanned is returned
+	// when the subnet is already banned.
+	Ban(subnet *net.IPNet, until time.Time) error
+
+	// Unban removes the ban on the provided subnet.  connmgr.ErrNotBanned
+	// is returned when the subnet is not banned.
+	Unban(subnet *net.IPNet) error
+
+	// ClearBanned removes all bans.
+	ClearBanned() error
+
+	// BannedSubnets returns the subnets that are currently banned.
+	BannedSubnets() []connmgr.BanEntry
+}
+
+// rpcserverPeerSyncStats describes the blocks and headers the sync manager has
+// requested from and received from a peer.
+type rpcserverPeerSyncStats struct {
+	InFlightHeights []int32
+	BlocksReceived  uint64
+	HeadersReceived uint64
+	BlockRate       float64
+	HeaderRate      float64
 }
 
 // rpcserverSyncManager represents a sync manager for use with the RPC server.
@@ -4360,6 +4981,10 @@ type rpcserverSyncManager interface {
 	// used to sync from or 0 if there is none.
 	SyncPeerID() int32
 
+	// PeerSyncStats returns the sync statistics of all peers known to the
+	// sync manager keyed by their ID.
+	PeerSyncStats() map[int32]*rpcserverPeerSyncStats
+
 	// LocateHeaders returns the headers of the blocks after the first known
 	// block in the provided locators until the provided stop hash or the
 	// current tip is reached, up to a max of wire.MaxBlockHeadersPerMsg
@@ -4395,6 +5020,9 @@ type rpcserverConfig struct {
 	ChainParams *chaincfg.Params
 	DB          database.DB
 
+	// Services defines the services the server advertises to peers.
+	Services wire.ServiceFlag
+
 	// TxMemPool defines the transaction memory pool to interact with.
 	TxMemPool *mempool.TxPool
 
@@ -4415,6 +5043,13 @@ type rpcserverConfig struct {
 	// The fee estimator keeps track of how long transactions are left in
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
+
+	// RequestTimer, when set, is invoked with the time spent handling each
+	// request for a known method.
+	RequestTimer func(method string, elapsed time.Duration)
+
+	// DiskUsage provides the size of the block database on disk.
+	DiskUsage *diskUsageCache
 }
 
 // newRPCServer returns a new instance of the rpcServer struct.
@@ -4427,18 +5062,14 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, error) {
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
 	}
-	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
-	}
-	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+	auth, err := newRPCAuthenticator(cfg)
+	if err != nil {
+		return nil, err
 	}
+	rpc.auth = auth
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
 	rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)
+	rpc.cfg.TxMemPool.Subscribe(rpc.handleMempoolNotification)
 
 	return &rpc, nil
 }
@@ -4468,6 +5099,12 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients of incoming block.
 		s.ntfnMgr.NotifyBlockConnected(block)
+		s.ntfnMgr.NotifyMempoolSequence(&btcjson.MempoolSequenceEvent{
+			Type:            btcjson.MempoolSequenceBlockConnected,
+			Hash:            block.Hash().String(),
+			Height:          block.Height(),
+			MempoolSequence: s.cfg.TxMemPool.Sequence(),
+		})
 
 	case blockchain.NTBlockDisconnected:
 		block, ok := notification.Data.(*btcutil.Block)
@@ -4478,7 +5115,36 @@ func (s *rpcServer) handleBlockchainNotification(notification *blockchain.Notifi
 
 		// Notify registered websocket clients.
 		s.ntfnMgr.NotifyBlockDisconnected(block)
+		s.ntfnMgr.NotifyMempoolSequence(&btcjson.MempoolSequenceEvent{
+			Type:            btcjson.MempoolSequenceBlockDisconnected,
+			Hash:            block.Hash().String(),
+			Height:          block.Height(),
+			MempoolSequence: s.cfg.TxMemPool.Sequence(),
+		})
+	}
+}
+
+// handleMempoolNotification is the callback for notifications from the
+// mempool.  It notifies websocket clients that registered for mempool sequence
+// notifications.  It is invoked with the mempool lock held, so the events are
+// queued in the order they happened.
+func (s *rpcServer) handleMempoolNotification(notification *mempool.Notification) {
+	event := &btcjson.MempoolSequenceEvent{
+		Hash:            notification.Tx.Hash().String(),
+		MempoolSequence: notification.Sequence,
+	}
+	switch notification.Type {
+	case mempool.NTTxAccepted:
+		event.Type = btcjson.MempoolSequenceTxAdded
+
+	case mempool.NTTxRemoved:
+		event.Type = btcjson.MempoolSequenceTxRemoved
+		event.Reason = notification.Reason.String()
+
+	default:
+		return
 	}
+	s.ntfnMgr.NotifyMempoolSequence(event)
 }
 
 func init() {
@@ -0,0 +1,48 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestDiskUsageCache ensures the size of a directory is cached until it is
+// older than the refresh interval.
+func TestDiskUsageCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diskusage")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name string, size int) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatalf("WriteFile: unexpected error: %v", err)
+		}
+	}
+
+	writeFile("a", 100)
+	c := newDiskUsageCache(dir)
+	if got := c.Size(); got != 100 {
+		t.Fatalf("unexpected size -- got %d, want 100", got)
+	}
+
+	// The cached size is returned until it expires.
+	writeFile("b", 50)
+	if got := c.Size(); got != 100 {
+		t.Fatalf("unexpected cached size -- got %d, want 100", got)
+	}
+	c.updated = time.Now().Add(-diskUsageRefreshInterval)
+	if got := c.Size(); got != 150 {
+		t.Fatalf("unexpected size after refresh -- got %d, want 150",
+			got)
+	}
+}
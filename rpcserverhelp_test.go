@@ -4,7 +4,12 @@
 
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/internal/rpchelp"
+)
 
 // TestHelp ensures the help is reasonably accurate by checking that every
 // command specified also has result types defined and the one-line usage and
@@ -12,7 +17,7 @@ import "testing"
 func TestHelp(t *testing.T) {
 	// Ensure there are result types specified for every handler.
 	for k := range rpcHandlers {
-		if _, ok := rpcResultTypes[k]; !ok {
+		if _, ok := rpchelp.ResultTypes[k]; !ok {
 			t.Errorf("RPC handler defined for method '%v' without "+
 				"also specifying result types", k)
 			continue
@@ -20,7 +25,7 @@ func TestHelp(t *testing.T) {
 
 	}
 	for k := range wsHandlers {
-		if _, ok := rpcResultTypes[k]; !ok {
+		if _, ok := rpchelp.ResultTypes[k]; !ok {
 			t.Errorf("RPC handler defined for method '%v' without "+
 				"also specifying result types", k)
 			continue
@@ -63,3 +68,48 @@ func TestHelp(t *testing.T) {
 		}
 	}
 }
+
+// TestRPCDiscover ensures an OpenRPC document describing every command can be
+// generated without errors.
+func TestRPCDiscover(t *testing.T) {
+	helpCacher := newHelpCacher()
+	for _, includeWebsockets := range []bool{false, true} {
+		doc, err := helpCacher.rpcDiscover(includeWebsockets)
+		if err != nil {
+			t.Fatalf("Failed to generate OpenRPC document: %v", err)
+		}
+
+		numMethods := len(rpcHandlers)
+		if includeWebsockets {
+			for k := range wsHandlers {
+				if _, ok := rpcHandlers[k]; !ok {
+					numMethods++
+				}
+			}
+		}
+		if len(doc.Methods) != numMethods {
+			t.Errorf("OpenRPC document describes %d methods, want %d",
+				len(doc.Methods), numMethods)
+		}
+
+		// The offline generator must describe the same methods.
+		methods := make([]string, 0, len(doc.Methods))
+		for i := range doc.Methods {
+			methods = append(methods, doc.Methods[i].Name)
+		}
+		offline := rpchelp.Methods(includeWebsockets)
+		if !reflect.DeepEqual(offline, methods) {
+			t.Errorf("offline OpenRPC methods do not match the "+
+				"handlers -- got %v, want %v", offline, methods)
+		}
+
+		cached, err := helpCacher.rpcDiscover(includeWebsockets)
+		if err != nil {
+			t.Fatalf("Failed to generate OpenRPC document (cached): %v",
+				err)
+		}
+		if cached != doc {
+			t.Error("OpenRPC document was not cached")
+		}
+	}
+}
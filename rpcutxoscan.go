@@ -0,0 +1,179 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+const (
+	// utxoScanInterruptInterval is the number of utxos visited by a
+	// scantxoutset scan in between checks whether it should be aborted.
+	utxoScanInterruptInterval = 10000
+)
+
+// errUtxoScanAborted indicates a scantxoutset scan was aborted before it
+// visited the entire utxo set.
+var errUtxoScanAborted = errors.New("utxo set scan aborted")
+
+// utxoScanState houses the state of the scantxoutset scan in progress, if
+// any, which is shared by the invocations to start, abort, and query the
+// status of a scan.  Only a single scan may run at a time.
+type utxoScanState struct {
+	sync.Mutex
+	inProgress bool
+	abort      chan struct{}
+
+	// progress is the estimated percentage of the utxo set the scan in
+	// progress has visited.  It must be accessed atomically.
+	progress int32
+}
+
+// utxoScanScripts returns the output scripts described by the passed scan
+// objects of the scantxoutset command keyed by the script along with the
+// descriptor of each individual script.
+func utxoScanScripts(s *rpcServer, scanObjects []btcjson.ScanObject) (map[string]string, error) {
+	outputs, err := expandScanObjects(s, scanObjects)
+	if err != nil {
+		return nil, err
+	}
+	scripts := make(map[string]string, len(outputs))
+	for _, output := range outputs {
+		scripts[string(output.PkScript)] = output.Descriptor
+	}
+	return scripts, nil
+}
+
+// handleScanTxOutSet implements the scantxoutset command.
+func handleScanTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+	state := &s.utxoScan
+
+	switch c.Action {
+	case btcjson.STOStatus:
+		state.Lock()
+		defer state.Unlock()
+		if !state.inProgress {
+			return nil, nil
+		}
+		return &btcjson.ScanTxOutSetStatusResult{
+			Progress: atomic.LoadInt32(&state.progress),
+		}, nil
+
+	case btcjson.STOAbort:
+		state.Lock()
+		defer state.Unlock()
+		if !state.inProgress || state.abort == nil {
+			return false, nil
+		}
+		close(state.abort)
+		state.abort = nil
+		return true, nil
+
+	case btcjson.STOStart:
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Invalid action %q", c.Action),
+		}
+	}
+
+	if c.ScanObjects == nil {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "The scanobjects argument is required for the " +
+				"start action",
+		}
+	}
+	scripts, err := utxoScanScripts(s, *c.ScanObjects)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reserve the scan and release it once done.
+	state.Lock()
+	if state.inProgress {
+		state.Unlock()
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Scan already in progress, use action " +
+				"\"abort\" or \"status\"",
+		}
+	}
+	abort := make(chan struct{})
+	state.inProgress = true
+	state.abort = abort
+	atomic.StoreInt32(&state.progress, 0)
+	state.Unlock()
+	defer func() {
+		state.Lock()
+		state.inProgress = false
+		state.abort = nil
+		state.Unlock()
+	}()
+
+	// The utxos are visited in order of their transaction hash, so the
+	// progress is estimated from its two leading bytes.
+	var txOuts int64
+	var totalAmount btcutil.Amount
+	unspents := make([]btcjson.ScanTxOutSetUnspent, 0)
+	hash, height, err := s.cfg.Chain.ScanUtxoSet(func(outpoint wire.OutPoint, entry *blockchain.UtxoEntry) error {
+		txOuts++
+		if txOuts%utxoScanInterruptInterval == 0 {
+			select {
+			case <-abort:
+				return errUtxoScanAborted
+			case <-closeChan:
+				return errUtxoScanAborted
+			case <-s.quit:
+				return errUtxoScanAborted
+			default:
+			}
+		}
+		high := int32(outpoint.Hash[0])<<8 | int32(outpoint.Hash[1])
+		atomic.StoreInt32(&state.progress, high*100/65536)
+
+		desc, ok := scripts[string(entry.PkScript())]
+		if !ok {
+			return nil
+		}
+		totalAmount += btcutil.Amount(entry.Amount())
+		unspents = append(unspents, btcjson.ScanTxOutSetUnspent{
+			TxID:         outpoint.Hash.String(),
+			Vout:         outpoint.Index,
+			ScriptPubKey: hex.EncodeToString(entry.PkScript()),
+			Descriptor:   desc,
+			Amount:       btcutil.Amount(entry.Amount()).ToBTC(),
+			Coinbase:     entry.IsCoinBase(),
+			Height:       entry.BlockHeight(),
+		})
+		return nil
+	})
+	if err != nil && err != errUtxoScanAborted {
+		context := "Failed to scan the utxo set"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := &btcjson.ScanTxOutSetResult{
+		Success:     err == nil,
+		TxOuts:      txOuts,
+		Unspents:    unspents,
+		TotalAmount: totalAmount.ToBTC(),
+	}
+	if hash != nil {
+		result.Height = height
+		result.BestBlock = hash.String()
+	}
+	return result, nil
+}
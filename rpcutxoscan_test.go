@@ -0,0 +1,207 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestUtxoScanScripts ensures the scan objects of the scantxoutset command are
+// expanded to the expected output scripts and that invalid descriptors are
+// rejected.
+func TestUtxoScanScripts(t *testing.T) {
+	t.Parallel()
+
+	// The secp256k1 generator point as public key along with its private
+	// key and addresses.
+	const pubKey = "0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959" +
+		"f2815b16f81798"
+	const wif = "KwDiBf89QgGbjEhKnhXJuH7LrciVrZi3qYjgd9M7rFU73sVHnoWn"
+	const p2pkh = "1BgGZ9tcN4rm9KBzDn7KprQz87SZ26SAMH"
+	const p2wpkh = "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+	const p2shp2wpkh = "3JvL6Ymt8MVWiCNHC7oWU6nLeHNJKLZGLN"
+	p2pk := "21" + pubKey + "ac"
+
+	// The master key of BIP0032 test vector 1.
+	const xprv = "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jP" +
+		"PqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+
+	// addrScript returns the output script of the passed address.
+	params := &chaincfg.MainNetParams
+	addrScript := func(addr string) string {
+		decoded, err := btcutil.DecodeAddress(addr, params)
+		if err != nil {
+			t.Fatalf("DecodeAddress(%s): unexpected error: %v", addr, err)
+		}
+		script, err := txscript.PayToAddrScript(decoded)
+		if err != nil {
+			t.Fatalf("PayToAddrScript(%s): unexpected error: %v", addr,
+				err)
+		}
+		return string(script)
+	}
+	p2pkBytes, _ := hex.DecodeString(p2pk)
+
+	tests := []struct {
+		name       string
+		objects    []btcjson.ScanObject
+		scripts    map[string]string
+		numScripts int
+		valid      bool
+	}{
+		{
+			name: "addr and raw",
+			objects: []btcjson.ScanObject{
+				{Descriptor: "addr(" + p2pkh + ")"},
+				{Descriptor: "raw(" + p2pk + ")#nprh0rpt"},
+			},
+			scripts: map[string]string{
+				addrScript(p2pkh): "addr(" + p2pkh + ")#45hf9yxk",
+				string(p2pkBytes): "raw(" + p2pk + ")#nprh0rpt",
+			},
+			valid: true,
+		},
+		{
+			name: "combo with key origin",
+			objects: []btcjson.ScanObject{
+				{Descriptor: "combo([d34db33f/0'/1]" + pubKey + ")"},
+			},
+			scripts: map[string]string{
+				string(p2pkBytes):      "pk([d34db33f/0'/1]" + pubKey + ")#mwy8qqdw",
+				addrScript(p2pkh):      "pkh([d34db33f/0'/1]" + pubKey + ")#zhuts2de",
+				addrScript(p2wpkh):     "wpkh([d34db33f/0'/1]" + pubKey + ")#5h63w9f2",
+				addrScript(p2shp2wpkh): "sh(wpkh([d34db33f/0'/1]" + pubKey + "))#2eftppcf",
+			},
+			valid: true,
+		},
+		{
+			name: "private key",
+			objects: []btcjson.ScanObject{
+				{Descriptor: "sh(wpkh(" + wif + "))"},
+			},
+			scripts: map[string]string{
+				addrScript(p2shp2wpkh): "sh(wpkh(" + pubKey + "))#jqtwwlah",
+			},
+			valid: true,
+		},
+		{
+			name:    "bad checksum",
+			objects: []btcjson.ScanObject{{Descriptor: "pkh(" + pubKey + ")#00000000"}},
+		},
+		{
+			name:    "address for other network",
+			objects: []btcjson.ScanObject{{Descriptor: "addr(mkmZxiEcEd8ZqjQWVZuC6so5dFMKEFpN2j)"}},
+		},
+		{
+			name: "ranged extended key",
+			objects: []btcjson.ScanObject{{
+				Descriptor: "pkh(" + xprv + "/0'/1/2'/*)",
+				Range:      &btcjson.DescriptorRange{Value: []int{1, 2}},
+			}},
+			scripts: map[string]string{
+				addrScript("1GHMdVjsUnGdvUi24WshYJ5brKYxyq97NR"): "pkh([" +
+					"3442193e/0'/1/2'/1]0226fff554e8aa3639067bcffed63" +
+					"b87f0b8d07bc2d2825921bbc7086ef1610e6a)#jhx7tj4z",
+				addrScript("1LjmJcdPnDHhNTUgrWyhLGnRDKxQjoxAgt"): "pkh([" +
+					"3442193e/0'/1/2'/2]02e8445082a72f29b75ca48748a91" +
+					"4df60622a609cacfce8ed0e35804560741d29)#ah5aks9l",
+			},
+			valid: true,
+		},
+		{
+			name: "ranged extended key without range",
+			objects: []btcjson.ScanObject{{
+				Descriptor: "wpkh(" + xprv + "/0'/1/2'/*)",
+			}},
+			numScripts: defaultDescriptorRangeEnd + 1,
+			valid:      true,
+		},
+		{
+			name: "range too large",
+			objects: []btcjson.ScanObject{{
+				Descriptor: "pkh(" + xprv + "/0'/1/2'/*)",
+				Range: &btcjson.DescriptorRange{
+					Value: maxDescriptorRangeSize,
+				},
+			}},
+		},
+		{
+			name:    "unsupported descriptor",
+			objects: []btcjson.ScanObject{{Descriptor: "tr(" + pubKey + ")"}},
+		},
+		{
+			name: "uncompressed witness key",
+			objects: []btcjson.ScanObject{{Descriptor: "wpkh(0479be667ef9" +
+				"dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798" +
+				"483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d0" +
+				"8ffb10d4b8)"}},
+		},
+		{
+			name: "range for unranged descriptor",
+			objects: []btcjson.ScanObject{{
+				Descriptor: "pkh(" + pubKey + ")",
+				Range:      &btcjson.DescriptorRange{Value: 10},
+			}},
+		},
+	}
+
+	s := &rpcServer{cfg: rpcserverConfig{ChainParams: params}}
+	for _, test := range tests {
+		scripts, err := utxoScanScripts(s, test.objects)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		numScripts := test.numScripts
+		if test.scripts != nil {
+			numScripts = len(test.scripts)
+		}
+		if len(scripts) != numScripts {
+			t.Errorf("%s: unexpected number of scripts - got %d, "+
+				"want %d", test.name, len(scripts), numScripts)
+			continue
+		}
+		for script, desc := range test.scripts {
+			if scripts[script] != desc {
+				t.Errorf("%s: unexpected descriptor for script "+
+					"%x - got %q, want %q", test.name, script,
+					scripts[script], desc)
+			}
+		}
+	}
+}
+
+// TestScanTxOutSetIdle ensures the status and abort actions of the
+// scantxoutset command report that no scan is in progress when idle.
+func TestScanTxOutSetIdle(t *testing.T) {
+	t.Parallel()
+
+	s := &rpcServer{}
+	status := btcjson.NewScanTxOutSetCmd(btcjson.STOStatus, nil)
+	result, err := handleScanTxOutSet(s, status, nil)
+	if err != nil || result != nil {
+		t.Fatalf("status: unexpected result %v (%v)", result, err)
+	}
+
+	abort := btcjson.NewScanTxOutSetCmd(btcjson.STOAbort, nil)
+	result, err = handleScanTxOutSet(s, abort, nil)
+	if err != nil || result != false {
+		t.Fatalf("abort: unexpected result %v (%v)", result, err)
+	}
+
+	start := btcjson.NewScanTxOutSetCmd(btcjson.STOStart, nil)
+	_, err = handleScanTxOutSet(s, start, nil)
+	if jerr, ok := err.(*btcjson.RPCError); !ok ||
+		jerr.Code != btcjson.ErrRPCInvalidParameter {
+
+		t.Fatalf("start: unexpected error %v", err)
+	}
+}
@@ -8,8 +8,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -66,16 +64,19 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifymempoolsequence":     handleNotifyMempoolSequence,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifymempoolsequence": handleStopNotifyMempoolSequence,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
 	"rescan":                    handleRescan,
 	"rescanblocks":              handleRescanBlocks,
+	"rpc.discover":              handleWebsocketRPCDiscover,
 }
 
 // WebsocketHandler handles a new websocket client by creating a new wsClient,
@@ -84,7 +85,7 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	user *rpcAuthUser) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -103,7 +104,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, user)
 	if err != nil {
 		rpcsLog.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -246,6 +247,21 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolSequence passes an event describing a change to the memory pool
+// or the best chain to the notification manager for delivery to the clients
+// that registered for mempool sequence notifications.  The events must be
+// passed in the order they happened.
+func (m *wsNotificationManager) NotifyMempoolSequence(event *btcjson.MempoolSequenceEvent) {
+	// As NotifyMempoolSequence will be called by the mempool and block
+	// manager and the RPC server may no longer be running, use a select
+	// statement to unblock enqueuing the notification once the RPC server
+	// has begun shutting down.
+	select {
+	case m.queueNotification <- (*notificationMempoolSequence)(event):
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -451,6 +467,7 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *btcutil.Tx
 }
+type notificationMempoolSequence btcjson.MempoolSequenceEvent
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -459,6 +476,8 @@ type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
+type notificationRegisterMempoolSequence wsClient
+type notificationUnregisterMempoolSequence wsClient
 type notificationRegisterSpent struct {
 	wsc *wsClient
 	ops []*wire.OutPoint
@@ -491,6 +510,7 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	sequenceNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -539,6 +559,12 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationMempoolSequence:
+				if len(sequenceNotifications) != 0 {
+					m.notifyMempoolSequence(sequenceNotifications,
+						(*btcjson.MempoolSequenceEvent)(n))
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -557,6 +583,7 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(sequenceNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -586,6 +613,14 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterMempoolSequence:
+				wsc := (*wsClient)(n)
+				sequenceNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterMempoolSequence:
+				wsc := (*wsClient)(n)
+				delete(sequenceNotifications, wsc.quit)
+
 			default:
 				rpcsLog.Warn("Unhandled notification type")
 			}
@@ -868,6 +903,35 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 	}
 }
 
+// RegisterMempoolSequenceUpdates requests mempool sequence notifications to the
+// passed websocket client.
+func (m *wsNotificationManager) RegisterMempoolSequenceUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterMempoolSequence)(wsc)
+}
+
+// UnregisterMempoolSequenceUpdates removes mempool sequence notifications for
+// the passed websocket client.
+func (m *wsNotificationManager) UnregisterMempoolSequenceUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterMempoolSequence)(wsc)
+}
+
+// notifyMempoolSequence notifies websocket clients that have registered for
+// mempool sequence updates about the passed event.
+func (*wsNotificationManager) notifyMempoolSequence(clients map[chan struct{}]*wsClient,
+	event *btcjson.MempoolSequenceEvent) {
+
+	ntfn := btcjson.NewMempoolSequenceNtfn(*event)
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal mempool sequence "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterSpentRequests requests a notification when each of the passed
 // outpoints is confirmed spent (contained in a block connected to the main
 // chain) for the passed websocket client.  The request is automatically
@@ -1264,9 +1328,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// user is the user the client authenticated as which determines the
+	// RPC calls, and therefore notifications, the client has access to.
+	user *rpcAuthUser
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1323,6 +1387,34 @@ out:
 			break out
 		}
 
+		// Batch requests are only accepted from authenticated clients
+		// since the authenticate request must be sent on its own.
+		if isBatchRequest(msg) {
+			if !c.authenticated {
+				rpcsLog.Warnf("Unauthenticated websocket batch " +
+					"request received")
+				break out
+			}
+
+			requests, jsonErr := parseBatchRequest(msg)
+			if jsonErr != nil {
+				if reply := marshalErrorReply("", nil, jsonErr); reply != nil {
+					c.SendMessage(reply, nil)
+				}
+				continue
+			}
+
+			// The requests of a batch are serviced in order by a
+			// single goroutine, so the batch only occupies one
+			// slot of the semaphore described below.
+			c.serviceRequestSem.acquire()
+			go func() {
+				c.serviceBatchRequest(requests)
+				c.serviceRequestSem.release()
+			}()
+			continue
+		}
+
 		var request btcjson.Request
 		err = json.Unmarshal(msg, &request)
 		if err != nil {
@@ -1334,7 +1426,7 @@ out:
 				Code:    btcjson.ErrRPCParse.Code,
 				Message: "Failed to parse request: " + err.Error(),
 			}
-			reply, err := createMarshalledReply(nil, nil, jsonErr)
+			reply, err := createMarshalledReply("", nil, nil, jsonErr)
 			if err != nil {
 				rpcsLog.Errorf("Failed to marshal parse failure "+
 					"reply: %v", err)
@@ -1344,25 +1436,8 @@ out:
 			continue
 		}
 
-		// The JSON-RPC 1.0 spec defines that notifications must have their "id"
-		// set to null and states that notifications do not have a response.
-		//
-		// A JSON-RPC 2.0 notification is a request with "json-rpc":"2.0", and
-		// without an "id" member. The specification states that notifications
-		// must not be responded to. JSON-RPC 2.0 permits the null value as a
-		// valid request id, therefore such requests are not notifications.
-		//
-		// Bitcoin Core serves requests with "id":null or even an absent "id",
-		// and responds to such requests with "id":null in the response.
-		//
-		// Btcd does not respond to any request without and "id" or "id":null,
-		// regardless the indicated JSON-RPC protocol version unless RPC quirks
-		// are enabled. With RPC quirks enabled, such requests will be responded
-		// to if the reqeust does not indicate JSON-RPC version.
-		//
-		// RPC quirks can be enabled by the user to avoid compatibility issues
-		// with software relying on Core's behavior.
-		if request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "") {
+		// Requests that are notifications are not responded to.
+		if isNotification(&request) {
 			if !c.authenticated {
 				break out
 			}
@@ -1375,7 +1450,8 @@ out:
 				break out
 			}
 
-			reply, err := createMarshalledReply(cmd.id, nil, cmd.err)
+			reply, err := createMarshalledReply(cmd.jsonrpc, cmd.id, nil,
+				cmd.err)
 			if err != nil {
 				rpcsLog.Errorf("Failed to marshal parse failure "+
 					"reply: %v", err)
@@ -1404,18 +1480,16 @@ out:
 			// Check credentials.
 			login := authCmd.Username + ":" + authCmd.Passphrase
 			auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-			authSha := sha256.Sum256([]byte(auth))
-			cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-			limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-			if cmp != 1 && limitcmp != 1 {
+			user := c.server.auth.authenticate(auth)
+			if user == nil {
 				rpcsLog.Warnf("Auth failure.")
 				break out
 			}
 			c.authenticated = true
-			c.isAdmin = cmp == 1
+			c.user = user
 
 			// Marshal and send response.
-			reply, err := createMarshalledReply(cmd.id, nil, nil)
+			reply, err := createMarshalledReply(cmd.jsonrpc, cmd.id, nil, nil)
 			if err != nil {
 				rpcsLog.Errorf("Failed to marshal authenticate reply: "+
 					"%v", err.Error())
@@ -1425,24 +1499,18 @@ out:
 			continue
 		}
 
-		// Check if the client is using limited RPC credentials and
-		// error when not authorized to call this RPC.
-		if !c.isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr := &btcjson.RPCError{
-					Code:    btcjson.ErrRPCInvalidParams.Code,
-					Message: "limited user not authorized for this method",
-				}
-				// Marshal and send response.
-				reply, err := createMarshalledReply(request.ID, nil, jsonErr)
-				if err != nil {
-					rpcsLog.Errorf("Failed to marshal parse failure "+
-						"reply: %v", err)
-					continue
-				}
-				c.SendMessage(reply, nil)
+		// Error when the client is not authorized to call this RPC.
+		if jsonErr := c.user.checkMethod(request.Method); jsonErr != nil {
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.Jsonrpc,
+				request.ID, nil, jsonErr)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal parse failure "+
+					"reply: %v", err)
 				continue
 			}
+			c.SendMessage(reply, nil)
+			continue
 		}
 
 		// Asynchronously handle the request.  A semaphore is used to
@@ -1482,6 +1550,15 @@ out:
 // appropriate RPC handler.  The response is marshalled and sent to the
 // websocket client.
 func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
+	if reply := c.executeRequest(r); reply != nil {
+		c.SendMessage(reply, nil)
+	}
+}
+
+// executeRequest executes a parsed RPC request by looking up and executing the
+// appropriate RPC handler and returns the marshalled response.  Nil is
+// returned when the response can't be marshalled.
+func (c *wsClient) executeRequest(r *parsedRPCCmd) []byte {
 	var (
 		result interface{}
 		err    error
@@ -1495,13 +1572,72 @@ func (c *wsClient) serviceRequest(r *parsedRPCCmd) {
 	} else {
 		result, err = c.server.standardCmdResult(r, nil)
 	}
-	reply, err := createMarshalledReply(r.id, result, err)
+	reply, err := createMarshalledReply(r.jsonrpc, r.id, result, err)
 	if err != nil {
 		rpcsLog.Errorf("Failed to marshal reply for <%s> "+
 			"command: %v", r.method, err)
-		return
+		return nil
+	}
+	return reply
+}
+
+// serviceBatchRequest services each request of a batch request in order and
+// sends the array of their marshalled responses to the websocket client as a
+// single message.  Each request is subject to the same authorization checks
+// as a single request, and the authenticate request is rejected since the
+// client must already be authenticated to send a batch request.
+func (c *wsClient) serviceBatchRequest(requests []json.RawMessage) {
+	replies := make([]json.RawMessage, 0, len(requests))
+	for _, rawRequest := range requests {
+		// Stop servicing the remaining requests once the client
+		// disconnected since the response can no longer be delivered.
+		select {
+		case <-c.quit:
+			return
+		default:
+		}
+
+		request, reply := parseBatchElement(rawRequest)
+		if request != nil {
+			reply = c.executeBatchElement(request)
+		}
+		if reply != nil {
+			replies = append(replies, reply)
+		}
+	}
+	if msg := marshalBatchReply(replies); msg != nil {
+		c.SendMessage(msg, nil)
+	}
+}
+
+// executeBatchElement executes a single request of a batch request and
+// returns the marshalled response.  Nil is returned when there is nothing to
+// send, such as for notifications.
+func (c *wsClient) executeBatchElement(request *btcjson.Request) []byte {
+	if isNotification(request) {
+		return nil
+	}
+
+	cmd := parseCmd(request)
+	if cmd.err != nil {
+		return marshalErrorReply(cmd.jsonrpc, cmd.id, cmd.err)
+	}
+	if _, ok := cmd.cmd.(*btcjson.AuthenticateCmd); ok {
+		jsonErr := &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidRequest.Code,
+			Message: "authenticate may not be part of a batch request",
+		}
+		return marshalErrorReply(cmd.jsonrpc, cmd.id, jsonErr)
+	}
+	rpcsLog.Debugf("Received batched command <%s> from %s", cmd.method,
+		c.addr)
+
+	// Error when the client is not authorized to call this RPC.
+	if jsonErr := c.user.checkMethod(request.Method); jsonErr != nil {
+		return marshalErrorReply(request.Jsonrpc, request.ID, jsonErr)
 	}
-	c.SendMessage(reply, nil)
+
+	return c.executeRequest(cmd)
 }
 
 // notificationQueueHandler handles the queuing of outgoing notifications for
@@ -1709,7 +1845,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, error) {
+	remoteAddr string, user *rpcAuthUser) (*wsClient, error) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -1719,8 +1855,8 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	client := &wsClient{
 		conn:              conn,
 		addr:              remoteAddr,
-		authenticated:     authenticated,
-		isAdmin:           isAdmin,
+		authenticated:     user != nil,
+		user:              user,
 		sessionID:         sessionID,
 		server:            server,
 		addrRequests:      make(map[string]struct{}),
@@ -1780,6 +1916,18 @@ func handleWebsocketHelp(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return help, nil
 }
 
+// handleWebsocketRPCDiscover implements the rpc.discover command extension for
+// websocket connections.  Unlike the HTTP POST variant, the returned document
+// also describes the websocket-specific commands.
+func handleWebsocketRPCDiscover(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	doc, err := wsc.server.helpCacher.rpcDiscover(true)
+	if err != nil {
+		context := "Failed to generate OpenRPC document"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return doc, nil
+}
+
 // handleLoadTxFilter implements the loadtxfilter command extension for
 // websocket connections.
 //
@@ -1882,6 +2030,20 @@ func handleStopNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface
 	return nil, nil
 }
 
+// handleNotifyMempoolSequence implements the notifymempoolsequence command
+// extension for websocket connections.
+func handleNotifyMempoolSequence(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterMempoolSequenceUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyMempoolSequence implements the stopnotifymempoolsequence
+// command extension for websocket connections.
+func handleStopNotifyMempoolSequence(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterMempoolSequenceUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifyReceived implements the notifyreceived command extension for
 // websocket connections.
 func handleNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, error) {
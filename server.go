@@ -14,6 +14,8 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -29,6 +31,7 @@ import (
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/connmgr"
 	"github.com/btcsuite/btcd/database"
+	"github.com/btcsuite/btcd/jsonlog"
 	"github.com/btcsuite/btcd/mempool"
 	"github.com/btcsuite/btcd/mining"
 	"github.com/btcsuite/btcd/mining/cpuminer"
@@ -53,10 +56,22 @@ const (
 	// defaultTargetOutbound is the default number of outbound peers to target.
 	defaultTargetOutbound = 8
 
+	// defaultTargetBlockRelayOnly is the default number of block-relay-only
+	// outbound peers to target in addition to the other outbound peers.
+	defaultTargetBlockRelayOnly = 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// banListFilename is the name of the file in the data directory the
+	// banned subnets are persisted to.
+	banListFilename = "banlist.json"
+
+	// banListPruneInterval is the interval at which expired bans are
+	// removed from the ban list.
+	banListPruneInterval = time.Minute * 15
 )
 
 var (
@@ -151,13 +166,17 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
+
+	// tipHash and tipUpdated track the best chain tip and when it last
+	// changed in order to detect a stale tip.
+	tipHash    chainhash.Hash
+	tipUpdated time.Time
 }
 
 // Count returns the count of all known peers.
@@ -204,13 +223,16 @@ type server struct {
 	shutdown      int32
 	shutdownSched int32
 	startupTime   int64
+	syncPeerID    int32 // ID of the sync peer, or 0 if there is none.
 
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
+	banList              *connmgr.BanList
 	connManager          *connmgr.ConnManager
 	sigCache             *txscript.SigCache
 	hashCache            *txscript.HashCache
 	rpcServer            *rpcServer
+	restServer           *restServer
 	syncManager          *netsync.SyncManager
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
@@ -254,6 +276,30 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// targetOutbound is the number of full-relay outbound peers to
+	// maintain.  Extra outbound peers connected due to a stale tip are
+	// evicted down to this number.
+	targetOutbound int
+
+	// evictionKey is a random secret used to choose which network groups
+	// of inbound peers are protected from eviction.
+	evictionKey [32]byte
+
+	// metrics houses the metrics exposed by the optional metrics server.
+	metrics *serverMetrics
+
+	// msgTraffic tracks the messages and bytes sent and received across
+	// all peers per message command.
+	msgTraffic peer.MsgTrafficCounter
+
+	// uploadTarget limits the bytes sent to peers per day by no longer
+	// serving historical blocks once the target is about to be reached.
+	uploadTarget *uploadTarget
+
+	// zmqNotifier publishes block and transaction notifications over
+	// ZeroMQ.  It is nil when no ZeroMQ endpoints are configured.
+	zmqNotifier *zmqNotifier
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
@@ -262,6 +308,13 @@ type serverPeer struct {
 	// The following variables must only be used atomically
 	feeFilter int64
 
+	// The following variables track when the peer last provided us with
+	// something useful as unix nanoseconds.  They must only be used
+	// atomically.
+	lastTxTime        int64 // Novel tx accepted to the mempool.
+	lastBlockTime     int64 // Novel block accepted to the chain.
+	lastBlockAnnounce int64 // Novel block announced or sent.
+
 	*peer.Peer
 
 	connReq        *connmgr.ConnReq
@@ -276,6 +329,7 @@ type serverPeer struct {
 	addressesMtx   sync.RWMutex
 	knownAddresses map[string]struct{}
 	banScore       connmgr.DynamicBanScore
+	evicted        bool
 	quit           chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
@@ -321,6 +375,27 @@ func (sp *serverPeer) addressKnown(na *wire.NetAddress) bool {
 	return exists
 }
 
+// isBlockRelayOnly returns whether or not the peer is a block-relay-only
+// outbound peer, with which transactions and addresses are never exchanged.
+func (sp *serverPeer) isBlockRelayOnly() bool {
+	return sp.connReq != nil && !sp.persistent &&
+		sp.connReq.Type == connmgr.ConnTypeBlockRelayOnly
+}
+
+// connectionType returns a human-readable description of the type of
+// connection to the peer.
+func (sp *serverPeer) connectionType() string {
+	switch {
+	case sp.Inbound():
+		return "inbound"
+	case sp.persistent:
+		return "manual"
+	case sp.connReq != nil:
+		return sp.connReq.Type.String()
+	}
+	return connmgr.ConnTypeFullRelay.String()
+}
+
 // setDisableRelayTx toggles relaying of transactions for the given peer.
 // It is safe for concurrent access.
 func (sp *serverPeer) setDisableRelayTx(disable bool) {
@@ -333,6 +408,11 @@ func (sp *serverPeer) setDisableRelayTx(disable bool) {
 // peer is disabled.
 // It is safe for concurrent access.
 func (sp *serverPeer) relayTxDisabled() bool {
+	// Transactions are never relayed to block-relay-only peers.
+	if sp.isBlockRelayOnly() {
+		return true
+	}
+
 	sp.relayMtx.Lock()
 	isDisabled := sp.disableRelayTx
 	sp.relayMtx.Unlock()
@@ -496,6 +576,13 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 		return
 	}
 
+	// Transactions are never announced to block-relay-only peers.
+	if sp.isBlockRelayOnly() {
+		peerLog.Debugf("Ignoring mempool request from block-relay-only "+
+			"peer %v", sp)
+		return
+	}
+
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
@@ -538,8 +625,19 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	if cfg.BlocksOnly {
+		txHash := msg.TxHash()
 		peerLog.Tracef("Ignoring tx %v from %v - blocksonly enabled",
-			msg.TxHash(), sp)
+			jsonlog.TxID(&txHash), sp)
+		return
+	}
+
+	// Block-relay-only peers were told not to relay transactions, so they
+	// are misbehaving when they send one.
+	if sp.isBlockRelayOnly() {
+		txHash := msg.TxHash()
+		peerLog.Infof("Peer %v sent tx %v on a block-relay-only "+
+			"connection -- disconnecting", sp, jsonlog.TxID(&txHash))
+		sp.Disconnect()
 		return
 	}
 
@@ -555,8 +653,16 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	// processed and known good or bad.  This helps prevent a malicious peer
 	// from queuing up a bunch of bad transactions before disconnecting (or
 	// being disconnected) and wasting memory.
+	txMemPool := sp.server.txMemPool
+	known := txMemPool.HaveTransaction(tx.Hash())
 	sp.server.syncManager.QueueTx(tx, sp.Peer, sp.txProcessed)
 	<-sp.txProcessed
+
+	// Note when the peer provided a transaction that was new to us and is
+	// now in the mempool for use in the inbound peer eviction logic.
+	if !known && txMemPool.IsTransactionInPool(tx.Hash()) {
+		atomic.StoreInt64(&sp.lastTxTime, time.Now().UnixNano())
+	}
 }
 
 // OnBlock is invoked when a peer receives a block bitcoin message.  It
@@ -581,8 +687,17 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// reference implementation processes blocks in the same
 	// thread and therefore blocks further messages until
 	// the bitcoin block has been fully processed.
+	known, _ := sp.server.chain.HaveBlock(block.Hash())
 	sp.server.syncManager.QueueBlock(block, sp.Peer, sp.blockProcessed)
 	<-sp.blockProcessed
+
+	// Note when the peer provided a block that was new to us and has been
+	// accepted for use in the peer eviction logic.
+	if !known && sp.server.chain.MainChainHasBlock(block.Hash()) {
+		now := time.Now().UnixNano()
+		atomic.StoreInt64(&sp.lastBlockTime, now)
+		atomic.StoreInt64(&sp.lastBlockAnnounce, now)
+	}
 }
 
 // OnInv is invoked when a peer receives an inv bitcoin message and is
@@ -590,7 +705,13 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	for _, invVect := range msg.InvList {
+		if invVect.Type == wire.InvTypeBlock {
+			sp.noteBlockAnnounce(&invVect.Hash)
+		}
+	}
+
+	if !cfg.BlocksOnly && !sp.isBlockRelayOnly() {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -601,7 +722,8 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"transaction relay disabled",
+				jsonlog.TxID(&invVect.Hash), sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -622,9 +744,22 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	}
 }
 
+// noteBlockAnnounce records the time the peer announced the passed block when
+// the block is not already known.  It is used to identify outbound peers that
+// are not keeping us informed of new blocks.
+func (sp *serverPeer) noteBlockAnnounce(hash *chainhash.Hash) {
+	if known, err := sp.server.chain.HaveBlock(hash); err == nil && !known {
+		atomic.StoreInt64(&sp.lastBlockAnnounce, time.Now().UnixNano())
+	}
+}
+
 // OnHeaders is invoked when a peer receives a headers bitcoin
 // message.  The message is passed down to the sync manager.
 func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	if len(msg.Headers) > 0 {
+		hash := msg.Headers[len(msg.Headers)-1].BlockHash()
+		sp.noteBlockAnnounce(&hash)
+	}
 	sp.server.syncManager.QueueHeaders(msg, sp.Peer)
 }
 
@@ -662,6 +797,19 @@ func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
 			// Buffered so as to not make the send goroutine block.
 			c = make(chan struct{}, 1)
 		}
+
+		// Disconnect peers requesting historical blocks once the upload
+		// target is about to be reached.
+		if iv.Type != wire.InvTypeTx && iv.Type != wire.InvTypeWitnessTx &&
+			sp.server.blockRequestDenied(sp, &iv.Hash) {
+
+			peerLog.Infof("Upload target reached -- disconnecting "+
+				"peer %s requesting historical block %v", sp,
+				jsonlog.BlockHash(&iv.Hash))
+			sp.Disconnect()
+			return
+		}
+
 		var err error
 		switch iv.Type {
 		case wire.InvTypeWitnessTx:
@@ -823,7 +971,7 @@ func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
 	for i, filterBytes := range filters {
 		if len(filterBytes) == 0 {
 			peerLog.Warnf("Could not obtain cfilter for %v",
-				hashes[i])
+				jsonlog.BlockHash(&hashes[i]))
 			return
 		}
 
@@ -911,7 +1059,8 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 			return
 		}
 		if len(headerBytes) == 0 {
-			peerLog.Warnf("Could not obtain CF header for %v", prevBlockHash)
+			peerLog.Warnf("Could not obtain CF header for %v",
+				jsonlog.BlockHash(prevBlockHash))
 			return
 		}
 
@@ -930,7 +1079,8 @@ func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
 	// Populate HeaderHashes.
 	for i, hashBytes := range filterHashes {
 		if len(hashBytes) == 0 {
-			peerLog.Warnf("Could not obtain CF hash for %v", hashList[i])
+			peerLog.Warnf("Could not obtain CF hash for %v",
+				jsonlog.BlockHash(&hashList[i]))
 			return
 		}
 
@@ -1070,7 +1220,7 @@ func (sp *serverPeer) OnGetCFCheckpt(_ *peer.Peer, msg *wire.MsgGetCFCheckpt) {
 	for i, filterHeaderBytes := range filterHeaders {
 		if len(filterHeaderBytes) == 0 {
 			peerLog.Warnf("Could not obtain CF header for %v",
-				blockHashPtrs[i])
+				jsonlog.BlockHash(blockHashPtrs[i]))
 			return
 		}
 
@@ -1264,6 +1414,13 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Addresses are never exchanged with block-relay-only peers.
+	if sp.isBlockRelayOnly() {
+		peerLog.Debugf("Ignoring addresses from block-relay-only peer "+
+			"%v", sp)
+		return
+	}
+
 	// A message that has no addresses is invalid.
 	if len(msg.AddrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
@@ -1302,12 +1459,20 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 // the bytes received by the server.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	if bytesRead > 0 {
+		sp.server.msgTraffic.Add(msg, bytesRead, false)
+	}
+	sp.server.metrics.addP2PBytes("received", msg, bytesRead)
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
 // the bytes sent by the server.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	if bytesWritten > 0 {
+		sp.server.msgTraffic.Add(msg, bytesWritten, true)
+	}
+	sp.server.metrics.addP2PBytes("sent", msg, bytesWritten)
 }
 
 // OnNotFound is invoked when a peer sends a notfound message.
@@ -1440,7 +1605,7 @@ func (s *server) pushTxMsg(sp *serverPeer, hash *chainhash.Hash, doneChan chan<-
 	tx, err := s.txMemPool.FetchTransaction(hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch tx %v from transaction "+
-			"pool: %v", hash, err)
+			"pool: %v", jsonlog.TxID(hash), err)
 
 		if doneChan != nil {
 			doneChan <- struct{}{}
@@ -1472,7 +1637,7 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	})
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
-			hash, err)
+			jsonlog.BlockHash(hash), err)
 
 		if doneChan != nil {
 			doneChan <- struct{}{}
@@ -1485,7 +1650,7 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 	err = msgBlock.Deserialize(bytes.NewReader(blockBytes))
 	if err != nil {
 		peerLog.Tracef("Unable to deserialize requested block hash "+
-			"%v: %v", hash, err)
+			"%v: %v", jsonlog.BlockHash(hash), err)
 
 		if doneChan != nil {
 			doneChan <- struct{}{}
@@ -1543,7 +1708,7 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 	blk, err := sp.server.chain.BlockByHash(hash)
 	if err != nil {
 		peerLog.Tracef("Unable to fetch requested block hash %v: %v",
-			hash, err)
+			jsonlog.BlockHash(hash), err)
 
 		if doneChan != nil {
 			doneChan <- struct{}{}
@@ -1640,22 +1805,19 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if s.banList.IsBanned(net.ParseIP(host)) {
+		srvrLog.Debugf("Peer %s is banned - disconnecting", host)
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  Room is made for new inbound
+	// peers by evicting an existing inbound peer when possible.
+	if state.Count() >= cfg.MaxPeers &&
+		!(sp.Inbound() && s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
 		sp.Disconnect()
@@ -1676,10 +1838,15 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 			state.outboundPeers[sp.ID()] = sp
 		}
 	}
+	s.metrics.updatePeers(state)
 
 	// Update the address' last seen time if the peer has acknowledged
-	// our version and has sent us its version as well.
-	if sp.VerAckReceived() && sp.VersionKnown() && sp.NA() != nil {
+	// our version and has sent us its version as well.  This is skipped
+	// for block-relay-only peers since the last seen time is relayed to
+	// other peers and would reveal the connection.
+	if sp.VerAckReceived() && sp.VersionKnown() && sp.NA() != nil &&
+		!sp.isBlockRelayOnly() {
+
 		s.addrManager.Connected(sp.NA())
 	}
 
@@ -1694,8 +1861,12 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if !cfg.SimNet && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
-		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		// known tip.  Addresses are never exchanged with block-relay-only
+		// peers.
+		blockRelayOnly := sp.isBlockRelayOnly()
+		if !cfg.DisableListen && !blockRelayOnly &&
+			s.syncManager.IsCurrent() {
+
 			// Get address that best matches.
 			lna := s.addrManager.GetBestLocalAddress(sp.NA())
 			if addrmgr.IsRoutable(lna) {
@@ -1709,7 +1880,9 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= wire.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp &&
+			!blockRelayOnly {
+
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -1740,7 +1913,11 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+
+			// Evicted extra outbound peers are not replaced.
+			if !sp.evicted {
+				go s.connManager.NewConnReq()
+			}
 		}
 	}
 
@@ -1749,6 +1926,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
 		}
 		delete(list, sp.ID())
+		s.metrics.updatePeers(state)
 		srvrLog.Debugf("Removed peer %s", sp)
 		return
 	}
@@ -1762,10 +1940,20 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		srvrLog.Debugf("can't ban peer %s without an IP address", host)
+		return
+	}
+	err = s.banList.Ban(connmgr.HostSubnet(ip),
+		time.Now().Add(cfg.BanDuration))
+	if err != nil && err != connmgr.ErrAlreadyBanned {
+		srvrLog.Errorf("Failed to ban peer %s: %v", host, err)
+		return
+	}
 	direction := directionString(sp.Inbound())
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
 		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
@@ -1885,6 +2073,11 @@ type removeNodeMsg struct {
 	reply chan error
 }
 
+type disconnectSubnetMsg struct {
+	subnet *net.IPNet
+	reply  chan int
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *server) handleQuery(state *peerState, querymsg interface{}) {
@@ -1994,6 +2187,25 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		}
 
 		msg.reply <- errors.New("peer not found")
+
+	case disconnectSubnetMsg:
+		// Disconnect all peers in the subnet, including persistent
+		// peers.  The peers are removed from the lists once they are
+		// done and persistent peers are refused while the subnet is
+		// banned when they reconnect.
+		var count int
+		state.forAllPeers(func(sp *serverPeer) {
+			host, _, err := net.SplitHostPort(sp.Addr())
+			if err != nil {
+				return
+			}
+			ip := net.ParseIP(host)
+			if ip != nil && msg.subnet.Contains(ip) {
+				sp.Disconnect()
+				count++
+			}
+		})
+		msg.reply <- count
 	}
 }
 
@@ -2062,7 +2274,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    cfg.BlocksOnly || sp.isBlockRelayOnly(),
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
 	}
@@ -2087,6 +2299,7 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.connReq = c
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -2099,7 +2312,6 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 		return
 	}
 	sp.Peer = p
-	sp.connReq = c
 	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	sp.AssociateConnection(conn)
 	go s.peerDoneHandler(sp)
@@ -2126,6 +2338,45 @@ func (s *server) peerDoneHandler(sp *serverPeer) {
 	close(sp.quit)
 }
 
+// saveAnchors records the addresses of the connected block-relay-only peers
+// with the address manager so they are reconnected to at the next start.  It
+// is invoked from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	var anchors []*wire.NetAddress
+	for _, sp := range state.outboundPeers {
+		if sp.isBlockRelayOnly() && sp.Connected() && sp.NA() != nil {
+			anchors = append(anchors, sp.NA())
+		}
+	}
+	s.addrManager.SetAnchors(anchors)
+	if len(anchors) > 0 {
+		srvrLog.Debugf("Saved %d anchor %s", len(anchors),
+			pickNoun(uint64(len(anchors)), "peer", "peers"))
+	}
+}
+
+// getAnchors returns the addresses of the block-relay-only peers that were
+// saved during the previous run.  It is used by the connection manager to
+// reconnect to them.
+func (s *server) getAnchors() []net.Addr {
+	anchors := s.addrManager.TakeAnchors()
+	addrs := make([]net.Addr, 0, len(anchors))
+	for _, na := range anchors {
+		addr, err := addrStringToNetAddr(addrmgr.NetAddressKey(na))
+		if err != nil {
+			srvrLog.Debugf("Ignoring invalid anchor %v: %v",
+				addrmgr.NetAddressKey(na), err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) > 0 {
+		srvrLog.Infof("Reconnecting to %d anchor %s", len(addrs),
+			pickNoun(uint64(len(addrs)), "peer", "peers"))
+	}
+	return addrs
+}
+
 // peerHandler is used to handle peer operations such as adding and removing
 // peers to and from the server, banning peers, and broadcasting messages to
 // peers.  It must be run in a goroutine.
@@ -2144,7 +2395,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -2162,6 +2412,11 @@ func (s *server) peerHandler() {
 	}
 	go s.connManager.Start()
 
+	staleTipTicker := time.NewTicker(staleTipCheckInterval)
+	defer staleTipTicker.Stop()
+	banListPruneTicker := time.NewTicker(banListPruneInterval)
+	defer banListPruneTicker.Stop()
+
 out:
 	for {
 		select {
@@ -2193,7 +2448,26 @@ out:
 		case qmsg := <-s.query:
 			s.handleQuery(state, qmsg)
 
+		// Rotate outbound peers when the best chain tip is stale.  This
+		// is skipped on the test networks which only produce blocks on
+		// demand.
+		case <-staleTipTicker.C:
+			if !cfg.SimNet && !cfg.RegressionTest {
+				s.checkStaleTip(state)
+			}
+			s.evictExtraOutboundPeer(state)
+
+		// Remove expired bans from the ban list.
+		case <-banListPruneTicker.C:
+			if err := s.banList.Prune(); err != nil {
+				srvrLog.Errorf("Failed to save ban list: %v", err)
+			}
+
 		case <-s.quit:
+			// Save the block-relay-only peers as anchors to
+			// reconnect to at the next start.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
@@ -2272,6 +2546,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.addBytes(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2287,6 +2562,22 @@ func (s *server) NetTotals() (uint64, uint64) {
 		atomic.LoadUint64(&s.bytesSent)
 }
 
+// MsgTraffic returns the messages and bytes sent and received across all peers
+// keyed by message command.  It is safe for concurrent access.
+func (s *server) MsgTraffic() map[string]peer.MsgTraffic {
+	return s.msgTraffic.Traffic()
+}
+
+// SyncPeerChanged records the ID of the new sync peer, which is nil when there
+// is none, so it can be looked up without a round trip to the sync manager.
+func (s *server) SyncPeerChanged(syncPeer *peer.Peer) {
+	var id int32
+	if syncPeer != nil {
+		id = syncPeer.ID()
+	}
+	atomic.StoreInt32(&s.syncPeerID, id)
+}
+
 // UpdatePeerHeights updates the heights of all peers who have have announced
 // the latest connected main chain block, or a recognized orphan. These height
 // updates allow us to dynamically refresh peer heights, ensuring sync peer
@@ -2387,6 +2678,14 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	// Start serving REST requests if enabled.
+	if s.restServer != nil {
+		s.restServer.Start()
+	}
+
+	// Start serving metrics if enabled.
+	s.metrics.start()
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
@@ -2412,6 +2711,19 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Shutdown the REST server if it's running.
+	if s.restServer != nil {
+		s.restServer.Stop()
+	}
+
+	// Shutdown the metrics server if it's running.
+	s.metrics.stop()
+
+	// Stop publishing ZeroMQ notifications.
+	if s.zmqNotifier != nil {
+		s.zmqNotifier.stop()
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) error {
 		metadata := tx.Metadata()
@@ -2568,13 +2880,35 @@ out:
 	s.wg.Done()
 }
 
+// listenUnixSocket returns a listener on the Unix domain socket at the passed
+// path with the configured file mode.  A stale socket left behind at the path,
+// such as after a crash, is replaced.
+func listenUnixSocket(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	return listenUnix(path, cfg.rpcUnixSocketMode)
+}
+
 // setupRPCListeners returns a slice of listeners that are configured for use
 // with the RPC server depending on the configuration settings for listen
-// addresses and TLS.
+// addresses and TLS.  Listeners on Unix domain sockets never use TLS.
 func setupRPCListeners() ([]net.Listener, error) {
+	// Separate the Unix domain sockets from the interfaces to listen on.
+	var unixPaths, tcpAddrs []string
+	for _, addr := range cfg.RPCListeners {
+		if path, ok := unixSocketPath(addr); ok {
+			unixPaths = append(unixPaths, path)
+			continue
+		}
+		tcpAddrs = append(tcpAddrs, addr)
+	}
+
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
-	if !cfg.DisableTLS {
+	if !cfg.DisableTLS && len(tcpAddrs) > 0 {
 		// Generate the TLS cert and key file if both don't already
 		// exist.
 		if !fileExists(cfg.RPCKey) && !fileExists(cfg.RPCCert) {
@@ -2599,12 +2933,12 @@ func setupRPCListeners() ([]net.Listener, error) {
 		}
 	}
 
-	netAddrs, err := parseListeners(cfg.RPCListeners)
+	netAddrs, err := parseListeners(tcpAddrs)
 	if err != nil {
 		return nil, err
 	}
 
-	listeners := make([]net.Listener, 0, len(netAddrs))
+	listeners := make([]net.Listener, 0, len(netAddrs)+len(unixPaths))
 	for _, addr := range netAddrs {
 		listener, err := listenFunc(addr.Network(), addr.String())
 		if err != nil {
@@ -2613,6 +2947,39 @@ func setupRPCListeners() ([]net.Listener, error) {
 		}
 		listeners = append(listeners, listener)
 	}
+	for _, path := range unixPaths {
+		listener, err := listenUnixSocket(path)
+		if err != nil {
+			rpcsLog.Warnf("Can't listen on %s: %v", path, err)
+			continue
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// setupRESTListeners returns a slice of listeners that are configured for use
+// with the REST server depending on the configuration settings for listen
+// addresses.  The REST interface does not use TLS.
+func setupRESTListeners() ([]net.Listener, error) {
+	netAddrs, err := parseListeners(cfg.RESTListeners)
+	if err != nil {
+		return nil, fmt.Errorf("invalid REST listen address: %v", err)
+	}
+
+	listeners := make([]net.Listener, 0, len(netAddrs))
+	for _, addr := range netAddrs {
+		listener, err := net.Listen(addr.Network(), addr.String())
+		if err != nil {
+			for _, listener := range listeners {
+				listener.Close()
+			}
+			return nil, fmt.Errorf("unable to listen for REST "+
+				"requests on %s: %v", addr, err)
+		}
+		listeners = append(listeners, listener)
+	}
 
 	return listeners, nil
 }
@@ -2634,6 +3001,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
+	// Load the persisted ban list.
+	banList := connmgr.NewBanList(filepath.Join(cfg.DataDir, banListFilename))
+	if err := banList.Load(); err != nil {
+		return nil, fmt.Errorf("failed to load ban list: %v", err)
+	}
+
 	var listeners []net.Listener
 	var nat NAT
 	if !cfg.DisableListen {
@@ -2657,6 +3030,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	s := server{
 		chainParams:          chainParams,
 		addrManager:          amgr,
+		banList:              banList,
 		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
 		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
@@ -2675,7 +3049,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
+		uploadTarget: newUploadTarget(cfg.MaxUploadTarget*1024*1024,
+			chainParams.TargetTimePerBlock),
 	}
+	s.metrics = newServerMetrics(&s)
 
 	// Create the transaction and address indexes if needed.
 	//
@@ -2724,14 +3101,15 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	s.chain, err = blockchain.New(&blockchain.Config{
-		DB:           s.db,
-		Interrupt:    interrupt,
-		ChainParams:  s.chainParams,
-		Checkpoints:  checkpoints,
-		TimeSource:   s.timeSource,
-		SigCache:     s.sigCache,
-		IndexManager: indexManager,
-		HashCache:    s.hashCache,
+		DB:              s.db,
+		Interrupt:       interrupt,
+		ChainParams:     s.chainParams,
+		Checkpoints:     checkpoints,
+		TimeSource:      s.timeSource,
+		SigCache:        s.sigCache,
+		IndexManager:    indexManager,
+		HashCache:       s.hashCache,
+		ValidationTimer: s.metrics.observeValidation,
 	})
 	if err != nil {
 		return nil, err
@@ -2801,12 +3179,27 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		ChainParams:        s.chainParams,
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
+		SyncStallTimeout:   cfg.SyncStallTimeout,
 		FeeEstimator:       s.feeEstimator,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Publish notifications over ZeroMQ when enabled.  The notifier must
+	// subscribe to the chain after the sync manager so transactions that
+	// conflict with a connected block are removed from the mempool before
+	// the block is announced.
+	s.zmqNotifier, err = newZMQNotifier(zmqEndpoints(cfg))
+	if err != nil {
+		return nil, fmt.Errorf("unable to create ZeroMQ notifier: %v",
+			err)
+	}
+	if s.zmqNotifier != nil {
+		s.chain.Subscribe(s.zmqNotifier.handleBlockchainNotification)
+		s.txMemPool.Subscribe(s.zmqNotifier.handleMempoolNotification)
+	}
+
 	// Create the mining policy and block template generator based on the
 	// configuration options.
 	//
@@ -2858,6 +3251,11 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 					continue
 				}
 
+				// Skip banned addresses.
+				if s.banList.IsBanned(addr.NetAddress().IP) {
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2886,14 +3284,25 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+	targetBlockRelayOnly := defaultTargetBlockRelayOnly
+	if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+		targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+	}
+	s.targetOutbound = targetOutbound
+	if _, err := rand.Read(s.evictionKey[:]); err != nil {
+		return nil, err
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: uint32(targetBlockRelayOnly),
+		Dial:                 btcdDial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
+		GetAnchors:           s.getAnchors,
+		BanList:              s.banList,
 	})
 	if err != nil {
 		return nil, err
@@ -2917,6 +3326,31 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		})
 	}
 
+	// The RPC and REST servers share the configuration which provides
+	// access to the chain, mempool and indexes.
+	rpcConfig := rpcserverConfig{
+		StartupTime:  s.startupTime,
+		ConnMgr:      &rpcConnManager{&s},
+		SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
+		TimeSource:   s.timeSource,
+		Chain:        s.chain,
+		ChainParams:  chainParams,
+		DB:           db,
+		TxMemPool:    s.txMemPool,
+		Generator:    blockTemplateGenerator,
+		CPUMiner:     s.cpuMiner,
+		TxIndex:      s.txIndex,
+		AddrIndex:    s.addrIndex,
+		CfIndex:      s.cfIndex,
+		FeeEstimator: s.feeEstimator,
+		Services:     s.services,
+		DiskUsage:    newDiskUsageCache(blockDbPath(cfg.DbType)),
+	}
+
+	// The REST server shares the configuration of the RPC server, but not
+	// its listeners or request timer.
+	restConfig := rpcConfig
+
 	if !cfg.DisableRPC {
 		// Setup listeners for the configured RPC listen addresses and
 		// TLS settings.
@@ -2928,23 +3362,9 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			return nil, errors.New("RPCS: No valid listen address")
 		}
 
-		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndex:      s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
-		})
+		rpcConfig.Listeners = rpcListeners
+		rpcConfig.RequestTimer = s.metrics.observeRPC
+		s.rpcServer, err = newRPCServer(&rpcConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -2956,6 +3376,36 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}()
 	}
 
+	// Setup the REST listeners after the RPC server so they are not leaked
+	// when setting it up fails, and close the RPC listeners when they fail.
+	var restListeners []net.Listener
+	if len(cfg.RESTListeners) > 0 {
+		restListeners, err = setupRESTListeners()
+		if err != nil {
+			for _, listener := range rpcConfig.Listeners {
+				listener.Close()
+			}
+			return nil, err
+		}
+		restConfig.Listeners = restListeners
+		s.restServer = newRESTServer(&restConfig)
+	}
+
+	// Listen for metrics last so the listener is not leaked when any of the
+	// steps above fail, and close the RPC and REST listeners when it fails.
+	if cfg.MetricsListen != "" {
+		if err := s.metrics.listen(cfg.MetricsListen); err != nil {
+			for _, listener := range rpcConfig.Listeners {
+				listener.Close()
+			}
+			for _, listener := range restListeners {
+				listener.Close()
+			}
+			return nil, fmt.Errorf("unable to listen for metrics on "+
+				"%s: %v", cfg.MetricsListen, err)
+		}
+	}
+
 	return &s, nil
 }
 
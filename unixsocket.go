@@ -0,0 +1,42 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"net"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// umaskMtx serializes changes to the process-wide file mode creation mask.
+var umaskMtx sync.Mutex
+
+// listenUnix returns a listener on a new Unix domain socket at the passed path
+// with the passed file mode.  The socket is created with a file mode creation
+// mask that already restricts it to the passed mode, so it is never accessible
+// with broader permissions, not even briefly.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	umaskMtx.Lock()
+	oldMask := syscall.Umask(int(^mode & os.ModePerm))
+	listener, err := net.Listen("unix", path)
+	syscall.Umask(oldMask)
+	umaskMtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// The mask only removes permissions, so set the exact mode afterwards
+	// in case the socket was created with fewer permissions than
+	// requested.
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,30 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix returns a listener on a new Unix domain socket at the passed path
+// with the passed file mode.  There is no file mode creation mask on these
+// platforms, and Windows only supports the read-only attribute of files, so
+// access to the socket is controlled by the permissions of its directory
+// instead.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestListenUnix ensures Unix domain sockets are created with the requested
+// file mode and that the file mode creation mask of the process is restored.
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "unixsocket")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldMask := syscall.Umask(022)
+	defer syscall.Umask(oldMask)
+
+	for i, mode := range []os.FileMode{0600, 0660, 0777} {
+		path := filepath.Join(dir, fmt.Sprintf("rpc%d.sock", i))
+		listener, err := listenUnix(path, mode)
+		if err != nil {
+			t.Fatalf("listenUnix: unexpected error: %v", err)
+		}
+		fi, err := os.Stat(path)
+		listener.Close()
+		if err != nil {
+			t.Fatalf("Stat: unexpected error: %v", err)
+		}
+		if got := fi.Mode().Perm(); got != mode {
+			t.Errorf("unexpected mode of socket -- got %v, want %v",
+				got, mode)
+		}
+		if mask := syscall.Umask(022); mask != 022 {
+			t.Fatalf("umask not restored -- got %o, want 022", mask)
+		}
+	}
+}
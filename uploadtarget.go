@@ -0,0 +1,170 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// uploadTargetTimeframe is the length of the cycle over which the
+	// upload target applies.
+	uploadTargetTimeframe = time.Hour * 24
+
+	// historicalBlockAge is the age after which a block is considered
+	// historical.  Historical blocks are no longer served to peers once
+	// the upload target is about to be reached.
+	historicalBlockAge = time.Hour * 24 * 7
+)
+
+// uploadTargetStats describes the state of the upload target for the current
+// cycle.
+type uploadTargetStats struct {
+	Timeframe             time.Duration
+	Target                uint64
+	TargetReached         bool
+	ServeHistoricalBlocks bool
+	BytesLeftInCycle      uint64
+	TimeLeftInCycle       time.Duration
+}
+
+// uploadTarget keeps track of the bytes sent to peers during the current cycle
+// in order to limit the upload traffic to a target per cycle.  A target of zero
+// means there is no limit.
+//
+// It is safe for concurrent access.
+type uploadTarget struct {
+	mtx sync.Mutex
+
+	// These fields are set at creation time and never modified.
+	target        uint64
+	timeframe     time.Duration
+	blockInterval time.Duration
+
+	cycleStart time.Time
+	cycleBytes uint64
+}
+
+// newUploadTarget returns a new upload target that allows the passed number of
+// bytes to be sent per cycle.  Blocks are expected at the passed interval.
+func newUploadTarget(target uint64, blockInterval time.Duration) *uploadTarget {
+	return &uploadTarget{
+		target:        target,
+		timeframe:     uploadTargetTimeframe,
+		blockInterval: blockInterval,
+	}
+}
+
+// maybeStartCycle starts a new cycle when the current one has ended.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) maybeStartCycle(now time.Time) {
+	if now.Sub(u.cycleStart) >= u.timeframe {
+		u.cycleStart = now
+		u.cycleBytes = 0
+	}
+}
+
+// timeLeft returns the amount of time left in the current cycle.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) timeLeft(now time.Time) time.Duration {
+	if u.cycleStart.IsZero() {
+		return u.timeframe
+	}
+	left := u.cycleStart.Add(u.timeframe).Sub(now)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// addBytes accounts for the passed number of bytes sent to a peer.
+func (u *uploadTarget) addBytes(n uint64) {
+	u.mtx.Lock()
+	u.maybeStartCycle(time.Now())
+	u.cycleBytes += n
+	u.mtx.Unlock()
+}
+
+// reached returns whether or not the upload target has been reached for the
+// current cycle.
+//
+// This function MUST be called with the upload target lock held.
+func (u *uploadTarget) reached(now time.Time, historical bool) bool {
+	if u.target == 0 {
+		return false
+	}
+	if now.Sub(u.cycleStart) >= u.timeframe {
+		return false
+	}
+
+	// Historical blocks are no longer served once the remaining bytes in
+	// the cycle are only enough to relay each new block that is expected
+	// for the remainder of the cycle once.
+	if historical {
+		blocks := uint64(u.timeLeft(now) / u.blockInterval)
+		buffer := blocks * wire.MaxBlockPayload
+		return buffer >= u.target || u.cycleBytes >= u.target-buffer
+	}
+	return u.cycleBytes >= u.target
+}
+
+// ServeHistoricalBlocks returns whether or not historical blocks may still be
+// served to peers during the current cycle.
+func (u *uploadTarget) ServeHistoricalBlocks() bool {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return !u.reached(time.Now(), true)
+}
+
+// Stats returns the state of the upload target for the current cycle.
+func (u *uploadTarget) Stats() *uploadTargetStats {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	now := time.Now()
+	stats := &uploadTargetStats{
+		Timeframe:             u.timeframe,
+		Target:                u.target,
+		TargetReached:         u.reached(now, false),
+		ServeHistoricalBlocks: !u.reached(now, true),
+	}
+	if u.target == 0 {
+		return stats
+	}
+	stats.TimeLeftInCycle = u.timeLeft(now)
+	if now.Sub(u.cycleStart) >= u.timeframe {
+		stats.BytesLeftInCycle = u.target
+	} else if u.cycleBytes < u.target {
+		stats.BytesLeftInCycle = u.target - u.cycleBytes
+	}
+	return stats
+}
+
+// isHistoricalBlock returns whether or not the block with the passed hash is
+// old enough to be considered historical.  Unknown blocks are not historical.
+func (s *server) isHistoricalBlock(hash *chainhash.Hash) bool {
+	header, err := s.chain.HeaderByHash(hash)
+	if err != nil {
+		return false
+	}
+	cutoff := s.timeSource.AdjustedTime().Add(-historicalBlockAge)
+	return header.Timestamp.Before(cutoff)
+}
+
+// blockRequestDenied returns whether or not a request from the passed peer
+// for the block with the passed hash must be denied because the upload target
+// is about to be reached.  Whitelisted peers are never denied.
+func (s *server) blockRequestDenied(sp *serverPeer, hash *chainhash.Hash) bool {
+	if sp.isWhitelisted || s.uploadTarget.ServeHistoricalBlocks() {
+		return false
+	}
+	return s.isHistoricalBlock(hash)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestUploadTarget ensures the upload target stops serving historical blocks
+// once only enough bytes to relay the expected new blocks are left, is reached
+// once all bytes are used, and starts over in the next cycle.
+func TestUploadTarget(t *testing.T) {
+	// Use a target that leaves room for 10 historical blocks in addition to
+	// the buffer for the new blocks expected in a cycle.
+	const blockInterval = time.Hour
+	blocksPerCycle := uint64(uploadTargetTimeframe / blockInterval)
+	buffer := blocksPerCycle * wire.MaxBlockPayload
+	target := buffer + 10*wire.MaxBlockPayload
+
+	u := newUploadTarget(target, blockInterval)
+	stats := u.Stats()
+	if stats.TargetReached || !stats.ServeHistoricalBlocks {
+		t.Fatalf("unexpected initial state: %+v", stats)
+	}
+	if stats.BytesLeftInCycle != target {
+		t.Fatalf("unexpected bytes left: got %d, want %d",
+			stats.BytesLeftInCycle, target)
+	}
+
+	u.addBytes(9 * wire.MaxBlockPayload)
+	if !u.ServeHistoricalBlocks() {
+		t.Fatal("historical blocks not served below the buffer")
+	}
+	// The buffer shrinks as time in the cycle passes, so send an extra
+	// block to make sure the buffer is entered.
+	u.addBytes(2 * wire.MaxBlockPayload)
+	if u.ServeHistoricalBlocks() {
+		t.Fatal("historical blocks served within the buffer")
+	}
+	if u.Stats().TargetReached {
+		t.Fatal("target reached before all bytes were sent")
+	}
+
+	u.addBytes(buffer)
+	stats = u.Stats()
+	if !stats.TargetReached || stats.BytesLeftInCycle != 0 {
+		t.Fatalf("unexpected state after sending target: %+v", stats)
+	}
+
+	// Pretend the cycle started a full timeframe ago to ensure the next
+	// cycle starts over.
+	u.mtx.Lock()
+	u.cycleStart = u.cycleStart.Add(-uploadTargetTimeframe)
+	u.mtx.Unlock()
+	stats = u.Stats()
+	if stats.TargetReached || !stats.ServeHistoricalBlocks ||
+		stats.BytesLeftInCycle != target {
+
+		t.Fatalf("unexpected state after cycle ended: %+v", stats)
+	}
+
+	// A zero target never limits anything.
+	u = newUploadTarget(0, blockInterval)
+	u.addBytes(buffer * 2)
+	stats = u.Stats()
+	if stats.TargetReached || !stats.ServeHistoricalBlocks {
+		t.Fatalf("unexpected state without target: %+v", stats)
+	}
+}
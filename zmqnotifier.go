@@ -0,0 +1,243 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/zmtp"
+	"github.com/btcsuite/btcutil"
+)
+
+// Topics published by the ZeroMQ notifier.  They match the topics published by
+// bitcoind so existing consumers work unchanged.
+const (
+	zmqTopicHashBlock = "hashblock"
+	zmqTopicHashTx    = "hashtx"
+	zmqTopicRawBlock  = "rawblock"
+	zmqTopicRawTx     = "rawtx"
+	zmqTopicSequence  = "sequence"
+)
+
+// Labels of the events published on the sequence topic.
+const (
+	zmqSequenceBlockConnected    = 'C'
+	zmqSequenceBlockDisconnected = 'D'
+	zmqSequenceTxAccepted        = 'A'
+	zmqSequenceTxRemoved         = 'R'
+)
+
+// zmqEndpoints returns the ZeroMQ endpoints configured for each topic.  Topics
+// without an endpoint are not included.
+func zmqEndpoints(cfg *config) map[string]string {
+	endpoints := make(map[string]string)
+	for topic, endpoint := range map[string]string{
+		zmqTopicHashBlock: cfg.ZMQPubHashBlock,
+		zmqTopicHashTx:    cfg.ZMQPubHashTx,
+		zmqTopicRawBlock:  cfg.ZMQPubRawBlock,
+		zmqTopicRawTx:     cfg.ZMQPubRawTx,
+		zmqTopicSequence:  cfg.ZMQPubSequence,
+	} {
+		if endpoint != "" {
+			endpoints[topic] = endpoint
+		}
+	}
+	return endpoints
+}
+
+// zmqTopic houses a topic that is published along with the publisher it is
+// published on and its message sequence number.
+type zmqTopic struct {
+	name      string
+	publisher *zmtp.Publisher
+	sequence  uint32
+}
+
+// zmqNotifier publishes block and transaction notifications using the ZeroMQ
+// message format used by bitcoind.  Each message consists of three frames: the
+// topic, the body, and the sequence number of the message within the topic as
+// a little-endian uint32.
+type zmqNotifier struct {
+	// mtx protects the topic sequence numbers and ensures messages are
+	// published in the order of their sequence numbers.
+	mtx    sync.Mutex
+	topics map[string]*zmqTopic
+
+	publishers []*zmtp.Publisher
+}
+
+// newZMQNotifier returns a notifier that publishes each of the topics in the
+// passed map on the associated endpoint.  Topics that share an endpoint are
+// published on the same socket.  Nil is returned when no topics are passed.
+func newZMQNotifier(endpoints map[string]string) (*zmqNotifier, error) {
+	if len(endpoints) == 0 {
+		return nil, nil
+	}
+
+	n := &zmqNotifier{topics: make(map[string]*zmqTopic)}
+	publishers := make(map[string]*zmtp.Publisher)
+	for topic, endpoint := range endpoints {
+		publisher, ok := publishers[endpoint]
+		if !ok {
+			publisher = zmtp.NewPublisher(zmtp.DefaultHighWaterMark)
+			if err := publisher.Listen(endpoint); err != nil {
+				n.stop()
+				return nil, err
+			}
+			publishers[endpoint] = publisher
+			n.publishers = append(n.publishers, publisher)
+			zmqpLog.Infof("ZMQ publisher listening on %s", endpoint)
+		}
+		n.topics[topic] = &zmqTopic{name: topic, publisher: publisher}
+		zmqpLog.Debugf("Publishing %s notifications on %s", topic,
+			endpoint)
+	}
+	return n, nil
+}
+
+// publish publishes the passed body on the passed topic if it is enabled.
+func (n *zmqNotifier) publish(topicName string, body []byte) {
+	topic, ok := n.topics[topicName]
+	if !ok {
+		return
+	}
+
+	n.mtx.Lock()
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], topic.sequence)
+	topic.sequence++
+	err := topic.publisher.Publish([]byte(topic.name), body, seq[:])
+	n.mtx.Unlock()
+	if err != nil {
+		zmqpLog.Debugf("Unable to publish %s notification: %v",
+			topic.name, err)
+	}
+}
+
+// enabled returns whether any of the passed topics are published.
+func (n *zmqNotifier) enabled(topics ...string) bool {
+	for _, topic := range topics {
+		if _, ok := n.topics[topic]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// reversedHash returns the passed hash in the byte order it is displayed in,
+// which is the order bitcoind publishes hashes in.
+func reversedHash(hash *chainhash.Hash) []byte {
+	b := make([]byte, chainhash.HashSize)
+	for i := range hash {
+		b[chainhash.HashSize-1-i] = hash[i]
+	}
+	return b
+}
+
+// sequenceBody returns the body of a message on the sequence topic for the
+// passed hash and event label.  The memory pool sequence number is appended as
+// a little-endian uint64 when it is not zero.
+func sequenceBody(hash *chainhash.Hash, label byte, mempoolSeq uint64) []byte {
+	body := make([]byte, 0, chainhash.HashSize+1+8)
+	body = append(body, reversedHash(hash)...)
+	body = append(body, label)
+	if mempoolSeq != 0 {
+		var seq [8]byte
+		binary.LittleEndian.PutUint64(seq[:], mempoolSeq)
+		body = append(body, seq[:]...)
+	}
+	return body
+}
+
+// notifyTx publishes the hashtx and rawtx notifications for the passed
+// transaction.
+func (n *zmqNotifier) notifyTx(tx *btcutil.Tx) {
+	n.publish(zmqTopicHashTx, reversedHash(tx.Hash()))
+	if n.enabled(zmqTopicRawTx) {
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			zmqpLog.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+			return
+		}
+		n.publish(zmqTopicRawTx, buf.Bytes())
+	}
+}
+
+// handleBlockchainNotification publishes notifications for blocks connected to
+// and disconnected from the main chain along with their transactions.
+func (n *zmqNotifier) handleBlockchainNotification(notification *blockchain.Notification) {
+	switch notification.Type {
+	case blockchain.NTBlockConnected:
+		block, ok := notification.Data.(*btcutil.Block)
+		if !ok {
+			zmqpLog.Warnf("Chain connected notification is not a block.")
+			break
+		}
+
+		for _, tx := range block.Transactions() {
+			n.notifyTx(tx)
+		}
+		n.publish(zmqTopicHashBlock, reversedHash(block.Hash()))
+		if n.enabled(zmqTopicRawBlock) {
+			blockBytes, err := block.Bytes()
+			if err != nil {
+				zmqpLog.Errorf("Unable to serialize block %v: %v",
+					block.Hash(), err)
+			} else {
+				n.publish(zmqTopicRawBlock, blockBytes)
+			}
+		}
+		n.publish(zmqTopicSequence, sequenceBody(block.Hash(),
+			zmqSequenceBlockConnected, 0))
+
+	case blockchain.NTBlockDisconnected:
+		block, ok := notification.Data.(*btcutil.Block)
+		if !ok {
+			zmqpLog.Warnf("Chain disconnected notification is not a block.")
+			break
+		}
+
+		for _, tx := range block.Transactions() {
+			n.notifyTx(tx)
+		}
+		n.publish(zmqTopicSequence, sequenceBody(block.Hash(),
+			zmqSequenceBlockDisconnected, 0))
+	}
+}
+
+// handleMempoolNotification publishes notifications for transactions accepted
+// into and removed from the memory pool.  Like bitcoind, removals due to the
+// transaction being included in a block are not published since they are
+// implied by the block connected event.
+func (n *zmqNotifier) handleMempoolNotification(notification *mempool.Notification) {
+	tx := notification.Tx
+	switch notification.Type {
+	case mempool.NTTxAccepted:
+		n.notifyTx(tx)
+		n.publish(zmqTopicSequence, sequenceBody(tx.Hash(),
+			zmqSequenceTxAccepted, notification.Sequence))
+
+	case mempool.NTTxRemoved:
+		if notification.Reason == mempool.RemovalConfirmed {
+			break
+		}
+		n.publish(zmqTopicSequence, sequenceBody(tx.Hash(),
+			zmqSequenceTxRemoved, notification.Sequence))
+	}
+}
+
+// stop closes all publishers.
+func (n *zmqNotifier) stop() {
+	for _, publisher := range n.publishers {
+		publisher.Close()
+	}
+}
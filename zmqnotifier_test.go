@@ -0,0 +1,101 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/mempool"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcd/zmtp"
+	"github.com/btcsuite/btclog"
+	"github.com/btcsuite/btcutil"
+)
+
+// TestZMQNotifier ensures mempool notifications are published on the sequence
+// and hashtx topics using the message format of bitcoind and that removals of
+// confirmed transactions are not published.
+func TestZMQNotifier(t *testing.T) {
+	// The log rotator is not initialized in tests.
+	zmqpLog.SetLevel(btclog.LevelOff)
+
+	n, err := newZMQNotifier(map[string]string{
+		zmqTopicSequence: "tcp://127.0.0.1:0",
+		zmqTopicHashTx:   "tcp://127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatalf("newZMQNotifier: unexpected error: %v", err)
+	}
+	defer n.stop()
+
+	// Both topics are published on the same socket.
+	if len(n.publishers) != 1 {
+		t.Fatalf("unexpected number of publishers: got %d, want 1",
+			len(n.publishers))
+	}
+	endpoint := "tcp://" + n.publishers[0].Addrs()[0].String()
+	sub, err := zmtp.Dial(endpoint)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer sub.Close()
+	if err := sub.Subscribe([]byte(zmqTopicSequence)); err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+
+	// Keep publishing until the subscription is known to the publisher
+	// since subscriptions are processed asynchronously.
+	tx := btcutil.NewTx(wire.NewMsgTx(wire.TxVersion))
+	hash := reversedHash(tx.Hash())
+	var frames [][]byte
+	for i := 0; i < 100 && frames == nil; i++ {
+		n.handleMempoolNotification(&mempool.Notification{
+			Type:     mempool.NTTxAccepted,
+			Tx:       tx,
+			Sequence: 7,
+		})
+		sub.SetDeadline(time.Now().Add(50 * time.Millisecond))
+		frames, _ = sub.Receive()
+	}
+	if frames == nil {
+		t.Fatal("no notification received")
+	}
+	wantBody := append(append(hash, 'A'), 7, 0, 0, 0, 0, 0, 0, 0)
+	if len(frames) != 3 || string(frames[0]) != zmqTopicSequence ||
+		!bytes.Equal(frames[1], wantBody) || len(frames[2]) != 4 {
+
+		t.Fatalf("unexpected accept notification: %x", frames)
+	}
+	prevSeq := binary.LittleEndian.Uint32(frames[2])
+
+	// The removal of a confirmed transaction is not published, so the
+	// next message must be the conflict removal with the next sequence.
+	n.handleMempoolNotification(&mempool.Notification{
+		Type:     mempool.NTTxRemoved,
+		Tx:       tx,
+		Sequence: 8,
+		Reason:   mempool.RemovalConfirmed,
+	})
+	n.handleMempoolNotification(&mempool.Notification{
+		Type:     mempool.NTTxRemoved,
+		Tx:       tx,
+		Sequence: 9,
+		Reason:   mempool.RemovalConflict,
+	})
+	sub.SetDeadline(time.Now().Add(5 * time.Second))
+	frames, err = sub.Receive()
+	if err != nil {
+		t.Fatalf("Receive: unexpected error: %v", err)
+	}
+	wantBody = append(append(hash, 'R'), 9, 0, 0, 0, 0, 0, 0, 0)
+	if len(frames) != 3 || !bytes.Equal(frames[1], wantBody) ||
+		binary.LittleEndian.Uint32(frames[2]) != prevSeq+1 {
+
+		t.Fatalf("unexpected removal notification: %x", frames)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package zmtp implements the publishing side of the ZeroMQ Message Transport
+Protocol (ZMTP) 3.0 in pure Go.
+
+Overview
+
+ZeroMQ publishers send multipart messages to all connected subscribers that
+subscribed to a prefix of the first part of the message, which is typically
+used as the topic.  This package implements a PUB socket that speaks ZMTP 3.0
+with the NULL security mechanism over TCP and Unix domain sockets, so existing
+ZeroMQ SUB sockets, such as those consuming the notifications of bitcoind, can
+connect to it without depending on the ZeroMQ C library.
+
+Endpoints are specified like in ZeroMQ as tcp://host:port for TCP, where a
+host of * listens on all interfaces, and ipc:///path/to/socket for Unix domain
+sockets.
+
+Like a ZeroMQ PUB socket, a Publisher never blocks when publishing.  Messages
+are queued for each subscriber up to a high water mark, and further messages
+are dropped for subscribers that do not keep up.
+
+A minimal Subscriber is provided as well.  It speaks the SUB side of the
+protocol and is mostly useful for testing.
+*/
+package zmtp
@@ -0,0 +1,325 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// greetingSize is the size of the greeting exchanged by both sides
+	// when a connection is established.
+	greetingSize = 64
+
+	// majorVersion and minorVersion are the protocol version advertised in
+	// the greeting.
+	majorVersion = 3
+	minorVersion = 0
+
+	// maxCommandSize is the maximum size of a command frame that is
+	// accepted from the remote side.
+	maxCommandSize = 4096
+
+	// handshakeTimeout is the maximum amount of time the greeting and
+	// READY command exchange may take.
+	handshakeTimeout = time.Second * 10
+
+	// Flags of a frame.
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+
+	// Names of the commands that are used.
+	cmdReady     = "READY"
+	cmdSubscribe = "SUBSCRIBE"
+	cmdCancel    = "CANCEL"
+	cmdPing      = "PING"
+	cmdPong      = "PONG"
+
+	// propSocketType is the name of the READY property which identifies
+	// the type of the socket.
+	propSocketType = "Socket-Type"
+
+	// Socket types that are used.
+	socketPub  = "PUB"
+	socketSub  = "SUB"
+	socketXSub = "XSUB"
+
+	// mechanismNull is the security mechanism advertised in the greeting.
+	// Only the NULL mechanism is supported.
+	mechanismNull = "NULL"
+)
+
+var (
+	// ErrClosed is returned when attempting to use a closed publisher or
+	// subscriber.
+	ErrClosed = errors.New("zmtp: use of closed socket")
+
+	// errFrameTooLarge is returned when the remote side sends a frame
+	// larger than allowed.
+	errFrameTooLarge = errors.New("zmtp: frame too large")
+)
+
+// ParseEndpoint splits the passed ZeroMQ style endpoint into a network and
+// address suitable for use with the net package.  Supported endpoints are
+// tcp://host:port and ipc:///path/to/socket.  A TCP host of * means all
+// interfaces.
+func ParseEndpoint(endpoint string) (network, address string, err error) {
+	switch {
+	case strings.HasPrefix(endpoint, "tcp://"):
+		address = strings.TrimPrefix(endpoint, "tcp://")
+		if strings.HasPrefix(address, "*:") {
+			address = address[1:]
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return "", "", fmt.Errorf("invalid endpoint %q: %v",
+				endpoint, err)
+		}
+		return "tcp", address, nil
+
+	case strings.HasPrefix(endpoint, "ipc://"):
+		address = strings.TrimPrefix(endpoint, "ipc://")
+		if address == "" {
+			return "", "", fmt.Errorf("invalid endpoint %q: missing "+
+				"path", endpoint)
+		}
+		return "unix", address, nil
+	}
+	return "", "", fmt.Errorf("unsupported endpoint %q -- must be "+
+		"tcp://host:port or ipc://path", endpoint)
+}
+
+// listen creates a listener for the passed endpoint.  A stale Unix domain
+// socket left behind at the path of an ipc endpoint is removed first.
+func listen(endpoint string) (net.Listener, error) {
+	network, address, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		fi, err := os.Lstat(address)
+		if err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return net.Listen(network, address)
+}
+
+// dial connects to the passed endpoint.
+func dial(endpoint string) (net.Conn, error) {
+	network, address, err := ParseEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return net.DialTimeout(network, address, handshakeTimeout)
+}
+
+// greeting returns the greeting to send when a connection is established.
+func greeting(asServer bool) []byte {
+	var g [greetingSize]byte
+	g[0] = 0xff
+	g[9] = 0x7f
+	g[10] = majorVersion
+	g[11] = minorVersion
+	copy(g[12:32], mechanismNull)
+	if asServer {
+		g[32] = 1
+	}
+	return g[:]
+}
+
+// readGreeting reads and validates the greeting of the remote side.
+func readGreeting(r io.Reader) error {
+	var g [greetingSize]byte
+	if _, err := io.ReadFull(r, g[:]); err != nil {
+		return err
+	}
+	if g[0] != 0xff || g[9]&0x01 != 0x01 {
+		return errors.New("zmtp: invalid greeting signature")
+	}
+	if g[10] < majorVersion {
+		return fmt.Errorf("zmtp: unsupported protocol version %d.%d",
+			g[10], g[11])
+	}
+	mechanism := string(bytes.TrimRight(g[12:32], "\x00"))
+	if mechanism != mechanismNull {
+		return fmt.Errorf("zmtp: unsupported security mechanism %q",
+			mechanism)
+	}
+	return nil
+}
+
+// writeFrame writes a single frame with the passed flags and body.  The long
+// flag is set as needed.
+func writeFrame(w *bufio.Writer, flags byte, body []byte) error {
+	if len(body) > 255 {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(body)))
+		w.WriteByte(flags | flagLong)
+		w.Write(size[:])
+	} else {
+		w.WriteByte(flags)
+		w.WriteByte(byte(len(body)))
+	}
+	_, err := w.Write(body)
+	return err
+}
+
+// writeMessage writes the passed frames as a single multipart message.
+func writeMessage(w *bufio.Writer, frames [][]byte) error {
+	for i, frame := range frames {
+		var flags byte
+		if i < len(frames)-1 {
+			flags = flagMore
+		}
+		if err := writeFrame(w, flags, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCommand writes a command with the passed name and data.
+func writeCommand(w *bufio.Writer, name string, data []byte) error {
+	body := make([]byte, 0, 1+len(name)+len(data))
+	body = append(body, byte(len(name)))
+	body = append(body, name...)
+	body = append(body, data...)
+	return writeFrame(w, flagCommand, body)
+}
+
+// readFrame reads a single frame and returns its flags and body.  An error is
+// returned when the frame is larger than the passed maximum size.
+func readFrame(r *bufio.Reader, maxSize uint64) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf[:])
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxSize {
+		return 0, nil, errFrameTooLarge
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return flags, body, nil
+}
+
+// parseCommand splits the body of a command frame into the command name and
+// data.
+func parseCommand(body []byte) (string, []byte, error) {
+	if len(body) == 0 || int(body[0]) > len(body)-1 {
+		return "", nil, errors.New("zmtp: malformed command")
+	}
+	nameLen := int(body[0])
+	return string(body[1 : 1+nameLen]), body[1+nameLen:], nil
+}
+
+// readyData returns the data of a READY command for the passed socket type.
+func readyData(socketType string) []byte {
+	data := make([]byte, 0, 1+len(propSocketType)+4+len(socketType))
+	data = append(data, byte(len(propSocketType)))
+	data = append(data, propSocketType...)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(socketType)))
+	data = append(data, size[:]...)
+	return append(data, socketType...)
+}
+
+// parseProperties parses the properties in the data of a READY command.
+func parseProperties(data []byte) (map[string]string, error) {
+	props := make(map[string]string)
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+4 {
+			return nil, errors.New("zmtp: malformed property")
+		}
+		name := string(data[1 : 1+nameLen])
+		data = data[1+nameLen:]
+		valueLen := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if uint64(len(data)) < uint64(valueLen) {
+			return nil, errors.New("zmtp: malformed property")
+		}
+		props[name] = string(data[:valueLen])
+		data = data[valueLen:]
+	}
+	return props, nil
+}
+
+// handshake exchanges the greeting and READY commands with the remote side
+// of the passed connection.  It returns the socket type of the remote side,
+// which must be one of the passed peer socket types.
+func handshake(conn net.Conn, r *bufio.Reader, w *bufio.Writer,
+	asServer bool, socketType string, peerTypes ...string) (string, error) {
+
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := w.Write(greeting(asServer)); err != nil {
+		return "", err
+	}
+	if err := writeCommand(w, cmdReady, readyData(socketType)); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	if err := readGreeting(r); err != nil {
+		return "", err
+	}
+	flags, body, err := readFrame(r, maxCommandSize)
+	if err != nil {
+		return "", err
+	}
+	if flags&flagCommand == 0 {
+		return "", errors.New("zmtp: expected READY command")
+	}
+	name, data, err := parseCommand(body)
+	if err != nil {
+		return "", err
+	}
+	if name != cmdReady {
+		return "", fmt.Errorf("zmtp: expected READY command, got %s",
+			name)
+	}
+	props, err := parseProperties(data)
+	if err != nil {
+		return "", err
+	}
+	peerType := props[propSocketType]
+	for _, t := range peerTypes {
+		if strings.EqualFold(peerType, t) {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("zmtp: incompatible socket type %q", peerType)
+}
@@ -0,0 +1,348 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmtp
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"sync"
+)
+
+const (
+	// DefaultHighWaterMark is the default maximum number of messages that
+	// are queued for a subscriber before further messages are dropped.
+	DefaultHighWaterMark = 1000
+)
+
+// subscription messages sent by ZMTP 3.0 subscribers start with one of these
+// bytes followed by the subscribed prefix.
+const (
+	msgUnsubscribe = 0x00
+	msgSubscribe   = 0x01
+)
+
+// outMessage is a message queued for a subscriber.  It is either a published
+// multipart message or, when the command name is set, a command.
+type outMessage struct {
+	frames  [][]byte
+	command string
+	data    []byte
+}
+
+// pubConn houses a connection to a subscriber along with its subscriptions and
+// queue of outgoing messages.
+type pubConn struct {
+	conn      net.Conn
+	sendQueue chan outMessage
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// subs counts the subscriptions to each prefix and is protected by the
+	// subsMtx mutex.  ZeroMQ allows subscribing to the same prefix multiple
+	// times, in which case it must be unsubscribed as many times.
+	subsMtx sync.Mutex
+	subs    map[string]int
+}
+
+// close closes the connection to the subscriber.  It is safe to call multiple
+// times.
+func (c *pubConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.quit)
+		c.conn.Close()
+	})
+}
+
+// subscribed returns whether or not the subscriber subscribed to a prefix of
+// the passed topic.
+func (c *pubConn) subscribed(topic []byte) bool {
+	c.subsMtx.Lock()
+	defer c.subsMtx.Unlock()
+	for prefix := range c.subs {
+		if bytes.HasPrefix(topic, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// subscribe adds or removes a subscription to the passed prefix.
+func (c *pubConn) subscribe(prefix []byte, subscribe bool) {
+	c.subsMtx.Lock()
+	defer c.subsMtx.Unlock()
+	key := string(prefix)
+	if subscribe {
+		c.subs[key]++
+		return
+	}
+	if c.subs[key] > 1 {
+		c.subs[key]--
+		return
+	}
+	delete(c.subs, key)
+}
+
+// Publisher is a ZeroMQ PUB socket.  It accepts connections from subscribers on
+// any number of endpoints and sends each published message to all subscribers
+// that subscribed to a prefix of its first frame.
+//
+// It is safe for concurrent access.
+type Publisher struct {
+	hwm int
+
+	mtx       sync.Mutex
+	listeners []net.Listener
+	conns     map[*pubConn]struct{}
+	closed    bool
+
+	wg sync.WaitGroup
+}
+
+// NewPublisher returns a new publisher which queues up to the passed number of
+// messages for each subscriber.  Listen must be called to accept connections
+// from subscribers.
+func NewPublisher(hwm int) *Publisher {
+	if hwm <= 0 {
+		hwm = DefaultHighWaterMark
+	}
+	return &Publisher{
+		hwm:   hwm,
+		conns: make(map[*pubConn]struct{}),
+	}
+}
+
+// Listen starts accepting connections from subscribers on the passed endpoint.
+// See ParseEndpoint for the supported endpoints.
+func (p *Publisher) Listen(endpoint string) error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+
+	listener, err := listen(endpoint)
+	if err != nil {
+		return err
+	}
+	p.listeners = append(p.listeners, listener)
+	p.wg.Add(1)
+	go p.listenHandler(listener)
+	return nil
+}
+
+// Addrs returns the addresses the publisher is listening on.
+func (p *Publisher) Addrs() []net.Addr {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	addrs := make([]net.Addr, 0, len(p.listeners))
+	for _, listener := range p.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+// listenHandler accepts connections from subscribers on the passed listener.
+// It must be run as a goroutine.
+func (p *Publisher) listenHandler(listener net.Listener) {
+	defer p.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			p.mtx.Lock()
+			closed := p.closed
+			p.mtx.Unlock()
+			if !closed {
+				log.Errorf("Can't accept connection on %s: %v",
+					listener.Addr(), err)
+			}
+			return
+		}
+		p.wg.Add(1)
+		go p.connHandler(conn)
+	}
+}
+
+// connHandler performs the handshake with a new subscriber and then handles
+// its subscriptions until the connection is closed.  It must be run as a
+// goroutine.
+func (p *Publisher) connHandler(conn net.Conn) {
+	defer p.wg.Done()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	_, err := handshake(conn, r, w, true, socketPub, socketSub, socketXSub)
+	if err != nil {
+		log.Debugf("Handshake with subscriber %s failed: %v",
+			conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+
+	c := &pubConn{
+		conn:      conn,
+		sendQueue: make(chan outMessage, p.hwm),
+		quit:      make(chan struct{}),
+		subs:      make(map[string]int),
+	}
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		conn.Close()
+		return
+	}
+	p.conns[c] = struct{}{}
+	p.mtx.Unlock()
+	log.Debugf("New subscriber %s", conn.RemoteAddr())
+
+	p.wg.Add(1)
+	go p.writeHandler(c, w)
+	err = p.readHandler(c, r)
+	log.Debugf("Subscriber %s disconnected: %v", conn.RemoteAddr(), err)
+
+	p.mtx.Lock()
+	delete(p.conns, c)
+	p.mtx.Unlock()
+	c.close()
+}
+
+// readHandler reads the subscriptions and commands sent by the subscriber
+// until an error occurs.
+func (p *Publisher) readHandler(c *pubConn, r *bufio.Reader) error {
+	var more bool
+	for {
+		flags, body, err := readFrame(r, maxCommandSize)
+		if err != nil {
+			return err
+		}
+
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(body)
+			if err != nil {
+				return err
+			}
+			switch name {
+			case cmdSubscribe:
+				c.subscribe(data, true)
+			case cmdCancel:
+				c.subscribe(data, false)
+			case cmdPing:
+				// The ping data is a TTL followed by the context
+				// that is echoed back.
+				var context []byte
+				if len(data) > 2 {
+					context = data[2:]
+				}
+				queueCommand(c, cmdPong, context)
+			}
+			continue
+		}
+
+		// Only single frame messages, which are not a continuation of a
+		// multipart message, can be subscriptions.
+		isSubscription := !more && flags&flagMore == 0 && len(body) > 0
+		more = flags&flagMore != 0
+		if !isSubscription {
+			continue
+		}
+		switch body[0] {
+		case msgSubscribe:
+			c.subscribe(body[1:], true)
+		case msgUnsubscribe:
+			c.subscribe(body[1:], false)
+		}
+	}
+}
+
+// queueCommand queues a command for the subscriber.  Commands go through the
+// send queue so they don't interleave with published messages.  The command is
+// dropped when the queue is full.
+func queueCommand(c *pubConn, name string, data []byte) {
+	select {
+	case c.sendQueue <- outMessage{command: name, data: data}:
+	default:
+	}
+}
+
+// writeHandler writes the queued messages to the subscriber until the
+// connection is closed.  It must be run as a goroutine.
+func (p *Publisher) writeHandler(c *pubConn, w *bufio.Writer) {
+	defer p.wg.Done()
+	for {
+		select {
+		case msg := <-c.sendQueue:
+			var err error
+			if msg.command != "" {
+				err = writeCommand(w, msg.command, msg.data)
+			} else {
+				err = writeMessage(w, msg.frames)
+			}
+			// Only flush once the queue is drained to batch writes.
+			if err == nil && len(c.sendQueue) == 0 {
+				err = w.Flush()
+			}
+			if err != nil {
+				log.Debugf("Can't write to subscriber %s: %v",
+					c.conn.RemoteAddr(), err)
+				c.close()
+				return
+			}
+
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Publish sends the multipart message made up of the passed frames to all
+// subscribers that subscribed to a prefix of the first frame.  It never
+// blocks.  The message is dropped for subscribers that already have the
+// maximum number of messages queued.  The frames must not be modified after
+// they are passed to Publish.
+func (p *Publisher) Publish(frames ...[]byte) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if p.closed {
+		return ErrClosed
+	}
+	for c := range p.conns {
+		if !c.subscribed(frames[0]) {
+			continue
+		}
+		select {
+		case c.sendQueue <- outMessage{frames: frames}:
+		default:
+			log.Tracef("Dropping message for subscriber %s that "+
+				"reached the high water mark", c.conn.RemoteAddr())
+		}
+	}
+	return nil
+}
+
+// Close stops listening for subscribers, disconnects all subscribers, and
+// waits for all goroutines to finish.  Queued messages that were not written
+// yet are dropped.
+func (p *Publisher) Close() error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return ErrClosed
+	}
+	p.closed = true
+	for _, listener := range p.listeners {
+		listener.Close()
+	}
+	for c := range p.conns {
+		c.close()
+	}
+	p.mtx.Unlock()
+
+	p.wg.Wait()
+	return nil
+}
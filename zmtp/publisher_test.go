@@ -0,0 +1,177 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmtp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseEndpoint ensures endpoints are translated to the expected network
+// and address and invalid endpoints are rejected.
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		network  string
+		address  string
+		valid    bool
+	}{
+		{"tcp://127.0.0.1:28332", "tcp", "127.0.0.1:28332", true},
+		{"tcp://*:28332", "tcp", ":28332", true},
+		{"tcp://[::1]:28332", "tcp", "[::1]:28332", true},
+		{"ipc:///tmp/btcd.zmq", "unix", "/tmp/btcd.zmq", true},
+		{"tcp://127.0.0.1", "", "", false},
+		{"ipc://", "", "", false},
+		{"udp://127.0.0.1:28332", "", "", false},
+		{"127.0.0.1:28332", "", "", false},
+	}
+
+	for i, test := range tests {
+		network, address, err := ParseEndpoint(test.endpoint)
+		if (err == nil) != test.valid {
+			t.Errorf("ParseEndpoint #%d (%s): unexpected error: %v", i,
+				test.endpoint, err)
+			continue
+		}
+		if network != test.network || address != test.address {
+			t.Errorf("ParseEndpoint #%d (%s): got %s %s, want %s %s",
+				i, test.endpoint, network, address, test.network,
+				test.address)
+		}
+	}
+}
+
+// waitForSubscriptions waits until the publisher knows about the passed number
+// of subscriptions across all of its subscribers.
+func waitForSubscriptions(t *testing.T, p *Publisher, want int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var n int
+		p.mtx.Lock()
+		for c := range p.conns {
+			c.subsMtx.Lock()
+			for _, count := range c.subs {
+				n += count
+			}
+			c.subsMtx.Unlock()
+		}
+		p.mtx.Unlock()
+		if n == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %d subscriptions", want)
+}
+
+// receive receives the next message from the passed subscriber and ensures it
+// matches the expected frames.
+func receive(t *testing.T, s *Subscriber, want ...[]byte) {
+	s.SetDeadline(time.Now().Add(5 * time.Second))
+	frames, err := s.Receive()
+	if err != nil {
+		t.Fatalf("Receive: unexpected error: %v", err)
+	}
+	if len(frames) != len(want) {
+		t.Fatalf("Receive: got %d frames, want %d", len(frames),
+			len(want))
+	}
+	for i := range frames {
+		if !bytes.Equal(frames[i], want[i]) {
+			t.Fatalf("Receive: frame #%d: got %x, want %x", i,
+				frames[i], want[i])
+		}
+	}
+}
+
+// testPublisher ensures messages published on the passed endpoint are delivered
+// to subscribers according to their subscriptions.
+func testPublisher(t *testing.T, endpoint string) {
+	p := NewPublisher(0)
+	defer p.Close()
+	if err := p.Listen(endpoint); err != nil {
+		t.Fatalf("Listen: unexpected error: %v", err)
+	}
+	addr := p.Addrs()[0]
+	if addr.Network() == "tcp" {
+		endpoint = "tcp://" + addr.String()
+	}
+
+	// Connect two subscribers where the first one subscribes to blocks
+	// only and the second one to everything.
+	blockSub, err := Dial(endpoint)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer blockSub.Close()
+	allSub, err := Dial(endpoint)
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer allSub.Close()
+	if err := blockSub.Subscribe([]byte("hashblock")); err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	if err := allSub.Subscribe(nil); err != nil {
+		t.Fatalf("Subscribe: unexpected error: %v", err)
+	}
+	waitForSubscriptions(t, p, 2)
+
+	// Include a frame that requires the long frame encoding.
+	largeBody := bytes.Repeat([]byte{0xaa}, 1000)
+	seq := []byte{0, 0, 0, 0}
+	p.Publish([]byte("hashtx"), largeBody, seq)
+	p.Publish([]byte("hashblock"), []byte{0x01}, seq)
+
+	receive(t, allSub, []byte("hashtx"), largeBody, seq)
+	receive(t, allSub, []byte("hashblock"), []byte{0x01}, seq)
+	receive(t, blockSub, []byte("hashblock"), []byte{0x01}, seq)
+
+	// Unsubscribing the second subscriber means only the first one
+	// receives further blocks.
+	if err := allSub.Unsubscribe(nil); err != nil {
+		t.Fatalf("Unsubscribe: unexpected error: %v", err)
+	}
+	waitForSubscriptions(t, p, 1)
+	p.Publish([]byte("hashblock"), []byte{0x02}, seq)
+	receive(t, blockSub, []byte("hashblock"), []byte{0x02}, seq)
+	allSub.SetDeadline(time.Now().Add(100 * time.Millisecond))
+	if frames, err := allSub.Receive(); err == nil {
+		t.Fatalf("unsubscribed subscriber received %x", frames)
+	}
+
+	// Closing the publisher disconnects the subscribers.
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	blockSub.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := blockSub.Receive(); err == nil {
+		t.Fatal("Receive: expected error after publisher was closed")
+	}
+	if err := p.Publish([]byte("hashblock")); err != ErrClosed {
+		t.Fatalf("Publish: unexpected error after close: %v", err)
+	}
+}
+
+// TestPublisher ensures the publisher works over both TCP and Unix domain
+// sockets.
+func TestPublisher(t *testing.T) {
+	t.Run("tcp", func(t *testing.T) {
+		testPublisher(t, "tcp://127.0.0.1:0")
+	})
+
+	t.Run("ipc", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "zmtp")
+		if err != nil {
+			t.Fatalf("unable to create temp dir: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		testPublisher(t, "ipc://"+filepath.Join(dir, "pub.sock"))
+	})
+}
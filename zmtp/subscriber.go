@@ -0,0 +1,128 @@
+// Copyright (c) 2017 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmtp
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+)
+
+// maxMessageSize is the maximum size of a frame of a published message that is
+// accepted by a subscriber.
+const maxMessageSize = 64 * 1024 * 1024
+
+// Subscriber is a minimal ZeroMQ SUB socket connected to a single publisher.
+// It is primarily intended for tests and simple tools that consume the
+// notifications of a Publisher.
+//
+// Receive must not be called concurrently, but the other methods are safe for
+// concurrent access.
+type Subscriber struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// wmtx protects the writer since subscriptions and pongs may be sent
+	// concurrently with Receive.
+	wmtx sync.Mutex
+	w    *bufio.Writer
+}
+
+// Dial connects to the publisher at the passed endpoint.  See ParseEndpoint for
+// the supported endpoints.
+func Dial(endpoint string) (*Subscriber, error) {
+	conn, err := dial(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	s := &Subscriber{
+		conn: conn,
+		r:    bufio.NewReader(conn),
+		w:    bufio.NewWriter(conn),
+	}
+	_, err = handshake(conn, s.r, s.w, false, socketSub, socketPub)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// sendSubscription sends a ZMTP 3.0 subscription message for the passed prefix.
+func (s *Subscriber) sendSubscription(kind byte, prefix []byte) error {
+	body := make([]byte, 0, 1+len(prefix))
+	body = append(body, kind)
+	body = append(body, prefix...)
+
+	s.wmtx.Lock()
+	defer s.wmtx.Unlock()
+	if err := writeFrame(s.w, 0, body); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Subscribe subscribes to all messages with a first frame that starts with the
+// passed prefix.  An empty prefix subscribes to all messages.
+func (s *Subscriber) Subscribe(prefix []byte) error {
+	return s.sendSubscription(msgSubscribe, prefix)
+}
+
+// Unsubscribe removes a subscription previously added with Subscribe.
+func (s *Subscriber) Unsubscribe(prefix []byte) error {
+	return s.sendSubscription(msgUnsubscribe, prefix)
+}
+
+// Receive waits for the next published message and returns its frames.  Pings
+// from the publisher are answered transparently.
+func (s *Subscriber) Receive() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		flags, body, err := readFrame(s.r, maxMessageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		if flags&flagCommand != 0 {
+			name, data, err := parseCommand(body)
+			if err != nil {
+				return nil, err
+			}
+			if name == cmdPing {
+				var context []byte
+				if len(data) > 2 {
+					context = data[2:]
+				}
+				s.wmtx.Lock()
+				err = writeCommand(s.w, cmdPong, context)
+				if err == nil {
+					err = s.w.Flush()
+				}
+				s.wmtx.Unlock()
+				if err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// SetDeadline sets the deadline for Receive and the subscription methods.  A
+// zero value disables the deadline.
+func (s *Subscriber) SetDeadline(t time.Time) error {
+	return s.conn.SetDeadline(t)
+}
+
+// Close disconnects from the publisher.
+func (s *Subscriber) Close() error {
+	return s.conn.Close()
+}